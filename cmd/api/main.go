@@ -1,63 +1,525 @@
 package main
 
 import (
+	"crypto/rand"
+	"expvar"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/lamboktulussimamora/gra-project/internal/config"
 	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/authz"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/event"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/session"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/directory"
 	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/mailer"
 	"github.com/lamboktulussimamora/gra-project/internal/interface/middleware"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/openapi"
 	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/rpc"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/saml"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/sender"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/upstream"
 	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+
+	// "sqlite3" driver for the SQL session, identity and tuple stores
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
-	// Create repository
-	userRepo := repository.NewInMemoryUserRepository()
+	// Load configuration from the environment
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
-	// Initialize password service
-	passwordParams := auth.ArgonParams{
-		Memory:      64 * 1024,
-		Iterations:  3,
-		Parallelism: 2,
-		SaltLength:  16,
-		KeyLength:   32,
+	// Create repository, persisted to a JSON file when a store path is configured. The
+	// repository is also the outbox of the events raised by users.
+	memoryUserRepo := repository.NewInMemoryUserRepository()
+	var userRepo user.Repository = memoryUserRepo
+	var userOutbox event.Outbox = memoryUserRepo
+	if cfg.UserStorePath != "" {
+		fileRepo, err := repository.NewFileUserRepository(cfg.UserStorePath)
+		if err != nil {
+			log.Fatalf("Failed to open user store: %v", err)
+		}
+		userRepo = fileRepo
+		userOutbox = fileRepo
 	}
-	passwordService := auth.NewPasswordService(passwordParams)
+
+	// Register the default tenant and the configured ones; each has its own users and tokens
+	tenantRepo := repository.NewInMemoryTenantRepository()
+	tenants := append([]config.TenantEntry{{ID: tenant.DefaultID, Name: "Default"}}, cfg.Tenants.Tenants...)
+	for _, entry := range tenants {
+		if err := tenantRepo.Save(&tenant.Tenant{ID: entry.ID, Name: entry.Name, CreatedAt: time.Now()}); err != nil {
+			log.Fatalf("Failed to register tenant %s: %v", entry.ID, err)
+		}
+	}
+	tenantMiddleware := middleware.NewTenantMiddleware(tenantRepo, middleware.TenantResolution{
+		Header:     cfg.Tenants.Header,
+		PathPrefix: cfg.Tenants.PathPrefix,
+		BaseDomain: cfg.Tenants.BaseDomain,
+	})
+
+	// Initialize password service, bounded so bursts of logins cannot exhaust memory
+	passwordParams := cfg.ArgonParams()
+	passwordService := auth.NewLimitedPasswordService(
+		auth.NewPasswordService(passwordParams, auth.WithPepper(cfg.Pepper)),
+		passwordParams,
+		cfg.HashLimiter,
+	)
+	expvar.Publish("password_hashing", expvar.Func(func() interface{} { return passwordService.Stats() }))
 
 	// Initialize JWT service
 	jwtConfig := auth.JWTConfig{
-		SecretKey:     "your-secret-key-here", // In production, use environment variables for secrets
-		TokenDuration: time.Hour * 24,         // 24 hours token validity
+		SecretKey:     cfg.JWTSecret,
+		TokenDuration: time.Hour * 24, // 24 hours token validity
 	}
 	jwtService := auth.NewJWTService(jwtConfig)
 
+	// Configure the password policy, optionally with an offline breached-password corpus
+	passwordPolicy := auth.DefaultPasswordPolicy()
+	if cfg.BreachedPasswordsPath != "" {
+		corpus, err := auth.LoadHashPrefixCorpus(cfg.BreachedPasswordsPath)
+		if err != nil {
+			log.Fatalf("Failed to load breached password corpus: %v", err)
+		}
+		passwordPolicy.Breached = corpus
+	}
+
 	// Create use cases
-	userUseCase := usecase.NewUserUseCase(userRepo, passwordService, jwtService)
+	emailSender := mailer.NewLogMailer()
+
+	// Webhooks notify downstream systems of user lifecycle events; deliveries are sent in the
+	// background and retried with backoff
+	webhookConfig := usecase.DefaultWebhookConfig()
+	webhookConfig.MaxAttempts = cfg.Webhooks.MaxAttempts
+	webhookConfig.AllowHTTP = cfg.Webhooks.AllowHTTP
+	webhookConfig.AllowPrivateNetworks = cfg.Webhooks.AllowPrivateNetworks
+	var senderOpts []sender.HTTPSenderOption
+	if cfg.Webhooks.AllowPrivateNetworks {
+		senderOpts = append(senderOpts, sender.WithPrivateNetworks())
+	}
+	webhookUseCase := usecase.NewWebhookUseCase(repository.NewInMemoryWebhookEndpointRepository(),
+		repository.NewInMemoryWebhookDeliveryRepository(), sender.NewHTTPSender(cfg.Webhooks.Timeout, senderOpts...), webhookConfig)
+	go func() {
+		for range time.Tick(5 * time.Second) {
+			if _, err := webhookUseCase.DeliverDue(); err != nil {
+				log.Printf("Error delivering webhooks: %v", err)
+			}
+		}
+	}()
+
+	// User events are relayed from the outbox to in-process subscribers, at least once
+	eventBus := event.NewBus()
+	webhookUseCase.SubscribeUserEvents(eventBus, repository.NewInMemoryProcessedEventRepository())
+	eventRelay := usecase.NewEventRelay(userOutbox, eventBus, usecase.DefaultEventRelayConfig())
+	go func() {
+		for range time.Tick(time.Second) {
+			if _, err := eventRelay.RelayPending(); err != nil {
+				log.Printf("Error relaying events: %v", err)
+			}
+		}
+	}()
+
+	userOpts := []usecase.UserUseCaseOption{
+		usecase.WithPasswordPolicy(passwordPolicy),
+		usecase.WithEventPublisher(webhookUseCase),
+		usecase.WithPasswordReset(repository.NewInMemoryPasswordResetRepository(), emailSender, time.Hour),
+		usecase.WithRefreshTokens(repository.NewInMemoryRefreshTokenRepository(), 30*24*time.Hour),
+	}
+
+	// Cookie sessions for browser frontends, stored in SQL when a database is configured. Users
+	// disabled by an operator or deprovisioned over SCIM are signed out of them.
+	var sessionRepo session.Repository
+	if cfg.Sessions.Enabled {
+		sessionRepo = repository.NewInMemorySessionRepository()
+		if cfg.Sessions.DBDriver != "" {
+			sqlRepo, err := repository.OpenSQLSessionRepository(cfg.Sessions.DBDriver, cfg.Sessions.DBDSN)
+			if err != nil {
+				log.Fatalf("Failed to open session store: %v", err)
+			}
+			sessionRepo = sqlRepo
+		}
+		userOpts = append(userOpts, usecase.WithSessions(sessionRepo))
+	}
+	if cfg.GenericRegistration {
+		userOpts = append(userOpts, usecase.WithGenericRegistration(emailSender))
+	}
+	if cfg.LDAP.URL != "" {
+		ldapAuthenticator, err := directory.NewLDAPAuthenticator(directory.LDAPConfig{
+			URL:          cfg.LDAP.URL,
+			StartTLS:     cfg.LDAP.StartTLS,
+			BindDN:       cfg.LDAP.BindDN,
+			BindPassword: cfg.LDAP.BindPassword,
+			BaseDN:       cfg.LDAP.BaseDN,
+			UserFilter:   cfg.LDAP.UserFilter,
+			GroupBaseDN:  cfg.LDAP.GroupBaseDN,
+			GroupFilter:  cfg.LDAP.GroupFilter,
+			GroupRoles:   cfg.LDAP.GroupRoles,
+			TenantID:     cfg.LDAP.TenantID,
+		}, userRepo)
+		if err != nil {
+			log.Fatalf("Failed to configure LDAP login: %v", err)
+		}
+		userOpts = append(userOpts, usecase.WithAuthenticators(ldapAuthenticator))
+	}
+	userUseCase := usecase.NewUserUseCase(userRepo, passwordService, jwtService, userOpts...)
+	apiKeyUseCase := usecase.NewAPIKeyUseCase(repository.NewInMemoryAPIKeyRepository(), userRepo)
+
+	// OAuth 2.1 authorization server for internal apps that delegate login to this service
+	oauthIssuer := cfg.OAuthIssuer
+	if oauthIssuer == "" {
+		oauthIssuer = "http://localhost:8080"
+	}
+	// ID tokens are signed with RSA keys published at the JWKS endpoint. A generated key changes on every
+	// restart, which invalidates the ID tokens relying parties hold, so configure keys in production.
+	var idTokenKeys *auth.KeySet
+	if len(cfg.OIDCSigningKeyFiles) > 0 {
+		idTokenKeys, err = auth.LoadKeySet(cfg.OIDCSigningKeyFiles...)
+	} else {
+		log.Println("OIDC_SIGNING_KEY_FILES is not set; generating a temporary ID token signing key")
+		idTokenKeys, err = auth.GenerateKeySet()
+	}
+	if err != nil {
+		log.Fatalf("Failed to load ID token signing keys: %v", err)
+	}
+	oauthClientRepo := repository.NewInMemoryOAuthClientRepository()
+	oauthUseCase := usecase.NewOAuthUseCase(usecase.OAuthRepositories{
+		Clients:       oauthClientRepo,
+		Codes:         repository.NewInMemoryAuthorizationCodeRepository(),
+		RefreshTokens: repository.NewInMemoryRefreshTokenRepository(),
+		Consents:      repository.NewInMemoryConsentRepository(),
+		Revocations:   repository.NewInMemoryRevocationRepository(),
+	}, userRepo, jwtService, usecase.DefaultOAuthConfig(oauthIssuer), usecase.WithIDTokens(idTokenKeys))
+	oidcUseCase := usecase.NewOIDCUseCase(oauthClientRepo, userRepo, idTokenKeys, oauthIssuer)
+
+	// Sign-in through upstream identity providers, linked to local users by verified email
+	var externalProviders []identity.Provider
+	for _, settings := range cfg.ExternalLogin.Providers {
+		redirectURL := settings.RedirectURL
+		if redirectURL == "" {
+			redirectURL = oauthIssuer + "/auth/" + settings.Name + "/callback"
+		}
+		provider, err := upstream.NewProvider(upstream.Config{
+			Name:         settings.Name,
+			Issuer:       settings.Issuer,
+			ClientID:     settings.ClientID,
+			ClientSecret: settings.ClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       settings.Scopes,
+			AuthURL:      settings.AuthURL,
+			TokenURL:     settings.TokenURL,
+			UserInfoURL:  settings.UserInfoURL,
+			TrustEmail:   settings.TrustEmail,
+		}, nil)
+		if err != nil {
+			log.Fatalf("Invalid identity provider: %v", err)
+		}
+		externalProviders = append(externalProviders, provider)
+	}
+	for _, settings := range cfg.ExternalLogin.SAMLProviders {
+		samlConfig := saml.Config{
+			Name:        settings.Name,
+			EntityID:    settings.EntityID,
+			ACSURL:      settings.ACSURL,
+			IdPEntityID: settings.IdPEntityID,
+			IdPSSOURL:   settings.IdPSSOURL,
+			Attributes: saml.AttributeMap{
+				Email:      settings.EmailAttribute,
+				GivenName:  settings.FirstNameAttribute,
+				FamilyName: settings.LastNameAttribute,
+			},
+			TrustEmail: settings.TrustEmail,
+		}
+		if samlConfig.EntityID == "" {
+			samlConfig.EntityID = oauthIssuer + "/auth/" + settings.Name + "/metadata"
+		}
+		if samlConfig.ACSURL == "" {
+			samlConfig.ACSURL = oauthIssuer + "/auth/" + settings.Name + "/acs"
+		}
+		if settings.IdPMetadataFile != "" {
+			metadata, err := saml.LoadIdPMetadata(settings.IdPMetadataFile)
+			if err != nil {
+				log.Fatalf("Failed to load SAML metadata for %s: %v", settings.Name, err)
+			}
+			samlConfig.IdPEntityID, samlConfig.IdPSSOURL = metadata.EntityID, metadata.SSOURL
+			samlConfig.IdPCertificates = metadata.Certificates
+		} else {
+			certs, err := saml.LoadCertificates(settings.IdPCertFile)
+			if err != nil {
+				log.Fatalf("Failed to load SAML certificate for %s: %v", settings.Name, err)
+			}
+			samlConfig.IdPCertificates = certs
+		}
+		provider, err := saml.NewServiceProvider(samlConfig, nil)
+		if err != nil {
+			log.Fatalf("Invalid SAML identity provider: %v", err)
+		}
+		externalProviders = append(externalProviders, provider)
+	}
+	var linkRepo identity.Repository = repository.NewInMemoryLinkedIdentityRepository()
+	if cfg.ExternalLogin.DBDriver != "" {
+		sqlRepo, err := repository.OpenSQLLinkedIdentityRepository(cfg.ExternalLogin.DBDriver, cfg.ExternalLogin.DBDSN)
+		if err != nil {
+			log.Fatalf("Failed to open linked identity store: %v", err)
+		}
+		linkRepo = sqlRepo
+	}
+	externalLoginConfig := usecase.DefaultExternalLoginConfig()
+	externalLoginConfig.CreateUsers = !cfg.ExternalLogin.LinkOnly
+	externalLoginUseCase := usecase.NewExternalLoginUseCase(externalProviders, linkRepo,
+		repository.NewInMemoryLoginStateRepository(), userRepo, jwtService, externalLoginConfig)
+	go func() {
+		for range time.Tick(5 * time.Minute) {
+			if err := externalLoginUseCase.PurgeExpired(); err != nil {
+				log.Printf("Error purging expired login states: %v", err)
+			}
+		}
+	}()
+
+	// Cookie sessions for browser frontends
+	var sessionUseCase *usecase.SessionUseCase
+	if cfg.Sessions.Enabled {
+		sessionUseCase = usecase.NewSessionUseCase(sessionRepo, userRepo, userUseCase, usecase.SessionConfig{
+			IdleTimeout:     cfg.Sessions.IdleTimeout,
+			AbsoluteTimeout: cfg.Sessions.AbsoluteTimeout,
+		})
+		go func() {
+			for range time.Tick(5 * time.Minute) {
+				if err := sessionUseCase.PurgeExpired(); err != nil {
+					log.Printf("Error purging expired sessions: %v", err)
+				}
+			}
+		}()
+	}
+
+	// SCIM provisioning; deprovisioned users are disabled and signed out of their sessions
+	scimUseCase := usecase.NewSCIMUseCase(userRepo, repository.NewInMemoryGroupRepository(), userUseCase)
+
+	// Organizations with teams; members are invited by email with signed, expiring links
+	inviteKey := cfg.Invitations.SigningKey
+	if len(inviteKey) == 0 {
+		log.Println("INVITE_SIGNING_KEY is not set; generating a temporary invitation signing key")
+		inviteKey = make([]byte, 32)
+		if _, err := rand.Read(inviteKey); err != nil {
+			log.Fatalf("Failed to generate invitation signing key: %v", err)
+		}
+	}
+	inviteAcceptURL := cfg.Invitations.AcceptURL
+	if inviteAcceptURL == "" {
+		inviteAcceptURL = oauthIssuer + "/invitations/accept"
+	}
+	orgConfig := usecase.DefaultOrgConfig(inviteKey, inviteAcceptURL)
+	orgConfig.InviteTTL = cfg.Invitations.TTL
+	orgUseCase := usecase.NewOrgUseCase(usecase.OrgRepositories{
+		Orgs:        repository.NewInMemoryOrgRepository(),
+		Members:     repository.NewInMemoryMembershipRepository(),
+		Teams:       repository.NewInMemoryTeamRepository(),
+		Invitations: repository.NewInMemoryInvitationRepository(),
+	}, userRepo, userUseCase, emailSender, orgConfig)
+
+	// Fine-grained authorization from a policy file, with relation tuples stored in SQL when a
+	// database is configured
+	authzPolicy := &authz.Policy{}
+	if cfg.Authz.PolicyFile != "" {
+		if authzPolicy, err = authz.LoadPolicy(cfg.Authz.PolicyFile); err != nil {
+			log.Fatalf("Failed to load authorization policy: %v", err)
+		}
+	}
+	var tupleRepo authz.TupleRepository = repository.NewInMemoryTupleRepository()
+	if cfg.Authz.DBDriver != "" {
+		sqlRepo, err := repository.OpenSQLTupleRepository(cfg.Authz.DBDriver, cfg.Authz.DBDSN)
+		if err != nil {
+			log.Fatalf("Failed to open relation tuple store: %v", err)
+		}
+		tupleRepo = sqlRepo
+	}
+	authzUseCase := usecase.NewAuthzUseCase(authzPolicy, tupleRepo)
 
 	// Create handlers
 	userHandler := handler.NewUserHandler(userUseCase)
 	helloHandler := handler.NewHelloHandler()
 	protectedHandler := handler.NewProtectedHandler()
+	passwordHandler := handler.NewPasswordHandler(userUseCase)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyUseCase)
+	oauthHandler := handler.NewOAuthHandler(oauthUseCase)
+	sessionCookie := handler.SessionCookieConfig{
+		Name:     cfg.Sessions.CookieName,
+		Secure:   cfg.Sessions.CookieSecure,
+		SameSite: cfg.Sessions.CookieSameSite,
+	}
+	oidcHandler := handler.NewOIDCHandler(oidcUseCase, sessionUseCase, sessionCookie)
+	externalLoginHandler := handler.NewExternalLoginHandler(externalLoginUseCase, cfg.Sessions.CookieSecure)
+	scimHandler := handler.NewSCIMHandler(scimUseCase, oauthIssuer+handler.SCIMBasePath)
+	orgHandler := handler.NewOrgHandler(orgUseCase)
+	authzHandler := handler.NewAuthzHandler(authzUseCase)
+	webhookHandler := handler.NewWebhookHandler(webhookUseCase)
+	introspectionHandler := handler.NewIntrospectionHandler(usecase.NewIntrospectionUseCase(jwtService, oauthUseCase, userRepo,
+		usecase.IntrospectionConfig{CacheTTL: cfg.Introspection.CacheTTL, RateLimit: cfg.Introspection.RateLimit}))
 
-	// Create middleware
-	authMiddleware := middleware.NewAuthMiddleware(jwtService)
+	// Create middleware; protected endpoints accept a JWT, an API key or, when enabled, a session cookie.
+	// Cookie-authenticated requests must also carry the session's CSRF token, and requests on
+	// routes of the authorization policy must pass its check.
+	authOpts := []middleware.AuthOption{
+		middleware.WithAPIKeys(apiKeyUseCase, usecase.APIKeyPrefix),
+		middleware.WithTokenRevocation(oauthUseCase),
+		middleware.WithAccountStatus(userUseCase),
+	}
+	if sessionUseCase != nil {
+		authOpts = append(authOpts, middleware.WithSessions(sessionUseCase, cfg.Sessions.CookieName))
+	}
+	authMiddleware := middleware.NewAuthMiddleware(jwtService, authOpts...)
+	csrfMiddleware := middleware.NewCSRFMiddleware()
+	authzMiddleware := middleware.NewAuthzMiddleware(authzUseCase, authzPolicy.Routes)
+	protect := func(h http.HandlerFunc) http.Handler {
+		return authMiddleware.Authenticate(csrfMiddleware.Protect(authzMiddleware.Enforce(h)))
+	}
 
-	// Register public endpoints
-	http.HandleFunc("/hello", helloHandler.Hello)
-	http.HandleFunc("/register", userHandler.Register)
-	http.HandleFunc("/login", userHandler.Login)
+	// Routes name their methods, so the mux answers other methods with 405 Method Not Allowed.
+	// Register public endpoints; importing expvar serves the password hashing metrics at /debug/vars
+	http.HandleFunc("GET /hello", helloHandler.Hello)
+	http.HandleFunc("POST /register", userHandler.Register)
+	http.HandleFunc("POST /login", userHandler.Login)
+	http.HandleFunc("POST /refresh", userHandler.Refresh)
+	http.HandleFunc("POST /password/forgot", passwordHandler.ForgotPassword)
+	http.HandleFunc("POST /password/reset", passwordHandler.ResetPassword)
 
 	// Register protected endpoints with auth middleware
-	http.Handle("/profile", authMiddleware.Authenticate(http.HandlerFunc(protectedHandler.Profile)))
+	http.Handle("GET /profile", protect(protectedHandler.Profile))
+	http.Handle("POST /password/change", protect(passwordHandler.ChangePassword))
+	http.Handle("GET /api-keys", protect(apiKeyHandler.Keys))
+	http.Handle("POST /api-keys", protect(apiKeyHandler.Keys))
+	http.Handle("GET /api-keys/{id}", protect(apiKeyHandler.Key))
+	http.Handle("DELETE /api-keys/{id}", protect(apiKeyHandler.Key))
+
+	// Register OAuth endpoints; the token, introspection and revocation endpoints authenticate clients themselves
+	http.Handle("GET /oauth/authorize", protect(oauthHandler.Authorize))
+	http.Handle("POST /oauth/authorize", protect(oauthHandler.Authorize))
+	http.HandleFunc("POST /oauth/token", oauthHandler.Token)
+	http.HandleFunc("POST /oauth/introspect", oauthHandler.Introspect)
+	http.HandleFunc("POST /oauth/revoke", oauthHandler.Revoke)
+	http.Handle("GET /oauth/clients", protect(oauthHandler.Clients))
+	http.Handle("POST /oauth/clients", protect(oauthHandler.Clients))
+	http.Handle("GET /oauth/consents", protect(oauthHandler.Consents))
+	http.Handle("DELETE /oauth/consents/{client_id}", protect(oauthHandler.RevokeConsent))
+
+	// Register OpenID Connect endpoints; logout is authorized by the id_token_hint
+	http.HandleFunc("GET "+handler.OIDCDiscoveryPath, oidcHandler.Discovery)
+	http.HandleFunc("GET "+handler.OIDCJWKSPath, oidcHandler.JWKS)
+	http.Handle("GET "+handler.OIDCUserInfoPath, protect(oidcHandler.UserInfo))
+	http.Handle("POST "+handler.OIDCUserInfoPath, protect(oidcHandler.UserInfo))
+	http.HandleFunc("GET "+handler.OIDCLogoutPath, oidcHandler.Logout)
+	http.HandleFunc("POST "+handler.OIDCLogoutPath, oidcHandler.Logout)
+
+	// Register external login endpoints; each provider gets its own login and callback path
+	http.HandleFunc("GET /auth/providers", externalLoginHandler.Providers)
+	for _, name := range externalLoginUseCase.Providers() {
+		http.HandleFunc("GET /auth/"+name+"/login", externalLoginHandler.Login)
+		http.HandleFunc("GET /auth/"+name+"/callback", externalLoginHandler.Callback)
+	}
+	for _, settings := range cfg.ExternalLogin.SAMLProviders {
+		http.HandleFunc("POST /auth/"+settings.Name+"/acs", externalLoginHandler.AssertionConsumer)
+		http.HandleFunc("GET /auth/"+settings.Name+"/metadata", externalLoginHandler.Metadata)
+	}
+	http.Handle("GET /auth/identities", protect(externalLoginHandler.Identities))
+	http.Handle("DELETE /auth/identities/{provider}", protect(externalLoginHandler.Unlink))
+
+	// Register SCIM 2.0 provisioning endpoints; clients authenticate with an API key holding the scim scope
+	http.HandleFunc("GET "+handler.SCIMBasePath+"/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+	http.Handle("GET "+handler.SCIMBasePath+"/Users", protect(scimHandler.Users))
+	http.Handle("POST "+handler.SCIMBasePath+"/Users", protect(scimHandler.Users))
+	http.Handle("GET "+handler.SCIMBasePath+"/Groups", protect(scimHandler.Groups))
+	http.Handle("POST "+handler.SCIMBasePath+"/Groups", protect(scimHandler.Groups))
+	http.Handle("GET "+handler.SCIMBasePath+"/Users/{id}", protect(scimHandler.User))
+	http.Handle("PUT "+handler.SCIMBasePath+"/Users/{id}", protect(scimHandler.User))
+	http.Handle("PATCH "+handler.SCIMBasePath+"/Users/{id}", protect(scimHandler.User))
+	http.Handle("DELETE "+handler.SCIMBasePath+"/Users/{id}", protect(scimHandler.User))
+	http.Handle("GET "+handler.SCIMBasePath+"/Groups/{id}", protect(scimHandler.Group))
+	http.Handle("PUT "+handler.SCIMBasePath+"/Groups/{id}", protect(scimHandler.Group))
+	http.Handle("PATCH "+handler.SCIMBasePath+"/Groups/{id}", protect(scimHandler.Group))
+	http.Handle("DELETE "+handler.SCIMBasePath+"/Groups/{id}", protect(scimHandler.Group))
+
+	// Register organization endpoints; invitees without an account register with their invitation
+	http.Handle("GET /orgs", protect(orgHandler.Orgs))
+	http.Handle("POST /orgs", protect(orgHandler.Orgs))
+	http.Handle("GET /orgs/{id}", protect(orgHandler.Org))
+	http.Handle("GET /orgs/{id}/members", protect(orgHandler.Org))
+	http.Handle("PUT /orgs/{id}/members/{user_id}", protect(orgHandler.Org))
+	http.Handle("DELETE /orgs/{id}/members/{user_id}", protect(orgHandler.Org))
+	http.Handle("GET /orgs/{id}/invitations", protect(orgHandler.Org))
+	http.Handle("POST /orgs/{id}/invitations", protect(orgHandler.Org))
+	http.Handle("DELETE /orgs/{id}/invitations/{invitation_id}", protect(orgHandler.Org))
+	http.Handle("GET /orgs/{id}/teams", protect(orgHandler.Org))
+	http.Handle("POST /orgs/{id}/teams", protect(orgHandler.Org))
+	http.Handle("DELETE /orgs/{id}/teams/{team_id}", protect(orgHandler.Org))
+	http.Handle("PUT /orgs/{id}/teams/{team_id}/members/{user_id}", protect(orgHandler.Org))
+	http.Handle("DELETE /orgs/{id}/teams/{team_id}/members/{user_id}", protect(orgHandler.Org))
+	http.Handle("POST /invitations/accept", protect(orgHandler.AcceptInvitation))
+	http.HandleFunc("POST /invitations/register", orgHandler.RegisterInvited)
+
+	// Register authorization endpoints
+	http.Handle("POST /authz/check", protect(authzHandler.Check))
+	http.Handle("POST /authz/explain", protect(authzHandler.Explain))
+	http.Handle("GET /authz/tuples", protect(authzHandler.Tuples))
+	http.Handle("POST /authz/tuples", protect(authzHandler.Tuples))
+	http.Handle("DELETE /authz/tuples", protect(authzHandler.Tuples))
+
+	// Register webhook endpoints; status=dead lists the dead-letter queue
+	http.Handle("GET /webhooks", protect(webhookHandler.Endpoints))
+	http.Handle("POST /webhooks", protect(webhookHandler.Endpoints))
+	http.Handle("GET /webhooks/{id}", protect(webhookHandler.Endpoint))
+	http.Handle("PUT /webhooks/{id}", protect(webhookHandler.Endpoint))
+	http.Handle("DELETE /webhooks/{id}", protect(webhookHandler.Endpoint))
+	http.Handle("POST /webhooks/{id}/secret", protect(webhookHandler.Endpoint))
+	http.Handle("GET /webhooks/{id}/deliveries", protect(webhookHandler.Endpoint))
+	http.Handle("GET /webhook-deliveries", protect(webhookHandler.Deliveries))
+	http.Handle("GET /webhook-deliveries/{id}", protect(webhookHandler.Delivery))
+	http.Handle("POST /webhook-deliveries/{id}/redeliver", protect(webhookHandler.Delivery))
+
+	// Register token introspection for services that cannot verify tokens themselves
+	http.Handle("POST /introspect", protect(introspectionHandler.Introspect))
+
+	// Register the OpenAPI description of these endpoints and a browsable version of it
+	http.Handle("GET "+openapi.SpecPath, handler.NewOpenAPIDocument(""))
+	http.Handle("GET "+openapi.DocsPath, openapi.DocsHandler())
+
+	// Register session endpoints when cookie sessions are enabled
+	if sessionUseCase != nil {
+		sessionHandler := handler.NewSessionHandler(sessionUseCase, sessionCookie)
+		http.HandleFunc("POST /session/login", sessionHandler.Login)
+		http.Handle("POST /session/logout", protect(sessionHandler.Logout))
+		http.Handle("GET /session", protect(sessionHandler.Current))
+		http.Handle("GET /sessions", protect(sessionHandler.List))
+		http.Handle("DELETE /sessions/{id}", protect(sessionHandler.Terminate))
+	}
+
+	// Serve the user API over gRPC alongside HTTP, with the same tenants and token checks
+	grpcServer := rpc.NewServer(userUseCase, jwtService, tenantRepo, cfg.Tenants.Header,
+		rpc.WithTokenRevocation(oauthUseCase), rpc.WithAccountStatus(userUseCase))
+	grpcListener, err := net.Listen("tcp", cfg.GRPCAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC: %v", err)
+	}
+	go func() {
+		fmt.Printf("Starting gRPC server on %s\n", cfg.GRPCAddr)
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("Failed to start gRPC server: %v", err)
+		}
+	}()
 
 	// Print a message indicating that the server is starting
 	fmt.Println("Starting server on :8080")
 
-	// Start the HTTP server on port 8080
-	err := http.ListenAndServe(":8080", nil)
+	// Start the HTTP server on port 8080, resolving the tenant before routing
+	err = http.ListenAndServe(":8080", tenantMiddleware.Resolve(http.DefaultServeMux))
 	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
@@ -1,49 +1,369 @@
 package main
 
 import (
+	"crypto/rand"
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/lamboktulussimamora/gra-project/internal/compatibility"
+	"github.com/lamboktulussimamora/gra-project/internal/config"
 	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
-	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/authz"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/event"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/session"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/directory"
 	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/mailer"
+	authmiddleware "github.com/lamboktulussimamora/gra-project/internal/interface/middleware"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/openapi"
 	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/saml"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/sender"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/upstream"
 	"github.com/lamboktulussimamora/gra-project/internal/usecase"
 	"github.com/lamboktulussimamora/gra/context"
 	"github.com/lamboktulussimamora/gra/middleware"
 	"github.com/lamboktulussimamora/gra/router"
-	"github.com/lamboktulussimamora/gra/validator"
+
+	// "sqlite3" driver for the SQL session, identity and tuple stores
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
-	// Create repository
-	userRepo := repository.NewInMemoryUserRepository()
+	// Load configuration from the environment
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
-	// Initialize password service
-	passwordParams := auth.ArgonParams{
-		Memory:      64 * 1024,
-		Iterations:  3,
-		Parallelism: 2,
-		SaltLength:  16,
-		KeyLength:   32,
+	// Create repository, persisted to a JSON file when a store path is configured. The
+	// repository is also the outbox of the events raised by users.
+	memoryUserRepo := repository.NewInMemoryUserRepository()
+	var userRepo user.Repository = memoryUserRepo
+	var userOutbox event.Outbox = memoryUserRepo
+	if cfg.UserStorePath != "" {
+		fileRepo, err := repository.NewFileUserRepository(cfg.UserStorePath)
+		if err != nil {
+			log.Fatalf("Failed to open user store: %v", err)
+		}
+		userRepo = fileRepo
+		userOutbox = fileRepo
 	}
-	passwordService := auth.NewPasswordService(passwordParams)
+
+	// Register the default tenant and the configured ones; each has its own users and tokens
+	tenantRepo := repository.NewInMemoryTenantRepository()
+	tenants := append([]config.TenantEntry{{ID: tenant.DefaultID, Name: "Default"}}, cfg.Tenants.Tenants...)
+	for _, entry := range tenants {
+		if err := tenantRepo.Save(&tenant.Tenant{ID: entry.ID, Name: entry.Name, CreatedAt: time.Now()}); err != nil {
+			log.Fatalf("Failed to register tenant %s: %v", entry.ID, err)
+		}
+	}
+	tenantMiddleware := authmiddleware.NewTenantMiddleware(tenantRepo, authmiddleware.TenantResolution{
+		Header:     cfg.Tenants.Header,
+		PathPrefix: cfg.Tenants.PathPrefix,
+		BaseDomain: cfg.Tenants.BaseDomain,
+	})
+
+	// Initialize password service, bounded so bursts of logins cannot exhaust memory
+	passwordParams := cfg.ArgonParams()
+	passwordService := auth.NewLimitedPasswordService(
+		auth.NewPasswordService(passwordParams, auth.WithPepper(cfg.Pepper)),
+		passwordParams,
+		cfg.HashLimiter,
+	)
+	expvar.Publish("password_hashing", expvar.Func(func() interface{} { return passwordService.Stats() }))
 
 	// Initialize JWT service
 	jwtConfig := auth.JWTConfig{
-		SecretKey:     "your-secret-key-here", // In production, use environment variables for secrets
-		TokenDuration: time.Hour * 24,         // 24 hours token validity
+		SecretKey:     cfg.JWTSecret,
+		TokenDuration: time.Hour * 24, // 24 hours token validity
 	}
 	jwtService := auth.NewJWTService(jwtConfig)
 
+	// Configure the password policy, optionally with an offline breached-password corpus
+	passwordPolicy := auth.DefaultPasswordPolicy()
+	if cfg.BreachedPasswordsPath != "" {
+		corpus, err := auth.LoadHashPrefixCorpus(cfg.BreachedPasswordsPath)
+		if err != nil {
+			log.Fatalf("Failed to load breached password corpus: %v", err)
+		}
+		passwordPolicy.Breached = corpus
+	}
+
 	// Create use cases
-	userUseCase := usecase.NewUserUseCase(userRepo, passwordService, jwtService)
+	emailSender := mailer.NewLogMailer()
+
+	// Webhooks notify downstream systems of user lifecycle events; deliveries are sent in the
+	// background and retried with backoff
+	webhookConfig := usecase.DefaultWebhookConfig()
+	webhookConfig.MaxAttempts = cfg.Webhooks.MaxAttempts
+	webhookConfig.AllowHTTP = cfg.Webhooks.AllowHTTP
+	webhookConfig.AllowPrivateNetworks = cfg.Webhooks.AllowPrivateNetworks
+	var senderOpts []sender.HTTPSenderOption
+	if cfg.Webhooks.AllowPrivateNetworks {
+		senderOpts = append(senderOpts, sender.WithPrivateNetworks())
+	}
+	webhookUseCase := usecase.NewWebhookUseCase(repository.NewInMemoryWebhookEndpointRepository(),
+		repository.NewInMemoryWebhookDeliveryRepository(), sender.NewHTTPSender(cfg.Webhooks.Timeout, senderOpts...), webhookConfig)
+	go func() {
+		for range time.Tick(5 * time.Second) {
+			if _, err := webhookUseCase.DeliverDue(); err != nil {
+				log.Printf("Error delivering webhooks: %v", err)
+			}
+		}
+	}()
+
+	// User events are relayed from the outbox to in-process subscribers, at least once
+	eventBus := event.NewBus()
+	webhookUseCase.SubscribeUserEvents(eventBus, repository.NewInMemoryProcessedEventRepository())
+	eventRelay := usecase.NewEventRelay(userOutbox, eventBus, usecase.DefaultEventRelayConfig())
+	go func() {
+		for range time.Tick(time.Second) {
+			if _, err := eventRelay.RelayPending(); err != nil {
+				log.Printf("Error relaying events: %v", err)
+			}
+		}
+	}()
+
+	userOpts := []usecase.UserUseCaseOption{
+		usecase.WithPasswordPolicy(passwordPolicy),
+		usecase.WithEventPublisher(webhookUseCase),
+		usecase.WithPasswordReset(repository.NewInMemoryPasswordResetRepository(), emailSender, time.Hour),
+		usecase.WithRefreshTokens(repository.NewInMemoryRefreshTokenRepository(), 30*24*time.Hour),
+	}
+
+	// Cookie sessions for browser frontends, stored in SQL when a database is configured. Users
+	// disabled by an operator or deprovisioned over SCIM are signed out of them.
+	var sessionRepo session.Repository
+	if cfg.Sessions.Enabled {
+		sessionRepo = repository.NewInMemorySessionRepository()
+		if cfg.Sessions.DBDriver != "" {
+			sqlRepo, err := repository.OpenSQLSessionRepository(cfg.Sessions.DBDriver, cfg.Sessions.DBDSN)
+			if err != nil {
+				log.Fatalf("Failed to open session store: %v", err)
+			}
+			sessionRepo = sqlRepo
+		}
+		userOpts = append(userOpts, usecase.WithSessions(sessionRepo))
+	}
+	if cfg.GenericRegistration {
+		userOpts = append(userOpts, usecase.WithGenericRegistration(emailSender))
+	}
+	if cfg.LDAP.URL != "" {
+		ldapAuthenticator, err := directory.NewLDAPAuthenticator(directory.LDAPConfig{
+			URL:          cfg.LDAP.URL,
+			StartTLS:     cfg.LDAP.StartTLS,
+			BindDN:       cfg.LDAP.BindDN,
+			BindPassword: cfg.LDAP.BindPassword,
+			BaseDN:       cfg.LDAP.BaseDN,
+			UserFilter:   cfg.LDAP.UserFilter,
+			GroupBaseDN:  cfg.LDAP.GroupBaseDN,
+			GroupFilter:  cfg.LDAP.GroupFilter,
+			GroupRoles:   cfg.LDAP.GroupRoles,
+			TenantID:     cfg.LDAP.TenantID,
+		}, userRepo)
+		if err != nil {
+			log.Fatalf("Failed to configure LDAP login: %v", err)
+		}
+		userOpts = append(userOpts, usecase.WithAuthenticators(ldapAuthenticator))
+	}
+	userUseCase := usecase.NewUserUseCase(userRepo, passwordService, jwtService, userOpts...)
+	apiKeyUseCase := usecase.NewAPIKeyUseCase(repository.NewInMemoryAPIKeyRepository(), userRepo)
+
+	// OAuth 2.1 authorization server for internal apps that delegate login to this service
+	oauthIssuer := cfg.OAuthIssuer
+	if oauthIssuer == "" {
+		oauthIssuer = "http://localhost:8082"
+	}
+	// ID tokens are signed with RSA keys published at the JWKS endpoint. A generated key changes on every
+	// restart, which invalidates the ID tokens relying parties hold, so configure keys in production.
+	var idTokenKeys *auth.KeySet
+	if len(cfg.OIDCSigningKeyFiles) > 0 {
+		idTokenKeys, err = auth.LoadKeySet(cfg.OIDCSigningKeyFiles...)
+	} else {
+		log.Println("OIDC_SIGNING_KEY_FILES is not set; generating a temporary ID token signing key")
+		idTokenKeys, err = auth.GenerateKeySet()
+	}
+	if err != nil {
+		log.Fatalf("Failed to load ID token signing keys: %v", err)
+	}
+	oauthClientRepo := repository.NewInMemoryOAuthClientRepository()
+	oauthUseCase := usecase.NewOAuthUseCase(usecase.OAuthRepositories{
+		Clients:       oauthClientRepo,
+		Codes:         repository.NewInMemoryAuthorizationCodeRepository(),
+		RefreshTokens: repository.NewInMemoryRefreshTokenRepository(),
+		Consents:      repository.NewInMemoryConsentRepository(),
+		Revocations:   repository.NewInMemoryRevocationRepository(),
+	}, userRepo, jwtService, usecase.DefaultOAuthConfig(oauthIssuer), usecase.WithIDTokens(idTokenKeys))
+	oidcUseCase := usecase.NewOIDCUseCase(oauthClientRepo, userRepo, idTokenKeys, oauthIssuer)
+
+	// Sign-in through upstream identity providers, linked to local users by verified email
+	var externalProviders []identity.Provider
+	for _, settings := range cfg.ExternalLogin.Providers {
+		redirectURL := settings.RedirectURL
+		if redirectURL == "" {
+			redirectURL = oauthIssuer + "/auth/" + settings.Name + "/callback"
+		}
+		provider, err := upstream.NewProvider(upstream.Config{
+			Name:         settings.Name,
+			Issuer:       settings.Issuer,
+			ClientID:     settings.ClientID,
+			ClientSecret: settings.ClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       settings.Scopes,
+			AuthURL:      settings.AuthURL,
+			TokenURL:     settings.TokenURL,
+			UserInfoURL:  settings.UserInfoURL,
+			TrustEmail:   settings.TrustEmail,
+		}, nil)
+		if err != nil {
+			log.Fatalf("Invalid identity provider: %v", err)
+		}
+		externalProviders = append(externalProviders, provider)
+	}
+	for _, settings := range cfg.ExternalLogin.SAMLProviders {
+		samlConfig := saml.Config{
+			Name:        settings.Name,
+			EntityID:    settings.EntityID,
+			ACSURL:      settings.ACSURL,
+			IdPEntityID: settings.IdPEntityID,
+			IdPSSOURL:   settings.IdPSSOURL,
+			Attributes: saml.AttributeMap{
+				Email:      settings.EmailAttribute,
+				GivenName:  settings.FirstNameAttribute,
+				FamilyName: settings.LastNameAttribute,
+			},
+			TrustEmail: settings.TrustEmail,
+		}
+		if samlConfig.EntityID == "" {
+			samlConfig.EntityID = oauthIssuer + "/auth/" + settings.Name + "/metadata"
+		}
+		if samlConfig.ACSURL == "" {
+			samlConfig.ACSURL = oauthIssuer + "/auth/" + settings.Name + "/acs"
+		}
+		if settings.IdPMetadataFile != "" {
+			metadata, err := saml.LoadIdPMetadata(settings.IdPMetadataFile)
+			if err != nil {
+				log.Fatalf("Failed to load SAML metadata for %s: %v", settings.Name, err)
+			}
+			samlConfig.IdPEntityID, samlConfig.IdPSSOURL = metadata.EntityID, metadata.SSOURL
+			samlConfig.IdPCertificates = metadata.Certificates
+		} else {
+			certs, err := saml.LoadCertificates(settings.IdPCertFile)
+			if err != nil {
+				log.Fatalf("Failed to load SAML certificate for %s: %v", settings.Name, err)
+			}
+			samlConfig.IdPCertificates = certs
+		}
+		provider, err := saml.NewServiceProvider(samlConfig, nil)
+		if err != nil {
+			log.Fatalf("Invalid SAML identity provider: %v", err)
+		}
+		externalProviders = append(externalProviders, provider)
+	}
+	var linkRepo identity.Repository = repository.NewInMemoryLinkedIdentityRepository()
+	if cfg.ExternalLogin.DBDriver != "" {
+		sqlRepo, err := repository.OpenSQLLinkedIdentityRepository(cfg.ExternalLogin.DBDriver, cfg.ExternalLogin.DBDSN)
+		if err != nil {
+			log.Fatalf("Failed to open linked identity store: %v", err)
+		}
+		linkRepo = sqlRepo
+	}
+	externalLoginConfig := usecase.DefaultExternalLoginConfig()
+	externalLoginConfig.CreateUsers = !cfg.ExternalLogin.LinkOnly
+	externalLoginUseCase := usecase.NewExternalLoginUseCase(externalProviders, linkRepo,
+		repository.NewInMemoryLoginStateRepository(), userRepo, jwtService, externalLoginConfig)
+	go func() {
+		for range time.Tick(5 * time.Minute) {
+			if err := externalLoginUseCase.PurgeExpired(); err != nil {
+				log.Printf("Error purging expired login states: %v", err)
+			}
+		}
+	}()
+
+	// Cookie sessions for browser frontends
+	var sessionUseCase *usecase.SessionUseCase
+	if cfg.Sessions.Enabled {
+		sessionUseCase = usecase.NewSessionUseCase(sessionRepo, userRepo, userUseCase, usecase.SessionConfig{
+			IdleTimeout:     cfg.Sessions.IdleTimeout,
+			AbsoluteTimeout: cfg.Sessions.AbsoluteTimeout,
+		})
+		go func() {
+			for range time.Tick(5 * time.Minute) {
+				if err := sessionUseCase.PurgeExpired(); err != nil {
+					log.Printf("Error purging expired sessions: %v", err)
+				}
+			}
+		}()
+	}
+
+	// SCIM provisioning; deprovisioned users are disabled and signed out of their sessions
+	scimUseCase := usecase.NewSCIMUseCase(userRepo, repository.NewInMemoryGroupRepository(), userUseCase)
+
+	// Organizations with teams; members are invited by email with signed, expiring links
+	inviteKey := cfg.Invitations.SigningKey
+	if len(inviteKey) == 0 {
+		log.Println("INVITE_SIGNING_KEY is not set; generating a temporary invitation signing key")
+		inviteKey = make([]byte, 32)
+		if _, err := rand.Read(inviteKey); err != nil {
+			log.Fatalf("Failed to generate invitation signing key: %v", err)
+		}
+	}
+	inviteAcceptURL := cfg.Invitations.AcceptURL
+	if inviteAcceptURL == "" {
+		inviteAcceptURL = oauthIssuer + "/invitations/accept"
+	}
+	orgConfig := usecase.DefaultOrgConfig(inviteKey, inviteAcceptURL)
+	orgConfig.InviteTTL = cfg.Invitations.TTL
+	orgUseCase := usecase.NewOrgUseCase(usecase.OrgRepositories{
+		Orgs:        repository.NewInMemoryOrgRepository(),
+		Members:     repository.NewInMemoryMembershipRepository(),
+		Teams:       repository.NewInMemoryTeamRepository(),
+		Invitations: repository.NewInMemoryInvitationRepository(),
+	}, userRepo, userUseCase, emailSender, orgConfig)
+
+	// Fine-grained authorization from a policy file, with relation tuples stored in SQL when a
+	// database is configured
+	authzPolicy := &authz.Policy{}
+	if cfg.Authz.PolicyFile != "" {
+		if authzPolicy, err = authz.LoadPolicy(cfg.Authz.PolicyFile); err != nil {
+			log.Fatalf("Failed to load authorization policy: %v", err)
+		}
+	}
+	var tupleRepo authz.TupleRepository = repository.NewInMemoryTupleRepository()
+	if cfg.Authz.DBDriver != "" {
+		sqlRepo, err := repository.OpenSQLTupleRepository(cfg.Authz.DBDriver, cfg.Authz.DBDSN)
+		if err != nil {
+			log.Fatalf("Failed to open relation tuple store: %v", err)
+		}
+		tupleRepo = sqlRepo
+	}
+	authzUseCase := usecase.NewAuthzUseCase(authzPolicy, tupleRepo)
 
 	// Create handlers
 	exampleHandler := handler.NewExampleHandler()
+	userHandler := handler.NewGraUserHandler(userUseCase)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyUseCase)
+	oauthHandler := handler.NewOAuthHandler(oauthUseCase)
+	sessionCookie := handler.SessionCookieConfig{
+		Name:     cfg.Sessions.CookieName,
+		Secure:   cfg.Sessions.CookieSecure,
+		SameSite: cfg.Sessions.CookieSameSite,
+	}
+	oidcHandler := handler.NewOIDCHandler(oidcUseCase, sessionUseCase, sessionCookie)
+	externalLoginHandler := handler.NewExternalLoginHandler(externalLoginUseCase, cfg.Sessions.CookieSecure)
+	scimHandler := handler.NewSCIMHandler(scimUseCase, oauthIssuer+handler.SCIMBasePath)
+	orgHandler := handler.NewOrgHandler(orgUseCase)
+	authzHandler := handler.NewAuthzHandler(authzUseCase)
+	webhookHandler := handler.NewWebhookHandler(webhookUseCase)
+	introspectionHandler := handler.NewIntrospectionHandler(usecase.NewIntrospectionUseCase(jwtService, oauthUseCase, userRepo,
+		usecase.IntrospectionConfig{CacheTTL: cfg.Introspection.CacheTTL, RateLimit: cfg.Introspection.RateLimit}))
 
 	// Create router
 	r := router.New()
@@ -57,58 +377,137 @@ func main() {
 
 	// Register public routes
 	r.GET("/hello", exampleHandler.Hello)
-	r.POST("/register", exampleHandler.Register)
-	r.POST("/login", func(c *context.Context) {
-		var req handler.LoginRequest
-		if err := c.BindJSON(&req); err != nil {
-			c.Error(http.StatusBadRequest, "Invalid request format")
-			return
-		}
-
-		// Validate request
-		v := validator.New()
-		errors := v.Validate(&req)
-		if len(errors) > 0 {
-			c.JSON(http.StatusBadRequest, map[string]interface{}{
-				"status": "error",
-				"error":  "Validation failed",
-				"errors": errors,
-			})
-			return
-		}
-
-		// Call the use case
-		authResp, err := userUseCase.Login(req.Email, req.Password)
-		if err != nil {
-			c.Error(http.StatusUnauthorized, err.Error())
-			return
-		}
-
-		// Return success response
-		c.Success(http.StatusOK, "Login successful", map[string]interface{}{
-			"token": authResp.Token,
-			"user": map[string]interface{}{
-				"first_name": authResp.User.FirstName,
-				"last_name":  authResp.User.LastName,
-				"email":      authResp.User.Email,
-			},
-		})
+	r.GET("/debug/vars", func(c *context.Context) {
+		expvar.Handler().ServeHTTP(c.Writer, c.Request)
 	})
+	r.POST("/register", userHandler.Register)
+	r.POST("/login", userHandler.Login)
+	r.POST("/refresh", userHandler.Refresh)
+	r.POST("/password/forgot", userHandler.ForgotPassword)
+	r.POST("/password/reset", userHandler.ResetPassword)
+
+	// Protected routes are wrapped with the auth middleware, which accepts a JWT, an API key
+	// and, when enabled, a session cookie. Cookie-authenticated requests must also carry the CSRF token,
+	// and requests on routes of the authorization policy must pass its check.
+	authOpts := []authmiddleware.AuthOption{
+		authmiddleware.WithAPIKeys(apiKeyUseCase, usecase.APIKeyPrefix),
+		authmiddleware.WithTokenRevocation(oauthUseCase),
+		authmiddleware.WithAccountStatus(userUseCase),
+	}
+	if sessionUseCase != nil {
+		authOpts = append(authOpts, authmiddleware.WithSessions(sessionUseCase, cfg.Sessions.CookieName))
+	}
+	authMiddleware := compatibility.AuthMiddlewareFrom(authmiddleware.NewAuthMiddleware(jwtService, authOpts...))
+	csrfMiddleware := compatibility.HTTPMiddleware(authmiddleware.NewCSRFMiddleware().Protect)
+	authzMiddleware := compatibility.HTTPMiddleware(authmiddleware.NewAuthzMiddleware(authzUseCase, authzPolicy.Routes).Enforce)
+	authenticate := func(h router.HandlerFunc) router.HandlerFunc {
+		return authMiddleware(csrfMiddleware(authzMiddleware(h)))
+	}
+	r.GET("/api/profile", authenticate(exampleHandler.Profile))
+	r.POST("/api/password/change", authenticate(userHandler.ChangePassword))
+	r.GET("/api/api-keys", authenticate(compatibility.WrapHTTP(apiKeyHandler.Keys)))
+	r.POST("/api/api-keys", authenticate(compatibility.WrapHTTP(apiKeyHandler.Keys)))
+	r.GET("/api/api-keys/:id", authenticate(compatibility.WrapHTTP(apiKeyHandler.Key)))
+	r.DELETE("/api/api-keys/:id", authenticate(compatibility.WrapHTTP(apiKeyHandler.Key)))
 
-	// Create a group of protected routes
-	protectedRouter := router.New()
-	// Apply auth middleware to all routes in this router
-	protectedRouter.Use(compatibility.AuthMiddleware(jwtService, common.UserClaimsKey))
+	// Register OAuth routes; the token, introspection and revocation endpoints authenticate clients themselves
+	r.GET("/oauth/authorize", authenticate(compatibility.WrapHTTP(oauthHandler.Authorize)))
+	r.POST("/oauth/authorize", authenticate(compatibility.WrapHTTP(oauthHandler.Authorize)))
+	r.POST("/oauth/token", compatibility.WrapHTTP(oauthHandler.Token))
+	r.POST("/oauth/introspect", compatibility.WrapHTTP(oauthHandler.Introspect))
+	r.POST("/oauth/revoke", compatibility.WrapHTTP(oauthHandler.Revoke))
+	r.GET("/api/oauth/clients", authenticate(compatibility.WrapHTTP(oauthHandler.Clients)))
+	r.POST("/api/oauth/clients", authenticate(compatibility.WrapHTTP(oauthHandler.Clients)))
+	r.GET("/api/oauth/consents", authenticate(compatibility.WrapHTTP(oauthHandler.Consents)))
+	r.DELETE("/api/oauth/consents/:client_id", authenticate(compatibility.WrapHTTP(oauthHandler.RevokeConsent)))
 
-	// Add protected routes
-	protectedRouter.GET("/profile", exampleHandler.Profile)
+	// Register OpenID Connect routes; logout is authorized by the id_token_hint
+	r.GET(handler.OIDCDiscoveryPath, compatibility.WrapHTTP(oidcHandler.Discovery))
+	r.GET(handler.OIDCJWKSPath, compatibility.WrapHTTP(oidcHandler.JWKS))
+	r.GET(handler.OIDCUserInfoPath, authenticate(compatibility.WrapHTTP(oidcHandler.UserInfo)))
+	r.POST(handler.OIDCUserInfoPath, authenticate(compatibility.WrapHTTP(oidcHandler.UserInfo)))
+	r.GET(handler.OIDCLogoutPath, compatibility.WrapHTTP(oidcHandler.Logout))
+	r.POST(handler.OIDCLogoutPath, compatibility.WrapHTTP(oidcHandler.Logout))
 
-	// Mount the protected router under the /api path
-	r.Handle(http.MethodGet, "/api/profile", func(c *context.Context) {
-		exampleHandler.Profile(c)
-	})
+	// Register external login routes
+	r.GET("/auth/providers", compatibility.WrapHTTP(externalLoginHandler.Providers))
+	r.GET("/auth/:provider/login", compatibility.WrapHTTP(externalLoginHandler.Login))
+	r.GET("/auth/:provider/callback", compatibility.WrapHTTP(externalLoginHandler.Callback))
+	r.POST("/auth/:provider/acs", compatibility.WrapHTTP(externalLoginHandler.AssertionConsumer))
+	r.GET("/auth/:provider/metadata", compatibility.WrapHTTP(externalLoginHandler.Metadata))
+	r.GET("/api/auth/identities", authenticate(compatibility.WrapHTTP(externalLoginHandler.Identities)))
+	r.DELETE("/api/auth/identities/:provider", authenticate(compatibility.WrapHTTP(externalLoginHandler.Unlink)))
+
+	// Register SCIM 2.0 provisioning routes; clients authenticate with an API key holding the scim scope
+	r.GET(handler.SCIMBasePath+"/ServiceProviderConfig", compatibility.WrapHTTP(scimHandler.ServiceProviderConfig))
+	r.GET(handler.SCIMBasePath+"/Users", authenticate(compatibility.WrapHTTP(scimHandler.Users)))
+	r.POST(handler.SCIMBasePath+"/Users", authenticate(compatibility.WrapHTTP(scimHandler.Users)))
+	r.GET(handler.SCIMBasePath+"/Groups", authenticate(compatibility.WrapHTTP(scimHandler.Groups)))
+	r.POST(handler.SCIMBasePath+"/Groups", authenticate(compatibility.WrapHTTP(scimHandler.Groups)))
+	r.GET(handler.SCIMBasePath+"/Users/:id", authenticate(compatibility.WrapHTTP(scimHandler.User)))
+	r.PUT(handler.SCIMBasePath+"/Users/:id", authenticate(compatibility.WrapHTTP(scimHandler.User)))
+	r.Handle(http.MethodPatch, handler.SCIMBasePath+"/Users/:id", authenticate(compatibility.WrapHTTP(scimHandler.User)))
+	r.DELETE(handler.SCIMBasePath+"/Users/:id", authenticate(compatibility.WrapHTTP(scimHandler.User)))
+	r.GET(handler.SCIMBasePath+"/Groups/:id", authenticate(compatibility.WrapHTTP(scimHandler.Group)))
+	r.PUT(handler.SCIMBasePath+"/Groups/:id", authenticate(compatibility.WrapHTTP(scimHandler.Group)))
+	r.Handle(http.MethodPatch, handler.SCIMBasePath+"/Groups/:id", authenticate(compatibility.WrapHTTP(scimHandler.Group)))
+	r.DELETE(handler.SCIMBasePath+"/Groups/:id", authenticate(compatibility.WrapHTTP(scimHandler.Group)))
+
+	// Register organization routes; invitees without an account register with their invitation
+	r.GET("/api/orgs", authenticate(compatibility.WrapHTTP(orgHandler.Orgs)))
+	r.POST("/api/orgs", authenticate(compatibility.WrapHTTP(orgHandler.Orgs)))
+	r.GET("/api/orgs/:id", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.GET("/api/orgs/:id/members", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.PUT("/api/orgs/:id/members/:user_id", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.DELETE("/api/orgs/:id/members/:user_id", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.GET("/api/orgs/:id/invitations", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.POST("/api/orgs/:id/invitations", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.DELETE("/api/orgs/:id/invitations/:invitation_id", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.GET("/api/orgs/:id/teams", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.POST("/api/orgs/:id/teams", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.DELETE("/api/orgs/:id/teams/:team_id", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.PUT("/api/orgs/:id/teams/:team_id/members/:user_id", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.DELETE("/api/orgs/:id/teams/:team_id/members/:user_id", authenticate(compatibility.WrapHTTP(orgHandler.Org)))
+	r.POST("/api/invitations/accept", authenticate(compatibility.WrapHTTP(orgHandler.AcceptInvitation)))
+	r.POST("/invitations/register", compatibility.WrapHTTP(orgHandler.RegisterInvited))
+
+	// Authorization routes
+	r.POST("/api/authz/check", authenticate(compatibility.WrapHTTP(authzHandler.Check)))
+	r.POST("/api/authz/explain", authenticate(compatibility.WrapHTTP(authzHandler.Explain)))
+	r.GET("/api/authz/tuples", authenticate(compatibility.WrapHTTP(authzHandler.Tuples)))
+	r.POST("/api/authz/tuples", authenticate(compatibility.WrapHTTP(authzHandler.Tuples)))
+	r.DELETE("/api/authz/tuples", authenticate(compatibility.WrapHTTP(authzHandler.Tuples)))
+
+	// Webhook routes; status=dead lists the dead-letter queue
+	r.GET("/api/webhooks", authenticate(compatibility.WrapHTTP(webhookHandler.Endpoints)))
+	r.POST("/api/webhooks", authenticate(compatibility.WrapHTTP(webhookHandler.Endpoints)))
+	r.GET("/api/webhooks/:id", authenticate(compatibility.WrapHTTP(webhookHandler.Endpoint)))
+	r.PUT("/api/webhooks/:id", authenticate(compatibility.WrapHTTP(webhookHandler.Endpoint)))
+	r.DELETE("/api/webhooks/:id", authenticate(compatibility.WrapHTTP(webhookHandler.Endpoint)))
+	r.POST("/api/webhooks/:id/secret", authenticate(compatibility.WrapHTTP(webhookHandler.Endpoint)))
+	r.GET("/api/webhooks/:id/deliveries", authenticate(compatibility.WrapHTTP(webhookHandler.Endpoint)))
+	r.GET("/api/webhook-deliveries", authenticate(compatibility.WrapHTTP(webhookHandler.Deliveries)))
+	r.GET("/api/webhook-deliveries/:id", authenticate(compatibility.WrapHTTP(webhookHandler.Delivery)))
+	r.POST("/api/webhook-deliveries/:id/redeliver", authenticate(compatibility.WrapHTTP(webhookHandler.Delivery)))
+
+	// Register token introspection for services that cannot verify tokens themselves
+	r.POST("/api/introspect", authenticate(compatibility.WrapHTTP(introspectionHandler.Introspect)))
+
+	// Register the OpenAPI description of these routes and a browsable version of it
+	r.GET(openapi.SpecPath, compatibility.WrapHTTP(handler.NewOpenAPIDocument("/api").ServeHTTP))
+	r.GET(openapi.DocsPath, compatibility.WrapHTTP(openapi.DocsHandler().ServeHTTP))
+
+	// Register session routes when cookie sessions are enabled
+	if sessionUseCase != nil {
+		sessionHandler := handler.NewSessionHandler(sessionUseCase, sessionCookie)
+		r.POST("/session/login", compatibility.WrapHTTP(sessionHandler.Login))
+		r.POST("/api/session/logout", authenticate(compatibility.WrapHTTP(sessionHandler.Logout)))
+		r.GET("/api/session", authenticate(compatibility.WrapHTTP(sessionHandler.Current)))
+		r.GET("/api/sessions", authenticate(compatibility.WrapHTTP(sessionHandler.List)))
+		r.DELETE("/api/sessions/:id", authenticate(compatibility.WrapHTTP(sessionHandler.Terminate)))
+	}
 
 	// Start server
 	fmt.Println("Server started on :8082")
-	log.Fatal(http.ListenAndServe(":8082", r))
+	log.Fatal(http.ListenAndServe(":8082", tenantMiddleware.Resolve(r)))
 }
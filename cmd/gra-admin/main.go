@@ -0,0 +1,418 @@
+// Command gra-admin performs operator tasks on the configured user store, such as creating an
+// admin or disabling a compromised account, and prints its results as JSON.
+//
+// Usage:
+//
+//	gra-admin <command> [flags]
+//
+// Passwords are read from the first line of standard input, so they stay out of the process
+// list and shell history. The user store is locked while it is written, and running servers
+// reload it when it changes, so they see account changes made here without a restart.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/config"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+
+	// "sqlite3" driver for the SQL session, identity and tuple stores
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// command is a subcommand; run parses its arguments and returns the value to print
+type command struct {
+	summary string
+	run     func(cfg *config.Config, args []string) (interface{}, error)
+}
+
+var commands = map[string]command{
+	"create-admin":       {"create an admin user; the password is read from stdin", createAdmin},
+	"reset-password":     {"set a user's password; the password is read from stdin", resetPassword},
+	"disable":            {"disable an account and sign it out everywhere", setDisabled(true)},
+	"unlock":             {"re-enable a disabled account", setDisabled(false)},
+	"revoke-sessions":    {"sign a user out of every session", revokeSessions},
+	"mint-token":         {"issue a short-lived access token for a user, for debugging", mintToken},
+	"rotate-signing-key": {"generate a new ID token signing key ahead of the configured ones", rotateSigningKey},
+	"argon-params":       {"print the Argon2 parameters passwords are hashed with, or calibrate new ones", argonParams},
+}
+
+// commandOrder lists the commands in the usage message
+var commandOrder = []string{
+	"create-admin", "reset-password", "disable", "unlock", "revoke-sessions",
+	"mint-token", "rotate-signing-key", "argon-params",
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fail(fmt.Errorf("failed to load configuration: %w", err))
+	}
+	result, err := cmd.run(cfg, os.Args[2:])
+	if err != nil {
+		fail(err)
+	}
+	printJSON(os.Stdout, result)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: gra-admin <command> [flags]\n\nCommands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun gra-admin <command> -h for the flags of a command.")
+}
+
+// errorOutput is printed when a command fails
+type errorOutput struct {
+	Error  string                  `json:"error"`
+	Fields []validation.FieldError `json:"fields,omitempty"`
+}
+
+// fail prints an error as JSON and exits with status 1
+func fail(err error) {
+	out := errorOutput{Error: err.Error()}
+	var validationErrs validation.Errors
+	if errors.As(err, &validationErrs) {
+		out.Error = "validation failed"
+		out.Fields = validationErrs
+	}
+	printJSON(os.Stdout, out)
+	os.Exit(1)
+}
+
+func printJSON(w io.Writer, v interface{}) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode output: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// userFlags are the flags of commands acting on a user
+type userFlags struct {
+	store  *string
+	tenant *string
+	email  *string
+}
+
+func newUserFlags(cfg *config.Config, fs *flag.FlagSet) userFlags {
+	return userFlags{
+		store:  fs.String("store", cfg.UserStorePath, "path to the JSON user store"),
+		tenant: fs.String("tenant", tenant.DefaultID, "tenant of the user"),
+		email:  fs.String("email", "", "email of the user"),
+	}
+}
+
+// userUseCase opens the user store with the password hashing, policy and token signing the
+// servers use, so accounts changed here behave as if changed through the API
+func (f userFlags) userUseCase(cfg *config.Config, opts ...usecase.UserUseCaseOption) (*usecase.UserUseCase, error) {
+	if *f.store == "" {
+		return nil, errors.New("a user store is required: pass -store or set USER_STORE_PATH")
+	}
+	if *f.email == "" {
+		return nil, errors.New("an email is required: pass -email")
+	}
+	if !knownTenant(cfg, *f.tenant) {
+		return nil, fmt.Errorf("unknown tenant %q", *f.tenant)
+	}
+
+	userRepo, err := repository.NewFileUserRepository(*f.store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user store: %w", err)
+	}
+
+	passwordPolicy := auth.DefaultPasswordPolicy()
+	if cfg.BreachedPasswordsPath != "" {
+		corpus, err := auth.LoadHashPrefixCorpus(cfg.BreachedPasswordsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load breached password corpus: %w", err)
+		}
+		passwordPolicy.Breached = corpus
+	}
+	opts = append([]usecase.UserUseCaseOption{usecase.WithPasswordPolicy(passwordPolicy)}, opts...)
+
+	return usecase.NewUserUseCase(
+		userRepo,
+		auth.NewPasswordService(cfg.ArgonParams(), auth.WithPepper(cfg.Pepper)),
+		auth.NewJWTService(auth.JWTConfig{SecretKey: cfg.JWTSecret}),
+		opts...,
+	), nil
+}
+
+// knownTenant reports whether the servers serve a tenant
+func knownTenant(cfg *config.Config, tenantID string) bool {
+	if tenantID == tenant.DefaultID {
+		return true
+	}
+	for _, entry := range cfg.Tenants.Tenants {
+		if entry.ID == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// readPassword reads a password from the first line of standard input
+func readPassword() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on standard input")
+	}
+	return password, nil
+}
+
+// userOutput describes an account
+type userOutput struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Roles     []string  `json:"roles"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newUserOutput(tenantID string, u *usecase.UserResponse) userOutput {
+	roles := u.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return userOutput{
+		ID:        u.ID,
+		TenantID:  tenantID,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Roles:     roles,
+		Disabled:  u.Disabled,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+func createAdmin(cfg *config.Config, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	f := newUserFlags(cfg, fs)
+	firstName := fs.String("first-name", "", "first name of the admin")
+	lastName := fs.String("last-name", "", "last name of the admin")
+	fs.Parse(args)
+
+	users, err := f.userUseCase(cfg)
+	if err != nil {
+		return nil, err
+	}
+	password, err := readPassword()
+	if err != nil {
+		return nil, err
+	}
+	created, err := users.CreateAdmin(*f.tenant, *firstName, *lastName, *f.email, password)
+	if err != nil {
+		return nil, err
+	}
+	return newUserOutput(*f.tenant, created), nil
+}
+
+func resetPassword(cfg *config.Config, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	f := newUserFlags(cfg, fs)
+	fs.Parse(args)
+
+	users, err := f.userUseCase(cfg)
+	if err != nil {
+		return nil, err
+	}
+	password, err := readPassword()
+	if err != nil {
+		return nil, err
+	}
+	if err := users.SetPassword(*f.tenant, *f.email, password); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"tenant_id": *f.tenant, "email": *f.email, "password_reset": true}, nil
+}
+
+func setDisabled(disabled bool) func(cfg *config.Config, args []string) (interface{}, error) {
+	return func(cfg *config.Config, args []string) (interface{}, error) {
+		name := "unlock"
+		if disabled {
+			name = "disable"
+		}
+		fs := flag.NewFlagSet(name, flag.ExitOnError)
+		f := newUserFlags(cfg, fs)
+		fs.Parse(args)
+
+		sessions, err := openSessions(cfg)
+		if err != nil {
+			return nil, err
+		}
+		users, err := f.userUseCase(cfg, sessions...)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := users.SetDisabled(*f.tenant, *f.email, disabled)
+		if err != nil {
+			return nil, err
+		}
+		return newUserOutput(*f.tenant, updated), nil
+	}
+}
+
+func revokeSessions(cfg *config.Config, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("revoke-sessions", flag.ExitOnError)
+	f := newUserFlags(cfg, fs)
+	fs.Parse(args)
+
+	sessions, err := openSessions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, errors.New("sessions are only kept in the servers' memory; configure SESSION_DB_DRIVER and SESSION_DB_DSN, or restart the servers to end every session")
+	}
+	users, err := f.userUseCase(cfg, sessions...)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := users.RevokeSessions(*f.tenant, *f.email)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"tenant_id": *f.tenant, "email": *f.email, "sessions_revoked": revoked}, nil
+}
+
+// openSessions returns the option ending sessions in the configured session database. Without
+// one, sessions live in the memory of the servers and cannot be reached from here.
+func openSessions(cfg *config.Config) ([]usecase.UserUseCaseOption, error) {
+	if cfg.Sessions.DBDriver == "" {
+		return nil, nil
+	}
+	sessionRepo, err := repository.OpenSQLSessionRepository(cfg.Sessions.DBDriver, cfg.Sessions.DBDSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session store: %w", err)
+	}
+	return []usecase.UserUseCaseOption{usecase.WithSessions(sessionRepo)}, nil
+}
+
+func mintToken(cfg *config.Config, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("mint-token", flag.ExitOnError)
+	f := newUserFlags(cfg, fs)
+	ttl := fs.Duration("ttl", 15*time.Minute, fmt.Sprintf("how long the token is valid, at most %s", usecase.MaxDebugTokenTTL))
+	fs.Parse(args)
+
+	users, err := f.userUseCase(cfg)
+	if err != nil {
+		return nil, err
+	}
+	token, err := users.IssueDebugToken(*f.tenant, *f.email, *ttl)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"access_token": token.Token,
+		"token_type":   "Bearer",
+		"expires_at":   token.ExpiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+func rotateSigningKey(cfg *config.Config, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("rotate-signing-key", flag.ExitOnError)
+	out := fs.String("out", "", "path of the new PEM key file; it must not exist")
+	keep := fs.Int("keep", 2, "configured keys to keep publishing so tokens they signed still verify")
+	fs.Parse(args)
+
+	if *out == "" {
+		return nil, errors.New("a key file is required: pass -out")
+	}
+	if *keep < 0 {
+		return nil, errors.New("-keep must not be negative")
+	}
+	// Check the configured keys load before adding one to them
+	if len(cfg.OIDCSigningKeyFiles) > 0 {
+		if _, err := auth.LoadKeySet(cfg.OIDCSigningKeyFiles...); err != nil {
+			return nil, fmt.Errorf("failed to load the configured signing keys: %w", err)
+		}
+	}
+
+	keyID, err := auth.WriteSigningKey(*out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+	retained := cfg.OIDCSigningKeyFiles
+	if len(retained) > *keep {
+		retained = retained[:*keep]
+	}
+	files := append([]string{*out}, retained...)
+	return map[string]interface{}{
+		"key_id":                 keyID,
+		"path":                   *out,
+		"oidc_signing_key_files": strings.Join(files, ","),
+		"retired":                append([]string{}, cfg.OIDCSigningKeyFiles[len(retained):]...),
+	}, nil
+}
+
+func argonParams(cfg *config.Config, args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("argon-params", flag.ExitOnError)
+	calibrate := fs.Duration("calibrate", 0, "measure parameters that take this long to hash on this host, e.g. 250ms")
+	maxMemory := fs.Int("max-memory-mib", int(cfg.ArgonMaxMemory/1024), "memory cap for -calibrate, in MiB")
+	fs.Parse(args)
+
+	params := cfg.ArgonParams()
+	if *calibrate > 0 {
+		params = auth.CalibrateArgonParams(*calibrate, uint32(*maxMemory)*1024, params)
+	}
+	out := map[string]interface{}{
+		"algorithm":   "argon2id",
+		"memory_kib":  params.Memory,
+		"iterations":  params.Iterations,
+		"parallelism": params.Parallelism,
+		"salt_length": params.SaltLength,
+		"key_length":  params.KeyLength,
+		"params":      params.String(),
+		"calibrated":  cfg.ArgonCalibrateTarget > 0,
+		"pepper_key":  cfg.Pepper.CurrentKeyID,
+	}
+	if cfg.ArgonCalibrateTarget > 0 {
+		out["calibrate_target"] = cfg.ArgonCalibrateTarget.String()
+	}
+	// Measured values only apply once every server is configured with them
+	if *calibrate > 0 {
+		out["calibrate_target"] = calibrate.String()
+		out["env"] = map[string]string{
+			"ARGON_MEMORY_KIB":  fmt.Sprint(params.Memory),
+			"ARGON_ITERATIONS":  fmt.Sprint(params.Iterations),
+			"ARGON_PARALLELISM": fmt.Sprint(params.Parallelism),
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,63 @@
+// Command password-report prints how many users are on each password hashing parameter set
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/lamboktulussimamora/gra-project/internal/config"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	storePath := flag.String("store", cfg.UserStorePath, "path to the JSON user store")
+	tenantID := flag.String("tenant", tenant.DefaultID, "tenant whose users are reported")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	if *storePath == "" {
+		log.Fatal("A user store is required: pass -store or set USER_STORE_PATH")
+	}
+
+	userRepo, err := repository.NewFileUserRepository(*storePath)
+	if err != nil {
+		log.Fatalf("Failed to open user store: %v", err)
+	}
+
+	// Compare against the parameters and pepper key the servers hash with
+	passwordService := auth.NewPasswordService(cfg.ArgonParams(), auth.WithPepper(cfg.Pepper))
+	userUseCase := usecase.NewUserUseCase(userRepo, passwordService, nil)
+
+	report, err := userUseCase.PasswordHashReport(*tenantID)
+	if err != nil {
+		log.Fatalf("Failed to build report: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PARAMS\tUSERS\tCURRENT")
+	for _, stats := range report {
+		fmt.Fprintf(w, "%s\t%d\t%t\n", stats.Params, stats.Users, stats.Current)
+	}
+	w.Flush()
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/middleware"
 	"github.com/lamboktulussimamora/gra/context"
 	"github.com/lamboktulussimamora/gra/router"
 )
@@ -84,3 +86,46 @@ func CORSMiddleware(origin string) router.Middleware {
 		}
 	}
 }
+
+// AuthMiddlewareFrom adapts the net/http auth middleware, including its API key support, to the gra router.
+// The principal and user claims are stored under the same context keys as on the net/http server.
+func AuthMiddlewareFrom(m *middleware.AuthMiddleware) router.Middleware {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(c *context.Context) {
+			principal, errorMsg := m.Resolve(c.Request)
+			if principal == nil {
+				c.Error(http.StatusUnauthorized, errorMsg)
+				return
+			}
+
+			c.WithValue(common.PrincipalKey, principal)
+			if principal.Claims != nil {
+				c.WithValue(common.UserClaimsKey, principal.Claims)
+			}
+
+			next(c)
+		}
+	}
+}
+
+// WrapHTTP mounts a net/http handler on the gra router.
+// Values added to the gra context are visible through the request context.
+func WrapHTTP(h http.HandlerFunc) router.HandlerFunc {
+	return func(c *context.Context) {
+		h(c.Writer, c.Request)
+	}
+}
+
+// HTTPMiddleware adapts a net/http middleware, such as the CSRF middleware, to the gra router.
+// The request seen by the wrapped middleware, including context values, is passed on to next.
+func HTTPMiddleware(mw func(http.Handler) http.Handler) router.Middleware {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(c *context.Context) {
+			mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.Writer = w
+				c.Request = r
+				next(c)
+			})).ServeHTTP(c.Writer, c.Request)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+)
+
+// argonCalibration is the layout of the calibration cache file. A cached result is only reused
+// for the same target, memory cap and base parameters.
+type argonCalibration struct {
+	Target    string           `json:"target"`
+	MaxMemory uint32           `json:"max_memory_kib"`
+	Base      auth.ArgonParams `json:"base"`
+	Params    auth.ArgonParams `json:"params"`
+}
+
+// calibratedArgonParams calibrates the Argon2 parameters to target, reusing the result kept in
+// cachePath if there is one. Measurements vary a little between runs, and every change to
+// stronger parameters rehashes passwords at their next login, so servers should share a cache.
+func calibratedArgonParams(target time.Duration, maxMemory uint32, base auth.ArgonParams, cachePath string) auth.ArgonParams {
+	key := argonCalibration{Target: target.String(), MaxMemory: maxMemory, Base: base}
+	if cachePath != "" {
+		cached, err := readArgonCalibration(cachePath)
+		if err == nil && cached.Target == key.Target && cached.MaxMemory == key.MaxMemory && cached.Base == key.Base {
+			log.Printf("Using Argon2 parameters %s calibrated for a %s target from %s", cached.Params, target, cachePath)
+			return cached.Params
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Ignoring Argon2 calibration cache %s: %v", cachePath, err)
+		}
+	}
+
+	key.Params = auth.CalibrateArgonParams(target, maxMemory, base)
+	log.Printf("Calibrated Argon2 parameters to %s for a %s target", key.Params, target)
+	if cachePath != "" {
+		if err := writeArgonCalibration(cachePath, key); err != nil {
+			log.Printf("Failed to write Argon2 calibration cache %s: %v", cachePath, err)
+		}
+	}
+	return key.Params
+}
+
+// readArgonCalibration reads a calibration cache file
+func readArgonCalibration(path string) (argonCalibration, error) {
+	var cached argonCalibration
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cached, err
+	}
+	err = json.Unmarshal(data, &cached)
+	return cached, err
+}
+
+// writeArgonCalibration replaces a calibration cache file
+func writeArgonCalibration(path string, calibration argonCalibration) error {
+	data, err := json.MarshalIndent(calibration, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,699 @@
+// Package config loads the runtime configuration shared by the servers and command line tools
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+)
+
+// defaultJWTSecret keeps local setups working without configuration; set JWT_SECRET in production
+const defaultJWTSecret = "your-secret-key-here"
+
+// Config holds settings read from the environment
+type Config struct {
+	// UserStorePath is the JSON user store; users are kept in memory when empty
+	UserStorePath string
+	// BreachedPasswordsPath is an optional breached-password corpus
+	BreachedPasswordsPath string
+	// JWTSecret signs the tokens issued at login; servers and tools must share it
+	JWTSecret string
+	// Pepper holds the server-side password pepper keys
+	Pepper auth.Pepper
+	// GenericRegistration hides whether an email is already registered
+	GenericRegistration bool
+	// HashLimiter bounds the concurrency and memory of password hashing
+	HashLimiter auth.LimiterConfig
+	// Argon holds the Argon2 parameters new passwords are hashed with, unless they are calibrated
+	Argon auth.ArgonParams
+	// ArgonCalibrateTarget, when set, calibrates the Argon2 parameters to this latency at startup
+	ArgonCalibrateTarget time.Duration
+	// ArgonCalibrationCache is an optional file the calibrated parameters are kept in, so
+	// restarts and other processes on the host reuse them instead of measuring again
+	ArgonCalibrationCache string
+	// ArgonMaxMemory caps the Argon2 memory cost chosen by calibration, in KiB
+	ArgonMaxMemory uint32
+	// Sessions configures cookie-based browser sessions
+	Sessions SessionSettings
+	// OAuthIssuer is the public base URL of the OAuth authorization server
+	OAuthIssuer string
+	// OIDCSigningKeyFiles are PEM RSA keys for ID tokens; the first signs new tokens.
+	// A key is generated at startup when empty.
+	OIDCSigningKeyFiles []string
+	// ExternalLogin configures sign-in through upstream identity providers
+	ExternalLogin ExternalLoginSettings
+	// LDAP configures password logins against a directory
+	LDAP LDAPSettings
+	// Tenants configures the tenants and how requests are matched to them
+	Tenants TenantSettings
+	// Invitations configures the links that invite users to organizations
+	Invitations InvitationSettings
+	// Authz configures fine-grained authorization
+	Authz AuthzSettings
+	// Webhooks configures the delivery of user lifecycle events
+	Webhooks WebhookSettings
+	// GRPCAddr is the listen address of the gRPC API
+	GRPCAddr string
+	// Introspection configures token introspection for other services
+	Introspection IntrospectionSettings
+}
+
+// IntrospectionSettings holds the token introspection settings
+type IntrospectionSettings struct {
+	// CacheTTL is how long a result is reused; zero disables the cache
+	CacheTTL time.Duration
+	// RateLimit is how many tokens each caller may introspect per minute
+	RateLimit int
+}
+
+// WebhookSettings holds the webhook delivery settings
+type WebhookSettings struct {
+	// MaxAttempts is how often a delivery is tried before it is dead-lettered
+	MaxAttempts int
+	// Timeout bounds each delivery attempt
+	Timeout time.Duration
+	// AllowHTTP accepts plain http endpoint URLs, for local development
+	AllowHTTP bool
+	// AllowPrivateNetworks accepts endpoints on loopback, private and link-local addresses
+	AllowPrivateNetworks bool
+}
+
+// AuthzSettings holds the fine-grained authorization settings
+type AuthzSettings struct {
+	// PolicyFile is the JSON authorization policy; without one no types are defined and no
+	// routes are checked
+	PolicyFile string
+	// DBDriver and DBDSN select a SQL relation tuple store; tuples are kept in memory when empty
+	DBDriver string
+	DBDSN    string
+}
+
+// InvitationSettings holds the organization invitation settings
+type InvitationSettings struct {
+	// SigningKey signs invitation links. A key is generated at startup when empty, which
+	// invalidates outstanding links on every restart.
+	SigningKey []byte
+	TTL        time.Duration
+	// AcceptURL is the page invitees open; defaults to <OAUTH_ISSUER>/invitations/accept
+	AcceptURL string
+}
+
+// TenantSettings holds the tenants besides the default one and how a request selects its tenant.
+// Requests that select no tenant belong to the default tenant.
+type TenantSettings struct {
+	Tenants []TenantEntry
+	// BaseDomain enables subdomain resolution, e.g. acme.example.com with base domain example.com
+	BaseDomain string
+	// Header names the request header carrying a tenant ID
+	Header string
+	// PathPrefix enables path resolution, e.g. /t/acme/login with prefix /t/
+	PathPrefix string
+}
+
+// TenantEntry is a configured tenant
+type TenantEntry struct {
+	ID   string
+	Name string
+}
+
+// LDAPSettings holds the directory login settings. Directory logins are off when URL is empty.
+type LDAPSettings struct {
+	URL          string
+	StartTLS     bool
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	UserFilter   string
+	GroupBaseDN  string
+	GroupFilter  string
+	// GroupRoles maps group DNs to roles
+	GroupRoles map[string]string
+	// TenantID is the tenant the directory's users belong to
+	TenantID string
+}
+
+// ExternalLoginSettings holds the upstream identity provider settings
+type ExternalLoginSettings struct {
+	Providers     []ExternalProviderSettings
+	SAMLProviders []SAMLProviderSettings
+	// LinkOnly stops external logins from creating accounts
+	LinkOnly bool
+	// DBDriver and DBDSN select a SQL linked identity store; links are kept in memory when empty
+	DBDriver string
+	DBDSN    string
+}
+
+// ExternalProviderSettings describes one upstream provider. Issuer selects OpenID Connect
+// discovery; plain OAuth 2.0 providers set the endpoint URLs instead.
+type ExternalProviderSettings struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL defaults to <OAUTH_ISSUER>/auth/<name>/callback
+	RedirectURL string
+	Scopes      []string
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	TrustEmail  bool
+}
+
+// SAMLProviderSettings describes one SAML identity provider. IdPMetadataFile replaces the
+// IdP entity ID, SSO URL and certificate settings.
+type SAMLProviderSettings struct {
+	Name string
+	// EntityID defaults to <OAUTH_ISSUER>/auth/<name>/metadata
+	EntityID string
+	// ACSURL defaults to <OAUTH_ISSUER>/auth/<name>/acs
+	ACSURL          string
+	IdPMetadataFile string
+	IdPEntityID     string
+	IdPSSOURL       string
+	IdPCertFile     string
+	EmailAttribute  string
+	// FirstNameAttribute and LastNameAttribute override the standard attribute names
+	FirstNameAttribute string
+	LastNameAttribute  string
+	TrustEmail         bool
+}
+
+// SessionSettings holds the cookie session settings
+type SessionSettings struct {
+	// Enabled turns on the session endpoints and cookie authentication
+	Enabled         bool
+	IdleTimeout     time.Duration
+	AbsoluteTimeout time.Duration
+	CookieName      string
+	CookieSecure    bool
+	CookieSameSite  http.SameSite
+	// DBDriver and DBDSN select a SQL session store; sessions are kept in memory when empty.
+	// The driver must be linked into the binary.
+	DBDriver string
+	DBDSN    string
+}
+
+// Load reads the configuration from the environment:
+//
+//	USER_STORE_PATH          path to the JSON user store
+//	BREACHED_PASSWORDS_PATH  breached-password corpus file or range directory
+//	JWT_SECRET               HMAC secret of login tokens (default: a development placeholder)
+//	PASSWORD_PEPPER_KEYS     comma separated "<id>=<base64 secret>" pepper keys
+//	PASSWORD_PEPPER_CURRENT  ID of the pepper key used for new hashes
+//	GENERIC_REGISTRATION     "true" to answer registrations identically for taken emails
+//	HASH_MAX_CONCURRENT      concurrent password hashes (default: number of CPUs)
+//	HASH_MEMORY_BUDGET_MIB   total Argon2 memory for concurrent hashes (default: 512)
+//	HASH_QUEUE_TIMEOUT       how long a hash may wait for capacity (default: 2s)
+//	ARGON_MEMORY_KIB         Argon2 memory cost in KiB (default: 65536)
+//	ARGON_ITERATIONS         Argon2 iterations (default: 3)
+//	ARGON_PARALLELISM        Argon2 lanes (default: 2)
+//	ARGON_CALIBRATE_TARGET   target hash latency for startup calibration, e.g. 250ms
+//	ARGON_CALIBRATION_CACHE  file the calibrated parameters are kept in and reused from
+//	ARGON_MAX_MEMORY_MIB     Argon2 memory cap used by calibration (default: 64)
+//	SESSIONS_ENABLED         "true" to enable cookie-based browser sessions
+//	SESSION_IDLE_TIMEOUT     how long an unused session stays valid (default: 30m)
+//	SESSION_ABSOLUTE_TIMEOUT maximum session lifetime (default: 24h)
+//	SESSION_COOKIE_NAME      session cookie name (default: session)
+//	SESSION_COOKIE_INSECURE  "true" to drop the Secure attribute for local HTTP development
+//	SESSION_COOKIE_SAMESITE  lax, strict or none (default: lax)
+//	SESSION_DB_DRIVER        database/sql driver name of the session store, e.g. sqlite3
+//	SESSION_DB_DSN           data source name of the session store
+//	OAUTH_ISSUER             public base URL of the OAuth server (default: the server's local URL)
+//	OIDC_SIGNING_KEY_FILES   comma separated PEM RSA keys for ID tokens, current key first
+//	EXTERNAL_PROVIDERS       comma separated names of upstream identity providers, each configured by
+//	                         EXTERNAL_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL, _SCOPES,
+//	                         _AUTH_URL, _TOKEN_URL, _USERINFO_URL and _TRUST_EMAIL
+//	SAML_PROVIDERS           comma separated names of SAML identity providers, each configured by
+//	                         SAML_<NAME>_IDP_METADATA_FILE or _IDP_ENTITY_ID, _IDP_SSO_URL and
+//	                         _IDP_CERT_FILE, plus _ENTITY_ID, _ACS_URL, _EMAIL_ATTRIBUTE,
+//	                         _FIRST_NAME_ATTRIBUTE, _LAST_NAME_ATTRIBUTE and _TRUST_EMAIL
+//	EXTERNAL_LINK_ONLY       "true" to only sign in to existing accounts through external providers
+//	IDENTITY_DB_DRIVER       database/sql driver name of the linked identity store, e.g. sqlite3
+//	IDENTITY_DB_DSN          data source name of the linked identity store
+//	LDAP_URL                 directory URL for password logins, e.g. ldaps://dc.example.com
+//	LDAP_START_TLS           "true" to upgrade an ldap:// connection with StartTLS
+//	LDAP_BIND_DN             service account used to search the directory (default: anonymous)
+//	LDAP_BIND_PASSWORD       service account password
+//	LDAP_BASE_DN             where to search for users
+//	LDAP_USER_FILTER         user search filter with %s for the email (default: (&(objectClass=person)(mail=%s)))
+//	LDAP_GROUP_BASE_DN       where to search for groups, for directories without memberOf
+//	LDAP_GROUP_FILTER        group search filter with %s for the user DN (default: (member=%s))
+//	LDAP_GROUP_ROLES         semicolon separated "<role>=<group DN>" mappings
+//	LDAP_TENANT              tenant whose users the directory signs in (default: default)
+//	TENANTS                  comma separated "<id>=<name>" tenants besides the default tenant
+//	TENANT_BASE_DOMAIN       domain under which subdomains select a tenant, e.g. example.com
+//	TENANT_HEADER            request header that selects a tenant (default: X-Tenant-ID)
+//	TENANT_PATH_PREFIX       path prefix followed by a tenant ID that selects it, e.g. /t/
+//	INVITE_SIGNING_KEY       base64 key of at least 32 bytes that signs organization invitation links
+//	INVITE_TTL               how long an invitation link stays valid (default: 168h)
+//	INVITE_ACCEPT_URL        page invitees open, which receives the token parameter
+//	AUTHZ_POLICY_FILE        JSON policy of object types, permissions and checked routes
+//	AUTHZ_DB_DRIVER          database/sql driver name of the relation tuple store, e.g. sqlite3
+//	AUTHZ_DB_DSN             data source name of the relation tuple store
+//	WEBHOOK_MAX_ATTEMPTS     attempts before a webhook delivery is dead-lettered (default: 8)
+//	WEBHOOK_TIMEOUT          how long a webhook endpoint may take to respond (default: 10s)
+//	WEBHOOK_ALLOW_HTTP       "true" to accept plain http webhook URLs for local development
+//	WEBHOOK_ALLOW_PRIVATE    "true" to deliver webhooks to loopback, private and link-local addresses
+//	GRPC_ADDR                listen address of the gRPC API (default: :9090)
+//	INTROSPECT_CACHE_TTL     how long token introspection results are reused (default: 30s)
+//	INTROSPECT_RATE_LIMIT    tokens each caller may introspect per minute (default: 600)
+func Load() (*Config, error) {
+	cfg := &Config{
+		UserStorePath:         os.Getenv("USER_STORE_PATH"),
+		BreachedPasswordsPath: os.Getenv("BREACHED_PASSWORDS_PATH"),
+		JWTSecret:             os.Getenv("JWT_SECRET"),
+		OAuthIssuer:           os.Getenv("OAUTH_ISSUER"),
+		OIDCSigningKeyFiles:   envList("OIDC_SIGNING_KEY_FILES"),
+		GRPCAddr:              os.Getenv("GRPC_ADDR"),
+	}
+	if cfg.GRPCAddr == "" {
+		cfg.GRPCAddr = ":9090"
+	}
+	if cfg.JWTSecret == "" {
+		cfg.JWTSecret = defaultJWTSecret
+	}
+
+	var err error
+	if cfg.GenericRegistration, err = envBool("GENERIC_REGISTRATION"); err != nil {
+		return nil, err
+	}
+
+	keys, err := auth.ParsePepperKeys(os.Getenv("PASSWORD_PEPPER_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Pepper = auth.Pepper{
+		CurrentKeyID: os.Getenv("PASSWORD_PEPPER_CURRENT"),
+		Keys:         keys,
+	}
+	if cfg.Pepper.CurrentKeyID != "" && !cfg.Pepper.Enabled() {
+		return nil, fmt.Errorf("PASSWORD_PEPPER_CURRENT %q is not in PASSWORD_PEPPER_KEYS", cfg.Pepper.CurrentKeyID)
+	}
+
+	maxConcurrent, err := envInt("HASH_MAX_CONCURRENT", runtime.NumCPU())
+	if err != nil {
+		return nil, err
+	}
+	memoryBudget, err := envInt("HASH_MEMORY_BUDGET_MIB", 512)
+	if err != nil {
+		return nil, err
+	}
+	queueTimeout, err := envDuration("HASH_QUEUE_TIMEOUT", 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.HashLimiter = auth.LimiterConfig{
+		MaxConcurrent: maxConcurrent,
+		MemoryBudget:  uint64(memoryBudget) * 1024,
+		QueueTimeout:  queueTimeout,
+	}
+
+	if cfg.Argon, err = loadArgonParams(); err != nil {
+		return nil, err
+	}
+	if cfg.ArgonCalibrateTarget, err = envDuration("ARGON_CALIBRATE_TARGET", 0); err != nil {
+		return nil, err
+	}
+	cfg.ArgonCalibrationCache = os.Getenv("ARGON_CALIBRATION_CACHE")
+	maxMemory, err := envInt("ARGON_MAX_MEMORY_MIB", 64)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ArgonMaxMemory = uint32(maxMemory) * 1024
+
+	if cfg.Sessions, err = loadSessionSettings(); err != nil {
+		return nil, err
+	}
+	if cfg.ExternalLogin, err = loadExternalLoginSettings(); err != nil {
+		return nil, err
+	}
+	if cfg.LDAP, err = loadLDAPSettings(); err != nil {
+		return nil, err
+	}
+	if cfg.Tenants, err = loadTenantSettings(); err != nil {
+		return nil, err
+	}
+	if cfg.Invitations, err = loadInvitationSettings(); err != nil {
+		return nil, err
+	}
+	if cfg.Authz, err = loadAuthzSettings(); err != nil {
+		return nil, err
+	}
+	if cfg.Webhooks, err = loadWebhookSettings(); err != nil {
+		return nil, err
+	}
+	if cfg.Introspection.CacheTTL, err = envDuration("INTROSPECT_CACHE_TTL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.Introspection.RateLimit, err = envInt("INTROSPECT_RATE_LIMIT", 600); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadSessionSettings reads the SESSION_* variables
+func loadSessionSettings() (SessionSettings, error) {
+	settings := SessionSettings{
+		CookieName: os.Getenv("SESSION_COOKIE_NAME"),
+		DBDriver:   os.Getenv("SESSION_DB_DRIVER"),
+		DBDSN:      os.Getenv("SESSION_DB_DSN"),
+	}
+	if settings.CookieName == "" {
+		settings.CookieName = "session"
+	}
+
+	var err error
+	if settings.Enabled, err = envBool("SESSIONS_ENABLED"); err != nil {
+		return settings, err
+	}
+	insecure, err := envBool("SESSION_COOKIE_INSECURE")
+	if err != nil {
+		return settings, err
+	}
+	settings.CookieSecure = !insecure
+
+	if settings.IdleTimeout, err = envDuration("SESSION_IDLE_TIMEOUT", 30*time.Minute); err != nil {
+		return settings, err
+	}
+	if settings.AbsoluteTimeout, err = envDuration("SESSION_ABSOLUTE_TIMEOUT", 24*time.Hour); err != nil {
+		return settings, err
+	}
+
+	switch v := os.Getenv("SESSION_COOKIE_SAMESITE"); v {
+	case "", "lax":
+		settings.CookieSameSite = http.SameSiteLaxMode
+	case "strict":
+		settings.CookieSameSite = http.SameSiteStrictMode
+	case "none":
+		// Browsers reject SameSite=None cookies without the Secure attribute
+		if !settings.CookieSecure {
+			return settings, fmt.Errorf("SESSION_COOKIE_SAMESITE none requires a secure cookie")
+		}
+		settings.CookieSameSite = http.SameSiteNoneMode
+	default:
+		return settings, fmt.Errorf("invalid SESSION_COOKIE_SAMESITE %q", v)
+	}
+
+	if (settings.DBDriver == "") != (settings.DBDSN == "") {
+		return settings, fmt.Errorf("SESSION_DB_DRIVER and SESSION_DB_DSN must be set together")
+	}
+
+	return settings, nil
+}
+
+// loadArgonParams reads the ARGON_* cost parameters over the defaults
+func loadArgonParams() (auth.ArgonParams, error) {
+	params := auth.DefaultArgonParams()
+	memory, err := envInt("ARGON_MEMORY_KIB", int(params.Memory))
+	if err != nil {
+		return params, err
+	}
+	iterations, err := envInt("ARGON_ITERATIONS", int(params.Iterations))
+	if err != nil {
+		return params, err
+	}
+	parallelism, err := envInt("ARGON_PARALLELISM", int(params.Parallelism))
+	if err != nil {
+		return params, err
+	}
+	if parallelism > 255 {
+		return params, fmt.Errorf("invalid ARGON_PARALLELISM %d", parallelism)
+	}
+	// Argon2 needs at least 8 KiB per lane
+	if memory < 8*parallelism {
+		return params, fmt.Errorf("ARGON_MEMORY_KIB must be at least %d for %d lanes", 8*parallelism, parallelism)
+	}
+
+	params.Memory = uint32(memory)
+	params.Iterations = uint32(iterations)
+	params.Parallelism = uint8(parallelism)
+	return params, nil
+}
+
+// ArgonParams returns the Argon2 parameters to hash with: the configured ones, or parameters
+// calibrated to ArgonCalibrateTarget on this host when a target is configured
+func (c *Config) ArgonParams() auth.ArgonParams {
+	if c.ArgonCalibrateTarget <= 0 {
+		return c.Argon
+	}
+	return calibratedArgonParams(c.ArgonCalibrateTarget, c.ArgonMaxMemory, c.Argon, c.ArgonCalibrationCache)
+}
+
+// loadExternalLoginSettings reads the EXTERNAL_*, SAML_* and IDENTITY_DB_* variables
+func loadExternalLoginSettings() (ExternalLoginSettings, error) {
+	settings := ExternalLoginSettings{
+		DBDriver: os.Getenv("IDENTITY_DB_DRIVER"),
+		DBDSN:    os.Getenv("IDENTITY_DB_DSN"),
+	}
+
+	var err error
+	if settings.LinkOnly, err = envBool("EXTERNAL_LINK_ONLY"); err != nil {
+		return settings, err
+	}
+
+	for _, name := range envList("EXTERNAL_PROVIDERS") {
+		prefix := "EXTERNAL_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := ExternalProviderSettings{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(strings.ReplaceAll(os.Getenv(prefix+"SCOPES"), ",", " ")),
+			AuthURL:      os.Getenv(prefix + "AUTH_URL"),
+			TokenURL:     os.Getenv(prefix + "TOKEN_URL"),
+			UserInfoURL:  os.Getenv(prefix + "USERINFO_URL"),
+		}
+		if provider.TrustEmail, err = envBool(prefix + "TRUST_EMAIL"); err != nil {
+			return settings, err
+		}
+		if provider.ClientID == "" {
+			return settings, fmt.Errorf("%sCLIENT_ID is required", prefix)
+		}
+		settings.Providers = append(settings.Providers, provider)
+	}
+
+	for _, name := range envList("SAML_PROVIDERS") {
+		prefix := "SAML_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := SAMLProviderSettings{
+			Name:               name,
+			EntityID:           os.Getenv(prefix + "ENTITY_ID"),
+			ACSURL:             os.Getenv(prefix + "ACS_URL"),
+			IdPMetadataFile:    os.Getenv(prefix + "IDP_METADATA_FILE"),
+			IdPEntityID:        os.Getenv(prefix + "IDP_ENTITY_ID"),
+			IdPSSOURL:          os.Getenv(prefix + "IDP_SSO_URL"),
+			IdPCertFile:        os.Getenv(prefix + "IDP_CERT_FILE"),
+			EmailAttribute:     os.Getenv(prefix + "EMAIL_ATTRIBUTE"),
+			FirstNameAttribute: os.Getenv(prefix + "FIRST_NAME_ATTRIBUTE"),
+			LastNameAttribute:  os.Getenv(prefix + "LAST_NAME_ATTRIBUTE"),
+		}
+		if provider.TrustEmail, err = envBool(prefix + "TRUST_EMAIL"); err != nil {
+			return settings, err
+		}
+		if provider.IdPMetadataFile == "" && (provider.IdPEntityID == "" || provider.IdPSSOURL == "" || provider.IdPCertFile == "") {
+			return settings, fmt.Errorf("%sIDP_METADATA_FILE or %sIDP_ENTITY_ID, %sIDP_SSO_URL and %sIDP_CERT_FILE are required",
+				prefix, prefix, prefix, prefix)
+		}
+		settings.SAMLProviders = append(settings.SAMLProviders, provider)
+	}
+
+	return settings, nil
+}
+
+// loadLDAPSettings reads the LDAP_* variables
+func loadLDAPSettings() (LDAPSettings, error) {
+	settings := LDAPSettings{
+		URL:          os.Getenv("LDAP_URL"),
+		BindDN:       os.Getenv("LDAP_BIND_DN"),
+		BindPassword: os.Getenv("LDAP_BIND_PASSWORD"),
+		BaseDN:       os.Getenv("LDAP_BASE_DN"),
+		UserFilter:   os.Getenv("LDAP_USER_FILTER"),
+		GroupBaseDN:  os.Getenv("LDAP_GROUP_BASE_DN"),
+		GroupFilter:  os.Getenv("LDAP_GROUP_FILTER"),
+		GroupRoles:   make(map[string]string),
+		TenantID:     os.Getenv("LDAP_TENANT"),
+	}
+	if settings.URL == "" {
+		return settings, nil
+	}
+
+	var err error
+	if settings.StartTLS, err = envBool("LDAP_START_TLS"); err != nil {
+		return settings, err
+	}
+	if settings.BaseDN == "" {
+		return settings, fmt.Errorf("LDAP_BASE_DN is required with LDAP_URL")
+	}
+	if settings.TenantID == "" {
+		settings.TenantID = tenant.DefaultID
+	}
+	if !tenant.ValidID(settings.TenantID) {
+		return settings, fmt.Errorf("invalid LDAP_TENANT %q", settings.TenantID)
+	}
+
+	// Group DNs contain commas, so mappings are separated by semicolons
+	for _, mapping := range strings.Split(os.Getenv("LDAP_GROUP_ROLES"), ";") {
+		if mapping = strings.TrimSpace(mapping); mapping == "" {
+			continue
+		}
+		role, groupDN, ok := strings.Cut(mapping, "=")
+		if !ok || strings.TrimSpace(role) == "" || strings.TrimSpace(groupDN) == "" {
+			return settings, fmt.Errorf("invalid LDAP_GROUP_ROLES entry %q", mapping)
+		}
+		settings.GroupRoles[strings.TrimSpace(groupDN)] = strings.TrimSpace(role)
+	}
+
+	return settings, nil
+}
+
+// loadTenantSettings reads the TENANT* variables
+func loadTenantSettings() (TenantSettings, error) {
+	settings := TenantSettings{
+		BaseDomain: strings.ToLower(strings.Trim(os.Getenv("TENANT_BASE_DOMAIN"), ".")),
+		Header:     os.Getenv("TENANT_HEADER"),
+		PathPrefix: os.Getenv("TENANT_PATH_PREFIX"),
+	}
+	if settings.Header == "" {
+		settings.Header = "X-Tenant-ID"
+	}
+	if settings.PathPrefix != "" && (!strings.HasPrefix(settings.PathPrefix, "/") || !strings.HasSuffix(settings.PathPrefix, "/")) {
+		return settings, fmt.Errorf("TENANT_PATH_PREFIX %q must start and end with /", settings.PathPrefix)
+	}
+
+	for _, entry := range envList("TENANTS") {
+		id, name, _ := strings.Cut(entry, "=")
+		id, name = strings.TrimSpace(id), strings.TrimSpace(name)
+		if !tenant.ValidID(id) || id == tenant.DefaultID {
+			return settings, fmt.Errorf("invalid TENANTS entry %q", entry)
+		}
+		if name == "" {
+			name = id
+		}
+		settings.Tenants = append(settings.Tenants, TenantEntry{ID: id, Name: name})
+	}
+
+	return settings, nil
+}
+
+// loadInvitationSettings reads the INVITE_* variables
+func loadInvitationSettings() (InvitationSettings, error) {
+	settings := InvitationSettings{AcceptURL: os.Getenv("INVITE_ACCEPT_URL")}
+
+	if v := os.Getenv("INVITE_SIGNING_KEY"); v != "" {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(key) < 32 {
+			return settings, fmt.Errorf("INVITE_SIGNING_KEY must be a base64 key of at least 32 bytes")
+		}
+		settings.SigningKey = key
+	}
+
+	var err error
+	if settings.TTL, err = envDuration("INVITE_TTL", 7*24*time.Hour); err != nil {
+		return settings, err
+	}
+	if settings.TTL <= 0 {
+		return settings, fmt.Errorf("INVITE_TTL must be positive")
+	}
+
+	return settings, nil
+}
+
+// loadAuthzSettings reads the AUTHZ_* variables
+func loadAuthzSettings() (AuthzSettings, error) {
+	settings := AuthzSettings{
+		PolicyFile: os.Getenv("AUTHZ_POLICY_FILE"),
+		DBDriver:   os.Getenv("AUTHZ_DB_DRIVER"),
+		DBDSN:      os.Getenv("AUTHZ_DB_DSN"),
+	}
+	if (settings.DBDriver == "") != (settings.DBDSN == "") {
+		return settings, fmt.Errorf("AUTHZ_DB_DRIVER and AUTHZ_DB_DSN must be set together")
+	}
+	return settings, nil
+}
+
+// loadWebhookSettings reads the WEBHOOK_* variables
+func loadWebhookSettings() (WebhookSettings, error) {
+	var settings WebhookSettings
+	var err error
+	if settings.MaxAttempts, err = envInt("WEBHOOK_MAX_ATTEMPTS", 8); err != nil {
+		return settings, err
+	}
+	if settings.MaxAttempts < 1 {
+		return settings, fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be at least 1")
+	}
+	if settings.Timeout, err = envDuration("WEBHOOK_TIMEOUT", 10*time.Second); err != nil {
+		return settings, err
+	}
+	if settings.Timeout <= 0 {
+		return settings, fmt.Errorf("WEBHOOK_TIMEOUT must be positive")
+	}
+	if settings.AllowHTTP, err = envBool("WEBHOOK_ALLOW_HTTP"); err != nil {
+		return settings, err
+	}
+	if settings.AllowPrivateNetworks, err = envBool("WEBHOOK_ALLOW_PRIVATE"); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// envBool reads a boolean such as "true" from the environment, defaulting to false
+func envBool(name string) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return b, nil
+}
+
+// envList reads a comma separated list from the environment, skipping empty entries
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// envInt reads a positive integer from the environment
+func envInt(name string, fallback int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return n, nil
+}
+
+// envDuration reads a duration such as "250ms" from the environment
+func envDuration(name string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return d, nil
+}
@@ -0,0 +1,58 @@
+// Package apikey defines API keys used by services and batch jobs instead of interactive logins
+package apikey
+
+import (
+	"time"
+)
+
+// OwnerType identifies whether a key belongs to a user or to a service
+type OwnerType string
+
+// Owner types
+const (
+	OwnerUser    OwnerType = "user"
+	OwnerService OwnerType = "service"
+)
+
+// Scopes understood by the API key endpoints
+const (
+	// ScopeManage allows listing, creating and revoking the owner's keys
+	ScopeManage = "api_keys"
+	// ScopeAdmin additionally allows managing service-owned keys
+	ScopeAdmin = "api_keys:admin"
+)
+
+// APIKey represents an API key. Only a hash of the secret is stored; the prefix is
+// public and identifies the key for lookups and in logs.
+type APIKey struct {
+	ID         string
+	TenantID   string
+	Name       string
+	Prefix     string
+	SecretHash string
+	OwnerType  OwnerType
+	OwnerID    string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// Active returns true if the key is neither revoked nor expired
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// Repository defines the interface for API key storage
+type Repository interface {
+	Save(key *APIKey) error
+	Update(key *APIKey) error
+	UpdateLastUsed(id string, usedAt time.Time) error
+	FindByID(id string) (*APIKey, error)
+	FindByPrefix(prefix string) (*APIKey, error)
+	FindByOwner(ownerType OwnerType, ownerID string) ([]*APIKey, error)
+}
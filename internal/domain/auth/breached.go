@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// hashPrefixLength is the number of SHA-1 hex characters used as the k-anonymity range key
+const hashPrefixLength = 5
+
+// ErrInvalidHashPrefix is returned for a range prefix that is not five hex characters
+var ErrInvalidHashPrefix = errors.New("hash prefix must be five hex characters")
+
+// BreachedPasswordChecker reports whether a password appears in a known breach corpus
+type BreachedPasswordChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// HashPrefixCorpus is a breached-password corpus in the Have I Been Pwned k-anonymity format.
+// Passwords are looked up by the first five hex characters of their SHA-1 hash, so only
+// the matching range has to be read, and the check works entirely offline.
+//
+// The corpus can be loaded from a single file of "HASH:COUNT" lines, or from a directory of
+// range files named "<PREFIX>.txt" containing "SUFFIX:COUNT" lines as served by the range API.
+type HashPrefixCorpus struct {
+	dir    string
+	ranges map[string]map[string]int
+	mu     sync.RWMutex
+}
+
+// LoadHashPrefixCorpus opens a breached-password corpus from a file or a directory of range files.
+// A single file is loaded into memory; range files are read on demand and cached.
+func LoadHashPrefixCorpus(path string) (*HashPrefixCorpus, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	corpus := &HashPrefixCorpus{
+		ranges: make(map[string]map[string]int),
+	}
+
+	if info.IsDir() {
+		corpus.dir = path
+		return corpus, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, count, err := parseCorpusLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if hash == "" {
+			continue
+		}
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("invalid corpus hash %q", hash)
+		}
+
+		prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+		if corpus.ranges[prefix] == nil {
+			corpus.ranges[prefix] = make(map[string]int)
+		}
+		corpus.ranges[prefix][suffix] = count
+	}
+
+	return corpus, scanner.Err()
+}
+
+// IsBreached reports whether the password appears in the corpus
+func (c *HashPrefixCorpus) IsBreached(password string) (bool, error) {
+	count, err := c.Count(password)
+	return count > 0, err
+}
+
+// Count returns how many times the password appears in the corpus
+func (c *HashPrefixCorpus) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+
+	suffixes, err := c.Range(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	return suffixes[suffix], nil
+}
+
+// Range returns the hash suffixes and counts stored under a five character hash prefix.
+// The prefix names a range file, so anything but five hex characters is rejected.
+func (c *HashPrefixCorpus) Range(prefix string) (map[string]int, error) {
+	if !validHashPrefix(prefix) {
+		return nil, ErrInvalidHashPrefix
+	}
+	prefix = strings.ToUpper(prefix)
+
+	c.mu.RLock()
+	suffixes, ok := c.ranges[prefix]
+	c.mu.RUnlock()
+	if ok || c.dir == "" {
+		return suffixes, nil
+	}
+
+	suffixes, err := readRangeFile(filepath.Join(c.dir, prefix+".txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.ranges[prefix] = suffixes
+	c.mu.Unlock()
+
+	return suffixes, nil
+}
+
+// validHashPrefix reports whether prefix is exactly hashPrefixLength hex characters
+func validHashPrefix(prefix string) bool {
+	if len(prefix) != hashPrefixLength {
+		return false
+	}
+	for _, r := range prefix {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// readRangeFile reads a range file of "SUFFIX:COUNT" lines. A missing file is an empty range.
+func readRangeFile(path string) (map[string]int, error) {
+	suffixes := make(map[string]int)
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return suffixes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		suffix, count, err := parseCorpusLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if suffix != "" {
+			suffixes[suffix] = count
+		}
+	}
+
+	return suffixes, scanner.Err()
+}
+
+// parseCorpusLine parses a "HASH:COUNT" line. The count is optional and defaults to 1.
+func parseCorpusLine(line string) (string, int, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", 0, nil
+	}
+
+	hash, countStr, hasCount := strings.Cut(line, ":")
+	count := 1
+	if hasCount {
+		if _, err := fmt.Sscanf(countStr, "%d", &count); err != nil {
+			return "", 0, fmt.Errorf("invalid corpus line %q", line)
+		}
+	}
+
+	return strings.ToUpper(hash), count, nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// minCalibrationMemory is the lowest memory cost calibration will fall back to, in KiB
+const minCalibrationMemory = 8 * 1024
+
+// maxCalibrationIterations caps the iterations calibration will try
+const maxCalibrationIterations = 10
+
+// CalibrateArgonParams picks Argon2id parameters that take about target on this host.
+// Starting from base with the memory cost capped at maxMemory, it raises the iterations until
+// a hash takes at least target. If a single iteration is already too slow, it halves the memory
+// instead. Salt length, key length and parallelism are kept from base.
+func CalibrateArgonParams(target time.Duration, maxMemory uint32, base ArgonParams) ArgonParams {
+	params := base
+	if maxMemory > 0 {
+		params.Memory = maxMemory
+	}
+	params.Iterations = 1
+
+	// Reduce memory until one iteration fits in the target
+	for params.Memory > minCalibrationMemory && measureArgon(params) > target {
+		params.Memory /= 2
+	}
+
+	// Add iterations until the target is reached
+	for params.Iterations < maxCalibrationIterations {
+		if measureArgon(params) >= target {
+			break
+		}
+		params.Iterations++
+	}
+
+	return params
+}
+
+// measureArgon returns how long one Argon2id hash takes with the given parameters
+func measureArgon(params ArgonParams) time.Duration {
+	password := []byte("calibration password")
+	salt := make([]byte, params.SaltLength)
+
+	start := time.Now()
+	argon2.IDKey(password, salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return time.Since(start)
+}
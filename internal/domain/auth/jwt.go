@@ -1,10 +1,14 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
 	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
 )
 
@@ -17,17 +21,39 @@ var (
 // JWTService provides methods to generate and validate JWT tokens
 type JWTService interface {
 	GenerateToken(user *user.User) (string, error)
+	// GenerateClaimsToken signs the given claims, setting the issue and expiry times from ttl
+	GenerateClaimsToken(claims *Claims, ttl time.Duration) (string, error)
 	ValidateToken(tokenString string) (*Claims, error)
 }
 
 // Claims represents the JWT claims
 type Claims struct {
-	Email     string `json:"email"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
+	Email     string   `json:"email"`
+	FirstName string   `json:"first_name"`
+	LastName  string   `json:"last_name"`
+	Roles     []string `json:"roles,omitempty"`
+	// TenantID is the tenant the token was issued in; it is only accepted by that tenant
+	TenantID string `json:"tenant_id,omitempty"`
+	// Scope and ClientID are set on OAuth access tokens, which are limited to the granted scopes
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Scopes returns the space separated Scope claim as a list
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// Tenant returns the tenant the token was issued in. Tokens issued before tenants were
+// introduced belong to the default tenant.
+func (c *Claims) Tenant() string {
+	if c.TenantID == "" {
+		return tenant.DefaultID
+	}
+	return c.TenantID
+}
+
 // JWTConfig holds JWT configuration parameters
 type JWTConfig struct {
 	SecretKey     string
@@ -48,11 +74,19 @@ func NewJWTService(config JWTConfig) JWTService {
 
 // GenerateToken generates a new JWT token for the given user
 func (s *DefaultJWTService) GenerateToken(user *user.User) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
 	claims := Claims{
 		Email:     user.Email,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
+		Roles:     user.Roles,
+		TenantID:  user.TenantID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   user.ID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.config.TokenDuration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
@@ -62,6 +96,25 @@ func (s *DefaultJWTService) GenerateToken(user *user.User) (string, error) {
 	return token.SignedString([]byte(s.config.SecretKey))
 }
 
+// GenerateClaimsToken signs the given claims, setting the issue and expiry times from ttl and
+// a unique token ID unless the claims carry one
+func (s *DefaultJWTService) GenerateClaimsToken(claims *Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	signed := *claims
+	if signed.ID == "" {
+		tokenID, err := newTokenID()
+		if err != nil {
+			return "", err
+		}
+		signed.ID = tokenID
+	}
+	signed.IssuedAt = jwt.NewNumericDate(now)
+	signed.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, signed)
+	return token.SignedString([]byte(s.config.SecretKey))
+}
+
 // ValidateToken validates the provided token and returns the claims
 func (s *DefaultJWTService) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
@@ -89,3 +142,12 @@ func (s *DefaultJWTService) ValidateToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// newTokenID returns a random jti, so every token can be revoked on its own
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrUnknownSigningKey is returned when a token names a key ID that is not in the key set
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// SigningKey is an RSA key used to sign tokens that third parties verify, such as ID tokens
+type SigningKey struct {
+	// ID is the RFC 7638 thumbprint of the public key, used as the JWT "kid"
+	ID         string
+	PrivateKey *rsa.PrivateKey
+}
+
+// JSONWebKey is the public part of a signing key, see RFC 7517
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+// JSONWebKeySet is the document served at the JWKS endpoint
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// KeySet holds the RSA keys tokens are signed with. New tokens are signed with the current key;
+// retired keys stay published so tokens they signed still verify until they expire.
+type KeySet struct {
+	keys    []*SigningKey
+	current *SigningKey
+	mu      sync.RWMutex
+}
+
+// NewKeySet creates a key set signing with the given key
+func NewKeySet(current *rsa.PrivateKey) (*KeySet, error) {
+	ks := &KeySet{}
+	if err := ks.Rotate(current); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// GenerateKeySet creates a key set with a freshly generated 2048 bit key
+func GenerateKeySet() (*KeySet, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(key)
+}
+
+// LoadKeySet reads PEM encoded RSA private keys (PKCS #1 or PKCS #8). The first key signs new
+// tokens and the others are only published for verification.
+func LoadKeySet(paths ...string) (*KeySet, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no signing key files given")
+	}
+
+	ks := &KeySet{}
+	for i := len(paths) - 1; i >= 0; i-- {
+		key, err := loadRSAPrivateKey(paths[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := ks.Rotate(key); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// Rotate makes key the current signing key, keeping the previous keys for verification
+func (ks *KeySet) Rotate(key *rsa.PrivateKey) error {
+	if key.N.BitLen() < 2048 {
+		return errors.New("RSA signing keys must be at least 2048 bits")
+	}
+
+	signingKey := &SigningKey{ID: thumbprint(&key.PublicKey), PrivateKey: key}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.keys = append([]*SigningKey{signingKey}, ks.keys...)
+	ks.current = signingKey
+	return nil
+}
+
+// Sign signs claims with the current key using RS256
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	current := ks.current
+	ks.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = current.ID
+	return token.SignedString(current.PrivateKey)
+}
+
+// Parse verifies a token signed by any key in the set and decodes its claims.
+// Parser options can relax validation, e.g. to accept expired ID token hints.
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) error {
+	opts = append(opts, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := ks.find(kid)
+		if key == nil {
+			return nil, ErrUnknownSigningKey
+		}
+		return &key.PrivateKey.PublicKey, nil
+	}, opts...)
+	return err
+}
+
+// JWKS returns the public keys of the set
+func (ks *KeySet) JWKS() JSONWebKeySet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		n, e := encodePublicKey(&key.PrivateKey.PublicKey)
+		set.Keys = append(set.Keys, JSONWebKey{
+			KeyType:   "RSA",
+			Use:       "sig",
+			Algorithm: jwt.SigningMethodRS256.Alg(),
+			KeyID:     key.ID,
+			N:         n,
+			E:         e,
+		})
+	}
+	return set
+}
+
+// find returns the key with the given ID
+func (ks *KeySet) find(kid string) *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	for _, key := range ks.keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// PublicKey converts a JSON web key back to an RSA public key
+func (k JSONWebKey) PublicKey() (*rsa.PublicKey, error) {
+	if k.KeyType != "RSA" {
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+// encodePublicKey returns the base64url encoded modulus and exponent of a key
+func encodePublicKey(key *rsa.PublicKey) (string, string) {
+	n := base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	return n, e
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint of a public key
+func thumbprint(key *rsa.PublicKey) string {
+	n, e := encodePublicKey(key)
+	// The members must be in lexicographic order with no whitespace
+	canonical, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{e, "RSA", n})
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// WriteSigningKey generates a 2048 bit RSA key and writes it to a new file as PKCS #8 PEM,
+// readable by the owner only. It returns the key ID, the key's JWT "kid".
+func WriteSigningKey(path string) (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	// Never overwrite a key that may still sign or verify tokens
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return thumbprint(&key.PublicKey), nil
+}
+
+// loadRSAPrivateKey reads a PEM encoded RSA private key
+func loadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an RSA private key", path)
+	}
+	return key, nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrUnsupportedHash is returned when no verifier is registered for a hash prefix
+var ErrUnsupportedHash = errors.New("unsupported hash algorithm")
+
+// HashVerifier verifies passwords against hashes produced by one algorithm.
+// Verifiers are only used for verification; new hashes are always Argon2id.
+type HashVerifier interface {
+	Verify(hashedPassword, password string) (bool, error)
+	// Describe returns a label with the algorithm and its cost parameters
+	Describe(hashedPassword string) (string, error)
+}
+
+// defaultVerifiers returns the legacy verifiers keyed by their PHC / modular crypt identifier
+func defaultVerifiers() map[string]HashVerifier {
+	bcryptVerifier := BcryptVerifier{}
+	return map[string]HashVerifier{
+		"2a":            bcryptVerifier,
+		"2b":            bcryptVerifier,
+		"2y":            bcryptVerifier,
+		"scrypt":        ScryptVerifier{},
+		"pbkdf2-sha256": PBKDF2SHA256Verifier{},
+	}
+}
+
+// hashIdentifier returns the algorithm identifier of a "$id$..." encoded hash
+func hashIdentifier(hashedPassword string) string {
+	parts := strings.SplitN(hashedPassword, "$", 3)
+	if len(parts) < 3 || parts[0] != "" {
+		return ""
+	}
+	return parts[1]
+}
+
+// BcryptVerifier verifies $2a$, $2b$ and $2y$ bcrypt hashes
+type BcryptVerifier struct{}
+
+// Verify implements HashVerifier
+func (BcryptVerifier) Verify(hashedPassword, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+	return true, nil
+}
+
+// Describe implements HashVerifier
+func (BcryptVerifier) Describe(hashedPassword string) (string, error) {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	if err != nil {
+		return "", ErrInvalidHash
+	}
+	return fmt.Sprintf("bcrypt cost=%d", cost), nil
+}
+
+// ScryptVerifier verifies PHC scrypt hashes: $scrypt$ln=15,r=8,p=1$<salt>$<hash>
+type ScryptVerifier struct{}
+
+// Verify implements HashVerifier
+func (ScryptVerifier) Verify(hashedPassword, password string) (bool, error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 5 {
+		return false, ErrInvalidHash
+	}
+
+	var logN uint
+	var r, p int
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &logN, &r, &p); err != nil || logN > 30 {
+		return false, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+
+	comparisonHash, err := scrypt.Key([]byte(password), salt, 1<<logN, r, p, len(hash))
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+
+	return subtle.ConstantTimeCompare(hash, comparisonHash) == 1, nil
+}
+
+// Describe implements HashVerifier
+func (ScryptVerifier) Describe(hashedPassword string) (string, error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 5 {
+		return "", ErrInvalidHash
+	}
+	return "scrypt " + parts[2], nil
+}
+
+// PBKDF2SHA256Verifier verifies passlib style PBKDF2-SHA256 hashes:
+// $pbkdf2-sha256$<rounds>$<salt>$<hash>, where rounds may also be written as i=<rounds>
+// and salt and hash use the adapted base64 alphabet ("." instead of "+", no padding)
+type PBKDF2SHA256Verifier struct{}
+
+// Verify implements HashVerifier
+func (PBKDF2SHA256Verifier) Verify(hashedPassword, password string) (bool, error) {
+	rounds, salt, hash, err := decodePBKDF2Hash(hashedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	comparisonHash := pbkdf2.Key([]byte(password), salt, rounds, len(hash), sha256.New)
+	return subtle.ConstantTimeCompare(hash, comparisonHash) == 1, nil
+}
+
+// Describe implements HashVerifier
+func (PBKDF2SHA256Verifier) Describe(hashedPassword string) (string, error) {
+	rounds, _, _, err := decodePBKDF2Hash(hashedPassword)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256 i=%d", rounds), nil
+}
+
+// decodePBKDF2Hash parses the rounds, salt and hash of a PBKDF2-SHA256 hash
+func decodePBKDF2Hash(hashedPassword string) (int, []byte, []byte, error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 5 {
+		return 0, nil, nil, ErrInvalidHash
+	}
+
+	rounds, err := strconv.Atoi(strings.TrimPrefix(parts[2], "i="))
+	if err != nil || rounds <= 0 {
+		return 0, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := decodeAdaptedBase64(parts[3])
+	if err != nil {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	hash, err := decodeAdaptedBase64(parts[4])
+	if err != nil {
+		return 0, nil, nil, ErrInvalidHash
+	}
+
+	return rounds, salt, hash, nil
+}
+
+// decodeAdaptedBase64 decodes passlib's adapted base64, which uses "." in place of "+"
+func decodeAdaptedBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(s, ".", "+"))
+}
@@ -11,10 +11,19 @@ import (
 	"golang.org/x/crypto/argon2"
 )
 
+// Common password hashing errors
+var (
+	ErrInvalidHash         = errors.New("invalid hash format")
+	ErrIncompatibleVersion = errors.New("incompatible argon2 version")
+)
+
 // PasswordService provides methods for password hashing and verification
 type PasswordService interface {
 	HashPassword(password string) (string, error)
 	VerifyPassword(hashedPassword, password string) (bool, error)
+	// NeedsRehash reports whether a stored hash was produced with parameters
+	// weaker than the current ones and should be replaced after a successful login
+	NeedsRehash(hashedPassword string) bool
 }
 
 // ArgonParams defines the parameters used by the Argon2id algorithm
@@ -26,18 +35,78 @@ type ArgonParams struct {
 	KeyLength   uint32
 }
 
-// DefaultPasswordService is the default implementation of PasswordService
-type DefaultPasswordService struct {
+// DefaultArgonParams returns the Argon2id parameters used by the servers
+func DefaultArgonParams() ArgonParams {
+	return ArgonParams{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// weakerThan reports whether any cost or length of p is below that of q. Parallelism is not
+// compared, as fewer lanes do not make a hash cheaper to attack.
+func (p ArgonParams) weakerThan(q ArgonParams) bool {
+	return p.Memory < q.Memory || p.Iterations < q.Iterations || p.SaltLength < q.SaltLength || p.KeyLength < q.KeyLength
+}
+
+// String formats the cost parameters as they appear in an encoded hash
+func (p ArgonParams) String() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism)
+}
+
+// argonHash is a decoded $argon2id$ hash
+type argonHash struct {
 	params ArgonParams
+	keyID  string
+	salt   []byte
+	hash   []byte
 }
 
-// NewPasswordService creates a new password service with the given params
-func NewPasswordService(params ArgonParams) PasswordService {
-	return &DefaultPasswordService{
-		params: params,
+// DefaultPasswordService is the default implementation of PasswordService.
+// It hashes with Argon2id and verifies legacy hashes through a registry of verifiers
+// selected by the hash's PHC / modular crypt identifier.
+type DefaultPasswordService struct {
+	params    ArgonParams
+	pepper    Pepper
+	verifiers map[string]HashVerifier
+}
+
+// PasswordServiceOption configures optional behaviour of the default password service
+type PasswordServiceOption func(*DefaultPasswordService)
+
+// WithVerifier registers a verifier for hashes with the given identifier, such as "2b" for "$2b$..."
+func WithVerifier(id string, verifier HashVerifier) PasswordServiceOption {
+	return func(s *DefaultPasswordService) {
+		s.verifiers[id] = verifier
 	}
 }
 
+// WithPepper mixes a server-side secret into new hashes.
+// The key ID is stored in the hash as keyid=<id> so the pepper can be rotated.
+func WithPepper(pepper Pepper) PasswordServiceOption {
+	return func(s *DefaultPasswordService) {
+		s.pepper = pepper
+	}
+}
+
+// NewPasswordService creates a new password service with the given params.
+// bcrypt, scrypt and PBKDF2-SHA256 hashes are verified out of the box.
+func NewPasswordService(params ArgonParams, opts ...PasswordServiceOption) PasswordService {
+	s := &DefaultPasswordService{
+		params:    params,
+		verifiers: defaultVerifiers(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
 // HashPassword hashes a password using Argon2id
 func (s *DefaultPasswordService) HashPassword(password string) (string, error) {
 	salt := make([]byte, s.params.SaltLength)
@@ -45,58 +114,150 @@ func (s *DefaultPasswordService) HashPassword(password string) (string, error) {
 		return "", err
 	}
 
-	hash := argon2.IDKey([]byte(password), salt, s.params.Iterations, s.params.Memory, s.params.Parallelism, s.params.KeyLength)
+	input := []byte(password)
+	paramString := s.params.String()
+	if s.pepper.Enabled() {
+		peppered, err := s.pepper.apply(s.pepper.CurrentKeyID, password)
+		if err != nil {
+			return "", err
+		}
+		input = peppered
+		paramString += ",keyid=" + s.pepper.CurrentKeyID
+	}
+
+	hash := argon2.IDKey(input, salt, s.params.Iterations, s.params.Memory, s.params.Parallelism, s.params.KeyLength)
 
 	// Base64 encode the salt and hash
 	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
 
-	// Format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
-	encodedHash := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
-		argon2.Version, s.params.Memory, s.params.Iterations, s.params.Parallelism, b64Salt, b64Hash)
+	// Format: $argon2id$v=19$m=65536,t=3,p=2[,keyid=<id>]$<salt>$<hash>
+	encodedHash := fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, paramString, b64Salt, b64Hash)
 
 	return encodedHash, nil
 }
 
-// VerifyPassword verifies the password against the hash
+// VerifyPassword verifies the password against an Argon2id or registered legacy hash
 func (s *DefaultPasswordService) VerifyPassword(hashedPassword, password string) (bool, error) {
+	id := hashIdentifier(hashedPassword)
+	if id != "argon2id" {
+		verifier, ok := s.verifiers[id]
+		if !ok {
+			return false, ErrUnsupportedHash
+		}
+		return verifier.Verify(hashedPassword, password)
+	}
+
+	decoded, err := decodeArgonHash(hashedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	input := []byte(password)
+	if decoded.keyID != "" {
+		peppered, err := s.pepper.apply(decoded.keyID, password)
+		if err != nil {
+			return false, err
+		}
+		input = peppered
+	}
+
+	// Compute the hash of the provided password using the same parameters
+	p := decoded.params
+	comparisonHash := argon2.IDKey(input, decoded.salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	// Constant-time comparison to prevent timing attacks
+	return subtle.ConstantTimeCompare(decoded.hash, comparisonHash) == 1, nil
+}
+
+// NeedsRehash reports whether the hash is a legacy hash, uses parameters weaker than the
+// configured ones or was peppered with a key other than the current one. Hashes with stronger
+// parameters, e.g. written before the cost was lowered, are kept.
+func (s *DefaultPasswordService) NeedsRehash(hashedPassword string) bool {
+	decoded, err := decodeArgonHash(hashedPassword)
+	if err != nil {
+		return true
+	}
+
+	currentKeyID := ""
+	if s.pepper.Enabled() {
+		currentKeyID = s.pepper.CurrentKeyID
+	}
+
+	return decoded.params.weakerThan(s.params) || decoded.keyID != currentKeyID
+}
+
+// ParseArgonParams returns the parameters an encoded $argon2id$ hash was produced with
+func ParseArgonParams(hashedPassword string) (ArgonParams, error) {
+	decoded, err := decodeArgonHash(hashedPassword)
+	if err != nil {
+		return ArgonParams{}, err
+	}
+	return decoded.params, nil
+}
+
+// decodeArgonHash parses a hash in the $argon2id$v=19$m=65536,t=3,p=2[,keyid=<id>]$<salt>$<hash> format
+func decodeArgonHash(hashedPassword string) (*argonHash, error) {
 	parts := strings.Split(hashedPassword, "$")
-	if len(parts) != 6 {
-		return false, errors.New("invalid hash format")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrInvalidHash
 	}
 
 	var version int
 	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
 	if err != nil {
-		return false, errors.New("invalid hash format")
+		return nil, ErrInvalidHash
 	}
 
 	if version != argon2.Version {
-		return false, errors.New("incompatible argon2 version")
+		return nil, ErrIncompatibleVersion
 	}
 
-	var memory, iterations uint32
-	var parallelism uint8
-	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism)
+	var params ArgonParams
+	paramString, keyID, _ := strings.Cut(parts[3], ",keyid=")
+	_, err = fmt.Sscanf(paramString, "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
 	if err != nil {
-		return false, errors.New("invalid hash format")
+		return nil, ErrInvalidHash
 	}
 
 	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
-		return false, errors.New("invalid hash format")
+		return nil, ErrInvalidHash
 	}
 
-	decodedHash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
 	if err != nil {
-		return false, errors.New("invalid hash format")
+		return nil, ErrInvalidHash
 	}
 
-	keyLength := uint32(len(decodedHash))
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(hash))
 
-	// Compute the hash of the provided password using the same parameters
-	comparisonHash := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, keyLength)
+	return &argonHash{
+		params: params,
+		keyID:  keyID,
+		salt:   salt,
+		hash:   hash,
+	}, nil
+}
 
-	// Constant-time comparison to prevent timing attacks
-	return subtle.ConstantTimeCompare(decodedHash, comparisonHash) == 1, nil
+// DescribeHash returns a label identifying the algorithm and parameters of an encoded hash,
+// such as "argon2id m=65536,t=3,p=2" or "bcrypt cost=10". Hashes that cannot be parsed are labelled "unknown".
+func DescribeHash(hashedPassword string) string {
+	if verifier, ok := defaultVerifiers()[hashIdentifier(hashedPassword)]; ok {
+		label, err := verifier.Describe(hashedPassword)
+		if err != nil {
+			return "unknown"
+		}
+		return label
+	}
+
+	decoded, err := decodeArgonHash(hashedPassword)
+	if err != nil {
+		return "unknown"
+	}
+	if decoded.keyID != "" {
+		return "argon2id " + decoded.params.String() + " keyid=" + decoded.keyID
+	}
+	return "argon2id " + decoded.params.String()
 }
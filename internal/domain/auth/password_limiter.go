@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// ErrHashingBusy is returned when a hashing operation could not start within the queue timeout
+var ErrHashingBusy = errors.New("password hashing capacity exceeded")
+
+// LimiterConfig bounds the resources used by password hashing
+type LimiterConfig struct {
+	// MaxConcurrent is the maximum number of hashing operations running at once
+	MaxConcurrent int
+	// MemoryBudget is the total Argon2 memory in KiB that running operations may allocate
+	MemoryBudget uint64
+	// QueueTimeout is how long an operation may wait for capacity before failing with ErrHashingBusy
+	QueueTimeout time.Duration
+}
+
+// LimiterStats is a snapshot of the limiter metrics
+type LimiterStats struct {
+	InFlight      int64 `json:"in_flight"`
+	QueueDepth    int64 `json:"queue_depth"`
+	MaxQueueDepth int64 `json:"max_queue_depth"`
+	Completed     int64 `json:"completed"`
+	Rejected      int64 `json:"rejected"`
+}
+
+// LimitedPasswordService wraps a PasswordService with a bounded worker pool and a memory budget.
+// Each Argon2 operation allocates its full memory cost, so without a bound a burst of logins
+// can exhaust the process memory. Operations that cannot start in time fail with ErrHashingBusy.
+type LimitedPasswordService struct {
+	inner        PasswordService
+	params       ArgonParams
+	workers      *semaphore.Weighted
+	memory       *semaphore.Weighted
+	memoryBudget int64
+	queueTimeout time.Duration
+
+	inFlight      atomic.Int64
+	queueDepth    atomic.Int64
+	maxQueueDepth atomic.Int64
+	completed     atomic.Int64
+	rejected      atomic.Int64
+}
+
+// NewLimitedPasswordService creates a limiter around inner. params are the Argon2 parameters
+// inner hashes with and determine the memory reserved for each new hash.
+func NewLimitedPasswordService(inner PasswordService, params ArgonParams, config LimiterConfig) *LimitedPasswordService {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 1
+	}
+	if config.MemoryBudget < uint64(params.Memory) {
+		config.MemoryBudget = uint64(params.Memory)
+	}
+
+	return &LimitedPasswordService{
+		inner:        inner,
+		params:       params,
+		workers:      semaphore.NewWeighted(int64(config.MaxConcurrent)),
+		memory:       semaphore.NewWeighted(int64(config.MemoryBudget)),
+		memoryBudget: int64(config.MemoryBudget),
+		queueTimeout: config.QueueTimeout,
+	}
+}
+
+// HashPassword hashes a password once capacity is available
+func (s *LimitedPasswordService) HashPassword(password string) (string, error) {
+	release, err := s.acquire(int64(s.params.Memory))
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return s.inner.HashPassword(password)
+}
+
+// VerifyPassword verifies a password once capacity is available.
+// The memory reserved is taken from the stored hash, which may predate the current parameters.
+func (s *LimitedPasswordService) VerifyPassword(hashedPassword, password string) (bool, error) {
+	memory := int64(s.params.Memory)
+	if params, err := ParseArgonParams(hashedPassword); err == nil {
+		memory = int64(params.Memory)
+	}
+
+	release, err := s.acquire(memory)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	return s.inner.VerifyPassword(hashedPassword, password)
+}
+
+// NeedsRehash delegates to the wrapped service; it does not hash
+func (s *LimitedPasswordService) NeedsRehash(hashedPassword string) bool {
+	return s.inner.NeedsRehash(hashedPassword)
+}
+
+// Stats returns a snapshot of the limiter metrics
+func (s *LimitedPasswordService) Stats() LimiterStats {
+	return LimiterStats{
+		InFlight:      s.inFlight.Load(),
+		QueueDepth:    s.queueDepth.Load(),
+		MaxQueueDepth: s.maxQueueDepth.Load(),
+		Completed:     s.completed.Load(),
+		Rejected:      s.rejected.Load(),
+	}
+}
+
+// acquire waits for a worker slot and memory, returning a function that releases both
+func (s *LimitedPasswordService) acquire(memory int64) (func(), error) {
+	// A single operation larger than the budget still runs, but alone
+	if memory > s.memoryBudget {
+		memory = s.memoryBudget
+	}
+
+	depth := s.queueDepth.Add(1)
+	for {
+		max := s.maxQueueDepth.Load()
+		if depth <= max || s.maxQueueDepth.CompareAndSwap(max, depth) {
+			break
+		}
+	}
+	defer s.queueDepth.Add(-1)
+
+	ctx := context.Background()
+	if s.queueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.queueTimeout)
+		defer cancel()
+	}
+
+	if err := s.workers.Acquire(ctx, 1); err != nil {
+		s.rejected.Add(1)
+		return nil, ErrHashingBusy
+	}
+	if err := s.memory.Acquire(ctx, memory); err != nil {
+		s.workers.Release(1)
+		s.rejected.Add(1)
+		return nil, ErrHashingBusy
+	}
+
+	s.inFlight.Add(1)
+	return func() {
+		s.inFlight.Add(-1)
+		s.completed.Add(1)
+		s.memory.Release(memory)
+		s.workers.Release(1)
+	}, nil
+}
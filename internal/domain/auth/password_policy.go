@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PasswordPolicy defines the rules a new password has to satisfy
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// MinScore is the minimum PasswordStrength score (0-4)
+	MinScore int
+	// RejectPersonalInfo rejects passwords containing the user's name or email
+	RejectPersonalInfo bool
+	// Breached is an optional breached-password corpus
+	Breached BreachedPasswordChecker
+}
+
+// DefaultPasswordPolicy returns the policy used when none is configured
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:          8,
+		MaxLength:          128,
+		MinScore:           2,
+		RejectPersonalInfo: true,
+	}
+}
+
+// Check validates a password against the policy.
+// personalInfo holds values such as the user's name and email.
+// It returns a list of human-readable violations, which is empty when the password is acceptable.
+func (p PasswordPolicy) Check(password string, personalInfo ...string) ([]string, error) {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	// Overlong passwords are rejected before the more expensive checks run on them
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return []string{fmt.Sprintf("must be at most %d characters", p.MaxLength)}, nil
+	}
+
+	if p.RejectPersonalInfo && containsPersonalInfo(password, personalInfo) {
+		violations = append(violations, "must not contain your name or email")
+	}
+
+	if p.MinScore > 0 && PasswordStrength(password, personalInfo...) < p.MinScore {
+		violations = append(violations, "is too easy to guess")
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.IsBreached(password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			violations = append(violations, "has appeared in a data breach")
+		}
+	}
+
+	return violations, nil
+}
+
+// containsPersonalInfo returns true if the password contains any token of the personal info
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	lower := strings.ToLower(password)
+	for _, info := range personalInfo {
+		for _, token := range personalTokens(info) {
+			if strings.Contains(lower, token) {
+				return true
+			}
+		}
+	}
+	return false
+}
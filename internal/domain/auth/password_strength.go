@@ -0,0 +1,205 @@
+package auth
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+// commonPasswords maps a common password to its popularity rank
+var commonPasswords = func() map[string]int {
+	ranks := make(map[string]int)
+	for i, word := range strings.Fields(commonPasswordsList) {
+		ranks[word] = i + 1
+	}
+	return ranks
+}()
+
+// keyboardRows are the rows used to detect keyboard walks such as "qwerty" or "asdf"
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// maxSegmentLength bounds the patterns tried at each position, keeping the estimate linear in
+// the password length. It exceeds the longest dictionary word; longer user inputs extend it.
+const maxSegmentLength = 32
+
+// leetSubstitutions maps common character substitutions back to letters
+var leetSubstitutions = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t")
+
+// PasswordStrength estimates the strength of a password on a 0-4 scale in the style of zxcvbn.
+// The password is split into the cheapest sequence of patterns (dictionary words, user inputs,
+// repeats, sequences, keyboard walks and years) and scored by the log10 of the resulting guesses.
+// userInputs are values such as the user's name or email that an attacker would try first.
+func PasswordStrength(password string, userInputs ...string) int {
+	guesses := estimateGuesses(password, userInputs)
+
+	switch {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// estimateGuesses finds the minimum number of guesses over all segmentations of the password
+func estimateGuesses(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+
+	inputs := make(map[string]bool)
+	maxSegment := maxSegmentLength
+	for _, input := range userInputs {
+		for _, token := range personalTokens(input) {
+			inputs[token] = true
+			if l := utf8.RuneCountInString(token); l > maxSegment {
+				maxSegment = l
+			}
+		}
+	}
+
+	// best[j] holds the cheapest guess count for the first j runes
+	best := make([]float64, n+1)
+	best[0] = 1
+	for j := 1; j <= n; j++ {
+		best[j] = math.Inf(1)
+		for i := max(0, j-maxSegment); i < j; i++ {
+			if g := best[i] * segmentGuesses(runes[i:j], inputs); g < best[j] {
+				best[j] = g
+			}
+		}
+	}
+
+	return best[n]
+}
+
+// segmentGuesses returns the guesses needed for a single segment using the cheapest matching pattern
+func segmentGuesses(segment []rune, inputs map[string]bool) float64 {
+	guesses := bruteforceGuesses(segment)
+	if len(segment) < 3 {
+		return guesses
+	}
+
+	lower := strings.ToLower(string(segment))
+	variations := 1.0
+	if lower != string(segment) {
+		variations = 2
+	}
+
+	if inputs[lower] {
+		guesses = math.Min(guesses, variations)
+	}
+	if rank, ok := commonPasswords[lower]; ok {
+		guesses = math.Min(guesses, float64(rank)*variations)
+	}
+	if rank, ok := commonPasswords[leetSubstitutions.Replace(lower)]; ok {
+		guesses = math.Min(guesses, float64(rank)*variations*4)
+	}
+	if isRepeat(segment) {
+		guesses = math.Min(guesses, 10*float64(len(segment)))
+	}
+	if isSequence(segment) || isKeyboardWalk(lower) {
+		guesses = math.Min(guesses, 26*float64(len(segment)))
+	}
+	if len(segment) == 4 && isYear(lower) {
+		guesses = math.Min(guesses, 120)
+	}
+
+	return guesses
+}
+
+// bruteforceGuesses returns the guesses for a segment that matches no known pattern
+func bruteforceGuesses(segment []rune) float64 {
+	guesses := 1.0
+	for _, r := range segment {
+		switch {
+		case unicode.IsDigit(r):
+			guesses *= 10
+		case unicode.IsLower(r), unicode.IsUpper(r):
+			guesses *= 26
+		default:
+			guesses *= 33
+		}
+	}
+	return guesses
+}
+
+// isRepeat returns true if the segment is a single repeated character
+func isRepeat(segment []rune) bool {
+	for _, r := range segment[1:] {
+		if r != segment[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// isSequence returns true if consecutive characters differ by a constant step of 1, such as "abc" or "321"
+func isSequence(segment []rune) bool {
+	step := segment[1] - segment[0]
+	if step != 1 && step != -1 {
+		return false
+	}
+	for i := 2; i < len(segment); i++ {
+		if segment[i]-segment[i-1] != step {
+			return false
+		}
+	}
+	return true
+}
+
+// isKeyboardWalk returns true if the segment is a straight run along a keyboard row
+func isKeyboardWalk(lower string) bool {
+	for _, row := range keyboardRows {
+		if strings.Contains(row, lower) || strings.Contains(reverse(row), lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// isYear returns true for four-digit years between 1900 and 2099
+func isYear(s string) bool {
+	return (strings.HasPrefix(s, "19") || strings.HasPrefix(s, "20")) && strings.Trim(s, "0123456789") == ""
+}
+
+// personalTokens splits a name or the local part of an email into lowercase tokens
+// of three or more characters
+func personalTokens(value string) []string {
+	if local, _, isEmail := strings.Cut(value, "@"); isEmail {
+		value = local
+	}
+
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var tokens []string
+	for _, f := range fields {
+		if len([]rune(f)) >= 3 {
+			tokens = append(tokens, f)
+		}
+	}
+	return tokens
+}
+
+// reverse reverses a string
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownPepperKey is returned when a hash references a pepper key that is not configured
+var ErrUnknownPepperKey = errors.New("unknown pepper key")
+
+// Pepper holds server-side secrets that are mixed into password hashes.
+// The secrets live outside the database, so a leaked user table alone cannot be cracked offline.
+// Each hash records the ID of the key it was made with; old keys keep verifying until
+// the hashes are upgraded to the current key on login.
+type Pepper struct {
+	CurrentKeyID string
+	Keys         map[string][]byte
+}
+
+// Enabled returns true if a current pepper key is configured
+func (p Pepper) Enabled() bool {
+	return p.CurrentKeyID != "" && len(p.Keys[p.CurrentKeyID]) > 0
+}
+
+// apply returns HMAC-SHA256(key, password) encoded for use as the Argon2 input
+func (p Pepper) apply(keyID, password string) ([]byte, error) {
+	key, ok := p.Keys[keyID]
+	if !ok {
+		return nil, ErrUnknownPepperKey
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return mac.Sum(nil), nil
+}
+
+// ParsePepperKeys parses a comma separated list of "<id>=<base64 secret>" pairs
+func ParsePepperKeys(spec string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(pair, "=")
+		if !ok || !validPepperKeyID(id) {
+			return nil, fmt.Errorf("invalid pepper key %q", id)
+		}
+
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(secret) < 16 {
+			return nil, fmt.Errorf("pepper key %q must be at least 16 base64 encoded bytes", id)
+		}
+		keys[id] = secret
+	}
+	return keys, nil
+}
+
+// validPepperKeyID restricts key IDs to characters that are safe inside an encoded hash
+func validPepperKeyID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
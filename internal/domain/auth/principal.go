@@ -0,0 +1,100 @@
+package auth
+
+// PrincipalType identifies what kind of caller a principal represents
+type PrincipalType string
+
+// Principal types
+const (
+	PrincipalUser    PrincipalType = "user"
+	PrincipalService PrincipalType = "service"
+)
+
+// Authentication methods recorded on a principal
+const (
+	AuthMethodJWT     = "jwt"
+	AuthMethodAPIKey  = "api_key"
+	AuthMethodSession = "session"
+	AuthMethodOAuth   = "oauth"
+)
+
+// Principal is the authenticated caller of a request, independent of how it authenticated
+type Principal struct {
+	Type PrincipalType
+	// Subject is the user ID for users and the service name for services
+	Subject string
+	// TenantID is the tenant the principal belongs to
+	TenantID   string
+	Email      string
+	Roles      []string
+	AuthMethod string
+	// Scopes restricts what the principal may do. Nil means unrestricted, as for interactive logins;
+	// API keys carry exactly the scopes they were created with.
+	Scopes []string
+	// APIKeyID is set when the principal authenticated with an API key
+	APIKeyID string
+	// SessionID and CSRFToken are set when the principal authenticated with a session cookie
+	SessionID string
+	CSRFToken string
+	// Claims holds the user claims for user principals
+	Claims *Claims
+}
+
+// NewUserPrincipal creates an unrestricted principal from validated JWT claims
+func NewUserPrincipal(claims *Claims) *Principal {
+	return &Principal{
+		Type:       PrincipalUser,
+		Subject:    claims.Subject,
+		TenantID:   claims.Tenant(),
+		Email:      claims.Email,
+		Roles:      claims.Roles,
+		AuthMethod: AuthMethodJWT,
+		Claims:     claims,
+	}
+}
+
+// NewPrincipalFromClaims creates a principal from validated JWT claims. Login tokens give an
+// unrestricted user principal. OAuth access tokens are limited to their granted scopes, and
+// client credentials tokens, whose subject is the client itself, act as a service.
+func NewPrincipalFromClaims(claims *Claims) *Principal {
+	if claims.ClientID == "" {
+		return NewUserPrincipal(claims)
+	}
+
+	if claims.Subject == claims.ClientID {
+		return &Principal{
+			Type:       PrincipalService,
+			Subject:    claims.ClientID,
+			TenantID:   claims.Tenant(),
+			AuthMethod: AuthMethodOAuth,
+			Scopes:     append([]string{}, claims.Scopes()...),
+		}
+	}
+
+	principal := NewUserPrincipal(claims)
+	principal.AuthMethod = AuthMethodOAuth
+	principal.Scopes = append([]string{}, claims.Scopes()...)
+	return principal
+}
+
+// HasScope reports whether the principal may act within scope
+func (p *Principal) HasScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the principal has a role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,11 @@
+package authz
+
+// Explanation records how a check was evaluated, as a tree of the relations, permissions and
+// tuples that were followed. Evaluation stops at the first branch that grants access, so a
+// denied check lists every path that was tried.
+type Explanation struct {
+	// Expression is what this node evaluated, e.g. document:readme#edit or a tuple
+	Expression string
+	Allowed    bool
+	Children   []*Explanation
+}
@@ -0,0 +1,179 @@
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Policy defines the object types with their relations and permissions, and the routes whose
+// requests are checked against them. A policy file is JSON, for example:
+//
+//	{
+//	  "types": {
+//	    "team": {"relations": ["member"]},
+//	    "document": {
+//	      "relations": ["owner_team", "editor", "viewer"],
+//	      "permissions": {
+//	        "edit": ["editor", "owner_team->member"],
+//	        "view": ["viewer", "edit"]
+//	      }
+//	    }
+//	  },
+//	  "routes": [
+//	    {"method": "PUT", "path": "/documents/{id}", "resource": "document:{id}", "action": "edit"}
+//	  ]
+//	}
+type Policy struct {
+	Types  map[string]TypeDefinition `json:"types"`
+	Routes []Route                   `json:"routes"`
+}
+
+// TypeDefinition holds the relations and permissions of an object type. Relations are granted
+// directly by tuples. A permission is the union of its expressions: another relation or
+// permission of the object, or tupleset->relation, which follows the objects related through
+// tupleset and checks relation on each of them.
+type TypeDefinition struct {
+	Relations   []string            `json:"relations"`
+	Permissions map[string][]string `json:"permissions"`
+}
+
+// HasRelation reports whether name is a relation of the type
+func (d TypeDefinition) HasRelation(name string) bool {
+	for _, relation := range d.Relations {
+		if relation == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Defines reports whether name is a relation or permission of the type
+func (d TypeDefinition) Defines(name string) bool {
+	_, isPermission := d.Permissions[name]
+	return isPermission || d.HasRelation(name)
+}
+
+// Route maps requests to the action they perform on a resource. Path segments written {name}
+// match any segment and can be used in the resource, e.g. document:{id}. An empty method
+// matches every method.
+type Route struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+// LoadPolicy reads and validates a JSON policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses and validates a JSON policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid authorization policy: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks that every name is well formed, that permissions only refer to names of
+// their type and that routes name known resources and actions. The relation after an arrow
+// is resolved on whatever type the tupleset points to, so it is checked at evaluation time.
+func (p *Policy) Validate() error {
+	for _, typ := range sortedKeys(p.Types) {
+		def := p.Types[typ]
+		if !namePattern.MatchString(typ) {
+			return fmt.Errorf("invalid authorization policy: invalid type name %q", typ)
+		}
+		for _, relation := range def.Relations {
+			if !namePattern.MatchString(relation) {
+				return fmt.Errorf("invalid authorization policy: invalid relation %s#%s", typ, relation)
+			}
+		}
+		for _, permission := range sortedKeys(def.Permissions) {
+			if !namePattern.MatchString(permission) || def.HasRelation(permission) {
+				return fmt.Errorf("invalid authorization policy: invalid permission %s#%s", typ, permission)
+			}
+			for _, expr := range def.Permissions[permission] {
+				tupleset, relation, isArrow := strings.Cut(expr, "->")
+				valid := def.Defines(expr)
+				if isArrow {
+					valid = def.HasRelation(tupleset) && namePattern.MatchString(relation)
+				}
+				if !valid {
+					return fmt.Errorf("invalid authorization policy: %s#%s refers to unknown %q", typ, permission, expr)
+				}
+			}
+		}
+	}
+
+	for _, route := range p.Routes {
+		if err := p.validateRoute(route); err != nil {
+			return fmt.Errorf("invalid authorization policy: route %s %s: %w", route.Method, route.Path, err)
+		}
+	}
+	return nil
+}
+
+// validateRoute checks that a route's resource has a known type and action and only uses
+// parameters of its path
+func (p *Policy) validateRoute(route Route) error {
+	if !strings.HasPrefix(route.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	typ, id, ok := strings.Cut(route.Resource, ":")
+	if !ok || id == "" {
+		return fmt.Errorf("resource %q must be type:id", route.Resource)
+	}
+	def, ok := p.Types[typ]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownType, typ)
+	}
+	if !def.Defines(route.Action) {
+		return fmt.Errorf("%w %s#%s", ErrUnknownRelation, typ, route.Action)
+	}
+
+	for _, param := range placeholders(id) {
+		if !strings.Contains(route.Path, "{"+param+"}") {
+			return fmt.Errorf("resource uses {%s}, which is not in the path", param)
+		}
+	}
+	return nil
+}
+
+// placeholders returns the {name} placeholders of a template
+func placeholders(template string) []string {
+	var names []string
+	for {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			return names
+		}
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			return names
+		}
+		names = append(names, template[start+1:start+end])
+		template = template[start+end+1:]
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
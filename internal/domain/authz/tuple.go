@@ -0,0 +1,155 @@
+// Package authz defines relationship-based access control in the style of Zanzibar. Relation
+// tuples record how subjects relate to objects, and a policy derives permissions from them, so
+// rules like "members of the owning team can edit a document" need no code.
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+)
+
+// Tuple and policy errors
+var (
+	ErrInvalidTuple    = errors.New("invalid relation tuple")
+	ErrUnknownType     = errors.New("unknown object type")
+	ErrUnknownRelation = errors.New("unknown relation")
+)
+
+// Wildcard as a subject ID matches every subject of the type, e.g. user:*
+const Wildcard = "*"
+
+var (
+	// namePattern restricts object types and relations
+	namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	// idPattern restricts object IDs to characters that cannot be confused with the separators
+	idPattern = regexp.MustCompile(`^[A-Za-z0-9_.=+/|-]{1,128}$`)
+)
+
+// Object is a resource, or a user or service acting as a subject, written type:id
+type Object struct {
+	Type string
+	ID   string
+}
+
+// String returns the type:id form of the object
+func (o Object) String() string {
+	return o.Type + ":" + o.ID
+}
+
+// ParseObject parses the type:id form of an object
+func ParseObject(s string) (Object, error) {
+	typ, id, ok := strings.Cut(s, ":")
+	if !ok || !namePattern.MatchString(typ) || !idPattern.MatchString(id) {
+		return Object{}, fmt.Errorf("%w: object %q must be type:id", ErrInvalidTuple, s)
+	}
+	return Object{Type: typ, ID: id}, nil
+}
+
+// Subject is who a tuple grants a relation to: an object such as user:42, every object of a
+// type with user:*, or a userset such as team:7#member, meaning every subject with the member
+// relation on team:7
+type Subject struct {
+	Object
+	Relation string
+}
+
+// String returns the type:id or type:id#relation form of the subject
+func (s Subject) String() string {
+	if s.Relation == "" {
+		return s.Object.String()
+	}
+	return s.Object.String() + "#" + s.Relation
+}
+
+// ParseSubject parses the type:id, type:* or type:id#relation form of a subject
+func ParseSubject(s string) (Subject, error) {
+	objectPart, relation, hasRelation := strings.Cut(s, "#")
+	if hasRelation && !namePattern.MatchString(relation) {
+		return Subject{}, fmt.Errorf("%w: subject %q has an invalid relation", ErrInvalidTuple, s)
+	}
+
+	if typ, id, ok := strings.Cut(objectPart, ":"); ok && id == Wildcard && !hasRelation && namePattern.MatchString(typ) {
+		return Subject{Object: Object{Type: typ, ID: Wildcard}}, nil
+	}
+	object, err := ParseObject(objectPart)
+	if err != nil {
+		return Subject{}, fmt.Errorf("%w: subject %q must be type:id, type:* or type:id#relation", ErrInvalidTuple, s)
+	}
+	return Subject{Object: object, Relation: relation}, nil
+}
+
+// PrincipalSubject returns the subject an authenticated principal checks as: user:<id> for users
+// and service:<name> for services
+func PrincipalSubject(p *auth.Principal) Subject {
+	if p.Type == auth.PrincipalService {
+		return Subject{Object: Object{Type: "service", ID: p.Subject}}
+	}
+	return Subject{Object: Object{Type: "user", ID: p.Subject}}
+}
+
+// Tuple records that a subject has a relation to an object within a tenant
+type Tuple struct {
+	TenantID string
+	Object   Object
+	Relation string
+	Subject  Subject
+}
+
+// String returns the object#relation@subject form of the tuple
+func (t Tuple) String() string {
+	return t.Object.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+// ParseTuple parses the object#relation@subject form of a tuple, e.g.
+// document:readme#owner_team@team:platform
+func ParseTuple(s string) (Tuple, error) {
+	objectRelation, subjectPart, ok := strings.Cut(s, "@")
+	if !ok {
+		return Tuple{}, fmt.Errorf("%w: %q must be object#relation@subject", ErrInvalidTuple, s)
+	}
+	objectPart, relation, ok := strings.Cut(objectRelation, "#")
+	if !ok || !namePattern.MatchString(relation) {
+		return Tuple{}, fmt.Errorf("%w: %q must be object#relation@subject", ErrInvalidTuple, s)
+	}
+
+	object, err := ParseObject(objectPart)
+	if err != nil {
+		return Tuple{}, err
+	}
+	subject, err := ParseSubject(subjectPart)
+	if err != nil {
+		return Tuple{}, err
+	}
+	return Tuple{Object: object, Relation: relation, Subject: subject}, nil
+}
+
+// TupleFilter selects tuples; empty fields match every tuple
+type TupleFilter struct {
+	ObjectType string
+	ObjectID   string
+	Relation   string
+	// Subject, when set, matches the exact subject
+	Subject *Subject
+}
+
+// Matches reports whether a tuple is selected by the filter
+func (f TupleFilter) Matches(t Tuple) bool {
+	return (f.ObjectType == "" || f.ObjectType == t.Object.Type) &&
+		(f.ObjectID == "" || f.ObjectID == t.Object.ID) &&
+		(f.Relation == "" || f.Relation == t.Relation) &&
+		(f.Subject == nil || *f.Subject == t.Subject)
+}
+
+// TupleRepository defines the interface for relation tuple storage
+type TupleRepository interface {
+	// Write stores tuples; tuples that already exist are left unchanged
+	Write(tuples []Tuple) error
+	// Delete removes tuples; tuples that do not exist are ignored
+	Delete(tuples []Tuple) error
+	// Read returns the tuples of a tenant selected by filter, ordered by object, relation and subject
+	Read(tenantID string, filter TupleFilter) ([]Tuple, error)
+}
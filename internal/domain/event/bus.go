@@ -0,0 +1,54 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// AllTypes subscribes a handler to every event type
+const AllTypes = "*"
+
+// Handler handles an event. Handlers may see an event more than once, see Idempotent.
+type Handler func(e Event) error
+
+// Bus dispatches events to the handlers subscribed in the process
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]subscription
+}
+
+type subscription struct {
+	name    string
+	handler Handler
+}
+
+// NewBus creates an event bus without subscribers
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]subscription)}
+}
+
+// Subscribe registers a handler, named for logs and idempotency, for an event type or AllTypes
+func (b *Bus) Subscribe(eventType, name string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[eventType] = append(b.handlers[eventType], subscription{name: name, handler: handler})
+}
+
+// Dispatch calls every handler subscribed to the event, in order of subscription. A failing
+// handler does not stop the others; their errors are returned together, and the caller
+// dispatches the event again later.
+func (b *Bus) Dispatch(e Event) error {
+	b.mu.RLock()
+	subs := append(append([]subscription(nil), b.handlers[e.Type]...), b.handlers[AllTypes]...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subs {
+		if err := sub.handler(e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,62 @@
+// Package event defines domain events. Aggregates record the events their operations raise,
+// and repositories write them to an outbox in the same change as the aggregate, so an event is
+// stored if and only if its change is. A relay later dispatches outbox events to subscribers
+// on an in-process bus, at least once.
+package event
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Event is something that happened to an aggregate of a tenant
+type Event struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	TenantID string `json:"tenant_id"`
+	// AggregateID identifies the aggregate that raised the event, e.g. a user ID
+	AggregateID string            `json:"aggregate_id"`
+	OccurredAt  time.Time         `json:"occurred_at"`
+	Data        map[string]string `json:"data,omitempty"`
+}
+
+// New creates an event that happens now
+func New(eventType, tenantID, aggregateID string, data map[string]string) Event {
+	return Event{
+		ID:          NewID(),
+		Type:        eventType,
+		TenantID:    tenantID,
+		AggregateID: aggregateID,
+		OccurredAt:  time.Now(),
+		Data:        data,
+	}
+}
+
+// Recorder collects the events an aggregate raises until its repository stores them.
+// Aggregates embed it.
+type Recorder struct {
+	events []Event
+}
+
+// Record adds an event raised by the aggregate
+func (r *Recorder) Record(e Event) {
+	r.events = append(r.events, e)
+}
+
+// PullEvents returns the recorded events and forgets them. Repositories call it when they
+// write the aggregate, so each event reaches the outbox once.
+func (r *Recorder) PullEvents() []Event {
+	events := r.events
+	r.events = nil
+	return events
+}
+
+// NewID generates a random event ID
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("event: failed to generate ID: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,26 @@
+package event
+
+// ProcessedStore remembers which events each consumer has handled
+type ProcessedStore interface {
+	// Processed reports whether a consumer has handled an event
+	Processed(consumer, eventID string) (bool, error)
+	// MarkProcessed records that a consumer has handled an event
+	MarkProcessed(consumer, eventID string) error
+}
+
+// Idempotent wraps a handler so each event is handled by the named consumer at most once,
+// however often it is dispatched. An event is only marked handled when the handler succeeds,
+// so a failure is retried with the next dispatch. Handlers of one consumer must not run
+// concurrently for the same event.
+func Idempotent(store ProcessedStore, consumer string, handler Handler) Handler {
+	return func(e Event) error {
+		processed, err := store.Processed(consumer, e.ID)
+		if err != nil || processed {
+			return err
+		}
+		if err := handler(e); err != nil {
+			return err
+		}
+		return store.MarkProcessed(consumer, e.ID)
+	}
+}
@@ -0,0 +1,23 @@
+package event
+
+import "time"
+
+// OutboxEntry is an event waiting in an outbox to be dispatched
+type OutboxEntry struct {
+	Event Event `json:"event"`
+	// Attempts counts the failed dispatches so far
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// Outbox is the store of events written together with the changes that raised them. It is
+// implemented by the repositories of aggregates that record events.
+type Outbox interface {
+	// Pending returns up to limit entries due for dispatch at now, oldest first
+	Pending(now time.Time, limit int) ([]OutboxEntry, error)
+	// MarkDispatched removes an entry once every subscriber has handled its event
+	MarkDispatched(eventID string) error
+	// MarkFailed records a failed dispatch and when to try again
+	MarkFailed(eventID, reason string, retryAt time.Time) error
+}
@@ -0,0 +1,48 @@
+// Package group defines groups of users, such as the groups a directory provisions over SCIM
+package group
+
+import (
+	"errors"
+	"time"
+)
+
+// Repository errors
+var (
+	ErrNotFound      = errors.New("group not found")
+	ErrDuplicateName = errors.New("a group with this name already exists")
+)
+
+// Group is a named set of users of one tenant
+type Group struct {
+	ID          string
+	TenantID    string
+	DisplayName string
+	// ExternalID is the identifier a provisioning client knows the group by
+	ExternalID string
+	// MemberIDs are the IDs of the member users
+	MemberIDs []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// HasMember reports whether a user is a member of the group
+func (g *Group) HasMember(userID string) bool {
+	for _, id := range g.MemberIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Repository defines the interface for group storage. Display names are unique within a
+// tenant, ignoring case.
+type Repository interface {
+	Save(g *Group) error
+	Update(g *Group) error
+	Delete(id string) error
+	FindByID(tenantID, id string) (*Group, error)
+	// FindAll returns every group of a tenant ordered by display name
+	FindAll(tenantID string) ([]*Group, error)
+	FindByMember(userID string) ([]*Group, error)
+}
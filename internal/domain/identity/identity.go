@@ -0,0 +1,90 @@
+// Package identity defines sign-in through external identity providers and the accounts linked to local users
+package identity
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Repository errors
+var (
+	ErrNotFound      = errors.New("linked identity not found")
+	ErrAlreadyLinked = errors.New("external account is already linked")
+)
+
+// LinkedIdentity links an account at an external provider to a local user of a tenant
+type LinkedIdentity struct {
+	ID       string
+	TenantID string
+	UserID   string
+	Provider string
+	// Subject is the provider's stable identifier for the account
+	Subject string
+	// Email is the address the provider asserted when the account was last used
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+// Repository defines the interface for linked identity storage.
+// Each provider account can be linked to at most one user per tenant.
+type Repository interface {
+	// Save stores a new link, returning ErrAlreadyLinked if the provider account is linked already
+	Save(link *LinkedIdentity) error
+	Update(link *LinkedIdentity) error
+	FindBySubject(tenantID, provider, subject string) (*LinkedIdentity, error)
+	FindByUser(userID string) ([]*LinkedIdentity, error)
+	// Delete removes a user's link to a provider
+	Delete(userID, provider string) error
+}
+
+// LoginState holds what is needed to complete an external login between the redirect to the
+// provider and its callback
+type LoginState struct {
+	// ID is a hash of the state parameter sent to the provider
+	ID           string
+	TenantID     string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// StateRepository defines the interface for pending login state storage
+type StateRepository interface {
+	Save(state *LoginState) error
+	// Take removes and returns a state, so each one can complete a single login
+	Take(id string) (*LoginState, error)
+	DeleteExpired(now time.Time) error
+}
+
+// Profile is what a provider asserts about the account that signed in
+type Profile struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+}
+
+// Provider is an upstream OpenID Connect, OAuth 2.0 or SAML identity provider
+type Provider interface {
+	// Name identifies the provider in URLs and linked identities
+	Name() string
+	// AuthCodeURL returns the provider URL to send the browser to
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems an authorization code and returns the verified profile. For OpenID Connect
+	// providers the ID token must carry the given nonce.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Profile, error)
+}
+
+// PostBindingProvider is a Provider, such as a SAML identity provider, that is set up by
+// importing this service's metadata and that returns its response in a cross-site form POST
+// instead of a redirect. For these providers Exchange receives the posted response as the code.
+type PostBindingProvider interface {
+	Provider
+	// Metadata returns the document describing this service to the provider
+	Metadata() ([]byte, error)
+}
@@ -0,0 +1,14 @@
+// Package mail defines the outbound email port used by the use cases
+package mail
+
+// Message represents an outbound email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer defines the interface for sending emails
+type Mailer interface {
+	Send(msg Message) error
+}
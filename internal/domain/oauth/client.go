@@ -0,0 +1,82 @@
+// Package oauth defines the clients, grants and tokens of the OAuth 2.1 authorization server
+package oauth
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by repositories when a record does not exist
+var ErrNotFound = errors.New("oauth record not found")
+
+// ClientType distinguishes clients that can keep a secret from those that cannot
+type ClientType string
+
+// Client types, see RFC 6749 section 2.1
+const (
+	ClientConfidential ClientType = "confidential"
+	ClientPublic       ClientType = "public"
+)
+
+// Grant types supported by the token endpoint
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// Client is a registered OAuth client. Only a hash of a confidential client's secret is stored.
+type Client struct {
+	ID string
+	// TenantID is the tenant the client was registered in; it can only sign in that tenant's users
+	TenantID     string
+	SecretHash   string
+	Name         string
+	Type         ClientType
+	RedirectURIs []string
+	// PostLogoutRedirectURIs are where relying parties may send users after RP-initiated logout
+	PostLogoutRedirectURIs []string
+	GrantTypes             []string
+	Scopes                 []string
+	CreatedAt              time.Time
+}
+
+// AllowsGrant reports whether the client is registered for a grant type
+func (c *Client) AllowsGrant(grantType string) bool {
+	return contains(c.GrantTypes, grantType)
+}
+
+// AllowsRedirectURI reports whether uri exactly matches a registered redirect URI
+func (c *Client) AllowsRedirectURI(uri string) bool {
+	return contains(c.RedirectURIs, uri)
+}
+
+// AllowsPostLogoutRedirectURI reports whether uri exactly matches a registered post-logout redirect URI
+func (c *Client) AllowsPostLogoutRedirectURI(uri string) bool {
+	return contains(c.PostLogoutRedirectURIs, uri)
+}
+
+// AllowsScope reports whether the client may request a scope
+func (c *Client) AllowsScope(scope string) bool {
+	return contains(c.Scopes, scope)
+}
+
+// ClientRepository defines the interface for client storage
+type ClientRepository interface {
+	Save(client *Client) error
+	FindByID(id string) (*Client, error)
+	FindAll() ([]*Client, error)
+}
+
+// contains reports whether values contains value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeClientAdmin allows registering and listing OAuth clients
+const ScopeClientAdmin = "oauth:clients"
@@ -0,0 +1,105 @@
+package oauth
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCodeUsed is returned when an authorization code that was already redeemed is marked used
+var ErrCodeUsed = errors.New("authorization code already used")
+
+// AuthorizationCode is a short-lived, single-use code issued by the authorization endpoint.
+// Only a hash of the code is stored.
+type AuthorizationCode struct {
+	CodeHash    string
+	ClientID    string
+	UserID      string
+	RedirectURI string
+	// RedirectURIRequested records that the authorization request named RedirectURI, which
+	// the token request then has to repeat
+	RedirectURIRequested bool
+	Scopes               []string
+	CodeChallenge        string
+	CodeChallengeMethod  string
+	// Nonce is echoed into ID tokens for OpenID Connect requests
+	Nonce     string
+	ExpiresAt time.Time
+	// UsedAt is set on redemption; a second redemption revokes the tokens issued for the code
+	UsedAt *time.Time
+	// FamilyID identifies the refresh tokens issued from this code
+	FamilyID  string
+	CreatedAt time.Time
+}
+
+// AuthorizationCodeRepository defines the interface for authorization code storage
+type AuthorizationCodeRepository interface {
+	Save(code *AuthorizationCode) error
+	// MarkUsed sets UsedAt on a code that has not been redeemed yet, or fails with ErrCodeUsed.
+	// The check and the write are atomic, so only one of two concurrent redemptions succeeds.
+	MarkUsed(codeHash string, at time.Time) error
+	FindByHash(codeHash string) (*AuthorizationCode, error)
+}
+
+// RefreshToken is a rotating refresh token. Every rotation issues a new token in the same
+// family; presenting a rotated token again revokes the whole family.
+type RefreshToken struct {
+	TokenHash string
+	FamilyID  string
+	ClientID  string
+	UserID    string
+	Scopes    []string
+	ExpiresAt time.Time
+	// RotatedAt is set once the token has been exchanged for a successor
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// Active returns true if the token can still be exchanged
+func (t *RefreshToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && t.RotatedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// RefreshTokenRepository defines the interface for refresh token storage
+type RefreshTokenRepository interface {
+	Save(token *RefreshToken) error
+	Update(token *RefreshToken) error
+	FindByHash(tokenHash string) (*RefreshToken, error)
+	// RevokeFamily revokes every token of a rotation family
+	RevokeFamily(familyID string, at time.Time) error
+	// RevokeGrant revokes every token a user granted to a client
+	RevokeGrant(userID, clientID string, at time.Time) error
+}
+
+// Consent records the scopes a user has approved for a client
+type Consent struct {
+	UserID    string
+	ClientID  string
+	Scopes    []string
+	GrantedAt time.Time
+}
+
+// Covers reports whether the consent includes every scope in scopes
+func (c *Consent) Covers(scopes []string) bool {
+	for _, scope := range scopes {
+		if !contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// ConsentRepository defines the interface for consent storage. Save replaces any
+// existing consent of the user for the client.
+type ConsentRepository interface {
+	Save(consent *Consent) error
+	Find(userID, clientID string) (*Consent, error)
+	FindByUser(userID string) ([]*Consent, error)
+	Delete(userID, clientID string) error
+}
+
+// RevocationRepository records revoked access token IDs until the tokens expire
+type RevocationRepository interface {
+	Revoke(tokenID string, expiresAt time.Time) error
+	IsRevoked(tokenID string) (bool, error)
+}
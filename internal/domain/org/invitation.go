@@ -0,0 +1,32 @@
+package org
+
+import "time"
+
+// Invitation invites an email address to join an organization with a role. The invitee
+// receives a signed link that works until the invitation expires, is accepted or is revoked.
+type Invitation struct {
+	ID    string
+	OrgID string
+	Email string
+	Role  Role
+	// InvitedBy is the ID of the user who sent the invitation
+	InvitedBy  string
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// Pending returns true if the invitation can still be accepted
+func (i *Invitation) Pending(now time.Time) bool {
+	return i.AcceptedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// InvitationRepository defines the interface for invitation storage
+type InvitationRepository interface {
+	Save(inv *Invitation) error
+	Update(inv *Invitation) error
+	Delete(id string) error
+	FindByID(id string) (*Invitation, error)
+	// FindByOrg returns the invitations of an organization, most recent first
+	FindByOrg(orgID string) ([]*Invitation, error)
+}
@@ -0,0 +1,81 @@
+// Package org defines organizations of users within a tenant, their teams and the invitations
+// that bring new members in
+package org
+
+import (
+	"errors"
+	"time"
+)
+
+// Repository errors
+var (
+	ErrNotFound           = errors.New("organization not found")
+	ErrMemberNotFound     = errors.New("membership not found")
+	ErrTeamNotFound       = errors.New("team not found")
+	ErrDuplicateTeamName  = errors.New("a team with this name already exists")
+	ErrInvitationNotFound = errors.New("invitation not found")
+)
+
+// Role is the role of a member in an organization
+type Role string
+
+// Organization roles, from most to least privileged. Owners manage the organization and its
+// owners, admins manage members, teams and invitations, and members can see the organization.
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+)
+
+// rank orders the roles so they can be compared
+var rank = map[Role]int{
+	RoleMember: 1,
+	RoleAdmin:  2,
+	RoleOwner:  3,
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return rank[r] > 0
+}
+
+// AtLeast reports whether r grants everything other grants
+func (r Role) AtLeast(other Role) bool {
+	return rank[r] >= rank[other]
+}
+
+// Organization is a named group of users of one tenant
+type Organization struct {
+	ID        string
+	TenantID  string
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Repository defines the interface for organization storage
+type Repository interface {
+	Save(o *Organization) error
+	FindByID(tenantID, id string) (*Organization, error)
+}
+
+// Membership records that a user belongs to an organization with a role
+type Membership struct {
+	OrgID     string
+	UserID    string
+	Role      Role
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// MembershipRepository defines the interface for membership storage
+type MembershipRepository interface {
+	// Save stores a membership, replacing any previous membership of the user in the organization
+	Save(m *Membership) error
+	Delete(orgID, userID string) error
+	Find(orgID, userID string) (*Membership, error)
+	// FindByOrg returns the members of an organization, oldest first
+	FindByOrg(orgID string) ([]*Membership, error)
+	// FindByUser returns the memberships of a user, oldest first
+	FindByUser(userID string) ([]*Membership, error)
+}
@@ -0,0 +1,35 @@
+package org
+
+import "time"
+
+// Team is a named subset of the members of an organization
+type Team struct {
+	ID    string
+	OrgID string
+	Name  string
+	// MemberIDs are the IDs of the member users, who are all members of the organization
+	MemberIDs []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// HasMember reports whether a user is a member of the team
+func (t *Team) HasMember(userID string) bool {
+	for _, id := range t.MemberIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// TeamRepository defines the interface for team storage. Team names are unique within an
+// organization, ignoring case.
+type TeamRepository interface {
+	Save(t *Team) error
+	Update(t *Team) error
+	Delete(id string) error
+	FindByID(orgID, id string) (*Team, error)
+	// FindByOrg returns the teams of an organization ordered by name
+	FindByOrg(orgID string) ([]*Team, error)
+}
@@ -0,0 +1,45 @@
+// Package session defines server-side browser sessions referenced by a cookie
+package session
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by repositories when a session does not exist
+var ErrNotFound = errors.New("session not found")
+
+// Session represents a server-side login session. The ID is a hash of the cookie
+// token, so a leaked session store cannot be replayed as cookies.
+type Session struct {
+	ID        string
+	UserID    string
+	CSRFToken string
+	UserAgent string
+	IPAddress string
+	CreatedAt time.Time
+	// LastSeenAt is refreshed on use and drives the idle timeout
+	LastSeenAt time.Time
+	// ExpiresAt is the absolute lifetime, which activity does not extend
+	ExpiresAt time.Time
+}
+
+// Active returns true if the session has neither been idle too long nor reached its absolute expiry
+func (s *Session) Active(now time.Time, idleTimeout time.Duration) bool {
+	if !now.Before(s.ExpiresAt) {
+		return false
+	}
+	return idleTimeout <= 0 || now.Sub(s.LastSeenAt) < idleTimeout
+}
+
+// Repository defines the interface for session storage
+type Repository interface {
+	Save(s *Session) error
+	Update(s *Session) error
+	FindByID(id string) (*Session, error)
+	FindByUser(userID string) ([]*Session, error)
+	Delete(id string) error
+	DeleteByUser(userID string) error
+	// DeleteExpired removes sessions whose absolute expiry or idle deadline has passed
+	DeleteExpired(now time.Time, idleTimeout time.Duration) error
+}
@@ -0,0 +1,37 @@
+// Package tenant defines the organizations that share this service, each with its own users
+package tenant
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+// DefaultID is the tenant of single-tenant deployments and of requests that name no tenant
+const DefaultID = "default"
+
+// ErrNotFound is returned by repositories when a tenant does not exist
+var ErrNotFound = errors.New("tenant not found")
+
+// idPattern restricts tenant IDs to DNS labels, so every tenant can be addressed by subdomain
+var idPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Tenant is an organization whose users, tokens and data are isolated from other tenants
+type Tenant struct {
+	// ID is also the subdomain, header value and path segment that selects the tenant
+	ID        string
+	Name      string
+	CreatedAt time.Time
+}
+
+// ValidID reports whether id can be used as a tenant ID
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// Repository defines the interface for tenant storage
+type Repository interface {
+	Save(t *Tenant) error
+	FindByID(id string) (*Tenant, error)
+	FindAll() ([]*Tenant, error)
+}
@@ -0,0 +1,38 @@
+package user
+
+import "github.com/lamboktulussimamora/gra-project/internal/domain/event"
+
+// Events raised by user operations
+const (
+	EventRegistered   = "user.registered"
+	EventEmailChanged = "user.email_changed"
+	EventDisabled     = "user.disabled"
+	EventEnabled      = "user.enabled"
+)
+
+// ChangeEmail sets a new email, raising EventEmailChanged with the previous one
+func (u *User) ChangeEmail(email string) {
+	if email == u.Email {
+		return
+	}
+	previous := u.Email
+	u.Email = email
+	u.record(EventEmailChanged, map[string]string{"email": email, "previous_email": previous})
+}
+
+// SetDisabled disables or re-enables the account, raising EventDisabled or EventEnabled
+func (u *User) SetDisabled(disabled bool) {
+	if disabled == u.Disabled {
+		return
+	}
+	u.Disabled = disabled
+	if disabled {
+		u.record(EventDisabled, map[string]string{"email": u.Email})
+	} else {
+		u.record(EventEnabled, map[string]string{"email": u.Email})
+	}
+}
+
+func (u *User) record(eventType string, data map[string]string) {
+	u.Record(event.New(eventType, u.TenantID, u.ID, data))
+}
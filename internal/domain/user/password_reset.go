@@ -0,0 +1,24 @@
+package user
+
+import "time"
+
+// PasswordResetToken represents a pending password reset.
+// Only the hash of the token is stored; the raw token is sent to the user.
+type PasswordResetToken struct {
+	TokenHash string
+	TenantID  string
+	Email     string
+	ExpiresAt time.Time
+}
+
+// Expired returns true if the token can no longer be used
+func (t *PasswordResetToken) Expired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// PasswordResetRepository defines the interface for password reset token storage
+type PasswordResetRepository interface {
+	Save(token *PasswordResetToken) error
+	FindByHash(tokenHash string) (*PasswordResetToken, error)
+	Delete(tokenHash string) error
+}
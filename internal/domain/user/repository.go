@@ -1,7 +1,13 @@
 package user
 
-// Repository defines the interface for user data access
+// Repository defines the interface for user data access. Every lookup is scoped to a tenant,
+// so a user of one tenant can never be found through another; Save and Update use the tenant
+// of the user.
 type Repository interface {
 	Save(user *User) error
-	FindByEmail(email string) (*User, error)
+	Update(user *User) error
+	FindByID(tenantID, id string) (*User, error)
+	FindByEmail(tenantID, email string) (*User, error)
+	// FindAll returns every user of a tenant
+	FindAll(tenantID string) ([]*User, error)
 }
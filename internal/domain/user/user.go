@@ -1,21 +1,44 @@
 package user
 
-import "time"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/event"
+)
+
+// RoleAdmin is the role granted to administrators
+const RoleAdmin = "admin"
 
 // User represents the user entity with all its attributes
 type User struct {
+	ID string
+	// TenantID is the tenant the user belongs to. Emails are unique within a tenant only.
+	TenantID  string
 	FirstName string
 	LastName  string
 	Email     string
 	Password  string
+	Roles     []string
+	// ExternalID is the identifier a provisioning client such as a SCIM directory knows the user by
+	ExternalID string
+	// Disabled users cannot sign in. Deprovisioned users are disabled rather than deleted.
+	Disabled  bool
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Recorder holds the events raised by the operations on the user until they are saved
+	event.Recorder `json:"-"`
 }
 
-// NewUser creates a new user instance with current time for created/updated fields
-func NewUser(firstName, lastName, email, password string) *User {
+// NewUser creates a new user of a tenant with current time for created/updated fields.
+// It raises EventRegistered, which is written to the outbox when the user is saved.
+func NewUser(tenantID, firstName, lastName, email, password string) *User {
 	now := time.Now()
-	return &User{
+	u := &User{
+		ID:        NewID(),
+		TenantID:  tenantID,
 		FirstName: firstName,
 		LastName:  lastName,
 		Email:     email,
@@ -23,9 +46,30 @@ func NewUser(firstName, lastName, email, password string) *User {
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
+	u.record(EventRegistered, map[string]string{"email": email})
+	return u
+}
+
+// NewID generates a random user ID
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("user: failed to generate ID: " + err.Error())
+	}
+	return hex.EncodeToString(b)
 }
 
 // Validate returns true if the user data is valid
 func (u *User) Validate() bool {
 	return u.FirstName != "" && u.LastName != "" && u.Email != "" && u.Password != ""
 }
+
+// HasRole returns true if the user has the given role
+func (u *User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
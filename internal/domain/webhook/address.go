@@ -0,0 +1,11 @@
+package webhook
+
+import "net"
+
+// PublicAddress reports whether ip may receive webhooks. Loopback, private, link-local,
+// multicast and unspecified addresses are refused, so a registered endpoint cannot make the
+// service call into its own network, such as a cloud metadata endpoint.
+func PublicAddress(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
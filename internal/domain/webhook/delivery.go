@@ -0,0 +1,79 @@
+package webhook
+
+import "time"
+
+// Delivery states
+const (
+	// StatusPending deliveries wait for their next attempt
+	StatusPending = "pending"
+	// StatusSucceeded deliveries were accepted by the endpoint
+	StatusSucceeded = "succeeded"
+	// StatusDead deliveries ran out of attempts and sit in the dead-letter queue until they
+	// are redelivered
+	StatusDead = "dead"
+)
+
+// Delivery is one event sent to one endpoint, with the log of its attempts
+type Delivery struct {
+	ID         string
+	TenantID   string
+	EndpointID string
+	EventID    string
+	EventType  string
+	// Payload is the exact JSON body sent, so redeliveries send the same bytes
+	Payload []byte
+	Status  string
+	// NextAttemptAt is when a pending delivery is attempted next
+	NextAttemptAt time.Time
+	Attempts      []Attempt
+	// RedeliveryOf is the delivery this one was manually redelivered from, if any
+	RedeliveryOf string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Attempt records one try to deliver a payload
+type Attempt struct {
+	At time.Time
+	// StatusCode is the endpoint's HTTP status, or 0 when no response was received
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
+
+// DeliveryFilter selects deliveries; empty fields match every delivery
+type DeliveryFilter struct {
+	EndpointID string
+	Status     string
+}
+
+// Matches reports whether a delivery is selected by the filter
+func (f DeliveryFilter) Matches(d *Delivery) bool {
+	return (f.EndpointID == "" || f.EndpointID == d.EndpointID) &&
+		(f.Status == "" || f.Status == d.Status)
+}
+
+// DeliveryRepository defines the interface for webhook delivery storage
+type DeliveryRepository interface {
+	Save(delivery *Delivery) error
+	Update(delivery *Delivery) error
+	FindByID(tenantID, id string) (*Delivery, error)
+	// Find returns the deliveries of a tenant selected by filter, newest first
+	Find(tenantID string, filter DeliveryFilter) ([]*Delivery, error)
+	// FindDue returns up to limit pending deliveries of every tenant due at now, oldest first,
+	// with at most perEndpoint of them to any one endpoint
+	FindDue(now time.Time, limit, perEndpoint int) ([]*Delivery, error)
+}
+
+// Request is an HTTP POST of a payload to an endpoint
+type Request struct {
+	URL     string
+	Headers map[string]string
+	Body    []byte
+}
+
+// Sender defines the interface for posting payloads to endpoints. It returns the response
+// status code, or an error when no response was received.
+type Sender interface {
+	Send(req Request) (int, error)
+}
@@ -0,0 +1,94 @@
+// Package webhook defines the notification of user lifecycle events to HTTP endpoints that a
+// tenant registers. Every event is delivered to each subscribed endpoint as a signed JSON
+// payload, retried with backoff until the endpoint accepts it or it is dead-lettered.
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Webhook errors
+var (
+	ErrEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// Event types
+const (
+	EventUserRegistered   = "user.registered"
+	EventUserLoggedIn     = "user.logged_in"
+	EventUserEmailChanged = "user.email_changed"
+	EventUserDeleted      = "user.deleted"
+)
+
+// EventTypes lists the event types endpoints can subscribe to
+var EventTypes = []string{EventUserRegistered, EventUserLoggedIn, EventUserEmailChanged, EventUserDeleted}
+
+// Event is something that happened to a user of a tenant
+type Event struct {
+	ID         string
+	TenantID   string
+	Type       string
+	OccurredAt time.Time
+	// Data holds the event attributes, such as user_id and email
+	Data map[string]string
+}
+
+// NewEvent creates an event that happens now
+func NewEvent(tenantID, eventType string, data map[string]string) Event {
+	return Event{
+		ID:         NewID(),
+		TenantID:   tenantID,
+		Type:       eventType,
+		OccurredAt: time.Now(),
+		Data:       data,
+	}
+}
+
+// Endpoint is a URL of a tenant that receives events
+type Endpoint struct {
+	ID       string
+	TenantID string
+	URL      string
+	// Secret signs the payloads delivered to the endpoint
+	Secret string
+	// Events are the event types delivered to the endpoint; empty means every type
+	Events      []string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Subscribes reports whether events of a type are delivered to the endpoint
+func (e *Endpoint) Subscribes(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, t := range e.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// EndpointRepository defines the interface for webhook endpoint storage
+type EndpointRepository interface {
+	Save(endpoint *Endpoint) error
+	Update(endpoint *Endpoint) error
+	Delete(tenantID, id string) error
+	FindByID(tenantID, id string) (*Endpoint, error)
+	FindByTenant(tenantID string) ([]*Endpoint, error)
+}
+
+// NewID generates a random webhook ID
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("webhook: failed to generate ID: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -1,17 +1,32 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
 )
 
 // APIResponse is a standardized response structure for all API handlers
 type APIResponse struct {
-	Status  string      `json:"status"`          // "success" or "error"
-	Message string      `json:"message"`         // Human-readable message
-	Data    interface{} `json:"data,omitempty"`  // Optional data payload
-	Error   string      `json:"error,omitempty"` // Error message if status is "error"
+	Status  string      `json:"status"`           // "success" or "error"
+	Message string      `json:"message"`          // Human-readable message
+	Data    interface{} `json:"data,omitempty"`   // Optional data payload
+	Error   string      `json:"error,omitempty"`  // Error message if status is "error"
+	Errors  interface{} `json:"errors,omitempty"` // Field errors if validation failed
+}
+
+// NewValidationErrorResponse builds the error response for failed request validation
+func NewValidationErrorResponse(errs validation.Errors) APIResponse {
+	return APIResponse{
+		Status: "error",
+		Error:  "Validation failed",
+		Errors: errs,
+	}
 }
 
 // SendJSONResponse is a helper function to send an APIResponse
@@ -29,3 +44,29 @@ type UserContextKey string
 
 // UserClaimsKey is the context key for storing user claims
 const UserClaimsKey UserContextKey = "userClaims"
+
+// PrincipalKey is the context key for storing the authenticated principal
+const PrincipalKey UserContextKey = "principal"
+
+// PrincipalFromContext returns the authenticated principal stored by the auth middleware
+func PrincipalFromContext(ctx context.Context) (*auth.Principal, bool) {
+	principal, ok := ctx.Value(PrincipalKey).(*auth.Principal)
+	return principal, ok
+}
+
+// TenantKey is the context key for storing the tenant a request was resolved to
+const TenantKey UserContextKey = "tenant"
+
+// WithTenant stores the tenant ID of a request in a context
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, TenantKey, tenantID)
+}
+
+// TenantFromContext returns the tenant stored by the tenant middleware, or the default tenant
+// for requests that were not resolved to one
+func TenantFromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(TenantKey).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return tenant.DefaultID
+}
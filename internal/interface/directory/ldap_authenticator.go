@@ -0,0 +1,275 @@
+// Package directory authenticates users against an LDAP directory such as Active Directory
+package directory
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// DefaultUserFilter finds a person by email; %s is replaced with the escaped login email
+const DefaultUserFilter = "(&(objectClass=person)(mail=%s))"
+
+// DefaultGroupFilter finds the groups listing a member; %s is replaced with the escaped user DN
+const DefaultGroupFilter = "(member=%s)"
+
+// LDAPConfig describes how to find and verify directory accounts
+type LDAPConfig struct {
+	// URL is the directory address, e.g. ldaps://dc.example.com
+	URL string
+	// StartTLS upgrades an ldap:// connection before any credentials are sent
+	StartTLS bool
+	// TLSConfig is used for ldaps:// and StartTLS
+	TLSConfig *tls.Config
+	// BindDN and BindPassword are the service account used to search; anonymous when empty
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	UserFilter   string
+	// GroupBaseDN enables a group search for directories without memberOf
+	GroupBaseDN string
+	GroupFilter string
+	// GroupRoles maps group DNs to the roles their members get
+	GroupRoles map[string]string
+	// TenantID is the tenant whose logins the directory answers and whose users it provisions
+	TenantID string
+	Timeout  time.Duration
+}
+
+// LDAPAuthenticator verifies credentials by binding as the user's directory entry. Accounts are
+// provisioned on first login and their directory-managed roles are refreshed on every login.
+type LDAPAuthenticator struct {
+	config   LDAPConfig
+	userRepo user.Repository
+}
+
+// NewLDAPAuthenticator creates a new LDAP authenticator
+func NewLDAPAuthenticator(config LDAPConfig, userRepo user.Repository) (*LDAPAuthenticator, error) {
+	if config.URL == "" || config.BaseDN == "" {
+		return nil, errors.New("ldap authenticator needs a URL and base DN")
+	}
+	if config.UserFilter == "" {
+		config.UserFilter = DefaultUserFilter
+	}
+	if config.GroupFilter == "" {
+		config.GroupFilter = DefaultGroupFilter
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.TenantID == "" {
+		config.TenantID = tenant.DefaultID
+	}
+
+	return &LDAPAuthenticator{config: config, userRepo: userRepo}, nil
+}
+
+// Authenticate checks an email and password against the directory and returns the local user.
+// Logins to other tenants than the directory's are left to the next authenticator.
+func (a *LDAPAuthenticator) Authenticate(tenantID, email, password string) (*user.User, error) {
+	// An empty password would be an unauthenticated bind, which many servers accept
+	if password == "" || tenantID != a.config.TenantID {
+		return nil, usecase.ErrInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := a.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	entry, err := a.findUser(conn, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, usecase.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap bind as user failed: %w", err)
+	}
+
+	groups := entry.GetAttributeValues("memberOf")
+	if a.config.GroupBaseDN != "" {
+		// Search groups with the service account; the user may not be allowed to
+		if err := a.bindService(conn); err != nil {
+			return nil, err
+		}
+		found, err := a.findGroups(conn, entry.DN)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, found...)
+	}
+
+	return a.provision(email, entry, a.rolesFor(groups))
+}
+
+// dial connects to the directory, upgrading to TLS when configured
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(a.config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: a.config.Timeout}),
+		ldap.DialWithTLSConfig(a.config.TLSConfig))
+	if err != nil {
+		return nil, fmt.Errorf("ldap dial failed: %w", err)
+	}
+	conn.SetTimeout(a.config.Timeout)
+
+	if a.config.StartTLS {
+		if err := conn.StartTLS(a.config.TLSConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap StartTLS failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// bindService binds as the service account, or anonymously when none is configured
+func (a *LDAPAuthenticator) bindService(conn *ldap.Conn) error {
+	var err error
+	if a.config.BindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(a.config.BindDN, a.config.BindPassword)
+	}
+	if err != nil {
+		return fmt.Errorf("ldap service bind failed: %w", err)
+	}
+	return nil
+}
+
+// findUser returns the single directory entry for an email
+func (a *LDAPAuthenticator) findUser(conn *ldap.Conn, email string) (*ldap.Entry, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(a.config.Timeout.Seconds()), false,
+		fmt.Sprintf(a.config.UserFilter, ldap.EscapeFilter(email)),
+		[]string{"mail", "givenName", "sn", "cn", "memberOf"},
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("ldap user search failed: %w", err)
+	}
+
+	// An ambiguous email must not pick one of the accounts
+	if result == nil || len(result.Entries) != 1 {
+		return nil, usecase.ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+// findGroups returns the DNs of the groups listing userDN as a member
+func (a *LDAPAuthenticator) findGroups(conn *ldap.Conn, userDN string) ([]string, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.config.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(a.config.Timeout.Seconds()), false,
+		fmt.Sprintf(a.config.GroupFilter, ldap.EscapeFilter(userDN)),
+		[]string{"dn"},
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ldap group search failed: %w", err)
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.DN)
+	}
+	return groups, nil
+}
+
+// rolesFor maps group DNs to roles. DNs are compared the way the directory does, ignoring case
+// and spacing differences.
+func (a *LDAPAuthenticator) rolesFor(groups []string) []string {
+	var roles []string
+	for groupDN, role := range a.config.GroupRoles {
+		mapped, err := ldap.ParseDN(groupDN)
+		if err != nil {
+			continue
+		}
+		for _, group := range groups {
+			if dn, err := ldap.ParseDN(group); err == nil && dn.EqualFold(mapped) {
+				roles = appendRole(roles, role)
+				break
+			}
+		}
+	}
+	return roles
+}
+
+// provision creates the local user on first login and replaces its directory-managed roles.
+// Roles that no group maps to, such as ones granted locally, are kept.
+func (a *LDAPAuthenticator) provision(email string, entry *ldap.Entry, roles []string) (*user.User, error) {
+	email = validation.NormalizeEmail(email)
+
+	u, err := a.userRepo.FindByEmail(a.config.TenantID, email)
+	if err != nil {
+		firstName := validation.NormalizeName(entry.GetAttributeValue("givenName"))
+		lastName := validation.NormalizeName(entry.GetAttributeValue("sn"))
+		if firstName == "" && lastName == "" {
+			firstName = validation.NormalizeName(entry.GetAttributeValue("cn"))
+		}
+
+		// Directory users have no local password; the directory stays the source of truth
+		u = user.NewUser(a.config.TenantID, firstName, lastName, email, "")
+		u.Roles = roles
+		if err := a.userRepo.Save(u); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+
+	managed := make(map[string]bool, len(a.config.GroupRoles))
+	for _, role := range a.config.GroupRoles {
+		managed[role] = true
+	}
+	updated := append([]string(nil), roles...)
+	for _, role := range u.Roles {
+		if !managed[role] {
+			updated = appendRole(updated, role)
+		}
+	}
+
+	if !sameRoles(u.Roles, updated) {
+		u.Roles = updated
+		u.UpdatedAt = time.Now()
+		if err := a.userRepo.Update(u); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// appendRole adds a role unless it is already present
+func appendRole(roles []string, role string) []string {
+	for _, r := range roles {
+		if r == role {
+			return roles
+		}
+	}
+	return append(roles, role)
+}
+
+// sameRoles reports whether two role lists hold the same roles, in any order
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, role := range b {
+		if len(appendRole(a, role)) != len(a) {
+			return false
+		}
+	}
+	return true
+}
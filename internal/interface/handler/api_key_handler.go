@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// APIKeyHandler handles HTTP requests for managing API keys
+type APIKeyHandler struct {
+	apiKeyUseCase *usecase.APIKeyUseCase
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyUseCase *usecase.APIKeyUseCase) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyUseCase: apiKeyUseCase,
+	}
+}
+
+// CreateAPIKeyRequest represents the API key creation request data
+type CreateAPIKeyRequest struct {
+	Name        string     `json:"name"`
+	Scopes      []string   `json:"scopes"`
+	ServiceName string     `json:"service_name,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKeyDTO represents the API key data that is returned in API responses
+type APIKeyDTO struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	OwnerType  string     `json:"owner_type"`
+	OwnerID    string     `json:"owner_id"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedAPIKeyDTO includes the raw key, which is only returned on creation
+type CreatedAPIKeyDTO struct {
+	APIKeyDTO
+	Key string `json:"key"`
+}
+
+// Keys handles GET (list) and POST (create) requests on the API key collection.
+// Admins can list service keys with ?service=<name>.
+func (h *APIKeyHandler) Keys(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := h.apiKeyUseCase.List(principal, r.URL.Query().Get("service"))
+		if err != nil {
+			sendError(w, apiKeyErrorStatus(err), err)
+			return
+		}
+
+		data := make([]APIKeyDTO, 0, len(keys))
+		for _, key := range keys {
+			data = append(data, newAPIKeyDTO(key))
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "API keys retrieved successfully",
+			Data:    data,
+		})
+
+	case http.MethodPost:
+		var req CreateAPIKeyRequest
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+
+		created, err := h.apiKeyUseCase.Create(principal, usecase.CreateAPIKeyInput{
+			Name:        req.Name,
+			Scopes:      req.Scopes,
+			ServiceName: req.ServiceName,
+			ExpiresAt:   req.ExpiresAt,
+		})
+		if err != nil {
+			sendError(w, apiKeyErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusCreated, APIResponse{
+			Status:  "success",
+			Message: "API key created. Store the key now, it will not be shown again",
+			Data: CreatedAPIKeyDTO{
+				APIKeyDTO: newAPIKeyDTO(created.APIKeyResponse),
+				Key:       created.Key,
+			},
+		})
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// Key handles GET (show) and DELETE (revoke) requests on a single API key
+func (h *APIKeyHandler) Key(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	id := path.Base(r.URL.Path)
+
+	switch r.Method {
+	case http.MethodGet:
+		key, err := h.apiKeyUseCase.Get(principal, id)
+		if err != nil {
+			sendError(w, apiKeyErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "API key retrieved successfully",
+			Data:    newAPIKeyDTO(*key),
+		})
+
+	case http.MethodDelete:
+		if err := h.apiKeyUseCase.Revoke(principal, id); err != nil {
+			sendError(w, apiKeyErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "API key revoked successfully",
+		})
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// apiKeyErrorStatus maps API key use case errors to HTTP status codes
+func apiKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, usecase.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// newAPIKeyDTO converts a use case API key response to its DTO
+func newAPIKeyDTO(key usecase.APIKeyResponse) APIKeyDTO {
+	return APIKeyDTO{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		OwnerType:  string(key.OwnerType),
+		OwnerID:    key.OwnerID,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
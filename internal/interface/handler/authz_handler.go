@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/authz"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// AuthzHandler handles HTTP requests for permission checks and relation tuples
+type AuthzHandler struct {
+	authzUseCase *usecase.AuthzUseCase
+}
+
+// NewAuthzHandler creates a new authorization handler
+func NewAuthzHandler(authzUseCase *usecase.AuthzUseCase) *AuthzHandler {
+	return &AuthzHandler{
+		authzUseCase: authzUseCase,
+	}
+}
+
+// CheckRequest represents a permission check request. An empty subject checks the caller.
+type CheckRequest struct {
+	Subject  string `json:"subject,omitempty"`
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+}
+
+// CheckResultDTO represents the result of a permission check
+type CheckResultDTO struct {
+	Allowed bool `json:"allowed"`
+}
+
+// ExplanationDTO represents how a permission check was evaluated
+type ExplanationDTO struct {
+	Expression string            `json:"expression"`
+	Allowed    bool              `json:"allowed"`
+	Children   []*ExplanationDTO `json:"children,omitempty"`
+}
+
+// TuplesRequest represents relation tuples to write or delete, written object#relation@subject
+type TuplesRequest struct {
+	Tuples []string `json:"tuples"`
+}
+
+// Check handles POST requests that check whether the caller, or for admins any subject, may
+// perform an action on a resource
+func (h *AuthzHandler) Check(w http.ResponseWriter, r *http.Request) {
+	h.evaluate(w, r, func(principal *auth.Principal, input usecase.CheckInput) (interface{}, error) {
+		allowed, err := h.authzUseCase.CheckAccess(principal, input)
+		return CheckResultDTO{Allowed: allowed}, err
+	})
+}
+
+// Explain handles POST requests that evaluate a check and return the tree of relations and
+// tuples that decided it
+func (h *AuthzHandler) Explain(w http.ResponseWriter, r *http.Request) {
+	h.evaluate(w, r, func(principal *auth.Principal, input usecase.CheckInput) (interface{}, error) {
+		explanation, err := h.authzUseCase.ExplainAccess(principal, input)
+		if err != nil {
+			return nil, err
+		}
+		return newExplanationDTO(explanation), nil
+	})
+}
+
+// Tuples handles GET (read), POST (write) and DELETE requests on the relation tuples of the
+// tenant. Reads can be narrowed with ?object=<type or type:id>&relation=<name>&subject=<subject>.
+func (h *AuthzHandler) Tuples(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		tuples, err := h.authzUseCase.ReadTuples(principal, usecase.TupleQuery{
+			Object:   query.Get("object"),
+			Relation: query.Get("relation"),
+			Subject:  query.Get("subject"),
+		})
+		if err != nil {
+			sendError(w, authzErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Relation tuples retrieved successfully",
+			Data:    tupleStrings(tuples),
+		})
+
+	case http.MethodPost:
+		var req TuplesRequest
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+
+		tuples, err := h.authzUseCase.WriteTuples(principal, req.Tuples)
+		if err != nil {
+			sendError(w, authzErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusCreated, APIResponse{
+			Status:  "success",
+			Message: "Relation tuples written successfully",
+			Data:    tupleStrings(tuples),
+		})
+
+	case http.MethodDelete:
+		var req TuplesRequest
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+
+		if err := h.authzUseCase.DeleteTuples(principal, req.Tuples); err != nil {
+			sendError(w, authzErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Relation tuples deleted successfully",
+		})
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// evaluate decodes a check request and sends the result of run
+func (h *AuthzHandler) evaluate(w http.ResponseWriter, r *http.Request, run func(*auth.Principal, usecase.CheckInput) (interface{}, error)) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	var req CheckRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	data, err := run(principal, usecase.CheckInput{Subject: req.Subject, Action: req.Action, Resource: req.Resource})
+	if err != nil {
+		sendError(w, authzErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Permission checked",
+		Data:    data,
+	})
+}
+
+// authzErrorStatus maps authorization use case errors to HTTP status codes
+func authzErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, authz.ErrUnknownType), errors.Is(err, authz.ErrUnknownRelation), errors.Is(err, authz.ErrInvalidTuple):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func newExplanationDTO(e *authz.Explanation) *ExplanationDTO {
+	dto := &ExplanationDTO{Expression: e.Expression, Allowed: e.Allowed}
+	for _, child := range e.Children {
+		dto.Children = append(dto.Children, newExplanationDTO(child))
+	}
+	return dto
+}
+
+// tupleStrings returns the object#relation@subject form of tuples
+func tupleStrings(tuples []authz.Tuple) []string {
+	strs := make([]string, 0, len(tuples))
+	for _, t := range tuples {
+		strs = append(strs, t.String())
+	}
+	return strs
+}
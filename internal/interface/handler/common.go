@@ -1,9 +1,15 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+	"github.com/lamboktulussimamora/gra/context"
 )
 
 // APIResponse is an alias for common.APIResponse for backward compatibility
@@ -13,3 +19,75 @@ type APIResponse = common.APIResponse
 func SendJSONResponse(w http.ResponseWriter, status int, response common.APIResponse) {
 	common.SendJSONResponse(w, status, response)
 }
+
+// requireMethod sends a 405 response and returns false if the request method does not match
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		SendJSONResponse(w, http.StatusMethodNotAllowed, APIResponse{
+			Status: "error",
+			Error:  "Method not allowed",
+		})
+		return false
+	}
+	return true
+}
+
+// sendUnauthorized sends a 401 response for requests without an authenticated principal
+func sendUnauthorized(w http.ResponseWriter) {
+	SendJSONResponse(w, http.StatusUnauthorized, APIResponse{
+		Status: "error",
+		Error:  "Unauthorized",
+	})
+}
+
+// decodeJSONRequest decodes the JSON request body into v, sending a 400 response on failure
+func decodeJSONRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		SendJSONResponse(w, http.StatusBadRequest, APIResponse{
+			Status: "error",
+			Error:  "Invalid request format",
+		})
+		log.Printf("Error decoding JSON: %v", err)
+		return false
+	}
+	return true
+}
+
+// retryAfterSeconds is suggested to clients when the service is busy
+const retryAfterSeconds = "1"
+
+// sendError sends an error response. Validation errors are always sent as 400 with their field
+// details, and ErrServiceBusy as 503 with a Retry-After header.
+func sendError(w http.ResponseWriter, status int, err error) {
+	var validationErrs validation.Errors
+	if errors.As(err, &validationErrs) {
+		SendJSONResponse(w, http.StatusBadRequest, common.NewValidationErrorResponse(validationErrs))
+		return
+	}
+	if errors.Is(err, usecase.ErrServiceBusy) {
+		w.Header().Set("Retry-After", retryAfterSeconds)
+		status = http.StatusServiceUnavailable
+	}
+
+	SendJSONResponse(w, status, APIResponse{
+		Status: "error",
+		Error:  err.Error(),
+	})
+}
+
+// sendGraError is the gra router counterpart of sendError
+func sendGraError(c *context.Context, status int, err error) {
+	var validationErrs validation.Errors
+	if errors.As(err, &validationErrs) {
+		c.JSON(http.StatusBadRequest, common.NewValidationErrorResponse(validationErrs))
+		return
+	}
+	if errors.Is(err, usecase.ErrServiceBusy) {
+		c.Writer.Header().Set("Retry-After", retryAfterSeconds)
+		status = http.StatusServiceUnavailable
+	}
+
+	c.Error(status, err.Error())
+}
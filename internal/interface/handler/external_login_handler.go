@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// ExternalLoginStateCookie binds a pending external login to the browser that started it
+const ExternalLoginStateCookie = "external_login_state"
+
+// ExternalLoginHandler handles sign-in through upstream identity providers. The login, callback,
+// acs and metadata endpoints take the provider name from the path segment before the last one.
+type ExternalLoginHandler struct {
+	externalLoginUseCase *usecase.ExternalLoginUseCase
+	// secureCookie should only be disabled for local development over plain HTTP
+	secureCookie bool
+}
+
+// NewExternalLoginHandler creates a new external login handler
+func NewExternalLoginHandler(externalLoginUseCase *usecase.ExternalLoginUseCase, secureCookie bool) *ExternalLoginHandler {
+	return &ExternalLoginHandler{
+		externalLoginUseCase: externalLoginUseCase,
+		secureCookie:         secureCookie,
+	}
+}
+
+// LinkedIdentityDTO represents a linked identity that is returned in API responses
+type LinkedIdentityDTO struct {
+	Provider    string    `json:"provider"`
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// Providers lists the configured providers
+func (h *ExternalLoginHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Identity providers retrieved successfully",
+		Data:    h.externalLoginUseCase.Providers(),
+	})
+}
+
+// Login redirects the browser to the provider and remembers the login state in a cookie
+func (h *ExternalLoginHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	start, err := h.externalLoginUseCase.Begin(r.Context(), common.TenantFromContext(r.Context()), providerFromPath(r))
+	if err != nil {
+		sendError(w, externalLoginErrorStatus(err), err)
+		return
+	}
+
+	http.SetCookie(w, h.newStateCookie(start.State, h.externalLoginUseCase.Config().StateTTL, start.FormPost))
+	http.Redirect(w, r, start.RedirectURL, http.StatusFound)
+}
+
+// Callback completes the login when the provider redirects back and returns a login token
+func (h *ExternalLoginHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	query := r.URL.Query()
+
+	// The state cookie is single use whatever the outcome
+	http.SetCookie(w, h.newStateCookie("", -1, false))
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		SendJSONResponse(w, http.StatusUnauthorized, APIResponse{
+			Status: "error",
+			Error:  "Sign-in was not completed: " + providerErr,
+		})
+		return
+	}
+
+	h.complete(w, r, query.Get("state"), query.Get("code"))
+}
+
+// AssertionConsumer completes a SAML login from the response the identity provider posts, with
+// the state in the RelayState field, and returns a login token
+func (h *ExternalLoginHandler) AssertionConsumer(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	http.SetCookie(w, h.newStateCookie("", -1, true))
+	h.complete(w, r, r.PostFormValue("RelayState"), r.PostFormValue("SAMLResponse"))
+}
+
+// Metadata serves the document the provider imports to trust this service
+func (h *ExternalLoginHandler) Metadata(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	metadata, err := h.externalLoginUseCase.Metadata(providerFromPath(r))
+	if err != nil {
+		sendError(w, externalLoginErrorStatus(err), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(metadata)
+}
+
+// complete finishes a login whose state came back from the provider
+func (h *ExternalLoginHandler) complete(w http.ResponseWriter, r *http.Request, state, code string) {
+	// The state from the provider must come from this browser, so an attacker cannot complete
+	// their own login in the victim's browser
+	cookie, err := r.Cookie(ExternalLoginStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		sendError(w, http.StatusBadRequest, usecase.ErrInvalidLoginState)
+		return
+	}
+
+	authResp, err := h.externalLoginUseCase.Complete(r.Context(), common.TenantFromContext(r.Context()), providerFromPath(r), state, code)
+	if err != nil {
+		sendError(w, externalLoginErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Login successful",
+		Data:    newAuthResponseDTO(authResp),
+	})
+}
+
+// Identities lists the external accounts linked to the current user
+func (h *ExternalLoginHandler) Identities(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	identities, err := h.externalLoginUseCase.ListIdentities(principal)
+	if err != nil {
+		sendError(w, externalLoginErrorStatus(err), err)
+		return
+	}
+
+	data := make([]LinkedIdentityDTO, 0, len(identities))
+	for _, i := range identities {
+		data = append(data, LinkedIdentityDTO{
+			Provider:    i.Provider,
+			Email:       i.Email,
+			CreatedAt:   i.CreatedAt,
+			LastLoginAt: i.LastLoginAt,
+		})
+	}
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Linked identities retrieved successfully",
+		Data:    data,
+	})
+}
+
+// Unlink removes the current user's link to the provider in the last path segment
+func (h *ExternalLoginHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodDelete) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	if err := h.externalLoginUseCase.Unlink(principal, path.Base(r.URL.Path)); err != nil {
+		sendError(w, externalLoginErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Identity unlinked successfully",
+	})
+}
+
+// newStateCookie creates the login state cookie. SameSite=Lax still sends it on the provider's
+// top-level redirect back to the callback, but not on a cross-site form POST, which needs
+// SameSite=None. Browsers only accept that on secure cookies.
+func (h *ExternalLoginHandler) newStateCookie(value string, maxAge time.Duration, crossSitePost bool) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     ExternalLoginStateCookie,
+		Value:    value,
+		Path:     "/auth/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   h.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	if crossSitePost && h.secureCookie {
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	return cookie
+}
+
+// providerFromPath returns the provider name from /auth/{provider}/{endpoint}
+func providerFromPath(r *http.Request) string {
+	return path.Base(path.Dir(r.URL.Path))
+}
+
+// externalLoginErrorStatus maps external login errors to HTTP status codes
+func externalLoginErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrUnknownProvider), errors.Is(err, usecase.ErrIdentityNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, usecase.ErrInvalidLoginState):
+		return http.StatusBadRequest
+	case errors.Is(err, usecase.ErrExternalLoginFailed):
+		return http.StatusBadGateway
+	case errors.Is(err, usecase.ErrEmailNotVerified), errors.Is(err, usecase.ErrNoLinkedAccount),
+		errors.Is(err, usecase.ErrAccountDisabled):
+		return http.StatusUnauthorized
+	case errors.Is(err, usecase.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, usecase.ErrLastSignInMethod):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/lamboktulussimamora/gra-project/internal/compatibility"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"github.com/lamboktulussimamora/gra/context"
+)
+
+// GraUserHandler handles user requests on the gra router
+type GraUserHandler struct {
+	userUseCase *usecase.UserUseCase
+}
+
+// NewGraUserHandler creates a new gra user handler
+func NewGraUserHandler(userUseCase *usecase.UserUseCase) *GraUserHandler {
+	return &GraUserHandler{
+		userUseCase: userUseCase,
+	}
+}
+
+// Register handles user registration requests
+func (h *GraUserHandler) Register(c *context.Context) {
+	var req RegisterUserRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.Error(http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	// Call the use case, which owns request validation
+	userResp, err := h.userUseCase.Register(common.TenantFromContext(c.Request.Context()), req.FirstName, req.LastName, req.Email, req.Password)
+	if err != nil {
+		sendGraError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.Success(http.StatusCreated, "User registered successfully", newUserResponseDTO(*userResp))
+}
+
+// Login handles user login requests
+func (h *GraUserHandler) Login(c *context.Context) {
+	var req LoginRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.Error(http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	// Call the use case, which owns request validation
+	authResp, err := h.userUseCase.Login(common.TenantFromContext(c.Request.Context()), req.Email, req.Password)
+	if err != nil {
+		sendGraError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	c.Success(http.StatusOK, "Login successful", newAuthResponseDTO(authResp))
+}
+
+// Refresh exchanges a refresh token for a new token and refresh token
+func (h *GraUserHandler) Refresh(c *context.Context) {
+	var req RefreshRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.Error(http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	authResp, err := h.userUseCase.Refresh(common.TenantFromContext(c.Request.Context()), req.RefreshToken)
+	if err != nil {
+		sendGraError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	c.Success(http.StatusOK, "Token refreshed successfully", newAuthResponseDTO(authResp))
+}
+
+// ChangePassword handles password change requests for the authenticated user
+func (h *GraUserHandler) ChangePassword(c *context.Context) {
+	claimsVal, _ := compatibility.GetUserClaims(c)
+	claims, ok := claimsVal.(*auth.Claims)
+	if !ok {
+		c.Error(http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.Error(http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if err := h.userUseCase.ChangePassword(claims.Tenant(), claims.Email, req.CurrentPassword, req.NewPassword); err != nil {
+		sendGraError(c, passwordErrorStatus(err), err)
+		return
+	}
+
+	c.Success(http.StatusOK, "Password changed successfully", nil)
+}
+
+// ForgotPassword handles requests to email a password reset token
+func (h *GraUserHandler) ForgotPassword(c *context.Context) {
+	var req ForgotPasswordRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.Error(http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if err := h.userUseCase.RequestPasswordReset(common.TenantFromContext(c.Request.Context()), req.Email); err != nil {
+		sendGraError(c, passwordErrorStatus(err), err)
+		return
+	}
+
+	c.Success(http.StatusAccepted, forgotPasswordMessage, nil)
+}
+
+// ResetPassword handles requests to set a new password with a reset token
+func (h *GraUserHandler) ResetPassword(c *context.Context) {
+	var req ResetPasswordRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.Error(http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if err := h.userUseCase.ResetPassword(common.TenantFromContext(c.Request.Context()), req.Token, req.NewPassword); err != nil {
+		sendGraError(c, passwordErrorStatus(err), err)
+		return
+	}
+
+	c.Success(http.StatusOK, "Password reset successfully", nil)
+}
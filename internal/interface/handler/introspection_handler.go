@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// rateLimitRetryAfterSeconds is suggested to callers over their rate limit; limits are per minute
+const rateLimitRetryAfterSeconds = "60"
+
+// IntrospectionHandler handles token introspection requests from other services
+type IntrospectionHandler struct {
+	introspectionUseCase *usecase.IntrospectionUseCase
+}
+
+// NewIntrospectionHandler creates a new introspection handler
+func NewIntrospectionHandler(introspectionUseCase *usecase.IntrospectionUseCase) *IntrospectionHandler {
+	return &IntrospectionHandler{
+		introspectionUseCase: introspectionUseCase,
+	}
+}
+
+// IntrospectRequest represents the introspection request data
+type IntrospectRequest struct {
+	Token string `json:"token"`
+}
+
+// IntrospectionResultDTO represents the verdict on a token. Claims are only included for
+// active tokens.
+type IntrospectionResultDTO struct {
+	Active bool            `json:"active"`
+	Claims *TokenClaimsDTO `json:"claims,omitempty"`
+}
+
+// TokenClaimsDTO represents the claims of an active token
+type TokenClaimsDTO struct {
+	Subject   string     `json:"subject"`
+	Email     string     `json:"email,omitempty"`
+	FirstName string     `json:"first_name,omitempty"`
+	LastName  string     `json:"last_name,omitempty"`
+	Roles     []string   `json:"roles,omitempty"`
+	TenantID  string     `json:"tenant_id"`
+	Scope     string     `json:"scope,omitempty"`
+	ClientID  string     `json:"client_id,omitempty"`
+	TokenID   string     `json:"token_id,omitempty"`
+	Issuer    string     `json:"issuer,omitempty"`
+	IssuedAt  *time.Time `json:"issued_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Introspect handles POST requests asking whether a token is valid
+func (h *IntrospectionHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	var req IntrospectRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	result, err := h.introspectionUseCase.Introspect(principal, req.Token)
+	if err != nil {
+		if errors.Is(err, usecase.ErrRateLimited) {
+			w.Header().Set("Retry-After", rateLimitRetryAfterSeconds)
+		}
+		sendError(w, introspectionErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Token introspected successfully",
+		Data:    newIntrospectionResultDTO(result),
+	})
+}
+
+func introspectionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, usecase.ErrRateLimited):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func newIntrospectionResultDTO(result *usecase.TokenIntrospection) IntrospectionResultDTO {
+	if !result.Active {
+		return IntrospectionResultDTO{}
+	}
+	return IntrospectionResultDTO{Active: true, Claims: newTokenClaimsDTO(result.Claims)}
+}
+
+func newTokenClaimsDTO(claims *auth.Claims) *TokenClaimsDTO {
+	dto := &TokenClaimsDTO{
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		FirstName: claims.FirstName,
+		LastName:  claims.LastName,
+		Roles:     claims.Roles,
+		TenantID:  claims.Tenant(),
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenID:   claims.ID,
+		Issuer:    claims.Issuer,
+	}
+	if claims.IssuedAt != nil {
+		dto.IssuedAt = &claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		dto.ExpiresAt = &claims.ExpiresAt.Time
+	}
+	return dto
+}
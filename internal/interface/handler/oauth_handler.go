@@ -0,0 +1,480 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// OAuthHandler handles the OAuth 2.1 authorization server endpoints. The protocol endpoints
+// speak form-encoded requests and the plain JSON responses of RFC 6749; the management
+// endpoints use the usual API response envelope.
+type OAuthHandler struct {
+	oauthUseCase *usecase.OAuthUseCase
+}
+
+// NewOAuthHandler creates a new OAuth handler
+func NewOAuthHandler(oauthUseCase *usecase.OAuthUseCase) *OAuthHandler {
+	return &OAuthHandler{
+		oauthUseCase: oauthUseCase,
+	}
+}
+
+// RegisterClientRequest represents the client registration request data
+type RegisterClientRequest struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+	// PostLogoutRedirectURIs are the URIs the client may ask to return to after an
+	// RP-initiated logout
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+}
+
+// ClientDTO represents the client data that is returned in API responses
+type ClientDTO struct {
+	ID                     string    `json:"client_id"`
+	Name                   string    `json:"name"`
+	Type                   string    `json:"type"`
+	RedirectURIs           []string  `json:"redirect_uris"`
+	PostLogoutRedirectURIs []string  `json:"post_logout_redirect_uris,omitempty"`
+	GrantTypes             []string  `json:"grant_types"`
+	Scopes                 []string  `json:"scopes"`
+	CreatedAt              time.Time `json:"created_at"`
+}
+
+// RegisteredClientDTO includes the client secret, which is only returned on registration
+type RegisteredClientDTO struct {
+	ClientDTO
+	Secret string `json:"client_secret,omitempty"`
+}
+
+// ConsentPromptDTO describes the consent the user is asked for
+type ConsentPromptDTO struct {
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name"`
+	Scopes     []string `json:"scopes"`
+}
+
+// AuthorizeRedirectDTO tells a frontend where to send the browser after a consent decision
+type AuthorizeRedirectDTO struct {
+	RedirectURL string `json:"redirect_url"`
+}
+
+// ConsentDTO represents a consent the user has granted
+type ConsentDTO struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// TokenResponseDTO is the token endpoint response, see RFC 6749 section 5.1
+type TokenResponseDTO struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// IntrospectionDTO is the introspection response, see RFC 7662 section 2.2
+type IntrospectionDTO struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// OAuthErrorDTO is the error response, see RFC 6749 section 5.2
+type OAuthErrorDTO struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// Clients handles GET (list) and POST (register) requests on the client collection
+func (h *OAuthHandler) Clients(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		clients, err := h.oauthUseCase.ListClients(principal)
+		if err != nil {
+			sendError(w, oauthManagementStatus(err), err)
+			return
+		}
+
+		data := make([]ClientDTO, 0, len(clients))
+		for _, client := range clients {
+			data = append(data, newClientDTO(client))
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Clients retrieved successfully",
+			Data:    data,
+		})
+
+	case http.MethodPost:
+		var req RegisterClientRequest
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+
+		registered, err := h.oauthUseCase.RegisterClient(principal, usecase.RegisterClientInput{
+			Name:                   req.Name,
+			Type:                   req.Type,
+			RedirectURIs:           req.RedirectURIs,
+			PostLogoutRedirectURIs: req.PostLogoutRedirectURIs,
+			GrantTypes:             req.GrantTypes,
+			Scopes:                 req.Scopes,
+		})
+		if err != nil {
+			sendError(w, oauthManagementStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusCreated, APIResponse{
+			Status:  "success",
+			Message: "Client registered. Store the secret now, it will not be shown again",
+			Data: RegisteredClientDTO{
+				ClientDTO: newClientDTO(registered.ClientResponse),
+				Secret:    registered.Secret,
+			},
+		})
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// Authorize handles the authorization endpoint for a signed-in user. GET redirects back to the
+// client when no consent is needed and otherwise returns the consent prompt. POST submits the
+// user's decision (decision=approve or deny) along with the original parameters and returns the
+// URL to send the browser to.
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		sendOAuthError(w, &usecase.OAuthError{Code: usecase.OAuthInvalidRequest, Description: "malformed request"})
+		return
+	}
+
+	req := usecase.AuthorizeRequest{
+		ResponseType:        r.Form.Get("response_type"),
+		ClientID:            r.Form.Get("client_id"),
+		RedirectURI:         r.Form.Get("redirect_uri"),
+		Scope:               r.Form.Get("scope"),
+		State:               r.Form.Get("state"),
+		CodeChallenge:       r.Form.Get("code_challenge"),
+		CodeChallengeMethod: r.Form.Get("code_challenge_method"),
+		Nonce:               r.Form.Get("nonce"),
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		result, err := h.oauthUseCase.Authorize(principal, req)
+		if err != nil {
+			h.sendAuthorizeError(w, err)
+			return
+		}
+		if result.Consent != nil {
+			SendJSONResponse(w, http.StatusOK, APIResponse{
+				Status:  "success",
+				Message: "Consent required",
+				Data: ConsentPromptDTO{
+					ClientID:   result.Consent.ClientID,
+					ClientName: result.Consent.ClientName,
+					Scopes:     result.Consent.Scopes,
+				},
+			})
+			return
+		}
+		http.Redirect(w, r, result.RedirectURL, http.StatusFound)
+
+	case http.MethodPost:
+		result, err := h.oauthUseCase.Consent(principal, req, r.Form.Get("decision") == "approve")
+		if err != nil {
+			h.sendAuthorizeError(w, err)
+			return
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Consent recorded",
+			Data:    AuthorizeRedirectDTO{RedirectURL: result.RedirectURL},
+		})
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// Token handles the token endpoint
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	clientID, clientSecret, ok := h.parseClientRequest(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.oauthUseCase.Token(usecase.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scope:        r.PostForm.Get("scope"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TenantID:     common.TenantFromContext(r.Context()),
+	})
+	if err != nil {
+		sendOAuthError(w, err)
+		return
+	}
+
+	sendOAuthJSON(w, http.StatusOK, TokenResponseDTO{
+		AccessToken:  resp.AccessToken,
+		TokenType:    resp.TokenType,
+		ExpiresIn:    resp.ExpiresIn,
+		RefreshToken: resp.RefreshToken,
+		Scope:        resp.Scope,
+		IDToken:      resp.IDToken,
+	})
+}
+
+// Introspect handles the token introspection endpoint, see RFC 7662
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	clientID, clientSecret, ok := h.parseClientRequest(w, r)
+	if !ok {
+		return
+	}
+
+	introspection, err := h.oauthUseCase.Introspect(common.TenantFromContext(r.Context()), clientID, clientSecret, r.PostForm.Get("token"))
+	if err != nil {
+		sendOAuthError(w, err)
+		return
+	}
+
+	sendOAuthJSON(w, http.StatusOK, newIntrospectionDTO(introspection))
+}
+
+// Revoke handles the token revocation endpoint, see RFC 7009
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	clientID, clientSecret, ok := h.parseClientRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthUseCase.Revoke(common.TenantFromContext(r.Context()), clientID, clientSecret, r.PostForm.Get("token")); err != nil {
+		sendOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// Consents handles GET requests listing the clients the user has granted access to
+func (h *OAuthHandler) Consents(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	consents, err := h.oauthUseCase.ListConsents(principal)
+	if err != nil {
+		sendError(w, oauthManagementStatus(err), err)
+		return
+	}
+
+	data := make([]ConsentDTO, 0, len(consents))
+	for _, consent := range consents {
+		data = append(data, ConsentDTO{
+			ClientID:   consent.ClientID,
+			ClientName: consent.ClientName,
+			Scopes:     consent.Scopes,
+			GrantedAt:  consent.GrantedAt,
+		})
+	}
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Consents retrieved successfully",
+		Data:    data,
+	})
+}
+
+// RevokeConsent handles DELETE requests withdrawing consent for the client in the last path segment
+func (h *OAuthHandler) RevokeConsent(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodDelete) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	if err := h.oauthUseCase.RevokeConsent(principal, path.Base(r.URL.Path)); err != nil {
+		sendError(w, oauthManagementStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Consent revoked successfully",
+	})
+}
+
+// parseClientRequest parses a form-encoded client request and returns the client credentials from
+// HTTP Basic authentication (client_secret_basic) or the form (client_secret_post)
+func (h *OAuthHandler) parseClientRequest(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	if err := r.ParseForm(); err != nil {
+		sendOAuthError(w, &usecase.OAuthError{Code: usecase.OAuthInvalidRequest, Description: "malformed request"})
+		return "", "", false
+	}
+
+	if id, secret, ok := r.BasicAuth(); ok {
+		// Credentials are form-encoded before being placed in the header, see RFC 6749 section 2.3.1
+		clientID, err := url.QueryUnescape(id)
+		if err != nil {
+			clientID = id
+		}
+		clientSecret, err := url.QueryUnescape(secret)
+		if err != nil {
+			clientSecret = secret
+		}
+		return clientID, clientSecret, true
+	}
+
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), true
+}
+
+// sendAuthorizeError sends an authorization error that must not be redirected to the client
+func (h *OAuthHandler) sendAuthorizeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, usecase.ErrForbidden) {
+		sendError(w, http.StatusForbidden, err)
+		return
+	}
+
+	// The client is not authenticating here, so an unknown client is a bad request rather than a 401
+	var oauthErr *usecase.OAuthError
+	if errors.As(err, &oauthErr) {
+		sendOAuthJSON(w, http.StatusBadRequest, OAuthErrorDTO{Error: oauthErr.Code, Description: oauthErr.Description})
+		return
+	}
+	sendOAuthError(w, err)
+}
+
+// sendOAuthError sends an OAuth error response. Client authentication failures are 401.
+func sendOAuthError(w http.ResponseWriter, err error) {
+	var oauthErr *usecase.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("OAuth request failed: %v", err)
+		sendOAuthJSON(w, http.StatusInternalServerError, OAuthErrorDTO{Error: "server_error"})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == usecase.OAuthInvalidClient {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		status = http.StatusUnauthorized
+	}
+	sendOAuthJSON(w, status, OAuthErrorDTO{Error: oauthErr.Code, Description: oauthErr.Description})
+}
+
+// sendOAuthJSON sends a protocol response, which must never be cached
+func sendOAuthJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, status, v)
+}
+
+// writeJSON sends v as a bare JSON document, without the API response envelope
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// oauthManagementStatus maps OAuth management errors to HTTP status codes
+func oauthManagementStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, usecase.ErrConsentNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// newClientDTO converts a use case client response to its DTO
+func newClientDTO(client usecase.ClientResponse) ClientDTO {
+	return ClientDTO{
+		ID:                     client.ID,
+		Name:                   client.Name,
+		Type:                   string(client.Type),
+		RedirectURIs:           client.RedirectURIs,
+		PostLogoutRedirectURIs: client.PostLogoutRedirectURIs,
+		GrantTypes:             client.GrantTypes,
+		Scopes:                 client.Scopes,
+		CreatedAt:              client.CreatedAt,
+	}
+}
+
+// newIntrospectionDTO converts a use case introspection to its DTO
+func newIntrospectionDTO(i *usecase.Introspection) IntrospectionDTO {
+	if !i.Active {
+		return IntrospectionDTO{}
+	}
+
+	return IntrospectionDTO{
+		Active:    true,
+		Scope:     i.Scope,
+		ClientID:  i.ClientID,
+		Username:  i.Username,
+		TokenType: i.TokenType,
+		Exp:       i.ExpiresAt.Unix(),
+		Iat:       i.IssuedAt.Unix(),
+		Sub:       i.Subject,
+		Iss:       i.Issuer,
+		Jti:       i.TokenID,
+	}
+}
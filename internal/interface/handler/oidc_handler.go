@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// OpenID Connect endpoint paths, relative to the issuer
+const (
+	OIDCDiscoveryPath = "/.well-known/openid-configuration"
+	OIDCJWKSPath      = "/.well-known/jwks.json"
+	OIDCUserInfoPath  = "/userinfo"
+	OIDCLogoutPath    = "/oauth/logout"
+)
+
+// OIDCHandler handles the OpenID Connect provider endpoints layered on the OAuth handler
+type OIDCHandler struct {
+	oidcUseCase *usecase.OIDCUseCase
+	// sessions ends the browser session on logout; nil when cookie sessions are disabled
+	sessions *SessionHandler
+}
+
+// NewOIDCHandler creates a new OpenID Connect handler. sessionUseCase may be nil when cookie
+// sessions are disabled.
+func NewOIDCHandler(oidcUseCase *usecase.OIDCUseCase, sessionUseCase *usecase.SessionUseCase, cookie SessionCookieConfig) *OIDCHandler {
+	h := &OIDCHandler{oidcUseCase: oidcUseCase}
+	if sessionUseCase != nil {
+		h.sessions = NewSessionHandler(sessionUseCase, cookie)
+	}
+	return h
+}
+
+// DiscoveryDTO is the provider metadata document, see OpenID Connect Discovery 1.0 section 3
+type DiscoveryDTO struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	EndSessionEndpoint                string   `json:"end_session_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// LogoutDTO is returned by the logout endpoint when there is no post-logout redirect
+type LogoutDTO struct {
+	SessionEnded bool `json:"session_ended"`
+}
+
+// Discovery serves the provider metadata
+func (h *OIDCHandler) Discovery(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	issuer := h.oidcUseCase.Issuer()
+	writeJSON(w, http.StatusOK, DiscoveryDTO{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + OIDCUserInfoPath,
+		JWKSURI:                           issuer + OIDCJWKSPath,
+		EndSessionEndpoint:                issuer + OIDCLogoutPath,
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		ScopesSupported:                   usecase.OIDCScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   usecase.OIDCClaims,
+	})
+}
+
+// JWKS serves the public keys ID tokens are signed with
+func (h *OIDCHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Relying parties cache the key set; keep it short so rotations are picked up
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.oidcUseCase.JWKS())
+}
+
+// UserInfo returns the claims about the user the access token was issued for
+func (h *OIDCHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		requireMethod(w, r, http.MethodGet)
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	claims, err := h.oidcUseCase.UserInfo(principal)
+	if err != nil {
+		// A token without the openid scope is insufficient, see RFC 6750 section 3.1
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		sendOAuthJSON(w, http.StatusForbidden, OAuthErrorDTO{Error: "insufficient_scope"})
+		return
+	}
+
+	sendOAuthJSON(w, http.StatusOK, claims)
+}
+
+// Logout handles RP-initiated logout. The browser session is ended when it belongs to the user
+// named by the id_token_hint, and the browser is sent back to the registered post-logout URI.
+func (h *OIDCHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		requireMethod(w, r, http.MethodGet)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		sendOAuthError(w, &usecase.OAuthError{Code: usecase.OAuthInvalidRequest, Description: "malformed request"})
+		return
+	}
+
+	result, err := h.oidcUseCase.Logout(usecase.LogoutRequest{
+		IDTokenHint:           r.Form.Get("id_token_hint"),
+		PostLogoutRedirectURI: r.Form.Get("post_logout_redirect_uri"),
+		State:                 r.Form.Get("state"),
+		ClientID:              r.Form.Get("client_id"),
+		TenantID:              common.TenantFromContext(r.Context()),
+	})
+	if err != nil {
+		// Never redirect to an unverified URI
+		var oauthErr *usecase.OAuthError
+		if errors.As(err, &oauthErr) {
+			sendOAuthJSON(w, http.StatusBadRequest, OAuthErrorDTO{Error: oauthErr.Code, Description: oauthErr.Description})
+			return
+		}
+		sendOAuthError(w, err)
+		return
+	}
+
+	ended := h.endSession(w, r, result.UserID)
+	if result.RedirectURL != "" {
+		http.Redirect(w, r, result.RedirectURL, http.StatusFound)
+		return
+	}
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Logged out successfully",
+		Data:    LogoutDTO{SessionEnded: ended},
+	})
+}
+
+// endSession ends the browser session carried by the request if it belongs to userID, and
+// reports whether it did
+func (h *OIDCHandler) endSession(w http.ResponseWriter, r *http.Request, userID string) bool {
+	if h.sessions == nil {
+		return false
+	}
+	cookie, err := r.Cookie(h.sessions.cookie.Name)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	principal, err := h.sessions.sessionUseCase.Authenticate(common.TenantFromContext(r.Context()), cookie.Value)
+	if err != nil || principal.Subject != userID {
+		return false
+	}
+	if err := h.sessions.sessionUseCase.Logout(principal); err != nil {
+		return false
+	}
+
+	cleared := h.sessions.newCookie("", time.Unix(0, 0))
+	cleared.MaxAge = -1
+	http.SetCookie(w, cleared)
+	return true
+}
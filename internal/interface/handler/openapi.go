@@ -0,0 +1,283 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/openapi"
+)
+
+// TokenForm documents the form fields of the OAuth token endpoint. Clients may send their
+// credentials with HTTP basic authentication instead.
+type TokenForm struct {
+	GrantType    string `json:"grant_type"`
+	Code         string `json:"code,omitempty"`
+	RedirectURI  string `json:"redirect_uri,omitempty"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// TokenActionForm documents the form fields of the OAuth introspection and revocation endpoints
+type TokenActionForm struct {
+	Token        string `json:"token"`
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// ConsentForm documents the form fields of a consent decision, sent with the parameters of
+// the authorization request
+type ConsentForm struct {
+	Decision string `json:"decision"`
+}
+
+// SAMLResponseForm documents the form an identity provider posts to the assertion consumer
+type SAMLResponseForm struct {
+	SAMLResponse string `json:"SAMLResponse"`
+	RelayState   string `json:"RelayState"`
+}
+
+// protocolPaths are protected endpoints whose paths are fixed by their protocol, so servers
+// that mount the API under a prefix serve them unprefixed
+var protocolPaths = []string{"/oauth/authorize", OIDCUserInfoPath, SCIMBasePath + "/"}
+
+// NewOpenAPIDocument describes the HTTP API. The gra server mounts protected endpoints under
+// /api and passes it as apiPrefix; public and protocol endpoints keep their paths.
+func NewOpenAPIDocument(apiPrefix string) *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:   "gra-project API",
+		Version: "1.0.0",
+		Description: "User registration, login and account management. Protected endpoints accept a JWT, " +
+			"an API key or, when enabled, a session cookie; the tenant is selected with the X-Tenant-ID header.",
+	})
+	for _, op := range apiOperations {
+		if op.Auth == openapi.AuthUser && !isProtocolPath(op.Path) {
+			op.Path = apiPrefix + op.Path
+		}
+		doc.Add(op)
+	}
+	return doc
+}
+
+func isProtocolPath(path string) bool {
+	for _, p := range protocolPaths {
+		if path == p || strings.HasSuffix(p, "/") && strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+var authorizeParameters = []openapi.Parameter{
+	{Name: "response_type", Description: "Must be code", Required: true},
+	{Name: "client_id", Required: true},
+	{Name: "redirect_uri", Required: true},
+	{Name: "scope"},
+	{Name: "state"},
+	{Name: "code_challenge", Description: "PKCE challenge", Required: true},
+	{Name: "code_challenge_method", Description: "Must be S256"},
+	{Name: "nonce", Description: "Copied into the ID token"},
+}
+
+var scimListParameters = []openapi.Parameter{
+	{Name: "filter", Description: `SCIM filter, e.g. userName eq "ann@example.com"`},
+	{Name: "startIndex", Description: "1-based index of the first result"},
+	{Name: "count", Description: "Maximum number of results"},
+}
+
+// apiOperations are the endpoints of both servers, with the paths of cmd/api
+var apiOperations = []openapi.Operation{
+	{Method: http.MethodGet, Path: "/hello", Tag: "General", Summary: "Simple hello world endpoint"},
+	{Method: http.MethodGet, Path: openapi.SpecPath, Tag: "General", Summary: "This OpenAPI document", Bare: true},
+	{Method: http.MethodGet, Path: openapi.DocsPath, Tag: "General", Summary: "Browsable API documentation", Bare: true, ResponseType: "text/html"},
+
+	// Users and passwords
+	{Method: http.MethodPost, Path: "/register", Tag: "Users", Summary: "Register a user",
+		Request: RegisterUserRequest{}, Status: http.StatusCreated, Response: UserResponseDTO{}},
+	{Method: http.MethodPost, Path: "/login", Tag: "Users", Summary: "Log in and receive a JWT",
+		Request: LoginRequest{}, Response: AuthResponseDTO{}},
+	{Method: http.MethodPost, Path: "/refresh", Tag: "Users", Summary: "Exchange a refresh token for a new JWT and refresh token",
+		Request: RefreshRequest{}, Response: AuthResponseDTO{}},
+	{Method: http.MethodGet, Path: "/profile", Tag: "Users", Summary: "Profile of the current user", Auth: openapi.AuthUser,
+		Response: map[string]string{}},
+	{Method: http.MethodPost, Path: "/password/forgot", Tag: "Users", Summary: "Email a password reset token",
+		Request: ForgotPasswordRequest{}, Status: http.StatusAccepted},
+	{Method: http.MethodPost, Path: "/password/reset", Tag: "Users", Summary: "Set a new password with a reset token",
+		Request: ResetPasswordRequest{}},
+	{Method: http.MethodPost, Path: "/password/change", Tag: "Users", Summary: "Change the current password", Auth: openapi.AuthUser,
+		Request: ChangePasswordRequest{}},
+
+	// API keys
+	{Method: http.MethodGet, Path: "/api-keys", Tag: "API keys", Summary: "List your API keys", Auth: openapi.AuthUser,
+		Query: []openapi.Parameter{{Name: "service", Description: "List the keys of a service instead (admin)"}}, Response: []APIKeyDTO{}},
+	{Method: http.MethodPost, Path: "/api-keys", Tag: "API keys", Summary: "Create an API key; the raw key is returned only once", Auth: openapi.AuthUser,
+		Request: CreateAPIKeyRequest{}, Status: http.StatusCreated, Response: CreatedAPIKeyDTO{}},
+	{Method: http.MethodGet, Path: "/api-keys/{id}", Tag: "API keys", Summary: "Show an API key", Auth: openapi.AuthUser,
+		Response: APIKeyDTO{}},
+	{Method: http.MethodDelete, Path: "/api-keys/{id}", Tag: "API keys", Summary: "Revoke an API key", Auth: openapi.AuthUser},
+
+	// Cookie sessions, served when SESSIONS_ENABLED=true
+	{Method: http.MethodPost, Path: "/session/login", Tag: "Sessions", Summary: "Start a cookie session",
+		Request: LoginRequest{}, Response: SessionLoginResponseDTO{}},
+	{Method: http.MethodPost, Path: "/session/logout", Tag: "Sessions", Summary: "End the current session", Auth: openapi.AuthUser},
+	{Method: http.MethodGet, Path: "/session", Tag: "Sessions", Summary: "Current session and its CSRF token", Auth: openapi.AuthUser,
+		Response: CurrentSessionDTO{}},
+	{Method: http.MethodGet, Path: "/sessions", Tag: "Sessions", Summary: "List your active sessions", Auth: openapi.AuthUser,
+		Response: []SessionDTO{}},
+	{Method: http.MethodDelete, Path: "/sessions/{id}", Tag: "Sessions", Summary: "Terminate one of your sessions", Auth: openapi.AuthUser},
+
+	// OAuth 2.1 authorization server
+	{Method: http.MethodGet, Path: "/oauth/authorize", Tag: "OAuth", Summary: "Authorization endpoint", Auth: openapi.AuthUser,
+		Description: "Redirects with a code when the user has consented, and returns a consent prompt otherwise",
+		Query:       authorizeParameters, Response: ConsentPromptDTO{}},
+	{Method: http.MethodPost, Path: "/oauth/authorize", Tag: "OAuth", Summary: "Submit the consent decision", Auth: openapi.AuthUser,
+		Description: "Takes the parameters of the authorization request and decision=approve or deny",
+		Query:       authorizeParameters, Request: ConsentForm{}, RequestType: openapi.ContentForm, Response: AuthorizeRedirectDTO{}},
+	{Method: http.MethodPost, Path: "/oauth/token", Tag: "OAuth", Summary: "Token endpoint", Auth: openapi.AuthClient,
+		Description: "Grants tokens for authorization codes, refresh tokens and client credentials",
+		Request:     TokenForm{}, RequestType: openapi.ContentForm, Bare: true, Response: TokenResponseDTO{}, Error: OAuthErrorDTO{}},
+	{Method: http.MethodPost, Path: "/oauth/introspect", Tag: "OAuth", Summary: "Token introspection (RFC 7662)", Auth: openapi.AuthClient,
+		Request: TokenActionForm{}, RequestType: openapi.ContentForm, Bare: true, Response: IntrospectionDTO{}, Error: OAuthErrorDTO{}},
+	{Method: http.MethodPost, Path: "/oauth/revoke", Tag: "OAuth", Summary: "Token revocation (RFC 7009)", Auth: openapi.AuthClient,
+		Request: TokenActionForm{}, RequestType: openapi.ContentForm, Bare: true, Error: OAuthErrorDTO{}},
+	{Method: http.MethodGet, Path: "/oauth/clients", Tag: "OAuth", Summary: "List OAuth clients (admin)", Auth: openapi.AuthUser,
+		Response: []ClientDTO{}},
+	{Method: http.MethodPost, Path: "/oauth/clients", Tag: "OAuth", Summary: "Register an OAuth client (admin)", Auth: openapi.AuthUser,
+		Request: RegisterClientRequest{}, Status: http.StatusCreated, Response: RegisteredClientDTO{}},
+	{Method: http.MethodGet, Path: "/oauth/consents", Tag: "OAuth", Summary: "List the apps you have granted access", Auth: openapi.AuthUser,
+		Response: []ConsentDTO{}},
+	{Method: http.MethodDelete, Path: "/oauth/consents/{client_id}", Tag: "OAuth", Summary: "Withdraw consent and revoke the app's refresh tokens", Auth: openapi.AuthUser},
+
+	// OpenID Connect
+	{Method: http.MethodGet, Path: OIDCDiscoveryPath, Tag: "OpenID Connect", Summary: "Discovery document",
+		Bare: true, Response: DiscoveryDTO{}},
+	{Method: http.MethodGet, Path: OIDCJWKSPath, Tag: "OpenID Connect", Summary: "Public keys ID tokens are signed with",
+		Bare: true, Response: auth.JSONWebKeySet{}},
+	{Method: http.MethodGet, Path: OIDCUserInfoPath, Tag: "OpenID Connect", Summary: "Claims about the user of an openid access token", Auth: openapi.AuthUser,
+		Bare: true, Response: map[string]interface{}{}, Error: OAuthErrorDTO{}},
+	{Method: http.MethodPost, Path: OIDCUserInfoPath, Tag: "OpenID Connect", Summary: "Claims about the user of an openid access token", Auth: openapi.AuthUser,
+		Bare: true, Response: map[string]interface{}{}, Error: OAuthErrorDTO{}},
+	{Method: http.MethodGet, Path: OIDCLogoutPath, Tag: "OpenID Connect", Summary: "RP-initiated logout",
+		Description: "Redirects to the post_logout_redirect_uri when one is given",
+		Query:       []openapi.Parameter{{Name: "id_token_hint", Required: true}, {Name: "post_logout_redirect_uri"}, {Name: "state"}, {Name: "client_id"}},
+		Response:    LogoutDTO{}},
+	{Method: http.MethodPost, Path: OIDCLogoutPath, Tag: "OpenID Connect", Summary: "RP-initiated logout",
+		Description: "Takes the parameters of the GET request as a form",
+		Response:    LogoutDTO{}},
+
+	// External identity providers
+	{Method: http.MethodGet, Path: "/auth/providers", Tag: "External login", Summary: "List the configured external identity providers",
+		Response: []string{}},
+	{Method: http.MethodGet, Path: "/auth/{provider}/login", Tag: "External login", Summary: "Redirect to the provider to sign in",
+		Status: http.StatusFound, Bare: true},
+	{Method: http.MethodGet, Path: "/auth/{provider}/callback", Tag: "External login", Summary: "Complete an OpenID Connect sign-in and return a login token",
+		Query: []openapi.Parameter{{Name: "code"}, {Name: "state"}, {Name: "error"}}, Response: AuthResponseDTO{}},
+	{Method: http.MethodPost, Path: "/auth/{provider}/acs", Tag: "External login", Summary: "Complete a SAML sign-in and return a login token",
+		Request: SAMLResponseForm{}, RequestType: openapi.ContentForm, Response: AuthResponseDTO{}},
+	{Method: http.MethodGet, Path: "/auth/{provider}/metadata", Tag: "External login", Summary: "SAML service provider metadata",
+		Bare: true, ResponseType: "application/samlmetadata+xml"},
+	{Method: http.MethodGet, Path: "/auth/identities", Tag: "External login", Summary: "List your linked external accounts", Auth: openapi.AuthUser,
+		Response: []LinkedIdentityDTO{}},
+	{Method: http.MethodDelete, Path: "/auth/identities/{provider}", Tag: "External login", Summary: "Unlink an external account", Auth: openapi.AuthUser},
+
+	// SCIM 2.0 provisioning
+	{Method: http.MethodGet, Path: SCIMBasePath + "/ServiceProviderConfig", Tag: "SCIM", Summary: "Supported SCIM features",
+		Bare: true, ResponseType: scimContentType, Response: map[string]interface{}{}},
+	{Method: http.MethodGet, Path: SCIMBasePath + "/Users", Tag: "SCIM", Summary: "List or filter users", Auth: openapi.AuthUser,
+		Query: scimListParameters, Bare: true, ResponseType: scimContentType, Response: SCIMListResponseDTO{}, Error: SCIMErrorDTO{}},
+	{Method: http.MethodPost, Path: SCIMBasePath + "/Users", Tag: "SCIM", Summary: "Provision a user", Auth: openapi.AuthUser,
+		Request: SCIMUserDTO{}, Status: http.StatusCreated, Bare: true, ResponseType: scimContentType, Response: SCIMUserDTO{}, Error: SCIMErrorDTO{}},
+	{Method: http.MethodGet, Path: SCIMBasePath + "/Users/{id}", Tag: "SCIM", Summary: "Read a user", Auth: openapi.AuthUser,
+		Bare: true, ResponseType: scimContentType, Response: SCIMUserDTO{}, Error: SCIMErrorDTO{}},
+	{Method: http.MethodPut, Path: SCIMBasePath + "/Users/{id}", Tag: "SCIM", Summary: "Replace a user", Auth: openapi.AuthUser,
+		Request: SCIMUserDTO{}, Bare: true, ResponseType: scimContentType, Response: SCIMUserDTO{}, Error: SCIMErrorDTO{}},
+	{Method: http.MethodPatch, Path: SCIMBasePath + "/Users/{id}", Tag: "SCIM", Summary: "Update or deactivate a user", Auth: openapi.AuthUser,
+		Request: SCIMPatchRequest{}, Bare: true, ResponseType: scimContentType, Response: SCIMUserDTO{}, Error: SCIMErrorDTO{}},
+	{Method: http.MethodDelete, Path: SCIMBasePath + "/Users/{id}", Tag: "SCIM", Summary: "Deprovision a user", Auth: openapi.AuthUser,
+		Status: http.StatusNoContent, Bare: true, Error: SCIMErrorDTO{}},
+	{Method: http.MethodGet, Path: SCIMBasePath + "/Groups", Tag: "SCIM", Summary: "List or filter groups", Auth: openapi.AuthUser,
+		Query: scimListParameters, Bare: true, ResponseType: scimContentType, Response: SCIMListResponseDTO{}, Error: SCIMErrorDTO{}},
+	{Method: http.MethodPost, Path: SCIMBasePath + "/Groups", Tag: "SCIM", Summary: "Create a group", Auth: openapi.AuthUser,
+		Request: SCIMGroupDTO{}, Status: http.StatusCreated, Bare: true, ResponseType: scimContentType, Response: SCIMGroupDTO{}, Error: SCIMErrorDTO{}},
+	{Method: http.MethodGet, Path: SCIMBasePath + "/Groups/{id}", Tag: "SCIM", Summary: "Read a group", Auth: openapi.AuthUser,
+		Bare: true, ResponseType: scimContentType, Response: SCIMGroupDTO{}, Error: SCIMErrorDTO{}},
+	{Method: http.MethodPut, Path: SCIMBasePath + "/Groups/{id}", Tag: "SCIM", Summary: "Replace a group", Auth: openapi.AuthUser,
+		Request: SCIMGroupDTO{}, Bare: true, ResponseType: scimContentType, Response: SCIMGroupDTO{}, Error: SCIMErrorDTO{}},
+	{Method: http.MethodPatch, Path: SCIMBasePath + "/Groups/{id}", Tag: "SCIM", Summary: "Update a group or its members", Auth: openapi.AuthUser,
+		Request: SCIMPatchRequest{}, Bare: true, ResponseType: scimContentType, Response: SCIMGroupDTO{}, Error: SCIMErrorDTO{}},
+	{Method: http.MethodDelete, Path: SCIMBasePath + "/Groups/{id}", Tag: "SCIM", Summary: "Delete a group", Auth: openapi.AuthUser,
+		Status: http.StatusNoContent, Bare: true, Error: SCIMErrorDTO{}},
+
+	// Organizations and teams
+	{Method: http.MethodGet, Path: "/orgs", Tag: "Organizations", Summary: "List your organizations", Auth: openapi.AuthUser,
+		Response: []OrgDTO{}},
+	{Method: http.MethodPost, Path: "/orgs", Tag: "Organizations", Summary: "Create an organization", Auth: openapi.AuthUser,
+		Request: CreateOrgRequest{}, Status: http.StatusCreated, Response: OrgDTO{}},
+	{Method: http.MethodGet, Path: "/orgs/{id}", Tag: "Organizations", Summary: "Show an organization and your role", Auth: openapi.AuthUser,
+		Response: OrgDTO{}},
+	{Method: http.MethodGet, Path: "/orgs/{id}/members", Tag: "Organizations", Summary: "List the members of an organization", Auth: openapi.AuthUser,
+		Response: []MemberDTO{}},
+	{Method: http.MethodPut, Path: "/orgs/{id}/members/{user_id}", Tag: "Organizations", Summary: "Change a member's role", Auth: openapi.AuthUser,
+		Request: ChangeRoleRequest{}, Response: MemberDTO{}},
+	{Method: http.MethodDelete, Path: "/orgs/{id}/members/{user_id}", Tag: "Organizations", Summary: "Remove a member", Auth: openapi.AuthUser},
+	{Method: http.MethodGet, Path: "/orgs/{id}/invitations", Tag: "Organizations", Summary: "List invitations", Auth: openapi.AuthUser,
+		Response: []InvitationDTO{}},
+	{Method: http.MethodPost, Path: "/orgs/{id}/invitations", Tag: "Organizations", Summary: "Invite an email address", Auth: openapi.AuthUser,
+		Request: InviteRequest{}, Status: http.StatusCreated, Response: InvitationDTO{}},
+	{Method: http.MethodDelete, Path: "/orgs/{id}/invitations/{invitation_id}", Tag: "Organizations", Summary: "Revoke an invitation", Auth: openapi.AuthUser},
+	{Method: http.MethodGet, Path: "/orgs/{id}/teams", Tag: "Organizations", Summary: "List teams", Auth: openapi.AuthUser,
+		Response: []TeamDTO{}},
+	{Method: http.MethodPost, Path: "/orgs/{id}/teams", Tag: "Organizations", Summary: "Create a team", Auth: openapi.AuthUser,
+		Request: CreateOrgRequest{}, Status: http.StatusCreated, Response: TeamDTO{}},
+	{Method: http.MethodDelete, Path: "/orgs/{id}/teams/{team_id}", Tag: "Organizations", Summary: "Delete a team", Auth: openapi.AuthUser},
+	{Method: http.MethodPut, Path: "/orgs/{id}/teams/{team_id}/members/{user_id}", Tag: "Organizations", Summary: "Add a team member", Auth: openapi.AuthUser,
+		Response: TeamDTO{}},
+	{Method: http.MethodDelete, Path: "/orgs/{id}/teams/{team_id}/members/{user_id}", Tag: "Organizations", Summary: "Remove a team member", Auth: openapi.AuthUser,
+		Response: TeamDTO{}},
+	{Method: http.MethodPost, Path: "/invitations/accept", Tag: "Organizations", Summary: "Join the organization of an invitation sent to your email", Auth: openapi.AuthUser,
+		Request: InvitationTokenRequest{}, Response: OrgDTO{}},
+	{Method: http.MethodPost, Path: "/invitations/register", Tag: "Organizations", Summary: "Register with an invitation and join its organization",
+		Request: RegisterInvitedRequest{}, Status: http.StatusCreated, Response: UserResponseDTO{}},
+
+	// Fine-grained authorization
+	{Method: http.MethodPost, Path: "/authz/check", Tag: "Authorization", Summary: "Check whether a subject may perform an action on a resource", Auth: openapi.AuthUser,
+		Request: CheckRequest{}, Response: CheckResultDTO{}},
+	{Method: http.MethodPost, Path: "/authz/explain", Tag: "Authorization", Summary: "Show how a permission check was decided (admin)", Auth: openapi.AuthUser,
+		Request: CheckRequest{}, Response: ExplanationDTO{}},
+	{Method: http.MethodGet, Path: "/authz/tuples", Tag: "Authorization", Summary: "Read relation tuples (admin)", Auth: openapi.AuthUser,
+		Query: []openapi.Parameter{{Name: "object", Description: "Object type or type:id"}, {Name: "relation"}, {Name: "subject"}}, Response: []string{}},
+	{Method: http.MethodPost, Path: "/authz/tuples", Tag: "Authorization", Summary: "Write relation tuples (admin)", Auth: openapi.AuthUser,
+		Request: TuplesRequest{}, Status: http.StatusCreated, Response: []string{}},
+	{Method: http.MethodDelete, Path: "/authz/tuples", Tag: "Authorization", Summary: "Delete relation tuples (admin)", Auth: openapi.AuthUser,
+		Request: TuplesRequest{}},
+
+	// Webhooks
+	{Method: http.MethodGet, Path: "/webhooks", Tag: "Webhooks", Summary: "List webhook endpoints (admin)", Auth: openapi.AuthUser,
+		Response: []WebhookEndpointDTO{}},
+	{Method: http.MethodPost, Path: "/webhooks", Tag: "Webhooks", Summary: "Register a webhook endpoint (admin)", Auth: openapi.AuthUser,
+		Request: WebhookEndpointRequest{}, Status: http.StatusCreated, Response: WebhookEndpointDTO{}},
+	{Method: http.MethodGet, Path: "/webhooks/{id}", Tag: "Webhooks", Summary: "Show a webhook endpoint (admin)", Auth: openapi.AuthUser,
+		Response: WebhookEndpointDTO{}},
+	{Method: http.MethodPut, Path: "/webhooks/{id}", Tag: "Webhooks", Summary: "Update a webhook endpoint (admin)", Auth: openapi.AuthUser,
+		Request: WebhookEndpointRequest{}, Response: WebhookEndpointDTO{}},
+	{Method: http.MethodDelete, Path: "/webhooks/{id}", Tag: "Webhooks", Summary: "Delete a webhook endpoint (admin)", Auth: openapi.AuthUser},
+	{Method: http.MethodPost, Path: "/webhooks/{id}/secret", Tag: "Webhooks", Summary: "Rotate the signing secret of an endpoint (admin)", Auth: openapi.AuthUser,
+		Response: WebhookEndpointDTO{}},
+	{Method: http.MethodGet, Path: "/webhooks/{id}/deliveries", Tag: "Webhooks", Summary: "List the deliveries to an endpoint (admin)", Auth: openapi.AuthUser,
+		Query: []openapi.Parameter{{Name: "status", Description: "Delivery status, dead for the dead-letter queue"}}, Response: []WebhookDeliveryDTO{}},
+	{Method: http.MethodGet, Path: "/webhook-deliveries", Tag: "Webhooks", Summary: "List deliveries (admin)", Auth: openapi.AuthUser,
+		Query:    []openapi.Parameter{{Name: "endpoint_id"}, {Name: "status", Description: "Delivery status, dead for the dead-letter queue"}},
+		Response: []WebhookDeliveryDTO{}},
+	{Method: http.MethodGet, Path: "/webhook-deliveries/{id}", Tag: "Webhooks", Summary: "Show a delivery with its payload and attempts (admin)", Auth: openapi.AuthUser,
+		Response: WebhookDeliveryDTO{}},
+	{Method: http.MethodPost, Path: "/webhook-deliveries/{id}/redeliver", Tag: "Webhooks", Summary: "Send a delivery again (admin)", Auth: openapi.AuthUser,
+		Status: http.StatusAccepted, Response: WebhookDeliveryDTO{}},
+
+	// Token introspection for services
+	{Method: http.MethodPost, Path: "/introspect", Tag: "Introspection", Summary: "Check any token issued here and return its claims", Auth: openapi.AuthUser,
+		Description: "Callers must be services holding the introspect scope",
+		Request:     IntrospectRequest{}, Response: IntrospectionResultDTO{}},
+}
@@ -0,0 +1,536 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/org"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// OrgHandler handles HTTP requests for organizations, their members, teams and invitations
+type OrgHandler struct {
+	orgUseCase *usecase.OrgUseCase
+}
+
+// NewOrgHandler creates a new organization handler
+func NewOrgHandler(orgUseCase *usecase.OrgUseCase) *OrgHandler {
+	return &OrgHandler{
+		orgUseCase: orgUseCase,
+	}
+}
+
+// CreateOrgRequest represents the organization and team creation request data
+type CreateOrgRequest struct {
+	Name string `json:"name"`
+}
+
+// ChangeRoleRequest represents the member role change request data
+type ChangeRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// InviteRequest represents the invitation request data
+type InviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// InvitationTokenRequest represents the invitation acceptance request data
+type InvitationTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// RegisterInvitedRequest represents the registration request data of an invitee. The email
+// is the one the invitation was sent to.
+type RegisterInvitedRequest struct {
+	Token     string `json:"token"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Password  string `json:"password"`
+}
+
+// OrgDTO represents an organization as seen by the caller
+type OrgDTO struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MemberDTO represents a member of an organization
+type MemberDTO struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Role      string    `json:"role"`
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+// TeamDTO represents a team of an organization
+type TeamDTO struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	MemberIDs []string  `json:"member_ids"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InvitationDTO represents an invitation to an organization
+type InvitationDTO struct {
+	ID         string     `json:"id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	InvitedBy  string     `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Orgs handles GET (list the caller's organizations) and POST (create) requests on the
+// organization collection
+func (h *OrgHandler) Orgs(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		orgs, err := h.orgUseCase.ListOrgs(principal)
+		if err != nil {
+			sendError(w, orgErrorStatus(err), err)
+			return
+		}
+
+		data := make([]OrgDTO, 0, len(orgs))
+		for _, o := range orgs {
+			data = append(data, newOrgDTO(o))
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Organizations retrieved successfully",
+			Data:    data,
+		})
+
+	case http.MethodPost:
+		var req CreateOrgRequest
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+
+		created, err := h.orgUseCase.CreateOrg(principal, req.Name)
+		if err != nil {
+			sendError(w, orgErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusCreated, APIResponse{
+			Status:  "success",
+			Message: "Organization created successfully",
+			Data:    newOrgDTO(*created),
+		})
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// Org handles the resources of a single organization:
+//
+//	GET            /orgs/{id}
+//	GET            /orgs/{id}/members
+//	PUT, DELETE    /orgs/{id}/members/{user_id}
+//	GET, POST      /orgs/{id}/invitations
+//	DELETE         /orgs/{id}/invitations/{invitation_id}
+//	GET, POST      /orgs/{id}/teams
+//	DELETE         /orgs/{id}/teams/{team_id}
+//	PUT, DELETE    /orgs/{id}/teams/{team_id}/members/{user_id}
+func (h *OrgHandler) Org(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	orgID, segments := orgPath(r.URL.Path)
+	switch {
+	case orgID == "":
+		sendNotFound(w)
+	case len(segments) == 0:
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		o, err := h.orgUseCase.GetOrg(principal, orgID)
+		if err != nil {
+			sendError(w, orgErrorStatus(err), err)
+			return
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Organization retrieved successfully",
+			Data:    newOrgDTO(*o),
+		})
+	case segments[0] == "members" && len(segments) == 1:
+		h.members(w, r, principal, orgID)
+	case segments[0] == "members" && len(segments) == 2:
+		h.member(w, r, principal, orgID, segments[1])
+	case segments[0] == "invitations" && len(segments) == 1:
+		h.invitations(w, r, principal, orgID)
+	case segments[0] == "invitations" && len(segments) == 2:
+		h.invitation(w, r, principal, orgID, segments[1])
+	case segments[0] == "teams" && len(segments) == 1:
+		h.teams(w, r, principal, orgID)
+	case segments[0] == "teams" && len(segments) == 2:
+		h.team(w, r, principal, orgID, segments[1])
+	case segments[0] == "teams" && len(segments) == 4 && segments[2] == "members":
+		h.teamMember(w, r, principal, orgID, segments[1], segments[3])
+	default:
+		sendNotFound(w)
+	}
+}
+
+// AcceptInvitation handles POST requests that add the caller to the organization of an invitation
+func (h *OrgHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	var req InvitationTokenRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	o, err := h.orgUseCase.AcceptInvitation(principal, req.Token)
+	if err != nil {
+		sendError(w, orgErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Invitation accepted",
+		Data:    newOrgDTO(*o),
+	})
+}
+
+// RegisterInvited handles POST requests that register an invitee without an account and add
+// them to the organization of the invitation
+func (h *OrgHandler) RegisterInvited(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req RegisterInvitedRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	userResp, err := h.orgUseCase.RegisterInvited(common.TenantFromContext(r.Context()), req.Token, req.FirstName, req.LastName, req.Password)
+	if err != nil {
+		sendError(w, orgErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "User registered and invitation accepted",
+		Data:    newUserResponseDTO(*userResp),
+	})
+}
+
+// members handles GET requests on the member collection
+func (h *OrgHandler) members(w http.ResponseWriter, r *http.Request, principal *auth.Principal, orgID string) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	members, err := h.orgUseCase.ListMembers(principal, orgID)
+	if err != nil {
+		sendError(w, orgErrorStatus(err), err)
+		return
+	}
+
+	data := make([]MemberDTO, 0, len(members))
+	for _, m := range members {
+		data = append(data, newMemberDTO(m))
+	}
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Members retrieved successfully",
+		Data:    data,
+	})
+}
+
+// member handles PUT (change role) and DELETE (remove) requests on a member
+func (h *OrgHandler) member(w http.ResponseWriter, r *http.Request, principal *auth.Principal, orgID, userID string) {
+	switch r.Method {
+	case http.MethodPut:
+		var req ChangeRoleRequest
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+
+		m, err := h.orgUseCase.ChangeRole(principal, orgID, userID, org.Role(req.Role))
+		if err != nil {
+			sendError(w, orgErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Member role changed successfully",
+			Data:    newMemberDTO(*m),
+		})
+
+	case http.MethodDelete:
+		if err := h.orgUseCase.RemoveMember(principal, orgID, userID); err != nil {
+			sendError(w, orgErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Member removed successfully",
+		})
+
+	default:
+		requireMethod(w, r, http.MethodPut)
+	}
+}
+
+// invitations handles GET (list) and POST (invite) requests on the invitation collection
+func (h *OrgHandler) invitations(w http.ResponseWriter, r *http.Request, principal *auth.Principal, orgID string) {
+	switch r.Method {
+	case http.MethodGet:
+		invitations, err := h.orgUseCase.ListInvitations(principal, orgID)
+		if err != nil {
+			sendError(w, orgErrorStatus(err), err)
+			return
+		}
+
+		data := make([]InvitationDTO, 0, len(invitations))
+		for _, inv := range invitations {
+			data = append(data, newInvitationDTO(inv))
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Invitations retrieved successfully",
+			Data:    data,
+		})
+
+	case http.MethodPost:
+		var req InviteRequest
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+		if req.Role == "" {
+			req.Role = string(org.RoleMember)
+		}
+
+		inv, err := h.orgUseCase.Invite(principal, orgID, usecase.InviteInput{Email: req.Email, Role: org.Role(req.Role)})
+		if err != nil {
+			sendError(w, orgErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusCreated, APIResponse{
+			Status:  "success",
+			Message: "Invitation sent",
+			Data:    newInvitationDTO(*inv),
+		})
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// invitation handles DELETE (revoke) requests on an invitation
+func (h *OrgHandler) invitation(w http.ResponseWriter, r *http.Request, principal *auth.Principal, orgID, invitationID string) {
+	if !requireMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	if err := h.orgUseCase.RevokeInvitation(principal, orgID, invitationID); err != nil {
+		sendError(w, orgErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Invitation revoked successfully",
+	})
+}
+
+// teams handles GET (list) and POST (create) requests on the team collection
+func (h *OrgHandler) teams(w http.ResponseWriter, r *http.Request, principal *auth.Principal, orgID string) {
+	switch r.Method {
+	case http.MethodGet:
+		teams, err := h.orgUseCase.ListTeams(principal, orgID)
+		if err != nil {
+			sendError(w, orgErrorStatus(err), err)
+			return
+		}
+
+		data := make([]TeamDTO, 0, len(teams))
+		for _, t := range teams {
+			data = append(data, newTeamDTO(t))
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Teams retrieved successfully",
+			Data:    data,
+		})
+
+	case http.MethodPost:
+		var req CreateOrgRequest
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+
+		t, err := h.orgUseCase.CreateTeam(principal, orgID, req.Name)
+		if err != nil {
+			sendError(w, orgErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusCreated, APIResponse{
+			Status:  "success",
+			Message: "Team created successfully",
+			Data:    newTeamDTO(*t),
+		})
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// team handles DELETE requests on a team
+func (h *OrgHandler) team(w http.ResponseWriter, r *http.Request, principal *auth.Principal, orgID, teamID string) {
+	if !requireMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	if err := h.orgUseCase.DeleteTeam(principal, orgID, teamID); err != nil {
+		sendError(w, orgErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Team deleted successfully",
+	})
+}
+
+// teamMember handles PUT (add) and DELETE (remove) requests on a team member
+func (h *OrgHandler) teamMember(w http.ResponseWriter, r *http.Request, principal *auth.Principal, orgID, teamID, userID string) {
+	var (
+		t       *usecase.TeamResponse
+		err     error
+		message string
+	)
+	switch r.Method {
+	case http.MethodPut:
+		t, err = h.orgUseCase.AddTeamMember(principal, orgID, teamID, userID)
+		message = "Team member added successfully"
+	case http.MethodDelete:
+		t, err = h.orgUseCase.RemoveTeamMember(principal, orgID, teamID, userID)
+		message = "Team member removed successfully"
+	default:
+		requireMethod(w, r, http.MethodPut)
+		return
+	}
+	if err != nil {
+		sendError(w, orgErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: message,
+		Data:    newTeamDTO(*t),
+	})
+}
+
+// orgPath splits a path below /orgs/ into the organization ID and the segments after it, so
+// /api/orgs/1/teams/2 gives "1" and [teams 2]
+func orgPath(p string) (string, []string) {
+	return subtreePath(p, "/orgs/")
+}
+
+// sendNotFound sends a 404 response for paths that name no resource
+func sendNotFound(w http.ResponseWriter) {
+	SendJSONResponse(w, http.StatusNotFound, APIResponse{
+		Status: "error",
+		Error:  "Not found",
+	})
+}
+
+// orgErrorStatus maps organization use case errors to HTTP status codes
+func orgErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrForbidden), errors.Is(err, usecase.ErrInvitationEmailMismatch):
+		return http.StatusForbidden
+	case errors.Is(err, usecase.ErrOrgNotFound), errors.Is(err, usecase.ErrMemberNotFound),
+		errors.Is(err, usecase.ErrTeamNotFound), errors.Is(err, usecase.ErrInvitationNotFound),
+		errors.Is(err, usecase.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, usecase.ErrAlreadyMember), errors.Is(err, usecase.ErrLastOwner),
+		errors.Is(err, org.ErrDuplicateTeamName), errors.Is(err, usecase.ErrUserExists):
+		return http.StatusConflict
+	case errors.Is(err, usecase.ErrInvalidInvitation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func newOrgDTO(o usecase.OrgResponse) OrgDTO {
+	return OrgDTO{
+		ID:        o.ID,
+		Name:      o.Name,
+		Role:      string(o.Role),
+		CreatedAt: o.CreatedAt,
+	}
+}
+
+func newMemberDTO(m usecase.MemberResponse) MemberDTO {
+	return MemberDTO{
+		UserID:    m.UserID,
+		Email:     m.Email,
+		FirstName: m.FirstName,
+		LastName:  m.LastName,
+		Role:      string(m.Role),
+		JoinedAt:  m.JoinedAt,
+	}
+}
+
+func newTeamDTO(t usecase.TeamResponse) TeamDTO {
+	return TeamDTO{
+		ID:        t.ID,
+		Name:      t.Name,
+		MemberIDs: t.MemberIDs,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func newInvitationDTO(inv usecase.InvitationResponse) InvitationDTO {
+	return InvitationDTO{
+		ID:         inv.ID,
+		Email:      inv.Email,
+		Role:       string(inv.Role),
+		InvitedBy:  inv.InvitedBy,
+		ExpiresAt:  inv.ExpiresAt,
+		AcceptedAt: inv.AcceptedAt,
+		CreatedAt:  inv.CreatedAt,
+	}
+}
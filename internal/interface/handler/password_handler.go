@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// PasswordHandler handles HTTP requests for password change and reset
+type PasswordHandler struct {
+	userUseCase *usecase.UserUseCase
+}
+
+// NewPasswordHandler creates a new password handler
+func NewPasswordHandler(userUseCase *usecase.UserUseCase) *PasswordHandler {
+	return &PasswordHandler{
+		userUseCase: userUseCase,
+	}
+}
+
+// ChangePasswordRequest represents the password change request data
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ForgotPasswordRequest represents the password reset request data
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest represents the data needed to complete a password reset
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// forgotPasswordMessage is returned whether or not the account exists
+const forgotPasswordMessage = "If an account exists for this email, a reset link has been sent"
+
+// ChangePassword handles password change requests for the authenticated user
+func (h *PasswordHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	claims, ok := r.Context().Value(common.UserClaimsKey).(*auth.Claims)
+	if !ok {
+		SendJSONResponse(w, http.StatusUnauthorized, APIResponse{
+			Status: "error",
+			Error:  "Unauthorized",
+		})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	err := h.userUseCase.ChangePassword(claims.Tenant(), claims.Email, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		sendError(w, passwordErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Password changed successfully",
+	})
+}
+
+// ForgotPassword handles requests to email a password reset token
+func (h *PasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req ForgotPasswordRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.userUseCase.RequestPasswordReset(common.TenantFromContext(r.Context()), req.Email); err != nil {
+		sendError(w, passwordErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusAccepted, APIResponse{
+		Status:  "success",
+		Message: forgotPasswordMessage,
+	})
+}
+
+// ResetPassword handles requests to set a new password with a reset token
+func (h *PasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req ResetPasswordRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.userUseCase.ResetPassword(common.TenantFromContext(r.Context()), req.Token, req.NewPassword); err != nil {
+		sendError(w, passwordErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Password reset successfully",
+	})
+}
+
+// passwordErrorStatus maps password use case errors to HTTP status codes
+func passwordErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	case errors.Is(err, usecase.ErrPasswordResetDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, usecase.ErrInvalidResetToken):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,582 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// SCIMBasePath is the root of the SCIM endpoints
+const SCIMBasePath = "/scim/v2"
+
+// SCIM schema URNs, see RFC 7643 and RFC 7644
+const (
+	SCIMUserSchema        = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMGroupSchema       = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMListSchema        = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMPatchSchema       = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMErrorSchema       = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCIMConfigSchema      = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimContentType       = "application/scim+json"
+	scimExcludeAttributes = "excludedAttributes"
+)
+
+// SCIMHandler serves the SCIM 2.0 Users and Groups endpoints for provisioning clients
+type SCIMHandler struct {
+	scimUseCase *usecase.SCIMUseCase
+	// baseURL is the absolute URL of the SCIM root, used for resource locations
+	baseURL string
+}
+
+// NewSCIMHandler creates a new SCIM handler serving resources below baseURL
+func NewSCIMHandler(scimUseCase *usecase.SCIMUseCase, baseURL string) *SCIMHandler {
+	return &SCIMHandler{
+		scimUseCase: scimUseCase,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// SCIMMetaDTO holds the resource metadata of a SCIM resource
+type SCIMMetaDTO struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+	Version      string    `json:"version"`
+}
+
+// SCIMNameDTO is the name of a SCIM user
+type SCIMNameDTO struct {
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	Formatted  string `json:"formatted,omitempty"`
+}
+
+// SCIMEmailDTO is an email address of a SCIM user
+type SCIMEmailDTO struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMReferenceDTO references another resource, such as a group member or a user's group
+type SCIMReferenceDTO struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// SCIMUserDTO is a SCIM user resource
+type SCIMUserDTO struct {
+	Schemas     []string           `json:"schemas"`
+	ID          string             `json:"id,omitempty"`
+	ExternalID  string             `json:"externalId,omitempty"`
+	UserName    string             `json:"userName"`
+	Name        *SCIMNameDTO       `json:"name,omitempty"`
+	DisplayName string             `json:"displayName,omitempty"`
+	Emails      []SCIMEmailDTO     `json:"emails,omitempty"`
+	Active      *bool              `json:"active,omitempty"`
+	Groups      []SCIMReferenceDTO `json:"groups,omitempty"`
+	Meta        *SCIMMetaDTO       `json:"meta,omitempty"`
+}
+
+// SCIMGroupDTO is a SCIM group resource
+type SCIMGroupDTO struct {
+	Schemas     []string           `json:"schemas"`
+	ID          string             `json:"id,omitempty"`
+	ExternalID  string             `json:"externalId,omitempty"`
+	DisplayName string             `json:"displayName"`
+	Members     []SCIMReferenceDTO `json:"members,omitempty"`
+	Meta        *SCIMMetaDTO       `json:"meta,omitempty"`
+}
+
+// SCIMListResponseDTO is a page of SCIM resources
+type SCIMListResponseDTO struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// SCIMPatchRequest is a SCIM PATCH request body
+type SCIMPatchRequest struct {
+	Schemas    []string `json:"schemas"`
+	Operations []struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	} `json:"Operations"`
+}
+
+// SCIMErrorDTO is a SCIM error response. The status is a string, as RFC 7644 requires.
+type SCIMErrorDTO struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// Users handles GET (list) and POST (create) requests on the user collection
+func (h *SCIMHandler) Users(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := h.scimUseCase.ListUsers(principal, scimQuery(r))
+		if err != nil {
+			sendSCIMError(w, err)
+			return
+		}
+
+		resources := make([]SCIMUserDTO, 0, len(list.Resources))
+		for i := range list.Resources {
+			resources = append(resources, h.newSCIMUserDTO(&list.Resources[i], excludes(r, "groups")))
+		}
+		writeSCIM(w, http.StatusOK, SCIMListResponseDTO{
+			Schemas:      []string{SCIMListSchema},
+			TotalResults: list.TotalResults,
+			StartIndex:   list.StartIndex,
+			ItemsPerPage: len(resources),
+			Resources:    resources,
+		})
+
+	case http.MethodPost:
+		var req SCIMUserDTO
+		if !decodeSCIMRequest(w, r, &req) {
+			return
+		}
+
+		created, err := h.scimUseCase.CreateUser(principal, newSCIMUserInput(req))
+		if err != nil {
+			sendSCIMError(w, err)
+			return
+		}
+		h.sendUser(w, http.StatusCreated, created)
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// User handles GET, PUT, PATCH and DELETE requests on a single user. DELETE deactivates the
+// user instead of removing it.
+func (h *SCIMHandler) User(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	id := path.Base(r.URL.Path)
+	ifMatch := r.Header.Get("If-Match")
+
+	var result *usecase.SCIMUser
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		result, err = h.scimUseCase.GetUser(principal, id)
+		if err == nil && notModified(r, result.Version) {
+			w.Header().Set("ETag", result.Version)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+	case http.MethodPut:
+		var req SCIMUserDTO
+		if !decodeSCIMRequest(w, r, &req) {
+			return
+		}
+		result, err = h.scimUseCase.ReplaceUser(principal, id, ifMatch, newSCIMUserInput(req))
+
+	case http.MethodPatch:
+		ops, ok := decodeSCIMPatch(w, r)
+		if !ok {
+			return
+		}
+		result, err = h.scimUseCase.PatchUser(principal, id, ifMatch, ops)
+
+	case http.MethodDelete:
+		if err := h.scimUseCase.DeleteUser(principal, id, ifMatch); err != nil {
+			sendSCIMError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+		return
+	}
+
+	if err != nil {
+		sendSCIMError(w, err)
+		return
+	}
+	h.sendUser(w, http.StatusOK, result)
+}
+
+// Groups handles GET (list) and POST (create) requests on the group collection
+func (h *SCIMHandler) Groups(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := h.scimUseCase.ListGroups(principal, scimQuery(r))
+		if err != nil {
+			sendSCIMError(w, err)
+			return
+		}
+
+		resources := make([]SCIMGroupDTO, 0, len(list.Resources))
+		for i := range list.Resources {
+			resources = append(resources, h.newSCIMGroupDTO(&list.Resources[i], excludes(r, "members")))
+		}
+		writeSCIM(w, http.StatusOK, SCIMListResponseDTO{
+			Schemas:      []string{SCIMListSchema},
+			TotalResults: list.TotalResults,
+			StartIndex:   list.StartIndex,
+			ItemsPerPage: len(resources),
+			Resources:    resources,
+		})
+
+	case http.MethodPost:
+		var req SCIMGroupDTO
+		if !decodeSCIMRequest(w, r, &req) {
+			return
+		}
+
+		created, err := h.scimUseCase.CreateGroup(principal, newSCIMGroupInput(req))
+		if err != nil {
+			sendSCIMError(w, err)
+			return
+		}
+		h.sendGroup(w, r, http.StatusCreated, created)
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// Group handles GET, PUT, PATCH and DELETE requests on a single group
+func (h *SCIMHandler) Group(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	id := path.Base(r.URL.Path)
+	ifMatch := r.Header.Get("If-Match")
+
+	var result *usecase.SCIMGroup
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		result, err = h.scimUseCase.GetGroup(principal, id)
+		if err == nil && notModified(r, result.Version) {
+			w.Header().Set("ETag", result.Version)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+	case http.MethodPut:
+		var req SCIMGroupDTO
+		if !decodeSCIMRequest(w, r, &req) {
+			return
+		}
+		result, err = h.scimUseCase.ReplaceGroup(principal, id, ifMatch, newSCIMGroupInput(req))
+
+	case http.MethodPatch:
+		ops, ok := decodeSCIMPatch(w, r)
+		if !ok {
+			return
+		}
+		result, err = h.scimUseCase.PatchGroup(principal, id, ifMatch, ops)
+
+	case http.MethodDelete:
+		if err := h.scimUseCase.DeleteGroup(principal, id, ifMatch); err != nil {
+			sendSCIMError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+		return
+	}
+
+	if err != nil {
+		sendSCIMError(w, err)
+		return
+	}
+	h.sendGroup(w, r, http.StatusOK, result)
+}
+
+// ServiceProviderConfig describes the supported SCIM features so clients can adapt to them
+func (h *SCIMHandler) ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	supported := func(ok bool) map[string]bool { return map[string]bool{"supported": ok} }
+	writeSCIM(w, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{SCIMConfigSchema},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": usecase.SCIMMaxCount},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(true),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "An API key with the " + usecase.ScopeSCIM + " scope, or an OAuth access token",
+			"primary":     true,
+		}},
+		"meta": map[string]string{"resourceType": "ServiceProviderConfig", "location": h.baseURL + "/ServiceProviderConfig"},
+	})
+}
+
+func (h *SCIMHandler) sendUser(w http.ResponseWriter, status int, u *usecase.SCIMUser) {
+	dto := h.newSCIMUserDTO(u, false)
+	w.Header().Set("ETag", u.Version)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", dto.Meta.Location)
+	}
+	writeSCIM(w, status, dto)
+}
+
+func (h *SCIMHandler) sendGroup(w http.ResponseWriter, r *http.Request, status int, g *usecase.SCIMGroup) {
+	dto := h.newSCIMGroupDTO(g, excludes(r, "members"))
+	w.Header().Set("ETag", g.Version)
+	if status == http.StatusCreated {
+		w.Header().Set("Location", dto.Meta.Location)
+	}
+	writeSCIM(w, status, dto)
+}
+
+func (h *SCIMHandler) newSCIMUserDTO(u *usecase.SCIMUser, withoutGroups bool) SCIMUserDTO {
+	active := u.Active
+	dto := SCIMUserDTO{
+		Schemas:    []string{SCIMUserSchema},
+		ID:         u.ID,
+		ExternalID: u.ExternalID,
+		UserName:   u.UserName,
+		Name: &SCIMNameDTO{
+			GivenName:  u.GivenName,
+			FamilyName: u.FamilyName,
+			Formatted:  strings.TrimSpace(u.GivenName + " " + u.FamilyName),
+		},
+		DisplayName: strings.TrimSpace(u.GivenName + " " + u.FamilyName),
+		Emails:      []SCIMEmailDTO{{Value: u.UserName, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &SCIMMetaDTO{
+			ResourceType: "User",
+			Created:      u.Created,
+			LastModified: u.Modified,
+			Location:     h.baseURL + "/Users/" + u.ID,
+			Version:      u.Version,
+		},
+	}
+	if !withoutGroups {
+		for _, g := range u.Groups {
+			dto.Groups = append(dto.Groups, SCIMReferenceDTO{Value: g.ID, Display: g.DisplayName, Ref: h.baseURL + "/Groups/" + g.ID})
+		}
+	}
+	return dto
+}
+
+func (h *SCIMHandler) newSCIMGroupDTO(g *usecase.SCIMGroup, withoutMembers bool) SCIMGroupDTO {
+	dto := SCIMGroupDTO{
+		Schemas:     []string{SCIMGroupSchema},
+		ID:          g.ID,
+		ExternalID:  g.ExternalID,
+		DisplayName: g.DisplayName,
+		Meta: &SCIMMetaDTO{
+			ResourceType: "Group",
+			Created:      g.Created,
+			LastModified: g.Modified,
+			Location:     h.baseURL + "/Groups/" + g.ID,
+			Version:      g.Version,
+		},
+	}
+	if !withoutMembers {
+		for _, m := range g.Members {
+			dto.Members = append(dto.Members, SCIMReferenceDTO{Value: m.ID, Display: m.Display, Ref: h.baseURL + "/Users/" + m.ID})
+		}
+	}
+	return dto
+}
+
+// newSCIMUserInput converts a user resource to use case input. Clients that only send emails
+// get the primary email as their userName.
+func newSCIMUserInput(req SCIMUserDTO) usecase.SCIMUserInput {
+	input := usecase.SCIMUserInput{
+		UserName:    req.UserName,
+		ExternalID:  req.ExternalID,
+		DisplayName: req.DisplayName,
+		Active:      req.Active,
+	}
+	if req.Name != nil {
+		input.GivenName = req.Name.GivenName
+		input.FamilyName = req.Name.FamilyName
+		if input.DisplayName == "" {
+			input.DisplayName = req.Name.Formatted
+		}
+	}
+	if input.UserName == "" {
+		for _, email := range req.Emails {
+			if input.UserName == "" || email.Primary {
+				input.UserName = email.Value
+			}
+		}
+	}
+	return input
+}
+
+func newSCIMGroupInput(req SCIMGroupDTO) usecase.SCIMGroupInput {
+	input := usecase.SCIMGroupInput{
+		DisplayName: req.DisplayName,
+		ExternalID:  req.ExternalID,
+	}
+	for _, m := range req.Members {
+		input.MemberIDs = append(input.MemberIDs, m.Value)
+	}
+	return input
+}
+
+// scimQuery reads the filter and pagination parameters of a list request
+func scimQuery(r *http.Request) usecase.SCIMQuery {
+	params := r.URL.Query()
+	query := usecase.SCIMQuery{
+		Filter:     params.Get("filter"),
+		StartIndex: 1,
+		Count:      usecase.SCIMDefaultCount,
+	}
+	if n, err := strconv.Atoi(params.Get("startIndex")); err == nil {
+		query.StartIndex = n
+	}
+	if n, err := strconv.Atoi(params.Get("count")); err == nil {
+		query.Count = n
+	}
+	return query
+}
+
+// excludes reports whether the request asks to leave an attribute out of the response,
+// as clients do for large member lists
+func excludes(r *http.Request, attr string) bool {
+	for _, excluded := range strings.Split(r.URL.Query().Get(scimExcludeAttributes), ",") {
+		if strings.EqualFold(strings.TrimSpace(excluded), attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether an If-None-Match header matches the current version
+func notModified(r *http.Request, version string) bool {
+	return usecase.MatchesSCIMVersion(r.Header.Get("If-None-Match"), version)
+}
+
+// decodeSCIMRequest decodes a SCIM request body into v, sending a SCIM error on failure
+func decodeSCIMRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		log.Printf("Error decoding SCIM request: %v", err)
+		sendSCIMError(w, &usecase.SCIMError{Type: usecase.SCIMInvalidSyntax, Detail: "request body is not valid JSON"})
+		return false
+	}
+	return true
+}
+
+// decodeSCIMPatch decodes the operations of a PATCH request
+func decodeSCIMPatch(w http.ResponseWriter, r *http.Request) ([]usecase.SCIMPatchOperation, bool) {
+	var req SCIMPatchRequest
+	if !decodeSCIMRequest(w, r, &req) {
+		return nil, false
+	}
+	if len(req.Operations) == 0 {
+		sendSCIMError(w, &usecase.SCIMError{Type: usecase.SCIMInvalidSyntax, Detail: "Operations is required"})
+		return nil, false
+	}
+
+	ops := make([]usecase.SCIMPatchOperation, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		ops = append(ops, usecase.SCIMPatchOperation{Op: op.Op, Path: op.Path, Value: op.Value})
+	}
+	return ops, true
+}
+
+// sendSCIMError sends an error in the SCIM error format
+func sendSCIMError(w http.ResponseWriter, err error) {
+	status := scimErrorStatus(err)
+	dto := SCIMErrorDTO{
+		Schemas: []string{SCIMErrorSchema},
+		Status:  strconv.Itoa(status),
+		Detail:  err.Error(),
+	}
+
+	var scimErr *usecase.SCIMError
+	switch {
+	case errors.As(err, &scimErr):
+		dto.SCIMType = scimErr.Type
+		dto.Detail = scimErr.Detail
+	case errors.Is(err, usecase.ErrServiceBusy):
+		w.Header().Set("Retry-After", retryAfterSeconds)
+	case status == http.StatusInternalServerError:
+		log.Printf("SCIM request failed: %v", err)
+		dto.Detail = "internal server error"
+	}
+	writeSCIM(w, status, dto)
+}
+
+// scimErrorStatus maps SCIM use case errors to HTTP status codes
+func scimErrorStatus(err error) int {
+	var scimErr *usecase.SCIMError
+	switch {
+	case errors.As(err, &scimErr):
+		if scimErr.Type == usecase.SCIMUniqueness {
+			return http.StatusConflict
+		}
+		return http.StatusBadRequest
+	case errors.Is(err, usecase.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, usecase.ErrUserNotFound), errors.Is(err, usecase.ErrGroupNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, usecase.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, usecase.ErrServiceBusy):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeSCIM sends a SCIM response body
+func writeSCIM(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// SessionCookieConfig controls the attributes of the session cookie
+type SessionCookieConfig struct {
+	Name   string
+	Domain string
+	// Secure should only be disabled for local development over plain HTTP
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// DefaultSessionCookieConfig returns a Secure, SameSite=Lax cookie named "session"
+func DefaultSessionCookieConfig() SessionCookieConfig {
+	return SessionCookieConfig{
+		Name:     "session",
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// SessionHandler handles HTTP requests for cookie-based browser sessions
+type SessionHandler struct {
+	sessionUseCase *usecase.SessionUseCase
+	cookie         SessionCookieConfig
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(sessionUseCase *usecase.SessionUseCase, cookie SessionCookieConfig) *SessionHandler {
+	return &SessionHandler{
+		sessionUseCase: sessionUseCase,
+		cookie:         cookie,
+	}
+}
+
+// SessionDTO represents the session data that is returned in API responses
+type SessionDTO struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// SessionLoginResponseDTO represents the session login response data. The session token
+// itself is only sent in the HttpOnly cookie.
+type SessionLoginResponseDTO struct {
+	User      UserResponseDTO `json:"user"`
+	Session   SessionDTO      `json:"session"`
+	CSRFToken string          `json:"csrf_token"`
+}
+
+// CurrentSessionDTO represents the current session and its CSRF token
+type CurrentSessionDTO struct {
+	Session   SessionDTO `json:"session"`
+	CSRFToken string     `json:"csrf_token"`
+}
+
+// Login handles session login requests and sets the session cookie
+func (h *SessionHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req LoginRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	created, err := h.sessionUseCase.Login(common.TenantFromContext(r.Context()), req.Email, req.Password, usecase.SessionClient{
+		UserAgent: r.UserAgent(),
+		IPAddress: clientIP(r),
+	})
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	http.SetCookie(w, h.newCookie(created.Token, created.Session.ExpiresAt))
+
+	session := newSessionDTO(created.Session)
+	session.Current = true
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Login successful",
+		Data: SessionLoginResponseDTO{
+			User:      newUserResponseDTO(created.User),
+			Session:   session,
+			CSRFToken: created.CSRFToken,
+		},
+	})
+}
+
+// Logout ends the current session and clears the session cookie
+func (h *SessionHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	if err := h.sessionUseCase.Logout(principal); err != nil {
+		sendError(w, sessionErrorStatus(err), err)
+		return
+	}
+
+	cookie := h.newCookie("", time.Unix(0, 0))
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Logged out successfully",
+	})
+}
+
+// Current returns the current session and its CSRF token, so a reloaded page can recover the token
+func (h *SessionHandler) Current(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	current, err := h.sessionUseCase.Current(principal)
+	if err != nil {
+		sendError(w, sessionErrorStatus(err), err)
+		return
+	}
+
+	session := newSessionDTO(*current)
+	session.Current = true
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Session retrieved successfully",
+		Data: CurrentSessionDTO{
+			Session:   session,
+			CSRFToken: principal.CSRFToken,
+		},
+	})
+}
+
+// List returns the active sessions of the current user
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	sessions, err := h.sessionUseCase.List(principal)
+	if err != nil {
+		sendError(w, sessionErrorStatus(err), err)
+		return
+	}
+
+	data := make([]SessionDTO, 0, len(sessions))
+	for _, s := range sessions {
+		dto := newSessionDTO(s)
+		dto.Current = s.ID == principal.SessionID
+		data = append(data, dto)
+	}
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Sessions retrieved successfully",
+		Data:    data,
+	})
+}
+
+// Terminate ends one of the current user's sessions, identified by the last path segment
+func (h *SessionHandler) Terminate(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodDelete) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	if err := h.sessionUseCase.Terminate(principal, path.Base(r.URL.Path)); err != nil {
+		sendError(w, sessionErrorStatus(err), err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Session terminated successfully",
+	})
+}
+
+// newCookie creates the session cookie. It is never readable by scripts.
+func (h *SessionHandler) newCookie(value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     h.cookie.Name,
+		Value:    value,
+		Path:     "/",
+		Domain:   h.cookie.Domain,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   h.cookie.Secure,
+		SameSite: h.cookie.SameSite,
+	}
+}
+
+// sessionErrorStatus maps session use case errors to HTTP status codes
+func sessionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, usecase.ErrSessionNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// clientIP returns the address of the connecting client. Forwarded headers are not trusted.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// newSessionDTO converts a use case session response to its DTO
+func newSessionDTO(s usecase.SessionResponse) SessionDTO {
+	return SessionDTO{
+		ID:         s.ID,
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IPAddress,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+	}
+}
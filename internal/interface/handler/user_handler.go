@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
 	"github.com/lamboktulussimamora/gra-project/internal/usecase"
 )
 
@@ -47,8 +48,14 @@ type LoginRequest struct {
 
 // AuthResponseDTO represents the authentication response data
 type AuthResponseDTO struct {
-	User  UserResponseDTO `json:"user"`
-	Token string          `json:"token"`
+	User         UserResponseDTO `json:"user"`
+	Token        string          `json:"token"`
+	RefreshToken string          `json:"refresh_token,omitempty"`
+}
+
+// RefreshRequest represents the refresh request data
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // Register handles user registration requests
@@ -87,23 +94,14 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the use case
-	userResp, err := h.userUseCase.Register(req.FirstName, req.LastName, req.Email, req.Password)
+	userResp, err := h.userUseCase.Register(common.TenantFromContext(r.Context()), req.FirstName, req.LastName, req.Email, req.Password)
 	if err != nil {
-		SendJSONResponse(w, http.StatusBadRequest, APIResponse{
-			Status: "error",
-			Error:  err.Error(),
-		})
+		sendError(w, http.StatusBadRequest, err)
 		return
 	}
 
 	// Convert domain response to DTO
-	responseData := UserResponseDTO{
-		FirstName: userResp.FirstName,
-		LastName:  userResp.LastName,
-		Email:     userResp.Email,
-		CreatedAt: userResp.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: userResp.UpdatedAt.Format(time.RFC3339),
-	}
+	responseData := newUserResponseDTO(*userResp)
 
 	// Return success response
 	SendJSONResponse(w, http.StatusCreated, APIResponse{
@@ -149,26 +147,14 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the use case
-	authResp, err := h.userUseCase.Login(req.Email, req.Password)
+	authResp, err := h.userUseCase.Login(common.TenantFromContext(r.Context()), req.Email, req.Password)
 	if err != nil {
-		SendJSONResponse(w, http.StatusUnauthorized, APIResponse{
-			Status: "error",
-			Error:  err.Error(),
-		})
+		sendError(w, http.StatusUnauthorized, err)
 		return
 	}
 
 	// Convert domain response to DTO
-	responseData := AuthResponseDTO{
-		User: UserResponseDTO{
-			FirstName: authResp.User.FirstName,
-			LastName:  authResp.User.LastName,
-			Email:     authResp.User.Email,
-			CreatedAt: authResp.User.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: authResp.User.UpdatedAt.Format(time.RFC3339),
-		},
-		Token: authResp.Token,
-	}
+	responseData := newAuthResponseDTO(authResp)
 
 	// Return success response
 	SendJSONResponse(w, http.StatusOK, APIResponse{
@@ -177,3 +163,47 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		Data:    responseData,
 	})
 }
+
+// Refresh exchanges a refresh token for a new token and refresh token
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req RefreshRequest
+	if !decodeJSONRequest(w, r, &req) {
+		return
+	}
+
+	authResp, err := h.userUseCase.Refresh(common.TenantFromContext(r.Context()), req.RefreshToken)
+	if err != nil {
+		sendError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Token refreshed successfully",
+		Data:    newAuthResponseDTO(authResp),
+	})
+}
+
+// newUserResponseDTO converts a use case user response to its DTO
+func newUserResponseDTO(userResp usecase.UserResponse) UserResponseDTO {
+	return UserResponseDTO{
+		FirstName: userResp.FirstName,
+		LastName:  userResp.LastName,
+		Email:     userResp.Email,
+		CreatedAt: userResp.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: userResp.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// newAuthResponseDTO converts a use case auth response to its DTO
+func newAuthResponseDTO(authResp *usecase.AuthResponse) AuthResponseDTO {
+	return AuthResponseDTO{
+		User:         newUserResponseDTO(authResp.User),
+		Token:        authResp.Token,
+		RefreshToken: authResp.RefreshToken,
+	}
+}
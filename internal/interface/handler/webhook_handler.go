@@ -0,0 +1,371 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/webhook"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// WebhookHandler handles HTTP requests for webhook endpoints and their deliveries
+type WebhookHandler struct {
+	webhookUseCase *usecase.WebhookUseCase
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhookUseCase *usecase.WebhookUseCase) *WebhookHandler {
+	return &WebhookHandler{
+		webhookUseCase: webhookUseCase,
+	}
+}
+
+// WebhookEndpointRequest represents the endpoint creation and update request data. Empty
+// events subscribes to every event type.
+type WebhookEndpointRequest struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+}
+
+// WebhookEndpointDTO represents a webhook endpoint. The secret is only included when it is
+// created or rotated.
+type WebhookEndpointDTO struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description,omitempty"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDeliveryDTO represents a delivery of an event to an endpoint. The payload and
+// attempts are only included when a single delivery is retrieved.
+type WebhookDeliveryDTO struct {
+	ID            string              `json:"id"`
+	EndpointID    string              `json:"endpoint_id"`
+	EventID       string              `json:"event_id"`
+	EventType     string              `json:"event_type"`
+	Status        string              `json:"status"`
+	AttemptCount  int                 `json:"attempt_count"`
+	NextAttemptAt *time.Time          `json:"next_attempt_at,omitempty"`
+	RedeliveryOf  string              `json:"redelivery_of,omitempty"`
+	Payload       json.RawMessage     `json:"payload,omitempty"`
+	Attempts      []WebhookAttemptDTO `json:"attempts,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+// WebhookAttemptDTO represents one attempt of a delivery
+type WebhookAttemptDTO struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// Endpoints handles GET (list) and POST (create) requests on the endpoint collection
+func (h *WebhookHandler) Endpoints(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		endpoints, err := h.webhookUseCase.ListEndpoints(principal)
+		if err != nil {
+			sendError(w, webhookErrorStatus(err), err)
+			return
+		}
+
+		data := make([]WebhookEndpointDTO, 0, len(endpoints))
+		for _, e := range endpoints {
+			data = append(data, newWebhookEndpointDTO(e, false))
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Webhook endpoints retrieved successfully",
+			Data:    data,
+		})
+
+	case http.MethodPost:
+		var req WebhookEndpointRequest
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+
+		e, err := h.webhookUseCase.CreateEndpoint(principal, usecase.WebhookEndpointInput(req))
+		if err != nil {
+			sendError(w, webhookErrorStatus(err), err)
+			return
+		}
+
+		SendJSONResponse(w, http.StatusCreated, APIResponse{
+			Status:  "success",
+			Message: "Webhook endpoint created successfully. Store the secret now; it will not be shown again.",
+			Data:    newWebhookEndpointDTO(e, true),
+		})
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// Endpoint handles the resources of a single endpoint:
+//
+//	GET, PUT, DELETE  /webhooks/{id}
+//	POST              /webhooks/{id}/secret
+//	GET               /webhooks/{id}/deliveries?status=<status>
+func (h *WebhookHandler) Endpoint(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	id, segments := subtreePath(r.URL.Path, "/webhooks/")
+	switch {
+	case id == "":
+		sendNotFound(w)
+	case len(segments) == 0:
+		h.endpoint(w, r, principal, id)
+	case len(segments) == 1 && segments[0] == "secret":
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		e, err := h.webhookUseCase.RotateSecret(principal, id)
+		if err != nil {
+			sendError(w, webhookErrorStatus(err), err)
+			return
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Webhook secret rotated. Store the secret now; it will not be shown again.",
+			Data:    newWebhookEndpointDTO(e, true),
+		})
+	case len(segments) == 1 && segments[0] == "deliveries":
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		if _, err := h.webhookUseCase.GetEndpoint(principal, id); err != nil {
+			sendError(w, webhookErrorStatus(err), err)
+			return
+		}
+		h.listDeliveries(w, principal, webhook.DeliveryFilter{EndpointID: id, Status: r.URL.Query().Get("status")})
+	default:
+		sendNotFound(w)
+	}
+}
+
+// Deliveries handles GET requests on the delivery log, which can be narrowed with
+// ?endpoint_id=<id>&status=<pending|succeeded|dead>. status=dead lists the dead-letter queue.
+func (h *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	query := r.URL.Query()
+	h.listDeliveries(w, principal, webhook.DeliveryFilter{EndpointID: query.Get("endpoint_id"), Status: query.Get("status")})
+}
+
+// Delivery handles the resources of a single delivery:
+//
+//	GET   /webhook-deliveries/{id}
+//	POST  /webhook-deliveries/{id}/redeliver
+func (h *WebhookHandler) Delivery(w http.ResponseWriter, r *http.Request) {
+	principal, ok := common.PrincipalFromContext(r.Context())
+	if !ok {
+		sendUnauthorized(w)
+		return
+	}
+
+	id, segments := subtreePath(r.URL.Path, "/webhook-deliveries/")
+	switch {
+	case id == "":
+		sendNotFound(w)
+	case len(segments) == 0:
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		d, err := h.webhookUseCase.GetDelivery(principal, id)
+		if err != nil {
+			sendError(w, webhookErrorStatus(err), err)
+			return
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Webhook delivery retrieved successfully",
+			Data:    newWebhookDeliveryDTO(d, true),
+		})
+	case len(segments) == 1 && segments[0] == "redeliver":
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		d, err := h.webhookUseCase.Redeliver(principal, id)
+		if err != nil {
+			sendError(w, webhookErrorStatus(err), err)
+			return
+		}
+		SendJSONResponse(w, http.StatusAccepted, APIResponse{
+			Status:  "success",
+			Message: "Webhook redelivery queued",
+			Data:    newWebhookDeliveryDTO(d, false),
+		})
+	default:
+		sendNotFound(w)
+	}
+}
+
+// endpoint handles GET, PUT and DELETE requests on an endpoint
+func (h *WebhookHandler) endpoint(w http.ResponseWriter, r *http.Request, principal *auth.Principal, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		e, err := h.webhookUseCase.GetEndpoint(principal, id)
+		if err != nil {
+			sendError(w, webhookErrorStatus(err), err)
+			return
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Webhook endpoint retrieved successfully",
+			Data:    newWebhookEndpointDTO(e, false),
+		})
+
+	case http.MethodPut:
+		var req WebhookEndpointRequest
+		if !decodeJSONRequest(w, r, &req) {
+			return
+		}
+		e, err := h.webhookUseCase.UpdateEndpoint(principal, id, usecase.WebhookEndpointInput(req))
+		if err != nil {
+			sendError(w, webhookErrorStatus(err), err)
+			return
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Webhook endpoint updated successfully",
+			Data:    newWebhookEndpointDTO(e, false),
+		})
+
+	case http.MethodDelete:
+		if err := h.webhookUseCase.DeleteEndpoint(principal, id); err != nil {
+			sendError(w, webhookErrorStatus(err), err)
+			return
+		}
+		SendJSONResponse(w, http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Webhook endpoint deleted successfully",
+		})
+
+	default:
+		requireMethod(w, r, http.MethodGet)
+	}
+}
+
+// listDeliveries sends the deliveries selected by filter
+func (h *WebhookHandler) listDeliveries(w http.ResponseWriter, principal *auth.Principal, filter webhook.DeliveryFilter) {
+	deliveries, err := h.webhookUseCase.ListDeliveries(principal, filter)
+	if err != nil {
+		sendError(w, webhookErrorStatus(err), err)
+		return
+	}
+
+	data := make([]WebhookDeliveryDTO, 0, len(deliveries))
+	for _, d := range deliveries {
+		data = append(data, newWebhookDeliveryDTO(d, false))
+	}
+	SendJSONResponse(w, http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Webhook deliveries retrieved successfully",
+		Data:    data,
+	})
+}
+
+// subtreePath splits a path below prefix into the resource ID and the segments after it, so
+// /api/webhooks/1/deliveries with prefix /webhooks/ gives "1" and [deliveries]
+func subtreePath(p, prefix string) (string, []string) {
+	i := strings.Index(p, prefix)
+	if i < 0 {
+		return "", nil
+	}
+
+	segments := strings.Split(strings.Trim(p[i+len(prefix):], "/"), "/")
+	if segments[0] == "" {
+		return "", nil
+	}
+	return segments[0], segments[1:]
+}
+
+// webhookErrorStatus maps webhook use case errors to HTTP status codes
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, webhook.ErrEndpointNotFound), errors.Is(err, webhook.ErrDeliveryNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func newWebhookEndpointDTO(e *webhook.Endpoint, withSecret bool) WebhookEndpointDTO {
+	dto := WebhookEndpointDTO{
+		ID:          e.ID,
+		URL:         e.URL,
+		Events:      e.Events,
+		Description: e.Description,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+	}
+	if dto.Events == nil {
+		dto.Events = []string{}
+	}
+	if withSecret {
+		dto.Secret = e.Secret
+	}
+	return dto
+}
+
+func newWebhookDeliveryDTO(d *webhook.Delivery, detailed bool) WebhookDeliveryDTO {
+	dto := WebhookDeliveryDTO{
+		ID:           d.ID,
+		EndpointID:   d.EndpointID,
+		EventID:      d.EventID,
+		EventType:    d.EventType,
+		Status:       d.Status,
+		AttemptCount: len(d.Attempts),
+		RedeliveryOf: d.RedeliveryOf,
+		CreatedAt:    d.CreatedAt,
+		UpdatedAt:    d.UpdatedAt,
+	}
+	if d.Status == webhook.StatusPending {
+		next := d.NextAttemptAt
+		dto.NextAttemptAt = &next
+	}
+	if detailed {
+		dto.Payload = d.Payload
+		dto.Attempts = make([]WebhookAttemptDTO, 0, len(d.Attempts))
+		for _, a := range d.Attempts {
+			dto.Attempts = append(dto.Attempts, WebhookAttemptDTO{
+				At:         a.At,
+				StatusCode: a.StatusCode,
+				Error:      a.Error,
+				DurationMS: a.Duration.Milliseconds(),
+			})
+		}
+	}
+	return dto
+}
@@ -0,0 +1,22 @@
+// Package mailer provides implementations of the mail.Mailer port
+package mailer
+
+import (
+	"log"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/mail"
+)
+
+// LogMailer is a mailer that writes messages to the application log instead of sending them
+type LogMailer struct{}
+
+// NewLogMailer creates a new log mailer
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send logs the message
+func (m *LogMailer) Send(msg mail.Message) error {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
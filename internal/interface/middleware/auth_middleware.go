@@ -9,64 +9,190 @@ import (
 	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
 )
 
+// apiKeyHeader is the header service callers may send their API key in
+const apiKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator resolves raw API keys presented to a tenant to principals
+type APIKeyAuthenticator interface {
+	Authenticate(tenantID, rawKey string) (*auth.Principal, error)
+}
+
+// SessionAuthenticator resolves session cookie tokens presented to a tenant to principals
+type SessionAuthenticator interface {
+	Authenticate(tenantID, token string) (*auth.Principal, error)
+}
+
 // AuthMiddleware is a middleware that authenticates requests
 type AuthMiddleware struct {
-	jwtService auth.JWTService
+	jwtService    auth.JWTService
+	apiKeys       APIKeyAuthenticator
+	apiKeyPrefix  string
+	sessions      SessionAuthenticator
+	sessionCookie string
+	revocations   TokenRevocationChecker
+	accounts      AccountStatusChecker
+}
+
+// TokenRevocationChecker reports whether a JWT has been revoked before its expiry
+type TokenRevocationChecker interface {
+	IsTokenRevoked(tokenID string) bool
+}
+
+// AccountStatusChecker reports whether a user may still use the tokens issued to them
+type AccountStatusChecker interface {
+	IsAccountActive(tenantID, userID string) bool
+}
+
+// AuthOption configures optional authentication methods
+type AuthOption func(*AuthMiddleware)
+
+// WithAPIKeys accepts API keys in the X-API-Key header, as "Authorization: ApiKey <key>",
+// or as a bearer token starting with keyPrefix
+func WithAPIKeys(apiKeys APIKeyAuthenticator, keyPrefix string) AuthOption {
+	return func(m *AuthMiddleware) {
+		m.apiKeys = apiKeys
+		m.apiKeyPrefix = keyPrefix
+	}
+}
+
+// WithSessions accepts a session cookie when the request carries no Authorization header
+func WithSessions(sessions SessionAuthenticator, cookieName string) AuthOption {
+	return func(m *AuthMiddleware) {
+		m.sessions = sessions
+		m.sessionCookie = cookieName
+	}
+}
+
+// WithTokenRevocation rejects JWTs whose ID has been revoked, such as revoked OAuth access tokens
+func WithTokenRevocation(revocations TokenRevocationChecker) AuthOption {
+	return func(m *AuthMiddleware) {
+		m.revocations = revocations
+	}
+}
+
+// WithAccountStatus rejects JWTs of users whose account has since been disabled or deleted, such
+// as users deprovisioned through SCIM. Tokens would stay usable until they expire otherwise.
+func WithAccountStatus(accounts AccountStatusChecker) AuthOption {
+	return func(m *AuthMiddleware) {
+		m.accounts = accounts
+	}
 }
 
 // NewAuthMiddleware creates a new authentication middleware
-func NewAuthMiddleware(jwtService auth.JWTService) *AuthMiddleware {
-	return &AuthMiddleware{
+func NewAuthMiddleware(jwtService auth.JWTService, opts ...AuthOption) *AuthMiddleware {
+	m := &AuthMiddleware{
 		jwtService: jwtService,
 	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
 }
 
-// Authenticate middleware checks for valid JWT token in Authorization header
+// Authenticate middleware checks for a valid JWT, API key or session cookie and stores the principal in the context.
+// User claims are also stored under common.UserClaimsKey for handlers that only need the user.
 func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get the Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+		principal, errorMsg := m.Resolve(r)
+		if principal == nil {
 			common.SendJSONResponse(w, http.StatusUnauthorized, common.APIResponse{
 				Status: "error",
-				Error:  "Authorization header is required",
+				Error:  errorMsg,
 			})
 			return
 		}
 
-		// Check if the header has the correct format (Bearer <token>)
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			common.SendJSONResponse(w, http.StatusUnauthorized, common.APIResponse{
-				Status: "error",
-				Error:  "Authorization header format must be Bearer <token>",
-			})
-			return
+		// Call the next handler with the updated context
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// Resolve authenticates a request. It returns the principal, or nil and an error message for the client.
+// Credentials are only accepted by the tenant the request was resolved to.
+func (m *AuthMiddleware) Resolve(r *http.Request) (*auth.Principal, string) {
+	tenantID := common.TenantFromContext(r.Context())
+	principal, errorMsg := m.resolve(r, tenantID)
+	if principal != nil && principal.TenantID != tenantID {
+		return nil, "Token is not valid for this tenant"
+	}
+	return principal, errorMsg
+}
+
+// resolve finds the principal for whichever credential the request carries
+func (m *AuthMiddleware) resolve(r *http.Request, tenantID string) (*auth.Principal, string) {
+	if key := r.Header.Get(apiKeyHeader); key != "" && m.apiKeys != nil {
+		return m.resolveAPIKey(tenantID, key)
+	}
+
+	// Get the Authorization header
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		if cookie, err := r.Cookie(m.sessionCookie); err == nil && m.sessions != nil {
+			return m.resolveSession(tenantID, cookie.Value)
 		}
+		return nil, "Authorization header is required"
+	}
 
-		// Extract the token
-		tokenString := parts[1]
-
-		// Validate the token
-		claims, err := m.jwtService.ValidateToken(tokenString)
-		if err != nil {
-			var errorMsg string
-			if err == auth.ErrExpiredToken {
-				errorMsg = "Token has expired"
-			} else {
-				errorMsg = "Invalid token"
-			}
-			common.SendJSONResponse(w, http.StatusUnauthorized, common.APIResponse{
-				Status: "error",
-				Error:  errorMsg,
-			})
-			return
+	// Check if the header has the correct format (Bearer <token> or ApiKey <key>)
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 {
+		return nil, "Authorization header format must be Bearer <token>"
+	}
+
+	switch {
+	case parts[0] == "ApiKey" && m.apiKeys != nil:
+		return m.resolveAPIKey(tenantID, parts[1])
+	case parts[0] != "Bearer":
+		return nil, "Authorization header format must be Bearer <token>"
+	case m.apiKeys != nil && strings.HasPrefix(parts[1], m.apiKeyPrefix):
+		return m.resolveAPIKey(tenantID, parts[1])
+	}
+
+	// Validate the token
+	claims, err := m.jwtService.ValidateToken(parts[1])
+	if err != nil {
+		if err == auth.ErrExpiredToken {
+			return nil, "Token has expired"
 		}
+		return nil, "Invalid token"
+	}
+	if m.revocations != nil && claims.ID != "" && m.revocations.IsTokenRevoked(claims.ID) {
+		return nil, "Token has been revoked"
+	}
 
-		// Add claims to context using common UserClaimsKey
-		ctx := context.WithValue(r.Context(), common.UserClaimsKey, claims)
+	principal := auth.NewPrincipalFromClaims(claims)
+	if m.accounts != nil && principal.Type == auth.PrincipalUser && !m.accounts.IsAccountActive(claims.Tenant(), claims.Subject) {
+		return nil, "Account is disabled"
+	}
+	return principal, ""
+}
 
-		// Call the next handler with the updated context
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// resolveAPIKey authenticates an API key
+func (m *AuthMiddleware) resolveAPIKey(tenantID, key string) (*auth.Principal, string) {
+	principal, err := m.apiKeys.Authenticate(tenantID, key)
+	if err != nil {
+		return nil, "Invalid API key"
+	}
+	return principal, ""
+}
+
+// resolveSession authenticates a session cookie
+func (m *AuthMiddleware) resolveSession(tenantID, token string) (*auth.Principal, string) {
+	principal, err := m.sessions.Authenticate(tenantID, token)
+	if err != nil {
+		return nil, "Session has expired"
+	}
+	return principal, ""
+}
+
+// WithPrincipal stores a principal, and its user claims if any, in a context
+func WithPrincipal(ctx context.Context, principal *auth.Principal) context.Context {
+	ctx = context.WithValue(ctx, common.PrincipalKey, principal)
+	if principal.Claims != nil {
+		// Add claims to context using common UserClaimsKey
+		ctx = context.WithValue(ctx, common.UserClaimsKey, principal.Claims)
+	}
+	return ctx
 }
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/authz"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+)
+
+// PermissionChecker decides whether a subject may perform an action on a resource in a tenant
+type PermissionChecker interface {
+	Check(tenantID string, subject authz.Subject, action string, resource authz.Object) (bool, error)
+}
+
+// AuthzMiddleware checks requests on the routes of an authorization policy
+type AuthzMiddleware struct {
+	checker PermissionChecker
+	routes  []authz.Route
+}
+
+// NewAuthzMiddleware creates a new authorization middleware for the policy routes
+func NewAuthzMiddleware(checker PermissionChecker, routes []authz.Route) *AuthzMiddleware {
+	return &AuthzMiddleware{checker: checker, routes: routes}
+}
+
+// Enforce checks that the principal may perform the action of the first route matching the
+// request, which is routed by its path after tenant resolution. Requests matching no route
+// pass through. It must run after Authenticate.
+func (m *AuthzMiddleware) Enforce(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, params, ok := m.match(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, ok := common.PrincipalFromContext(r.Context())
+		if !ok {
+			common.SendJSONResponse(w, http.StatusUnauthorized, common.APIResponse{
+				Status: "error",
+				Error:  "Authentication required",
+			})
+			return
+		}
+
+		resource, err := authz.ParseObject(expand(route.Resource, params))
+		if err != nil {
+			common.SendJSONResponse(w, http.StatusNotFound, common.APIResponse{
+				Status: "error",
+				Error:  "Resource not found",
+			})
+			return
+		}
+
+		allowed, err := m.checker.Check(principal.TenantID, authz.PrincipalSubject(principal), route.Action, resource)
+		if err != nil {
+			log.Printf("authorization check of %s#%s failed: %v", resource, route.Action, err)
+			common.SendJSONResponse(w, http.StatusInternalServerError, common.APIResponse{
+				Status: "error",
+				Error:  "Internal server error",
+			})
+			return
+		}
+		if !allowed {
+			common.SendJSONResponse(w, http.StatusForbidden, common.APIResponse{
+				Status: "error",
+				Error:  "Forbidden",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// match returns the first route matching the request and the values of its path parameters
+func (m *AuthzMiddleware) match(r *http.Request) (authz.Route, map[string]string, bool) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for _, route := range m.routes {
+		if route.Method != "" && route.Method != r.Method {
+			continue
+		}
+		if params, ok := matchPath(strings.Split(strings.Trim(route.Path, "/"), "/"), segments); ok {
+			return route, params, true
+		}
+	}
+	return authz.Route{}, nil, false
+}
+
+// matchPath matches path segments against a pattern whose {name} segments match any segment
+func matchPath(pattern, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[p[1:len(p)-1]] = segments[i]
+			continue
+		}
+		if p != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// expand replaces the {name} placeholders of a resource template with path parameters
+func expand(template string, params map[string]string) string {
+	for name, value := range params {
+		template = strings.ReplaceAll(template, "{"+name+"}", value)
+	}
+	return template
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+)
+
+// TenantResolution configures how a request selects its tenant. Each way is off when its
+// field is empty.
+type TenantResolution struct {
+	// Header names a request header carrying the tenant ID, e.g. X-Tenant-ID
+	Header string
+	// PathPrefix selects the tenant from the path segment after it, e.g. /t/ for /t/acme/login.
+	// The prefix and tenant ID are stripped before routing.
+	PathPrefix string
+	// BaseDomain selects the tenant from the subdomain directly below it, e.g. example.com
+	// for acme.example.com
+	BaseDomain string
+}
+
+// TenantMiddleware resolves the tenant of each request and stores its ID in the context.
+// A request that selects no tenant belongs to the default tenant.
+type TenantMiddleware struct {
+	tenants    tenant.Repository
+	resolution TenantResolution
+}
+
+// NewTenantMiddleware creates a new tenant middleware
+func NewTenantMiddleware(tenants tenant.Repository, resolution TenantResolution) *TenantMiddleware {
+	resolution.BaseDomain = strings.ToLower(strings.Trim(resolution.BaseDomain, "."))
+	return &TenantMiddleware{tenants: tenants, resolution: resolution}
+}
+
+// Resolve selects the tenant from the header, then the path, then the subdomain. Requests for
+// an unknown tenant are answered with 404 so they never reach another tenant's data.
+func (m *TenantMiddleware) Resolve(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, r := m.tenantOf(r)
+		if tenantID == "" {
+			tenantID = tenant.DefaultID
+		}
+
+		if _, err := m.tenants.FindByID(tenantID); err != nil {
+			common.SendJSONResponse(w, http.StatusNotFound, common.APIResponse{
+				Status: "error",
+				Error:  "Unknown tenant",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(common.WithTenant(r.Context(), tenantID)))
+	})
+}
+
+// tenantOf returns the tenant ID a request names, if any, and the request to route. A tenant
+// in the path is stripped from the returned request.
+func (m *TenantMiddleware) tenantOf(r *http.Request) (string, *http.Request) {
+	if m.resolution.Header != "" {
+		if tenantID := strings.TrimSpace(r.Header.Get(m.resolution.Header)); tenantID != "" {
+			return strings.ToLower(tenantID), r
+		}
+	}
+
+	if prefix := m.resolution.PathPrefix; prefix != "" && strings.HasPrefix(r.URL.Path, prefix) {
+		tenantID, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if tenantID != "" {
+			routed := r.Clone(r.Context())
+			routed.URL.Path = "/" + rest
+			routed.URL.RawPath = ""
+			return strings.ToLower(tenantID), routed
+		}
+	}
+
+	if m.resolution.BaseDomain != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		if sub, ok := strings.CutSuffix(host, "."+m.resolution.BaseDomain); ok && !strings.Contains(sub, ".") {
+			return sub, r
+		}
+	}
+
+	return "", r
+}
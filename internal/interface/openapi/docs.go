@@ -0,0 +1,48 @@
+package openapi
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// The docs page renders the document at SpecPath, relative to the page. Its style and script
+// are embedded and inlined, so the page loads nothing from other origins.
+var (
+	//go:embed docs.html
+	docsTemplate string
+	//go:embed docs.css
+	docsStyle string
+	//go:embed docs.js
+	docsScript string
+)
+
+// docsPage is the page served at DocsPath
+var docsPage = []byte(strings.NewReplacer("{{style}}", docsStyle, "{{script}}", docsScript).Replace(docsTemplate))
+
+// docsPolicy allows only the inlined style and script, identified by their hashes, and requests
+// for the document
+var docsPolicy = "default-src 'none'; connect-src 'self'; base-uri 'none'; frame-ancestors 'none'; " +
+	"style-src '" + inlineHash(docsStyle) + "'; script-src '" + inlineHash(docsScript) + "'"
+
+// inlineHash returns the Content-Security-Policy source of an inline style or script
+func inlineHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// DocsHandler serves a browsable version of the document, which must be served at SpecPath
+func DocsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", docsPolicy)
+		w.Write(docsPage)
+	})
+}
@@ -0,0 +1,167 @@
+"use strict";
+
+// The document is served next to this page, which keeps tenant path prefixes intact
+const specURL = "openapi.json";
+const methods = ["get", "post", "put", "patch", "delete"];
+
+// el creates an element with text content and children; text is never parsed as HTML
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [name, value] of Object.entries(attrs || {})) {
+    node.setAttribute(name, value);
+  }
+  for (const child of children) {
+    if (child !== null && child !== undefined) {
+      node.append(child);
+    }
+  }
+  return node;
+}
+
+// resolve follows a local $ref into the components of the document
+function resolve(doc, schema) {
+  const prefix = "#/components/schemas/";
+  if (schema && schema.$ref && schema.$ref.startsWith(prefix)) {
+    const name = schema.$ref.slice(prefix.length);
+    return { name: name, schema: (doc.components.schemas || {})[name] || {} };
+  }
+  return { name: "", schema: schema || {} };
+}
+
+// resolveResponse follows a local $ref into the shared responses of the document
+function resolveResponse(doc, response) {
+  const prefix = "#/components/responses/";
+  if (response.$ref && response.$ref.startsWith(prefix)) {
+    return (doc.components.responses || {})[response.$ref.slice(prefix.length)] || {};
+  }
+  return response;
+}
+
+// typeName describes the type of a schema in one line
+function typeName(doc, schema) {
+  const ref = resolve(doc, schema);
+  const s = ref.schema;
+  if (ref.name) {
+    return ref.name;
+  }
+  if (s.allOf) {
+    return s.allOf.map((part) => typeName(doc, part)).join(" & ");
+  }
+  if (s.type === "array") {
+    return typeName(doc, s.items) + "[]";
+  }
+  const type = Array.isArray(s.type) ? s.type.join(" | ") : s.type || "any";
+  return s.format ? type + " (" + s.format + ")" : type;
+}
+
+// renderSchema lists the properties of a schema, following references a few levels deep
+function renderSchema(doc, schema, depth) {
+  let s = resolve(doc, schema).schema;
+  if (s.type === "array") {
+    s = resolve(doc, s.items).schema;
+  }
+  const parts = s.allOf ? s.allOf.map((part) => resolve(doc, part).schema) : [s];
+  const list = el("ul", { class: "schema" });
+  for (const part of parts) {
+    const required = new Set(part.required || []);
+    for (const [name, property] of Object.entries(part.properties || {})) {
+      const item = el("li", {}, el("code", {}, name), " ", el("span", { class: "muted" }, typeName(doc, property)));
+      if (required.has(name)) {
+        item.append(" required");
+      }
+      if (property.description) {
+        item.append(" – " + property.description);
+      }
+      if (depth < 3) {
+        const nested = renderSchema(doc, property, depth + 1);
+        if (nested.childElementCount > 0) {
+          item.append(nested);
+        }
+      }
+      list.append(item);
+    }
+  }
+  return list;
+}
+
+// renderContent shows the body schema of a request or response
+function renderContent(doc, content) {
+  const body = el("div");
+  for (const [type, media] of Object.entries(content || {})) {
+    body.append(el("p", {}, el("code", {}, type), " ", el("span", { class: "muted" }, typeName(doc, media.schema))));
+    body.append(renderSchema(doc, media.schema, 0));
+  }
+  return body;
+}
+
+// renderOperation renders one method on one path
+function renderOperation(doc, method, path, op) {
+  const body = el("div");
+  if (op.description) {
+    body.append(el("p", {}, op.description));
+  }
+  if (op.security && op.security.length > 0) {
+    body.append(el("p", { class: "muted" }, "Authentication: " + op.security.map((s) => Object.keys(s).join(", ")).join(" or ")));
+  }
+  if (op.parameters && op.parameters.length > 0) {
+    const table = el("table", {}, el("tr", {}, el("th", {}, "Parameter"), el("th", {}, "In"), el("th", {}, "Description")));
+    for (const p of op.parameters) {
+      table.append(el("tr", {}, el("td", {}, el("code", {}, p.name), p.required ? " required" : ""), el("td", {}, p.in), el("td", {}, p.description || "")));
+    }
+    body.append(el("h4", {}, "Parameters"), table);
+  }
+  if (op.requestBody) {
+    body.append(el("h4", {}, "Request body"), renderContent(doc, op.requestBody.content));
+  }
+  for (const [status, ref] of Object.entries(op.responses || {})) {
+    const response = resolveResponse(doc, ref);
+    body.append(el("h4", {}, status + " " + (response.description || "")), renderContent(doc, response.content));
+  }
+
+  return el("details", {},
+    el("summary", {}, el("span", { class: "method " + method }, method), el("span", { class: "path" }, path), op.summary || ""),
+    body);
+}
+
+// render replaces the page with the operations of the document, grouped by tag
+function render(doc) {
+  const main = document.getElementById("docs");
+  main.replaceChildren(el("h1", {}, doc.info.title), el("p", { class: "muted" }, "Version " + doc.info.version));
+  if (doc.info.description) {
+    main.append(el("p", {}, doc.info.description));
+  }
+
+  // Tags are listed in the order the document declares them
+  const tags = new Map((doc.tags || []).map((tag) => [tag.name, []]));
+  for (const [path, item] of Object.entries(doc.paths || {})) {
+    for (const method of methods) {
+      const op = item[method];
+      if (!op) {
+        continue;
+      }
+      const tag = (op.tags && op.tags[0]) || "Other";
+      if (!tags.has(tag)) {
+        tags.set(tag, []);
+      }
+      tags.get(tag).push(renderOperation(doc, method, path, op));
+    }
+  }
+  for (const [tag, operations] of tags) {
+    if (operations.length === 0) {
+      continue;
+    }
+    main.append(el("h2", {}, tag), ...operations);
+  }
+}
+
+fetch(specURL)
+  .then((response) => {
+    if (!response.ok) {
+      throw new Error(specURL + " answered " + response.status);
+    }
+    return response.json();
+  })
+  .then(render)
+  .catch((err) => {
+    document.getElementById("docs").replaceChildren(el("p", {}, "Could not load the API document: " + err.message));
+  });
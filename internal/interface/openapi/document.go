@@ -0,0 +1,280 @@
+// Package openapi builds OpenAPI 3.1 documents from operation descriptions, with the schemas of
+// request and response bodies generated from their Go types
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+)
+
+// Version is the OpenAPI version of the generated documents
+const Version = "3.1.0"
+
+// Paths the document and its docs UI are served at
+const (
+	SpecPath = "/openapi.json"
+	DocsPath = "/docs"
+)
+
+// Content types of request and response bodies
+const (
+	ContentJSON = "application/json"
+	ContentForm = "application/x-www-form-urlencoded"
+)
+
+// Auth is how an operation authenticates its caller
+type Auth int
+
+const (
+	// AuthNone marks public operations
+	AuthNone Auth = iota
+	// AuthUser marks operations that accept a JWT, an API key or a session cookie
+	AuthUser
+	// AuthClient marks OAuth endpoints that authenticate the client with its secret
+	AuthClient
+)
+
+// Info describes the API as a whole
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Parameter is a query parameter of an operation; path parameters are taken from the path
+type Parameter struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Operation describes one method on one path
+type Operation struct {
+	Method string
+	// Path names path parameters in braces, e.g. /api-keys/{id}
+	Path        string
+	Summary     string
+	Description string
+	Tag         string
+	Auth        Auth
+	Query       []Parameter
+
+	// Request is a value of the request body type, nil when the operation takes no body
+	Request interface{}
+	// RequestType is the content type of the body, JSON by default
+	RequestType string
+
+	// Status is the status of a successful response, 200 by default
+	Status int
+	// Response is a value of the data type of the API response envelope, or of the whole body
+	// when Bare is set. Nil responses have no data.
+	Response interface{}
+	// ResponseType is the content type of a bare response, JSON by default
+	ResponseType string
+	// Bare responses are sent without the API response envelope, as by the OAuth and SCIM
+	// endpoints
+	Bare bool
+	// Error is a value of the body type of errors of bare responses
+	Error interface{}
+}
+
+// Document is an OpenAPI document for a set of operations. It serves itself as JSON.
+type Document struct {
+	info       Info
+	operations []Operation
+
+	once sync.Once
+	body []byte
+	err  error
+}
+
+// New creates an empty document
+func New(info Info) *Document {
+	return &Document{info: info}
+}
+
+// Add adds operations to the document
+func (d *Document) Add(ops ...Operation) {
+	d.operations = append(d.operations, ops...)
+}
+
+// Operations returns the operations of the document
+func (d *Document) Operations() []Operation {
+	return append([]Operation(nil), d.operations...)
+}
+
+// ServeHTTP serves the document as JSON
+func (d *Document) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d.once.Do(func() {
+		d.body, d.err = json.Marshal(d)
+	})
+	if d.err != nil {
+		http.Error(w, "failed to build the API description", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ContentJSON)
+	w.Write(d.body)
+}
+
+// MarshalJSON renders the OpenAPI document
+func (d *Document) MarshalJSON() ([]byte, error) {
+	schemas := newSchemaBuilder()
+	paths := map[string]map[string]interface{}{}
+	tags := map[string]bool{}
+	for _, op := range d.operations {
+		item, ok := paths[op.Path]
+		if !ok {
+			item = map[string]interface{}{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = d.operation(op, schemas)
+		if op.Tag != "" {
+			tags[op.Tag] = true
+		}
+	}
+
+	var tagList []map[string]string
+	for tag := range tags {
+		tagList = append(tagList, map[string]string{"name": tag})
+	}
+	sort.Slice(tagList, func(i, j int) bool { return tagList[i]["name"] < tagList[j]["name"] })
+
+	return json.Marshal(map[string]interface{}{
+		"openapi": Version,
+		"info":    d.info,
+		"tags":    tagList,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "The request failed",
+					"content":     jsonContent(ContentJSON, schemas.schema(reflect.TypeOf(common.APIResponse{}))),
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth":    map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey":        map[string]string{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"sessionCookie": map[string]string{"type": "apiKey", "in": "cookie", "name": "session"},
+				"clientSecret":  map[string]string{"type": "http", "scheme": "basic"},
+			},
+		},
+	})
+}
+
+var pathParameter = regexp.MustCompile(`\{([^}]+)\}`)
+
+// operation renders an operation object
+func (d *Document) operation(op Operation, schemas *schemaBuilder) map[string]interface{} {
+	rendered := map[string]interface{}{
+		"operationId": operationID(op),
+		"summary":     op.Summary,
+	}
+	if op.Description != "" {
+		rendered["description"] = op.Description
+	}
+	if op.Tag != "" {
+		rendered["tags"] = []string{op.Tag}
+	}
+
+	var params []map[string]interface{}
+	for _, match := range pathParameter.FindAllStringSubmatch(op.Path, -1) {
+		params = append(params, map[string]interface{}{
+			"name": match[1], "in": "path", "required": true, "schema": map[string]string{"type": "string"},
+		})
+	}
+	for _, q := range op.Query {
+		param := map[string]interface{}{"name": q.Name, "in": "query", "schema": map[string]string{"type": "string"}}
+		if q.Description != "" {
+			param["description"] = q.Description
+		}
+		if q.Required {
+			param["required"] = true
+		}
+		params = append(params, param)
+	}
+	if params != nil {
+		rendered["parameters"] = params
+	}
+
+	if op.Request != nil {
+		contentType := op.RequestType
+		if contentType == "" {
+			contentType = ContentJSON
+		}
+		rendered["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(contentType, schemas.schema(reflect.TypeOf(op.Request))),
+		}
+	}
+
+	switch op.Auth {
+	case AuthUser:
+		rendered["security"] = []map[string][]string{{"bearerAuth": {}}, {"apiKey": {}}, {"sessionCookie": {}}}
+	case AuthClient:
+		rendered["security"] = []map[string][]string{{"clientSecret": {}}}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	errorResponse := map[string]interface{}{"$ref": "#/components/responses/Error"}
+	if op.Bare {
+		contentType := op.ResponseType
+		if contentType == "" {
+			contentType = ContentJSON
+		}
+		if op.Response != nil {
+			success["content"] = jsonContent(contentType, schemas.schema(reflect.TypeOf(op.Response)))
+		}
+		errorResponse = map[string]interface{}{"description": "The request failed"}
+		if op.Error != nil {
+			errorResponse["content"] = jsonContent(ContentJSON, schemas.schema(reflect.TypeOf(op.Error)))
+		}
+	} else {
+		envelope := schemas.schema(reflect.TypeOf(common.APIResponse{}))
+		if op.Response != nil {
+			envelope = map[string]interface{}{"allOf": []interface{}{envelope, map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"data": schemas.schema(reflect.TypeOf(op.Response))},
+			}}}
+		}
+		success["content"] = jsonContent(ContentJSON, envelope)
+	}
+	rendered["responses"] = map[string]interface{}{
+		strconv.Itoa(status): success,
+		"default":            errorResponse,
+	}
+	return rendered
+}
+
+// operationID derives a unique ID from the method and path, e.g. get_api-keys_id
+func operationID(op Operation) string {
+	id := strings.ToLower(op.Method)
+	for _, segment := range strings.Split(op.Path, "/") {
+		segment = strings.Trim(segment, "{}")
+		if segment != "" {
+			id += "_" + segment
+		}
+	}
+	return id
+}
+
+func jsonContent(contentType string, schema interface{}) map[string]interface{} {
+	return map[string]interface{}{contentType: map[string]interface{}{"schema": schema}}
+}
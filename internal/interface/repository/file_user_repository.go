@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/event"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+)
+
+// FileUserRepository is a user repository persisted as a JSON file.
+// It lets the servers and command line tools share one store without a database: writes hold
+// an exclusive lock on a sidecar ".lock" file and reload the store first, and reads reload it
+// when another process has replaced it, so changes made elsewhere are neither lost nor missed.
+// The outbox of user events is kept in the same file, so a change and the events it
+// raised are written together.
+type FileUserRepository struct {
+	path   string
+	users  map[userKey]*user.User
+	outbox outboxEntries
+	// loaded identifies the version of the file the cache holds, nil before it is first read
+	loaded os.FileInfo
+	mu     sync.Mutex
+}
+
+// userStoreFile is the layout of the store file
+type userStoreFile struct {
+	Users  []*user.User        `json:"users"`
+	Outbox []event.OutboxEntry `json:"outbox,omitempty"`
+}
+
+// NewFileUserRepository opens the JSON user store at path, creating it on first write
+func NewFileUserRepository(path string) (*FileUserRepository, error) {
+	r := &FileUserRepository{
+		path:  path,
+		users: make(map[userKey]*user.User),
+	}
+
+	unlock, err := lockFile(r.lockPath())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	migrated, err := r.reload()
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		if err := r.persist(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Save saves a user and persists the store
+func (r *FileUserRepository) Save(user *user.User) error {
+	return r.write(func() error {
+		// Check if user already exists
+		key := userKey{user.TenantID, user.Email}
+		if _, exists := r.users[key]; exists {
+			return errors.New("user already exists")
+		}
+
+		r.outbox.add(user.PullEvents())
+		r.users[key] = copyUser(user)
+		return nil
+	})
+}
+
+// Update replaces an existing user, found by ID, in the store and persists it. The email may change
+// as long as no other user of the tenant has the new one.
+func (r *FileUserRepository) Update(user *user.User) error {
+	return r.write(func() error {
+		if err := updateUser(r.users, user); err != nil {
+			return err
+		}
+		r.outbox.add(user.PullEvents())
+		return nil
+	})
+}
+
+// FindByID finds a user of a tenant by ID
+func (r *FileUserRepository) FindByID(tenantID, id string) (*user.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return findUserByID(r.users, tenantID, id)
+}
+
+// FindByEmail finds a user of a tenant by email
+func (r *FileUserRepository) FindByEmail(tenantID, email string) (*user.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	user, exists := r.users[userKey{tenantID, email}]
+	if !exists {
+		return nil, errors.New("user not found")
+	}
+
+	return copyUser(user), nil
+}
+
+// FindAll returns every user of a tenant ordered by email
+func (r *FileUserRepository) FindAll(tenantID string) ([]*user.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	var users []*user.User
+	for key, u := range r.users {
+		if key.tenantID == tenantID {
+			users = append(users, copyUser(u))
+		}
+	}
+	sortUsers(users)
+
+	return users, nil
+}
+
+// Pending returns up to limit outbox entries due at now, oldest first
+func (r *FileUserRepository) Pending(now time.Time, limit int) ([]event.OutboxEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r.outbox.pending(now, limit), nil
+}
+
+// MarkDispatched removes the outbox entry of a dispatched event and persists the store
+func (r *FileUserRepository) MarkDispatched(eventID string) error {
+	return r.write(func() error {
+		return r.outbox.remove(eventID)
+	})
+}
+
+// MarkFailed records a failed dispatch of an outbox event and persists the store
+func (r *FileUserRepository) MarkFailed(eventID, reason string, retryAt time.Time) error {
+	return r.write(func() error {
+		return r.outbox.fail(eventID, reason, retryAt)
+	})
+}
+
+// write applies a change to the latest version of the store and persists it, holding the
+// file lock so no other process writes in between
+func (r *FileUserRepository) write(change func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	unlock, err := lockFile(r.lockPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := r.reload(); err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	if err := r.persist(); err != nil {
+		// The cache may now differ from the file; read it again next time
+		r.loaded = nil
+		return err
+	}
+	return nil
+}
+
+// reload reads the store file into the cache unless the cache already holds its current
+// version, and reports whether old records were migrated and need to be written back.
+// The caller must hold the lock.
+func (r *FileUserRepository) reload() (bool, error) {
+	f, err := os.Open(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if r.loaded != nil && os.SameFile(r.loaded, info) && info.ModTime().Equal(r.loaded.ModTime()) && info.Size() == r.loaded.Size() {
+		return false, nil
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return false, err
+	}
+
+	var store userStoreFile
+	migrated := false
+	// Stores written before the outbox was introduced hold a bare list of users
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &store.Users); err != nil {
+			return false, err
+		}
+		migrated = true
+	} else if err := json.Unmarshal(data, &store); err != nil {
+		return false, err
+	}
+
+	users := make(map[userKey]*user.User, len(store.Users))
+	for _, u := range store.Users {
+		// Users stored before IDs were introduced get a stable one on load
+		if u.ID == "" {
+			u.ID = user.NewID()
+			migrated = true
+		}
+		// Users stored before tenants were introduced belong to the default tenant
+		if u.TenantID == "" {
+			u.TenantID = tenant.DefaultID
+			migrated = true
+		}
+		users[userKey{u.TenantID, u.Email}] = u
+	}
+
+	r.users = users
+	r.outbox = store.Outbox
+	r.loaded = info
+	return migrated, nil
+}
+
+// lockPath returns the path of the file that serializes writers of the store
+func (r *FileUserRepository) lockPath() string {
+	return r.path + ".lock"
+}
+
+// sortedUsers returns every stored user ordered by tenant and email. The caller must hold the lock.
+func (r *FileUserRepository) sortedUsers() []*user.User {
+	users := make([]*user.User, 0, len(r.users))
+	for _, u := range r.users {
+		users = append(users, u)
+	}
+	sortUsers(users)
+	return users
+}
+
+// persist atomically rewrites the store file. The caller must hold the lock and the file lock.
+func (r *FileUserRepository) persist() error {
+	data, err := json.MarshalIndent(userStoreFile{Users: r.sortedUsers(), Outbox: r.outbox}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	r.loaded = info
+	return nil
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/apikey"
+)
+
+// errAPIKeyNotFound is returned for keys that are not stored
+var errAPIKeyNotFound = errors.New("api key not found")
+
+// InMemoryAPIKeyRepository is an in-memory implementation of the API key repository.
+// Keys are copied in and out, so callers never share the stored values.
+type InMemoryAPIKeyRepository struct {
+	keys map[string]apikey.APIKey
+	mu   sync.RWMutex
+}
+
+// NewInMemoryAPIKeyRepository creates a new in-memory API key repository
+func NewInMemoryAPIKeyRepository() *InMemoryAPIKeyRepository {
+	return &InMemoryAPIKeyRepository{
+		keys: make(map[string]apikey.APIKey),
+	}
+}
+
+// Save stores a new API key
+func (r *InMemoryAPIKeyRepository) Save(key *apikey.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.keys[key.ID]; exists {
+		return errors.New("api key already exists")
+	}
+
+	r.keys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+// Update replaces an existing API key
+func (r *InMemoryAPIKeyRepository) Update(key *apikey.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.keys[key.ID]; !exists {
+		return errAPIKeyNotFound
+	}
+
+	r.keys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+// UpdateLastUsed records when a key was last used without touching its other fields, so it
+// cannot undo a concurrent revocation
+func (r *InMemoryAPIKeyRepository) UpdateLastUsed(id string, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return errAPIKeyNotFound
+	}
+
+	key.LastUsedAt = &usedAt
+	r.keys[id] = key
+	return nil
+}
+
+// FindByID finds an API key by ID
+func (r *InMemoryAPIKeyRepository) FindByID(id string) (*apikey.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return nil, errAPIKeyNotFound
+	}
+
+	key = copyAPIKey(&key)
+	return &key, nil
+}
+
+// FindByPrefix finds an API key by its public prefix
+func (r *InMemoryAPIKeyRepository) FindByPrefix(prefix string) (*apikey.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Prefix == prefix {
+			key = copyAPIKey(&key)
+			return &key, nil
+		}
+	}
+
+	return nil, errAPIKeyNotFound
+}
+
+// FindByOwner returns the keys of an owner, newest first
+func (r *InMemoryAPIKeyRepository) FindByOwner(ownerType apikey.OwnerType, ownerID string) ([]*apikey.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var keys []*apikey.APIKey
+	for _, key := range r.keys {
+		if key.OwnerType == ownerType && key.OwnerID == ownerID {
+			key = copyAPIKey(&key)
+			keys = append(keys, &key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+
+	return keys, nil
+}
+
+// copyAPIKey copies a key so callers never share its scopes or timestamps
+func copyAPIKey(k *apikey.APIKey) apikey.APIKey {
+	c := *k
+	c.Scopes = append([]string(nil), k.Scopes...)
+	c.ExpiresAt = copyTime(k.ExpiresAt)
+	c.LastUsedAt = copyTime(k.LastUsedAt)
+	c.RevokedAt = copyTime(k.RevokedAt)
+	return c
+}
+
+// copyTime copies an optional timestamp
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/oauth"
+)
+
+// InMemoryAuthorizationCodeRepository is an in-memory implementation of the authorization code repository
+type InMemoryAuthorizationCodeRepository struct {
+	codes map[string]oauth.AuthorizationCode
+	mu    sync.RWMutex
+}
+
+// NewInMemoryAuthorizationCodeRepository creates a new in-memory authorization code repository
+func NewInMemoryAuthorizationCodeRepository() *InMemoryAuthorizationCodeRepository {
+	return &InMemoryAuthorizationCodeRepository{
+		codes: make(map[string]oauth.AuthorizationCode),
+	}
+}
+
+// Save stores a new authorization code
+func (r *InMemoryAuthorizationCodeRepository) Save(code *oauth.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.codes[code.CodeHash]; exists {
+		return errors.New("authorization code already exists")
+	}
+
+	r.codes[code.CodeHash] = *code
+	return nil
+}
+
+// MarkUsed records the redemption of an authorization code that has not been used yet
+func (r *InMemoryAuthorizationCodeRepository) MarkUsed(codeHash string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, exists := r.codes[codeHash]
+	if !exists {
+		return oauth.ErrNotFound
+	}
+	if code.UsedAt != nil {
+		return oauth.ErrCodeUsed
+	}
+
+	code.UsedAt = &at
+	r.codes[codeHash] = code
+	return nil
+}
+
+// FindByHash finds an authorization code by the hash of the code
+func (r *InMemoryAuthorizationCodeRepository) FindByHash(codeHash string) (*oauth.AuthorizationCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	code, exists := r.codes[codeHash]
+	if !exists {
+		return nil, oauth.ErrNotFound
+	}
+
+	return &code, nil
+}
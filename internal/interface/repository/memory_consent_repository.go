@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/oauth"
+)
+
+// consentKey identifies the consent of a user for a client
+type consentKey struct {
+	userID   string
+	clientID string
+}
+
+// InMemoryConsentRepository is an in-memory implementation of the consent repository
+type InMemoryConsentRepository struct {
+	consents map[consentKey]oauth.Consent
+	mu       sync.RWMutex
+}
+
+// NewInMemoryConsentRepository creates a new in-memory consent repository
+func NewInMemoryConsentRepository() *InMemoryConsentRepository {
+	return &InMemoryConsentRepository{
+		consents: make(map[consentKey]oauth.Consent),
+	}
+}
+
+// Save stores a consent, replacing any previous consent of the user for the client
+func (r *InMemoryConsentRepository) Save(consent *oauth.Consent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.consents[consentKey{consent.UserID, consent.ClientID}] = *consent
+	return nil
+}
+
+// Find finds the consent of a user for a client
+func (r *InMemoryConsentRepository) Find(userID, clientID string) (*oauth.Consent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	consent, exists := r.consents[consentKey{userID, clientID}]
+	if !exists {
+		return nil, oauth.ErrNotFound
+	}
+
+	return &consent, nil
+}
+
+// FindByUser returns the consents of a user, most recent first
+func (r *InMemoryConsentRepository) FindByUser(userID string) ([]*oauth.Consent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var consents []*oauth.Consent
+	for key, consent := range r.consents {
+		if key.userID == userID {
+			consent := consent
+			consents = append(consents, &consent)
+		}
+	}
+	sort.Slice(consents, func(i, j int) bool { return consents[i].GrantedAt.After(consents[j].GrantedAt) })
+
+	return consents, nil
+}
+
+// Delete removes the consent of a user for a client
+func (r *InMemoryConsentRepository) Delete(userID, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := consentKey{userID, clientID}
+	if _, exists := r.consents[key]; !exists {
+		return oauth.ErrNotFound
+	}
+
+	delete(r.consents, key)
+	return nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/group"
+)
+
+// InMemoryGroupRepository is an in-memory implementation of the group repository
+type InMemoryGroupRepository struct {
+	groups map[string]group.Group
+	mu     sync.RWMutex
+}
+
+// NewInMemoryGroupRepository creates a new in-memory group repository
+func NewInMemoryGroupRepository() *InMemoryGroupRepository {
+	return &InMemoryGroupRepository{
+		groups: make(map[string]group.Group),
+	}
+}
+
+// Save stores a new group
+func (r *InMemoryGroupRepository) Save(g *group.Group) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(g) {
+		return group.ErrDuplicateName
+	}
+
+	r.groups[g.ID] = copyGroup(g)
+	return nil
+}
+
+// Update replaces an existing group
+func (r *InMemoryGroupRepository) Update(g *group.Group) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.groups[g.ID]; !exists {
+		return group.ErrNotFound
+	}
+	if r.nameTaken(g) {
+		return group.ErrDuplicateName
+	}
+
+	r.groups[g.ID] = copyGroup(g)
+	return nil
+}
+
+// Delete removes a group
+func (r *InMemoryGroupRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.groups[id]; !exists {
+		return group.ErrNotFound
+	}
+
+	delete(r.groups, id)
+	return nil
+}
+
+// FindByID finds a group of a tenant by ID
+func (r *InMemoryGroupRepository) FindByID(tenantID, id string) (*group.Group, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	g, exists := r.groups[id]
+	if !exists || g.TenantID != tenantID {
+		return nil, group.ErrNotFound
+	}
+
+	g = copyGroup(&g)
+	return &g, nil
+}
+
+// FindAll returns every group of a tenant ordered by display name
+func (r *InMemoryGroupRepository) FindAll(tenantID string) ([]*group.Group, error) {
+	return r.find(func(g *group.Group) bool { return g.TenantID == tenantID })
+}
+
+// FindByMember returns the groups a user belongs to, ordered by display name
+func (r *InMemoryGroupRepository) FindByMember(userID string) ([]*group.Group, error) {
+	return r.find(func(g *group.Group) bool { return g.HasMember(userID) })
+}
+
+func (r *InMemoryGroupRepository) find(match func(*group.Group) bool) ([]*group.Group, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	groups := make([]*group.Group, 0, len(r.groups))
+	for _, g := range r.groups {
+		if match(&g) {
+			g = copyGroup(&g)
+			groups = append(groups, &g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.ToLower(groups[i].DisplayName) < strings.ToLower(groups[j].DisplayName)
+	})
+
+	return groups, nil
+}
+
+// nameTaken reports whether another group of the tenant already has the display name of g.
+// Callers hold the lock.
+func (r *InMemoryGroupRepository) nameTaken(g *group.Group) bool {
+	for _, other := range r.groups {
+		if other.ID != g.ID && other.TenantID == g.TenantID && strings.EqualFold(other.DisplayName, g.DisplayName) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyGroup copies a group so callers never share the stored member slice
+func copyGroup(g *group.Group) group.Group {
+	c := *g
+	c.MemberIDs = append([]string(nil), g.MemberIDs...)
+	return c
+}
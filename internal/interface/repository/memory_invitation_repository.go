@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/org"
+)
+
+// InMemoryInvitationRepository is an in-memory implementation of the invitation repository
+type InMemoryInvitationRepository struct {
+	invitations map[string]org.Invitation
+	mu          sync.RWMutex
+}
+
+// NewInMemoryInvitationRepository creates a new in-memory invitation repository
+func NewInMemoryInvitationRepository() *InMemoryInvitationRepository {
+	return &InMemoryInvitationRepository{
+		invitations: make(map[string]org.Invitation),
+	}
+}
+
+// Save stores a new invitation
+func (r *InMemoryInvitationRepository) Save(inv *org.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.invitations[inv.ID] = *inv
+	return nil
+}
+
+// Update replaces an existing invitation
+func (r *InMemoryInvitationRepository) Update(inv *org.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.invitations[inv.ID]; !exists {
+		return org.ErrInvitationNotFound
+	}
+
+	r.invitations[inv.ID] = *inv
+	return nil
+}
+
+// Delete removes an invitation
+func (r *InMemoryInvitationRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.invitations[id]; !exists {
+		return org.ErrInvitationNotFound
+	}
+
+	delete(r.invitations, id)
+	return nil
+}
+
+// FindByID finds an invitation by ID
+func (r *InMemoryInvitationRepository) FindByID(id string) (*org.Invitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	inv, exists := r.invitations[id]
+	if !exists {
+		return nil, org.ErrInvitationNotFound
+	}
+
+	return &inv, nil
+}
+
+// FindByOrg returns the invitations of an organization, most recent first
+func (r *InMemoryInvitationRepository) FindByOrg(orgID string) ([]*org.Invitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var invitations []*org.Invitation
+	for _, inv := range r.invitations {
+		if inv.OrgID == orgID {
+			inv := inv
+			invitations = append(invitations, &inv)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].CreatedAt.After(invitations[j].CreatedAt) })
+
+	return invitations, nil
+}
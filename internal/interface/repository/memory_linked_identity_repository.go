@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+)
+
+// InMemoryLinkedIdentityRepository is an in-memory implementation of the linked identity repository
+type InMemoryLinkedIdentityRepository struct {
+	// links is keyed by tenant, provider and subject
+	links map[[3]string]identity.LinkedIdentity
+	mu    sync.RWMutex
+}
+
+// NewInMemoryLinkedIdentityRepository creates a new in-memory linked identity repository
+func NewInMemoryLinkedIdentityRepository() *InMemoryLinkedIdentityRepository {
+	return &InMemoryLinkedIdentityRepository{
+		links: make(map[[3]string]identity.LinkedIdentity),
+	}
+}
+
+// Save stores a new link
+func (r *InMemoryLinkedIdentityRepository) Save(link *identity.LinkedIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [3]string{link.TenantID, link.Provider, link.Subject}
+	if _, exists := r.links[key]; exists {
+		return identity.ErrAlreadyLinked
+	}
+
+	r.links[key] = *link
+	return nil
+}
+
+// Update replaces an existing link
+func (r *InMemoryLinkedIdentityRepository) Update(link *identity.LinkedIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [3]string{link.TenantID, link.Provider, link.Subject}
+	if _, exists := r.links[key]; !exists {
+		return identity.ErrNotFound
+	}
+
+	r.links[key] = *link
+	return nil
+}
+
+// FindBySubject finds the link for a provider account in a tenant
+func (r *InMemoryLinkedIdentityRepository) FindBySubject(tenantID, provider, subject string) (*identity.LinkedIdentity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	link, exists := r.links[[3]string{tenantID, provider, subject}]
+	if !exists {
+		return nil, identity.ErrNotFound
+	}
+
+	return &link, nil
+}
+
+// FindByUser returns the links of a user, ordered by provider
+func (r *InMemoryLinkedIdentityRepository) FindByUser(userID string) ([]*identity.LinkedIdentity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var links []*identity.LinkedIdentity
+	for _, link := range r.links {
+		if link.UserID == userID {
+			link := link
+			links = append(links, &link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Provider < links[j].Provider })
+
+	return links, nil
+}
+
+// Delete removes a user's link to a provider
+func (r *InMemoryLinkedIdentityRepository) Delete(userID, provider string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, link := range r.links {
+		if link.UserID == userID && link.Provider == provider {
+			delete(r.links, key)
+			return nil
+		}
+	}
+	return identity.ErrNotFound
+}
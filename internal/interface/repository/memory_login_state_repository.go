@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+)
+
+// InMemoryLoginStateRepository is an in-memory implementation of the external login state repository
+type InMemoryLoginStateRepository struct {
+	states map[string]identity.LoginState
+	mu     sync.Mutex
+}
+
+// NewInMemoryLoginStateRepository creates a new in-memory login state repository
+func NewInMemoryLoginStateRepository() *InMemoryLoginStateRepository {
+	return &InMemoryLoginStateRepository{
+		states: make(map[string]identity.LoginState),
+	}
+}
+
+// Save stores a new login state
+func (r *InMemoryLoginStateRepository) Save(state *identity.LoginState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.states[state.ID]; exists {
+		return errors.New("login state already exists")
+	}
+
+	r.states[state.ID] = *state
+	return nil
+}
+
+// Take removes and returns a login state
+func (r *InMemoryLoginStateRepository) Take(id string) (*identity.LoginState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, exists := r.states[id]
+	if !exists {
+		return nil, identity.ErrNotFound
+	}
+
+	delete(r.states, id)
+	return &state, nil
+}
+
+// DeleteExpired removes login states that were never completed
+func (r *InMemoryLoginStateRepository) DeleteExpired(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, state := range r.states {
+		if !now.Before(state.ExpiresAt) {
+			delete(r.states, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/org"
+)
+
+// membershipKey identifies the membership of a user in an organization
+type membershipKey struct {
+	orgID  string
+	userID string
+}
+
+// InMemoryMembershipRepository is an in-memory implementation of the membership repository
+type InMemoryMembershipRepository struct {
+	memberships map[membershipKey]org.Membership
+	mu          sync.RWMutex
+}
+
+// NewInMemoryMembershipRepository creates a new in-memory membership repository
+func NewInMemoryMembershipRepository() *InMemoryMembershipRepository {
+	return &InMemoryMembershipRepository{
+		memberships: make(map[membershipKey]org.Membership),
+	}
+}
+
+// Save stores a membership, replacing any previous membership of the user in the organization
+func (r *InMemoryMembershipRepository) Save(m *org.Membership) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.memberships[membershipKey{m.OrgID, m.UserID}] = *m
+	return nil
+}
+
+// Delete removes the membership of a user in an organization
+func (r *InMemoryMembershipRepository) Delete(orgID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := membershipKey{orgID, userID}
+	if _, exists := r.memberships[key]; !exists {
+		return org.ErrMemberNotFound
+	}
+
+	delete(r.memberships, key)
+	return nil
+}
+
+// Find finds the membership of a user in an organization
+func (r *InMemoryMembershipRepository) Find(orgID, userID string) (*org.Membership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, exists := r.memberships[membershipKey{orgID, userID}]
+	if !exists {
+		return nil, org.ErrMemberNotFound
+	}
+
+	return &m, nil
+}
+
+// FindByOrg returns the members of an organization, oldest first
+func (r *InMemoryMembershipRepository) FindByOrg(orgID string) ([]*org.Membership, error) {
+	return r.find(func(key membershipKey) bool { return key.orgID == orgID })
+}
+
+// FindByUser returns the memberships of a user, oldest first
+func (r *InMemoryMembershipRepository) FindByUser(userID string) ([]*org.Membership, error) {
+	return r.find(func(key membershipKey) bool { return key.userID == userID })
+}
+
+func (r *InMemoryMembershipRepository) find(match func(membershipKey) bool) ([]*org.Membership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var memberships []*org.Membership
+	for key, m := range r.memberships {
+		if match(key) {
+			m := m
+			memberships = append(memberships, &m)
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool { return memberships[i].CreatedAt.Before(memberships[j].CreatedAt) })
+
+	return memberships, nil
+}
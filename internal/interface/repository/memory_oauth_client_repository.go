@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/oauth"
+)
+
+// InMemoryOAuthClientRepository is an in-memory implementation of the OAuth client repository
+type InMemoryOAuthClientRepository struct {
+	clients map[string]*oauth.Client
+	mu      sync.RWMutex
+}
+
+// NewInMemoryOAuthClientRepository creates a new in-memory OAuth client repository
+func NewInMemoryOAuthClientRepository() *InMemoryOAuthClientRepository {
+	return &InMemoryOAuthClientRepository{
+		clients: make(map[string]*oauth.Client),
+	}
+}
+
+// Save stores a new client
+func (r *InMemoryOAuthClientRepository) Save(client *oauth.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.clients[client.ID]; exists {
+		return errors.New("oauth client already exists")
+	}
+
+	r.clients[client.ID] = client
+	return nil
+}
+
+// FindByID finds a client by ID
+func (r *InMemoryOAuthClientRepository) FindByID(id string) (*oauth.Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	client, exists := r.clients[id]
+	if !exists {
+		return nil, oauth.ErrNotFound
+	}
+
+	return client, nil
+}
+
+// FindAll returns all clients ordered by name
+func (r *InMemoryOAuthClientRepository) FindAll() ([]*oauth.Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clients := make([]*oauth.Client, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Name < clients[j].Name })
+
+	return clients, nil
+}
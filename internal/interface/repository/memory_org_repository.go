@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/org"
+)
+
+// InMemoryOrgRepository is an in-memory implementation of the organization repository
+type InMemoryOrgRepository struct {
+	orgs map[string]org.Organization
+	mu   sync.RWMutex
+}
+
+// NewInMemoryOrgRepository creates a new in-memory organization repository
+func NewInMemoryOrgRepository() *InMemoryOrgRepository {
+	return &InMemoryOrgRepository{
+		orgs: make(map[string]org.Organization),
+	}
+}
+
+// Save stores an organization
+func (r *InMemoryOrgRepository) Save(o *org.Organization) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.orgs[o.ID] = *o
+	return nil
+}
+
+// FindByID finds an organization of a tenant by ID
+func (r *InMemoryOrgRepository) FindByID(tenantID, id string) (*org.Organization, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, exists := r.orgs[id]
+	if !exists || o.TenantID != tenantID {
+		return nil, org.ErrNotFound
+	}
+
+	return &o, nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+)
+
+// InMemoryPasswordResetRepository is an in-memory implementation of the password reset repository
+type InMemoryPasswordResetRepository struct {
+	tokens map[string]*user.PasswordResetToken
+	mu     sync.RWMutex
+}
+
+// NewInMemoryPasswordResetRepository creates a new in-memory password reset repository
+func NewInMemoryPasswordResetRepository() *InMemoryPasswordResetRepository {
+	return &InMemoryPasswordResetRepository{
+		tokens: make(map[string]*user.PasswordResetToken),
+	}
+}
+
+// Save stores a reset token
+func (r *InMemoryPasswordResetRepository) Save(token *user.PasswordResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+// FindByHash finds a reset token by its hash
+func (r *InMemoryPasswordResetRepository) FindByHash(tokenHash string) (*user.PasswordResetToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	token, exists := r.tokens[tokenHash]
+	if !exists {
+		return nil, errors.New("reset token not found")
+	}
+
+	return token, nil
+}
+
+// Delete removes a reset token
+func (r *InMemoryPasswordResetRepository) Delete(tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.tokens, tokenHash)
+	return nil
+}
@@ -0,0 +1,41 @@
+package repository
+
+import "sync"
+
+// processedKey identifies an event handled by a consumer
+type processedKey struct {
+	consumer string
+	eventID  string
+}
+
+// InMemoryProcessedEventRepository is an in-memory implementation of the store of events
+// handled by idempotent consumers
+type InMemoryProcessedEventRepository struct {
+	processed map[processedKey]struct{}
+	mu        sync.RWMutex
+}
+
+// NewInMemoryProcessedEventRepository creates a new in-memory processed event repository
+func NewInMemoryProcessedEventRepository() *InMemoryProcessedEventRepository {
+	return &InMemoryProcessedEventRepository{
+		processed: make(map[processedKey]struct{}),
+	}
+}
+
+// Processed reports whether a consumer has handled an event
+func (r *InMemoryProcessedEventRepository) Processed(consumer, eventID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, processed := r.processed[processedKey{consumer, eventID}]
+	return processed, nil
+}
+
+// MarkProcessed records that a consumer has handled an event
+func (r *InMemoryProcessedEventRepository) MarkProcessed(consumer, eventID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.processed[processedKey{consumer, eventID}] = struct{}{}
+	return nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/oauth"
+)
+
+// InMemoryRefreshTokenRepository is an in-memory implementation of the refresh token repository
+type InMemoryRefreshTokenRepository struct {
+	tokens map[string]oauth.RefreshToken
+	mu     sync.RWMutex
+}
+
+// NewInMemoryRefreshTokenRepository creates a new in-memory refresh token repository
+func NewInMemoryRefreshTokenRepository() *InMemoryRefreshTokenRepository {
+	return &InMemoryRefreshTokenRepository{
+		tokens: make(map[string]oauth.RefreshToken),
+	}
+}
+
+// Save stores a new refresh token
+func (r *InMemoryRefreshTokenRepository) Save(token *oauth.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tokens[token.TokenHash]; exists {
+		return errors.New("refresh token already exists")
+	}
+
+	r.tokens[token.TokenHash] = *token
+	return nil
+}
+
+// Update replaces an existing refresh token
+func (r *InMemoryRefreshTokenRepository) Update(token *oauth.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tokens[token.TokenHash]; !exists {
+		return oauth.ErrNotFound
+	}
+
+	r.tokens[token.TokenHash] = *token
+	return nil
+}
+
+// FindByHash finds a refresh token by the hash of the token
+func (r *InMemoryRefreshTokenRepository) FindByHash(tokenHash string) (*oauth.RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	token, exists := r.tokens[tokenHash]
+	if !exists {
+		return nil, oauth.ErrNotFound
+	}
+
+	return &token, nil
+}
+
+// RevokeFamily revokes every token of a rotation family
+func (r *InMemoryRefreshTokenRepository) RevokeFamily(familyID string, at time.Time) error {
+	return r.revokeWhere(at, func(token oauth.RefreshToken) bool {
+		return token.FamilyID == familyID
+	})
+}
+
+// RevokeGrant revokes every token a user granted to a client
+func (r *InMemoryRefreshTokenRepository) RevokeGrant(userID, clientID string, at time.Time) error {
+	return r.revokeWhere(at, func(token oauth.RefreshToken) bool {
+		return token.UserID == userID && token.ClientID == clientID
+	})
+}
+
+// revokeWhere revokes the unrevoked tokens matching match
+func (r *InMemoryRefreshTokenRepository) revokeWhere(at time.Time, match func(oauth.RefreshToken) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, token := range r.tokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &at
+			r.tokens[hash] = token
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"sync"
+	"time"
+)
+
+// InMemoryRevocationRepository is an in-memory implementation of the access token revocation list.
+// Entries are dropped once the revoked token would have expired anyway.
+type InMemoryRevocationRepository struct {
+	revoked map[string]time.Time
+	mu      sync.Mutex
+}
+
+// NewInMemoryRevocationRepository creates a new in-memory revocation repository
+func NewInMemoryRevocationRepository() *InMemoryRevocationRepository {
+	return &InMemoryRevocationRepository{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Revoke records a revoked token ID until expiresAt
+func (r *InMemoryRevocationRepository) Revoke(tokenID string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, expiry := range r.revoked {
+		if !now.Before(expiry) {
+			delete(r.revoked, id)
+		}
+	}
+
+	r.revoked[tokenID] = expiresAt
+	return nil
+}
+
+// IsRevoked reports whether a token ID has been revoked
+func (r *InMemoryRevocationRepository) IsRevoked(tokenID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, revoked := r.revoked[tokenID]
+	return revoked, nil
+}
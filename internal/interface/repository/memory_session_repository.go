@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/session"
+)
+
+// InMemorySessionRepository is an in-memory implementation of the session repository.
+// Sessions are copied in and out so callers can update them without locking.
+type InMemorySessionRepository struct {
+	sessions map[string]session.Session
+	mu       sync.RWMutex
+}
+
+// NewInMemorySessionRepository creates a new in-memory session repository
+func NewInMemorySessionRepository() *InMemorySessionRepository {
+	return &InMemorySessionRepository{
+		sessions: make(map[string]session.Session),
+	}
+}
+
+// Save stores a new session
+func (r *InMemorySessionRepository) Save(s *session.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.sessions[s.ID]; exists {
+		return errors.New("session already exists")
+	}
+
+	r.sessions[s.ID] = *s
+	return nil
+}
+
+// Update replaces an existing session
+func (r *InMemorySessionRepository) Update(s *session.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.sessions[s.ID]; !exists {
+		return session.ErrNotFound
+	}
+
+	r.sessions[s.ID] = *s
+	return nil
+}
+
+// FindByID finds a session by ID
+func (r *InMemorySessionRepository) FindByID(id string) (*session.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, exists := r.sessions[id]
+	if !exists {
+		return nil, session.ErrNotFound
+	}
+
+	return &s, nil
+}
+
+// FindByUser returns the sessions of a user, most recently used first
+func (r *InMemorySessionRepository) FindByUser(userID string) ([]*session.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var sessions []*session.Session
+	for _, s := range r.sessions {
+		if s.UserID == userID {
+			s := s
+			sessions = append(sessions, &s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+
+	return sessions, nil
+}
+
+// Delete removes a session
+func (r *InMemorySessionRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.sessions[id]; !exists {
+		return session.ErrNotFound
+	}
+
+	delete(r.sessions, id)
+	return nil
+}
+
+// DeleteByUser removes all sessions of a user
+func (r *InMemorySessionRepository) DeleteByUser(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, s := range r.sessions {
+		if s.UserID == userID {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired removes sessions that are no longer active
+func (r *InMemorySessionRepository) DeleteExpired(now time.Time, idleTimeout time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, s := range r.sessions {
+		if !s.Active(now, idleTimeout) {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/org"
+)
+
+// InMemoryTeamRepository is an in-memory implementation of the team repository
+type InMemoryTeamRepository struct {
+	teams map[string]org.Team
+	mu    sync.RWMutex
+}
+
+// NewInMemoryTeamRepository creates a new in-memory team repository
+func NewInMemoryTeamRepository() *InMemoryTeamRepository {
+	return &InMemoryTeamRepository{
+		teams: make(map[string]org.Team),
+	}
+}
+
+// Save stores a new team
+func (r *InMemoryTeamRepository) Save(t *org.Team) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(t) {
+		return org.ErrDuplicateTeamName
+	}
+
+	r.teams[t.ID] = copyTeam(t)
+	return nil
+}
+
+// Update replaces an existing team
+func (r *InMemoryTeamRepository) Update(t *org.Team) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.teams[t.ID]; !exists {
+		return org.ErrTeamNotFound
+	}
+	if r.nameTaken(t) {
+		return org.ErrDuplicateTeamName
+	}
+
+	r.teams[t.ID] = copyTeam(t)
+	return nil
+}
+
+// Delete removes a team
+func (r *InMemoryTeamRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.teams[id]; !exists {
+		return org.ErrTeamNotFound
+	}
+
+	delete(r.teams, id)
+	return nil
+}
+
+// FindByID finds a team of an organization by ID
+func (r *InMemoryTeamRepository) FindByID(orgID, id string) (*org.Team, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, exists := r.teams[id]
+	if !exists || t.OrgID != orgID {
+		return nil, org.ErrTeamNotFound
+	}
+
+	t = copyTeam(&t)
+	return &t, nil
+}
+
+// FindByOrg returns the teams of an organization ordered by name
+func (r *InMemoryTeamRepository) FindByOrg(orgID string) ([]*org.Team, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var teams []*org.Team
+	for _, t := range r.teams {
+		if t.OrgID == orgID {
+			t = copyTeam(&t)
+			teams = append(teams, &t)
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		return strings.ToLower(teams[i].Name) < strings.ToLower(teams[j].Name)
+	})
+
+	return teams, nil
+}
+
+// nameTaken reports whether another team of the organization already has the name of t.
+// Callers hold the lock.
+func (r *InMemoryTeamRepository) nameTaken(t *org.Team) bool {
+	for _, other := range r.teams {
+		if other.ID != t.ID && other.OrgID == t.OrgID && strings.EqualFold(other.Name, t.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyTeam copies a team so callers never share the stored member slice
+func copyTeam(t *org.Team) org.Team {
+	c := *t
+	c.MemberIDs = append([]string(nil), t.MemberIDs...)
+	return c
+}
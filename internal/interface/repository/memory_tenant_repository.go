@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+)
+
+// InMemoryTenantRepository is an in-memory implementation of the tenant repository
+type InMemoryTenantRepository struct {
+	tenants map[string]*tenant.Tenant
+	mu      sync.RWMutex
+}
+
+// NewInMemoryTenantRepository creates a new in-memory tenant repository
+func NewInMemoryTenantRepository() *InMemoryTenantRepository {
+	return &InMemoryTenantRepository{
+		tenants: make(map[string]*tenant.Tenant),
+	}
+}
+
+// Save stores a new tenant
+func (r *InMemoryTenantRepository) Save(t *tenant.Tenant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tenants[t.ID]; exists {
+		return errors.New("tenant already exists")
+	}
+
+	r.tenants[t.ID] = t
+	return nil
+}
+
+// FindByID finds a tenant by ID
+func (r *InMemoryTenantRepository) FindByID(id string) (*tenant.Tenant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, exists := r.tenants[id]
+	if !exists {
+		return nil, tenant.ErrNotFound
+	}
+
+	return t, nil
+}
+
+// FindAll returns all tenants ordered by ID
+func (r *InMemoryTenantRepository) FindAll() ([]*tenant.Tenant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenants := make([]*tenant.Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+
+	return tenants, nil
+}
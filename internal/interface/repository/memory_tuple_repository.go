@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/authz"
+)
+
+// tupleKey identifies a tuple within a tenant
+type tupleKey struct {
+	tenantID string
+	tuple    string
+}
+
+// InMemoryTupleRepository is an in-memory implementation of the relation tuple repository
+type InMemoryTupleRepository struct {
+	tuples map[tupleKey]authz.Tuple
+	mu     sync.RWMutex
+}
+
+// NewInMemoryTupleRepository creates a new in-memory relation tuple repository
+func NewInMemoryTupleRepository() *InMemoryTupleRepository {
+	return &InMemoryTupleRepository{
+		tuples: make(map[tupleKey]authz.Tuple),
+	}
+}
+
+// Write stores tuples; tuples that already exist are left unchanged
+func (r *InMemoryTupleRepository) Write(tuples []authz.Tuple) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range tuples {
+		r.tuples[tupleKey{t.TenantID, t.String()}] = t
+	}
+	return nil
+}
+
+// Delete removes tuples; tuples that do not exist are ignored
+func (r *InMemoryTupleRepository) Delete(tuples []authz.Tuple) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range tuples {
+		delete(r.tuples, tupleKey{t.TenantID, t.String()})
+	}
+	return nil
+}
+
+// Read returns the tuples of a tenant selected by filter, ordered by object, relation and subject
+func (r *InMemoryTupleRepository) Read(tenantID string, filter authz.TupleFilter) ([]authz.Tuple, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tuples []authz.Tuple
+	for key, t := range r.tuples {
+		if key.tenantID == tenantID && filter.Matches(t) {
+			tuples = append(tuples, t)
+		}
+	}
+	sort.Slice(tuples, func(i, j int) bool { return tuples[i].String() < tuples[j].String() })
+
+	return tuples, nil
+}
@@ -2,21 +2,33 @@ package repository
 
 import (
 	"errors"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/lamboktulussimamora/gra-project/internal/domain/event"
 	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
 )
 
-// InMemoryUserRepository is an in-memory implementation of the user repository
+// userKey identifies a stored user. Emails are unique within a tenant only.
+type userKey struct {
+	tenantID string
+	email    string
+}
+
+// InMemoryUserRepository is an in-memory implementation of the user repository. It is also
+// the outbox of the events raised by users, written under the same lock as the users.
+// Users are copied in and out, so callers never share the stored values.
 type InMemoryUserRepository struct {
-	users map[string]*user.User
-	mu    sync.RWMutex
+	users  map[userKey]*user.User
+	outbox outboxEntries
+	mu     sync.RWMutex
 }
 
 // NewInMemoryUserRepository creates a new in-memory user repository
 func NewInMemoryUserRepository() *InMemoryUserRepository {
 	return &InMemoryUserRepository{
-		users: make(map[string]*user.User),
+		users: make(map[userKey]*user.User),
 	}
 }
 
@@ -26,24 +38,144 @@ func (r *InMemoryUserRepository) Save(user *user.User) error {
 	defer r.mu.Unlock()
 
 	// Check if user already exists
-	if _, exists := r.users[user.Email]; exists {
+	key := userKey{user.TenantID, user.Email}
+	if _, exists := r.users[key]; exists {
 		return errors.New("user already exists")
 	}
 
-	// Store the user
-	r.users[user.Email] = user
+	// Store the user with the events it raised
+	r.outbox.add(user.PullEvents())
+	r.users[key] = copyUser(user)
+	return nil
+}
+
+// Update replaces an existing user, found by ID, in the in-memory store. The email may change
+// as long as no other user of the tenant has the new one.
+func (r *InMemoryUserRepository) Update(user *user.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := updateUser(r.users, user); err != nil {
+		return err
+	}
+	r.outbox.add(user.PullEvents())
 	return nil
 }
 
-// FindByEmail finds a user by email
-func (r *InMemoryUserRepository) FindByEmail(email string) (*user.User, error) {
+// FindByID finds a user of a tenant by ID
+func (r *InMemoryUserRepository) FindByID(tenantID, id string) (*user.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return findUserByID(r.users, tenantID, id)
+}
+
+// FindByEmail finds a user of a tenant by email
+func (r *InMemoryUserRepository) FindByEmail(tenantID, email string) (*user.User, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	user, exists := r.users[email]
+	user, exists := r.users[userKey{tenantID, email}]
 	if !exists {
 		return nil, errors.New("user not found")
 	}
 
-	return user, nil
+	return copyUser(user), nil
+}
+
+// FindAll returns every user of a tenant ordered by email
+func (r *InMemoryUserRepository) FindAll(tenantID string) ([]*user.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var users []*user.User
+	for key, u := range r.users {
+		if key.tenantID == tenantID {
+			users = append(users, copyUser(u))
+		}
+	}
+	sortUsers(users)
+
+	return users, nil
+}
+
+// Pending returns up to limit outbox entries due at now, oldest first
+func (r *InMemoryUserRepository) Pending(now time.Time, limit int) ([]event.OutboxEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.outbox.pending(now, limit), nil
+}
+
+// MarkDispatched removes the outbox entry of a dispatched event
+func (r *InMemoryUserRepository) MarkDispatched(eventID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.outbox.remove(eventID)
+}
+
+// MarkFailed records a failed dispatch of an outbox event
+func (r *InMemoryUserRepository) MarkFailed(eventID, reason string, retryAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.outbox.fail(eventID, reason, retryAt)
+}
+
+// updateUser replaces a user in a map keyed by tenant and email with a copy of u. The caller
+// may have changed the email, so the old key is looked up by ID. A failed update leaves the
+// stored user as it was. The caller must hold the write lock.
+func updateUser(users map[userKey]*user.User, u *user.User) error {
+	oldKey, exists := userKey{}, false
+	for key, stored := range users {
+		if stored.ID == u.ID && key.tenantID == u.TenantID {
+			oldKey, exists = key, true
+			break
+		}
+	}
+	if !exists {
+		return errors.New("user not found")
+	}
+
+	newKey := userKey{u.TenantID, u.Email}
+	if oldKey != newKey {
+		if _, taken := users[newKey]; taken {
+			return errors.New("user already exists")
+		}
+		delete(users, oldKey)
+	}
+
+	users[newKey] = copyUser(u)
+	return nil
+}
+
+// findUserByID returns a copy of a user of a tenant in a map keyed by tenant and email
+func findUserByID(users map[userKey]*user.User, tenantID, id string) (*user.User, error) {
+	for key, u := range users {
+		if key.tenantID == tenantID && u.ID == id {
+			return copyUser(u), nil
+		}
+	}
+
+	return nil, errors.New("user not found")
+}
+
+// copyUser copies a user so callers never share its roles. The copy carries no recorded
+// events; repositories pull those into the outbox before storing a user.
+func copyUser(u *user.User) *user.User {
+	c := *u
+	c.Roles = append([]string(nil), u.Roles...)
+	c.Recorder = event.Recorder{}
+	return &c
+}
+
+// sortUsers orders users by tenant and email
+func sortUsers(users []*user.User) {
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].TenantID != users[j].TenantID {
+			return users[i].TenantID < users[j].TenantID
+		}
+		return users[i].Email < users[j].Email
+	})
 }
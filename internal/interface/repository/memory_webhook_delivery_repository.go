@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/webhook"
+)
+
+// InMemoryWebhookDeliveryRepository is an in-memory implementation of the webhook delivery repository
+type InMemoryWebhookDeliveryRepository struct {
+	deliveries map[string]webhook.Delivery
+	mu         sync.RWMutex
+}
+
+// NewInMemoryWebhookDeliveryRepository creates a new in-memory webhook delivery repository
+func NewInMemoryWebhookDeliveryRepository() *InMemoryWebhookDeliveryRepository {
+	return &InMemoryWebhookDeliveryRepository{
+		deliveries: make(map[string]webhook.Delivery),
+	}
+}
+
+// Save stores a new delivery
+func (r *InMemoryWebhookDeliveryRepository) Save(d *webhook.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries[d.ID] = copyDelivery(d)
+	return nil
+}
+
+// Update replaces an existing delivery
+func (r *InMemoryWebhookDeliveryRepository) Update(d *webhook.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.deliveries[d.ID]; !exists {
+		return webhook.ErrDeliveryNotFound
+	}
+
+	r.deliveries[d.ID] = copyDelivery(d)
+	return nil
+}
+
+// FindByID finds a delivery of a tenant by ID
+func (r *InMemoryWebhookDeliveryRepository) FindByID(tenantID, id string) (*webhook.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, exists := r.deliveries[id]
+	if !exists || d.TenantID != tenantID {
+		return nil, webhook.ErrDeliveryNotFound
+	}
+
+	d = copyDelivery(&d)
+	return &d, nil
+}
+
+// Find returns the deliveries of a tenant selected by filter, newest first
+func (r *InMemoryWebhookDeliveryRepository) Find(tenantID string, filter webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var deliveries []*webhook.Delivery
+	for _, d := range r.deliveries {
+		if d.TenantID == tenantID && filter.Matches(&d) {
+			d = copyDelivery(&d)
+			deliveries = append(deliveries, &d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+
+	return deliveries, nil
+}
+
+// FindDue returns up to limit pending deliveries due at now, oldest first, with at most
+// perEndpoint of them to any one endpoint
+func (r *InMemoryWebhookDeliveryRepository) FindDue(now time.Time, limit, perEndpoint int) ([]*webhook.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var deliveries []*webhook.Delivery
+	for _, d := range r.deliveries {
+		if d.Status == webhook.StatusPending && !d.NextAttemptAt.After(now) {
+			d = copyDelivery(&d)
+			deliveries = append(deliveries, &d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt)
+	})
+
+	// A backlog of one endpoint leaves room in the batch for the others
+	perEndpointCount := make(map[string]int)
+	due := deliveries[:0]
+	for _, d := range deliveries {
+		if len(due) == limit {
+			break
+		}
+		if perEndpointCount[d.EndpointID] < perEndpoint {
+			perEndpointCount[d.EndpointID]++
+			due = append(due, d)
+		}
+	}
+
+	return due, nil
+}
+
+// copyDelivery copies a delivery so callers never share the stored payload or attempt log
+func copyDelivery(d *webhook.Delivery) webhook.Delivery {
+	c := *d
+	c.Payload = append([]byte(nil), d.Payload...)
+	c.Attempts = append([]webhook.Attempt(nil), d.Attempts...)
+	return c
+}
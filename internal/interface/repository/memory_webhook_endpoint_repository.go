@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/webhook"
+)
+
+// InMemoryWebhookEndpointRepository is an in-memory implementation of the webhook endpoint repository
+type InMemoryWebhookEndpointRepository struct {
+	endpoints map[string]webhook.Endpoint
+	mu        sync.RWMutex
+}
+
+// NewInMemoryWebhookEndpointRepository creates a new in-memory webhook endpoint repository
+func NewInMemoryWebhookEndpointRepository() *InMemoryWebhookEndpointRepository {
+	return &InMemoryWebhookEndpointRepository{
+		endpoints: make(map[string]webhook.Endpoint),
+	}
+}
+
+// Save stores a new endpoint
+func (r *InMemoryWebhookEndpointRepository) Save(e *webhook.Endpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.endpoints[e.ID] = copyEndpoint(e)
+	return nil
+}
+
+// Update replaces an existing endpoint
+func (r *InMemoryWebhookEndpointRepository) Update(e *webhook.Endpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, exists := r.endpoints[e.ID]; !exists || stored.TenantID != e.TenantID {
+		return webhook.ErrEndpointNotFound
+	}
+
+	r.endpoints[e.ID] = copyEndpoint(e)
+	return nil
+}
+
+// Delete removes an endpoint of a tenant
+func (r *InMemoryWebhookEndpointRepository) Delete(tenantID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, exists := r.endpoints[id]; !exists || e.TenantID != tenantID {
+		return webhook.ErrEndpointNotFound
+	}
+
+	delete(r.endpoints, id)
+	return nil
+}
+
+// FindByID finds an endpoint of a tenant by ID
+func (r *InMemoryWebhookEndpointRepository) FindByID(tenantID, id string) (*webhook.Endpoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, exists := r.endpoints[id]
+	if !exists || e.TenantID != tenantID {
+		return nil, webhook.ErrEndpointNotFound
+	}
+
+	e = copyEndpoint(&e)
+	return &e, nil
+}
+
+// FindByTenant returns the endpoints of a tenant, oldest first
+func (r *InMemoryWebhookEndpointRepository) FindByTenant(tenantID string) ([]*webhook.Endpoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var endpoints []*webhook.Endpoint
+	for _, e := range r.endpoints {
+		if e.TenantID == tenantID {
+			e = copyEndpoint(&e)
+			endpoints = append(endpoints, &e)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].CreatedAt.Before(endpoints[j].CreatedAt)
+	})
+
+	return endpoints, nil
+}
+
+// copyEndpoint copies an endpoint so callers never share the stored event slice
+func copyEndpoint(e *webhook.Endpoint) webhook.Endpoint {
+	c := *e
+	c.Events = append([]string(nil), e.Events...)
+	return c
+}
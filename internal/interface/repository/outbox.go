@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/event"
+)
+
+// errOutboxEntryNotFound is returned for events that are not, or no longer, in an outbox
+var errOutboxEntryNotFound = errors.New("outbox entry not found")
+
+// outboxEntries holds the outbox of a repository in the order events were written. The
+// repository's lock guards it, so entries are added in the same critical section as the
+// change that raised them.
+type outboxEntries []event.OutboxEntry
+
+// add appends events that are due for dispatch immediately
+func (o *outboxEntries) add(events []event.Event) {
+	for _, e := range events {
+		*o = append(*o, event.OutboxEntry{Event: e, NextAttemptAt: e.OccurredAt})
+	}
+}
+
+// pending returns up to limit entries due at now, oldest first
+func (o outboxEntries) pending(now time.Time, limit int) []event.OutboxEntry {
+	var due []event.OutboxEntry
+	for _, entry := range o {
+		if len(due) == limit {
+			break
+		}
+		if !entry.NextAttemptAt.After(now) {
+			due = append(due, entry)
+		}
+	}
+	return due
+}
+
+// remove deletes the entry of an event
+func (o *outboxEntries) remove(eventID string) error {
+	for i, entry := range *o {
+		if entry.Event.ID == eventID {
+			*o = append((*o)[:i], (*o)[i+1:]...)
+			return nil
+		}
+	}
+	return errOutboxEntryNotFound
+}
+
+// fail records a failed dispatch of an event
+func (o outboxEntries) fail(eventID, reason string, retryAt time.Time) error {
+	for i := range o {
+		if o[i].Event.ID == eventID {
+			o[i].Attempts++
+			o[i].LastError = reason
+			o[i].NextAttemptAt = retryAt
+			return nil
+		}
+	}
+	return errOutboxEntryNotFound
+}
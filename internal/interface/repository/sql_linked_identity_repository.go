@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+)
+
+// linkedIdentityColumns lists the linked identity columns in scan order
+const linkedIdentityColumns = "id, tenant_id, user_id, provider, subject, email, created_at, last_login_at"
+
+// SQLLinkedIdentityRepository stores linked identities in a SQL database through database/sql.
+// Timestamps are stored as Unix nanoseconds so the schema works on any driver.
+type SQLLinkedIdentityRepository struct {
+	db    *sql.DB
+	table string
+	// dollarPlaceholders selects $1 style placeholders instead of ?
+	dollarPlaceholders bool
+}
+
+// NewSQLLinkedIdentityRepository creates a linked identity repository on an open database.
+// The driver name selects the placeholder style.
+func NewSQLLinkedIdentityRepository(db *sql.DB, driverName string) *SQLLinkedIdentityRepository {
+	return &SQLLinkedIdentityRepository{
+		db:                 db,
+		table:              "linked_identities",
+		dollarPlaceholders: driverName == "postgres" || driverName == "pgx",
+	}
+}
+
+// OpenSQLLinkedIdentityRepository opens a database, checks the connection and creates the schema
+func OpenSQLLinkedIdentityRepository(driverName, dsn string) (*SQLLinkedIdentityRepository, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	repo := NewSQLLinkedIdentityRepository(db, driverName)
+	if err := repo.CreateSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// CreateSchema creates the linked identities table and its indexes if they do not exist.
+// A provider account can only be linked once in each tenant.
+func (r *SQLLinkedIdentityRepository) CreateSchema() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS ` + r.table + ` (
+			id VARCHAR(64) PRIMARY KEY,
+			tenant_id VARCHAR(63) NOT NULL,
+			user_id VARCHAR(64) NOT NULL,
+			provider VARCHAR(64) NOT NULL,
+			subject VARCHAR(255) NOT NULL,
+			email VARCHAR(320) NOT NULL,
+			created_at BIGINT NOT NULL,
+			last_login_at BIGINT NOT NULL,
+			UNIQUE (tenant_id, provider, subject)
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + r.table + `_user_id_idx ON ` + r.table + ` (user_id)`,
+	}
+
+	for _, stmt := range statements {
+		if _, err := r.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create linked identity schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Save stores a new link. The unique constraint also rejects a concurrent duplicate.
+func (r *SQLLinkedIdentityRepository) Save(link *identity.LinkedIdentity) error {
+	if _, err := r.FindBySubject(link.TenantID, link.Provider, link.Subject); err == nil {
+		return identity.ErrAlreadyLinked
+	}
+
+	_, err := r.db.Exec(
+		r.query("INSERT INTO "+r.table+" ("+linkedIdentityColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		link.ID, link.TenantID, link.UserID, link.Provider, link.Subject, link.Email,
+		link.CreatedAt.UnixNano(), link.LastLoginAt.UnixNano(),
+	)
+	return err
+}
+
+// Update stores the mutable fields of an existing link
+func (r *SQLLinkedIdentityRepository) Update(link *identity.LinkedIdentity) error {
+	result, err := r.db.Exec(
+		r.query("UPDATE "+r.table+" SET email = ?, last_login_at = ? WHERE tenant_id = ? AND provider = ? AND subject = ?"),
+		link.Email, link.LastLoginAt.UnixNano(), link.TenantID, link.Provider, link.Subject,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffectedOr(result, identity.ErrNotFound)
+}
+
+// FindBySubject finds the link for a provider account in a tenant
+func (r *SQLLinkedIdentityRepository) FindBySubject(tenantID, provider, subject string) (*identity.LinkedIdentity, error) {
+	row := r.db.QueryRow(
+		r.query("SELECT "+linkedIdentityColumns+" FROM "+r.table+" WHERE tenant_id = ? AND provider = ? AND subject = ?"),
+		tenantID, provider, subject,
+	)
+
+	link, err := scanLinkedIdentity(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, identity.ErrNotFound
+	}
+	return link, err
+}
+
+// FindByUser returns the links of a user, ordered by provider
+func (r *SQLLinkedIdentityRepository) FindByUser(userID string) ([]*identity.LinkedIdentity, error) {
+	rows, err := r.db.Query(
+		r.query("SELECT "+linkedIdentityColumns+" FROM "+r.table+" WHERE user_id = ? ORDER BY provider"),
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*identity.LinkedIdentity
+	for rows.Next() {
+		link, err := scanLinkedIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// Delete removes a user's link to a provider
+func (r *SQLLinkedIdentityRepository) Delete(userID, provider string) error {
+	result, err := r.db.Exec(r.query("DELETE FROM "+r.table+" WHERE user_id = ? AND provider = ?"), userID, provider)
+	if err != nil {
+		return err
+	}
+	return requireAffectedOr(result, identity.ErrNotFound)
+}
+
+// query rewrites ? placeholders for drivers that use numbered placeholders
+func (r *SQLLinkedIdentityRepository) query(q string) string {
+	return rebind(q, r.dollarPlaceholders)
+}
+
+// scanLinkedIdentity reads a linked identity row in linkedIdentityColumns order
+func scanLinkedIdentity(row rowScanner) (*identity.LinkedIdentity, error) {
+	var link identity.LinkedIdentity
+	var createdAt, lastLoginAt int64
+	if err := row.Scan(&link.ID, &link.TenantID, &link.UserID, &link.Provider, &link.Subject, &link.Email, &createdAt, &lastLoginAt); err != nil {
+		return nil, err
+	}
+
+	link.CreatedAt = time.Unix(0, createdAt)
+	link.LastLoginAt = time.Unix(0, lastLoginAt)
+	return &link, nil
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/session"
+)
+
+// sessionColumns lists the session columns in scan order
+const sessionColumns = "id, user_id, csrf_token, user_agent, ip_address, created_at, last_seen_at, expires_at"
+
+// SQLSessionRepository stores sessions in a SQL database through database/sql.
+// Timestamps are stored as Unix nanoseconds so the schema works on any driver.
+type SQLSessionRepository struct {
+	db    *sql.DB
+	table string
+	// dollarPlaceholders selects $1 style placeholders instead of ?
+	dollarPlaceholders bool
+}
+
+// NewSQLSessionRepository creates a session repository on an open database. The driver name
+// selects the placeholder style; the driver itself must be linked into the binary.
+func NewSQLSessionRepository(db *sql.DB, driverName string) *SQLSessionRepository {
+	return &SQLSessionRepository{
+		db:                 db,
+		table:              "sessions",
+		dollarPlaceholders: driverName == "postgres" || driverName == "pgx",
+	}
+}
+
+// OpenSQLSessionRepository opens a database, checks the connection and creates the session schema
+func OpenSQLSessionRepository(driverName, dsn string) (*SQLSessionRepository, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	repo := NewSQLSessionRepository(db, driverName)
+	if err := repo.CreateSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// CreateSchema creates the sessions table and its user index if they do not exist
+func (r *SQLSessionRepository) CreateSchema() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS ` + r.table + ` (
+			id VARCHAR(64) PRIMARY KEY,
+			user_id VARCHAR(64) NOT NULL,
+			csrf_token VARCHAR(64) NOT NULL,
+			user_agent VARCHAR(512) NOT NULL,
+			ip_address VARCHAR(64) NOT NULL,
+			created_at BIGINT NOT NULL,
+			last_seen_at BIGINT NOT NULL,
+			expires_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + r.table + `_user_id_idx ON ` + r.table + ` (user_id)`,
+	}
+
+	for _, stmt := range statements {
+		if _, err := r.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create session schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Save stores a new session
+func (r *SQLSessionRepository) Save(s *session.Session) error {
+	_, err := r.db.Exec(
+		r.query("INSERT INTO "+r.table+" ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		s.ID, s.UserID, s.CSRFToken, s.UserAgent, s.IPAddress,
+		s.CreatedAt.UnixNano(), s.LastSeenAt.UnixNano(), s.ExpiresAt.UnixNano(),
+	)
+	return err
+}
+
+// Update stores the mutable fields of an existing session
+func (r *SQLSessionRepository) Update(s *session.Session) error {
+	result, err := r.db.Exec(
+		r.query("UPDATE "+r.table+" SET csrf_token = ?, last_seen_at = ?, expires_at = ? WHERE id = ?"),
+		s.CSRFToken, s.LastSeenAt.UnixNano(), s.ExpiresAt.UnixNano(), s.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// FindByID finds a session by ID
+func (r *SQLSessionRepository) FindByID(id string) (*session.Session, error) {
+	row := r.db.QueryRow(r.query("SELECT "+sessionColumns+" FROM "+r.table+" WHERE id = ?"), id)
+
+	s, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, session.ErrNotFound
+	}
+	return s, err
+}
+
+// FindByUser returns the sessions of a user, most recently used first
+func (r *SQLSessionRepository) FindByUser(userID string) ([]*session.Session, error) {
+	rows, err := r.db.Query(
+		r.query("SELECT "+sessionColumns+" FROM "+r.table+" WHERE user_id = ? ORDER BY last_seen_at DESC"),
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*session.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Delete removes a session
+func (r *SQLSessionRepository) Delete(id string) error {
+	result, err := r.db.Exec(r.query("DELETE FROM "+r.table+" WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// DeleteByUser removes all sessions of a user
+func (r *SQLSessionRepository) DeleteByUser(userID string) error {
+	_, err := r.db.Exec(r.query("DELETE FROM "+r.table+" WHERE user_id = ?"), userID)
+	return err
+}
+
+// DeleteExpired removes sessions that are no longer active
+func (r *SQLSessionRepository) DeleteExpired(now time.Time, idleTimeout time.Duration) error {
+	if idleTimeout <= 0 {
+		_, err := r.db.Exec(r.query("DELETE FROM "+r.table+" WHERE expires_at <= ?"), now.UnixNano())
+		return err
+	}
+
+	_, err := r.db.Exec(
+		r.query("DELETE FROM "+r.table+" WHERE expires_at <= ? OR last_seen_at <= ?"),
+		now.UnixNano(), now.Add(-idleTimeout).UnixNano(),
+	)
+	return err
+}
+
+// query rewrites ? placeholders for drivers that use numbered placeholders
+func (r *SQLSessionRepository) query(q string) string {
+	return rebind(q, r.dollarPlaceholders)
+}
+
+// rebind rewrites ? placeholders as $1, $2, ... when dollar is set
+func rebind(q string, dollar bool) string {
+	if !dollar {
+		return q
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range q {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSession reads a session row in sessionColumns order
+func scanSession(row rowScanner) (*session.Session, error) {
+	var s session.Session
+	var createdAt, lastSeenAt, expiresAt int64
+	if err := row.Scan(&s.ID, &s.UserID, &s.CSRFToken, &s.UserAgent, &s.IPAddress, &createdAt, &lastSeenAt, &expiresAt); err != nil {
+		return nil, err
+	}
+
+	s.CreatedAt = time.Unix(0, createdAt)
+	s.LastSeenAt = time.Unix(0, lastSeenAt)
+	s.ExpiresAt = time.Unix(0, expiresAt)
+	return &s, nil
+}
+
+// requireAffected maps an update or delete that matched no rows to session.ErrNotFound
+func requireAffected(result sql.Result) error {
+	return requireAffectedOr(result, session.ErrNotFound)
+}
+
+// requireAffectedOr maps an update or delete that matched no rows to notFound
+func requireAffectedOr(result sql.Result, notFound error) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/authz"
+)
+
+// tupleColumns lists the relation tuple columns in scan order
+const tupleColumns = "tenant_id, object_type, object_id, relation, subject_type, subject_id, subject_relation"
+
+// SQLTupleRepository stores relation tuples in a SQL database through database/sql. A subject
+// without a relation is stored with an empty subject_relation.
+type SQLTupleRepository struct {
+	db    *sql.DB
+	table string
+	// dollarPlaceholders selects $1 style placeholders instead of ?
+	dollarPlaceholders bool
+}
+
+// NewSQLTupleRepository creates a relation tuple repository on an open database. The driver
+// name selects the placeholder style.
+func NewSQLTupleRepository(db *sql.DB, driverName string) *SQLTupleRepository {
+	return &SQLTupleRepository{
+		db:                 db,
+		table:              "relation_tuples",
+		dollarPlaceholders: driverName == "postgres" || driverName == "pgx",
+	}
+}
+
+// OpenSQLTupleRepository opens a database, checks the connection and creates the schema
+func OpenSQLTupleRepository(driverName, dsn string) (*SQLTupleRepository, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	repo := NewSQLTupleRepository(db, driverName)
+	if err := repo.CreateSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// CreateSchema creates the relation tuples table and its subject index if they do not exist.
+// Checks read tuples by object and relation, which the primary key covers.
+func (r *SQLTupleRepository) CreateSchema() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS ` + r.table + ` (
+			tenant_id VARCHAR(63) NOT NULL,
+			object_type VARCHAR(64) NOT NULL,
+			object_id VARCHAR(128) NOT NULL,
+			relation VARCHAR(64) NOT NULL,
+			subject_type VARCHAR(64) NOT NULL,
+			subject_id VARCHAR(128) NOT NULL,
+			subject_relation VARCHAR(64) NOT NULL,
+			PRIMARY KEY (` + tupleColumns + `)
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + r.table + `_subject_idx ON ` + r.table + ` (tenant_id, subject_type, subject_id)`,
+	}
+
+	for _, stmt := range statements {
+		if _, err := r.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create relation tuple schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Write stores tuples in one transaction; tuples that already exist are left unchanged
+func (r *SQLTupleRepository) Write(tuples []authz.Tuple) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, t := range tuples {
+		var count int
+		err := tx.QueryRow(
+			r.query("SELECT COUNT(*) FROM "+r.table+" WHERE "+tupleMatch),
+			tupleArgs(t)...,
+		).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		if _, err := tx.Exec(r.query("INSERT INTO "+r.table+" ("+tupleColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)"), tupleArgs(t)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete removes tuples in one transaction; tuples that do not exist are ignored
+func (r *SQLTupleRepository) Delete(tuples []authz.Tuple) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, t := range tuples {
+		if _, err := tx.Exec(r.query("DELETE FROM "+r.table+" WHERE "+tupleMatch), tupleArgs(t)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Read returns the tuples of a tenant selected by filter, ordered by object, relation and subject
+func (r *SQLTupleRepository) Read(tenantID string, filter authz.TupleFilter) ([]authz.Tuple, error) {
+	conditions := []string{"tenant_id = ?"}
+	args := []interface{}{tenantID}
+	for _, c := range []struct{ column, value string }{
+		{"object_type", filter.ObjectType},
+		{"object_id", filter.ObjectID},
+		{"relation", filter.Relation},
+	} {
+		if c.value != "" {
+			conditions = append(conditions, c.column+" = ?")
+			args = append(args, c.value)
+		}
+	}
+	if filter.Subject != nil {
+		conditions = append(conditions, "subject_type = ?", "subject_id = ?", "subject_relation = ?")
+		args = append(args, filter.Subject.Type, filter.Subject.ID, filter.Subject.Relation)
+	}
+
+	rows, err := r.db.Query(
+		r.query("SELECT "+tupleColumns+" FROM "+r.table+" WHERE "+strings.Join(conditions, " AND ")+
+			" ORDER BY object_type, object_id, relation, subject_type, subject_id, subject_relation"),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tuples []authz.Tuple
+	for rows.Next() {
+		var t authz.Tuple
+		if err := rows.Scan(&t.TenantID, &t.Object.Type, &t.Object.ID, &t.Relation, &t.Subject.Type, &t.Subject.ID, &t.Subject.Relation); err != nil {
+			return nil, err
+		}
+		tuples = append(tuples, t)
+	}
+	return tuples, rows.Err()
+}
+
+// query rewrites ? placeholders for drivers that use numbered placeholders
+func (r *SQLTupleRepository) query(q string) string {
+	return rebind(q, r.dollarPlaceholders)
+}
+
+// tupleMatch selects one tuple by all of its columns, in tupleArgs order
+const tupleMatch = "tenant_id = ? AND object_type = ? AND object_id = ? AND relation = ? AND subject_type = ? AND subject_id = ? AND subject_relation = ?"
+
+// tupleArgs returns the column values of a tuple in tupleColumns order
+func tupleArgs(t authz.Tuple) []interface{} {
+	return []interface{}{t.TenantID, t.Object.Type, t.Object.ID, t.Relation, t.Subject.Type, t.Subject.ID, t.Subject.Relation}
+}
@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"errors"
+	"log"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// statusError maps a use case error to a gRPC status, as the HTTP handlers map them to
+// response codes. Unexpected errors are logged and hidden from the caller.
+func statusError(err error) error {
+	var validationErrs validation.Errors
+	switch {
+	case errors.As(err, &validationErrs):
+		return validationStatus(validationErrs)
+	case errors.Is(err, usecase.ErrInvalidCredentials):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, usecase.ErrUserExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, usecase.ErrAccountDisabled), errors.Is(err, usecase.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, usecase.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, usecase.ErrServiceBusy):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		log.Printf("gRPC call failed: %v", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// validationStatus reports every invalid field as a BadRequest detail
+func validationStatus(errs validation.Errors) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(errs))
+	for i, e := range errs {
+		violations[i] = &errdetails.BadRequest_FieldViolation{Field: e.Field, Description: e.Message}
+	}
+	st, err := status.New(codes.InvalidArgument, errs.Error()).WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, errs.Error())
+	}
+	return st.Err()
+}
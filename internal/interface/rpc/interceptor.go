@@ -0,0 +1,132 @@
+// Package rpc serves the user and authentication use cases over gRPC, alongside the HTTP API
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/middleware"
+)
+
+// TenantInterceptor resolves the tenant of each call from a metadata key, the gRPC
+// counterpart of the tenant header. A call that selects no tenant belongs to the default tenant.
+type TenantInterceptor struct {
+	tenants tenant.Repository
+	key     string
+}
+
+// NewTenantInterceptor creates a tenant interceptor reading the tenant ID from the metadata key
+func NewTenantInterceptor(tenants tenant.Repository, key string) *TenantInterceptor {
+	return &TenantInterceptor{tenants: tenants, key: strings.ToLower(key)}
+}
+
+// Unary stores the tenant ID in the context. Calls for an unknown tenant fail with NotFound so
+// they never reach another tenant's data.
+func (i *TenantInterceptor) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	tenantID := tenant.DefaultID
+	if value := strings.TrimSpace(firstMetadata(ctx, i.key)); value != "" {
+		tenantID = strings.ToLower(value)
+	}
+	if _, err := i.tenants.FindByID(tenantID); err != nil {
+		return nil, status.Error(codes.NotFound, "unknown tenant")
+	}
+	return handler(common.WithTenant(ctx, tenantID), req)
+}
+
+// AuthInterceptor authenticates calls with a JWT in the authorization metadata and stores the
+// principal and its claims in the context, as the auth middleware does for HTTP requests
+type AuthInterceptor struct {
+	jwtService  auth.JWTService
+	revocations middleware.TokenRevocationChecker
+	accounts    middleware.AccountStatusChecker
+	public      map[string]bool
+}
+
+// AuthOption configures optional checks of the auth interceptor
+type AuthOption func(*AuthInterceptor)
+
+// WithTokenRevocation rejects JWTs whose ID has been revoked, such as revoked OAuth access tokens
+func WithTokenRevocation(revocations middleware.TokenRevocationChecker) AuthOption {
+	return func(i *AuthInterceptor) {
+		i.revocations = revocations
+	}
+}
+
+// WithAccountStatus rejects JWTs of users whose account has since been disabled or deleted
+func WithAccountStatus(accounts middleware.AccountStatusChecker) AuthOption {
+	return func(i *AuthInterceptor) {
+		i.accounts = accounts
+	}
+}
+
+// NewAuthInterceptor creates an auth interceptor. Calls to the public methods, given by full
+// method name, are let through without credentials.
+func NewAuthInterceptor(jwtService auth.JWTService, publicMethods []string, opts ...AuthOption) *AuthInterceptor {
+	i := &AuthInterceptor{
+		jwtService: jwtService,
+		public:     make(map[string]bool, len(publicMethods)),
+	}
+	for _, method := range publicMethods {
+		i.public[method] = true
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Unary authenticates calls to methods that are not public. Calls without a valid token for
+// their tenant fail with Unauthenticated.
+func (i *AuthInterceptor) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if i.public[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	token, ok := strings.CutPrefix(firstMetadata(ctx, "authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata must be Bearer <token>")
+	}
+	claims, err := i.validate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return handler(middleware.WithPrincipal(ctx, auth.NewPrincipalFromClaims(claims)), req)
+}
+
+// validate checks a token for the tenant of the call
+func (i *AuthInterceptor) validate(ctx context.Context, token string) (*auth.Claims, error) {
+	claims, err := i.jwtService.ValidateToken(token)
+	if err != nil {
+		if err == auth.ErrExpiredToken {
+			return nil, status.Error(codes.Unauthenticated, "token has expired")
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if i.revocations != nil && claims.ID != "" && i.revocations.IsTokenRevoked(claims.ID) {
+		return nil, status.Error(codes.Unauthenticated, "token has been revoked")
+	}
+	if claims.Tenant() != common.TenantFromContext(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "token is not valid for this tenant")
+	}
+	if i.accounts != nil && auth.NewPrincipalFromClaims(claims).Type == auth.PrincipalUser && !i.accounts.IsAccountActive(claims.Tenant(), claims.Subject) {
+		return nil, status.Error(codes.Unauthenticated, "account is disabled")
+	}
+	return claims, nil
+}
+
+// firstMetadata returns the first incoming metadata value of a key, or "" if there is none
+func firstMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
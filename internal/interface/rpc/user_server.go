@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	userv1 "github.com/lamboktulussimamora/gra-project/api/proto/user/v1"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/common"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// PublicMethods are the user service methods callable without a token
+var PublicMethods = []string{
+	userv1.UserService_Register_FullMethodName,
+	userv1.UserService_Login_FullMethodName,
+	userv1.UserService_ValidateToken_FullMethodName,
+}
+
+// UserServer implements the gRPC user service on top of the user use case
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+	userUseCase *usecase.UserUseCase
+	auth        *AuthInterceptor
+}
+
+// NewUserServer creates a user service. ValidateToken checks tokens like the auth interceptor does.
+func NewUserServer(userUseCase *usecase.UserUseCase, authInterceptor *AuthInterceptor) *UserServer {
+	return &UserServer{
+		userUseCase: userUseCase,
+		auth:        authInterceptor,
+	}
+}
+
+// NewServer creates a gRPC server with the user service. Each call is resolved to its tenant
+// before it is authenticated.
+func NewServer(userUseCase *usecase.UserUseCase, jwtService auth.JWTService, tenants tenant.Repository, tenantKey string, opts ...AuthOption) *grpc.Server {
+	tenantInterceptor := NewTenantInterceptor(tenants, tenantKey)
+	authInterceptor := NewAuthInterceptor(jwtService, PublicMethods, opts...)
+
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(tenantInterceptor.Unary, authInterceptor.Unary))
+	userv1.RegisterUserServiceServer(server, NewUserServer(userUseCase, authInterceptor))
+	return server
+}
+
+// Register creates a user with a local password
+func (s *UserServer) Register(ctx context.Context, req *userv1.RegisterRequest) (*userv1.RegisterResponse, error) {
+	resp, err := s.userUseCase.Register(common.TenantFromContext(ctx), req.GetFirstName(), req.GetLastName(), req.GetEmail(), req.GetPassword())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &userv1.RegisterResponse{User: newUserMessage(resp)}, nil
+}
+
+// Login checks an email and password and issues a JWT
+func (s *UserServer) Login(ctx context.Context, req *userv1.LoginRequest) (*userv1.LoginResponse, error) {
+	resp, err := s.userUseCase.Login(common.TenantFromContext(ctx), req.GetEmail(), req.GetPassword())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &userv1.LoginResponse{User: newUserMessage(&resp.User), Token: resp.Token}, nil
+}
+
+// ValidateToken checks a JWT for the tenant of the call and returns its claims
+func (s *UserServer) ValidateToken(ctx context.Context, req *userv1.ValidateTokenRequest) (*userv1.ValidateTokenResponse, error) {
+	claims, err := s.auth.validate(ctx, req.GetToken())
+	if err != nil {
+		return nil, err
+	}
+	return &userv1.ValidateTokenResponse{Claims: newClaimsMessage(claims)}, nil
+}
+
+// GetUser returns a user, or the caller when no ID is given
+func (s *UserServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	principal, ok := common.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	id := req.GetId()
+	if id == "" {
+		id = principal.Subject
+	}
+
+	resp, err := s.userUseCase.GetUser(principal, id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &userv1.GetUserResponse{User: newUserMessage(resp)}, nil
+}
+
+// newUserMessage converts a user response to its message
+func newUserMessage(u *usecase.UserResponse) *userv1.User {
+	return &userv1.User{
+		Id:        u.ID,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		CreatedAt: timestamppb.New(u.CreatedAt),
+		UpdatedAt: timestamppb.New(u.UpdatedAt),
+	}
+}
+
+// newClaimsMessage converts token claims to their message
+func newClaimsMessage(claims *auth.Claims) *userv1.Claims {
+	msg := &userv1.Claims{
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		FirstName: claims.FirstName,
+		LastName:  claims.LastName,
+		Roles:     claims.Roles,
+		TenantId:  claims.Tenant(),
+		Scopes:    claims.Scopes(),
+		ClientId:  claims.ClientID,
+	}
+	if claims.ExpiresAt != nil {
+		msg.ExpiresAt = timestamppb.New(claims.ExpiresAt.Time)
+	}
+	return msg
+}
@@ -0,0 +1,136 @@
+package saml
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// IdPMetadata is what a service provider needs from an identity provider's metadata
+type IdPMetadata struct {
+	EntityID     string
+	SSOURL       string
+	Certificates []*x509.Certificate
+}
+
+// ParseIdPMetadata reads the entity ID, HTTP-Redirect SSO URL and signing certificates from
+// identity provider metadata. For an EntitiesDescriptor the first identity provider is used.
+func ParseIdPMetadata(data []byte) (*IdPMetadata, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, fmt.Errorf("malformed metadata: %w", err)
+	}
+	root := doc.Root()
+	if root == nil {
+		return nil, errors.New("empty metadata")
+	}
+
+	entities := []*etree.Element{root}
+	if is(root, nsMetadata, "EntitiesDescriptor") {
+		entities = children(root, nsMetadata, "EntityDescriptor")
+	}
+	for _, entity := range entities {
+		if !is(entity, nsMetadata, "EntityDescriptor") {
+			continue
+		}
+		idp := child(entity, nsMetadata, "IDPSSODescriptor")
+		if idp == nil {
+			continue
+		}
+
+		metadata := &IdPMetadata{EntityID: entity.SelectAttrValue("entityID", "")}
+		for _, sso := range children(idp, nsMetadata, "SingleSignOnService") {
+			if sso.SelectAttrValue("Binding", "") == bindingRedirect {
+				metadata.SSOURL = sso.SelectAttrValue("Location", "")
+				break
+			}
+		}
+		for _, key := range children(idp, nsMetadata, "KeyDescriptor") {
+			if use := key.SelectAttrValue("use", ""); use != "" && use != "signing" {
+				continue
+			}
+			certs, err := keyCertificates(key)
+			if err != nil {
+				return nil, err
+			}
+			metadata.Certificates = append(metadata.Certificates, certs...)
+		}
+
+		if metadata.EntityID == "" || metadata.SSOURL == "" || len(metadata.Certificates) == 0 {
+			return nil, errors.New("metadata needs an entity ID, HTTP-Redirect SSO service and signing certificate")
+		}
+		return metadata, nil
+	}
+	return nil, errors.New("metadata has no identity provider")
+}
+
+// keyCertificates parses the X.509 certificates in a KeyDescriptor
+func keyCertificates(key *etree.Element) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	info := child(key, nsDSig, "KeyInfo")
+	if info == nil {
+		return nil, nil
+	}
+	for _, data := range children(info, nsDSig, "X509Data") {
+		for _, el := range children(data, nsDSig, "X509Certificate") {
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(el.Text()), ""))
+			if err != nil {
+				return nil, fmt.Errorf("malformed certificate in metadata: %w", err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("malformed certificate in metadata: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+// ParseCertificatesPEM parses the PEM certificates an identity provider signs with
+func ParseCertificatesPEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// LoadIdPMetadata reads identity provider metadata from a file
+func LoadIdPMetadata(path string) (*IdPMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIdPMetadata(data)
+}
+
+// LoadCertificates reads PEM certificates from a file
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCertificatesPEM(data)
+}
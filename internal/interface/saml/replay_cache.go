@@ -0,0 +1,47 @@
+package saml
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayCache remembers the assertions that were accepted while they are still valid
+type ReplayCache interface {
+	// Remember records an assertion ID until expiresAt. It returns false if the ID was
+	// already recorded and has not expired.
+	Remember(id string, expiresAt time.Time) bool
+}
+
+// MemoryReplayCache is a ReplayCache for a single instance
+type MemoryReplayCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryReplayCache creates an empty in-memory replay cache
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{seen: make(map[string]time.Time)}
+}
+
+// Remember records an assertion ID until expiresAt
+func (c *MemoryReplayCache) Remember(id string, expiresAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) > time.Minute {
+		for seenID, until := range c.seen {
+			if !now.Before(until) {
+				delete(c.seen, seenID)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if until, ok := c.seen[id]; ok && now.Before(until) {
+		return false
+	}
+	c.seen[id] = expiresAt
+	return true
+}
@@ -0,0 +1,486 @@
+// Package saml implements the identity.Provider port for SAML 2.0 identity providers, acting as
+// the service provider in the Web Browser SSO profile
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+// SAML namespaces, bindings and identifiers
+const (
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	nsDSig      = "http://www.w3.org/2000/09/xmldsig#"
+
+	bindingRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	bindingPOST     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+
+	statusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	nameIDEmail        = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	confirmationBearer = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+)
+
+// timeFormat is the xs:dateTime layout SAML uses, always in UTC
+const timeFormat = "2006-01-02T15:04:05Z"
+
+// defaultClockSkew is the difference between the identity provider's clock and ours that is tolerated
+const defaultClockSkew = 2 * time.Minute
+
+// AttributeMap names the assertion attributes a profile is read from. Empty fields fall back to
+// the names common identity providers use.
+type AttributeMap struct {
+	Email      string
+	GivenName  string
+	FamilyName string
+	Name       string
+}
+
+// Attribute names tried in order when an AttributeMap field is empty
+var (
+	defaultEmailAttributes = []string{"email", "mail", "urn:oid:0.9.2342.19200300.100.1.3",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"}
+	defaultGivenNameAttributes = []string{"givenName", "firstName", "urn:oid:2.5.4.42",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname"}
+	defaultFamilyNameAttributes = []string{"sn", "surname", "lastName", "urn:oid:2.5.4.4",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname"}
+	defaultNameAttributes = []string{"displayName", "name", "urn:oid:2.16.840.1.113730.3.1.241",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name"}
+)
+
+// Config describes this service provider and the identity provider it trusts
+type Config struct {
+	Name string
+	// EntityID identifies this service provider, conventionally its metadata URL
+	EntityID string
+	// ACSURL is the assertion consumer service URL the identity provider posts responses to
+	ACSURL string
+	// IdPEntityID, IdPSSOURL and IdPCertificates can be read from the identity provider's
+	// metadata with ParseIdPMetadata. More than one certificate allows a key rollover.
+	IdPEntityID     string
+	IdPSSOURL       string
+	IdPCertificates []*x509.Certificate
+	Attributes      AttributeMap
+	// TrustEmail treats asserted addresses as verified. SAML has no verified flag, so without it
+	// logins only reach accounts that are already linked.
+	TrustEmail bool
+	ClockSkew  time.Duration
+}
+
+// ServiceProvider signs users in through a SAML identity provider. It sends AuthnRequests with
+// the HTTP-Redirect binding and accepts signed responses with the HTTP-POST binding. Unsolicited
+// responses and encrypted assertions are not supported.
+type ServiceProvider struct {
+	config Config
+	replay ReplayCache
+}
+
+// NewServiceProvider creates a service provider. A nil replay cache keeps assertion IDs in memory.
+func NewServiceProvider(config Config, replay ReplayCache) (*ServiceProvider, error) {
+	if config.Name == "" || config.EntityID == "" || config.ACSURL == "" {
+		return nil, errors.New("saml provider needs a name, entity ID and ACS URL")
+	}
+	if config.IdPEntityID == "" || config.IdPSSOURL == "" || len(config.IdPCertificates) == 0 {
+		return nil, fmt.Errorf("saml provider %q needs the identity provider's entity ID, SSO URL and certificate", config.Name)
+	}
+	if config.ClockSkew <= 0 {
+		config.ClockSkew = defaultClockSkew
+	}
+	if replay == nil {
+		replay = NewMemoryReplayCache()
+	}
+
+	return &ServiceProvider{config: config, replay: replay}, nil
+}
+
+// Name identifies the provider
+func (p *ServiceProvider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL returns the identity provider URL carrying a deflated AuthnRequest. The state is
+// sent as the RelayState and the request ID is derived from the nonce. SAML has no PKCE, so the
+// code challenge is ignored.
+func (p *ServiceProvider) AuthCodeURL(_ context.Context, state, nonce, _ string) (string, error) {
+	doc := etree.NewDocument()
+	req := doc.CreateElement("samlp:AuthnRequest")
+	req.CreateAttr("xmlns:samlp", nsProtocol)
+	req.CreateAttr("xmlns:saml", nsAssertion)
+	req.CreateAttr("ID", requestID(nonce))
+	req.CreateAttr("Version", "2.0")
+	req.CreateAttr("IssueInstant", time.Now().UTC().Format(timeFormat))
+	req.CreateAttr("Destination", p.config.IdPSSOURL)
+	req.CreateAttr("AssertionConsumerServiceURL", p.config.ACSURL)
+	req.CreateAttr("ProtocolBinding", bindingPOST)
+	req.CreateElement("saml:Issuer").SetText(p.config.EntityID)
+	req.CreateElement("samlp:NameIDPolicy").CreateAttr("AllowCreate", "true")
+
+	data, err := doc.WriteToBytes()
+	if err != nil {
+		return "", err
+	}
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(p.config.IdPSSOURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	query.Set("RelayState", state)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Exchange verifies a base64 SAMLResponse posted to the assertion consumer service and returns
+// the profile it asserts. The response must answer the request made with the same nonce.
+func (p *ServiceProvider) Exchange(_ context.Context, samlResponse, _, nonce string) (*identity.Profile, error) {
+	// Identity providers may wrap the base64 text
+	raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(samlResponse), ""))
+	if err != nil {
+		return nil, fmt.Errorf("malformed SAMLResponse: %w", err)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return nil, fmt.Errorf("malformed SAMLResponse: %w", err)
+	}
+	root := doc.Root()
+	if root == nil || !is(root, nsProtocol, "Response") {
+		return nil, errors.New("SAMLResponse is not a Response")
+	}
+
+	return p.parseResponse(root, requestID(nonce))
+}
+
+// Metadata returns this service provider's metadata for the identity provider to import
+func (p *ServiceProvider) Metadata() ([]byte, error) {
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+	entity := doc.CreateElement("md:EntityDescriptor")
+	entity.CreateAttr("xmlns:md", nsMetadata)
+	entity.CreateAttr("entityID", p.config.EntityID)
+
+	sp := entity.CreateElement("md:SPSSODescriptor")
+	sp.CreateAttr("protocolSupportEnumeration", nsProtocol)
+	sp.CreateAttr("AuthnRequestsSigned", "false")
+	sp.CreateAttr("WantAssertionsSigned", "true")
+	sp.CreateElement("md:NameIDFormat").SetText(nameIDEmail)
+
+	acs := sp.CreateElement("md:AssertionConsumerService")
+	acs.CreateAttr("Binding", bindingPOST)
+	acs.CreateAttr("Location", p.config.ACSURL)
+	acs.CreateAttr("index", "0")
+	acs.CreateAttr("isDefault", "true")
+
+	doc.Indent(2)
+	return doc.WriteToBytes()
+}
+
+// parseResponse checks the response and its single assertion. Only elements returned by
+// signature validation are read from, so content wrapped around a signed element is ignored.
+func (p *ServiceProvider) parseResponse(response *etree.Element, requestID string) (*identity.Profile, error) {
+	responseSigned := false
+	if hasSignature(response) {
+		verified, err := p.validate(response)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response signature: %w", err)
+		}
+		response, responseSigned = verified, true
+	}
+
+	if err := p.checkResponse(response, requestID); err != nil {
+		return nil, err
+	}
+
+	if len(children(response, nsAssertion, "EncryptedAssertion")) > 0 {
+		return nil, errors.New("encrypted assertions are not supported")
+	}
+	assertions := children(response, nsAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, fmt.Errorf("expected one assertion, got %d", len(assertions))
+	}
+	assertion := assertions[0]
+
+	if hasSignature(assertion) {
+		verified, err := p.validate(assertion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid assertion signature: %w", err)
+		}
+		assertion = verified
+	} else if !responseSigned {
+		return nil, errors.New("neither the response nor the assertion is signed")
+	}
+
+	return p.checkAssertion(assertion, requestID)
+}
+
+// validate verifies an element's enveloped signature against the identity provider's
+// certificates and returns the signed content
+func (p *ServiceProvider) validate(el *etree.Element) (*etree.Element, error) {
+	// Detach the element with the namespaces it inherits so it can be verified on its own
+	ctx, err := etreeutils.NSBuildParentContext(el)
+	if err != nil {
+		return nil, err
+	}
+	detached, err := etreeutils.NSDetatch(ctx, el)
+	if err != nil {
+		return nil, err
+	}
+
+	validator := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: p.config.IdPCertificates,
+	})
+	return validator.Validate(detached)
+}
+
+// checkResponse checks the response envelope
+func (p *ServiceProvider) checkResponse(response *etree.Element, requestID string) error {
+	if response.SelectAttrValue("Version", "") != "2.0" {
+		return errors.New("unsupported SAML version")
+	}
+	if dest := response.SelectAttrValue("Destination", ""); dest != "" && dest != p.config.ACSURL {
+		return fmt.Errorf("response is for %q", dest)
+	}
+	if response.SelectAttrValue("InResponseTo", "") != requestID {
+		return errors.New("response does not answer this login request")
+	}
+	if issuer := child(response, nsAssertion, "Issuer"); issuer != nil && strings.TrimSpace(issuer.Text()) != p.config.IdPEntityID {
+		return fmt.Errorf("response issued by %q", strings.TrimSpace(issuer.Text()))
+	}
+
+	var code *etree.Element
+	if status := child(response, nsProtocol, "Status"); status != nil {
+		code = child(status, nsProtocol, "StatusCode")
+	}
+	if code == nil {
+		return errors.New("response has no status")
+	}
+	if value := code.SelectAttrValue("Value", ""); value != statusSuccess {
+		return fmt.Errorf("identity provider answered %s", value)
+	}
+	return nil
+}
+
+// checkAssertion checks the assertion's issuer, subject confirmation, conditions and audience,
+// records its ID against replays and maps its attributes to a profile
+func (p *ServiceProvider) checkAssertion(assertion *etree.Element, requestID string) (*identity.Profile, error) {
+	now := time.Now()
+
+	id := assertion.SelectAttrValue("ID", "")
+	if id == "" {
+		return nil, errors.New("assertion has no ID")
+	}
+	issuer := child(assertion, nsAssertion, "Issuer")
+	if issuer == nil || strings.TrimSpace(issuer.Text()) != p.config.IdPEntityID {
+		return nil, errors.New("assertion is not from the identity provider")
+	}
+
+	subject := child(assertion, nsAssertion, "Subject")
+	if subject == nil {
+		return nil, errors.New("assertion has no subject")
+	}
+	nameID := child(subject, nsAssertion, "NameID")
+	if nameID == nil || strings.TrimSpace(nameID.Text()) == "" {
+		return nil, errors.New("assertion has no NameID")
+	}
+	confirmedUntil, err := p.checkConfirmation(subject, requestID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := child(assertion, nsAssertion, "Conditions")
+	if conditions == nil {
+		return nil, errors.New("assertion has no conditions")
+	}
+	notBefore, err := timeAttr(conditions, "NotBefore")
+	if err != nil {
+		return nil, err
+	}
+	notOnOrAfter, err := timeAttr(conditions, "NotOnOrAfter")
+	if err != nil {
+		return nil, err
+	}
+	if !notBefore.IsZero() && now.Add(p.config.ClockSkew).Before(notBefore) {
+		return nil, errors.New("assertion is not yet valid")
+	}
+	if !notOnOrAfter.IsZero() && !now.Add(-p.config.ClockSkew).Before(notOnOrAfter) {
+		return nil, errors.New("assertion has expired")
+	}
+	if err := p.checkAudience(conditions); err != nil {
+		return nil, err
+	}
+
+	// Remember the assertion until every validity window has passed
+	expiresAt := confirmedUntil
+	if !notOnOrAfter.IsZero() && notOnOrAfter.Before(expiresAt) {
+		expiresAt = notOnOrAfter
+	}
+	if !p.replay.Remember(p.config.IdPEntityID+" "+id, expiresAt.Add(p.config.ClockSkew)) {
+		return nil, errors.New("assertion was already used")
+	}
+
+	return p.profile(assertion, nameID), nil
+}
+
+// checkConfirmation requires a bearer confirmation for this service's ACS URL and request and
+// returns when it expires
+func (p *ServiceProvider) checkConfirmation(subject *etree.Element, requestID string, now time.Time) (time.Time, error) {
+	for _, confirmation := range children(subject, nsAssertion, "SubjectConfirmation") {
+		if confirmation.SelectAttrValue("Method", "") != confirmationBearer {
+			continue
+		}
+		data := child(confirmation, nsAssertion, "SubjectConfirmationData")
+		if data == nil {
+			continue
+		}
+		notOnOrAfter, err := timeAttr(data, "NotOnOrAfter")
+		if err != nil {
+			return time.Time{}, err
+		}
+		// A bearer confirmation must expire, or the assertion could be replayed forever
+		if notOnOrAfter.IsZero() || !now.Add(-p.config.ClockSkew).Before(notOnOrAfter) {
+			continue
+		}
+		if data.SelectAttrValue("Recipient", "") != p.config.ACSURL {
+			continue
+		}
+		if inResponseTo := data.SelectAttrValue("InResponseTo", ""); inResponseTo != "" && inResponseTo != requestID {
+			continue
+		}
+		return notOnOrAfter, nil
+	}
+	return time.Time{}, errors.New("assertion has no valid bearer confirmation for this service")
+}
+
+// checkAudience requires every audience restriction to include this service provider
+func (p *ServiceProvider) checkAudience(conditions *etree.Element) error {
+	restrictions := children(conditions, nsAssertion, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return errors.New("assertion has no audience restriction")
+	}
+	for _, restriction := range restrictions {
+		found := false
+		for _, audience := range children(restriction, nsAssertion, "Audience") {
+			found = found || strings.TrimSpace(audience.Text()) == p.config.EntityID
+		}
+		if !found {
+			return errors.New("assertion is not for this service")
+		}
+	}
+	return nil
+}
+
+// profile maps the assertion's NameID and attributes to a profile
+func (p *ServiceProvider) profile(assertion, nameID *etree.Element) *identity.Profile {
+	attributes := make(map[string]string)
+	if statement := child(assertion, nsAssertion, "AttributeStatement"); statement != nil {
+		for _, attribute := range children(statement, nsAssertion, "Attribute") {
+			name := attribute.SelectAttrValue("Name", "")
+			if value := child(attribute, nsAssertion, "AttributeValue"); value != nil && name != "" {
+				attributes[name] = strings.TrimSpace(value.Text())
+			}
+		}
+	}
+	lookup := func(configured string, defaults []string) string {
+		if configured != "" {
+			defaults = []string{configured}
+		}
+		for _, name := range defaults {
+			if value := attributes[name]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	subject := strings.TrimSpace(nameID.Text())
+	email := lookup(p.config.Attributes.Email, defaultEmailAttributes)
+	if email == "" && nameID.SelectAttrValue("Format", "") == nameIDEmail {
+		email = subject
+	}
+
+	return &identity.Profile{
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: email != "" && p.config.TrustEmail,
+		GivenName:     lookup(p.config.Attributes.GivenName, defaultGivenNameAttributes),
+		FamilyName:    lookup(p.config.Attributes.FamilyName, defaultFamilyNameAttributes),
+		Name:          lookup(p.config.Attributes.Name, defaultNameAttributes),
+	}
+}
+
+// requestID derives an xs:ID-safe AuthnRequest ID from a login nonce
+func requestID(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+	return "_" + hex.EncodeToString(sum[:16])
+}
+
+// hasSignature reports whether el carries an enveloped signature
+func hasSignature(el *etree.Element) bool {
+	return child(el, nsDSig, "Signature") != nil
+}
+
+// is reports whether el is the named element
+func is(el *etree.Element, ns, tag string) bool {
+	return el.Tag == tag && el.NamespaceURI() == ns
+}
+
+// child returns the first child element with the given namespace and name
+func child(el *etree.Element, ns, tag string) *etree.Element {
+	for _, c := range el.ChildElements() {
+		if is(c, ns, tag) {
+			return c
+		}
+	}
+	return nil
+}
+
+// children returns the child elements with the given namespace and name
+func children(el *etree.Element, ns, tag string) []*etree.Element {
+	var found []*etree.Element
+	for _, c := range el.ChildElements() {
+		if is(c, ns, tag) {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// timeAttr parses an optional xs:dateTime attribute
+func timeAttr(el *etree.Element, name string) (time.Time, error) {
+	value := el.SelectAttrValue(name, "")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return t, nil
+}
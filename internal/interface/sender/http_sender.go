@@ -0,0 +1,102 @@
+// Package sender provides implementations of the webhook.Sender port
+package sender
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/webhook"
+)
+
+// maxResponseBytes bounds how much of a response is read before the connection is reused
+const maxResponseBytes = 64 << 10
+
+// ErrPrivateAddress is returned when an endpoint resolves to a loopback, private or
+// link-local address
+var ErrPrivateAddress = errors.New("webhook endpoint resolves to a private address")
+
+// HTTPSender posts webhook payloads over HTTP. Redirects are not followed, so an endpoint
+// cannot bounce deliveries to another host.
+type HTTPSender struct {
+	client       *http.Client
+	allowPrivate bool
+}
+
+// HTTPSenderOption configures an HTTPSender
+type HTTPSenderOption func(*HTTPSender)
+
+// WithPrivateNetworks lets deliveries reach loopback, private and link-local addresses, for
+// local development or endpoints on an internal network
+func WithPrivateNetworks() HTTPSenderOption {
+	return func(s *HTTPSender) {
+		s.allowPrivate = true
+	}
+}
+
+// NewHTTPSender creates a new HTTP sender whose requests time out after timeout. Connections
+// to private addresses are refused once the host name is resolved, so a DNS record that
+// changes after the endpoint was registered cannot point deliveries at the internal network.
+func NewHTTPSender(timeout time.Duration, opts ...HTTPSenderOption) *HTTPSender {
+	s := &HTTPSender{}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	dialer := &net.Dialer{Timeout: timeout, Control: s.checkAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// Deliveries go straight to the endpoint; through a proxy only the proxy's address
+	// would be checked
+	transport.Proxy = nil
+
+	s.client = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return s
+}
+
+// checkAddress refuses connections to private addresses unless they are allowed
+func (s *HTTPSender) checkAddress(network, address string, _ syscall.RawConn) error {
+	if s.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !webhook.PublicAddress(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// Send posts the request body and returns the response status code
+func (s *HTTPSender) Send(req webhook.Request) (int, error) {
+	httpReq, err := http.NewRequest(http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return 0, err
+	}
+	for name, value := range req.Headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	return resp.StatusCode, nil
+}
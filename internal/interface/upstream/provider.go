@@ -0,0 +1,398 @@
+// Package upstream implements the identity.Provider port for external OpenID Connect and OAuth 2.0 providers
+package upstream
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+)
+
+// keyRefreshInterval limits how often an unknown key ID triggers a JWKS refetch
+const keyRefreshInterval = time.Minute
+
+// maxResponseSize bounds the documents read from a provider
+const maxResponseSize = 1 << 20
+
+// Config describes an upstream provider. Setting Issuer makes it an OpenID Connect provider:
+// endpoints that are not set are discovered and logins are verified with the ID token.
+// Plain OAuth 2.0 providers need AuthURL, TokenURL and UserInfoURL.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is this service's callback URL registered at the provider
+	RedirectURL string
+	// Scopes default to "openid email profile" for OpenID Connect providers
+	Scopes      []string
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	JWKSURL     string
+	// TrustEmail treats addresses as verified when the provider does not say, for providers that
+	// only ever return verified addresses
+	TrustEmail bool
+}
+
+// Provider signs users in through an upstream provider
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu            sync.Mutex
+	discovered    bool
+	keys          map[string]*rsa.PublicKey
+	keysFetchedAt time.Time
+}
+
+// NewProvider creates a provider. A nil client uses one with a 10 second timeout.
+func NewProvider(config Config, client *http.Client) (*Provider, error) {
+	if config.Name == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("upstream provider needs a name, client ID and redirect URL")
+	}
+	if config.Issuer == "" && (config.AuthURL == "" || config.TokenURL == "" || config.UserInfoURL == "") {
+		return nil, fmt.Errorf("upstream provider %q needs an issuer or auth, token and userinfo URLs", config.Name)
+	}
+	if len(config.Scopes) == 0 && config.Issuer != "" {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Provider{config: config, client: client}, nil
+}
+
+// Name identifies the provider
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL returns the provider's authorization URL with an S256 PKCE challenge.
+// The nonce is only sent to OpenID Connect providers.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	config, err := p.endpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(config.AuthURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", config.ClientID)
+	query.Set("redirect_uri", config.RedirectURL)
+	query.Set("scope", strings.Join(config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	if p.isOIDC() {
+		query.Set("nonce", nonce)
+	}
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the profile of the account that signed in
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*identity.Profile, error) {
+	config, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := p.redeem(ctx, config, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if p.isOIDC() {
+		if tokens.IDToken == "" {
+			return nil, errors.New("token response has no id_token")
+		}
+		if claims, err = p.verifyIDToken(ctx, config, tokens.IDToken, nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	// Plain OAuth 2.0 providers describe the account only through userinfo, and some OpenID
+	// Connect providers leave the email out of the ID token
+	if config.UserInfoURL != "" && (claims == nil || claims["email"] == nil) {
+		info, err := p.userInfo(ctx, config, tokens.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		if claims != nil && stringClaim(info, "sub") != stringClaim(claims, "sub") {
+			return nil, errors.New("userinfo subject does not match the id_token")
+		}
+		if claims == nil {
+			claims = info
+		} else {
+			for k, v := range info {
+				if _, ok := claims[k]; !ok {
+					claims[k] = v
+				}
+			}
+		}
+	}
+
+	return p.profile(claims)
+}
+
+// tokenResponse is the provider's token endpoint response
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// redeem calls the token endpoint, authenticating with client_secret_basic when there is a secret
+func (p *Provider) redeem(ctx context.Context, config Config, code, codeVerifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if config.ClientSecret == "" {
+		form.Set("client_id", config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	var tokens tokenResponse
+	status, err := p.doJSON(req, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("token request failed with status %d: %s %s", status, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.AccessToken == "" {
+		return nil, errors.New("token response has no access_token")
+	}
+	return &tokens, nil
+}
+
+// verifyIDToken checks the ID token signature, issuer, audience, expiry and nonce
+func (p *Provider) verifyIDToken(ctx context.Context, config Config, idToken, nonce string) (map[string]interface{}, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, config, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(config.Issuer),
+		jwt.WithAudience(config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if nonce == "" || stringClaim(claims, "nonce") != nonce {
+		return nil, errors.New("id_token nonce does not match")
+	}
+	return claims, nil
+}
+
+// publicKey returns the provider key with the given ID, refetching the JWKS for unknown keys
+// at most once per keyRefreshInterval
+func (p *Provider) publicKey(ctx context.Context, config Config, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < keyRefreshInterval {
+		return nil, auth.ErrUnknownSigningKey
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set auth.JSONWebKeySet
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("jwks request failed with status %d", status)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.KeyType != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, auth.ErrUnknownSigningKey
+}
+
+// userInfo fetches the userinfo document with an access token
+func (p *Provider) userInfo(ctx context.Context, config Config, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var info map[string]interface{}
+	status, err := p.doJSON(req, &info)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("userinfo request failed with status %d", status)
+	}
+	return info, nil
+}
+
+// endpoints returns the configuration with discovered endpoints filled in. Discovery runs on first
+// use rather than at startup, so an unavailable provider does not stop the service.
+func (p *Provider) endpoints(ctx context.Context) (Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovered || !p.isOIDC() {
+		return p.config, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return Config{}, err
+	}
+	var metadata struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	status, err := p.doJSON(req, &metadata)
+	if err != nil {
+		return Config{}, err
+	}
+	if status != http.StatusOK {
+		return Config{}, fmt.Errorf("discovery for %q failed with status %d", p.config.Name, status)
+	}
+	// The metadata must be for the configured issuer, see OpenID Connect Discovery 1.0 section 4.3
+	if metadata.Issuer != p.config.Issuer {
+		return Config{}, fmt.Errorf("discovery for %q returned issuer %q", p.config.Name, metadata.Issuer)
+	}
+
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	fill(&p.config.AuthURL, metadata.AuthorizationEndpoint)
+	fill(&p.config.TokenURL, metadata.TokenEndpoint)
+	fill(&p.config.UserInfoURL, metadata.UserInfoEndpoint)
+	fill(&p.config.JWKSURL, metadata.JWKSURI)
+	if p.config.AuthURL == "" || p.config.TokenURL == "" || p.config.JWKSURL == "" {
+		return Config{}, fmt.Errorf("discovery for %q is missing required endpoints", p.config.Name)
+	}
+
+	p.discovered = true
+	return p.config, nil
+}
+
+// doJSON sends a request and decodes a JSON response body of any status into v
+func (p *Provider) doJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("invalid response from %s: %w", req.URL.Host, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// profile maps standard claims to a profile. Providers that predate OpenID Connect often use
+// "id" for the subject.
+func (p *Provider) profile(claims map[string]interface{}) (*identity.Profile, error) {
+	subject := stringClaim(claims, "sub")
+	if subject == "" {
+		subject = stringClaim(claims, "id")
+	}
+	if subject == "" {
+		return nil, errors.New("provider did not return a subject")
+	}
+
+	profile := &identity.Profile{
+		Subject:    subject,
+		Email:      stringClaim(claims, "email"),
+		GivenName:  stringClaim(claims, "given_name"),
+		FamilyName: stringClaim(claims, "family_name"),
+		Name:       stringClaim(claims, "name"),
+	}
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		profile.EmailVerified = verified
+	case string:
+		profile.EmailVerified = verified == "true"
+	case nil:
+		profile.EmailVerified = p.config.TrustEmail && profile.Email != ""
+	}
+	return profile, nil
+}
+
+func (p *Provider) isOIDC() bool {
+	return p.config.Issuer != ""
+}
+
+// stringClaim returns a claim as a string; numeric IDs are formatted without an exponent
+func stringClaim(claims map[string]interface{}, name string) string {
+	switch v := claims[name].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
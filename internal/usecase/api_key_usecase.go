@@ -0,0 +1,314 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/apikey"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// APIKeyPrefix starts every API key so keys are recognizable in headers and secret scanners
+const APIKeyPrefix = "gra_"
+
+// lastUsedResolution limits how often the last-used timestamp of a key is written
+const lastUsedResolution = time.Minute
+
+// scopePattern restricts scope names to a safe character set
+var scopePattern = regexp.MustCompile(`^[a-z0-9_.:*-]+$`)
+
+// CreateAPIKeyInput holds the validated fields of an API key creation request
+type CreateAPIKeyInput struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes"`
+	// ServiceName creates a service-owned key instead of a key owned by the caller
+	ServiceName string     `json:"service_name" validate:"max=100"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// APIKeyResponse represents the API key data that is safe to return
+type APIKeyResponse struct {
+	ID         string
+	Name       string
+	Prefix     string
+	OwnerType  apikey.OwnerType
+	OwnerID    string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// CreatedAPIKey is returned once on creation and is the only time the raw key is available
+type CreatedAPIKey struct {
+	APIKeyResponse
+	Key string
+}
+
+// APIKeyUseCase defines the use cases for managing and authenticating API keys
+type APIKeyUseCase struct {
+	keyRepo  apikey.Repository
+	userRepo user.Repository
+}
+
+// NewAPIKeyUseCase creates a new API key use case instance
+func NewAPIKeyUseCase(keyRepo apikey.Repository, userRepo user.Repository) *APIKeyUseCase {
+	return &APIKeyUseCase{
+		keyRepo:  keyRepo,
+		userRepo: userRepo,
+	}
+}
+
+// Create creates a key owned by the caller, or by a service when ServiceName is set.
+// A caller can never grant a key more scopes than it holds itself.
+func (uc *APIKeyUseCase) Create(p *auth.Principal, input CreateAPIKeyInput) (*CreatedAPIKey, error) {
+	input.Name = strings.TrimSpace(input.Name)
+	input.ServiceName = strings.TrimSpace(input.ServiceName)
+
+	errs := validation.Check(&input)
+	if len(input.Scopes) == 0 {
+		errs = append(errs, validation.FieldError{Field: "scopes", Message: "scopes is required"})
+	}
+	for _, scope := range input.Scopes {
+		if !scopePattern.MatchString(scope) {
+			errs = append(errs, validation.FieldError{Field: "scopes", Message: fmt.Sprintf("scope %q is invalid", scope)})
+		}
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		errs = append(errs, validation.FieldError{Field: "expires_at", Message: "expires_at must be in the future"})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	ownerType, ownerID := apikey.OwnerUser, p.Subject
+	if input.ServiceName != "" {
+		ownerType, ownerID = apikey.OwnerService, input.ServiceName
+	}
+	if !uc.canManage(p, ownerType, ownerID) {
+		return nil, ErrForbidden
+	}
+	for _, scope := range input.Scopes {
+		if !p.HasScope(scope) {
+			return nil, ErrForbidden
+		}
+	}
+
+	prefix, err := randomHex(6)
+	if err != nil {
+		return nil, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &apikey.APIKey{
+		ID:         id,
+		TenantID:   p.TenantID,
+		Name:       input.Name,
+		Prefix:     prefix,
+		SecretHash: hashAPIKeySecret(encodedSecret),
+		OwnerType:  ownerType,
+		OwnerID:    ownerID,
+		Scopes:     input.Scopes,
+		ExpiresAt:  input.ExpiresAt,
+		CreatedAt:  time.Now(),
+	}
+	if err := uc.keyRepo.Save(key); err != nil {
+		return nil, err
+	}
+
+	return &CreatedAPIKey{
+		APIKeyResponse: newAPIKeyResponse(key),
+		Key:            APIKeyPrefix + prefix + "_" + encodedSecret,
+	}, nil
+}
+
+// List returns the caller's keys, or the keys of a service when serviceName is set
+func (uc *APIKeyUseCase) List(p *auth.Principal, serviceName string) ([]APIKeyResponse, error) {
+	ownerType, ownerID := apikey.OwnerUser, p.Subject
+	if serviceName != "" {
+		ownerType, ownerID = apikey.OwnerService, serviceName
+	}
+	if !uc.canManage(p, ownerType, ownerID) {
+		return nil, ErrForbidden
+	}
+
+	keys, err := uc.keyRepo.FindByOwner(ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		// Services with the same name in different tenants are different owners
+		if key.TenantID != p.TenantID {
+			continue
+		}
+		responses = append(responses, newAPIKeyResponse(key))
+	}
+	return responses, nil
+}
+
+// Get returns a single key the caller may manage
+func (uc *APIKeyUseCase) Get(p *auth.Principal, id string) (*APIKeyResponse, error) {
+	key, err := uc.findManageable(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := newAPIKeyResponse(key)
+	return &response, nil
+}
+
+// Revoke revokes a key the caller may manage. Revoking twice is not an error.
+func (uc *APIKeyUseCase) Revoke(p *auth.Principal, id string) error {
+	key, err := uc.findManageable(p, id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	return uc.keyRepo.Update(key)
+}
+
+// Authenticate resolves a raw API key presented to a tenant to the principal it acts for.
+// Keys issued in another tenant are rejected like unknown keys.
+func (uc *APIKeyUseCase) Authenticate(tenantID, rawKey string) (*auth.Principal, error) {
+	prefix, secret, ok := parseAPIKey(rawKey)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := uc.keyRepo.FindByPrefix(prefix)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashAPIKeySecret(secret))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !key.Active(now) || key.TenantID != tenantID {
+		return nil, ErrInvalidAPIKey
+	}
+
+	principal := &auth.Principal{
+		Type:     auth.PrincipalService,
+		Subject:  key.OwnerID,
+		TenantID: key.TenantID,
+	}
+
+	if key.OwnerType == apikey.OwnerUser {
+		owner, err := uc.userRepo.FindByID(key.TenantID, key.OwnerID)
+		if err != nil || owner.Disabled {
+			return nil, ErrInvalidAPIKey
+		}
+		principal = newUserPrincipal(owner)
+	}
+	principal.AuthMethod = auth.AuthMethodAPIKey
+	principal.Scopes = append([]string{}, key.Scopes...)
+	principal.APIKeyID = key.ID
+
+	uc.touch(key, now)
+	return principal, nil
+}
+
+// canManage reports whether the principal may manage the keys of an owner
+func (uc *APIKeyUseCase) canManage(p *auth.Principal, ownerType apikey.OwnerType, ownerID string) bool {
+	if !p.HasScope(apikey.ScopeManage) {
+		return false
+	}
+	if ownerType == apikey.OwnerService {
+		return p.HasRole(user.RoleAdmin) && p.HasScope(apikey.ScopeAdmin)
+	}
+	return p.Type == auth.PrincipalUser && p.Subject == ownerID
+}
+
+// findManageable finds a key and checks the principal may manage it.
+// Keys of other owners are reported as not found.
+func (uc *APIKeyUseCase) findManageable(p *auth.Principal, id string) (*apikey.APIKey, error) {
+	key, err := uc.keyRepo.FindByID(id)
+	if err != nil || key.TenantID != p.TenantID || !uc.canManage(p, key.OwnerType, key.OwnerID) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// touch records when a key was last used, at most once per lastUsedResolution
+func (uc *APIKeyUseCase) touch(key *apikey.APIKey, now time.Time) {
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < lastUsedResolution {
+		return
+	}
+
+	if err := uc.keyRepo.UpdateLastUsed(key.ID, now); err != nil {
+		log.Printf("Error updating last use of api key %s: %v", key.Prefix, err)
+	}
+}
+
+// parseAPIKey splits a raw key of the form gra_<prefix>_<secret>
+func parseAPIKey(rawKey string) (string, string, bool) {
+	if !strings.HasPrefix(rawKey, APIKeyPrefix) {
+		return "", "", false
+	}
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(rawKey, APIKeyPrefix), "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+// hashAPIKeySecret returns the stored representation of a key secret.
+// Secrets are 256 random bits, so a fast hash is sufficient.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newAPIKeyResponse converts an API key to its response representation
+func newAPIKeyResponse(key *apikey.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		OwnerType:  key.OwnerType,
+		OwnerID:    key.OwnerID,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -0,0 +1,18 @@
+package usecase
+
+import "github.com/lamboktulussimamora/gra-project/internal/domain/user"
+
+// Authenticator verifies login credentials against one source of accounts within a tenant. It
+// returns the local user for accepted credentials, or ErrInvalidCredentials so the next
+// authenticator is tried. Any other error means the source could not be asked.
+type Authenticator interface {
+	Authenticate(tenantID, email, password string) (*user.User, error)
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface
+type AuthenticatorFunc func(tenantID, email, password string) (*user.User, error)
+
+// Authenticate calls f
+func (f AuthenticatorFunc) Authenticate(tenantID, email, password string) (*user.User, error) {
+	return f(tenantID, email, password)
+}
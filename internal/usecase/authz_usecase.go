@@ -0,0 +1,303 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/authz"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// ScopeAuthz allows an admin to manage relation tuples and to check and explain the access of
+// other subjects
+const ScopeAuthz = "authz"
+
+// maxCheckDepth bounds how many relations a check follows, which also ends cycles in the tuples
+const maxCheckDepth = 32
+
+// CheckInput holds the fields of a check or explain request. An empty subject checks the caller.
+type CheckInput struct {
+	Subject  string
+	Action   string
+	Resource string
+}
+
+// TupleQuery selects relation tuples; empty fields match every tuple. Object is type or type:id.
+type TupleQuery struct {
+	Object   string
+	Relation string
+	Subject  string
+}
+
+// AuthzUseCase evaluates fine-grained permissions against a policy and the relation tuples
+type AuthzUseCase struct {
+	policy *authz.Policy
+	tuples authz.TupleRepository
+}
+
+// NewAuthzUseCase creates a new authorization use case instance
+func NewAuthzUseCase(policy *authz.Policy, tuples authz.TupleRepository) *AuthzUseCase {
+	return &AuthzUseCase{
+		policy: policy,
+		tuples: tuples,
+	}
+}
+
+// Check reports whether a subject may perform an action, which is a relation or permission of
+// the resource's type, on a resource in a tenant
+func (uc *AuthzUseCase) Check(tenantID string, subject authz.Subject, action string, resource authz.Object) (bool, error) {
+	explanation, err := uc.Explain(tenantID, subject, action, resource)
+	if err != nil {
+		return false, err
+	}
+	return explanation.Allowed, nil
+}
+
+// Explain evaluates a check like Check and returns how it was decided
+func (uc *AuthzUseCase) Explain(tenantID string, subject authz.Subject, action string, resource authz.Object) (*authz.Explanation, error) {
+	def, ok := uc.policy.Types[resource.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", authz.ErrUnknownType, resource.Type)
+	}
+	if !def.Defines(action) {
+		return nil, fmt.Errorf("%w %s#%s", authz.ErrUnknownRelation, resource.Type, action)
+	}
+
+	run := &checkRun{uc: uc, tenantID: tenantID, subject: subject}
+	return run.check(resource, action, 0)
+}
+
+// CheckAccess checks a request of the caller. Checking another subject takes tuple admin rights.
+func (uc *AuthzUseCase) CheckAccess(p *auth.Principal, input CheckInput) (bool, error) {
+	subject, resource, err := uc.parseCheck(p, input)
+	if err != nil {
+		return false, err
+	}
+	return uc.Check(p.TenantID, subject, input.Action, resource)
+}
+
+// ExplainAccess explains a check for debugging, which takes tuple admin rights
+func (uc *AuthzUseCase) ExplainAccess(p *auth.Principal, input CheckInput) (*authz.Explanation, error) {
+	if !canAdministerAuthz(p) {
+		return nil, ErrForbidden
+	}
+
+	subject, resource, err := uc.parseCheck(p, input)
+	if err != nil {
+		return nil, err
+	}
+	return uc.Explain(p.TenantID, subject, input.Action, resource)
+}
+
+// WriteTuples stores relation tuples, written object#relation@subject, in the caller's tenant.
+// Only relations can be written; permissions are always derived.
+func (uc *AuthzUseCase) WriteTuples(p *auth.Principal, tuples []string) ([]authz.Tuple, error) {
+	parsed, err := uc.parseTuples(p, tuples)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range parsed {
+		if err := uc.validateTuple(t); err != nil {
+			return nil, validation.Errors{{Field: "tuples", Message: err.Error()}}
+		}
+	}
+
+	if err := uc.tuples.Write(parsed); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// DeleteTuples removes relation tuples from the caller's tenant
+func (uc *AuthzUseCase) DeleteTuples(p *auth.Principal, tuples []string) error {
+	parsed, err := uc.parseTuples(p, tuples)
+	if err != nil {
+		return err
+	}
+	return uc.tuples.Delete(parsed)
+}
+
+// ReadTuples returns the relation tuples of the caller's tenant selected by query
+func (uc *AuthzUseCase) ReadTuples(p *auth.Principal, query TupleQuery) ([]authz.Tuple, error) {
+	if !canAdministerAuthz(p) {
+		return nil, ErrForbidden
+	}
+
+	filter := authz.TupleFilter{Relation: query.Relation}
+	filter.ObjectType, filter.ObjectID, _ = strings.Cut(query.Object, ":")
+	if query.Subject != "" {
+		subject, err := authz.ParseSubject(query.Subject)
+		if err != nil {
+			return nil, validation.Errors{{Field: "subject", Message: err.Error()}}
+		}
+		filter.Subject = &subject
+	}
+
+	return uc.tuples.Read(p.TenantID, filter)
+}
+
+// parseCheck parses the subject and resource of a check request
+func (uc *AuthzUseCase) parseCheck(p *auth.Principal, input CheckInput) (authz.Subject, authz.Object, error) {
+	var errs validation.Errors
+	subject := authz.PrincipalSubject(p)
+	if input.Subject != "" {
+		parsed, err := authz.ParseSubject(input.Subject)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: "subject", Message: err.Error()})
+		}
+		subject = parsed
+	}
+	resource, err := authz.ParseObject(input.Resource)
+	if err != nil {
+		errs = append(errs, validation.FieldError{Field: "resource", Message: err.Error()})
+	}
+	if input.Action == "" {
+		errs = append(errs, validation.FieldError{Field: "action", Message: "action is required"})
+	}
+	if len(errs) > 0 {
+		return authz.Subject{}, authz.Object{}, errs
+	}
+
+	if subject != authz.PrincipalSubject(p) && !canAdministerAuthz(p) {
+		return authz.Subject{}, authz.Object{}, ErrForbidden
+	}
+	return subject, resource, nil
+}
+
+// parseTuples checks the caller may manage tuples and parses tuples into the caller's tenant
+func (uc *AuthzUseCase) parseTuples(p *auth.Principal, tuples []string) ([]authz.Tuple, error) {
+	if !canAdministerAuthz(p) {
+		return nil, ErrForbidden
+	}
+	if len(tuples) == 0 {
+		return nil, validation.Errors{{Field: "tuples", Message: "tuples is required"}}
+	}
+
+	parsed := make([]authz.Tuple, 0, len(tuples))
+	for _, s := range tuples {
+		t, err := authz.ParseTuple(s)
+		if err != nil {
+			return nil, validation.Errors{{Field: "tuples", Message: err.Error()}}
+		}
+		t.TenantID = p.TenantID
+		parsed = append(parsed, t)
+	}
+	return parsed, nil
+}
+
+// validateTuple checks that a tuple writes a relation of a known type and that a userset
+// subject names a relation or permission of its type
+func (uc *AuthzUseCase) validateTuple(t authz.Tuple) error {
+	def, ok := uc.policy.Types[t.Object.Type]
+	if !ok {
+		return fmt.Errorf("%w %q", authz.ErrUnknownType, t.Object.Type)
+	}
+	if !def.HasRelation(t.Relation) {
+		return fmt.Errorf("%w %s#%s", authz.ErrUnknownRelation, t.Object.Type, t.Relation)
+	}
+	if t.Subject.Relation != "" && !uc.policy.Types[t.Subject.Type].Defines(t.Subject.Relation) {
+		return fmt.Errorf("%w %s#%s", authz.ErrUnknownRelation, t.Subject.Type, t.Subject.Relation)
+	}
+	return nil
+}
+
+// checkRun evaluates one check for a subject, recording the evaluation as an explanation
+type checkRun struct {
+	uc       *AuthzUseCase
+	tenantID string
+	subject  authz.Subject
+}
+
+// check evaluates whether the subject has a relation or permission on an object. Relations
+// follow their tuples, including usersets; permissions evaluate their expressions in order.
+func (c *checkRun) check(object authz.Object, relation string, depth int) (*authz.Explanation, error) {
+	if depth > maxCheckDepth {
+		return nil, ErrCheckTooDeep
+	}
+
+	node := &authz.Explanation{Expression: object.String() + "#" + relation}
+	def := c.uc.policy.Types[object.Type]
+
+	if def.HasRelation(relation) {
+		tuples, err := c.uc.tuples.Read(c.tenantID, authz.TupleFilter{ObjectType: object.Type, ObjectID: object.ID, Relation: relation})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tuples {
+			child := &authz.Explanation{Expression: t.String(), Allowed: c.matches(t.Subject)}
+			if !child.Allowed && t.Subject.Relation != "" {
+				userset, err := c.check(t.Subject.Object, t.Subject.Relation, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				child.Children = []*authz.Explanation{userset}
+				child.Allowed = userset.Allowed
+			}
+			if explain(node, child) {
+				return node, nil
+			}
+		}
+		return node, nil
+	}
+
+	for _, expr := range def.Permissions[relation] {
+		tupleset, computed, isArrow := strings.Cut(expr, "->")
+		if !isArrow {
+			child, err := c.check(object, expr, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if explain(node, child) {
+				return node, nil
+			}
+			continue
+		}
+
+		// Follow every object related through the tupleset; objects whose type does not
+		// define the relation cannot grant it
+		arrow := &authz.Explanation{Expression: object.String() + "#" + expr}
+		tuples, err := c.uc.tuples.Read(c.tenantID, authz.TupleFilter{ObjectType: object.Type, ObjectID: object.ID, Relation: tupleset})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tuples {
+			if !c.uc.policy.Types[t.Subject.Type].Defines(computed) {
+				continue
+			}
+			child, err := c.check(t.Subject.Object, computed, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if explain(arrow, child) {
+				break
+			}
+		}
+		if explain(node, arrow) {
+			return node, nil
+		}
+	}
+	return node, nil
+}
+
+// matches reports whether a tuple subject is the checked subject, directly or by wildcard
+func (c *checkRun) matches(s authz.Subject) bool {
+	if s == c.subject {
+		return true
+	}
+	return s.ID == authz.Wildcard && s.Type == c.subject.Type && c.subject.Relation == ""
+}
+
+// explain adds a child to an explanation, which is allowed once any child is allowed, and
+// reports whether it now is
+func explain(parent, child *authz.Explanation) bool {
+	parent.Children = append(parent.Children, child)
+	parent.Allowed = parent.Allowed || child.Allowed
+	return parent.Allowed
+}
+
+// canAdministerAuthz reports whether a principal may manage tuples and check other subjects
+func canAdministerAuthz(p *auth.Principal) bool {
+	return p.HasRole(user.RoleAdmin) && p.HasScope(ScopeAuthz)
+}
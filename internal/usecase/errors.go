@@ -0,0 +1,40 @@
+package usecase
+
+import "errors"
+
+// Common use case errors
+var (
+	ErrInvalidCredentials      = errors.New("invalid credentials")
+	ErrUserExists              = errors.New("user with this email already exists")
+	ErrInvalidResetToken       = errors.New("invalid or expired reset token")
+	ErrPasswordResetDisabled   = errors.New("password reset is not configured")
+	ErrServiceBusy             = errors.New("service is busy, please try again later")
+	ErrForbidden               = errors.New("forbidden")
+	ErrAPIKeyNotFound          = errors.New("api key not found")
+	ErrInvalidAPIKey           = errors.New("invalid api key")
+	ErrInvalidSession          = errors.New("invalid or expired session")
+	ErrSessionNotFound         = errors.New("session not found")
+	ErrConsentNotFound         = errors.New("consent not found")
+	ErrUnknownProvider         = errors.New("unknown identity provider")
+	ErrInvalidLoginState       = errors.New("invalid or expired login state")
+	ErrExternalLoginFailed     = errors.New("external login failed")
+	ErrEmailNotVerified        = errors.New("the identity provider has not verified this email address")
+	ErrNoLinkedAccount         = errors.New("no account is linked to this external identity")
+	ErrIdentityNotFound        = errors.New("linked identity not found")
+	ErrLastSignInMethod        = errors.New("cannot remove the only way to sign in to this account")
+	ErrAccountDisabled         = errors.New("account is disabled")
+	ErrUserNotFound            = errors.New("user not found")
+	ErrGroupNotFound           = errors.New("group not found")
+	ErrVersionMismatch         = errors.New("the resource has been modified since it was read")
+	ErrOrgNotFound             = errors.New("organization not found")
+	ErrMemberNotFound          = errors.New("member not found")
+	ErrTeamNotFound            = errors.New("team not found")
+	ErrInvitationNotFound      = errors.New("invitation not found")
+	ErrInvalidInvitation       = errors.New("invalid or expired invitation")
+	ErrInvitationEmailMismatch = errors.New("this invitation was sent to a different email address")
+	ErrAlreadyMember           = errors.New("user is already a member of this organization")
+	ErrCheckTooDeep            = errors.New("authorization check exceeded the maximum relation depth")
+	ErrLastOwner               = errors.New("an organization must keep at least one owner")
+	ErrRateLimited             = errors.New("rate limit exceeded, please try again later")
+	ErrInvalidRefreshToken     = errors.New("invalid or expired refresh token")
+)
@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"log"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/event"
+)
+
+// relayBatchSize bounds how many outbox events one RelayPending call dispatches
+const relayBatchSize = 100
+
+// EventRelayConfig holds the retry settings of the event relay
+type EventRelayConfig struct {
+	// InitialBackoff is the wait after the first failed dispatch; it doubles after each failure
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultEventRelayConfig returns the default retry settings
+func DefaultEventRelayConfig() EventRelayConfig {
+	return EventRelayConfig{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Minute,
+	}
+}
+
+// EventRelay dispatches the events of an outbox to the subscribers of an event bus. An event
+// leaves the outbox only once every subscriber has handled it, so delivery is at least once;
+// subscribers use event.Idempotent to handle each event once.
+type EventRelay struct {
+	outbox event.Outbox
+	bus    *event.Bus
+	config EventRelayConfig
+}
+
+// NewEventRelay creates a new event relay
+func NewEventRelay(outbox event.Outbox, bus *event.Bus, config EventRelayConfig) *EventRelay {
+	return &EventRelay{
+		outbox: outbox,
+		bus:    bus,
+		config: config,
+	}
+}
+
+// RelayPending dispatches the outbox events that are due. Events whose subscribers fail are
+// retried with exponential backoff. It returns the number of events dispatched successfully.
+func (r *EventRelay) RelayPending() (int, error) {
+	now := time.Now()
+	pending, err := r.outbox.Pending(now, relayBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	dispatched := 0
+	for _, entry := range pending {
+		if err := r.bus.Dispatch(entry.Event); err != nil {
+			log.Printf("Error dispatching %s event %s: %v", entry.Event.Type, entry.Event.ID, err)
+			retryAt := now.Add(r.backoff(entry.Attempts + 1))
+			if err := r.outbox.MarkFailed(entry.Event.ID, err.Error(), retryAt); err != nil {
+				return dispatched, err
+			}
+			continue
+		}
+		if err := r.outbox.MarkDispatched(entry.Event.ID); err != nil {
+			return dispatched, err
+		}
+		dispatched++
+	}
+	return dispatched, nil
+}
+
+// backoff returns the wait after the given number of failed dispatches
+func (r *EventRelay) backoff(failures int) time.Duration {
+	wait := r.config.InitialBackoff
+	for i := 1; i < failures && wait < r.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > r.config.MaxBackoff {
+		wait = r.config.MaxBackoff
+	}
+	return wait
+}
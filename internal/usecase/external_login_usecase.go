@@ -0,0 +1,341 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// ExternalLoginConfig holds the external login settings
+type ExternalLoginConfig struct {
+	// StateTTL is how long the user has to complete the login at the provider
+	StateTTL time.Duration
+	// CreateUsers creates an account for a verified email that has none yet. When false,
+	// external logins only reach existing accounts.
+	CreateUsers bool
+}
+
+// DefaultExternalLoginConfig allows ten minutes at the provider and creates accounts
+func DefaultExternalLoginConfig() ExternalLoginConfig {
+	return ExternalLoginConfig{
+		StateTTL:    10 * time.Minute,
+		CreateUsers: true,
+	}
+}
+
+// ExternalLoginStart is where to send the browser to sign in at a provider. The state must also
+// be bound to the browser, e.g. in a cookie, and presented again at the callback.
+type ExternalLoginStart struct {
+	RedirectURL string
+	State       string
+	// FormPost is set when the provider returns with a cross-site form POST, so a cookie
+	// holding the state needs SameSite=None
+	FormPost bool
+}
+
+// LinkedIdentityResponse represents a linked identity that is safe to return in API responses
+type LinkedIdentityResponse struct {
+	Provider    string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+// ExternalLoginUseCase signs users in through upstream identity providers and issues the
+// same JWT as a password login
+type ExternalLoginUseCase struct {
+	providers  map[string]identity.Provider
+	links      identity.Repository
+	states     identity.StateRepository
+	userRepo   user.Repository
+	jwtService auth.JWTService
+	config     ExternalLoginConfig
+}
+
+// NewExternalLoginUseCase creates a new external login use case instance
+func NewExternalLoginUseCase(
+	providers []identity.Provider,
+	links identity.Repository,
+	states identity.StateRepository,
+	userRepo user.Repository,
+	jwtService auth.JWTService,
+	config ExternalLoginConfig,
+) *ExternalLoginUseCase {
+	uc := &ExternalLoginUseCase{
+		providers:  make(map[string]identity.Provider, len(providers)),
+		links:      links,
+		states:     states,
+		userRepo:   userRepo,
+		jwtService: jwtService,
+		config:     config,
+	}
+	for _, p := range providers {
+		uc.providers[p.Name()] = p
+	}
+	return uc
+}
+
+// Config returns the external login settings
+func (uc *ExternalLoginUseCase) Config() ExternalLoginConfig {
+	return uc.config
+}
+
+// Providers returns the names of the configured providers in alphabetical order
+func (uc *ExternalLoginUseCase) Providers() []string {
+	names := make([]string, 0, len(uc.providers))
+	for name := range uc.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Metadata returns the document a provider imports to trust this service, such as SAML service
+// provider metadata
+func (uc *ExternalLoginUseCase) Metadata(providerName string) ([]byte, error) {
+	provider, ok := uc.providers[providerName].(identity.PostBindingProvider)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider.Metadata()
+}
+
+// Begin starts a login to a tenant at a provider with a fresh state, nonce and PKCE verifier
+func (uc *ExternalLoginUseCase) Begin(ctx context.Context, tenantID, providerName string) (*ExternalLoginStart, error) {
+	provider, ok := uc.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.states.Save(&identity.LoginState{
+		ID:           hashOAuthToken(state),
+		TenantID:     tenantID,
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(uc.config.StateTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	redirectURL, err := provider.AuthCodeURL(ctx, state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		log.Printf("Failed to start login with %s: %v", providerName, err)
+		return nil, ErrExternalLoginFailed
+	}
+
+	_, formPost := provider.(identity.PostBindingProvider)
+	return &ExternalLoginStart{RedirectURL: redirectURL, State: state, FormPost: formPost}, nil
+}
+
+// Complete finishes a login from the provider's callback. The provider account is matched to a
+// user of the tenant by an earlier link, then by verified email; otherwise a new user is created
+// if allowed. The callback must arrive at the tenant the login was started for.
+func (uc *ExternalLoginUseCase) Complete(ctx context.Context, tenantID, providerName, state, code string) (*AuthResponse, error) {
+	provider, ok := uc.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if state == "" || code == "" {
+		return nil, ErrInvalidLoginState
+	}
+
+	// States are single use, so a replayed callback fails here
+	loginState, err := uc.states.Take(hashOAuthToken(state))
+	if err != nil || loginState.Provider != providerName || loginState.TenantID != tenantID ||
+		!time.Now().Before(loginState.ExpiresAt) {
+		return nil, ErrInvalidLoginState
+	}
+
+	profile, err := provider.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		log.Printf("Login with %s failed: %v", providerName, err)
+		return nil, ErrExternalLoginFailed
+	}
+
+	u, err := uc.resolveUser(tenantID, providerName, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := uc.jwtService.GenerateToken(u)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	return &AuthResponse{
+		User:  newUserResponse(u),
+		Token: token,
+	}, nil
+}
+
+// ListIdentities returns the external accounts linked to the principal's user
+func (uc *ExternalLoginUseCase) ListIdentities(p *auth.Principal) ([]LinkedIdentityResponse, error) {
+	if p.Type != auth.PrincipalUser {
+		return nil, ErrForbidden
+	}
+
+	links, err := uc.links.FindByUser(p.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]LinkedIdentityResponse, 0, len(links))
+	for _, link := range links {
+		responses = append(responses, LinkedIdentityResponse{
+			Provider:    link.Provider,
+			Email:       link.Email,
+			CreatedAt:   link.CreatedAt,
+			LastLoginAt: link.LastLoginAt,
+		})
+	}
+	return responses, nil
+}
+
+// Unlink removes the principal's link to a provider. An account without a password keeps at
+// least one linked identity so it can still be signed in to.
+func (uc *ExternalLoginUseCase) Unlink(p *auth.Principal, providerName string) error {
+	if !isInteractiveUser(p) {
+		return ErrForbidden
+	}
+
+	links, err := uc.links.FindByUser(p.Subject)
+	if err != nil {
+		return err
+	}
+	linked := false
+	for _, link := range links {
+		linked = linked || link.Provider == providerName
+	}
+	if !linked {
+		return ErrIdentityNotFound
+	}
+
+	u, err := uc.userRepo.FindByID(p.TenantID, p.Subject)
+	if err != nil {
+		return err
+	}
+	if u.Password == "" && len(links) == 1 {
+		return ErrLastSignInMethod
+	}
+
+	if err := uc.links.Delete(p.Subject, providerName); err != nil {
+		if errors.Is(err, identity.ErrNotFound) {
+			return ErrIdentityNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// PurgeExpired removes login states that were never completed
+func (uc *ExternalLoginUseCase) PurgeExpired() error {
+	return uc.states.DeleteExpired(time.Now())
+}
+
+// resolveUser finds or creates the tenant's user for a provider account and records the login
+func (uc *ExternalLoginUseCase) resolveUser(tenantID, providerName string, profile *identity.Profile) (*user.User, error) {
+	now := time.Now()
+	email := validation.NormalizeEmail(profile.Email)
+
+	link, err := uc.links.FindBySubject(tenantID, providerName, profile.Subject)
+	if err == nil {
+		u, err := uc.userRepo.FindByID(tenantID, link.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if u.Disabled {
+			return nil, ErrAccountDisabled
+		}
+		link.Email = email
+		link.LastLoginAt = now
+		if err := uc.links.Update(link); err != nil {
+			log.Printf("Failed to record login for linked identity %s: %v", link.ID, err)
+		}
+		return u, nil
+	}
+	if !errors.Is(err, identity.ErrNotFound) {
+		return nil, err
+	}
+
+	// Linking by email trusts the provider's claim to the address, so an unverified address
+	// could take over the account registered with it
+	if email == "" || !profile.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	u, err := uc.userRepo.FindByEmail(tenantID, email)
+	if err == nil && u.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	if err != nil {
+		if !uc.config.CreateUsers {
+			return nil, ErrNoLinkedAccount
+		}
+		if u, err = uc.createUser(tenantID, email, profile); err != nil {
+			return nil, err
+		}
+	}
+
+	linkID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.links.Save(&identity.LinkedIdentity{
+		ID:          linkID,
+		TenantID:    tenantID,
+		UserID:      u.ID,
+		Provider:    providerName,
+		Subject:     profile.Subject,
+		Email:       email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	}); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// createUser creates a user without a password; a password can be set later with a reset
+func (uc *ExternalLoginUseCase) createUser(tenantID, email string, profile *identity.Profile) (*user.User, error) {
+	firstName := validation.NormalizeName(profile.GivenName)
+	lastName := validation.NormalizeName(profile.FamilyName)
+	if firstName == "" && lastName == "" {
+		if name := validation.NormalizeName(profile.Name); name != "" {
+			firstName, lastName, _ = strings.Cut(name, " ")
+		} else {
+			firstName, _, _ = strings.Cut(email, "@")
+		}
+	}
+
+	u := user.NewUser(tenantID, firstName, lastName, email, "")
+	if err := uc.userRepo.Save(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
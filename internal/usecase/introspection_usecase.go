@@ -0,0 +1,200 @@
+package usecase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+)
+
+// ScopeIntrospect allows a service to ask whether tokens are valid
+const ScopeIntrospect = "introspect"
+
+// introspectionCacheSize bounds how many token results are cached
+const introspectionCacheSize = 10000
+
+// TokenRevocationChecker reports whether a JWT has been revoked before its expiry
+type TokenRevocationChecker interface {
+	IsTokenRevoked(tokenID string) bool
+}
+
+// IntrospectionConfig holds the caching and rate limiting settings of token introspection
+type IntrospectionConfig struct {
+	// CacheTTL is how long a result is reused; a revocation or a disabled account may take
+	// this long to be seen
+	CacheTTL time.Duration
+	// RateLimit is how many tokens each caller may introspect per minute
+	RateLimit int
+}
+
+// DefaultIntrospectionConfig returns the default introspection settings
+func DefaultIntrospectionConfig() IntrospectionConfig {
+	return IntrospectionConfig{
+		CacheTTL:  30 * time.Second,
+		RateLimit: 600,
+	}
+}
+
+// TokenIntrospection is the verdict on a token. Claims are only set for active tokens.
+type TokenIntrospection struct {
+	Active bool
+	Claims *auth.Claims
+}
+
+type cachedIntrospection struct {
+	result    *TokenIntrospection
+	expiresAt time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// IntrospectionUseCase verifies tokens on behalf of services that cannot verify JWTs themselves
+type IntrospectionUseCase struct {
+	jwtService  auth.JWTService
+	revocations TokenRevocationChecker
+	userRepo    user.Repository
+	config      IntrospectionConfig
+
+	mu      sync.Mutex
+	cache   map[string]cachedIntrospection
+	windows map[string]*rateWindow
+}
+
+// NewIntrospectionUseCase creates a new introspection use case instance. revocations may be nil.
+// userRepo is where the users tokens were issued to are looked up.
+func NewIntrospectionUseCase(jwtService auth.JWTService, revocations TokenRevocationChecker, userRepo user.Repository, config IntrospectionConfig) *IntrospectionUseCase {
+	return &IntrospectionUseCase{
+		jwtService:  jwtService,
+		revocations: revocations,
+		userRepo:    userRepo,
+		config:      config,
+		cache:       make(map[string]cachedIntrospection),
+		windows:     make(map[string]*rateWindow),
+	}
+}
+
+// Introspect reports whether a token is a valid, unrevoked token of the caller's tenant, issued
+// to a service or to a user whose account is still enabled, and returns its claims. Callers must be services, authenticated with an API key or a client
+// credentials token, holding the introspect scope.
+func (uc *IntrospectionUseCase) Introspect(p *auth.Principal, token string) (*TokenIntrospection, error) {
+	if !canIntrospect(p) {
+		return nil, ErrForbidden
+	}
+	now := time.Now()
+	if !uc.allow(p, now) {
+		return nil, ErrRateLimited
+	}
+
+	key := introspectionCacheKey(p.TenantID, token)
+	if result, ok := uc.cached(key, now); ok {
+		return result, nil
+	}
+
+	result := uc.verify(p.TenantID, token)
+	uc.store(key, result, now)
+	return result, nil
+}
+
+// verify checks a token's signature, expiry, tenant and revocation, and that the user it was
+// issued to still exists and is enabled. Tokens outlive a disabled account otherwise.
+func (uc *IntrospectionUseCase) verify(tenantID, token string) *TokenIntrospection {
+	claims, err := uc.jwtService.ValidateToken(token)
+	if err != nil || claims.Tenant() != tenantID {
+		return &TokenIntrospection{}
+	}
+	if uc.revocations != nil && claims.ID != "" && uc.revocations.IsTokenRevoked(claims.ID) {
+		return &TokenIntrospection{}
+	}
+	if auth.NewPrincipalFromClaims(claims).Type == auth.PrincipalUser {
+		u, err := uc.userRepo.FindByID(tenantID, claims.Subject)
+		if err != nil || u.Disabled {
+			return &TokenIntrospection{}
+		}
+	}
+	return &TokenIntrospection{Active: true, Claims: claims}
+}
+
+// cached returns an unexpired cached result
+func (uc *IntrospectionUseCase) cached(key string, now time.Time) (*TokenIntrospection, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	entry, ok := uc.cache[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// store caches a result for the cache TTL, or until the token expires if that is sooner
+func (uc *IntrospectionUseCase) store(key string, result *TokenIntrospection, now time.Time) {
+	if uc.config.CacheTTL <= 0 {
+		return
+	}
+	expiresAt := now.Add(uc.config.CacheTTL)
+	if result.Active && result.Claims.ExpiresAt != nil && result.Claims.ExpiresAt.Before(expiresAt) {
+		expiresAt = result.Claims.ExpiresAt.Time
+	}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if len(uc.cache) >= introspectionCacheSize {
+		for k, entry := range uc.cache {
+			if !now.Before(entry.expiresAt) {
+				delete(uc.cache, k)
+			}
+		}
+		if len(uc.cache) >= introspectionCacheSize {
+			// Every entry is still fresh; start over rather than grow without bound
+			clear(uc.cache)
+		}
+	}
+	uc.cache[key] = cachedIntrospection{result: result, expiresAt: expiresAt}
+}
+
+// allow counts a call against the caller's limit for the current minute
+func (uc *IntrospectionUseCase) allow(p *auth.Principal, now time.Time) bool {
+	if uc.config.RateLimit <= 0 {
+		return true
+	}
+	caller := p.TenantID + "/" + p.Subject + "/" + p.APIKeyID
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	window, ok := uc.windows[caller]
+	if !ok || now.Sub(window.start) >= time.Minute {
+		for k, w := range uc.windows {
+			if now.Sub(w.start) >= time.Minute {
+				delete(uc.windows, k)
+			}
+		}
+		window = &rateWindow{start: now}
+		uc.windows[caller] = window
+	}
+	if window.count >= uc.config.RateLimit {
+		return false
+	}
+	window.count++
+	return true
+}
+
+// canIntrospect allows services holding the introspect scope. User logins are not services,
+// even though their tokens are unrestricted.
+func canIntrospect(p *auth.Principal) bool {
+	service := p.AuthMethod == auth.AuthMethodAPIKey || (p.Type == auth.PrincipalService && p.AuthMethod == auth.AuthMethodOAuth)
+	return service && p.HasScope(ScopeIntrospect)
+}
+
+// introspectionCacheKey identifies a token of a tenant without keeping the token itself
+func introspectionCacheKey(tenantID, token string) string {
+	sum := sha256.Sum256([]byte(tenantID + "\x00" + token))
+	return hex.EncodeToString(sum[:])
+}
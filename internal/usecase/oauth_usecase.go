@@ -0,0 +1,990 @@
+package usecase
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/oauth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// OAuth error codes, see RFC 6749 sections 4.1.2.1 and 5.2
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthAccessDenied            = "access_denied"
+)
+
+// pkceMethodS256 is the only PKCE method accepted, as plain challenges protect nothing
+const pkceMethodS256 = "S256"
+
+// OAuthError is an OAuth protocol error with its standard error code
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+// Error implements the error interface
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// newOAuthError creates an OAuth protocol error
+func newOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthConfig holds the authorization server settings
+type OAuthConfig struct {
+	// Issuer identifies this server in tokens and authorization responses
+	Issuer               string
+	AccessTokenTTL       time.Duration
+	RefreshTokenTTL      time.Duration
+	AuthorizationCodeTTL time.Duration
+	// IDTokenTTL bounds how long a client may accept an ID token as proof of the sign-in. It
+	// is independent of the access token, which the client renews with its refresh token.
+	IDTokenTTL time.Duration
+}
+
+// DefaultOAuthConfig returns 15 minute access tokens, 30 day refresh tokens, 1 minute codes
+// and 1 hour ID tokens
+func DefaultOAuthConfig(issuer string) OAuthConfig {
+	return OAuthConfig{
+		Issuer:               issuer,
+		AccessTokenTTL:       15 * time.Minute,
+		RefreshTokenTTL:      30 * 24 * time.Hour,
+		AuthorizationCodeTTL: time.Minute,
+		IDTokenTTL:           time.Hour,
+	}
+}
+
+// OAuthRepositories groups the stores used by the authorization server
+type OAuthRepositories struct {
+	Clients       oauth.ClientRepository
+	Codes         oauth.AuthorizationCodeRepository
+	RefreshTokens oauth.RefreshTokenRepository
+	Consents      oauth.ConsentRepository
+	Revocations   oauth.RevocationRepository
+}
+
+// RegisterClientInput holds the fields of a client registration request
+type RegisterClientInput struct {
+	Name                   string `validate:"required,max=100"`
+	Type                   string
+	RedirectURIs           []string
+	PostLogoutRedirectURIs []string
+	GrantTypes             []string
+	Scopes                 []string
+}
+
+// ClientResponse represents the client data that is safe to return
+type ClientResponse struct {
+	ID                     string
+	Name                   string
+	Type                   oauth.ClientType
+	RedirectURIs           []string
+	PostLogoutRedirectURIs []string
+	GrantTypes             []string
+	Scopes                 []string
+	CreatedAt              time.Time
+}
+
+// RegisteredClient is returned once on registration and is the only time the secret is available
+type RegisteredClient struct {
+	ClientResponse
+	Secret string
+}
+
+// AuthorizeRequest holds the parameters of an authorization request
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// ConsentPrompt describes what the user is asked to approve
+type ConsentPrompt struct {
+	ClientID   string
+	ClientName string
+	Scopes     []string
+}
+
+// AuthorizeResult is either a redirect back to the client, carrying a code or an error,
+// or a consent prompt to show the user
+type AuthorizeResult struct {
+	RedirectURL string
+	Consent     *ConsentPrompt
+}
+
+// TokenRequest holds the parameters of a token request
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	ClientID     string
+	ClientSecret string
+	// TenantID is the tenant the request was made to
+	TenantID string
+}
+
+// TokenResponse represents a successful token response
+type TokenResponse struct {
+	AccessToken  string
+	TokenType    string
+	ExpiresIn    int
+	RefreshToken string
+	Scope        string
+	// IDToken is set when the openid scope was granted and ID tokens are enabled
+	IDToken string
+}
+
+// Introspection describes a token as defined by RFC 7662
+type Introspection struct {
+	Active    bool
+	Scope     string
+	ClientID  string
+	Username  string
+	TokenType string
+	Subject   string
+	Issuer    string
+	TokenID   string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+}
+
+// ConsentResponse represents a consent the user has granted
+type ConsentResponse struct {
+	ClientID   string
+	ClientName string
+	Scopes     []string
+	GrantedAt  time.Time
+}
+
+// OAuthUseCase implements an OAuth 2.1 authorization server on top of the user store and JWT service
+type OAuthUseCase struct {
+	repos       OAuthRepositories
+	userRepo    user.Repository
+	jwtService  auth.JWTService
+	config      OAuthConfig
+	idTokenKeys *auth.KeySet
+}
+
+// OAuthUseCaseOption configures optional authorization server features
+type OAuthUseCaseOption func(*OAuthUseCase)
+
+// WithIDTokens issues OpenID Connect ID tokens signed with keys when the openid scope is granted
+func WithIDTokens(keys *auth.KeySet) OAuthUseCaseOption {
+	return func(uc *OAuthUseCase) {
+		uc.idTokenKeys = keys
+	}
+}
+
+// NewOAuthUseCase creates a new OAuth use case instance
+func NewOAuthUseCase(repos OAuthRepositories, userRepo user.Repository, jwtService auth.JWTService, config OAuthConfig, opts ...OAuthUseCaseOption) *OAuthUseCase {
+	uc := &OAuthUseCase{
+		repos:      repos,
+		userRepo:   userRepo,
+		jwtService: jwtService,
+		config:     config,
+	}
+
+	for _, opt := range opts {
+		opt(uc)
+	}
+
+	return uc
+}
+
+// RegisterClient registers a client. Only admins holding the oauth:clients scope may register clients.
+func (uc *OAuthUseCase) RegisterClient(p *auth.Principal, input RegisterClientInput) (*RegisteredClient, error) {
+	if !canAdministerClients(p) {
+		return nil, ErrForbidden
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Type == "" {
+		input.Type = string(oauth.ClientConfidential)
+	}
+	if len(input.GrantTypes) == 0 {
+		input.GrantTypes = []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken}
+	}
+	if errs := validateClient(&input); len(errs) > 0 {
+		return nil, errs
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &oauth.Client{
+		ID:                     id,
+		TenantID:               p.TenantID,
+		Name:                   input.Name,
+		Type:                   oauth.ClientType(input.Type),
+		RedirectURIs:           input.RedirectURIs,
+		PostLogoutRedirectURIs: input.PostLogoutRedirectURIs,
+		GrantTypes:             input.GrantTypes,
+		Scopes:                 input.Scopes,
+		CreatedAt:              time.Now(),
+	}
+
+	var secret string
+	if client.Type == oauth.ClientConfidential {
+		if secret, err = randomToken(); err != nil {
+			return nil, err
+		}
+		client.SecretHash = hashOAuthToken(secret)
+	}
+
+	if err := uc.repos.Clients.Save(client); err != nil {
+		return nil, err
+	}
+
+	return &RegisteredClient{
+		ClientResponse: newClientResponse(client),
+		Secret:         secret,
+	}, nil
+}
+
+// ListClients returns the clients registered in the principal's tenant
+func (uc *OAuthUseCase) ListClients(p *auth.Principal) ([]ClientResponse, error) {
+	if !canAdministerClients(p) {
+		return nil, ErrForbidden
+	}
+
+	clients, err := uc.repos.Clients.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]ClientResponse, 0, len(clients))
+	for _, client := range clients {
+		if client.TenantID != p.TenantID {
+			continue
+		}
+		responses = append(responses, newClientResponse(client))
+	}
+	return responses, nil
+}
+
+// Authorize handles an authorization request from a signed-in user. It redirects straight back
+// with a code when the user already consented to the requested scopes, and asks for consent otherwise.
+// Errors that cannot safely be redirected to the client are returned as *OAuthError.
+func (uc *OAuthUseCase) Authorize(p *auth.Principal, req AuthorizeRequest) (*AuthorizeResult, error) {
+	client, redirectURI, err := uc.authorizeTarget(p, req)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, oauthErr := checkAuthorizeRequest(client, req)
+	if oauthErr != nil {
+		return &AuthorizeResult{RedirectURL: uc.errorRedirect(redirectURI, req.State, oauthErr)}, nil
+	}
+
+	if consent, err := uc.repos.Consents.Find(p.Subject, client.ID); err == nil && consent.Covers(scopes) {
+		return uc.issueCode(p, client, redirectURI, scopes, req)
+	}
+
+	return &AuthorizeResult{
+		Consent: &ConsentPrompt{
+			ClientID:   client.ID,
+			ClientName: client.Name,
+			Scopes:     scopes,
+		},
+	}, nil
+}
+
+// Consent records the user's answer to a consent prompt and redirects back to the client
+func (uc *OAuthUseCase) Consent(p *auth.Principal, req AuthorizeRequest, approved bool) (*AuthorizeResult, error) {
+	client, redirectURI, err := uc.authorizeTarget(p, req)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, oauthErr := checkAuthorizeRequest(client, req)
+	if oauthErr == nil && !approved {
+		oauthErr = newOAuthError(OAuthAccessDenied, "the user denied the request")
+	}
+	if oauthErr != nil {
+		return &AuthorizeResult{RedirectURL: uc.errorRedirect(redirectURI, req.State, oauthErr)}, nil
+	}
+
+	// Extend any earlier consent rather than replacing it
+	granted := scopes
+	if consent, err := uc.repos.Consents.Find(p.Subject, client.ID); err == nil {
+		granted = mergeScopes(consent.Scopes, scopes)
+	}
+	if err := uc.repos.Consents.Save(&oauth.Consent{
+		UserID:    p.Subject,
+		ClientID:  client.ID,
+		Scopes:    granted,
+		GrantedAt: time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+
+	return uc.issueCode(p, client, redirectURI, scopes, req)
+}
+
+// Token handles a token request for the authorization code, refresh token and client credentials grants
+func (uc *OAuthUseCase) Token(req TokenRequest) (*TokenResponse, error) {
+	client, err := uc.authenticateClient(req.TenantID, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case oauth.GrantAuthorizationCode:
+		if !client.AllowsGrant(req.GrantType) {
+			return nil, newOAuthError(OAuthUnauthorizedClient, "client may not use this grant")
+		}
+		return uc.exchangeCode(client, req)
+	case oauth.GrantRefreshToken:
+		if !client.AllowsGrant(req.GrantType) {
+			return nil, newOAuthError(OAuthUnauthorizedClient, "client may not use this grant")
+		}
+		return uc.refresh(client, req)
+	case oauth.GrantClientCredentials:
+		if client.Type != oauth.ClientConfidential || !client.AllowsGrant(req.GrantType) {
+			return nil, newOAuthError(OAuthUnauthorizedClient, "client may not use this grant")
+		}
+		return uc.clientCredentials(client, req)
+	case "":
+		return nil, newOAuthError(OAuthInvalidRequest, "grant_type is required")
+	default:
+		return nil, newOAuthError(OAuthUnsupportedGrantType, "")
+	}
+}
+
+// Introspect describes an access or refresh token to an authenticated client, see RFC 7662.
+// Refresh tokens are only described to the client they were issued to, and access tokens only
+// to clients of the tenant they were issued in.
+func (uc *OAuthUseCase) Introspect(tenantID, clientID, clientSecret, token string) (*Introspection, error) {
+	client, err := uc.authenticateClient(tenantID, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, err := uc.jwtService.ValidateToken(token); err == nil {
+		if claims.ClientID == "" || claims.Tenant() != tenantID || uc.IsTokenRevoked(claims.ID) {
+			return &Introspection{}, nil
+		}
+		introspection := &Introspection{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			Username:  claims.Email,
+			TokenType: "Bearer",
+			Subject:   claims.Subject,
+			Issuer:    claims.Issuer,
+			TokenID:   claims.ID,
+		}
+		if claims.ExpiresAt != nil {
+			introspection.ExpiresAt = claims.ExpiresAt.Time
+		}
+		if claims.IssuedAt != nil {
+			introspection.IssuedAt = claims.IssuedAt.Time
+		}
+		return introspection, nil
+	}
+
+	refresh, err := uc.repos.RefreshTokens.FindByHash(hashOAuthToken(token))
+	if err != nil || refresh.ClientID != client.ID || !refresh.Active(time.Now()) {
+		return &Introspection{}, nil
+	}
+
+	introspection := &Introspection{
+		Active:    true,
+		Scope:     strings.Join(refresh.Scopes, " "),
+		ClientID:  refresh.ClientID,
+		TokenType: "refresh_token",
+		Subject:   refresh.UserID,
+		Issuer:    uc.config.Issuer,
+		ExpiresAt: refresh.ExpiresAt,
+		IssuedAt:  refresh.CreatedAt,
+	}
+	if u, err := uc.userRepo.FindByID(client.TenantID, refresh.UserID); err == nil {
+		introspection.Username = u.Email
+	}
+	return introspection, nil
+}
+
+// Revoke revokes an access or refresh token issued to the authenticated client, see RFC 7009.
+// Unknown tokens are not an error, so callers cannot probe for valid tokens.
+func (uc *OAuthUseCase) Revoke(tenantID, clientID, clientSecret, token string) error {
+	client, err := uc.authenticateClient(tenantID, clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+
+	if claims, err := uc.jwtService.ValidateToken(token); err == nil {
+		if claims.ClientID != client.ID || claims.ID == "" || claims.ExpiresAt == nil {
+			return nil
+		}
+		return uc.repos.Revocations.Revoke(claims.ID, claims.ExpiresAt.Time)
+	}
+
+	refresh, err := uc.repos.RefreshTokens.FindByHash(hashOAuthToken(token))
+	if err != nil || refresh.ClientID != client.ID {
+		return nil
+	}
+	return uc.repos.RefreshTokens.RevokeFamily(refresh.FamilyID, time.Now())
+}
+
+// IsTokenRevoked reports whether an access token ID has been revoked
+func (uc *OAuthUseCase) IsTokenRevoked(tokenID string) bool {
+	if tokenID == "" {
+		return false
+	}
+
+	revoked, err := uc.repos.Revocations.IsRevoked(tokenID)
+	if err != nil {
+		// Fail closed: a token that cannot be checked is treated as revoked
+		log.Printf("Error checking token revocation: %v", err)
+		return true
+	}
+	return revoked
+}
+
+// ListConsents returns the clients the principal's user has granted access to
+func (uc *OAuthUseCase) ListConsents(p *auth.Principal) ([]ConsentResponse, error) {
+	if !isInteractiveUser(p) {
+		return nil, ErrForbidden
+	}
+
+	consents, err := uc.repos.Consents.FindByUser(p.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]ConsentResponse, 0, len(consents))
+	for _, consent := range consents {
+		response := ConsentResponse{
+			ClientID:  consent.ClientID,
+			Scopes:    consent.Scopes,
+			GrantedAt: consent.GrantedAt,
+		}
+		if client, err := uc.repos.Clients.FindByID(consent.ClientID); err == nil {
+			response.ClientName = client.Name
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// RevokeConsent withdraws the user's consent for a client and revokes the client's refresh tokens
+func (uc *OAuthUseCase) RevokeConsent(p *auth.Principal, clientID string) error {
+	if !isInteractiveUser(p) {
+		return ErrForbidden
+	}
+
+	if err := uc.repos.Consents.Delete(p.Subject, clientID); err != nil {
+		return ErrConsentNotFound
+	}
+	return uc.repos.RefreshTokens.RevokeGrant(p.Subject, clientID, time.Now())
+}
+
+// authorizeTarget checks the caller and resolves the client and redirect URI of an authorization
+// request. Until both are known to be valid, errors must not be redirected.
+func (uc *OAuthUseCase) authorizeTarget(p *auth.Principal, req AuthorizeRequest) (*oauth.Client, string, error) {
+	if !isInteractiveUser(p) {
+		return nil, "", ErrForbidden
+	}
+
+	// Clients of other tenants are unknown here
+	client, err := uc.repos.Clients.FindByID(req.ClientID)
+	if err != nil || client.TenantID != p.TenantID {
+		return nil, "", newOAuthError(OAuthInvalidClient, "unknown client_id")
+	}
+
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !client.AllowsRedirectURI(redirectURI) {
+		return nil, "", newOAuthError(OAuthInvalidRequest, "redirect_uri is not registered for this client")
+	}
+
+	return client, redirectURI, nil
+}
+
+// checkAuthorizeRequest validates the redirectable parameters of an authorization request
+// and returns the scopes to grant
+func checkAuthorizeRequest(client *oauth.Client, req AuthorizeRequest) ([]string, *OAuthError) {
+	if req.ResponseType != "code" {
+		return nil, newOAuthError(OAuthUnsupportedResponseType, "only the code response type is supported")
+	}
+	if !client.AllowsGrant(oauth.GrantAuthorizationCode) {
+		return nil, newOAuthError(OAuthUnauthorizedClient, "client may not use the authorization code grant")
+	}
+	if req.CodeChallenge == "" {
+		return nil, newOAuthError(OAuthInvalidRequest, "code_challenge is required")
+	}
+	if req.CodeChallengeMethod != pkceMethodS256 {
+		return nil, newOAuthError(OAuthInvalidRequest, "code_challenge_method must be S256")
+	}
+
+	return resolveScopes(client, req.Scope, client.Scopes)
+}
+
+// issueCode stores a new authorization code and builds the redirect that delivers it
+func (uc *OAuthUseCase) issueCode(p *auth.Principal, client *oauth.Client, redirectURI string, scopes []string, req AuthorizeRequest) (*AuthorizeResult, error) {
+	code, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	familyID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := uc.repos.Codes.Save(&oauth.AuthorizationCode{
+		CodeHash:             hashOAuthToken(code),
+		ClientID:             client.ID,
+		UserID:               p.Subject,
+		RedirectURI:          redirectURI,
+		RedirectURIRequested: req.RedirectURI != "",
+		Scopes:               scopes,
+		CodeChallenge:        req.CodeChallenge,
+		CodeChallengeMethod:  req.CodeChallengeMethod,
+		Nonce:                req.Nonce,
+		ExpiresAt:            now.Add(uc.config.AuthorizationCodeTTL),
+		FamilyID:             familyID,
+		CreatedAt:            now,
+	}); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{"code": {code}}
+	return &AuthorizeResult{RedirectURL: uc.redirect(redirectURI, req.State, params)}, nil
+}
+
+// errorRedirect builds a redirect that reports an error to the client
+func (uc *OAuthUseCase) errorRedirect(redirectURI, state string, oauthErr *OAuthError) string {
+	params := url.Values{"error": {oauthErr.Code}}
+	if oauthErr.Description != "" {
+		params.Set("error_description", oauthErr.Description)
+	}
+	return uc.redirect(redirectURI, state, params)
+}
+
+// redirect appends the response parameters, the state and the issuer (RFC 9207) to a redirect URI
+func (uc *OAuthUseCase) redirect(redirectURI, state string, params url.Values) string {
+	if state != "" {
+		params.Set("state", state)
+	}
+	if uc.config.Issuer != "" {
+		params.Set("iss", uc.config.Issuer)
+	}
+
+	u, _ := url.Parse(redirectURI)
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// exchangeCode redeems an authorization code
+func (uc *OAuthUseCase) exchangeCode(client *oauth.Client, req TokenRequest) (*TokenResponse, error) {
+	code, err := uc.repos.Codes.FindByHash(hashOAuthToken(req.Code))
+	if err != nil || code.ClientID != client.ID {
+		return nil, newOAuthError(OAuthInvalidGrant, "invalid authorization code")
+	}
+
+	now := time.Now()
+	if code.UsedAt != nil {
+		return nil, uc.replayedCode(code, now)
+	}
+	if !now.Before(code.ExpiresAt) {
+		return nil, newOAuthError(OAuthInvalidGrant, "authorization code has expired")
+	}
+	// A redirect_uri sent with the authorization request must be repeated here
+	if (code.RedirectURIRequested || req.RedirectURI != "") && req.RedirectURI != code.RedirectURI {
+		return nil, newOAuthError(OAuthInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if !verifyPKCE(code.CodeChallenge, req.CodeVerifier) {
+		return nil, newOAuthError(OAuthInvalidGrant, "code_verifier does not match the code challenge")
+	}
+
+	// Marking the code used fails for all but one of concurrent exchanges
+	err = uc.repos.Codes.MarkUsed(code.CodeHash, now)
+	if errors.Is(err, oauth.ErrCodeUsed) {
+		return nil, uc.replayedCode(code, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := uc.userRepo.FindByID(client.TenantID, code.UserID)
+	if err != nil {
+		return nil, newOAuthError(OAuthInvalidGrant, "the user no longer exists")
+	}
+	if u.Disabled {
+		return nil, newOAuthError(OAuthInvalidGrant, "the user account is disabled")
+	}
+
+	return uc.issueTokens(client, u, code.Scopes, code.FamilyID, code.Nonce)
+}
+
+// replayedCode revokes the tokens an authorization code was exchanged for when it is presented
+// again, since the code may have been intercepted
+func (uc *OAuthUseCase) replayedCode(code *oauth.AuthorizationCode, now time.Time) error {
+	if err := uc.repos.RefreshTokens.RevokeFamily(code.FamilyID, now); err != nil {
+		log.Printf("Error revoking tokens of replayed authorization code: %v", err)
+	}
+	return newOAuthError(OAuthInvalidGrant, "authorization code has already been used")
+}
+
+// refresh rotates a refresh token. Presenting a token that was already rotated or revoked
+// revokes its whole family, since either the client or an attacker holds a stolen copy.
+func (uc *OAuthUseCase) refresh(client *oauth.Client, req TokenRequest) (*TokenResponse, error) {
+	token, err := uc.repos.RefreshTokens.FindByHash(hashOAuthToken(req.RefreshToken))
+	if err != nil || token.ClientID != client.ID {
+		return nil, newOAuthError(OAuthInvalidGrant, "invalid refresh token")
+	}
+
+	now := time.Now()
+	if token.RotatedAt != nil {
+		if err := uc.repos.RefreshTokens.RevokeFamily(token.FamilyID, now); err != nil {
+			log.Printf("Error revoking reused refresh token family: %v", err)
+		}
+		return nil, newOAuthError(OAuthInvalidGrant, "refresh token has already been used")
+	}
+	if !token.Active(now) {
+		return nil, newOAuthError(OAuthInvalidGrant, "refresh token has expired or been revoked")
+	}
+
+	// A refresh may narrow the granted scopes but never widen them
+	scopes, oauthErr := resolveScopesWithin(req.Scope, token.Scopes)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	u, err := uc.userRepo.FindByID(client.TenantID, token.UserID)
+	if err != nil {
+		return nil, newOAuthError(OAuthInvalidGrant, "the user no longer exists")
+	}
+	if u.Disabled {
+		return nil, newOAuthError(OAuthInvalidGrant, "the user account is disabled")
+	}
+
+	token.RotatedAt = &now
+	if err := uc.repos.RefreshTokens.Update(token); err != nil {
+		return nil, err
+	}
+
+	return uc.issueTokens(client, u, scopes, token.FamilyID, "")
+}
+
+// clientCredentials issues an access token for the client itself
+func (uc *OAuthUseCase) clientCredentials(client *oauth.Client, req TokenRequest) (*TokenResponse, error) {
+	scopes, oauthErr := resolveScopes(client, req.Scope, client.Scopes)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	claims := &auth.Claims{
+		Scope:    strings.Join(scopes, " "),
+		ClientID: client.ID,
+		TenantID: client.TenantID,
+	}
+	claims.Subject = client.ID
+
+	return uc.signAccessToken(claims)
+}
+
+// issueTokens issues an access token for a user, a refresh token when the client may refresh,
+// and an ID token for OpenID Connect requests
+func (uc *OAuthUseCase) issueTokens(client *oauth.Client, u *user.User, scopes []string, familyID, nonce string) (*TokenResponse, error) {
+	claims := &auth.Claims{
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Roles:     u.Roles,
+		TenantID:  u.TenantID,
+		Scope:     strings.Join(scopes, " "),
+		ClientID:  client.ID,
+	}
+	claims.Subject = u.ID
+
+	response, err := uc.signAccessToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.AllowsGrant(oauth.GrantRefreshToken) {
+		refreshToken, err := randomToken()
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		if err := uc.repos.RefreshTokens.Save(&oauth.RefreshToken{
+			TokenHash: hashOAuthToken(refreshToken),
+			FamilyID:  familyID,
+			ClientID:  client.ID,
+			UserID:    u.ID,
+			Scopes:    scopes,
+			ExpiresAt: now.Add(uc.config.RefreshTokenTTL),
+			CreatedAt: now,
+		}); err != nil {
+			return nil, err
+		}
+		response.RefreshToken = refreshToken
+	}
+
+	if uc.idTokenKeys != nil && containsScope(scopes, ScopeOpenID) {
+		idToken, err := uc.signIDToken(client, u, scopes, nonce)
+		if err != nil {
+			return nil, err
+		}
+		response.IDToken = idToken
+	}
+
+	return response, nil
+}
+
+// signIDToken signs an ID token for a user, with the claims the granted scopes allow
+func (uc *OAuthUseCase) signIDToken(client *oauth.Client, u *user.User, scopes []string, nonce string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims(scopedUserClaims(u, scopes))
+	claims["iss"] = uc.config.Issuer
+	claims["aud"] = client.ID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(uc.config.IDTokenTTL).Unix()
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	return uc.idTokenKeys.Sign(claims)
+}
+
+// signAccessToken signs an access token with a unique ID so it can be revoked
+func (uc *OAuthUseCase) signAccessToken(claims *auth.Claims) (*TokenResponse, error) {
+	tokenID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	claims.ID = tokenID
+	claims.Issuer = uc.config.Issuer
+	claims.Audience = jwt.ClaimStrings{claims.ClientID}
+
+	accessToken, err := uc.jwtService.GenerateClaimsToken(claims, uc.config.AccessTokenTTL)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(uc.config.AccessTokenTTL.Seconds()),
+		Scope:       claims.Scope,
+	}, nil
+}
+
+// authenticateClient checks the credentials of a client of the tenant. Confidential clients must
+// present their secret; public clients identify themselves by client_id alone.
+func (uc *OAuthUseCase) authenticateClient(tenantID, clientID, clientSecret string) (*oauth.Client, error) {
+	client, err := uc.repos.Clients.FindByID(clientID)
+	if err != nil || client.TenantID != tenantID {
+		return nil, newOAuthError(OAuthInvalidClient, "client authentication failed")
+	}
+
+	if client.Type == oauth.ClientPublic {
+		if clientSecret != "" {
+			return nil, newOAuthError(OAuthInvalidClient, "client authentication failed")
+		}
+		return client, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hashOAuthToken(clientSecret))) != 1 {
+		return nil, newOAuthError(OAuthInvalidClient, "client authentication failed")
+	}
+	return client, nil
+}
+
+// resolveScopes parses a requested scope, defaulting to fallback, and checks the client may request it
+func resolveScopes(client *oauth.Client, requested string, fallback []string) ([]string, *OAuthError) {
+	scopes, oauthErr := resolveScopesWithin(requested, fallback)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+	for _, scope := range scopes {
+		if !client.AllowsScope(scope) {
+			return nil, newOAuthError(OAuthInvalidScope, fmt.Sprintf("scope %q is not allowed for this client", scope))
+		}
+	}
+	return scopes, nil
+}
+
+// resolveScopesWithin parses a requested scope, defaulting to allowed, and checks it is a subset of allowed
+func resolveScopesWithin(requested string, allowed []string) ([]string, *OAuthError) {
+	if strings.TrimSpace(requested) == "" {
+		return allowed, nil
+	}
+
+	scopes := mergeScopes(nil, strings.Fields(requested))
+	for _, scope := range scopes {
+		if !containsScope(allowed, scope) {
+			return nil, newOAuthError(OAuthInvalidScope, fmt.Sprintf("scope %q is not allowed", scope))
+		}
+	}
+	return scopes, nil
+}
+
+// mergeScopes appends the scopes in extra that are not yet in scopes
+func mergeScopes(scopes, extra []string) []string {
+	merged := append([]string{}, scopes...)
+	for _, scope := range extra {
+		if !containsScope(merged, scope) {
+			merged = append(merged, scope)
+		}
+	}
+	return merged
+}
+
+// containsScope reports whether scopes contains scope
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyPKCE checks an S256 code verifier against its challenge, see RFC 7636 section 4.6
+func verifyPKCE(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// hashOAuthToken returns the stored representation of a code, refresh token or client secret.
+// These are 256 random bits, so a fast hash is sufficient.
+func hashOAuthToken(token string) string {
+	return hashAPIKeySecret(token)
+}
+
+// isInteractiveUser reports whether the principal is a user signed in with a login token or session,
+// rather than a scoped credential acting on the user's behalf
+func isInteractiveUser(p *auth.Principal) bool {
+	return p.Type == auth.PrincipalUser && p.Scopes == nil
+}
+
+// canAdministerClients reports whether the principal may manage OAuth clients
+func canAdministerClients(p *auth.Principal) bool {
+	return p.HasRole(user.RoleAdmin) && p.HasScope(oauth.ScopeClientAdmin)
+}
+
+// validateClient checks a client registration
+func validateClient(input *RegisterClientInput) validation.Errors {
+	errs := validation.Check(input)
+
+	clientType := oauth.ClientType(input.Type)
+	if clientType != oauth.ClientConfidential && clientType != oauth.ClientPublic {
+		errs = append(errs, validation.FieldError{Field: "type", Message: "type must be confidential or public"})
+	}
+
+	for _, grant := range input.GrantTypes {
+		switch grant {
+		case oauth.GrantAuthorizationCode, oauth.GrantRefreshToken:
+		case oauth.GrantClientCredentials:
+			if clientType == oauth.ClientPublic {
+				errs = append(errs, validation.FieldError{Field: "grant_types", Message: "public clients cannot use client_credentials"})
+			}
+		default:
+			errs = append(errs, validation.FieldError{Field: "grant_types", Message: fmt.Sprintf("grant type %q is not supported", grant)})
+		}
+	}
+
+	if containsScope(input.GrantTypes, oauth.GrantAuthorizationCode) && len(input.RedirectURIs) == 0 {
+		errs = append(errs, validation.FieldError{Field: "redirect_uris", Message: "redirect_uris is required for the authorization code grant"})
+	}
+	for _, uri := range input.RedirectURIs {
+		if !validRedirectURI(uri) {
+			errs = append(errs, validation.FieldError{Field: "redirect_uris", Message: fmt.Sprintf("redirect URI %q must be an absolute https or loopback http URL without a fragment", uri)})
+		}
+	}
+	for _, uri := range input.PostLogoutRedirectURIs {
+		if !validRedirectURI(uri) {
+			errs = append(errs, validation.FieldError{Field: "post_logout_redirect_uris", Message: fmt.Sprintf("redirect URI %q must be an absolute https or loopback http URL without a fragment", uri)})
+		}
+	}
+
+	if len(input.Scopes) == 0 {
+		errs = append(errs, validation.FieldError{Field: "scopes", Message: "scopes is required"})
+	}
+	for _, scope := range input.Scopes {
+		if !scopePattern.MatchString(scope) {
+			errs = append(errs, validation.FieldError{Field: "scopes", Message: fmt.Sprintf("scope %q is invalid", scope)})
+		}
+	}
+
+	return errs
+}
+
+// validRedirectURI accepts absolute https URLs and, for native and development clients, loopback http URLs
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return false
+	}
+}
+
+// newClientResponse converts a client to its response representation
+func newClientResponse(client *oauth.Client) ClientResponse {
+	return ClientResponse{
+		ID:                     client.ID,
+		Name:                   client.Name,
+		Type:                   client.Type,
+		RedirectURIs:           client.RedirectURIs,
+		PostLogoutRedirectURIs: client.PostLogoutRedirectURIs,
+		GrantTypes:             client.GrantTypes,
+		Scopes:                 client.Scopes,
+		CreatedAt:              client.CreatedAt,
+	}
+}
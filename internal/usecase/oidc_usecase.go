@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"net/url"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/oauth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+)
+
+// Standard OpenID Connect scopes
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OIDCScopes lists the OpenID Connect scopes this provider understands
+var OIDCScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// OIDCClaims lists the claims this provider can return
+var OIDCClaims = []string{"sub", "iss", "aud", "exp", "iat", "nonce", "name", "given_name", "family_name", "updated_at", "email", "email_verified"}
+
+// LogoutRequest holds the parameters of an RP-initiated logout request
+type LogoutRequest struct {
+	IDTokenHint           string
+	PostLogoutRedirectURI string
+	State                 string
+	ClientID              string
+	// TenantID is the tenant the request was made to
+	TenantID string
+}
+
+// LogoutResult tells the caller whose session to end and where to send the browser.
+// RedirectURL is empty when the relying party gave no post-logout redirect URI.
+type LogoutResult struct {
+	UserID      string
+	RedirectURL string
+}
+
+// OIDCUseCase implements the OpenID Connect provider features layered on the authorization server
+type OIDCUseCase struct {
+	clientRepo oauth.ClientRepository
+	userRepo   user.Repository
+	keys       *auth.KeySet
+	issuer     string
+}
+
+// NewOIDCUseCase creates a new OpenID Connect use case instance. keys must be the key set the
+// authorization server signs ID tokens with.
+func NewOIDCUseCase(clientRepo oauth.ClientRepository, userRepo user.Repository, keys *auth.KeySet, issuer string) *OIDCUseCase {
+	return &OIDCUseCase{
+		clientRepo: clientRepo,
+		userRepo:   userRepo,
+		keys:       keys,
+		issuer:     issuer,
+	}
+}
+
+// Issuer returns the issuer identifier
+func (uc *OIDCUseCase) Issuer() string {
+	return uc.issuer
+}
+
+// JWKS returns the public keys ID tokens are signed with
+func (uc *OIDCUseCase) JWKS() auth.JSONWebKeySet {
+	return uc.keys.JWKS()
+}
+
+// UserInfo returns the claims about the principal's user that its scopes allow.
+// The principal must hold the openid scope.
+func (uc *OIDCUseCase) UserInfo(p *auth.Principal) (map[string]interface{}, error) {
+	if p.Type != auth.PrincipalUser || !p.HasScope(ScopeOpenID) {
+		return nil, ErrForbidden
+	}
+
+	u, err := uc.userRepo.FindByID(p.TenantID, p.Subject)
+	if err != nil {
+		return nil, ErrForbidden
+	}
+
+	var scopes []string
+	for _, scope := range OIDCScopes {
+		if p.HasScope(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopedUserClaims(u, scopes), nil
+}
+
+// Logout validates an RP-initiated logout request. The ID token hint identifies the user and client;
+// it may have expired, but must have been issued by this provider.
+func (uc *OIDCUseCase) Logout(req LogoutRequest) (*LogoutResult, error) {
+	if req.IDTokenHint == "" {
+		return nil, newOAuthError(OAuthInvalidRequest, "id_token_hint is required")
+	}
+
+	claims := jwt.MapClaims{}
+	if err := uc.keys.Parse(req.IDTokenHint, claims, jwt.WithoutClaimsValidation(), jwt.WithIssuer(uc.issuer)); err != nil {
+		return nil, newOAuthError(OAuthInvalidRequest, "id_token_hint is invalid")
+	}
+
+	subject, _ := claims.GetSubject()
+	audience, _ := claims.GetAudience()
+	if subject == "" || len(audience) != 1 {
+		return nil, newOAuthError(OAuthInvalidRequest, "id_token_hint is invalid")
+	}
+	if req.ClientID != "" && req.ClientID != audience[0] {
+		return nil, newOAuthError(OAuthInvalidRequest, "client_id does not match the id_token_hint")
+	}
+
+	result := &LogoutResult{UserID: subject}
+	if req.PostLogoutRedirectURI == "" {
+		return result, nil
+	}
+
+	client, err := uc.clientRepo.FindByID(audience[0])
+	if err != nil || client.TenantID != req.TenantID || !client.AllowsPostLogoutRedirectURI(req.PostLogoutRedirectURI) {
+		return nil, newOAuthError(OAuthInvalidRequest, "post_logout_redirect_uri is not registered for this client")
+	}
+
+	redirect, _ := url.Parse(req.PostLogoutRedirectURI)
+	if req.State != "" {
+		query := redirect.Query()
+		query.Set("state", req.State)
+		redirect.RawQuery = query.Encode()
+	}
+	result.RedirectURL = redirect.String()
+	return result, nil
+}
+
+// scopedUserClaims returns the standard claims about a user that the given scopes release
+func scopedUserClaims(u *user.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": u.ID}
+
+	if containsScope(scopes, ScopeProfile) {
+		claims["name"] = u.FirstName + " " + u.LastName
+		claims["given_name"] = u.FirstName
+		claims["family_name"] = u.LastName
+		claims["updated_at"] = u.UpdatedAt.Unix()
+	}
+	if containsScope(scopes, ScopeEmail) {
+		claims["email"] = u.Email
+		// Addresses are not verified at registration
+		claims["email_verified"] = false
+	}
+
+	return claims
+}
@@ -0,0 +1,739 @@
+package usecase
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/mail"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/org"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// ScopeOrgs allows a principal to manage the organizations its user belongs to
+const ScopeOrgs = "orgs"
+
+// OrgRepositories groups the storage used by the organization use cases
+type OrgRepositories struct {
+	Orgs        org.Repository
+	Members     org.MembershipRepository
+	Teams       org.TeamRepository
+	Invitations org.InvitationRepository
+}
+
+// OrgConfig holds the invitation settings
+type OrgConfig struct {
+	// SigningKey signs invitation links so they cannot be forged or extended
+	SigningKey []byte
+	// InviteTTL is how long an invitation link stays valid
+	InviteTTL time.Duration
+	// AcceptURL is the page invitees open; the invitation token is added as the token parameter
+	AcceptURL string
+}
+
+// DefaultOrgConfig returns invitations that are valid for seven days
+func DefaultOrgConfig(signingKey []byte, acceptURL string) OrgConfig {
+	return OrgConfig{
+		SigningKey: signingKey,
+		InviteTTL:  7 * 24 * time.Hour,
+		AcceptURL:  acceptURL,
+	}
+}
+
+// OrgInput holds the fields of an organization or team creation request
+type OrgInput struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// InviteInput holds the fields of an invitation request
+type InviteInput struct {
+	Email string `json:"email" validate:"required,max=254,email"`
+	Role  org.Role
+}
+
+// OrgResponse represents an organization as seen by one of its members
+type OrgResponse struct {
+	ID        string
+	Name      string
+	Role      org.Role
+	CreatedAt time.Time
+}
+
+// MemberResponse represents a member of an organization
+type MemberResponse struct {
+	UserID    string
+	Email     string
+	FirstName string
+	LastName  string
+	Role      org.Role
+	JoinedAt  time.Time
+}
+
+// TeamResponse represents a team of an organization
+type TeamResponse struct {
+	ID        string
+	Name      string
+	MemberIDs []string
+	CreatedAt time.Time
+}
+
+// InvitationResponse represents the invitation data that is safe to return
+type InvitationResponse struct {
+	ID         string
+	Email      string
+	Role       org.Role
+	InvitedBy  string
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// OrgUseCase defines the use cases for organizations, their members, teams and invitations
+type OrgUseCase struct {
+	repos    OrgRepositories
+	userRepo user.Repository
+	users    *UserUseCase
+	mailer   mail.Mailer
+	config   OrgConfig
+}
+
+// NewOrgUseCase creates a new organization use case instance. Invitations are delivered with
+// mailer, and invitees without an account are registered through users.
+func NewOrgUseCase(repos OrgRepositories, userRepo user.Repository, users *UserUseCase, mailer mail.Mailer, config OrgConfig) *OrgUseCase {
+	return &OrgUseCase{
+		repos:    repos,
+		userRepo: userRepo,
+		users:    users,
+		mailer:   mailer,
+		config:   config,
+	}
+}
+
+// CreateOrg creates an organization in the caller's tenant with the caller as its owner
+func (uc *OrgUseCase) CreateOrg(p *auth.Principal, name string) (*OrgResponse, error) {
+	if !canManageOrgs(p) {
+		return nil, ErrForbidden
+	}
+
+	input := OrgInput{Name: strings.TrimSpace(name)}
+	if err := validation.Validate(&input); err != nil {
+		return nil, err
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	o := &org.Organization{
+		ID:        id,
+		TenantID:  p.TenantID,
+		Name:      input.Name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := uc.repos.Orgs.Save(o); err != nil {
+		return nil, err
+	}
+	owner := &org.Membership{OrgID: o.ID, UserID: p.Subject, Role: org.RoleOwner, CreatedAt: now, UpdatedAt: now}
+	if err := uc.repos.Members.Save(owner); err != nil {
+		return nil, err
+	}
+
+	response := newOrgResponse(o, owner)
+	return &response, nil
+}
+
+// ListOrgs returns the organizations the caller belongs to
+func (uc *OrgUseCase) ListOrgs(p *auth.Principal) ([]OrgResponse, error) {
+	if !canManageOrgs(p) {
+		return nil, ErrForbidden
+	}
+
+	memberships, err := uc.repos.Members.FindByUser(p.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := make([]OrgResponse, 0, len(memberships))
+	for _, m := range memberships {
+		o, err := uc.repos.Orgs.FindByID(p.TenantID, m.OrgID)
+		if err != nil {
+			continue
+		}
+		orgs = append(orgs, newOrgResponse(o, m))
+	}
+	return orgs, nil
+}
+
+// GetOrg returns an organization the caller belongs to
+func (uc *OrgUseCase) GetOrg(p *auth.Principal, orgID string) (*OrgResponse, error) {
+	o, m, err := uc.authorize(p, orgID, org.RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	response := newOrgResponse(o, m)
+	return &response, nil
+}
+
+// ListMembers returns the members of an organization the caller belongs to
+func (uc *OrgUseCase) ListMembers(p *auth.Principal, orgID string) ([]MemberResponse, error) {
+	o, _, err := uc.authorize(p, orgID, org.RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := uc.repos.Members.FindByOrg(o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]MemberResponse, 0, len(memberships))
+	for _, m := range memberships {
+		u, err := uc.userRepo.FindByID(o.TenantID, m.UserID)
+		if err != nil {
+			continue
+		}
+		members = append(members, newMemberResponse(u, m))
+	}
+	return members, nil
+}
+
+// ChangeRole changes the role of a member. Admins can change the roles of admins and members;
+// only owners can grant or take away ownership, and the last owner cannot be demoted.
+func (uc *OrgUseCase) ChangeRole(p *auth.Principal, orgID, userID string, role org.Role) (*MemberResponse, error) {
+	if !role.Valid() {
+		return nil, validation.Errors{invalidRoleError()}
+	}
+
+	o, caller, err := uc.authorize(p, orgID, org.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	target, err := uc.repos.Members.Find(o.ID, userID)
+	if err != nil {
+		return nil, ErrMemberNotFound
+	}
+	if !caller.Role.AtLeast(target.Role) || !caller.Role.AtLeast(role) {
+		return nil, ErrForbidden
+	}
+	if target.Role == org.RoleOwner && role != org.RoleOwner {
+		if err := uc.keepOwner(o.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	target.Role = role
+	target.UpdatedAt = time.Now()
+	if err := uc.repos.Members.Save(target); err != nil {
+		return nil, err
+	}
+
+	u, err := uc.userRepo.FindByID(o.TenantID, userID)
+	if err != nil {
+		return nil, ErrMemberNotFound
+	}
+	response := newMemberResponse(u, target)
+	return &response, nil
+}
+
+// RemoveMember removes a member from an organization and its teams. Members can always leave;
+// removing someone else takes at least their role and admin rights. The last owner cannot leave.
+func (uc *OrgUseCase) RemoveMember(p *auth.Principal, orgID, userID string) error {
+	required := org.RoleAdmin
+	if userID == p.Subject {
+		required = org.RoleMember
+	}
+	o, caller, err := uc.authorize(p, orgID, required)
+	if err != nil {
+		return err
+	}
+	target, err := uc.repos.Members.Find(o.ID, userID)
+	if err != nil {
+		return ErrMemberNotFound
+	}
+	if !caller.Role.AtLeast(target.Role) {
+		return ErrForbidden
+	}
+	if target.Role == org.RoleOwner {
+		if err := uc.keepOwner(o.ID); err != nil {
+			return err
+		}
+	}
+
+	teams, err := uc.repos.Teams.FindByOrg(o.ID)
+	if err != nil {
+		return err
+	}
+	for _, t := range teams {
+		if t.HasMember(userID) {
+			t.MemberIDs = removeString(t.MemberIDs, userID)
+			t.UpdatedAt = time.Now()
+			if err := uc.repos.Teams.Update(t); err != nil {
+				return err
+			}
+		}
+	}
+
+	return uc.repos.Members.Delete(o.ID, userID)
+}
+
+// Invite emails a signed, expiring invitation link to join an organization with a role.
+// Admins can invite admins and members; only owners can invite owners.
+func (uc *OrgUseCase) Invite(p *auth.Principal, orgID string, input InviteInput) (*InvitationResponse, error) {
+	input.Email = validation.NormalizeEmail(input.Email)
+	errs := validation.Check(&input)
+	if !input.Role.Valid() {
+		errs = append(errs, invalidRoleError())
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	o, caller, err := uc.authorize(p, orgID, org.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.Role.AtLeast(input.Role) {
+		return nil, ErrForbidden
+	}
+	if u, err := uc.userRepo.FindByEmail(o.TenantID, input.Email); err == nil {
+		if _, err := uc.repos.Members.Find(o.ID, u.ID); err == nil {
+			return nil, ErrAlreadyMember
+		}
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	inv := &org.Invitation{
+		ID:        id,
+		OrgID:     o.ID,
+		Email:     input.Email,
+		Role:      input.Role,
+		InvitedBy: p.Subject,
+		ExpiresAt: now.Add(uc.config.InviteTTL).Truncate(time.Second),
+		CreatedAt: now,
+	}
+	if err := uc.repos.Invitations.Save(inv); err != nil {
+		return nil, err
+	}
+
+	msg := mail.Message{
+		To:      inv.Email,
+		Subject: fmt.Sprintf("You have been invited to join %s", o.Name),
+		Body: fmt.Sprintf("You have been invited to join %s as %s.\nAccept the invitation: %s\nIt expires in %s.",
+			o.Name, inv.Role, uc.inviteLink(uc.signInvitation(inv)), uc.config.InviteTTL),
+	}
+	if err := uc.mailer.Send(msg); err != nil {
+		// An invitation nobody received cannot be accepted, so do not keep it
+		uc.repos.Invitations.Delete(inv.ID)
+		return nil, fmt.Errorf("failed to send invitation: %w", err)
+	}
+
+	response := newInvitationResponse(inv)
+	return &response, nil
+}
+
+// ListInvitations returns the invitations of an organization
+func (uc *OrgUseCase) ListInvitations(p *auth.Principal, orgID string) ([]InvitationResponse, error) {
+	o, _, err := uc.authorize(p, orgID, org.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	invitations, err := uc.repos.Invitations.FindByOrg(o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]InvitationResponse, 0, len(invitations))
+	for _, inv := range invitations {
+		responses = append(responses, newInvitationResponse(inv))
+	}
+	return responses, nil
+}
+
+// RevokeInvitation deletes an invitation so its link stops working
+func (uc *OrgUseCase) RevokeInvitation(p *auth.Principal, orgID, invitationID string) error {
+	o, _, err := uc.authorize(p, orgID, org.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	inv, err := uc.repos.Invitations.FindByID(invitationID)
+	if err != nil || inv.OrgID != o.ID {
+		return ErrInvitationNotFound
+	}
+
+	return uc.repos.Invitations.Delete(inv.ID)
+}
+
+// AcceptInvitation adds the caller to the organization of an invitation sent to their email
+func (uc *OrgUseCase) AcceptInvitation(p *auth.Principal, token string) (*OrgResponse, error) {
+	if !canManageOrgs(p) {
+		return nil, ErrForbidden
+	}
+
+	inv, o, err := uc.findInvitation(p.TenantID, token)
+	if err != nil {
+		return nil, err
+	}
+	u, err := uc.userRepo.FindByID(p.TenantID, p.Subject)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if u.Email != inv.Email {
+		return nil, ErrInvitationEmailMismatch
+	}
+
+	m, err := uc.join(inv, u)
+	if err != nil {
+		return nil, err
+	}
+
+	response := newOrgResponse(o, m)
+	return &response, nil
+}
+
+// RegisterInvited registers the invitee of an invitation with the invited email and adds the new
+// account to the organization. Invitees who already have an account accept the invitation
+// after logging in instead.
+func (uc *OrgUseCase) RegisterInvited(tenantID, token, firstName, lastName, password string) (*UserResponse, error) {
+	inv, _, err := uc.findInvitation(tenantID, token)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := uc.userRepo.FindByEmail(tenantID, inv.Email); err == nil {
+		return nil, ErrUserExists
+	}
+
+	response, err := uc.users.Register(tenantID, firstName, lastName, inv.Email, password)
+	if err != nil {
+		return nil, err
+	}
+	u, err := uc.userRepo.FindByEmail(tenantID, inv.Email)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := uc.join(inv, u); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// CreateTeam creates a team in an organization
+func (uc *OrgUseCase) CreateTeam(p *auth.Principal, orgID, name string) (*TeamResponse, error) {
+	input := OrgInput{Name: strings.TrimSpace(name)}
+	if err := validation.Validate(&input); err != nil {
+		return nil, err
+	}
+
+	o, _, err := uc.authorize(p, orgID, org.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	t := &org.Team{ID: id, OrgID: o.ID, Name: input.Name, CreatedAt: now, UpdatedAt: now}
+	if err := uc.repos.Teams.Save(t); err != nil {
+		return nil, err
+	}
+
+	response := newTeamResponse(t)
+	return &response, nil
+}
+
+// ListTeams returns the teams of an organization the caller belongs to
+func (uc *OrgUseCase) ListTeams(p *auth.Principal, orgID string) ([]TeamResponse, error) {
+	o, _, err := uc.authorize(p, orgID, org.RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := uc.repos.Teams.FindByOrg(o.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]TeamResponse, 0, len(teams))
+	for _, t := range teams {
+		responses = append(responses, newTeamResponse(t))
+	}
+	return responses, nil
+}
+
+// DeleteTeam deletes a team; its members stay in the organization
+func (uc *OrgUseCase) DeleteTeam(p *auth.Principal, orgID, teamID string) error {
+	o, _, err := uc.authorize(p, orgID, org.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	t, err := uc.repos.Teams.FindByID(o.ID, teamID)
+	if err != nil {
+		return ErrTeamNotFound
+	}
+
+	return uc.repos.Teams.Delete(t.ID)
+}
+
+// AddTeamMember adds a member of the organization to one of its teams
+func (uc *OrgUseCase) AddTeamMember(p *auth.Principal, orgID, teamID, userID string) (*TeamResponse, error) {
+	o, _, err := uc.authorize(p, orgID, org.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := uc.repos.Teams.FindByID(o.ID, teamID)
+	if err != nil {
+		return nil, ErrTeamNotFound
+	}
+	if _, err := uc.repos.Members.Find(o.ID, userID); err != nil {
+		return nil, ErrMemberNotFound
+	}
+
+	if !t.HasMember(userID) {
+		t.MemberIDs = append(t.MemberIDs, userID)
+		t.UpdatedAt = time.Now()
+		if err := uc.repos.Teams.Update(t); err != nil {
+			return nil, err
+		}
+	}
+
+	response := newTeamResponse(t)
+	return &response, nil
+}
+
+// RemoveTeamMember removes a user from a team. Members can always leave a team.
+func (uc *OrgUseCase) RemoveTeamMember(p *auth.Principal, orgID, teamID, userID string) (*TeamResponse, error) {
+	required := org.RoleAdmin
+	if userID == p.Subject {
+		required = org.RoleMember
+	}
+	o, _, err := uc.authorize(p, orgID, required)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := uc.repos.Teams.FindByID(o.ID, teamID)
+	if err != nil {
+		return nil, ErrTeamNotFound
+	}
+	if !t.HasMember(userID) {
+		return nil, ErrMemberNotFound
+	}
+
+	t.MemberIDs = removeString(t.MemberIDs, userID)
+	t.UpdatedAt = time.Now()
+	if err := uc.repos.Teams.Update(t); err != nil {
+		return nil, err
+	}
+
+	response := newTeamResponse(t)
+	return &response, nil
+}
+
+// authorize finds an organization of the caller's tenant and the caller's membership in it, which
+// must have at least the required role. Non-members cannot tell the organization exists.
+func (uc *OrgUseCase) authorize(p *auth.Principal, orgID string, required org.Role) (*org.Organization, *org.Membership, error) {
+	if !canManageOrgs(p) {
+		return nil, nil, ErrForbidden
+	}
+
+	o, err := uc.repos.Orgs.FindByID(p.TenantID, orgID)
+	if err != nil {
+		return nil, nil, ErrOrgNotFound
+	}
+	m, err := uc.repos.Members.Find(o.ID, p.Subject)
+	if err != nil {
+		return nil, nil, ErrOrgNotFound
+	}
+	if !m.Role.AtLeast(required) {
+		return nil, nil, ErrForbidden
+	}
+
+	return o, m, nil
+}
+
+// keepOwner returns ErrLastOwner if an organization has a single owner
+func (uc *OrgUseCase) keepOwner(orgID string) error {
+	members, err := uc.repos.Members.FindByOrg(orgID)
+	if err != nil {
+		return err
+	}
+
+	owners := 0
+	for _, m := range members {
+		if m.Role == org.RoleOwner {
+			owners++
+		}
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// findInvitation verifies an invitation token and returns its pending invitation and the
+// organization, which must belong to the tenant
+func (uc *OrgUseCase) findInvitation(tenantID, token string) (*org.Invitation, *org.Organization, error) {
+	id, ok := uc.verifyInvitation(token)
+	if !ok {
+		return nil, nil, ErrInvalidInvitation
+	}
+
+	inv, err := uc.repos.Invitations.FindByID(id)
+	if err != nil || !inv.Pending(time.Now()) || uc.signInvitation(inv) != token {
+		return nil, nil, ErrInvalidInvitation
+	}
+	o, err := uc.repos.Orgs.FindByID(tenantID, inv.OrgID)
+	if err != nil {
+		return nil, nil, ErrInvalidInvitation
+	}
+
+	return inv, o, nil
+}
+
+// join adds a user to the organization of an invitation and marks the invitation accepted
+func (uc *OrgUseCase) join(inv *org.Invitation, u *user.User) (*org.Membership, error) {
+	if _, err := uc.repos.Members.Find(inv.OrgID, u.ID); err == nil {
+		return nil, ErrAlreadyMember
+	}
+
+	now := time.Now()
+	m := &org.Membership{OrgID: inv.OrgID, UserID: u.ID, Role: inv.Role, CreatedAt: now, UpdatedAt: now}
+	if err := uc.repos.Members.Save(m); err != nil {
+		return nil, err
+	}
+
+	inv.AcceptedAt = &now
+	if err := uc.repos.Invitations.Update(inv); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// signInvitation returns the token of an invitation link: the invitation ID and expiry, signed
+// with HMAC-SHA256
+func (uc *OrgUseCase) signInvitation(inv *org.Invitation) string {
+	payload := inv.ID + "." + strconv.FormatInt(inv.ExpiresAt.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(uc.invitationMAC(payload))
+}
+
+// verifyInvitation checks the signature and expiry of an invitation token and returns the
+// invitation ID
+func (uc *OrgUseCase) verifyInvitation(token string) (string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, uc.invitationMAC(parts[0]+"."+parts[1])) {
+		return "", false
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || !time.Now().Before(time.Unix(expiresAt, 0)) {
+		return "", false
+	}
+
+	return parts[0], true
+}
+
+func (uc *OrgUseCase) invitationMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, uc.config.SigningKey)
+	mac.Write([]byte("invitation:" + payload))
+	return mac.Sum(nil)
+}
+
+// inviteLink adds an invitation token to the accept URL
+func (uc *OrgUseCase) inviteLink(token string) string {
+	link, err := url.Parse(uc.config.AcceptURL)
+	if err != nil {
+		return uc.config.AcceptURL + "?token=" + token
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// canManageOrgs reports whether a principal is a user allowed to act on organizations
+func canManageOrgs(p *auth.Principal) bool {
+	return p.Type == auth.PrincipalUser && p.HasScope(ScopeOrgs)
+}
+
+func invalidRoleError() validation.FieldError {
+	return validation.FieldError{Field: "role", Message: "role must be owner, admin or member"}
+}
+
+// removeString returns values without s
+func removeString(values []string, s string) []string {
+	kept := values[:0:0]
+	for _, v := range values {
+		if v != s {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func newOrgResponse(o *org.Organization, m *org.Membership) OrgResponse {
+	return OrgResponse{
+		ID:        o.ID,
+		Name:      o.Name,
+		Role:      m.Role,
+		CreatedAt: o.CreatedAt,
+	}
+}
+
+func newMemberResponse(u *user.User, m *org.Membership) MemberResponse {
+	return MemberResponse{
+		UserID:    u.ID,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Role:      m.Role,
+		JoinedAt:  m.CreatedAt,
+	}
+}
+
+func newTeamResponse(t *org.Team) TeamResponse {
+	return TeamResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		MemberIDs: append([]string{}, t.MemberIDs...),
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func newInvitationResponse(inv *org.Invitation) InvitationResponse {
+	return InvitationResponse{
+		ID:         inv.ID,
+		Email:      inv.Email,
+		Role:       inv.Role,
+		InvitedBy:  inv.InvitedBy,
+		ExpiresAt:  inv.ExpiresAt,
+		AcceptedAt: inv.AcceptedAt,
+		CreatedAt:  inv.CreatedAt,
+	}
+}
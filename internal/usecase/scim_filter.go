@@ -0,0 +1,433 @@
+package usecase
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scimResource is the attribute tree a SCIM filter is evaluated against. Keys are lower case,
+// values are strings, bools, times, nested resources or lists of nested resources.
+type scimResource map[string]interface{}
+
+// scimFilter is a parsed SCIM filter expression, see RFC 7644 section 3.4.2.2
+type scimFilter interface {
+	matches(r scimResource) bool
+}
+
+type scimLogical struct {
+	and         bool
+	left, right scimFilter
+}
+
+func (f scimLogical) matches(r scimResource) bool {
+	if f.and {
+		return f.left.matches(r) && f.right.matches(r)
+	}
+	return f.left.matches(r) || f.right.matches(r)
+}
+
+type scimNot struct {
+	inner scimFilter
+}
+
+func (f scimNot) matches(r scimResource) bool {
+	return !f.inner.matches(r)
+}
+
+// scimValuePath matches when any element of a multi-valued attribute matches the inner filter
+type scimValuePath struct {
+	attr  string
+	inner scimFilter
+}
+
+func (f scimValuePath) matches(r scimResource) bool {
+	for _, v := range scimElements(r, f.attr) {
+		if element, ok := v.(scimResource); ok && f.inner.matches(element) {
+			return true
+		}
+	}
+	return false
+}
+
+type scimComparison struct {
+	attr string
+	op   string
+	// value is a string, bool, float64 or nil
+	value interface{}
+}
+
+func (f scimComparison) matches(r scimResource) bool {
+	values := scimLookup(r, f.attr)
+	if f.op == "pr" {
+		for _, v := range values {
+			if s, ok := v.(string); !ok || s != "" {
+				return true
+			}
+		}
+		return false
+	}
+	if f.op == "ne" {
+		return !scimComparison{attr: f.attr, op: "eq", value: f.value}.matches(r)
+	}
+	for _, v := range values {
+		if compareSCIMValue(v, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareSCIMValue applies a comparison operator to one attribute value. Strings compare
+// without regard to case, as every string attribute exposed here is case insensitive.
+func compareSCIMValue(attr interface{}, op string, value interface{}) bool {
+	switch a := attr.(type) {
+	case string:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		a, s = strings.ToLower(a), strings.ToLower(s)
+		switch op {
+		case "eq":
+			return a == s
+		case "co":
+			return strings.Contains(a, s)
+		case "sw":
+			return strings.HasPrefix(a, s)
+		case "ew":
+			return strings.HasSuffix(a, s)
+		}
+		return compareOrdered(strings.Compare(a, s), op)
+	case bool:
+		b, ok := value.(bool)
+		return ok && op == "eq" && a == b
+	case time.Time:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return false
+		}
+		if op == "eq" {
+			return a.Equal(t)
+		}
+		return compareOrdered(a.Compare(t), op)
+	}
+	return false
+}
+
+// compareOrdered applies an ordering operator to the result of a three-way comparison
+func compareOrdered(cmp int, op string) bool {
+	switch op {
+	case "gt":
+		return cmp > 0
+	case "ge":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	case "le":
+		return cmp <= 0
+	}
+	return false
+}
+
+// scimLookup returns every value at a dotted attribute path, flattening multi-valued attributes.
+// A path ending at a multi-valued complex attribute yields the elements' "value" sub-attributes.
+func scimLookup(r scimResource, path string) []interface{} {
+	elements := scimElements(r, path)
+	values := make([]interface{}, 0, len(elements))
+	for _, v := range elements {
+		if element, ok := v.(scimResource); ok {
+			if value, exists := element["value"]; exists {
+				values = append(values, value)
+			}
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// scimElements returns every value at a dotted attribute path, with the elements of
+// multi-valued attributes in place of the attribute
+func scimElements(r scimResource, path string) []interface{} {
+	current := []interface{}{r}
+	for _, name := range strings.Split(path, ".") {
+		var next []interface{}
+		for _, v := range current {
+			if element, ok := v.(scimResource); ok {
+				next = append(next, flattenSCIMValue(element[name])...)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+func flattenSCIMValue(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []scimResource:
+		values := make([]interface{}, len(v))
+		for i, element := range v {
+			values[i] = element
+		}
+		return values
+	default:
+		return []interface{}{v}
+	}
+}
+
+// normalizeSCIMAttr lower-cases an attribute path and strips a leading schema URN, so
+// "urn:ietf:params:scim:schemas:core:2.0:User:userName" and "username" are the same attribute
+func normalizeSCIMAttr(attr string) string {
+	if i := strings.LastIndex(attr, ":"); i >= 0 {
+		attr = attr[i+1:]
+	}
+	return strings.ToLower(attr)
+}
+
+// parseSCIMFilter parses a SCIM filter expression
+func parseSCIMFilter(filter string) (scimFilter, error) {
+	tokens, err := tokenizeSCIMFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &scimFilterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type scimToken struct {
+	text string
+	// quoted is set for string literals, whose text is already unquoted
+	quoted bool
+}
+
+func tokenizeSCIMFilter(s string) ([]scimToken, error) {
+	var tokens []scimToken
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			tokens = append(tokens, scimToken{text: string(c)})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			var text string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &text); err != nil {
+				return nil, fmt.Errorf("invalid string %s", s[i:end+1])
+			}
+			tokens = append(tokens, scimToken{text: text, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t()[]\"", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, scimToken{text: s[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type scimFilterParser struct {
+	tokens []scimToken
+	pos    int
+}
+
+func (p *scimFilterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *scimFilterParser) expect(text string) error {
+	if !p.peekKeyword(text) {
+		return fmt.Errorf("expected %q", text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *scimFilterParser) parseOr() (scimFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = scimLogical{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) parseAnd() (scimFilter, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = scimLogical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) parseFactor() (scimFilter, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return scimNot{inner: inner}, nil
+	}
+	if p.peekKeyword("(") {
+		p.pos++
+		return p.parseGroup()
+	}
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return nil, fmt.Errorf("expected an attribute")
+	}
+
+	attr := normalizeSCIMAttr(p.tokens[p.pos].text)
+	p.pos++
+	if p.peekKeyword("[") {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return scimValuePath{attr: attr, inner: inner}, nil
+	}
+
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("expected an operator after %q", attr)
+	}
+	op := strings.ToLower(p.tokens[p.pos].text)
+	p.pos++
+	switch op {
+	case "pr":
+		return scimComparison{attr: attr, op: op}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("expected a value after %q", op)
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	value, err := parseSCIMFilterValue(token)
+	if err != nil {
+		return nil, err
+	}
+	return scimComparison{attr: attr, op: op, value: value}, nil
+}
+
+// parseGroup parses the rest of a parenthesized expression after its opening parenthesis
+func (p *scimFilterParser) parseGroup() (scimFilter, error) {
+	inner, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return inner, nil
+}
+
+func parseSCIMFilterValue(token scimToken) (interface{}, error) {
+	if token.quoted {
+		return token.text, nil
+	}
+	switch strings.ToLower(token.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if n, err := strconv.ParseFloat(token.text, 64); err == nil {
+		return n, nil
+	}
+	return nil, fmt.Errorf("invalid value %q", token.text)
+}
+
+// scimPath is a parsed PATCH path such as "name.givenName" or `members[value eq "42"]`
+type scimPath struct {
+	attr   string
+	filter scimFilter
+	sub    string
+}
+
+// parseSCIMPath parses a PATCH operation path, see RFC 7644 section 3.5.2
+func parseSCIMPath(path string) (scimPath, error) {
+	path = strings.TrimSpace(path)
+	open := strings.Index(path, "[")
+	if open < 0 {
+		attr := normalizeSCIMAttr(path)
+		if attr == "" {
+			return scimPath{}, fmt.Errorf("empty path")
+		}
+		if name, sub, ok := strings.Cut(attr, "."); ok {
+			return scimPath{attr: name, sub: sub}, nil
+		}
+		return scimPath{attr: attr}, nil
+	}
+
+	closing := strings.LastIndex(path, "]")
+	if closing < open {
+		return scimPath{}, fmt.Errorf("unbalanced brackets")
+	}
+	filter, err := parseSCIMFilter(path[open+1 : closing])
+	if err != nil {
+		return scimPath{}, err
+	}
+	parsed := scimPath{attr: normalizeSCIMAttr(path[:open]), filter: filter}
+	if rest := path[closing+1:]; rest != "" {
+		if !strings.HasPrefix(rest, ".") || len(rest) == 1 {
+			return scimPath{}, fmt.Errorf("invalid sub-attribute %q", rest)
+		}
+		parsed.sub = strings.ToLower(rest[1:])
+	}
+	return parsed, nil
+}
@@ -0,0 +1,942 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/group"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// ScopeSCIM allows a principal to provision users and groups over SCIM
+const ScopeSCIM = "scim"
+
+// Page sizes of SCIM list responses
+const (
+	SCIMDefaultCount = 100
+	SCIMMaxCount     = 200
+)
+
+// SCIM error types, see RFC 7644 section 3.12
+const (
+	SCIMInvalidFilter = "invalidFilter"
+	SCIMInvalidPath   = "invalidPath"
+	SCIMInvalidValue  = "invalidValue"
+	SCIMInvalidSyntax = "invalidSyntax"
+	SCIMNoTarget      = "noTarget"
+	SCIMUniqueness    = "uniqueness"
+)
+
+// SCIMError is a SCIM protocol error with its standard error type
+type SCIMError struct {
+	Type   string
+	Detail string
+}
+
+// Error implements the error interface
+func (e *SCIMError) Error() string {
+	return e.Type + ": " + e.Detail
+}
+
+// newSCIMError creates a SCIM protocol error
+func newSCIMError(scimType, format string, args ...interface{}) *SCIMError {
+	return &SCIMError{Type: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+// SCIMQuery selects a page of a SCIM resource list
+type SCIMQuery struct {
+	Filter string
+	// StartIndex is the 1-based index of the first result
+	StartIndex int
+	Count      int
+}
+
+// SCIMGroupRef is a group a SCIM user belongs to
+type SCIMGroupRef struct {
+	ID          string
+	DisplayName string
+}
+
+// SCIMUser is the SCIM view of a user
+type SCIMUser struct {
+	ID         string
+	ExternalID string
+	UserName   string
+	GivenName  string
+	FamilyName string
+	Active     bool
+	Groups     []SCIMGroupRef
+	Created    time.Time
+	Modified   time.Time
+	Version    string
+}
+
+// SCIMUserInput holds the writable attributes of a SCIM user
+type SCIMUserInput struct {
+	UserName   string
+	ExternalID string
+	GivenName  string
+	FamilyName string
+	// DisplayName is used for the name when no given or family name is sent
+	DisplayName string
+	// Active defaults to true when not sent
+	Active *bool
+}
+
+// SCIMMember is a member of a SCIM group
+type SCIMMember struct {
+	ID      string
+	Display string
+}
+
+// SCIMGroup is the SCIM view of a group
+type SCIMGroup struct {
+	ID          string
+	ExternalID  string
+	DisplayName string
+	Members     []SCIMMember
+	Created     time.Time
+	Modified    time.Time
+	Version     string
+}
+
+// SCIMGroupInput holds the writable attributes of a SCIM group
+type SCIMGroupInput struct {
+	DisplayName string
+	ExternalID  string
+	// MemberIDs are user IDs
+	MemberIDs []string
+}
+
+// SCIMPatchOperation is a single PATCH operation. Value holds decoded JSON.
+type SCIMPatchOperation struct {
+	Op    string
+	Path  string
+	Value interface{}
+}
+
+// SCIMUserList is a page of users
+type SCIMUserList struct {
+	Resources    []SCIMUser
+	TotalResults int
+	StartIndex   int
+}
+
+// SCIMGroupList is a page of groups
+type SCIMGroupList struct {
+	Resources    []SCIMGroup
+	TotalResults int
+	StartIndex   int
+}
+
+// SCIMUseCase provisions users and groups on behalf of a SCIM client such as an identity provider
+type SCIMUseCase struct {
+	userRepo  user.Repository
+	groupRepo group.Repository
+	users     *UserUseCase
+}
+
+// NewSCIMUseCase creates a new SCIM use case instance. Deactivated users are signed out through
+// users, which revokes their refresh tokens and sessions as disabling an account by hand does.
+func NewSCIMUseCase(userRepo user.Repository, groupRepo group.Repository, users *UserUseCase) *SCIMUseCase {
+	return &SCIMUseCase{
+		userRepo:  userRepo,
+		groupRepo: groupRepo,
+		users:     users,
+	}
+}
+
+// authorize allows services and admins holding the SCIM scope
+func (uc *SCIMUseCase) authorize(p *auth.Principal) error {
+	if !p.HasScope(ScopeSCIM) {
+		return ErrForbidden
+	}
+	if p.Type != auth.PrincipalService && !p.HasRole(user.RoleAdmin) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// ListUsers returns the users matching the query
+func (uc *SCIMUseCase) ListUsers(p *auth.Principal, query SCIMQuery) (*SCIMUserList, error) {
+	if err := uc.authorize(p); err != nil {
+		return nil, err
+	}
+	filter, err := parseQueryFilter(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := uc.userRepo.FindAll(p.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []SCIMUser
+	for _, u := range users {
+		scimUser, err := uc.newSCIMUser(u)
+		if err != nil {
+			return nil, err
+		}
+		if filter == nil || filter.matches(scimUserResource(scimUser)) {
+			matched = append(matched, *scimUser)
+		}
+	}
+
+	start, end := pageBounds(query, len(matched))
+	return &SCIMUserList{
+		Resources:    matched[start:end],
+		TotalResults: len(matched),
+		StartIndex:   start + 1,
+	}, nil
+}
+
+// GetUser returns a single user
+func (uc *SCIMUseCase) GetUser(p *auth.Principal, id string) (*SCIMUser, error) {
+	if err := uc.authorize(p); err != nil {
+		return nil, err
+	}
+	u, err := uc.findUser(p.TenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	return uc.newSCIMUser(u)
+}
+
+// CreateUser provisions a user. Provisioned users have no local password and sign in through
+// single sign-on, or set a password with a reset.
+func (uc *SCIMUseCase) CreateUser(p *auth.Principal, input SCIMUserInput) (*SCIMUser, error) {
+	if err := uc.authorize(p); err != nil {
+		return nil, err
+	}
+	email, err := normalizeSCIMUserName(input.UserName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := uc.userRepo.FindByEmail(p.TenantID, email); err == nil {
+		return nil, newSCIMError(SCIMUniqueness, "userName %s is already taken", email)
+	}
+
+	firstName, lastName := scimNames(input, email)
+	u := user.NewUser(p.TenantID, firstName, lastName, email, "")
+	u.ExternalID = input.ExternalID
+	u.Disabled = input.Active != nil && !*input.Active
+	if err := uc.userRepo.Save(u); err != nil {
+		return nil, err
+	}
+	return uc.newSCIMUser(u)
+}
+
+// ReplaceUser replaces the writable attributes of a user. A non-empty version must match the
+// current one.
+func (uc *SCIMUseCase) ReplaceUser(p *auth.Principal, id, version string, input SCIMUserInput) (*SCIMUser, error) {
+	if err := uc.authorize(p); err != nil {
+		return nil, err
+	}
+	u, err := uc.findUser(p.TenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSCIMVersion(version, u.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	email, err := normalizeSCIMUserName(input.UserName)
+	if err != nil {
+		return nil, err
+	}
+	updated := *u
+	updated.ChangeEmail(email)
+	updated.FirstName, updated.LastName = scimNames(input, email)
+	updated.ExternalID = input.ExternalID
+	updated.SetDisabled(input.Active != nil && !*input.Active)
+	return uc.saveUser(u, &updated)
+}
+
+// PatchUser applies PATCH operations to a user. A non-empty version must match the current one.
+func (uc *SCIMUseCase) PatchUser(p *auth.Principal, id, version string, ops []SCIMPatchOperation) (*SCIMUser, error) {
+	if err := uc.authorize(p); err != nil {
+		return nil, err
+	}
+	u, err := uc.findUser(p.TenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSCIMVersion(version, u.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	updated := *u
+	for _, op := range ops {
+		if err := patchUser(&updated, op); err != nil {
+			return nil, err
+		}
+	}
+	return uc.saveUser(u, &updated)
+}
+
+// DeleteUser deprovisions a user. The account is disabled rather than deleted, so its data and
+// audit trail survive and it can be re-activated later.
+func (uc *SCIMUseCase) DeleteUser(p *auth.Principal, id, version string) error {
+	if err := uc.authorize(p); err != nil {
+		return err
+	}
+	u, err := uc.findUser(p.TenantID, id)
+	if err != nil {
+		return err
+	}
+	if err := checkSCIMVersion(version, u.UpdatedAt); err != nil {
+		return err
+	}
+
+	updated := *u
+	updated.SetDisabled(true)
+	_, err = uc.saveUser(u, &updated)
+	return err
+}
+
+// ListGroups returns the groups matching the query
+func (uc *SCIMUseCase) ListGroups(p *auth.Principal, query SCIMQuery) (*SCIMGroupList, error) {
+	if err := uc.authorize(p); err != nil {
+		return nil, err
+	}
+	filter, err := parseQueryFilter(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := uc.groupRepo.FindAll(p.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []SCIMGroup
+	for _, g := range groups {
+		scimGroup := uc.newSCIMGroup(g)
+		if filter == nil || filter.matches(scimGroupResource(scimGroup)) {
+			matched = append(matched, *scimGroup)
+		}
+	}
+
+	start, end := pageBounds(query, len(matched))
+	return &SCIMGroupList{
+		Resources:    matched[start:end],
+		TotalResults: len(matched),
+		StartIndex:   start + 1,
+	}, nil
+}
+
+// GetGroup returns a single group
+func (uc *SCIMUseCase) GetGroup(p *auth.Principal, id string) (*SCIMGroup, error) {
+	if err := uc.authorize(p); err != nil {
+		return nil, err
+	}
+	g, err := uc.findGroup(p.TenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	return uc.newSCIMGroup(g), nil
+}
+
+// CreateGroup creates a group
+func (uc *SCIMUseCase) CreateGroup(p *auth.Principal, input SCIMGroupInput) (*SCIMGroup, error) {
+	if err := uc.authorize(p); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	g := &group.Group{
+		ID:          user.NewID(),
+		TenantID:    p.TenantID,
+		DisplayName: strings.TrimSpace(input.DisplayName),
+		ExternalID:  input.ExternalID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := uc.setMembers(g, input.MemberIDs); err != nil {
+		return nil, err
+	}
+	if err := uc.storeGroup(g, uc.groupRepo.Save); err != nil {
+		return nil, err
+	}
+	return uc.newSCIMGroup(g), nil
+}
+
+// ReplaceGroup replaces a group. A non-empty version must match the current one.
+func (uc *SCIMUseCase) ReplaceGroup(p *auth.Principal, id, version string, input SCIMGroupInput) (*SCIMGroup, error) {
+	if err := uc.authorize(p); err != nil {
+		return nil, err
+	}
+	g, err := uc.findGroup(p.TenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSCIMVersion(version, g.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	g.DisplayName = strings.TrimSpace(input.DisplayName)
+	g.ExternalID = input.ExternalID
+	if err := uc.setMembers(g, input.MemberIDs); err != nil {
+		return nil, err
+	}
+	g.UpdatedAt = time.Now()
+	if err := uc.storeGroup(g, uc.groupRepo.Update); err != nil {
+		return nil, err
+	}
+	return uc.newSCIMGroup(g), nil
+}
+
+// PatchGroup applies PATCH operations to a group. A non-empty version must match the current one.
+func (uc *SCIMUseCase) PatchGroup(p *auth.Principal, id, version string, ops []SCIMPatchOperation) (*SCIMGroup, error) {
+	if err := uc.authorize(p); err != nil {
+		return nil, err
+	}
+	g, err := uc.findGroup(p.TenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSCIMVersion(version, g.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	members := append([]string(nil), g.MemberIDs...)
+	for _, op := range ops {
+		if members, err = patchGroup(g, members, op); err != nil {
+			return nil, err
+		}
+	}
+	if err := uc.setMembers(g, members); err != nil {
+		return nil, err
+	}
+	g.UpdatedAt = time.Now()
+	if err := uc.storeGroup(g, uc.groupRepo.Update); err != nil {
+		return nil, err
+	}
+	return uc.newSCIMGroup(g), nil
+}
+
+// DeleteGroup deletes a group. Its members are not affected.
+func (uc *SCIMUseCase) DeleteGroup(p *auth.Principal, id, version string) error {
+	if err := uc.authorize(p); err != nil {
+		return err
+	}
+	g, err := uc.findGroup(p.TenantID, id)
+	if err != nil {
+		return err
+	}
+	if err := checkSCIMVersion(version, g.UpdatedAt); err != nil {
+		return err
+	}
+	return uc.groupRepo.Delete(g.ID)
+}
+
+func (uc *SCIMUseCase) findUser(tenantID, id string) (*user.User, error) {
+	u, err := uc.userRepo.FindByID(tenantID, id)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (uc *SCIMUseCase) findGroup(tenantID, id string) (*group.Group, error) {
+	g, err := uc.groupRepo.FindByID(tenantID, id)
+	if errors.Is(err, group.ErrNotFound) {
+		return nil, ErrGroupNotFound
+	}
+	return g, err
+}
+
+// saveUser stores the changes to a user, and signs the user out everywhere when the change
+// disables the account
+func (uc *SCIMUseCase) saveUser(current, updated *user.User) (*SCIMUser, error) {
+	if updated.Email != current.Email {
+		if _, err := uc.userRepo.FindByEmail(updated.TenantID, updated.Email); err == nil {
+			return nil, newSCIMError(SCIMUniqueness, "userName %s is already taken", updated.Email)
+		}
+	}
+	disabling := updated.Disabled && !current.Disabled
+
+	updated.UpdatedAt = time.Now()
+	if err := uc.userRepo.Update(updated); err != nil {
+		return nil, err
+	}
+	if disabling {
+		if _, err := uc.users.signOut(updated); err != nil {
+			return nil, err
+		}
+	}
+	return uc.newSCIMUser(updated)
+}
+
+// storeGroup validates a group and stores it with save
+func (uc *SCIMUseCase) storeGroup(g *group.Group, save func(*group.Group) error) error {
+	if g.DisplayName == "" {
+		return newSCIMError(SCIMInvalidValue, "displayName is required")
+	}
+	err := save(g)
+	if errors.Is(err, group.ErrDuplicateName) {
+		return newSCIMError(SCIMUniqueness, "displayName %s is already taken", g.DisplayName)
+	}
+	return err
+}
+
+// setMembers replaces the members of a group, rejecting users that are not in its tenant
+func (uc *SCIMUseCase) setMembers(g *group.Group, memberIDs []string) error {
+	members := make([]string, 0, len(memberIDs))
+	seen := make(map[string]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		if seen[id] {
+			continue
+		}
+		if _, err := uc.userRepo.FindByID(g.TenantID, id); err != nil {
+			return newSCIMError(SCIMInvalidValue, "member %s is not a known user", id)
+		}
+		seen[id] = true
+		members = append(members, id)
+	}
+	g.MemberIDs = members
+	return nil
+}
+
+func (uc *SCIMUseCase) newSCIMUser(u *user.User) (*SCIMUser, error) {
+	groups, err := uc.groupRepo.FindByMember(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]SCIMGroupRef, 0, len(groups))
+	for _, g := range groups {
+		refs = append(refs, SCIMGroupRef{ID: g.ID, DisplayName: g.DisplayName})
+	}
+
+	return &SCIMUser{
+		ID:         u.ID,
+		ExternalID: u.ExternalID,
+		UserName:   u.Email,
+		GivenName:  u.FirstName,
+		FamilyName: u.LastName,
+		Active:     !u.Disabled,
+		Groups:     refs,
+		Created:    u.CreatedAt,
+		Modified:   u.UpdatedAt,
+		Version:    scimVersion(u.UpdatedAt),
+	}, nil
+}
+
+func (uc *SCIMUseCase) newSCIMGroup(g *group.Group) *SCIMGroup {
+	members := make([]SCIMMember, 0, len(g.MemberIDs))
+	for _, id := range g.MemberIDs {
+		member := SCIMMember{ID: id}
+		if u, err := uc.userRepo.FindByID(g.TenantID, id); err == nil {
+			member.Display = u.Email
+		}
+		members = append(members, member)
+	}
+
+	return &SCIMGroup{
+		ID:          g.ID,
+		ExternalID:  g.ExternalID,
+		DisplayName: g.DisplayName,
+		Members:     members,
+		Created:     g.CreatedAt,
+		Modified:    g.UpdatedAt,
+		Version:     scimVersion(g.UpdatedAt),
+	}
+}
+
+// scimVersion derives the weak entity tag of a resource from its last modification
+func scimVersion(updatedAt time.Time) string {
+	return fmt.Sprintf(`W/"%x"`, updatedAt.UnixNano())
+}
+
+// checkSCIMVersion compares an If-Match header value with the current version of a resource
+func checkSCIMVersion(ifMatch string, updatedAt time.Time) error {
+	if ifMatch == "" || MatchesSCIMVersion(ifMatch, scimVersion(updatedAt)) {
+		return nil
+	}
+	return ErrVersionMismatch
+}
+
+// MatchesSCIMVersion reports whether an If-Match or If-None-Match header value lists version
+// or "*". Entity tags are compared weakly.
+func MatchesSCIMVersion(header, version string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(version, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseQueryFilter(filter string) (scimFilter, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+	f, err := parseSCIMFilter(filter)
+	if err != nil {
+		return nil, newSCIMError(SCIMInvalidFilter, "%v", err)
+	}
+	return f, nil
+}
+
+// pageBounds converts the 1-based start index and count of a query into slice bounds
+func pageBounds(query SCIMQuery, total int) (int, int) {
+	start := query.StartIndex - 1
+	if start < 0 {
+		start = 0
+	}
+	if start > total {
+		start = total
+	}
+	count := query.Count
+	if count < 0 {
+		count = 0
+	}
+	if count > SCIMMaxCount {
+		count = SCIMMaxCount
+	}
+	end := start + count
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func normalizeSCIMUserName(userName string) (string, error) {
+	email := validation.NormalizeEmail(userName)
+	if email == "" {
+		return "", newSCIMError(SCIMInvalidValue, "userName is required")
+	}
+	input := struct {
+		Email string `json:"userName" validate:"email"`
+	}{email}
+	if err := validation.Validate(&input); err != nil {
+		return "", newSCIMError(SCIMInvalidValue, "userName must be an email address")
+	}
+	return email, nil
+}
+
+// scimNames picks the first and last name of a user, falling back to the display name and
+// then the local part of the email, as accounts created by external logins do
+func scimNames(input SCIMUserInput, email string) (string, string) {
+	firstName := validation.NormalizeName(input.GivenName)
+	lastName := validation.NormalizeName(input.FamilyName)
+	if firstName != "" || lastName != "" {
+		return firstName, lastName
+	}
+	if name := validation.NormalizeName(input.DisplayName); name != "" {
+		firstName, lastName, _ = strings.Cut(name, " ")
+		return firstName, lastName
+	}
+	firstName, _, _ = strings.Cut(email, "@")
+	return firstName, ""
+}
+
+func scimUserResource(u *SCIMUser) scimResource {
+	groups := make([]scimResource, 0, len(u.Groups))
+	for _, g := range u.Groups {
+		groups = append(groups, scimResource{"value": g.ID, "display": g.DisplayName})
+	}
+	return scimResource{
+		"id":         u.ID,
+		"externalid": u.ExternalID,
+		"username":   u.UserName,
+		"name": scimResource{
+			"givenname":  u.GivenName,
+			"familyname": u.FamilyName,
+		},
+		"emails": []scimResource{{"value": u.UserName, "type": "work", "primary": true}},
+		"active": u.Active,
+		"groups": groups,
+		"meta": scimResource{
+			"created":      u.Created,
+			"lastmodified": u.Modified,
+		},
+	}
+}
+
+func scimGroupResource(g *SCIMGroup) scimResource {
+	members := make([]scimResource, 0, len(g.Members))
+	for _, m := range g.Members {
+		members = append(members, scimResource{"value": m.ID, "display": m.Display})
+	}
+	return scimResource{
+		"id":          g.ID,
+		"externalid":  g.ExternalID,
+		"displayname": g.DisplayName,
+		"members":     members,
+		"meta": scimResource{
+			"created":      g.Created,
+			"lastmodified": g.Modified,
+		},
+	}
+}
+
+// patchUser applies one PATCH operation to a user. Attributes that are not stored, such as
+// phone numbers or the enterprise extension, are ignored so clients can send full profiles.
+func patchUser(u *user.User, op SCIMPatchOperation) error {
+	kind := strings.ToLower(op.Op)
+	if kind != "add" && kind != "replace" && kind != "remove" {
+		return newSCIMError(SCIMInvalidSyntax, "unsupported operation %q", op.Op)
+	}
+
+	if op.Path == "" {
+		if kind == "remove" {
+			return newSCIMError(SCIMNoTarget, "remove requires a path")
+		}
+		values, ok := op.Value.(map[string]interface{})
+		if !ok {
+			return newSCIMError(SCIMInvalidValue, "an operation without a path needs an object value")
+		}
+		for attr, value := range values {
+			if err := patchUser(u, SCIMPatchOperation{Op: kind, Path: attr, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := parseSCIMPath(op.Path)
+	if err != nil {
+		return newSCIMError(SCIMInvalidPath, "%v", err)
+	}
+
+	switch path.attr {
+	case "active":
+		if kind == "remove" {
+			return newSCIMError(SCIMInvalidValue, "active cannot be removed")
+		}
+		active, ok := scimBool(op.Value)
+		if !ok {
+			return newSCIMError(SCIMInvalidValue, "active must be a boolean")
+		}
+		u.SetDisabled(!active)
+	case "username", "emails":
+		if kind == "remove" {
+			return newSCIMError(SCIMInvalidValue, "%s cannot be removed", path.attr)
+		}
+		value, ok := scimEmailValue(op.Value)
+		if !ok {
+			return newSCIMError(SCIMInvalidValue, "%s must be an email address", path.attr)
+		}
+		email, err := normalizeSCIMUserName(value)
+		if err != nil {
+			return err
+		}
+		u.ChangeEmail(email)
+	case "externalid":
+		value, _ := op.Value.(string)
+		if kind == "remove" {
+			value = ""
+		}
+		u.ExternalID = value
+	case "name":
+		return patchUserName(u, kind, path.sub, op.Value)
+	}
+	return nil
+}
+
+// patchUserName applies an operation to the name attribute or one of its sub-attributes
+func patchUserName(u *user.User, kind, sub string, value interface{}) error {
+	if sub == "" {
+		if kind == "remove" {
+			u.FirstName, u.LastName = "", ""
+			return nil
+		}
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return newSCIMError(SCIMInvalidValue, "name must be an object")
+		}
+		for attr, v := range values {
+			if err := patchUserName(u, kind, strings.ToLower(attr), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s, _ := value.(string)
+	if kind == "remove" {
+		s = ""
+	}
+	switch sub {
+	case "givenname":
+		u.FirstName = validation.NormalizeName(s)
+	case "familyname":
+		u.LastName = validation.NormalizeName(s)
+	}
+	return nil
+}
+
+// patchGroup applies one PATCH operation to a group and returns the resulting member IDs
+func patchGroup(g *group.Group, members []string, op SCIMPatchOperation) ([]string, error) {
+	kind := strings.ToLower(op.Op)
+	if kind != "add" && kind != "replace" && kind != "remove" {
+		return nil, newSCIMError(SCIMInvalidSyntax, "unsupported operation %q", op.Op)
+	}
+
+	if op.Path == "" {
+		if kind == "remove" {
+			return nil, newSCIMError(SCIMNoTarget, "remove requires a path")
+		}
+		values, ok := op.Value.(map[string]interface{})
+		if !ok {
+			return nil, newSCIMError(SCIMInvalidValue, "an operation without a path needs an object value")
+		}
+		var err error
+		for attr, value := range values {
+			if members, err = patchGroup(g, members, SCIMPatchOperation{Op: kind, Path: attr, Value: value}); err != nil {
+				return nil, err
+			}
+		}
+		return members, nil
+	}
+
+	path, err := parseSCIMPath(op.Path)
+	if err != nil {
+		return nil, newSCIMError(SCIMInvalidPath, "%v", err)
+	}
+
+	switch path.attr {
+	case "displayname":
+		if kind == "remove" {
+			return nil, newSCIMError(SCIMInvalidValue, "displayName cannot be removed")
+		}
+		name, ok := op.Value.(string)
+		if !ok {
+			return nil, newSCIMError(SCIMInvalidValue, "displayName must be a string")
+		}
+		g.DisplayName = strings.TrimSpace(name)
+	case "externalid":
+		value, _ := op.Value.(string)
+		if kind == "remove" {
+			value = ""
+		}
+		g.ExternalID = value
+	case "members":
+		return patchMembers(members, kind, path, op.Value)
+	default:
+		return nil, newSCIMError(SCIMInvalidPath, "unknown attribute %q", op.Path)
+	}
+	return members, nil
+}
+
+// patchMembers applies an operation to the members attribute
+func patchMembers(members []string, kind string, path scimPath, value interface{}) ([]string, error) {
+	if path.filter != nil {
+		// A filtered path selects existing members, which can only be removed
+		if kind != "remove" {
+			return nil, newSCIMError(SCIMInvalidPath, "only remove supports a member filter")
+		}
+		kept := members[:0:0]
+		for _, id := range members {
+			if !path.filter.matches(scimResource{"value": id}) {
+				kept = append(kept, id)
+			}
+		}
+		return kept, nil
+	}
+
+	ids, err := scimMemberIDs(value)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "add":
+		return append(members, ids...), nil
+	case "replace":
+		return ids, nil
+	}
+
+	// Removing without a value removes every member
+	if value == nil {
+		return nil, nil
+	}
+	removed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+	}
+	kept := members[:0:0]
+	for _, id := range members {
+		if !removed[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept, nil
+}
+
+// scimMemberIDs reads the user IDs from a members value, a list of {"value": id} objects
+func scimMemberIDs(value interface{}) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+
+	ids := make([]string, 0, len(list))
+	for _, element := range list {
+		member, ok := element.(map[string]interface{})
+		if !ok {
+			return nil, newSCIMError(SCIMInvalidValue, "members must be objects with a value")
+		}
+		id, ok := member["value"].(string)
+		if !ok || id == "" {
+			return nil, newSCIMError(SCIMInvalidValue, "members must be objects with a value")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// scimBool reads a boolean, accepting the "True" and "False" strings some clients send
+func scimBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(v) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// scimEmailValue reads an email from a userName or emails value: a string, an email object or
+// a list of email objects, of which the primary one wins
+func scimEmailValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case map[string]interface{}:
+		email, ok := v["value"].(string)
+		return email, ok
+	case []interface{}:
+		var first string
+		for _, element := range v {
+			email, ok := scimEmailValue(element)
+			if !ok {
+				continue
+			}
+			if object, _ := element.(map[string]interface{}); object != nil {
+				if primary, _ := scimBool(object["primary"]); primary {
+					return email, true
+				}
+			}
+			if first == "" {
+				first = email
+			}
+		}
+		return first, first != ""
+	}
+	return "", false
+}
@@ -0,0 +1,259 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/session"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+)
+
+// sessionTouchResolution limits how often the last-seen timestamp of a session is written
+const sessionTouchResolution = time.Minute
+
+// SessionConfig holds the session lifetimes
+type SessionConfig struct {
+	// IdleTimeout ends sessions that have not been used for this long
+	IdleTimeout time.Duration
+	// AbsoluteTimeout ends sessions this long after login regardless of activity
+	AbsoluteTimeout time.Duration
+}
+
+// DefaultSessionConfig returns a 30 minute idle timeout and a 24 hour absolute timeout
+func DefaultSessionConfig() SessionConfig {
+	return SessionConfig{
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 24 * time.Hour,
+	}
+}
+
+// SessionClient describes the client a session was created from
+type SessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+// SessionResponse represents the session data that is safe to return
+type SessionResponse struct {
+	ID         string
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+}
+
+// CreatedSession is returned on login and is the only time the cookie token is available
+type CreatedSession struct {
+	Session   SessionResponse
+	User      UserResponse
+	Token     string
+	CSRFToken string
+}
+
+// SessionUseCase defines the use cases for cookie-based browser sessions
+type SessionUseCase struct {
+	sessionRepo session.Repository
+	userRepo    user.Repository
+	users       *UserUseCase
+	config      SessionConfig
+}
+
+// NewSessionUseCase creates a new session use case instance. Credentials are verified by users.
+func NewSessionUseCase(sessionRepo session.Repository, userRepo user.Repository, users *UserUseCase, config SessionConfig) *SessionUseCase {
+	return &SessionUseCase{
+		sessionRepo: sessionRepo,
+		userRepo:    userRepo,
+		users:       users,
+		config:      config,
+	}
+}
+
+// Login verifies credentials in a tenant and starts a session
+func (uc *SessionUseCase) Login(tenantID, email, password string, client SessionClient) (*CreatedSession, error) {
+	u, err := uc.users.VerifyCredentials(tenantID, email, password)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	csrfToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s := &session.Session{
+		ID:         hashSessionToken(token),
+		UserID:     u.ID,
+		CSRFToken:  csrfToken,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(uc.config.AbsoluteTimeout),
+	}
+	if err := uc.sessionRepo.Save(s); err != nil {
+		return nil, err
+	}
+
+	return &CreatedSession{
+		Session:   newSessionResponse(s),
+		User:      newUserResponse(u),
+		Token:     token,
+		CSRFToken: csrfToken,
+	}, nil
+}
+
+// Authenticate resolves a session cookie token presented to a tenant to the principal of its
+// user. Sessions of another tenant's users are rejected. Use extends the idle deadline but never
+// the absolute expiry.
+func (uc *SessionUseCase) Authenticate(tenantID, token string) (*auth.Principal, error) {
+	if token == "" {
+		return nil, ErrInvalidSession
+	}
+
+	s, err := uc.sessionRepo.FindByID(hashSessionToken(token))
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+
+	now := time.Now()
+	if !s.Active(now, uc.config.IdleTimeout) {
+		if err := uc.sessionRepo.Delete(s.ID); err != nil {
+			log.Printf("Error deleting expired session of user %s: %v", s.UserID, err)
+		}
+		return nil, ErrInvalidSession
+	}
+
+	u, err := uc.userRepo.FindByID(tenantID, s.UserID)
+	if err != nil || u.Disabled {
+		return nil, ErrInvalidSession
+	}
+
+	if now.Sub(s.LastSeenAt) >= sessionTouchResolution {
+		s.LastSeenAt = now
+		if err := uc.sessionRepo.Update(s); err != nil {
+			log.Printf("Error updating last use of session of user %s: %v", s.UserID, err)
+		}
+	}
+
+	principal := newUserPrincipal(u)
+	principal.AuthMethod = auth.AuthMethodSession
+	principal.SessionID = s.ID
+	principal.CSRFToken = s.CSRFToken
+	return principal, nil
+}
+
+// Current returns the session the principal authenticated with
+func (uc *SessionUseCase) Current(p *auth.Principal) (*SessionResponse, error) {
+	if p.SessionID == "" {
+		return nil, ErrSessionNotFound
+	}
+
+	s, err := uc.sessionRepo.FindByID(p.SessionID)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+
+	response := newSessionResponse(s)
+	return &response, nil
+}
+
+// List returns the active sessions of the principal's user. Only the user, signed in with a login
+// token or session, may see them; API keys and OAuth tokens acting for the user may not.
+func (uc *SessionUseCase) List(p *auth.Principal) ([]SessionResponse, error) {
+	if !isInteractiveUser(p) {
+		return nil, ErrForbidden
+	}
+
+	sessions, err := uc.sessionRepo.FindByUser(p.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	responses := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		if s.Active(now, uc.config.IdleTimeout) {
+			responses = append(responses, newSessionResponse(s))
+		}
+	}
+	return responses, nil
+}
+
+// Terminate ends one of the principal's sessions. Like List, it is limited to the signed in user.
+// Sessions of other users are reported as not found.
+func (uc *SessionUseCase) Terminate(p *auth.Principal, id string) error {
+	if !isInteractiveUser(p) {
+		return ErrForbidden
+	}
+	s, err := uc.sessionRepo.FindByID(id)
+	if err != nil || s.UserID != p.Subject {
+		return ErrSessionNotFound
+	}
+
+	return uc.sessionRepo.Delete(s.ID)
+}
+
+// Logout ends the session the principal authenticated with
+func (uc *SessionUseCase) Logout(p *auth.Principal) error {
+	if p.SessionID == "" {
+		return ErrSessionNotFound
+	}
+
+	if err := uc.sessionRepo.Delete(p.SessionID); err != nil {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// TerminateUser ends every session of a user
+func (uc *SessionUseCase) TerminateUser(userID string) error {
+	return uc.sessionRepo.DeleteByUser(userID)
+}
+
+// PurgeExpired removes sessions that are no longer active from the store
+func (uc *SessionUseCase) PurgeExpired() error {
+	return uc.sessionRepo.DeleteExpired(time.Now(), uc.config.IdleTimeout)
+}
+
+// Config returns the session lifetimes
+func (uc *SessionUseCase) Config() SessionConfig {
+	return uc.config
+}
+
+// hashSessionToken returns the session ID for a cookie token
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken returns 256 random bits encoded for use in cookies and headers
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newSessionResponse converts a session to its response representation
+func newSessionResponse(s *session.Session) SessionResponse {
+	return SessionResponse{
+		ID:         s.ID,
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IPAddress,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+	}
+}
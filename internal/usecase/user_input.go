@@ -0,0 +1,41 @@
+package usecase
+
+import "github.com/lamboktulussimamora/gra-project/internal/validation"
+
+// RegisterInput holds the validated fields of a registration request
+type RegisterInput struct {
+	FirstName string `json:"first_name" validate:"required,max=50,name"`
+	LastName  string `json:"last_name" validate:"required,max=50,name"`
+	Email     string `json:"email" validate:"required,max=254,email"`
+	Password  string `json:"password" validate:"required,password"`
+}
+
+// Normalize canonicalizes the input before validation
+func (in *RegisterInput) Normalize() {
+	in.FirstName = validation.NormalizeName(in.FirstName)
+	in.LastName = validation.NormalizeName(in.LastName)
+	in.Email = validation.NormalizeEmail(in.Email)
+}
+
+// ChangePasswordInput holds the validated fields of a password change request
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required,password"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
+}
+
+// ResetPasswordInput holds the validated fields of a password reset request
+type ResetPasswordInput struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,password"`
+}
+
+// LoginInput holds the validated fields of a login request
+type LoginInput struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+// Normalize canonicalizes the input before validation
+func (in *LoginInput) Normalize() {
+	in.Email = validation.NormalizeEmail(in.Email)
+}
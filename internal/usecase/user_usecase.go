@@ -1,18 +1,41 @@
 package usecase
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/mail"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/oauth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/session"
 	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/webhook"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
 )
 
+// defaultResetTokenTTL is how long a password reset token stays valid
+const defaultResetTokenTTL = time.Hour
+
+// ScopeUsersRead allows an admin's scoped credential, such as an API key or an OAuth access token,
+// to read other accounts of the tenant
+const ScopeUsersRead = "users:read"
+
 // UserResponse represents the user data that is safe to return in API responses
 type UserResponse struct {
+	ID        string
 	FirstName string
 	LastName  string
 	Email     string
+	Roles     []string
+	Disabled  bool
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -21,6 +44,8 @@ type UserResponse struct {
 type AuthResponse struct {
 	User  UserResponse
 	Token string
+	// RefreshToken renews the token; it is only issued when refresh tokens are enabled
+	RefreshToken string
 }
 
 // UserUseCase defines the application use cases for user management
@@ -28,6 +53,93 @@ type UserUseCase struct {
 	userRepo        user.Repository
 	passwordService auth.PasswordService
 	jwtService      auth.JWTService
+	passwordPolicy  auth.PasswordPolicy
+	resetRepo       user.PasswordResetRepository
+	mailer          mail.Mailer
+	resetTokenTTL   time.Duration
+
+	// genericRegistration hides whether an email is already registered
+	genericRegistration bool
+
+	// authenticators verify login credentials in order, starting with local passwords
+	authenticators []Authenticator
+
+	// events receives logins, when set
+	events EventPublisher
+
+	// refreshTokens stores the refresh tokens issued at login, when enabled
+	refreshTokens   oauth.RefreshTokenRepository
+	refreshTokenTTL time.Duration
+
+	// sessions are ended when an account is disabled or signed out, when set
+	sessions session.Repository
+
+	// dummyHash is verified for unknown accounts so every login costs one password verification
+	dummyHash   string
+	dummyHashMu sync.Mutex
+}
+
+// UserUseCaseOption configures optional dependencies of the user use case
+type UserUseCaseOption func(*UserUseCase)
+
+// WithPasswordPolicy sets the policy enforced on new passwords
+func WithPasswordPolicy(policy auth.PasswordPolicy) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.passwordPolicy = policy
+	}
+}
+
+// WithPasswordReset enables the password reset flow.
+// Reset tokens are stored in repo and delivered with mailer.
+func WithPasswordReset(repo user.PasswordResetRepository, mailer mail.Mailer, ttl time.Duration) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.resetRepo = repo
+		uc.mailer = mailer
+		if ttl > 0 {
+			uc.resetTokenTTL = ttl
+		}
+	}
+}
+
+// WithGenericRegistration makes registration answer identically whether or not the email is taken.
+// Instead of an error, the existing account owner is notified by email.
+func WithGenericRegistration(mailer mail.Mailer) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.genericRegistration = true
+		uc.mailer = mailer
+	}
+}
+
+// WithAuthenticators adds authenticators, such as a directory, that are tried in order after
+// the local password check
+func WithAuthenticators(authenticators ...Authenticator) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.authenticators = append(uc.authenticators, authenticators...)
+	}
+}
+
+// WithEventPublisher publishes user.logged_in events, e.g. to webhooks. Registrations and other
+// changes to users reach subscribers through the outbox of the user repository instead.
+func WithEventPublisher(events EventPublisher) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.events = events
+	}
+}
+
+// WithRefreshTokens issues a rotating refresh token with every login, valid for ttl, so clients
+// can renew their token without asking for the password again
+func WithRefreshTokens(repo oauth.RefreshTokenRepository, ttl time.Duration) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.refreshTokens = repo
+		uc.refreshTokenTTL = ttl
+	}
+}
+
+// WithSessions ends the cookie sessions in repo when an account is disabled or signed out
+func WithSessions(repo session.Repository) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.sessions = repo
+	}
 }
 
 // NewUserUseCase creates a new user use case instance
@@ -35,36 +147,45 @@ func NewUserUseCase(
 	repo user.Repository,
 	passwordService auth.PasswordService,
 	jwtService auth.JWTService,
+	opts ...UserUseCaseOption,
 ) *UserUseCase {
-	return &UserUseCase{
+	uc := &UserUseCase{
 		userRepo:        repo,
 		passwordService: passwordService,
 		jwtService:      jwtService,
+		passwordPolicy:  auth.DefaultPasswordPolicy(),
+		resetTokenTTL:   defaultResetTokenTTL,
+	}
+	uc.authenticators = []Authenticator{AuthenticatorFunc(uc.verifyPassword)}
+
+	for _, opt := range opts {
+		opt(uc)
 	}
-}
 
-// Register registers a new user
-func (uc *UserUseCase) Register(firstName, lastName, email, password string) (*UserResponse, error) {
-	// Create a new user entity
-	newUser := user.NewUser(firstName, lastName, email, password)
+	return uc
+}
 
-	// Validate user data
-	if !newUser.Validate() {
-		return nil, errors.New("missing required fields")
+// Register registers a new user in a tenant
+func (uc *UserUseCase) Register(tenantID, firstName, lastName, email, password string) (*UserResponse, error) {
+	// Hash the password before the existence check so both outcomes take the same time
+	newUser, err := uc.newUser(tenantID, firstName, lastName, email, password)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if user already exists
-	existingUser, _ := uc.userRepo.FindByEmail(email)
+	existingUser, _ := uc.userRepo.FindByEmail(tenantID, newUser.Email)
 	if existingUser != nil {
-		return nil, errors.New("user with this email already exists")
-	}
+		if !uc.genericRegistration {
+			return nil, ErrUserExists
+		}
 
-	// Hash the password
-	hashedPassword, err := uc.passwordService.HashPassword(password)
-	if err != nil {
-		return nil, errors.New("failed to hash password")
+		// Answer as if the registration succeeded and let the owner know instead. Both answers
+		// already paid for the password hash, which dominates the response time.
+		uc.notifyExistingAccount(existingUser)
+		response := newUserResponse(newUser)
+		return &response, nil
 	}
-	newUser.Password = hashedPassword
 
 	// Save user to repository
 	if err := uc.userRepo.Save(newUser); err != nil {
@@ -72,49 +193,650 @@ func (uc *UserUseCase) Register(firstName, lastName, email, password string) (*U
 	}
 
 	// Create response
-	response := &UserResponse{
-		FirstName: newUser.FirstName,
-		LastName:  newUser.LastName,
-		Email:     newUser.Email,
-		CreatedAt: newUser.CreatedAt,
-		UpdatedAt: newUser.UpdatedAt,
+	response := newUserResponse(newUser)
+	return &response, nil
+}
+
+// newUser validates a registration and creates the user with a hashed password
+func (uc *UserUseCase) newUser(tenantID, firstName, lastName, email, password string) (*user.User, error) {
+	// Normalize and validate the request
+	input := RegisterInput{
+		FirstName: firstName,
+		LastName:  lastName,
+		Email:     email,
+		Password:  password,
+	}
+	input.Normalize()
+	errs := validation.Check(&input)
+	// The policy only scores passwords that passed the field rules, such as the length limit
+	if input.Password != "" && !errs.Has("password") {
+		policyErrs, err := uc.checkPassword("password", input.Password, input.FirstName, input.LastName, input.Email)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, policyErrs...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	hashedPassword, err := uc.passwordService.HashPassword(input.Password)
+	if err != nil {
+		return nil, hashingError(err)
+	}
+	return user.NewUser(tenantID, input.FirstName, input.LastName, input.Email, hashedPassword), nil
+}
+
+// Login authenticates a user of a tenant and returns a token
+func (uc *UserUseCase) Login(tenantID, email, password string) (*AuthResponse, error) {
+	// Normalize and validate the request
+	input := LoginInput{Email: email, Password: password}
+	input.Normalize()
+	if err := validation.Validate(&input); err != nil {
+		return nil, err
+	}
+
+	user, err := uc.verifyCredentials(tenantID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// A login starts a new family of refresh tokens
+	familyID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	return uc.issueTokens(user, familyID)
+}
+
+// Refresh exchanges a refresh token issued at login for a new token and refresh token.
+// Presenting a token that was already exchanged revokes every token descended from the same
+// login, since either the client or an attacker holds a stolen copy.
+func (uc *UserUseCase) Refresh(tenantID, refreshToken string) (*AuthResponse, error) {
+	if uc.refreshTokens == nil || refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	// Tokens issued to OAuth clients are refreshed at the token endpoint
+	stored, err := uc.refreshTokens.FindByHash(hashOAuthToken(refreshToken))
+	if err != nil || stored.ClientID != "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+	if stored.RotatedAt != nil {
+		if err := uc.refreshTokens.RevokeFamily(stored.FamilyID, now); err != nil {
+			log.Printf("Error revoking reused refresh token family: %v", err)
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if !stored.Active(now) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	u, err := uc.userRepo.FindByID(tenantID, stored.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if u.Disabled {
+		return nil, ErrAccountDisabled
 	}
 
+	stored.RotatedAt = &now
+	if err := uc.refreshTokens.Update(stored); err != nil {
+		return nil, err
+	}
+	return uc.issueTokens(u, stored.FamilyID)
+}
+
+// issueTokens issues a JWT for a user and, when enabled, a refresh token in the given family
+func (uc *UserUseCase) issueTokens(u *user.User, familyID string) (*AuthResponse, error) {
+	// Generate JWT token
+	token, err := uc.jwtService.GenerateToken(u)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	// Return auth response with token
+	response := &AuthResponse{
+		User:  newUserResponse(u),
+		Token: token,
+	}
+	if uc.refreshTokens == nil {
+		return response, nil
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := uc.refreshTokens.Save(&oauth.RefreshToken{
+		TokenHash: hashOAuthToken(refreshToken),
+		FamilyID:  familyID,
+		UserID:    u.ID,
+		ExpiresAt: now.Add(uc.refreshTokenTTL),
+		CreatedAt: now,
+	}); err != nil {
+		return nil, err
+	}
+	response.RefreshToken = refreshToken
 	return response, nil
 }
 
-// Login authenticates a user and returns a token
-func (uc *UserUseCase) Login(email, password string) (*AuthResponse, error) {
-	// Find user by email
-	user, err := uc.userRepo.FindByEmail(email)
+// GetUser returns a user of the principal's tenant. Users may read their own account and
+// admins any account, unless they act through a credential without the users:read scope.
+func (uc *UserUseCase) GetUser(p *auth.Principal, id string) (*UserResponse, error) {
+	self := p.Type == auth.PrincipalUser && p.Subject == id
+	if !self && !(p.HasRole(user.RoleAdmin) && p.HasScope(ScopeUsersRead)) {
+		return nil, ErrForbidden
+	}
+
+	u, err := uc.userRepo.FindByID(p.TenantID, id)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrUserNotFound
+	}
+	response := newUserResponse(u)
+	return &response, nil
+}
+
+// VerifyCredentials checks an email and password within a tenant and returns the user they
+// belong to. It is used by login flows that do not issue a JWT, such as cookie sessions.
+func (uc *UserUseCase) VerifyCredentials(tenantID, email, password string) (*user.User, error) {
+	input := LoginInput{Email: email, Password: password}
+	input.Normalize()
+	if err := validation.Validate(&input); err != nil {
+		return nil, err
+	}
+
+	return uc.verifyCredentials(tenantID, input)
+}
+
+// verifyCredentials checks a validated login input against each authenticator in turn. The first
+// one to accept the credentials decides the user.
+func (uc *UserUseCase) verifyCredentials(tenantID string, input LoginInput) (*user.User, error) {
+	var unavailable error
+	for _, authenticator := range uc.authenticators {
+		u, err := authenticator.Authenticate(tenantID, input.Email, input.Password)
+		switch {
+		case err == nil:
+			// Only reveal that an account is disabled to someone who knows its password
+			if u.Disabled {
+				return nil, ErrAccountDisabled
+			}
+			uc.publish(webhook.EventUserLoggedIn, u)
+			return u, nil
+		case errors.Is(err, ErrInvalidCredentials):
+			continue
+		case errors.Is(err, ErrServiceBusy):
+			return nil, err
+		default:
+			// A backend that cannot be reached must not look like a wrong password
+			log.Printf("Authenticator failed: %v", err)
+			unavailable = err
+		}
+	}
+
+	if unavailable != nil {
+		return nil, ErrServiceBusy
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// verifyPassword checks a password against the local account. Unknown accounts, and accounts
+// without a local password, still pay for a full verification so the response time does not
+// reveal which emails are registered.
+func (uc *UserUseCase) verifyPassword(tenantID, email, password string) (*user.User, error) {
+	u, err := uc.userRepo.FindByEmail(tenantID, email)
+	if err != nil || u.Password == "" {
+		dummyHash, err := uc.getDummyHash()
+		if err == nil {
+			_, err = uc.passwordService.VerifyPassword(dummyHash, password)
+		}
+		if errors.Is(err, auth.ErrHashingBusy) {
+			return nil, ErrServiceBusy
+		}
+		return nil, ErrInvalidCredentials
 	}
 
 	// Verify password
-	valid, err := uc.passwordService.VerifyPassword(user.Password, password)
+	valid, err := uc.passwordService.VerifyPassword(u.Password, password)
+	if errors.Is(err, auth.ErrHashingBusy) {
+		return nil, ErrServiceBusy
+	}
 	if err != nil || !valid {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
 	}
 
-	// Generate JWT token
-	token, err := uc.jwtService.GenerateToken(user)
+	// Upgrade hashes produced with outdated parameters while the plaintext is available
+	uc.rehashIfNeeded(u, password)
+
+	return u, nil
+}
+
+// ChangePassword replaces the password of an authenticated user after verifying the current one
+func (uc *UserUseCase) ChangePassword(tenantID, email, currentPassword, newPassword string) error {
+	input := ChangePasswordInput{CurrentPassword: currentPassword, NewPassword: newPassword}
+	if err := validation.Validate(&input); err != nil {
+		return err
+	}
+
+	u, err := uc.userRepo.FindByEmail(tenantID, validation.NormalizeEmail(email))
+	if err != nil {
+		return ErrInvalidCredentials
+	}
+
+	valid, err := uc.passwordService.VerifyPassword(u.Password, input.CurrentPassword)
+	if errors.Is(err, auth.ErrHashingBusy) {
+		return ErrServiceBusy
+	}
+	if err != nil || !valid {
+		return ErrInvalidCredentials
+	}
+
+	return uc.setPassword(u, "new_password", input.NewPassword)
+}
+
+// RequestPasswordReset emails a single-use reset token to the user.
+// Unknown emails are ignored so the response does not reveal which accounts exist.
+func (uc *UserUseCase) RequestPasswordReset(tenantID, email string) error {
+	if uc.resetRepo == nil || uc.mailer == nil {
+		return ErrPasswordResetDisabled
+	}
+
+	u, err := uc.userRepo.FindByEmail(tenantID, validation.NormalizeEmail(email))
+	if err != nil {
+		return nil
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	err = uc.resetRepo.Save(&user.PasswordResetToken{
+		TokenHash: hashResetToken(token),
+		TenantID:  u.TenantID,
+		Email:     u.Email,
+		ExpiresAt: time.Now().Add(uc.resetTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf("Use this token to reset your password: %s\nIt expires in %s.", token, uc.resetTokenTTL),
+	}
+
+	// Send in the background so known and unknown emails answer in the same time
+	go func() {
+		if err := uc.mailer.Send(msg); err != nil {
+			log.Printf("Error sending password reset to %s: %v", u.Email, err)
+		}
+	}()
+
+	return nil
+}
+
+// ResetPassword sets a new password using a token issued by RequestPasswordReset. The token
+// only works in the tenant it was issued for.
+func (uc *UserUseCase) ResetPassword(tenantID, token, newPassword string) error {
+	if uc.resetRepo == nil {
+		return ErrPasswordResetDisabled
+	}
+
+	input := ResetPasswordInput{Token: token, NewPassword: newPassword}
+	if err := validation.Validate(&input); err != nil {
+		return err
+	}
+
+	tokenHash := hashResetToken(input.Token)
+	resetToken, err := uc.resetRepo.FindByHash(tokenHash)
+	if err != nil || resetToken.Expired() || resetToken.TenantID != tenantID {
+		return ErrInvalidResetToken
+	}
+
+	u, err := uc.userRepo.FindByEmail(tenantID, resetToken.Email)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	if err := uc.setPassword(u, "new_password", input.NewPassword); err != nil {
+		return err
+	}
+
+	// Tokens are single use
+	return uc.resetRepo.Delete(tokenHash)
+}
+
+// CreateAdmin creates an administrator of a tenant, with the validation and password policy of
+// registration. It is meant for operators setting up or recovering a tenant.
+func (uc *UserUseCase) CreateAdmin(tenantID, firstName, lastName, email, password string) (*UserResponse, error) {
+	u, err := uc.newUser(tenantID, firstName, lastName, email, password)
+	if err != nil {
+		return nil, err
+	}
+	if existing, _ := uc.userRepo.FindByEmail(tenantID, u.Email); existing != nil {
+		return nil, ErrUserExists
+	}
+
+	u.Roles = []string{user.RoleAdmin}
+	if err := uc.userRepo.Save(u); err != nil {
+		return nil, err
+	}
+	response := newUserResponse(u)
+	return &response, nil
+}
+
+// SetPassword replaces the password of a user without the current one, for operators resetting
+// an account. The password policy applies and the user's refresh tokens are revoked.
+func (uc *UserUseCase) SetPassword(tenantID, email, password string) error {
+	u, err := uc.findByEmail(tenantID, email)
+	if err != nil {
+		return err
+	}
+	return uc.setPassword(u, "password", password)
+}
+
+// SetDisabled disables or re-enables an account. Disabling also signs the user out of every
+// session and revokes their refresh tokens.
+func (uc *UserUseCase) SetDisabled(tenantID, email string, disabled bool) (*UserResponse, error) {
+	u, err := uc.findByEmail(tenantID, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Disabled != disabled {
+		u.SetDisabled(disabled)
+		u.UpdatedAt = time.Now()
+		if err := uc.userRepo.Update(u); err != nil {
+			return nil, err
+		}
+	}
+	if disabled {
+		if _, err := uc.signOut(u); err != nil {
+			return nil, err
+		}
+	}
+	response := newUserResponse(u)
+	return &response, nil
+}
+
+// IsAccountActive reports whether a user still exists and is enabled, so tokens issued before
+// the account was disabled or deleted stop working
+func (uc *UserUseCase) IsAccountActive(tenantID, userID string) bool {
+	u, err := uc.userRepo.FindByID(tenantID, userID)
+	return err == nil && !u.Disabled
+}
+
+// RevokeSessions signs a user out of every session and revokes their refresh tokens, returning
+// the number of sessions ended. Access tokens already issued stay valid until they expire.
+func (uc *UserUseCase) RevokeSessions(tenantID, email string) (int, error) {
+	u, err := uc.findByEmail(tenantID, email)
+	if err != nil {
+		return 0, err
+	}
+	return uc.signOut(u)
+}
+
+// DebugToken is an access token issued to an operator on behalf of a user
+type DebugToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// MaxDebugTokenTTL bounds how long debug tokens stay valid
+const MaxDebugTokenTTL = time.Hour
+
+// IssueDebugToken signs an access token for a user without their password, so operators can
+// reproduce what the user sees. The token carries the claims of a login and is valid for ttl,
+// at most MaxDebugTokenTTL.
+func (uc *UserUseCase) IssueDebugToken(tenantID, email string, ttl time.Duration) (*DebugToken, error) {
+	if ttl <= 0 || ttl > MaxDebugTokenTTL {
+		return nil, validation.Errors{{Field: "ttl", Message: fmt.Sprintf("ttl must be positive and at most %s", MaxDebugTokenTTL)}}
+	}
+	u, err := uc.findByEmail(tenantID, email)
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	token, err := uc.jwtService.GenerateClaimsToken(newUserClaims(u), ttl)
 	if err != nil {
 		return nil, errors.New("failed to generate token")
 	}
+	return &DebugToken{Token: token, ExpiresAt: expiresAt}, nil
+}
 
-	// Create user response
-	userResp := UserResponse{
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+// findByEmail finds a user of a tenant by a normalized email
+func (uc *UserUseCase) findByEmail(tenantID, email string) (*user.User, error) {
+	u, err := uc.userRepo.FindByEmail(tenantID, validation.NormalizeEmail(email))
+	if err != nil {
+		return nil, ErrUserNotFound
 	}
+	return u, nil
+}
 
-	// Return auth response with token
-	return &AuthResponse{
-		User:  userResp,
-		Token: token,
-	}, nil
+// signOut ends the sessions of a user and revokes the refresh tokens issued at login,
+// returning the number of sessions ended
+func (uc *UserUseCase) signOut(u *user.User) (int, error) {
+	if uc.refreshTokens != nil {
+		if err := uc.refreshTokens.RevokeGrant(u.ID, "", time.Now()); err != nil {
+			return 0, err
+		}
+	}
+	if uc.sessions == nil {
+		return 0, nil
+	}
+
+	sessions, err := uc.sessions.FindByUser(u.ID)
+	if err != nil {
+		return 0, err
+	}
+	if err := uc.sessions.DeleteByUser(u.ID); err != nil {
+		return 0, err
+	}
+	return len(sessions), nil
+}
+
+// PasswordHashStats counts the users whose password hash uses one parameter set
+type PasswordHashStats struct {
+	Params  string `json:"params"`
+	Users   int    `json:"users"`
+	Current bool   `json:"current"`
+}
+
+// PasswordHashReport groups the users of a tenant by the algorithm and parameters of their
+// password hash. Groups that are not current will be upgraded on the users' next successful login.
+func (uc *UserUseCase) PasswordHashReport(tenantID string) ([]PasswordHashStats, error) {
+	users, err := uc.userRepo.FindAll(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*PasswordHashStats)
+	for _, u := range users {
+		label := auth.DescribeHash(u.Password)
+		group, ok := groups[label]
+		if !ok {
+			group = &PasswordHashStats{
+				Params:  label,
+				Current: !uc.passwordService.NeedsRehash(u.Password),
+			}
+			groups[label] = group
+		}
+		group.Users++
+	}
+
+	report := make([]PasswordHashStats, 0, len(groups))
+	for _, group := range groups {
+		report = append(report, *group)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Users > report[j].Users })
+
+	return report, nil
+}
+
+// rehashIfNeeded replaces an outdated password hash after a successful verification.
+// Failures are logged and never fail the login.
+func (uc *UserUseCase) rehashIfNeeded(u *user.User, password string) {
+	if !uc.passwordService.NeedsRehash(u.Password) {
+		return
+	}
+
+	hashedPassword, err := uc.passwordService.HashPassword(password)
+	if err != nil {
+		log.Printf("Error rehashing password for %s: %v", u.Email, err)
+		return
+	}
+
+	u.Password = hashedPassword
+	if err := uc.userRepo.Update(u); err != nil {
+		log.Printf("Error saving rehashed password for %s: %v", u.Email, err)
+	}
+}
+
+// notifyExistingAccount emails the owner of an account that someone tried to register again.
+// The email is sent in the background so it does not affect the response time.
+func (uc *UserUseCase) notifyExistingAccount(u *user.User) {
+	if uc.mailer == nil {
+		return
+	}
+
+	msg := mail.Message{
+		To:      u.Email,
+		Subject: "Someone tried to register with your email",
+		Body:    "An attempt was made to create an account with your email address. If this was you, log in or reset your password instead. Otherwise you can ignore this email.",
+	}
+
+	go func() {
+		if err := uc.mailer.Send(msg); err != nil {
+			log.Printf("Error sending existing account notice to %s: %v", u.Email, err)
+		}
+	}()
+}
+
+// getDummyHash returns a hash of a random password produced with the current parameters.
+// Failures are not cached, so a busy hasher at startup does not disable the protection.
+func (uc *UserUseCase) getDummyHash() (string, error) {
+	uc.dummyHashMu.Lock()
+	defer uc.dummyHashMu.Unlock()
+
+	if uc.dummyHash != "" {
+		return uc.dummyHash, nil
+	}
+
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return "", err
+	}
+
+	hash, err := uc.passwordService.HashPassword(base64.RawStdEncoding.EncodeToString(password))
+	if err != nil {
+		return "", err
+	}
+
+	uc.dummyHash = hash
+	return hash, nil
+}
+
+// setPassword checks a new password against the policy, hashes it and persists the user
+func (uc *UserUseCase) setPassword(u *user.User, field, password string) error {
+	errs, err := uc.checkPassword(field, password, u.FirstName, u.LastName, u.Email)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	hashedPassword, err := uc.passwordService.HashPassword(password)
+	if err != nil {
+		return hashingError(err)
+	}
+
+	u.Password = hashedPassword
+	u.UpdatedAt = time.Now()
+	if err := uc.userRepo.Update(u); err != nil {
+		return err
+	}
+
+	// Whoever held the old password may also hold a refresh token
+	if uc.refreshTokens != nil {
+		return uc.refreshTokens.RevokeGrant(u.ID, "", time.Now())
+	}
+	return nil
+}
+
+// checkPassword applies the password policy and returns its violations as field errors
+func (uc *UserUseCase) checkPassword(field, password string, personalInfo ...string) (validation.Errors, error) {
+	violations, err := uc.passwordPolicy.Check(password, personalInfo...)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs validation.Errors
+	for _, v := range violations {
+		errs = append(errs, validation.FieldError{Field: field, Message: field + " " + v})
+	}
+	return errs, nil
+}
+
+// publish sends an event about a user to the event publisher, if any
+func (uc *UserUseCase) publish(eventType string, u *user.User) {
+	if uc.events != nil {
+		uc.events.Publish(webhook.NewEvent(u.TenantID, eventType, userEventData(u)))
+	}
+}
+
+// hashingError maps password hashing failures to use case errors
+func hashingError(err error) error {
+	if errors.Is(err, auth.ErrHashingBusy) {
+		return ErrServiceBusy
+	}
+	return errors.New("failed to hash password")
+}
+
+// hashResetToken returns the stored representation of a reset token
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newUserResponse converts a user entity to its response representation
+func newUserResponse(u *user.User) UserResponse {
+	return UserResponse{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email,
+		Roles:     u.Roles,
+		Disabled:  u.Disabled,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+// newUserPrincipal creates an unrestricted principal for a stored user, with the same
+// claims a JWT issued at login would carry
+func newUserPrincipal(u *user.User) *auth.Principal {
+	return auth.NewUserPrincipal(newUserClaims(u))
+}
+
+// newUserClaims returns the claims of a JWT issued to a user at login, without times
+func newUserClaims(u *user.User) *auth.Claims {
+	claims := &auth.Claims{
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Roles:     u.Roles,
+		TenantID:  u.TenantID,
+	}
+	claims.Subject = u.ID
+	return claims
 }
@@ -0,0 +1,522 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/event"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/webhook"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// ScopeWebhooks allows an admin to manage the webhook endpoints and deliveries of a tenant
+const ScopeWebhooks = "webhooks"
+
+// webhookSecretPrefix marks endpoint secrets so they are recognizable in configuration
+const webhookSecretPrefix = "whsec_"
+
+// dueBatchSize bounds how many deliveries one DeliverDue call attempts
+const dueBatchSize = 100
+
+// EventPublisher receives user lifecycle events, such as the webhook use case
+type EventPublisher interface {
+	Publish(event webhook.Event)
+}
+
+// WebhookConfig holds the delivery settings
+type WebhookConfig struct {
+	// MaxAttempts is how often a delivery is tried before it is dead-lettered
+	MaxAttempts int
+	// InitialBackoff is the wait after the first failed attempt; it doubles after each failure
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// AllowHTTP accepts plain http endpoint URLs, for local development
+	AllowHTTP bool
+	// AllowPrivateNetworks accepts endpoints on loopback, private and link-local addresses.
+	// The sender must be configured to match, since it checks the resolved address again.
+	AllowPrivateNetworks bool
+	// Workers is how many endpoints DeliverDue sends to at the same time
+	Workers int
+	// MaxPerEndpoint bounds the deliveries to one endpoint per DeliverDue call, so a backlog
+	// or a slow endpoint cannot hold up the others
+	MaxPerEndpoint int
+}
+
+// DefaultWebhookConfig returns the default delivery settings: 8 attempts over about 2 hours
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		MaxAttempts:    8,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     time.Hour,
+		Workers:        4,
+		MaxPerEndpoint: 10,
+	}
+}
+
+// WebhookEndpointInput holds the fields of an endpoint request. Empty events subscribes to
+// every event type.
+type WebhookEndpointInput struct {
+	URL         string   `json:"url" validate:"required,max=2048"`
+	Events      []string `json:"events"`
+	Description string   `json:"description" validate:"max=200"`
+}
+
+// WebhookPayload is the JSON body delivered for an event
+type WebhookPayload struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	TenantID   string            `json:"tenant_id"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Data       map[string]string `json:"data"`
+}
+
+// WebhookUseCase delivers user lifecycle events to the endpoints registered by each tenant
+type WebhookUseCase struct {
+	endpoints  webhook.EndpointRepository
+	deliveries webhook.DeliveryRepository
+	sender     webhook.Sender
+	config     WebhookConfig
+}
+
+// NewWebhookUseCase creates a new webhook use case instance
+func NewWebhookUseCase(endpoints webhook.EndpointRepository, deliveries webhook.DeliveryRepository, sender webhook.Sender, config WebhookConfig) *WebhookUseCase {
+	return &WebhookUseCase{
+		endpoints:  endpoints,
+		deliveries: deliveries,
+		sender:     sender,
+		config:     config,
+	}
+}
+
+// Publish queues a delivery of an event to every endpoint of its tenant that subscribes to it.
+// Deliveries are sent by DeliverDue, so publishing never waits on an endpoint.
+func (uc *WebhookUseCase) Publish(event webhook.Event) {
+	if err := uc.queue(event); err != nil {
+		log.Printf("Error queueing %s event for webhooks: %v", event.Type, err)
+	}
+}
+
+// SubscribeUserEvents delivers the domain events of users from an event bus to webhooks.
+// Each event is queued once, however often the bus dispatches it. Disabled users are
+// delivered as user.deleted, as accounts are deprovisioned rather than deleted.
+func (uc *WebhookUseCase) SubscribeUserEvents(bus *event.Bus, processed event.ProcessedStore) {
+	types := map[string]string{
+		user.EventRegistered:   webhook.EventUserRegistered,
+		user.EventEmailChanged: webhook.EventUserEmailChanged,
+		user.EventDisabled:     webhook.EventUserDeleted,
+	}
+	for domainType, webhookType := range types {
+		bus.Subscribe(domainType, "webhooks", event.Idempotent(processed, "webhooks", func(e event.Event) error {
+			data := map[string]string{"user_id": e.AggregateID}
+			for k, v := range e.Data {
+				data[k] = v
+			}
+			return uc.queue(webhook.Event{
+				ID:         e.ID,
+				TenantID:   e.TenantID,
+				Type:       webhookType,
+				OccurredAt: e.OccurredAt,
+				Data:       data,
+			})
+		}))
+	}
+}
+
+// queue saves a pending delivery of an event for each subscribed endpoint of its tenant
+func (uc *WebhookUseCase) queue(event webhook.Event) error {
+	endpoints, err := uc.endpoints.FindByTenant(event.TenantID)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	for _, e := range endpoints {
+		if !e.Subscribes(event.Type) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(WebhookPayload{
+				ID:         event.ID,
+				Type:       event.Type,
+				TenantID:   event.TenantID,
+				OccurredAt: event.OccurredAt,
+				Data:       event.Data,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		d := &webhook.Delivery{
+			ID:            webhook.NewID(),
+			TenantID:      event.TenantID,
+			EndpointID:    e.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        webhook.StatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := uc.deliveries.Save(d); err != nil {
+			return fmt.Errorf("endpoint %s: %w", e.ID, err)
+		}
+	}
+	return nil
+}
+
+// DeliverDue attempts the pending deliveries that are due. Endpoints are sent to by a pool of
+// workers, each sending the deliveries of one endpoint in order. Failed deliveries are retried
+// with exponential backoff and dead-lettered after the last attempt. It returns the number of
+// deliveries attempted.
+func (uc *WebhookUseCase) DeliverDue() (int, error) {
+	due, err := uc.deliveries.FindDue(time.Now(), dueBatchSize, max(uc.config.MaxPerEndpoint, 1))
+	if err != nil {
+		return 0, err
+	}
+
+	var endpointIDs []string
+	byEndpoint := make(map[string][]*webhook.Delivery)
+	for _, d := range due {
+		if _, ok := byEndpoint[d.EndpointID]; !ok {
+			endpointIDs = append(endpointIDs, d.EndpointID)
+		}
+		byEndpoint[d.EndpointID] = append(byEndpoint[d.EndpointID], d)
+	}
+
+	queue := make(chan []*webhook.Delivery, len(endpointIDs))
+	for _, id := range endpointIDs {
+		queue <- byEndpoint[id]
+	}
+	close(queue)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		attempts int
+		firstErr error
+	)
+	for i := 0; i < min(max(uc.config.Workers, 1), len(endpointIDs)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for deliveries := range queue {
+				for _, d := range deliveries {
+					err := uc.attempt(d)
+					mu.Lock()
+					if err == nil {
+						attempts++
+					} else if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					if err != nil {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return attempts, firstErr
+}
+
+// attempt sends a delivery once and records the outcome
+func (uc *WebhookUseCase) attempt(d *webhook.Delivery) error {
+	start := time.Now()
+	attempt := webhook.Attempt{At: start}
+
+	e, err := uc.endpoints.FindByID(d.TenantID, d.EndpointID)
+	switch {
+	case err == nil:
+		attempt.StatusCode, err = uc.sender.Send(webhook.Request{
+			URL: e.URL,
+			Headers: map[string]string{
+				"Content-Type":          "application/json",
+				webhook.SignatureHeader: webhook.Sign(e.Secret, start, d.Payload),
+				webhook.EventHeader:     d.EventType,
+				webhook.DeliveryHeader:  d.ID,
+			},
+			Body: d.Payload,
+		})
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+	case errors.Is(err, webhook.ErrEndpointNotFound):
+		// The endpoint was deleted while the delivery waited; no retry can succeed
+		attempt.Error = "endpoint was deleted"
+		d.Attempts = append(d.Attempts, attempt)
+		d.Status = webhook.StatusDead
+		d.UpdatedAt = time.Now()
+		return uc.deliveries.Update(d)
+	default:
+		return err
+	}
+	attempt.Duration = time.Since(start)
+	d.Attempts = append(d.Attempts, attempt)
+
+	switch {
+	case attempt.Error == "" && attempt.StatusCode >= 200 && attempt.StatusCode < 300:
+		d.Status = webhook.StatusSucceeded
+	case len(d.Attempts) >= uc.config.MaxAttempts:
+		d.Status = webhook.StatusDead
+		log.Printf("Webhook delivery %s to endpoint %s dead-lettered after %d attempts", d.ID, d.EndpointID, len(d.Attempts))
+	default:
+		d.NextAttemptAt = time.Now().Add(uc.backoff(len(d.Attempts)))
+	}
+	d.UpdatedAt = time.Now()
+	return uc.deliveries.Update(d)
+}
+
+// backoff returns the wait after a number of failed attempts
+func (uc *WebhookUseCase) backoff(failures int) time.Duration {
+	wait := uc.config.InitialBackoff
+	for i := 1; i < failures && wait < uc.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > uc.config.MaxBackoff {
+		wait = uc.config.MaxBackoff
+	}
+	return wait
+}
+
+// CreateEndpoint registers an endpoint with a new signing secret, which is only returned here
+// and by RotateSecret
+func (uc *WebhookUseCase) CreateEndpoint(p *auth.Principal, input WebhookEndpointInput) (*webhook.Endpoint, error) {
+	if !canManageWebhooks(p) {
+		return nil, ErrForbidden
+	}
+	if err := uc.validateEndpoint(&input); err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	e := &webhook.Endpoint{
+		ID:          webhook.NewID(),
+		TenantID:    p.TenantID,
+		URL:         input.URL,
+		Secret:      secret,
+		Events:      input.Events,
+		Description: input.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := uc.endpoints.Save(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ListEndpoints returns the endpoints of the caller's tenant
+func (uc *WebhookUseCase) ListEndpoints(p *auth.Principal) ([]*webhook.Endpoint, error) {
+	if !canManageWebhooks(p) {
+		return nil, ErrForbidden
+	}
+	return uc.endpoints.FindByTenant(p.TenantID)
+}
+
+// GetEndpoint returns an endpoint of the caller's tenant
+func (uc *WebhookUseCase) GetEndpoint(p *auth.Principal, id string) (*webhook.Endpoint, error) {
+	if !canManageWebhooks(p) {
+		return nil, ErrForbidden
+	}
+	return uc.endpoints.FindByID(p.TenantID, id)
+}
+
+// UpdateEndpoint changes the URL, events and description of an endpoint
+func (uc *WebhookUseCase) UpdateEndpoint(p *auth.Principal, id string, input WebhookEndpointInput) (*webhook.Endpoint, error) {
+	if !canManageWebhooks(p) {
+		return nil, ErrForbidden
+	}
+	if err := uc.validateEndpoint(&input); err != nil {
+		return nil, err
+	}
+	e, err := uc.endpoints.FindByID(p.TenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	e.URL = input.URL
+	e.Events = input.Events
+	e.Description = input.Description
+	e.UpdatedAt = time.Now()
+	if err := uc.endpoints.Update(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// RotateSecret replaces the signing secret of an endpoint. Deliveries are signed with the new
+// secret from the next attempt on.
+func (uc *WebhookUseCase) RotateSecret(p *auth.Principal, id string) (*webhook.Endpoint, error) {
+	if !canManageWebhooks(p) {
+		return nil, ErrForbidden
+	}
+	e, err := uc.endpoints.FindByID(p.TenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Secret, err = newWebhookSecret(); err != nil {
+		return nil, err
+	}
+	e.UpdatedAt = time.Now()
+	if err := uc.endpoints.Update(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// DeleteEndpoint removes an endpoint. Its pending deliveries are dead-lettered when they come due.
+func (uc *WebhookUseCase) DeleteEndpoint(p *auth.Principal, id string) error {
+	if !canManageWebhooks(p) {
+		return ErrForbidden
+	}
+	return uc.endpoints.Delete(p.TenantID, id)
+}
+
+// ListDeliveries returns the delivery log of the caller's tenant, newest first. Filtering by
+// webhook.StatusDead lists the dead-letter queue.
+func (uc *WebhookUseCase) ListDeliveries(p *auth.Principal, filter webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	if !canManageWebhooks(p) {
+		return nil, ErrForbidden
+	}
+	switch filter.Status {
+	case "", webhook.StatusPending, webhook.StatusSucceeded, webhook.StatusDead:
+	default:
+		return nil, validation.Errors{{Field: "status", Message: "status must be pending, succeeded or dead"}}
+	}
+	return uc.deliveries.Find(p.TenantID, filter)
+}
+
+// GetDelivery returns a delivery of the caller's tenant with its attempts
+func (uc *WebhookUseCase) GetDelivery(p *auth.Principal, id string) (*webhook.Delivery, error) {
+	if !canManageWebhooks(p) {
+		return nil, ErrForbidden
+	}
+	return uc.deliveries.FindByID(p.TenantID, id)
+}
+
+// Redeliver queues a new delivery of the same payload to the same endpoint, with a fresh set
+// of attempts. The original delivery and its log are kept.
+func (uc *WebhookUseCase) Redeliver(p *auth.Principal, id string) (*webhook.Delivery, error) {
+	if !canManageWebhooks(p) {
+		return nil, ErrForbidden
+	}
+	original, err := uc.deliveries.FindByID(p.TenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := uc.endpoints.FindByID(p.TenantID, original.EndpointID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	d := &webhook.Delivery{
+		ID:            webhook.NewID(),
+		TenantID:      original.TenantID,
+		EndpointID:    original.EndpointID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        webhook.StatusPending,
+		NextAttemptAt: now,
+		RedeliveryOf:  original.ID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := uc.deliveries.Save(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// validateEndpoint normalizes and validates an endpoint request
+func (uc *WebhookUseCase) validateEndpoint(input *WebhookEndpointInput) error {
+	input.URL = strings.TrimSpace(input.URL)
+	input.Description = strings.TrimSpace(input.Description)
+
+	errs := validation.Check(input)
+	if input.URL != "" && !uc.validEndpointURL(input.URL) {
+		errs = append(errs, validation.FieldError{Field: "url", Message: "url must be an absolute https URL"})
+	} else if input.URL != "" && !uc.config.AllowPrivateNetworks && privateEndpointURL(input.URL) {
+		errs = append(errs, validation.FieldError{Field: "url", Message: "url must not point to a private network"})
+	}
+	for _, eventType := range input.Events {
+		if !knownEventType(eventType) {
+			errs = append(errs, validation.FieldError{Field: "events", Message: fmt.Sprintf("event type %q is unknown", eventType)})
+		}
+	}
+	return errs.Err()
+}
+
+// validEndpointURL accepts absolute https URLs, and http URLs when configured
+func (uc *WebhookUseCase) validEndpointURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.User != nil {
+		return false
+	}
+	return u.Scheme == "https" || (u.Scheme == "http" && uc.config.AllowHTTP)
+}
+
+// privateEndpointURL reports whether a URL names localhost or a private address. Host names
+// are only resolved when a delivery is sent, where the sender checks them.
+func privateEndpointURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && !webhook.PublicAddress(ip)
+}
+
+func knownEventType(eventType string) bool {
+	for _, t := range webhook.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// newWebhookSecret returns a random endpoint signing secret
+func newWebhookSecret() (string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + secret, nil
+}
+
+// canManageWebhooks reports whether a principal may manage the webhooks of its tenant
+func canManageWebhooks(p *auth.Principal) bool {
+	return p.HasRole(user.RoleAdmin) && p.HasScope(ScopeWebhooks)
+}
+
+// userEventData returns the data of an event about a user
+func userEventData(u *user.User) map[string]string {
+	return map[string]string{
+		"user_id": u.ID,
+		"email":   u.Email,
+	}
+}
@@ -0,0 +1,149 @@
+// Package validation provides the declarative request validation shared by all transports
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lamboktulussimamora/gra/validator"
+)
+
+// FieldError describes a single invalid field in a request
+type FieldError = validator.ValidationError
+
+// Errors is the list of field errors produced by Validate.
+// It implements error so it can be returned from the use case layer unchanged.
+type Errors []FieldError
+
+// Error implements the error interface
+func (e Errors) Error() string {
+	if len(e) == 0 {
+		return "validation failed"
+	}
+	return "validation failed: " + e[0].Message
+}
+
+// Err returns the errors as an error, or nil when the list is empty
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Has reports whether a field has an error
+func (e Errors) Has(field string) bool {
+	for _, fe := range e {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule validates a single string value and returns an error message, or "" when the value is valid
+type Rule func(fieldName, value string) string
+
+// MaxPasswordLength is the most characters the password rule accepts
+const MaxPasswordLength = 128
+
+// rules holds the project specific rules that extend the gra validator tags
+var rules = map[string]Rule{
+	"name":     validateName,
+	"password": validatePassword,
+}
+
+// RegisterRule registers a custom rule usable from validate tags
+func RegisterRule(name string, rule Rule) {
+	rules[name] = rule
+}
+
+// Validate validates a struct using its validate tags.
+// It returns nil when the struct is valid and Errors otherwise.
+func Validate(obj interface{}) error {
+	return Check(obj).Err()
+}
+
+// Check validates a struct using its validate tags and returns every field error.
+// Built-in gra rules (required, email, min, max) run first, followed by the project rules.
+func Check(obj interface{}) Errors {
+	errs := Errors(validator.New().Validate(obj))
+	return append(errs, validateCustom(obj)...)
+}
+
+// validateCustom applies the project rules to the top-level string fields of a struct
+func validateCustom(obj interface{}) Errors {
+	val := reflect.ValueOf(obj)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	typ := val.Type()
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		fieldType := typ.Field(i)
+		if field.Kind() != reflect.String {
+			continue
+		}
+
+		fieldName := strings.Split(fieldType.Tag.Get("json"), ",")[0]
+		if fieldName == "" || fieldName == "-" {
+			continue
+		}
+
+		for _, ruleName := range strings.Split(fieldType.Tag.Get("validate"), ",") {
+			rule, ok := rules[ruleName]
+			if !ok {
+				continue
+			}
+			// Empty values are reported by the required rule
+			if field.String() == "" {
+				continue
+			}
+			if msg := rule(fieldName, field.String()); msg != "" {
+				errs = append(errs, FieldError{Field: fieldName, Message: msg})
+			}
+		}
+	}
+	return errs
+}
+
+// validateName accepts letters, spaces, hyphens, apostrophes and periods, starting with a letter
+func validateName(fieldName, value string) string {
+	for i, r := range value {
+		if i == 0 && !unicode.IsLetter(r) {
+			return fieldName + " must start with a letter"
+		}
+		if unicode.IsLetter(r) || unicode.IsMark(r) || r == ' ' || r == '-' || r == '\'' || r == '.' {
+			continue
+		}
+		return fieldName + " may only contain letters, spaces, hyphens, apostrophes and periods"
+	}
+	return ""
+}
+
+// validatePassword limits a password to MaxPasswordLength characters. Unlike the gra max rule,
+// which counts bytes, it counts runes, so passwords outside ASCII get the same allowance.
+func validatePassword(fieldName, value string) string {
+	if utf8.RuneCountInString(value) > MaxPasswordLength {
+		return fmt.Sprintf("%s must be at most %d characters", fieldName, MaxPasswordLength)
+	}
+	return ""
+}
+
+// NormalizeEmail trims surrounding whitespace and lowercases an email address
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizeName trims surrounding whitespace and collapses inner runs of spaces
+func NormalizeName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
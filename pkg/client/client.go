@@ -0,0 +1,404 @@
+// Package client is a typed Go client for the user API. It keeps the tokens of the logged in
+// user in a token store, renews the access token with the refresh token before it expires or
+// when the server rejects it, retries busy and rate limited requests with backoff, and maps
+// error responses to typed errors.
+//
+//	c := client.New("https://auth.example.com")
+//	if _, err := c.Login(ctx, email, password); errors.Is(err, client.ErrUnauthorized) {
+//		// wrong email or password
+//	}
+//	profile, err := c.Profile(ctx)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin renews access tokens this long before they expire
+const tokenRefreshMargin = 30 * time.Second
+
+// RetryPolicy controls how requests are retried. Rate limited and busy responses are retried
+// for every request; network errors and gateway errors only for GET requests, as other
+// requests may have reached the server.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first; 1 disables retries
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; it doubles with each retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay. A Retry-After longer than this is not waited for.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy of new clients
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// RegisterRequest is the new account to register
+type RegisterRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+}
+
+// User is an account as returned by registration and login
+type User struct {
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Profile is the profile of the logged in user
+type Profile struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// Client calls the user API on behalf of one user. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	apiPrefix  string
+	httpClient *http.Client
+	tenantID   string
+	store      TokenStore
+	retry      RetryPolicy
+
+	// refreshMu serializes refreshes, as a refresh token may only be used once
+	refreshMu sync.Mutex
+}
+
+// Option configures a client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with the given HTTP client instead of one with a 10s timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sends requests through the given round tripper, e.g. to add tracing or to
+// test against a fake server
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		httpClient := *c.httpClient
+		httpClient.Transport = transport
+		c.httpClient = &httpClient
+	}
+}
+
+// WithTokenStore keeps tokens in the given store instead of in memory
+func WithTokenStore(store TokenStore) Option {
+	return func(c *Client) {
+		c.store = store
+	}
+}
+
+// WithTenant selects the tenant with the X-Tenant-ID header
+func WithTenant(tenantID string) Option {
+	return func(c *Client) {
+		c.tenantID = tenantID
+	}
+}
+
+// WithAPIPrefix sets the prefix of protected endpoints, e.g. /api for the core API server
+func WithAPIPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.apiPrefix = strings.TrimRight(prefix, "/")
+	}
+}
+
+// WithRetry replaces the default retry policy
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		if policy.MaxAttempts < 1 {
+			policy.MaxAttempts = 1
+		}
+		c.retry = policy
+	}
+}
+
+// New creates a client for the API server at baseURL, e.g. https://auth.example.com
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		store:      NewMemoryTokenStore(),
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// authResponse is the data of login and refresh responses
+type authResponse struct {
+	User         User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Register creates an account. It does not log in.
+func (c *Client) Register(ctx context.Context, req RegisterRequest) (*User, error) {
+	var user User
+	if err := c.send(ctx, http.MethodPost, "/register", req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login logs in and stores the tokens of the user
+func (c *Client) Login(ctx context.Context, email, password string) (*User, error) {
+	var resp authResponse
+	body := map[string]string{"email": email, "password": password}
+	if err := c.send(ctx, http.MethodPost, "/login", body, &resp); err != nil {
+		return nil, err
+	}
+	if err := c.store.Save(newTokens(resp.Token, resp.RefreshToken)); err != nil {
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+// Refresh renews the access token with the stored refresh token and stores the new tokens.
+// Protected methods refresh on their own; call it to renew tokens ahead of time.
+func (c *Client) Refresh(ctx context.Context) (*Tokens, error) {
+	tokens, err := c.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if tokens.AccessToken == "" {
+		return nil, ErrNotLoggedIn
+	}
+	tokens, err = c.refresh(ctx, tokens.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	return &tokens, nil
+}
+
+// Profile returns the profile of the logged in user
+func (c *Client) Profile(ctx context.Context) (*Profile, error) {
+	var profile Profile
+	if err := c.sendAuthenticated(ctx, http.MethodGet, c.apiPrefix+"/profile", nil, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// Logout forgets the stored tokens. The access token stays valid until it expires.
+func (c *Client) Logout() error {
+	return c.store.Save(Tokens{})
+}
+
+// Tokens returns the stored tokens
+func (c *Client) Tokens() (Tokens, error) {
+	return c.store.Load()
+}
+
+// sendAuthenticated sends a request with the stored access token, refreshing it when it is
+// about to expire or the server rejects it
+func (c *Client) sendAuthenticated(ctx context.Context, method, path string, body, out interface{}) error {
+	tokens, err := c.store.Load()
+	if err != nil {
+		return err
+	}
+	if tokens.AccessToken == "" {
+		return ErrNotLoggedIn
+	}
+	if tokens.RefreshToken != "" && tokens.expiring(time.Now(), tokenRefreshMargin) {
+		if tokens, err = c.refresh(ctx, tokens.AccessToken); err != nil {
+			return err
+		}
+	}
+
+	err = c.do(ctx, method, path, body, tokens.AccessToken, out)
+	if errors.Is(err, ErrUnauthorized) && tokens.RefreshToken != "" {
+		// The token may have been revoked or signed with a rotated key; refresh it once
+		if tokens, err = c.refresh(ctx, tokens.AccessToken); err != nil {
+			return err
+		}
+		err = c.do(ctx, method, path, body, tokens.AccessToken, out)
+	}
+	return err
+}
+
+// refresh exchanges the refresh token for new tokens unless another request already replaced
+// the stale access token, as using a rotated refresh token again revokes all its successors
+func (c *Client) refresh(ctx context.Context, staleAccessToken string) (Tokens, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	tokens, err := c.store.Load()
+	if err != nil {
+		return Tokens{}, err
+	}
+	if tokens.AccessToken == "" {
+		return Tokens{}, ErrNotLoggedIn
+	}
+	if tokens.AccessToken != staleAccessToken {
+		return tokens, nil
+	}
+	if tokens.RefreshToken == "" {
+		return Tokens{}, fmt.Errorf("%w: no refresh token", ErrUnauthorized)
+	}
+
+	var resp authResponse
+	body := map[string]string{"refresh_token": tokens.RefreshToken}
+	if err := c.send(ctx, http.MethodPost, "/refresh", body, &resp); err != nil {
+		return Tokens{}, err
+	}
+	tokens = newTokens(resp.Token, resp.RefreshToken)
+	if err := c.store.Save(tokens); err != nil {
+		return Tokens{}, err
+	}
+	return tokens, nil
+}
+
+// send sends an unauthenticated request
+func (c *Client) send(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.do(ctx, method, path, body, "", out)
+}
+
+// do sends a request, retrying it as the retry policy allows, and decodes the data of the
+// response into out
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, accessToken string, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := c.attempt(ctx, method, path, payload, accessToken, out)
+		if err == nil || attempt >= c.retry.MaxAttempts || !retryable(method, err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		if retryAfter > 0 {
+			if retryAfter > c.retry.MaxBackoff {
+				return err
+			}
+			delay = retryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends a request once and returns the Retry-After delay of the response
+func (c *Client) attempt(ctx context.Context, method, path string, payload []byte, accessToken string, out interface{}) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	if c.tenantID != "" {
+		req.Header.Set("X-Tenant-ID", c.tenantID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Error  string          `json:"error"`
+		Errors []FieldError    `json:"errors"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&envelope)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := envelope.Error
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return retryAfterDelay(resp.Header.Get("Retry-After")), &Error{
+			StatusCode: resp.StatusCode,
+			Message:    message,
+			Fields:     envelope.Errors,
+		}
+	}
+	if decodeErr != nil {
+		return 0, fmt.Errorf("client: invalid response: %w", decodeErr)
+	}
+	if out != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return 0, fmt.Errorf("client: invalid response data: %w", err)
+		}
+	}
+	return 0, nil
+}
+
+// retryable reports whether a failed request may be sent again
+func retryable(method string, err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		// The request may have reached the server before the connection failed
+		return method == http.MethodGet
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method == http.MethodGet
+	}
+	return false
+}
+
+// backoff returns the delay before the retry after attempt, doubling per attempt with jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.retry.InitialBackoff << (attempt - 1)
+	if delay > c.retry.MaxBackoff || delay <= 0 {
+		delay = c.retry.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryAfterDelay parses a Retry-After header given in seconds or as a date
+func retryAfterDelay(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
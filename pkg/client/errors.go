@@ -0,0 +1,78 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Errors the client's methods return, matched with errors.Is. Responses of the server wrap
+// the sentinel of their status in an *Error.
+var (
+	// ErrNotLoggedIn means a method needs tokens but the token store holds none
+	ErrNotLoggedIn = errors.New("client: not logged in")
+	// ErrBadRequest means the server rejected the request, e.g. a duplicate email
+	ErrBadRequest = errors.New("client: bad request")
+	// ErrValidation means fields of the request are invalid; the *Error lists them
+	ErrValidation = errors.New("client: validation failed")
+	// ErrUnauthorized means the credentials or tokens were rejected
+	ErrUnauthorized = errors.New("client: unauthorized")
+	// ErrForbidden means the caller may not perform the request
+	ErrForbidden = errors.New("client: forbidden")
+	// ErrNotFound means the requested resource does not exist
+	ErrNotFound = errors.New("client: not found")
+	// ErrRateLimited means the caller exceeded a rate limit and retries ran out
+	ErrRateLimited = errors.New("client: rate limit exceeded")
+	// ErrUnavailable means the server was busy or unavailable and retries ran out
+	ErrUnavailable = errors.New("client: service unavailable")
+)
+
+// FieldError is the validation error of one request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error response of the server
+type Error struct {
+	StatusCode int
+	// Message is the error the server reported
+	Message string
+	// Fields lists invalid fields of a request that failed validation
+	Fields []FieldError
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if len(e.Fields) == 0 {
+		return fmt.Sprintf("client: server responded %d: %s", e.StatusCode, e.Message)
+	}
+	fields := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		fields[i] = f.Field + ": " + f.Message
+	}
+	return fmt.Sprintf("client: server responded %d: %s (%s)", e.StatusCode, e.Message, strings.Join(fields, "; "))
+}
+
+// Is matches the sentinel error of the response status
+func (e *Error) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		if len(e.Fields) > 0 {
+			return target == ErrValidation
+		}
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return target == ErrUnavailable
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tokens are the tokens of a logged in user
+type Tokens struct {
+	AccessToken string `json:"access_token"`
+	// RefreshToken renews the access token; it is empty when the server does not issue refresh
+	// tokens
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// ExpiresAt is when the access token expires, zero when it cannot be told from the token
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// expiring reports whether the access token expires within margin
+func (t Tokens) expiring(now time.Time, margin time.Duration) bool {
+	return !t.ExpiresAt.IsZero() && !now.Add(margin).Before(t.ExpiresAt)
+}
+
+// TokenStore keeps the tokens of the client between requests, e.g. in a file or keychain so
+// users stay logged in across runs. Load returns zero tokens when none are stored.
+type TokenStore interface {
+	Load() (Tokens, error)
+	Save(tokens Tokens) error
+}
+
+// MemoryTokenStore keeps tokens in memory; it is the default store
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens Tokens
+}
+
+// NewMemoryTokenStore creates an empty in-memory token store
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+// Load returns the stored tokens
+func (s *MemoryTokenStore) Load() (Tokens, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens, nil
+}
+
+// Save replaces the stored tokens
+func (s *MemoryTokenStore) Save(tokens Tokens) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = tokens
+	return nil
+}
+
+// newTokens reads the expiry of an access token from its exp claim. The client does not verify
+// the token; it only needs to know when to renew it.
+func newTokens(accessToken, refreshToken string) Tokens {
+	tokens := Tokens{AccessToken: accessToken, RefreshToken: refreshToken}
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return tokens
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return tokens
+	}
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) == nil && claims.ExpiresAt > 0 {
+		tokens.ExpiresAt = time.Unix(claims.ExpiresAt, 0)
+	}
+	return tokens
+}
@@ -0,0 +1,284 @@
+// Package introspect is a client for the token introspection endpoint, for services that
+// cannot verify tokens themselves. Callers authenticate with an API key or OAuth client
+// credentials holding the introspect scope.
+//
+//	client := introspect.New("https://auth.example.com", introspect.WithAPIKey(key))
+//	result, err := client.Introspect(ctx, token)
+//	if err == nil && result.Active {
+//		// result.Claims describes the caller of your service
+//	}
+package introspect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by Introspect
+var (
+	// ErrUnauthorized means the client's own credentials were rejected
+	ErrUnauthorized = errors.New("introspect: client credentials were rejected")
+	// ErrForbidden means the client may not introspect tokens, e.g. it lacks the introspect scope
+	ErrForbidden = errors.New("introspect: client may not introspect tokens")
+	// ErrRateLimited means the client exceeded its rate limit
+	ErrRateLimited = errors.New("introspect: rate limit exceeded")
+)
+
+// Scope is the scope clients need to introspect tokens
+const Scope = "introspect"
+
+// DefaultPath is where the API server serves introspection
+const DefaultPath = "/introspect"
+
+// tokenRefreshMargin renews client credentials tokens this long before they expire
+const tokenRefreshMargin = 30 * time.Second
+
+// Result is the verdict on a token. Claims are only set for active tokens.
+type Result struct {
+	Active bool    `json:"active"`
+	Claims *Claims `json:"claims,omitempty"`
+}
+
+// Claims are the claims of an active token
+type Claims struct {
+	Subject   string     `json:"subject"`
+	Email     string     `json:"email,omitempty"`
+	FirstName string     `json:"first_name,omitempty"`
+	LastName  string     `json:"last_name,omitempty"`
+	Roles     []string   `json:"roles,omitempty"`
+	TenantID  string     `json:"tenant_id"`
+	Scope     string     `json:"scope,omitempty"`
+	ClientID  string     `json:"client_id,omitempty"`
+	TokenID   string     `json:"token_id,omitempty"`
+	Issuer    string     `json:"issuer,omitempty"`
+	IssuedAt  *time.Time `json:"issued_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// HasRole reports whether the token carries a role
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Error is an unexpected response from the server
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("introspect: server responded %d: %s", e.StatusCode, e.Message)
+}
+
+// Client asks the API server whether tokens are valid. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	endpoint   string
+	tokenURL   string
+	httpClient *http.Client
+	tenantID   string
+
+	apiKey       string
+	clientID     string
+	clientSecret string
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// Option configures a client
+type Option func(*Client)
+
+// WithAPIKey authenticates with an API key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithClientCredentials authenticates with an access token obtained from the OAuth token
+// endpoint with the client credentials grant. Tokens are renewed before they expire.
+func WithClientCredentials(clientID, clientSecret string) Option {
+	return func(c *Client) {
+		c.clientID = clientID
+		c.clientSecret = clientSecret
+	}
+}
+
+// WithHTTPClient sends requests with the given HTTP client instead of one with a 10s timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTenant selects the tenant with the X-Tenant-ID header; tokens of other tenants are inactive
+func WithTenant(tenantID string) Option {
+	return func(c *Client) {
+		c.tenantID = tenantID
+	}
+}
+
+// WithPath sets the introspection path, e.g. /api/introspect for the core API server
+func WithPath(path string) Option {
+	return func(c *Client) {
+		c.endpoint = c.baseURL + path
+	}
+}
+
+// New creates a client for the API server at baseURL, e.g. https://auth.example.com
+func New(baseURL string, opts ...Option) *Client {
+	baseURL = strings.TrimRight(baseURL, "/")
+	c := &Client{
+		baseURL:    baseURL,
+		endpoint:   baseURL + DefaultPath,
+		tokenURL:   baseURL + "/oauth/token",
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Introspect asks whether a token is valid. Invalid, expired and revoked tokens are not an
+// error; they give an inactive result.
+func (c *Client) Introspect(ctx context.Context, token string) (*Result, error) {
+	result, err := c.introspect(ctx, token)
+	if errors.Is(err, ErrUnauthorized) && c.clientID != "" {
+		// The access token may have been revoked or the server restarted; get a new one once
+		c.mu.Lock()
+		c.accessToken = ""
+		c.mu.Unlock()
+		result, err = c.introspect(ctx, token)
+	}
+	return result, err
+}
+
+func (c *Client) introspect(ctx context.Context, token string) (*Result, error) {
+	body, err := json.Marshal(map[string]string{"token": token})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := c.authenticate(ctx, req); err != nil {
+		return nil, err
+	}
+
+	var envelope struct {
+		Data  Result `json:"data"`
+		Error string `json:"error"`
+	}
+	status, err := c.do(req, &envelope)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case http.StatusOK:
+		return &envelope.Data, nil
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusForbidden:
+		return nil, ErrForbidden
+	case http.StatusTooManyRequests:
+		return nil, ErrRateLimited
+	default:
+		return nil, &Error{StatusCode: status, Message: envelope.Error}
+	}
+}
+
+// authenticate adds the client's credentials to a request
+func (c *Client) authenticate(ctx context.Context, req *http.Request) error {
+	if c.tenantID != "" {
+		req.Header.Set("X-Tenant-ID", c.tenantID)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+		return nil
+	}
+	if c.clientID == "" {
+		return nil
+	}
+
+	token, err := c.clientCredentialsToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// clientCredentialsToken returns a cached access token, requesting a new one when it is about to expire
+func (c *Client) clientCredentialsToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" && time.Now().Before(c.expiresAt) {
+		return c.accessToken, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {Scope}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	if c.tenantID != "" {
+		req.Header.Set("X-Tenant-ID", c.tenantID)
+	}
+
+	var resp struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := c.do(req, &resp)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case status == http.StatusOK && resp.AccessToken != "":
+	case status == http.StatusBadRequest || status == http.StatusUnauthorized:
+		return "", fmt.Errorf("%w: %s %s", ErrUnauthorized, resp.Error, resp.ErrorDescription)
+	default:
+		return "", &Error{StatusCode: status, Message: resp.Error}
+	}
+
+	c.accessToken = resp.AccessToken
+	c.expiresAt = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - tokenRefreshMargin)
+	return c.accessToken, nil
+}
+
+// do sends a request and decodes its JSON response body into v, returning the status code
+func (c *Client) do(req *http.Request, v interface{}) (int, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("introspect: invalid response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
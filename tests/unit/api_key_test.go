@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/apikey"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+)
+
+// apiKeyServer wires the API key endpoints and a protected profile endpoint
+type apiKeyServer struct {
+	*testServer
+}
+
+func newAPIKeyServer(t *testing.T) *apiKeyServer {
+	s := &apiKeyServer{testServer: newTestServer(t)}
+	apiKeyHandler := handler.NewAPIKeyHandler(s.apiKeys)
+	s.mux.Handle("GET /profile", s.protect(handler.NewProtectedHandler().Profile))
+	s.mux.Handle("GET /api-keys", s.protect(apiKeyHandler.Keys))
+	s.mux.Handle("POST /api-keys", s.protect(apiKeyHandler.Keys))
+	s.mux.Handle("GET /api-keys/{id}", s.protect(apiKeyHandler.Key))
+	s.mux.Handle("DELETE /api-keys/{id}", s.protect(apiKeyHandler.Key))
+	return s
+}
+
+// createKey creates a key with a bearer credential and returns the response data
+func (s *apiKeyServer) createKey(t *testing.T, credential string, req handler.CreateAPIKeyRequest) (handler.CreatedAPIKeyDTO, int) {
+	t.Helper()
+	rec := s.do(http.MethodPost, "/api-keys", bearer(credential), req)
+	var resp struct {
+		Data handler.CreatedAPIKeyDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp.Data, rec.Code
+}
+
+// TestAPIKeyAuthentication verifies keys authenticate in every supported header form and can be revoked
+func TestAPIKeyAuthentication(t *testing.T) {
+	s := newAPIKeyServer(t)
+	token := s.login(t, "ann@example.com")
+
+	created, code := s.createKey(t, token, handler.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"profile:read"}})
+	assertStatus(t, code, http.StatusCreated, "create key: expected status %d, got %d")
+	if created.Key == "" || created.OwnerType != "user" {
+		t.Fatalf("Unexpected created key: %+v", created)
+	}
+
+	headers := map[string]http.Header{
+		"X-API-Key": {"X-Api-Key": {created.Key}},
+		"Bearer":    bearer(created.Key),
+		"ApiKey":    {"Authorization": {"ApiKey " + created.Key}},
+	}
+	for name, header := range headers {
+		rec := s.do(http.MethodGet, "/profile", header, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("profile with %s: expected status %d, got %d", name, http.StatusOK, rec.Code)
+		}
+	}
+
+	// The key's scopes do not include key management
+	rec := s.do(http.MethodGet, "/api-keys", bearer(created.Key), nil)
+	assertStatus(t, rec.Code, http.StatusForbidden, "list keys with restricted key: expected status %d, got %d")
+
+	key, err := s.apiKeys.Get(auth.NewUserPrincipal(mustClaims(t, token)), created.ID)
+	if err != nil || key.LastUsedAt == nil {
+		t.Fatalf("Expected last use to be recorded, got %+v, %v", key, err)
+	}
+
+	rec = s.do(http.MethodDelete, "/api-keys/"+created.ID, bearer(token), nil)
+	assertStatus(t, rec.Code, http.StatusOK, "revoke key: expected status %d, got %d")
+	rec = s.do(http.MethodGet, "/profile", bearer(created.Key), nil)
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "profile with revoked key: expected status %d, got %d")
+
+	rec = s.do(http.MethodGet, "/profile", bearer(created.Key+"x"), nil)
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "profile with tampered key: expected status %d, got %d")
+}
+
+// TestAPIKeyScopesAndOwnership verifies keys cannot escalate scopes or manage other owners' keys
+func TestAPIKeyScopesAndOwnership(t *testing.T) {
+	s := newAPIKeyServer(t)
+	annToken := s.login(t, "ann@example.com")
+	bobToken := s.login(t, "bob@example.com")
+
+	manager, code := s.createKey(t, annToken, handler.CreateAPIKeyRequest{Name: "manager", Scopes: []string{"api_keys"}})
+	assertStatus(t, code, http.StatusCreated, "create manager key: expected status %d, got %d")
+
+	_, code = s.createKey(t, manager.Key, handler.CreateAPIKeyRequest{Name: "wider", Scopes: []string{"api_keys", "billing"}})
+	assertStatus(t, code, http.StatusForbidden, "create key with more scopes than the caller: expected status %d, got %d")
+
+	_, code = s.createKey(t, manager.Key, handler.CreateAPIKeyRequest{Name: "narrow", Scopes: []string{"api_keys"}})
+	assertStatus(t, code, http.StatusCreated, "create key within the caller's scopes: expected status %d, got %d")
+
+	_, code = s.createKey(t, annToken, handler.CreateAPIKeyRequest{Name: "svc", Scopes: []string{"orders"}, ServiceName: "billing"})
+	assertStatus(t, code, http.StatusForbidden, "create service key without admin role: expected status %d, got %d")
+
+	past := time.Now().Add(-time.Hour)
+	_, code = s.createKey(t, annToken, handler.CreateAPIKeyRequest{Name: "old", Scopes: []string{"a"}, ExpiresAt: &past})
+	assertStatus(t, code, http.StatusBadRequest, "create key that already expired: expected status %d, got %d")
+
+	rec := s.do(http.MethodGet, "/api-keys/"+manager.ID, bearer(bobToken), nil)
+	assertStatus(t, rec.Code, http.StatusNotFound, "get another user's key: expected status %d, got %d")
+	rec = s.do(http.MethodDelete, "/api-keys/"+manager.ID, bearer(bobToken), nil)
+	assertStatus(t, rec.Code, http.StatusNotFound, "revoke another user's key: expected status %d, got %d")
+}
+
+// TestServiceAPIKeys verifies admins can issue keys for services that act as service principals
+func TestServiceAPIKeys(t *testing.T) {
+	s := newAPIKeyServer(t)
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+
+	created, code := s.createKey(t, adminToken, handler.CreateAPIKeyRequest{Name: "billing", Scopes: []string{"orders:read"}, ServiceName: "billing"})
+	assertStatus(t, code, http.StatusCreated, "create service key: expected status %d, got %d")
+	if created.OwnerType != "service" || created.OwnerID != "billing" {
+		t.Fatalf("Unexpected service key: %+v", created)
+	}
+
+	principal, err := s.apiKeys.Authenticate(tenant.DefaultID, created.Key)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if principal.Type != auth.PrincipalService || principal.Subject != "billing" || principal.Claims != nil {
+		t.Errorf("Unexpected service principal: %+v", principal)
+	}
+	if !principal.HasScope("orders:read") || principal.HasScope("orders:write") {
+		t.Errorf("Service principal scopes not enforced: %v", principal.Scopes)
+	}
+
+	// Service principals have no user profile
+	rec := s.do(http.MethodGet, "/profile", bearer(created.Key), nil)
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "profile with service key: expected status %d, got %d")
+
+	rec = s.do(http.MethodGet, "/api-keys?service=billing", bearer(adminToken), nil)
+	assertStatus(t, rec.Code, http.StatusOK, "list service keys: expected status %d, got %d")
+	var resp struct {
+		Data []handler.APIKeyDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if len(resp.Data) != 1 || resp.Data[0].ID != created.ID {
+		t.Errorf("Unexpected service key list: %+v", resp.Data)
+	}
+}
+
+// TestAPIKeyLastUseKeepsRevocation verifies recording the last use of a key neither shares the
+// stored key with callers nor undoes a revocation saved at the same time
+func TestAPIKeyLastUseKeepsRevocation(t *testing.T) {
+	repo := repository.NewInMemoryAPIKeyRepository()
+	if err := repo.Save(&apikey.APIKey{ID: "k1", Prefix: "abc", Scopes: []string{"orders:read"}, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	found, _ := repo.FindByID("k1")
+	found.Scopes[0] = "orders:write"
+	if stored, _ := repo.FindByID("k1"); stored.Scopes[0] != "orders:read" {
+		t.Errorf("Expected callers not to share the stored key, got scopes %v", stored.Scopes)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := repo.UpdateLastUsed("k1", time.Now()); err != nil {
+				t.Errorf("UpdateLastUsed failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			key, _ := repo.FindByPrefix("abc")
+			_ = key.LastUsedAt
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		key, _ := repo.FindByID("k1")
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := repo.Update(key); err != nil {
+			t.Errorf("Update failed: %v", err)
+		}
+	}()
+	wg.Wait()
+
+	stored, _ := repo.FindByID("k1")
+	if stored.RevokedAt == nil {
+		t.Errorf("Expected the revocation to be kept, got %+v", stored)
+	}
+	if err := repo.UpdateLastUsed("missing", time.Now()); err == nil {
+		t.Errorf("Expected an unknown key to be reported")
+	}
+}
+
+func mustClaims(t *testing.T, token string) *auth.Claims {
+	t.Helper()
+	claims, err := testJWTService().ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	return claims
+}
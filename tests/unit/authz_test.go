@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/authz"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/middleware"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+const testAuthzPolicy = `{
+  "types": {
+    "user": {},
+    "team": {"relations": ["member"]},
+    "folder": {"relations": ["viewer"]},
+    "document": {
+      "relations": ["owner_team", "parent", "editor", "viewer"],
+      "permissions": {
+        "edit": ["editor", "owner_team->member"],
+        "view": ["viewer", "edit", "parent->viewer"]
+      }
+    }
+  },
+  "routes": [
+    {"method": "PUT", "path": "/documents/{id}", "resource": "document:{id}", "action": "edit"},
+    {"method": "GET", "path": "/documents/{id}", "resource": "document:{id}", "action": "view"}
+  ]
+}`
+
+func newTestAuthzUseCase(t *testing.T) *usecase.AuthzUseCase {
+	t.Helper()
+	policy, err := authz.ParsePolicy([]byte(testAuthzPolicy))
+	if err != nil {
+		t.Fatalf("ParsePolicy failed: %v", err)
+	}
+	return usecase.NewAuthzUseCase(policy, repository.NewInMemoryTupleRepository())
+}
+
+func authzAdmin() *auth.Principal {
+	return &auth.Principal{Type: auth.PrincipalUser, Subject: "admin", TenantID: tenant.DefaultID, Roles: []string{user.RoleAdmin}}
+}
+
+func authzUser(id string) *auth.Principal {
+	return &auth.Principal{Type: auth.PrincipalUser, Subject: id, TenantID: tenant.DefaultID}
+}
+
+func TestAuthzCheck(t *testing.T) {
+	uc := newTestAuthzUseCase(t)
+	if _, err := uc.WriteTuples(authzAdmin(), []string{
+		"team:platform#member@user:alice",
+		"document:readme#owner_team@team:platform",
+		"document:readme#parent@folder:docs",
+		"folder:docs#viewer@user:*",
+		"document:plan#viewer@team:platform#member",
+	}); err != nil {
+		t.Fatalf("WriteTuples failed: %v", err)
+	}
+
+	cases := []struct {
+		subject, action, resource string
+		want                      bool
+	}{
+		{"user:alice", "edit", "document:readme", true},
+		{"user:bob", "edit", "document:readme", false},
+		{"user:bob", "view", "document:readme", true},
+		{"user:alice", "viewer", "document:plan", true},
+		{"user:bob", "view", "document:plan", false},
+	}
+	for _, c := range cases {
+		subject, _ := authz.ParseSubject(c.subject)
+		resource, _ := authz.ParseObject(c.resource)
+		allowed, err := uc.Check(tenant.DefaultID, subject, c.action, resource)
+		if err != nil {
+			t.Fatalf("Check(%s, %s, %s) failed: %v", c.subject, c.action, c.resource, err)
+		}
+		if allowed != c.want {
+			t.Errorf("Check(%s, %s, %s) = %v, want %v", c.subject, c.action, c.resource, allowed, c.want)
+		}
+	}
+
+	// Tuples are scoped to their tenant
+	subject, _ := authz.ParseSubject("user:alice")
+	resource, _ := authz.ParseObject("document:readme")
+	if allowed, _ := uc.Check("acme", subject, "edit", resource); allowed {
+		t.Error("Expected tuples of another tenant not to grant access")
+	}
+
+	if _, err := uc.Check(tenant.DefaultID, subject, "delete", resource); !errors.Is(err, authz.ErrUnknownRelation) {
+		t.Errorf("Expected ErrUnknownRelation for an undefined action, got %v", err)
+	}
+	if _, err := uc.WriteTuples(authzAdmin(), []string{"document:readme#edit@user:bob"}); err == nil {
+		t.Error("Expected writing a permission as a tuple to be rejected")
+	}
+}
+
+func TestAuthzCheckAccessRights(t *testing.T) {
+	uc := newTestAuthzUseCase(t)
+	if _, err := uc.WriteTuples(authzUser("alice"), []string{"document:readme#editor@user:alice"}); !errors.Is(err, usecase.ErrForbidden) {
+		t.Errorf("Expected non-admins to be refused writing tuples, got %v", err)
+	}
+	if _, err := uc.WriteTuples(authzAdmin(), []string{"document:readme#editor@user:alice"}); err != nil {
+		t.Fatalf("WriteTuples failed: %v", err)
+	}
+
+	allowed, err := uc.CheckAccess(authzUser("alice"), usecase.CheckInput{Action: "edit", Resource: "document:readme"})
+	if err != nil || !allowed {
+		t.Errorf("Expected alice to edit her document, got %v, %v", allowed, err)
+	}
+	if _, err := uc.CheckAccess(authzUser("bob"), usecase.CheckInput{Subject: "user:alice", Action: "edit", Resource: "document:readme"}); !errors.Is(err, usecase.ErrForbidden) {
+		t.Errorf("Expected non-admins to be refused checking others, got %v", err)
+	}
+
+	scoped := authzAdmin()
+	scoped.Scopes = []string{"profile"}
+	if _, err := uc.ReadTuples(scoped, usecase.TupleQuery{}); !errors.Is(err, usecase.ErrForbidden) {
+		t.Errorf("Expected admins without the authz scope to be refused, got %v", err)
+	}
+}
+
+func TestAuthzExplain(t *testing.T) {
+	uc := newTestAuthzUseCase(t)
+	if _, err := uc.WriteTuples(authzAdmin(), []string{
+		"team:platform#member@user:alice",
+		"document:readme#owner_team@team:platform",
+	}); err != nil {
+		t.Fatalf("WriteTuples failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	authzHandler := handler.NewAuthzHandler(uc)
+	mux.Handle("/authz/explain", withTestPrincipal(authzAdmin(), http.HandlerFunc(authzHandler.Explain)))
+
+	body, _ := json.Marshal(handler.CheckRequest{Subject: "user:alice", Action: "edit", Resource: "document:readme"})
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/authz/explain", bytes.NewReader(body)))
+	assertStatus(t, rec.Code, http.StatusOK, "Expected status %d for an admin explain, got %d")
+
+	var explanation handler.ExplanationDTO
+	json.Unmarshal(rec.Body.Bytes(), &handler.APIResponse{Data: &explanation})
+	if !explanation.Allowed || explanation.Expression != "document:readme#edit" {
+		t.Fatalf("Unexpected explanation root %+v", explanation)
+	}
+
+	// The explanation must lead to the team membership that granted access
+	found := false
+	var walk func(*handler.ExplanationDTO)
+	walk = func(e *handler.ExplanationDTO) {
+		if e.Expression == "team:platform#member@user:alice" && e.Allowed {
+			found = true
+		}
+		for _, child := range e.Children {
+			walk(child)
+		}
+	}
+	walk(&explanation)
+	if !found {
+		t.Errorf("Expected the explanation to include the team membership tuple, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux = http.NewServeMux()
+	mux.Handle("/authz/explain", withTestPrincipal(authzUser("alice"), http.HandlerFunc(authzHandler.Explain)))
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/authz/explain", bytes.NewReader(body)))
+	assertStatus(t, rec.Code, http.StatusForbidden, "Expected status %d for a non-admin explain, got %d")
+}
+
+func TestAuthzMiddleware(t *testing.T) {
+	uc := newTestAuthzUseCase(t)
+	if _, err := uc.WriteTuples(authzAdmin(), []string{"document:readme#editor@user:alice", "document:readme#viewer@user:bob"}); err != nil {
+		t.Fatalf("WriteTuples failed: %v", err)
+	}
+	policy, _ := authz.ParsePolicy([]byte(testAuthzPolicy))
+	enforce := middleware.NewAuthzMiddleware(uc, policy.Routes).Enforce
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	cases := []struct {
+		principal    *auth.Principal
+		method, path string
+		want         int
+	}{
+		{authzUser("alice"), http.MethodPut, "/documents/readme", http.StatusNoContent},
+		{authzUser("bob"), http.MethodPut, "/documents/readme", http.StatusForbidden},
+		{authzUser("bob"), http.MethodGet, "/documents/readme", http.StatusNoContent},
+		{authzUser("carol"), http.MethodGet, "/documents/readme", http.StatusForbidden},
+		{authzUser("carol"), http.MethodGet, "/documents", http.StatusNoContent},
+		{nil, http.MethodGet, "/documents/readme", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		var h http.Handler = enforce(ok)
+		if c.principal != nil {
+			h = withTestPrincipal(c.principal, h)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, nil))
+		if rec.Code != c.want {
+			t.Errorf("%s %s: expected status %d, got %d", c.method, c.path, c.want, rec.Code)
+		}
+	}
+}
+
+// withTestPrincipal serves requests as if Authenticate had resolved principal
+func withTestPrincipal(principal *auth.Principal, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(middleware.WithPrincipal(r.Context(), principal)))
+	})
+}
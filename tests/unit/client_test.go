@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/middleware"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"github.com/lamboktulussimamora/gra-project/pkg/client"
+)
+
+// newClientServer serves the user endpoints the client calls, issuing access tokens valid for
+// tokenDuration with rotating refresh tokens
+func newClientServer(t *testing.T, tokenDuration time.Duration) *httptest.Server {
+	t.Helper()
+	jwtService := auth.NewJWTService(auth.JWTConfig{SecretKey: "test-secret", TokenDuration: tokenDuration})
+	userUseCase := usecase.NewUserUseCase(
+		repository.NewInMemoryUserRepository(),
+		auth.NewPasswordService(testArgonParams),
+		jwtService,
+		usecase.WithRefreshTokens(repository.NewInMemoryRefreshTokenRepository(), time.Hour),
+	)
+	userHandler := handler.NewUserHandler(userUseCase)
+	authMiddleware := middleware.NewAuthMiddleware(jwtService)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/register", userHandler.Register)
+	mux.HandleFunc("/login", userHandler.Login)
+	mux.HandleFunc("/refresh", userHandler.Refresh)
+	mux.Handle("/profile", authMiddleware.Authenticate(http.HandlerFunc(handler.NewProtectedHandler().Profile)))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// countingTransport counts the requests per path before passing them on
+type countingTransport struct {
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	if c.requests == nil {
+		c.requests = map[string]int{}
+	}
+	c.requests[req.URL.Path]++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (c *countingTransport) count(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests[path]
+}
+
+// busyTransport answers the first requests with 503 as a busy server would
+type busyTransport struct {
+	busy int
+}
+
+func (b *busyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if b.busy > 0 {
+		b.busy--
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(http.StatusServiceUnavailable)
+		return rec.Result(), nil
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+var fastRetry = client.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+func registerAndLogin(t *testing.T, c *client.Client) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := c.Register(ctx, client.RegisterRequest{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "Correct-Horse-9-Battery"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, err := c.Login(ctx, "ada@example.com", "Correct-Horse-9-Battery"); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+}
+
+func TestClientRegisterLoginProfile(t *testing.T) {
+	server := newClientServer(t, time.Hour)
+	c := client.New(server.URL)
+	ctx := context.Background()
+
+	if _, err := c.Profile(ctx); !errors.Is(err, client.ErrNotLoggedIn) {
+		t.Errorf("Expected ErrNotLoggedIn before logging in, got %v", err)
+	}
+
+	registered, err := c.Register(ctx, client.RegisterRequest{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "Correct-Horse-9-Battery"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if registered.Email != "ada@example.com" || registered.CreatedAt.IsZero() {
+		t.Errorf("Expected the registered user, got %+v", registered)
+	}
+
+	if _, err := c.Login(ctx, "ada@example.com", "wrong-password"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized for a wrong password, got %v", err)
+	}
+
+	loggedIn, err := c.Login(ctx, "ada@example.com", "Correct-Horse-9-Battery")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if loggedIn.FirstName != "Ada" {
+		t.Errorf("Expected Ada to log in, got %+v", loggedIn)
+	}
+	tokens, _ := c.Tokens()
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || time.Until(tokens.ExpiresAt) < 50*time.Minute {
+		t.Errorf("Expected stored tokens expiring in an hour, got %+v", tokens)
+	}
+
+	profile, err := c.Profile(ctx)
+	if err != nil {
+		t.Fatalf("Profile failed: %v", err)
+	}
+	if profile.Email != "ada@example.com" || profile.LastName != "Lovelace" {
+		t.Errorf("Expected Ada's profile, got %+v", profile)
+	}
+
+	if err := c.Logout(); err != nil {
+		t.Fatalf("Logout failed: %v", err)
+	}
+	if _, err := c.Profile(ctx); !errors.Is(err, client.ErrNotLoggedIn) {
+		t.Errorf("Expected ErrNotLoggedIn after logging out, got %v", err)
+	}
+}
+
+func TestClientTypedErrors(t *testing.T) {
+	server := newClientServer(t, time.Hour)
+	c := client.New(server.URL)
+	ctx := context.Background()
+
+	_, err := c.Register(ctx, client.RegisterRequest{FirstName: "Ada", Email: "not-an-email", Password: "short"})
+	if !errors.Is(err, client.ErrValidation) {
+		t.Fatalf("Expected ErrValidation, got %v", err)
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || len(apiErr.Fields) == 0 {
+		t.Fatalf("Expected the invalid fields, got %+v", apiErr)
+	}
+	fields := map[string]bool{}
+	for _, f := range apiErr.Fields {
+		fields[f.Field] = true
+	}
+	if !fields["email"] {
+		t.Errorf("Expected email to be invalid, got %+v", apiErr.Fields)
+	}
+
+	registerAndLogin(t, c)
+	_, err = c.Register(ctx, client.RegisterRequest{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "Correct-Horse-9-Battery"})
+	if !errors.Is(err, client.ErrBadRequest) || errors.Is(err, client.ErrValidation) {
+		t.Errorf("Expected ErrBadRequest for a duplicate email, got %v", err)
+	}
+}
+
+func TestClientRefreshesExpiringToken(t *testing.T) {
+	// Tokens valid for less than the refresh margin are renewed before every request
+	server := newClientServer(t, 10*time.Second)
+	transport := &countingTransport{}
+	c := client.New(server.URL, client.WithTransport(transport))
+	registerAndLogin(t, c)
+
+	if _, err := c.Profile(context.Background()); err != nil {
+		t.Fatalf("Profile failed: %v", err)
+	}
+	if n := transport.count("/refresh"); n != 1 {
+		t.Errorf("Expected the token to be refreshed once before the request, got %d refreshes", n)
+	}
+	if n := transport.count("/profile"); n != 1 {
+		t.Errorf("Expected one profile request, got %d", n)
+	}
+}
+
+func TestClientRefreshesRejectedToken(t *testing.T) {
+	server := newClientServer(t, time.Hour)
+	store := client.NewMemoryTokenStore()
+	transport := &countingTransport{}
+	c := client.New(server.URL, client.WithTokenStore(store), client.WithTransport(transport))
+	registerAndLogin(t, c)
+
+	// A token the server no longer accepts, e.g. signed with a rotated key
+	tokens, _ := store.Load()
+	tokens.AccessToken = "not-a-valid-token"
+	store.Save(tokens)
+
+	if _, err := c.Profile(context.Background()); err != nil {
+		t.Fatalf("Expected the rejected token to be refreshed, got %v", err)
+	}
+	if n := transport.count("/profile"); n != 2 {
+		t.Errorf("Expected the profile request to be sent again after refreshing, got %d requests", n)
+	}
+	refreshed, _ := store.Load()
+	if refreshed.AccessToken == "not-a-valid-token" || refreshed.RefreshToken == tokens.RefreshToken {
+		t.Errorf("Expected new tokens to be stored, got %+v", refreshed)
+	}
+}
+
+func TestClientRefreshTokenReuse(t *testing.T) {
+	server := newClientServer(t, time.Hour)
+	c := client.New(server.URL)
+	registerAndLogin(t, c)
+	ctx := context.Background()
+
+	stolen, _ := c.Tokens()
+	if _, err := c.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	// Replaying the rotated refresh token fails and revokes the tokens issued with it
+	attacker := client.New(server.URL, client.WithTokenStore(storeWith(stolen)))
+	if _, err := attacker.Refresh(ctx); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("Expected a reused refresh token to be rejected, got %v", err)
+	}
+	if _, err := c.Refresh(ctx); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("Expected the refreshed token to be revoked with its family, got %v", err)
+	}
+}
+
+func TestClientRetriesBusyServer(t *testing.T) {
+	server := newClientServer(t, time.Hour)
+	ctx := context.Background()
+
+	c := client.New(server.URL, client.WithTransport(&busyTransport{busy: 2}), client.WithRetry(fastRetry))
+	if _, err := c.Register(ctx, client.RegisterRequest{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "Correct-Horse-9-Battery"}); err != nil {
+		t.Fatalf("Expected Register to succeed after retries, got %v", err)
+	}
+
+	c = client.New(server.URL, client.WithTransport(&busyTransport{busy: 3}), client.WithRetry(fastRetry))
+	if _, err := c.Login(ctx, "ada@example.com", "Correct-Horse-9-Battery"); !errors.Is(err, client.ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable once retries run out, got %v", err)
+	}
+}
+
+func storeWith(tokens client.Tokens) client.TokenStore {
+	store := client.NewMemoryTokenStore()
+	store.Save(tokens)
+	return store
+}
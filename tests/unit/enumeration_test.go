@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/mail"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// TestLoginTimingDoesNotRevealAccounts compares login latency for registered and unknown emails
+func TestLoginTimingDoesNotRevealAccounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing test skipped in short mode")
+	}
+
+	// Use parameters expensive enough for the hash to dominate the measurement
+	params := testArgonParams
+	params.Memory = 8 * 1024
+	params.Iterations = 2
+
+	uc := usecase.NewUserUseCase(
+		repository.NewInMemoryUserRepository(),
+		auth.NewPasswordService(params),
+		testJWTService(),
+	)
+	if _, err := uc.Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	measure := func(email string) time.Duration {
+		start := time.Now()
+		if _, err := uc.Login(tenant.DefaultID, email, "wrong password"); err != usecase.ErrInvalidCredentials {
+			t.Fatalf("Expected invalid credentials, got %v", err)
+		}
+		return time.Since(start)
+	}
+
+	// Warm up, which also computes the dummy hash
+	measure("nobody@example.com")
+	measure("ann@example.com")
+
+	const samples = 40
+	var known, unknown []time.Duration
+	for i := 0; i < samples; i++ {
+		known = append(known, measure("ann@example.com"))
+		unknown = append(unknown, measure("nobody@example.com"))
+	}
+
+	knownMedian, unknownMedian := median(known), median(unknown)
+	ratio := float64(unknownMedian) / float64(knownMedian)
+	t.Logf("median login time: known %v, unknown %v (ratio %.2f)", knownMedian, unknownMedian, ratio)
+
+	if ratio < 0.7 || ratio > 1.4 {
+		t.Errorf("Login time reveals account existence: known %v, unknown %v", knownMedian, unknownMedian)
+	}
+}
+
+// countingRepository counts the writes made to a user store
+type countingRepository struct {
+	user.Repository
+	mu     sync.Mutex
+	writes int
+}
+
+func (r *countingRepository) Save(u *user.User) error {
+	r.count()
+	return r.Repository.Save(u)
+}
+
+func (r *countingRepository) Update(u *user.User) error {
+	r.count()
+	return r.Repository.Update(u)
+}
+
+func (r *countingRepository) count() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes++
+}
+
+func (r *countingRepository) Writes() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.writes
+}
+
+// blockingMailer holds every email until it is released
+type blockingMailer struct {
+	captureMailer
+	release chan struct{}
+}
+
+func (m *blockingMailer) Send(msg mail.Message) error {
+	<-m.release
+	return m.captureMailer.Send(msg)
+}
+
+// TestGenericRegistrationAnswersAlike verifies a taken email gets a response shaped like a
+// fresh registration, without a write to the store or waiting for the owner's email
+func TestGenericRegistrationAnswersAlike(t *testing.T) {
+	repo := &countingRepository{Repository: repository.NewInMemoryUserRepository()}
+	mailer := &blockingMailer{release: make(chan struct{})}
+	uc := usecase.NewUserUseCase(
+		repo,
+		auth.NewPasswordService(testArgonParams),
+		testJWTService(),
+		usecase.WithGenericRegistration(mailer),
+	)
+
+	if _, err := uc.Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	free, err := uc.Register(tenant.DefaultID, "Eve", "Mallory", "eve@example.com", "Kq8!vR3#pW6&")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	writes := repo.Writes()
+
+	done := make(chan *usecase.UserResponse)
+	go func() {
+		taken, err := uc.Register(tenant.DefaultID, "Eve", "Mallory", "ann@example.com", "Kq8!vR3#pW6&")
+		if err != nil {
+			t.Errorf("Expected duplicate registration to answer generically, got %v", err)
+		}
+		done <- taken
+	}()
+
+	var taken *usecase.UserResponse
+	select {
+	case taken = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Duplicate registration waited for the owner's email")
+	}
+	if taken == nil {
+		t.FailNow()
+	}
+
+	if got := repo.Writes(); got != writes {
+		t.Errorf("Expected no write for a taken email, got %d", got-writes)
+	}
+
+	// Both answers carry the same fields, with values of their own
+	if taken.ID == "" || taken.ID == free.ID || taken.CreatedAt.IsZero() || taken.UpdatedAt.IsZero() {
+		t.Errorf("Expected a fresh ID and timestamps, got %+v", taken)
+	}
+	if taken.FirstName != free.FirstName || taken.LastName != free.LastName || taken.Disabled != free.Disabled ||
+		strings.Join(taken.Roles, ",") != strings.Join(free.Roles, ",") {
+		t.Errorf("Expected the taken response %+v to match the free one %+v", taken, free)
+	}
+	if taken.Email != "ann@example.com" {
+		t.Errorf("Expected the response to echo the email, got %q", taken.Email)
+	}
+
+	close(mailer.release)
+	sent := mailer.waitFor(t, 1)
+	if sent[0].To != "ann@example.com" {
+		t.Errorf("Expected the existing owner to be notified, got %+v", sent[0])
+	}
+}
+
+// TestGenericRegistration verifies taken emails get the same answer and the owner is notified
+func TestGenericRegistration(t *testing.T) {
+	mailer := &captureMailer{}
+	uc := usecase.NewUserUseCase(
+		repository.NewInMemoryUserRepository(),
+		auth.NewPasswordService(testArgonParams),
+		testJWTService(),
+		usecase.WithGenericRegistration(mailer),
+	)
+
+	first, err := uc.Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	second, err := uc.Register(tenant.DefaultID, "Eve", "Mallory", "ann@example.com", "Kq8!vR3#pW6&")
+	if err != nil {
+		t.Fatalf("Expected duplicate registration to answer generically, got %v", err)
+	}
+	if second.Email != first.Email || second.FirstName != "Eve" {
+		t.Errorf("Expected the response to echo the request, got %+v", second)
+	}
+
+	sent := mailer.waitFor(t, 1)
+	if sent[0].To != "ann@example.com" || !strings.Contains(sent[0].Subject, "register") {
+		t.Errorf("Expected existing owner to be notified, got %+v", sent[0])
+	}
+
+	// The original account is untouched
+	if _, err := uc.Login(tenant.DefaultID, "ann@example.com", testPassword); err != nil {
+		t.Errorf("Expected original password to still work: %v", err)
+	}
+}
+
+// median returns the median of a set of durations
+func median(durations []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/event"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+func TestSCIMRaisesUserEventsThroughOutbox(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	users := usecase.NewUserUseCase(userRepo, auth.NewPasswordService(testArgonParams), testJWTService())
+	scim := usecase.NewSCIMUseCase(userRepo, repository.NewInMemoryGroupRepository(), users)
+	provisioner := &auth.Principal{Type: auth.PrincipalService, Subject: "okta", TenantID: tenant.DefaultID, Scopes: []string{usecase.ScopeSCIM}}
+
+	created, err := scim.CreateUser(provisioner, usecase.SCIMUserInput{UserName: "ann@example.com", GivenName: "Ann", FamilyName: "Lee"})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if _, err := scim.ReplaceUser(provisioner, created.ID, "", usecase.SCIMUserInput{UserName: "ann.lee@example.com", GivenName: "Ann", FamilyName: "Lee"}); err != nil {
+		t.Fatalf("ReplaceUser failed: %v", err)
+	}
+	if err := scim.DeleteUser(provisioner, created.ID, ""); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+
+	var events []event.Event
+	bus := event.NewBus()
+	bus.Subscribe(event.AllTypes, "recorder", func(e event.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	relay := usecase.NewEventRelay(userRepo, bus, usecase.DefaultEventRelayConfig())
+	if n, err := relay.RelayPending(); err != nil || n != 3 {
+		t.Fatalf("Expected three events to be relayed, got %d, %v", n, err)
+	}
+
+	want := []string{user.EventRegistered, user.EventEmailChanged, user.EventDisabled}
+	if len(events) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, events)
+	}
+	for i, eventType := range want {
+		if events[i].Type != eventType || events[i].AggregateID != created.ID || events[i].TenantID != tenant.DefaultID {
+			t.Errorf("Expected event %d to be %s for the user, got %+v", i, eventType, events[i])
+		}
+	}
+	if events[1].Data["previous_email"] != "ann@example.com" || events[1].Data["email"] != "ann.lee@example.com" {
+		t.Errorf("Expected the email change to carry both emails, got %v", events[1].Data)
+	}
+
+	if n, err := relay.RelayPending(); err != nil || n != 0 {
+		t.Errorf("Expected dispatched events to leave the outbox, got %d, %v", n, err)
+	}
+}
+
+func TestFailedUserChangeRaisesNoEvents(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	if err := userRepo.Save(user.NewUser(tenant.DefaultID, "Ann", "Lee", "ann@example.com", "")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := userRepo.Save(user.NewUser(tenant.DefaultID, "Ann", "Lee", "ann@example.com", "")); err == nil {
+		t.Fatal("Expected a duplicate user to be rejected")
+	}
+
+	pending, err := userRepo.Pending(time.Now(), 10)
+	if err != nil || len(pending) != 1 {
+		t.Errorf("Expected only the stored user's event in the outbox, got %v, %v", pending, err)
+	}
+}
+
+func TestEventRelayRetriesFailedSubscribers(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	if err := userRepo.Save(user.NewUser(tenant.DefaultID, "Ann", "Lee", "ann@example.com", "")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	handled := 0
+	failures := 1
+	bus := event.NewBus()
+	bus.Subscribe(user.EventRegistered, "counter", event.Idempotent(repository.NewInMemoryProcessedEventRepository(), "counter",
+		func(event.Event) error {
+			handled++
+			return nil
+		}))
+	bus.Subscribe(user.EventRegistered, "flaky", func(event.Event) error {
+		if failures > 0 {
+			failures--
+			return errors.New("downstream unavailable")
+		}
+		return nil
+	})
+	relay := usecase.NewEventRelay(userRepo, bus, usecase.EventRelayConfig{})
+
+	if n, err := relay.RelayPending(); err != nil || n != 0 {
+		t.Fatalf("Expected the failed dispatch to stay in the outbox, got %d, %v", n, err)
+	}
+	pending, err := userRepo.Pending(time.Now(), 10)
+	if err != nil || len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError == "" {
+		t.Fatalf("Expected the failure to be recorded, got %+v, %v", pending, err)
+	}
+
+	if n, err := relay.RelayPending(); err != nil || n != 1 {
+		t.Fatalf("Expected the retry to succeed, got %d, %v", n, err)
+	}
+	if handled != 1 {
+		t.Errorf("Expected the idempotent consumer to handle the redispatched event once, got %d", handled)
+	}
+}
+
+func TestEventRelayBacksOffFailedEvents(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	if err := userRepo.Save(user.NewUser(tenant.DefaultID, "Ann", "Lee", "ann@example.com", "")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	bus := event.NewBus()
+	bus.Subscribe(event.AllTypes, "broken", func(event.Event) error {
+		return errors.New("broken")
+	})
+	relay := usecase.NewEventRelay(userRepo, bus, usecase.DefaultEventRelayConfig())
+
+	if _, err := relay.RelayPending(); err != nil {
+		t.Fatalf("RelayPending failed: %v", err)
+	}
+	if pending, _ := userRepo.Pending(time.Now(), 10); len(pending) != 0 {
+		t.Errorf("Expected the failed event to wait for its backoff, got %+v", pending)
+	}
+	if pending, _ := userRepo.Pending(time.Now().Add(2*time.Second), 10); len(pending) != 1 {
+		t.Errorf("Expected the failed event to be due after its backoff, got %+v", pending)
+	}
+}
+
+func TestFileUserRepositoryPersistsOutbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	repo, err := repository.NewFileUserRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileUserRepository failed: %v", err)
+	}
+	u := user.NewUser(tenant.DefaultID, "Ann", "Lee", "ann@example.com", "")
+	if err := repo.Save(u); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reopened, err := repository.NewFileUserRepository(path)
+	if err != nil {
+		t.Fatalf("Reopening the store failed: %v", err)
+	}
+	pending, err := reopened.Pending(time.Now(), 10)
+	if err != nil || len(pending) != 1 || pending[0].Event.Type != user.EventRegistered || pending[0].Event.AggregateID != u.ID {
+		t.Fatalf("Expected the registration to survive a restart, got %+v, %v", pending, err)
+	}
+	if err := reopened.MarkDispatched(pending[0].Event.ID); err != nil {
+		t.Fatalf("MarkDispatched failed: %v", err)
+	}
+	if _, err := reopened.FindByEmail(tenant.DefaultID, "ann@example.com"); err != nil {
+		t.Errorf("Expected the user to survive a restart, got %v", err)
+	}
+}
+
+func TestFileUserRepositoryLoadsLegacyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	legacy := `[{"ID":"u1","TenantID":"default","Email":"ann@example.com","FirstName":"Ann","LastName":"Lee"}]`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	repo, err := repository.NewFileUserRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileUserRepository failed: %v", err)
+	}
+	if _, err := repo.FindByID(tenant.DefaultID, "u1"); err != nil {
+		t.Errorf("Expected the legacy user to load, got %v", err)
+	}
+	if pending, _ := repo.Pending(time.Now(), 10); len(pending) != 0 {
+		t.Errorf("Expected loading users to raise no events, got %+v", pending)
+	}
+}
+
+func TestFileUserRepositorySharedBetweenProcesses(t *testing.T) {
+	// Two repositories on one file stand in for a running server and the admin CLI
+	path := filepath.Join(t.TempDir(), "users.json")
+	server, err := repository.NewFileUserRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileUserRepository failed: %v", err)
+	}
+	ann := user.NewUser(tenant.DefaultID, "Ann", "Lee", "ann@example.com", "")
+	if err := server.Save(ann); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	cli, err := repository.NewFileUserRepository(path)
+	if err != nil {
+		t.Fatalf("NewFileUserRepository failed: %v", err)
+	}
+	disabled, err := cli.FindByEmail(tenant.DefaultID, "ann@example.com")
+	if err != nil {
+		t.Fatalf("FindByEmail failed: %v", err)
+	}
+	disabled.SetDisabled(true)
+	if err := cli.Update(disabled); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := cli.Save(user.NewUser(tenant.DefaultID, "Root", "Admin", "root@example.com", "")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// The server reads the changes without a restart and keeps them when it writes
+	if found, err := server.FindByEmail(tenant.DefaultID, "ann@example.com"); err != nil || !found.Disabled {
+		t.Errorf("Expected the server to see the account disabled, got %+v, %v", found, err)
+	}
+	if err := server.Save(user.NewUser(tenant.DefaultID, "Bob", "Ray", "bob@example.com", "")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if users, _ := cli.FindAll(tenant.DefaultID); len(users) != 3 {
+		t.Errorf("Expected the users written by both to be kept, got %d", len(users))
+	}
+	if pending, _ := cli.Pending(time.Now(), 10); len(pending) != 4 {
+		t.Errorf("Expected the events raised by both to be kept, got %d", len(pending))
+	}
+
+	// Concurrent writers do not overwrite each other's users
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		repo := server
+		if i%2 == 1 {
+			repo = cli
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := repo.Save(user.NewUser(tenant.DefaultID, "User", "Number", fmt.Sprintf("user%d@example.com", i), "")); err != nil {
+				t.Errorf("Save failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if users, _ := server.FindAll(tenant.DefaultID); len(users) != 23 {
+		t.Errorf("Expected every concurrent write to be kept, got %d users", len(users))
+	}
+}
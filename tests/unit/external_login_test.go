@@ -0,0 +1,298 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/upstream"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+const (
+	mockClientID     = "gra-app"
+	mockClientSecret = "mock-secret"
+	mockCallback     = "https://auth.example.com/auth/mock/callback"
+)
+
+// mockAccount is the account the mock provider signs in as
+type mockAccount struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Nonce overrides the nonce in the ID token when set
+	Nonce string
+}
+
+// mockGrant is an authorization code issued by the mock provider
+type mockGrant struct {
+	account   mockAccount
+	nonce     string
+	challenge string
+}
+
+// mockOIDCProvider is a minimal OpenID Connect provider that signs in as the configured account
+// without showing a login page
+type mockOIDCProvider struct {
+	server  *httptest.Server
+	keys    *auth.KeySet
+	mu      sync.Mutex
+	account mockAccount
+	grants  map[string]mockGrant
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	keys, err := auth.GenerateKeySet()
+	if err != nil {
+		t.Fatalf("GenerateKeySet failed: %v", err)
+	}
+	m := &mockOIDCProvider{keys: keys, grants: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(m.keys.JWKS())
+	})
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// signInAs sets the account the next authorization signs in as
+func (m *mockOIDCProvider) signInAs(account mockAccount) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.account = account
+}
+
+func (m *mockOIDCProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != mockClientID || q.Get("redirect_uri") != mockCallback || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	code := "code-" + q.Get("state")[:8]
+	m.grants[code] = mockGrant{account: m.account, nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	m.mu.Unlock()
+
+	http.Redirect(w, r, mockCallback+"?"+url.Values{"code": {code}, "state": {q.Get("state")}}.Encode(), http.StatusFound)
+}
+
+func (m *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != mockClientID || secret != mockClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.grants[r.PostFormValue("code")]
+	delete(m.grants, r.PostFormValue("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	nonce := grant.nonce
+	if grant.account.Nonce != "" {
+		nonce = grant.account.Nonce
+	}
+	idToken, _ := m.keys.Sign(jwt.MapClaims{
+		"iss":            m.server.URL,
+		"aud":            mockClientID,
+		"sub":            grant.account.Subject,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          grant.account.Email,
+		"email_verified": grant.account.EmailVerified,
+		"given_name":     "Sam",
+		"family_name":    "Rivera",
+	})
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "upstream-token", "token_type": "Bearer", "id_token": idToken})
+}
+
+// externalLoginServer wires the external login endpoints to a mock upstream provider
+type externalLoginServer struct {
+	*testServer
+	provider *mockOIDCProvider
+}
+
+func newExternalLoginServer(t *testing.T, config usecase.ExternalLoginConfig) *externalLoginServer {
+	t.Helper()
+	s := &externalLoginServer{testServer: newTestServer(t), provider: newMockOIDCProvider(t)}
+
+	provider, err := upstream.NewProvider(upstream.Config{
+		Name:         "mock",
+		Issuer:       s.provider.server.URL,
+		ClientID:     mockClientID,
+		ClientSecret: mockClientSecret,
+		RedirectURL:  mockCallback,
+	}, s.provider.server.Client())
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	externalLogins := usecase.NewExternalLoginUseCase([]identity.Provider{provider},
+		repository.NewInMemoryLinkedIdentityRepository(), repository.NewInMemoryLoginStateRepository(),
+		s.userRepo, testJWTService(), config)
+
+	externalLoginHandler := handler.NewExternalLoginHandler(externalLogins, true)
+	s.mux.HandleFunc("GET /auth/mock/login", externalLoginHandler.Login)
+	s.mux.HandleFunc("GET /auth/mock/callback", externalLoginHandler.Callback)
+	s.mux.Handle("GET /auth/identities", s.protect(externalLoginHandler.Identities))
+	s.mux.Handle("DELETE /auth/identities/{provider}", s.protect(externalLoginHandler.Unlink))
+	return s
+}
+
+// start begins a login and returns the state cookie and the callback URL the provider redirected to
+func (s *externalLoginServer) start(t *testing.T, account mockAccount) (*http.Cookie, string) {
+	t.Helper()
+	s.provider.signInAs(account)
+
+	rec := s.serve(httptest.NewRequest(http.MethodGet, "/auth/mock/login", nil))
+	assertStatus(t, rec.Code, http.StatusFound, "start login: expected status %d, got %d")
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != handler.ExternalLoginStateCookie || !cookies[0].HttpOnly {
+		t.Fatalf("Expected an HttpOnly state cookie, got %v", cookies)
+	}
+
+	client := s.provider.server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Provider authorization failed: %v", err)
+	}
+	resp.Body.Close()
+	assertStatus(t, resp.StatusCode, http.StatusFound, "provider authorization: expected status %d, got %d")
+	return cookies[0], resp.Header.Get("Location")
+}
+
+// callback delivers the provider's redirect to the callback endpoint
+func (s *externalLoginServer) callback(cookie *http.Cookie, callbackURL string) (handler.AuthResponseDTO, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, callbackURL, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := s.serve(req)
+
+	var resp struct {
+		Data handler.AuthResponseDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp.Data, rec
+}
+
+// externalLogin runs a complete external login
+func (s *externalLoginServer) externalLogin(t *testing.T, account mockAccount) (handler.AuthResponseDTO, *httptest.ResponseRecorder) {
+	t.Helper()
+	cookie, callbackURL := s.start(t, account)
+	return s.callback(cookie, callbackURL)
+}
+
+// TestExternalLoginLinksVerifiedEmail verifies an upstream account is linked to the existing user
+// with the same verified email and signs in as that user afterwards
+func TestExternalLoginLinksVerifiedEmail(t *testing.T) {
+	s := newExternalLoginServer(t, usecase.DefaultExternalLoginConfig())
+	if _, err := s.users.Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	ann, _ := s.userRepo.FindByEmail(tenant.DefaultID, "ann@example.com")
+	account := mockAccount{Subject: "upstream-1", Email: "Ann@Example.com", EmailVerified: true}
+
+	cookie, callbackURL := s.start(t, account)
+	authResp, rec := s.callback(cookie, callbackURL)
+	assertStatus(t, rec.Code, http.StatusOK, "external login: expected status %d, got %d")
+	if claims := mustClaims(t, authResp.Token); claims.Subject != ann.ID || authResp.User.Email != "ann@example.com" {
+		t.Fatalf("Expected a token for the existing user, got %+v", claims)
+	}
+
+	// The state is single use
+	_, rec = s.callback(cookie, callbackURL)
+	assertStatus(t, rec.Code, http.StatusBadRequest, "replayed callback: expected status %d, got %d")
+
+	// Later logins find the link even if the upstream email changes
+	account.Email = "ann.lee@example.org"
+	authResp, rec = s.externalLogin(t, account)
+	assertStatus(t, rec.Code, http.StatusOK, "second external login: expected status %d, got %d")
+	if mustClaims(t, authResp.Token).Subject != ann.ID {
+		t.Error("A linked identity should sign in to the same user")
+	}
+
+	rec = s.do(http.MethodGet, "/auth/identities", bearer(authResp.Token), nil)
+	var identities struct {
+		Data []handler.LinkedIdentityDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &identities)
+	if len(identities.Data) != 1 || identities.Data[0].Provider != "mock" || identities.Data[0].Email != "ann.lee@example.org" {
+		t.Errorf("Unexpected linked identities %+v", identities.Data)
+	}
+
+	// Ann has a password, so the link can be removed
+	rec = s.do(http.MethodDelete, "/auth/identities/mock", bearer(authResp.Token), nil)
+	assertStatus(t, rec.Code, http.StatusOK, "unlink: expected status %d, got %d")
+}
+
+// TestExternalLoginRejections covers unverified emails, state binding, nonce checks and account creation
+func TestExternalLoginRejections(t *testing.T) {
+	s := newExternalLoginServer(t, usecase.DefaultExternalLoginConfig())
+	if _, err := s.users.Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	_, rec := s.externalLogin(t, mockAccount{Subject: "upstream-1", Email: "ann@example.com"})
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "unverified email: expected status %d, got %d")
+
+	_, callbackURL := s.start(t, mockAccount{Subject: "upstream-1", Email: "ann@example.com", EmailVerified: true})
+	_, rec = s.callback(nil, callbackURL)
+	assertStatus(t, rec.Code, http.StatusBadRequest, "callback without state cookie: expected status %d, got %d")
+
+	_, rec = s.externalLogin(t, mockAccount{Subject: "upstream-1", Email: "ann@example.com", EmailVerified: true, Nonce: "replayed"})
+	assertStatus(t, rec.Code, http.StatusBadGateway, "ID token with wrong nonce: expected status %d, got %d")
+
+	// A verified email without an account gets a new passwordless user
+	authResp, rec := s.externalLogin(t, mockAccount{Subject: "upstream-2", Email: "sam@example.com", EmailVerified: true})
+	assertStatus(t, rec.Code, http.StatusOK, "external sign-up: expected status %d, got %d")
+	sam, err := s.userRepo.FindByEmail(tenant.DefaultID, "sam@example.com")
+	if err != nil || sam.FirstName != "Sam" || sam.LastName != "Rivera" || sam.Password != "" {
+		t.Fatalf("Expected a new passwordless user, got %+v, %v", sam, err)
+	}
+
+	// The only way into a passwordless account cannot be removed
+	rec = s.do(http.MethodDelete, "/auth/identities/mock", bearer(authResp.Token), nil)
+	assertStatus(t, rec.Code, http.StatusConflict, "unlink last sign-in method: expected status %d, got %d")
+
+	linkOnly := usecase.DefaultExternalLoginConfig()
+	linkOnly.CreateUsers = false
+	s = newExternalLoginServer(t, linkOnly)
+	_, rec = s.externalLogin(t, mockAccount{Subject: "upstream-3", Email: "kim@example.com", EmailVerified: true})
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "external sign-up when disabled: expected status %d, got %d")
+}
@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	userv1 "github.com/lamboktulussimamora/gra-project/api/proto/user/v1"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/rpc"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// newGRPCClient serves the user service over an in-memory connection
+func newGRPCClient(t *testing.T) (userv1.UserServiceClient, *repository.InMemoryUserRepository) {
+	t.Helper()
+	userRepo := repository.NewInMemoryUserRepository()
+	jwtService := testJWTService()
+	users := usecase.NewUserUseCase(userRepo, auth.NewPasswordService(testArgonParams), jwtService)
+
+	tenantRepo := repository.NewInMemoryTenantRepository()
+	for _, id := range []string{tenant.DefaultID, "acme"} {
+		if err := tenantRepo.Save(&tenant.Tenant{ID: id, Name: id, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Save tenant failed: %v", err)
+		}
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := rpc.NewServer(users, jwtService, tenantRepo, "X-Tenant-ID")
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return userv1.NewUserServiceClient(conn), userRepo
+}
+
+// withToken adds a bearer token to the outgoing metadata
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func assertCode(t *testing.T, err error, want codes.Code, msg string) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Errorf(msg, want, got)
+	}
+}
+
+func TestGRPCRegisterLoginAndValidate(t *testing.T) {
+	client, _ := newGRPCClient(t)
+	ctx := context.Background()
+
+	reg, err := client.Register(ctx, &userv1.RegisterRequest{FirstName: "Ann", LastName: "Lee", Email: "Ann@Example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if reg.GetUser().GetEmail() != "ann@example.com" || reg.GetUser().GetId() == "" {
+		t.Errorf("Unexpected registered user %v", reg.GetUser())
+	}
+	_, err = client.Register(ctx, &userv1.RegisterRequest{FirstName: "Ann", LastName: "Lee", Email: "ann@example.com", Password: testPassword})
+	assertCode(t, err, codes.AlreadyExists, "Expected code %v for a taken email, got %v")
+
+	_, err = client.Login(ctx, &userv1.LoginRequest{Email: "ann@example.com", Password: "wrong password"})
+	assertCode(t, err, codes.Unauthenticated, "Expected code %v for a wrong password, got %v")
+	login, err := client.Login(ctx, &userv1.LoginRequest{Email: "ann@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	validated, err := client.ValidateToken(ctx, &userv1.ValidateTokenRequest{Token: login.GetToken()})
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	claims := validated.GetClaims()
+	if claims.GetSubject() != reg.GetUser().GetId() || claims.GetEmail() != "ann@example.com" || claims.GetTenantId() != tenant.DefaultID {
+		t.Errorf("Unexpected claims %v", claims)
+	}
+	if !claims.GetExpiresAt().AsTime().After(time.Now()) {
+		t.Errorf("Expected the expiry to be in the future, got %v", claims.GetExpiresAt().AsTime())
+	}
+
+	_, err = client.ValidateToken(ctx, &userv1.ValidateTokenRequest{Token: "not-a-token"})
+	assertCode(t, err, codes.Unauthenticated, "Expected code %v for an invalid token, got %v")
+	acme := metadata.AppendToOutgoingContext(ctx, "x-tenant-id", "acme")
+	_, err = client.ValidateToken(acme, &userv1.ValidateTokenRequest{Token: login.GetToken()})
+	assertCode(t, err, codes.Unauthenticated, "Expected code %v for a token of another tenant, got %v")
+}
+
+func TestGRPCValidationErrors(t *testing.T) {
+	client, _ := newGRPCClient(t)
+
+	_, err := client.Register(context.Background(), &userv1.RegisterRequest{Email: "not-an-email"})
+	assertCode(t, err, codes.InvalidArgument, "Expected code %v for invalid input, got %v")
+
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range badRequest.GetFieldViolations() {
+				fields = append(fields, v.GetField())
+			}
+		}
+	}
+	if len(fields) < 2 {
+		t.Errorf("Expected a violation per invalid field, got %v", fields)
+	}
+}
+
+func TestGRPCGetUserRequiresToken(t *testing.T) {
+	client, userRepo := newGRPCClient(t)
+	ctx := context.Background()
+	for _, email := range []string{"ann@example.com", "bob@example.com"} {
+		if _, err := client.Register(ctx, &userv1.RegisterRequest{FirstName: "Test", LastName: "User", Email: email, Password: testPassword}); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+	login, err := client.Login(ctx, &userv1.LoginRequest{Email: "ann@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	bob, _ := userRepo.FindByEmail(tenant.DefaultID, "bob@example.com")
+
+	_, err = client.GetUser(ctx, &userv1.GetUserRequest{})
+	assertCode(t, err, codes.Unauthenticated, "Expected code %v without a token, got %v")
+
+	self, err := client.GetUser(withToken(ctx, login.GetToken()), &userv1.GetUserRequest{})
+	if err != nil || self.GetUser().GetEmail() != "ann@example.com" {
+		t.Fatalf("Expected the caller to be returned, got %v, %v", self, err)
+	}
+	_, err = client.GetUser(withToken(ctx, login.GetToken()), &userv1.GetUserRequest{Id: bob.ID})
+	assertCode(t, err, codes.PermissionDenied, "Expected code %v for another user's account, got %v")
+
+	// Admins may read any account of their tenant
+	ann, _ := userRepo.FindByEmail(tenant.DefaultID, "ann@example.com")
+	ann.Roles = []string{user.RoleAdmin}
+	if err := userRepo.Update(ann); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	admin, err := client.Login(ctx, &userv1.LoginRequest{Email: "ann@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	other, err := client.GetUser(withToken(ctx, admin.GetToken()), &userv1.GetUserRequest{Id: bob.ID})
+	if err != nil || other.GetUser().GetEmail() != "bob@example.com" {
+		t.Errorf("Expected an admin to read another account, got %v, %v", other, err)
+	}
+	_, err = client.GetUser(withToken(ctx, admin.GetToken()), &userv1.GetUserRequest{Id: "missing"})
+	assertCode(t, err, codes.NotFound, "Expected code %v for an unknown user, got %v")
+
+	// A third-party client acting for the admin only reads other users with the users:read scope
+	oauthToken := func(scope string) string {
+		token, err := testJWTService().GenerateClaimsToken(&auth.Claims{
+			Email: ann.Email, Roles: ann.Roles, TenantID: tenant.DefaultID, Scope: scope, ClientID: "reports",
+			RegisteredClaims: jwt.RegisteredClaims{Subject: ann.ID},
+		}, time.Minute)
+		if err != nil {
+			t.Fatalf("GenerateClaimsToken failed: %v", err)
+		}
+		return token
+	}
+	_, err = client.GetUser(withToken(ctx, oauthToken("openid profile")), &userv1.GetUserRequest{Id: bob.ID})
+	assertCode(t, err, codes.PermissionDenied, "Expected code %v for a client without the users:read scope, got %v")
+	if _, err := client.GetUser(withToken(ctx, oauthToken("openid")), &userv1.GetUserRequest{}); err != nil {
+		t.Errorf("Expected a client to read the admin's own account, got %v", err)
+	}
+	scoped, err := client.GetUser(withToken(ctx, oauthToken(usecase.ScopeUsersRead)), &userv1.GetUserRequest{Id: bob.ID})
+	if err != nil || scoped.GetUser().GetEmail() != "bob@example.com" {
+		t.Errorf("Expected a client with the users:read scope to read another account, got %v, %v", scoped, err)
+	}
+}
+
+func TestGRPCUnknownTenant(t *testing.T) {
+	client, _ := newGRPCClient(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", "initech")
+
+	_, err := client.Login(ctx, &userv1.LoginRequest{Email: "ann@example.com", Password: testPassword})
+	assertCode(t, err, codes.NotFound, "Expected code %v for an unknown tenant, got %v")
+}
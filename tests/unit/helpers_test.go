@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/authz"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/middleware"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// testPassword is the password of the users the tests register
+const testPassword = "tV9#qL2!mZ7$"
+
+// testArgonParams keeps hashing cheap so the suite stays fast
+var testArgonParams = auth.ArgonParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// testJWTService returns a JWT service with a fixed test secret
+func testJWTService() auth.JWTService {
+	return auth.NewJWTService(auth.JWTConfig{
+		SecretKey:     "test-secret",
+		TokenDuration: time.Hour,
+	})
+}
+
+// newTestUserUseCase wires a user use case backed by an in-memory repository
+func newTestUserUseCase(t *testing.T) *usecase.UserUseCase {
+	t.Helper()
+	return usecase.NewUserUseCase(
+		repository.NewInMemoryUserRepository(),
+		auth.NewPasswordService(testArgonParams),
+		testJWTService(),
+	)
+}
+
+// testServer wires the use cases behind the HTTP tests around in-memory stores. Tests register
+// the routes they exercise on mux, wrapping protected handlers with protect.
+type testServer struct {
+	userRepo    *repository.InMemoryUserRepository
+	sessionRepo *repository.InMemorySessionRepository
+	clients     *repository.InMemoryOAuthClientRepository
+	users       *usecase.UserUseCase
+	sessions    *usecase.SessionUseCase
+	apiKeys     *usecase.APIKeyUseCase
+	oauth       *usecase.OAuthUseCase
+	keys        *auth.KeySet
+	mux         *http.ServeMux
+	protect     func(h http.HandlerFunc) http.Handler
+}
+
+// newTestServer creates a test server whose protect is the middleware chain of cmd/api: a JWT,
+// an API key or a session cookie authenticates, cookie requests must carry the CSRF token, and
+// routes of the (empty) authorization policy are checked
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	s := &testServer{
+		userRepo:    repository.NewInMemoryUserRepository(),
+		sessionRepo: repository.NewInMemorySessionRepository(),
+		clients:     repository.NewInMemoryOAuthClientRepository(),
+		mux:         http.NewServeMux(),
+	}
+	jwtService := testJWTService()
+	s.users = usecase.NewUserUseCase(s.userRepo, auth.NewPasswordService(testArgonParams), jwtService,
+		usecase.WithRefreshTokens(repository.NewInMemoryRefreshTokenRepository(), time.Hour),
+		usecase.WithSessions(s.sessionRepo))
+	s.sessions = usecase.NewSessionUseCase(s.sessionRepo, s.userRepo, s.users, usecase.DefaultSessionConfig())
+	s.apiKeys = usecase.NewAPIKeyUseCase(repository.NewInMemoryAPIKeyRepository(), s.userRepo)
+
+	var err error
+	if s.keys, err = auth.GenerateKeySet(); err != nil {
+		t.Fatalf("GenerateKeySet failed: %v", err)
+	}
+	s.oauth = usecase.NewOAuthUseCase(usecase.OAuthRepositories{
+		Clients:       s.clients,
+		Codes:         repository.NewInMemoryAuthorizationCodeRepository(),
+		RefreshTokens: repository.NewInMemoryRefreshTokenRepository(),
+		Consents:      repository.NewInMemoryConsentRepository(),
+		Revocations:   repository.NewInMemoryRevocationRepository(),
+	}, s.userRepo, jwtService, usecase.DefaultOAuthConfig(oauthTestIssuer), usecase.WithIDTokens(s.keys))
+
+	authMiddleware := middleware.NewAuthMiddleware(jwtService,
+		middleware.WithAPIKeys(s.apiKeys, usecase.APIKeyPrefix),
+		middleware.WithTokenRevocation(s.oauth),
+		middleware.WithAccountStatus(s.users),
+		middleware.WithSessions(s.sessions, handler.DefaultSessionCookieConfig().Name))
+	csrfMiddleware := middleware.NewCSRFMiddleware()
+	policy := &authz.Policy{}
+	authzMiddleware := middleware.NewAuthzMiddleware(usecase.NewAuthzUseCase(policy, repository.NewInMemoryTupleRepository()), policy.Routes)
+	s.protect = func(h http.HandlerFunc) http.Handler {
+		return authMiddleware.Authenticate(csrfMiddleware.Protect(authzMiddleware.Enforce(h)))
+	}
+	return s
+}
+
+// login registers a user, optionally with roles, and returns a login JWT
+func (s *testServer) login(t *testing.T, email string, roles ...string) string {
+	t.Helper()
+	if _, err := s.users.Register(tenant.DefaultID, "Ann", "Lee", email, testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if len(roles) > 0 {
+		u, _ := s.userRepo.FindByEmail(tenant.DefaultID, email)
+		u.Roles = roles
+		if err := s.userRepo.Update(u); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	resp, err := s.users.Login(tenant.DefaultID, email, testPassword)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	return resp.Token
+}
+
+// serve sends a request to the server
+func (s *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// do sends a request with body encoded as JSON, if given
+func (s *testServer) do(method, path string, header http.Header, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return s.serve(req)
+}
+
+func bearer(credential string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + credential}}
+}
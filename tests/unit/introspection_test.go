@@ -0,0 +1,226 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/oauth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"github.com/lamboktulussimamora/gra-project/pkg/introspect"
+)
+
+// newIntrospectionServer serves /introspect next to the OAuth endpoints
+func newIntrospectionServer(t *testing.T) (*oauthServer, *usecase.APIKeyUseCase, *httptest.Server) {
+	t.Helper()
+	s := newOAuthServer(t)
+	introspection := usecase.NewIntrospectionUseCase(testJWTService(), s.oauth, s.userRepo, usecase.IntrospectionConfig{})
+	s.mux.Handle("POST /introspect", s.protect(handler.NewIntrospectionHandler(introspection).Introspect))
+
+	server := httptest.NewServer(s.mux)
+	t.Cleanup(server.Close)
+	return s, s.apiKeys, server
+}
+
+func TestIntrospectWithAPIKey(t *testing.T) {
+	s, apiKeys, server := newIntrospectionServer(t)
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+	admin, _ := s.userRepo.FindByEmail(tenant.DefaultID, "admin@example.com")
+	adminPrincipal := auth.NewUserPrincipal(&auth.Claims{TenantID: tenant.DefaultID, Roles: []string{user.RoleAdmin}})
+	adminPrincipal.Subject = admin.ID
+	key, err := apiKeys.Create(adminPrincipal, usecase.CreateAPIKeyInput{Name: "orders", ServiceName: "orders", Scopes: []string{usecase.ScopeIntrospect}})
+	if err != nil {
+		t.Fatalf("Create API key failed: %v", err)
+	}
+	client := introspect.New(server.URL, introspect.WithAPIKey(key.Key))
+
+	result, err := client.Introspect(context.Background(), adminToken)
+	if err != nil {
+		t.Fatalf("Introspect failed: %v", err)
+	}
+	if !result.Active || result.Claims.Subject != admin.ID || result.Claims.Email != "admin@example.com" || !result.Claims.HasRole(user.RoleAdmin) {
+		t.Errorf("Expected the admin's claims, got %+v", result.Claims)
+	}
+	if result.Claims.ExpiresAt == nil || !result.Claims.ExpiresAt.After(time.Now()) {
+		t.Errorf("Expected a future expiry, got %v", result.Claims.ExpiresAt)
+	}
+
+	result, err = client.Introspect(context.Background(), "not-a-token")
+	if err != nil || result.Active || result.Claims != nil {
+		t.Errorf("Expected an invalid token to be inactive, got %+v, %v", result, err)
+	}
+
+	noScope, err := apiKeys.Create(adminPrincipal, usecase.CreateAPIKeyInput{Name: "reports", ServiceName: "reports", Scopes: []string{"reports"}})
+	if err != nil {
+		t.Fatalf("Create API key failed: %v", err)
+	}
+	if _, err := introspect.New(server.URL, introspect.WithAPIKey(noScope.Key)).Introspect(context.Background(), adminToken); !errors.Is(err, introspect.ErrForbidden) {
+		t.Errorf("Expected a key without the introspect scope to be forbidden, got %v", err)
+	}
+	if _, err := introspect.New(server.URL, introspect.WithAPIKey("gra_invalid")).Introspect(context.Background(), adminToken); !errors.Is(err, introspect.ErrUnauthorized) {
+		t.Errorf("Expected an unknown key to be unauthorized, got %v", err)
+	}
+
+	// A user's login is not a service, even though its token is unrestricted
+	req := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(`{"token":"x"}`))
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	assertStatus(t, s.serve(req).Code, http.StatusForbidden, "Expected status %d for a user token, got %d")
+}
+
+func TestIntrospectWithClientCredentials(t *testing.T) {
+	s, _, server := newIntrospectionServer(t)
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+	machine := s.registerClient(t, adminToken, handler.RegisterClientRequest{
+		Name:       "Orders Service",
+		GrantTypes: []string{oauth.GrantClientCredentials},
+		Scopes:     []string{usecase.ScopeIntrospect, "orders:read"},
+	})
+	client := introspect.New(server.URL, introspect.WithClientCredentials(machine.ID, machine.Secret))
+
+	// Revoked access tokens are inactive
+	tokens, _, status := s.token(t, machine, url.Values{"grant_type": {"client_credentials"}, "scope": {"orders:read"}})
+	assertStatus(t, status, http.StatusOK, "client credentials: expected status %d, got %d")
+	result, err := client.Introspect(context.Background(), tokens.AccessToken)
+	if err != nil || !result.Active || result.Claims.ClientID != machine.ID || result.Claims.Scope != "orders:read" {
+		t.Fatalf("Expected the access token to be active, got %+v, %v", result, err)
+	}
+	assertStatus(t, s.postForm("/oauth/revoke", machine, url.Values{"token": {tokens.AccessToken}}).Code, http.StatusOK,
+		"revoke: expected status %d, got %d")
+	result, err = client.Introspect(context.Background(), tokens.AccessToken)
+	if err != nil || result.Active {
+		t.Errorf("Expected a revoked token to be inactive, got %+v, %v", result, err)
+	}
+
+	wrong := introspect.New(server.URL, introspect.WithClientCredentials(machine.ID, "wrong"))
+	if _, err := wrong.Introspect(context.Background(), tokens.AccessToken); !errors.Is(err, introspect.ErrUnauthorized) {
+		t.Errorf("Expected a wrong client secret to be unauthorized, got %v", err)
+	}
+}
+
+func TestIntrospectInactiveForDisabledUsers(t *testing.T) {
+	s, apiKeys, server := newIntrospectionServer(t)
+	s.login(t, "admin@example.com", user.RoleAdmin)
+	admin, _ := s.userRepo.FindByEmail(tenant.DefaultID, "admin@example.com")
+	adminPrincipal := auth.NewUserPrincipal(&auth.Claims{TenantID: tenant.DefaultID, Roles: []string{user.RoleAdmin}})
+	adminPrincipal.Subject = admin.ID
+	key, err := apiKeys.Create(adminPrincipal, usecase.CreateAPIKeyInput{Name: "orders", ServiceName: "orders", Scopes: []string{usecase.ScopeIntrospect}})
+	if err != nil {
+		t.Fatalf("Create API key failed: %v", err)
+	}
+	client := introspect.New(server.URL, introspect.WithAPIKey(key.Key))
+
+	annToken := s.login(t, "ann@example.com")
+	result, err := client.Introspect(context.Background(), annToken)
+	if err != nil || !result.Active {
+		t.Fatalf("Expected Ann's token to be active, got %+v, %v", result, err)
+	}
+	// Every login token carries its own ID, so it can be revoked alone
+	if result.Claims.TokenID == "" {
+		t.Errorf("Expected the login token to carry a jti")
+	}
+	if again, _ := s.users.Login(tenant.DefaultID, "ann@example.com", testPassword); again.Token == annToken {
+		t.Errorf("Expected each login to issue a distinct token")
+	}
+
+	ann, _ := s.userRepo.FindByEmail(tenant.DefaultID, "ann@example.com")
+	ann.SetDisabled(true)
+	if err := s.userRepo.Update(ann); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if result, err := client.Introspect(context.Background(), annToken); err != nil || result.Active {
+		t.Errorf("Expected the token of a disabled account to be inactive, got %+v, %v", result, err)
+	}
+
+	// Tokens of users that do not exist, e.g. deleted ones, are inactive too
+	ghost := &auth.Claims{Email: "ghost@example.com", TenantID: tenant.DefaultID}
+	ghost.Subject = "deleted-user"
+	ghostToken, err := testJWTService().GenerateClaimsToken(ghost, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateClaimsToken failed: %v", err)
+	}
+	if result, err := client.Introspect(context.Background(), ghostToken); err != nil || result.Active {
+		t.Errorf("Expected the token of a missing user to be inactive, got %+v, %v", result, err)
+	}
+}
+
+// countingRevocations counts revocation checks and revokes nothing
+type countingRevocations struct {
+	checks int
+}
+
+func (r *countingRevocations) IsTokenRevoked(string) bool {
+	r.checks++
+	return false
+}
+
+func TestIntrospectionCachesAndRateLimits(t *testing.T) {
+	jwtService := testJWTService()
+	revocations := &countingRevocations{}
+	userRepo := repository.NewInMemoryUserRepository()
+	ann := user.NewUser(tenant.DefaultID, "Ann", "Lee", "ann@example.com", "")
+	userRepo.Save(ann)
+	introspection := usecase.NewIntrospectionUseCase(jwtService, revocations, userRepo, usecase.IntrospectionConfig{CacheTTL: time.Minute, RateLimit: 2})
+	caller := &auth.Principal{Type: auth.PrincipalService, Subject: "orders", TenantID: tenant.DefaultID,
+		AuthMethod: auth.AuthMethodAPIKey, APIKeyID: "k1", Scopes: []string{usecase.ScopeIntrospect}}
+
+	claims := &auth.Claims{Email: "ann@example.com", TenantID: tenant.DefaultID}
+	claims.Subject = ann.ID
+	claims.ID = "t1"
+	token, err := jwtService.GenerateClaimsToken(claims, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateClaimsToken failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		result, err := introspection.Introspect(caller, token)
+		if err != nil || !result.Active {
+			t.Fatalf("Expected the token to be active, got %+v, %v", result, err)
+		}
+	}
+	if revocations.checks != 1 {
+		t.Errorf("Expected the second result to come from the cache, got %d revocation checks", revocations.checks)
+	}
+	if _, err := introspection.Introspect(caller, token); !errors.Is(err, usecase.ErrRateLimited) {
+		t.Errorf("Expected the third call in a minute to be rate limited, got %v", err)
+	}
+
+	other := *caller
+	other.APIKeyID = "k2"
+	if _, err := introspection.Introspect(&other, token); err != nil {
+		t.Errorf("Expected each caller to have its own limit, got %v", err)
+	}
+	acme := other
+	acme.TenantID = "acme"
+	acme.APIKeyID = "k3"
+	if result, err := introspection.Introspect(&acme, token); err != nil || result.Active {
+		t.Errorf("Expected a token of another tenant to be inactive, got %+v, %v", result, err)
+	}
+}
+
+func TestIntrospectClientMapsRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/introspect" || r.Header.Get("X-Tenant-ID") != "acme" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"status":"error","error":"rate limit exceeded"}`))
+	}))
+	defer server.Close()
+
+	client := introspect.New(server.URL, introspect.WithAPIKey("gra_key"), introspect.WithTenant("acme"), introspect.WithPath("/api/introspect"))
+	if _, err := client.Introspect(context.Background(), "token"); !errors.Is(err, introspect.ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+}
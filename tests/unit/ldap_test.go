@@ -0,0 +1,376 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/directory"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+const (
+	stubBaseDN      = "dc=example,dc=com"
+	stubServiceDN   = "cn=svc,ou=services,dc=example,dc=com"
+	stubServicePass = "svc-secret"
+	stubAliceDN     = "uid=alice,ou=people,dc=example,dc=com"
+	stubAlicePass   = "directory-pass"
+	stubAdminsDN    = "cn=admins,ou=groups,dc=example,dc=com"
+	stubOpsDN       = "cn=ops,ou=groups,dc=example,dc=com"
+)
+
+// stubEntry is a directory entry; entries with a password can be bound to
+type stubEntry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// stubDirectory is a minimal in-process LDAP server that answers simple binds and searches
+// with equality, presence, and, or and not filters
+type stubDirectory struct {
+	listener net.Listener
+	mu       sync.Mutex
+	entries  []*stubEntry
+}
+
+func newStubDirectory(t *testing.T, entries ...*stubEntry) *stubDirectory {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	d := &stubDirectory{listener: listener, entries: entries}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	return d
+}
+
+func (d *stubDirectory) URL() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+// update changes the directory while the server is running
+func (d *stubDirectory) update(fn func(entries []*stubEntry)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(d.entries)
+}
+
+func (d *stubDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		id, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			writeLDAPMessage(conn, id, d.bind(op))
+		case ldap.ApplicationSearchRequest:
+			for _, entry := range d.search(op) {
+				writeLDAPMessage(conn, id, entry)
+			}
+			writeLDAPMessage(conn, id, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		default:
+			// Unbind and anything unsupported end the connection
+			return
+		}
+	}
+}
+
+func (d *stubDirectory) bind(op *ber.Packet) *ber.Packet {
+	name := op.Children[1].Data.String()
+	password := op.Children[2].Data.String()
+	if name == "" && password == "" {
+		return ldapResult(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+	}
+	if name == stubServiceDN && password == stubServicePass {
+		return ldapResult(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, entry := range d.entries {
+		if strings.EqualFold(entry.dn, name) && entry.password != "" && entry.password == password {
+			return ldapResult(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+		}
+	}
+	return ldapResult(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials)
+}
+
+func (d *stubDirectory) search(op *ber.Packet) []*ber.Packet {
+	base := strings.ToLower(op.Children[0].Data.String())
+	filter := op.Children[6]
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var results []*ber.Packet
+	for _, entry := range d.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.dn), base) || !matchFilter(filter, entry) {
+			continue
+		}
+
+		result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "DN"))
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for name, values := range entry.attrs {
+			attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+			attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, value := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+			}
+			attribute.AppendChild(set)
+			attributes.AppendChild(attribute)
+		}
+		result.AppendChild(attributes)
+		results = append(results, result)
+	}
+	return results
+}
+
+// matchFilter evaluates the filter subset the authenticator uses
+func matchFilter(filter *ber.Packet, entry *stubEntry) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(child, entry) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(child, entry) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matchFilter(filter.Children[0], entry)
+	case ldap.FilterEqualityMatch:
+		want := filter.Children[1].Data.String()
+		for _, value := range attributeValues(entry, filter.Children[0].Data.String()) {
+			if strings.EqualFold(value, want) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(attributeValues(entry, filter.Data.String())) > 0
+	default:
+		return false
+	}
+}
+
+func attributeValues(entry *stubEntry, name string) []string {
+	for attr, values := range entry.attrs {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func ldapResult(tag ber.Tag, code uint16) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return result
+}
+
+func writeLDAPMessage(w io.Writer, id int64, op *ber.Packet) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Message")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	envelope.AppendChild(op)
+	w.Write(envelope.Bytes())
+}
+
+// sameRoleSet reports whether roles holds exactly the wanted roles, in any order
+func sameRoleSet(roles []string, want ...string) bool {
+	got := append([]string(nil), roles...)
+	sort.Strings(got)
+	sort.Strings(want)
+	return reflect.DeepEqual(got, want)
+}
+
+// newAlice returns the directory entry of a person in the admins group
+func newAlice() *stubEntry {
+	return &stubEntry{
+		dn:       stubAliceDN,
+		password: stubAlicePass,
+		attrs: map[string][]string{
+			"objectClass": {"person", "inetOrgPerson"},
+			"mail":        {"Alice@Example.com"},
+			"givenName":   {"Alice"},
+			"sn":          {"Liddell"},
+			"memberOf":    {stubAdminsDN},
+		},
+	}
+}
+
+// newLDAPUserUseCase wires a user use case that falls back to the stub directory
+func newLDAPUserUseCase(t *testing.T, d *stubDirectory, config directory.LDAPConfig) (*usecase.UserUseCase, user.Repository) {
+	t.Helper()
+	config.URL = d.URL()
+	config.BaseDN = stubBaseDN
+	config.BindDN = stubServiceDN
+	config.BindPassword = stubServicePass
+
+	userRepo := repository.NewInMemoryUserRepository()
+	authenticator, err := directory.NewLDAPAuthenticator(config, userRepo)
+	if err != nil {
+		t.Fatalf("NewLDAPAuthenticator failed: %v", err)
+	}
+	uc := usecase.NewUserUseCase(userRepo, auth.NewPasswordService(testArgonParams), testJWTService(),
+		usecase.WithAuthenticators(authenticator))
+	return uc, userRepo
+}
+
+func TestLDAPLoginProvisionsUser(t *testing.T) {
+	d := newStubDirectory(t, newAlice())
+	uc, userRepo := newLDAPUserUseCase(t, d, directory.LDAPConfig{
+		// Group DNs are matched regardless of case
+		GroupRoles: map[string]string{"CN=Admins,OU=Groups,DC=example,DC=com": "admin"},
+	})
+
+	resp, err := uc.Login(tenant.DefaultID, "alice@example.com", stubAlicePass)
+	if err != nil {
+		t.Fatalf("Login through the directory failed: %v", err)
+	}
+	if claims := mustClaims(t, resp.Token); !reflect.DeepEqual(claims.Roles, []string{"admin"}) {
+		t.Errorf("Expected the admin role from the group, got %v", claims.Roles)
+	}
+
+	u, err := userRepo.FindByEmail(tenant.DefaultID, "alice@example.com")
+	if err != nil {
+		t.Fatalf("Expected the directory user to be provisioned: %v", err)
+	}
+	if u.FirstName != "Alice" || u.LastName != "Liddell" {
+		t.Errorf("Expected the name from the directory, got %q %q", u.FirstName, u.LastName)
+	}
+	if u.Password != "" {
+		t.Error("Expected a provisioned user without a local password")
+	}
+
+	// A second login reuses the account
+	if _, err := uc.Login(tenant.DefaultID, "alice@example.com", stubAlicePass); err != nil {
+		t.Fatalf("Second login failed: %v", err)
+	}
+	users, err := userRepo.FindAll(tenant.DefaultID)
+	if err != nil {
+		t.Fatalf("FindAll failed: %v", err)
+	}
+	if len(users) != 1 {
+		t.Errorf("Expected one provisioned user, got %d", len(users))
+	}
+}
+
+func TestLDAPLoginSyncsRoles(t *testing.T) {
+	d := newStubDirectory(t, newAlice(), &stubEntry{
+		dn:    stubOpsDN,
+		attrs: map[string][]string{"objectClass": {"groupOfNames"}, "member": {stubAliceDN}},
+	})
+	uc, userRepo := newLDAPUserUseCase(t, d, directory.LDAPConfig{
+		GroupBaseDN: "ou=groups," + stubBaseDN,
+		GroupRoles:  map[string]string{stubAdminsDN: "admin", stubOpsDN: "operator"},
+	})
+
+	resp, err := uc.Login(tenant.DefaultID, "alice@example.com", stubAlicePass)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	roles := mustClaims(t, resp.Token).Roles
+	if !sameRoleSet(roles, "admin", "operator") {
+		t.Fatalf("Expected roles from memberOf and the group search, got %v", roles)
+	}
+
+	// Roles granted outside the directory survive; directory roles follow group membership
+	u, _ := userRepo.FindByEmail(tenant.DefaultID, "alice@example.com")
+	u.Roles = append(u.Roles, "support")
+	if err := userRepo.Update(u); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	d.update(func(entries []*stubEntry) {
+		entries[0].attrs["memberOf"] = nil
+	})
+
+	resp, err = uc.Login(tenant.DefaultID, "alice@example.com", stubAlicePass)
+	if err != nil {
+		t.Fatalf("Login after the group change failed: %v", err)
+	}
+	roles = mustClaims(t, resp.Token).Roles
+	if !sameRoleSet(roles, "operator", "support") {
+		t.Errorf("Expected operator and support roles, got %v", roles)
+	}
+}
+
+func TestLDAPLoginRejections(t *testing.T) {
+	d := newStubDirectory(t, newAlice())
+	uc, _ := newLDAPUserUseCase(t, d, directory.LDAPConfig{})
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{"wrong password", "alice@example.com", "not-the-password"},
+		{"unknown email", "bob@example.com", stubAlicePass},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := uc.Login(tenant.DefaultID, tt.email, tt.password); !errors.Is(err, usecase.ErrInvalidCredentials) {
+				t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLDAPLocalUsersStillLogIn(t *testing.T) {
+	d := newStubDirectory(t, newAlice())
+	uc, _ := newLDAPUserUseCase(t, d, directory.LDAPConfig{})
+
+	if _, err := uc.Register(tenant.DefaultID, "Carol", "Local", "carol@example.com", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, err := uc.Login(tenant.DefaultID, "carol@example.com", testPassword); err != nil {
+		t.Errorf("Expected a local password login to succeed: %v", err)
+	}
+
+	// A directory that cannot be reached is reported as unavailable, not as a wrong password
+	d.listener.Close()
+	if _, err := uc.Login(tenant.DefaultID, "alice@example.com", stubAlicePass); !errors.Is(err, usecase.ErrServiceBusy) {
+		t.Errorf("Expected ErrServiceBusy with the directory down, got %v", err)
+	}
+	if _, err := uc.Login(tenant.DefaultID, "carol@example.com", testPassword); err != nil {
+		t.Errorf("Expected local logins to work with the directory down: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// TestLegacyHashesAreVerifiedAndUpgraded verifies imported bcrypt, scrypt and PBKDF2 hashes
+func TestLegacyHashesAreVerifiedAndUpgraded(t *testing.T) {
+	const password = "tV9#qL2!mZ7$"
+	salt := []byte("0123456789abcdef")
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scryptKey, err := scrypt.Key([]byte(password), salt, 1<<10, 8, 1, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scryptHash := fmt.Sprintf("$scrypt$ln=10,r=8,p=1$%s$%s",
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(scryptKey))
+
+	pbkdf2Key := pbkdf2.Key([]byte(password), salt, 1000, 32, sha256.New)
+	adapted := strings.NewReplacer("+", ".")
+	pbkdf2Hash := fmt.Sprintf("$pbkdf2-sha256$1000$%s$%s",
+		adapted.Replace(base64.RawStdEncoding.EncodeToString(salt)), adapted.Replace(base64.RawStdEncoding.EncodeToString(pbkdf2Key)))
+
+	hashes := map[string]string{
+		"bcrypt":        string(bcryptHash),
+		"scrypt":        scryptHash,
+		"pbkdf2-sha256": pbkdf2Hash,
+	}
+
+	for name, hash := range hashes {
+		t.Run(name, func(t *testing.T) {
+			repo := repository.NewInMemoryUserRepository()
+			if err := repo.Save(user.NewUser(tenant.DefaultID, "Ann", "Lee", "ann@example.com", hash)); err != nil {
+				t.Fatal(err)
+			}
+
+			passwordService := auth.NewPasswordService(testArgonParams)
+			uc := usecase.NewUserUseCase(repo, passwordService, testJWTService())
+
+			if !strings.HasPrefix(auth.DescribeHash(hash), name) {
+				t.Errorf("Expected %s label, got %q", name, auth.DescribeHash(hash))
+			}
+			if _, err := uc.Login(tenant.DefaultID, "ann@example.com", "wrong password"); err != usecase.ErrInvalidCredentials {
+				t.Errorf("Expected invalid credentials, got %v", err)
+			}
+			if _, err := uc.Login(tenant.DefaultID, "ann@example.com", password); err != nil {
+				t.Fatalf("Login with legacy hash failed: %v", err)
+			}
+
+			stored, _ := repo.FindByEmail(tenant.DefaultID, "ann@example.com")
+			if !strings.HasPrefix(stored.Password, "$argon2id$") || passwordService.NeedsRehash(stored.Password) {
+				t.Errorf("Expected hash to be upgraded to Argon2id, got %s", stored.Password)
+			}
+		})
+	}
+}
+
+// TestUnsupportedHashIsRejected verifies unknown hash formats never verify
+func TestUnsupportedHashIsRejected(t *testing.T) {
+	passwordService := auth.NewPasswordService(testArgonParams)
+
+	valid, err := passwordService.VerifyPassword("$md5$abc$def", "anything")
+	if valid || err != auth.ErrUnsupportedHash {
+		t.Errorf("Expected ErrUnsupportedHash, got %v, %v", valid, err)
+	}
+}
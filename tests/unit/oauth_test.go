@@ -0,0 +1,489 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/oauth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+const (
+	oauthTestIssuer   = "https://auth.example.com"
+	oauthTestRedirect = "https://app.example.com/callback"
+	oauthTestVerifier = "dBjftJeZ4CVP-mJ92K9xfdcB4e1g8PUx5LbSkSWqjOmAcA7eXVyqvSxxK8"
+)
+
+// oauthServer wires the authorization server endpoints around in-memory stores
+type oauthServer struct {
+	*testServer
+}
+
+func newOAuthServer(t *testing.T) *oauthServer {
+	t.Helper()
+	s := &oauthServer{testServer: newTestServer(t)}
+	oidcUseCase := usecase.NewOIDCUseCase(s.clients, s.userRepo, s.keys, oauthTestIssuer)
+	oauthHandler := handler.NewOAuthHandler(s.oauth)
+	cookie := handler.DefaultSessionCookieConfig()
+	oidcHandler := handler.NewOIDCHandler(oidcUseCase, s.sessions, cookie)
+
+	s.mux.Handle("GET /profile", s.protect(handler.NewProtectedHandler().Profile))
+	s.mux.Handle("GET /oauth/authorize", s.protect(oauthHandler.Authorize))
+	s.mux.Handle("POST /oauth/authorize", s.protect(oauthHandler.Authorize))
+	s.mux.HandleFunc("POST /oauth/token", oauthHandler.Token)
+	s.mux.HandleFunc("POST /oauth/introspect", oauthHandler.Introspect)
+	s.mux.HandleFunc("POST /oauth/revoke", oauthHandler.Revoke)
+	s.mux.Handle("GET /oauth/clients", s.protect(oauthHandler.Clients))
+	s.mux.Handle("POST /oauth/clients", s.protect(oauthHandler.Clients))
+	s.mux.Handle("GET /oauth/consents", s.protect(oauthHandler.Consents))
+	s.mux.Handle("DELETE /oauth/consents/{client_id}", s.protect(oauthHandler.RevokeConsent))
+	s.mux.HandleFunc("GET "+handler.OIDCDiscoveryPath, oidcHandler.Discovery)
+	s.mux.HandleFunc("GET "+handler.OIDCJWKSPath, oidcHandler.JWKS)
+	s.mux.Handle("GET "+handler.OIDCUserInfoPath, s.protect(oidcHandler.UserInfo))
+	s.mux.Handle("POST "+handler.OIDCUserInfoPath, s.protect(oidcHandler.UserInfo))
+	s.mux.HandleFunc("GET "+handler.OIDCLogoutPath, oidcHandler.Logout)
+	s.mux.HandleFunc("POST "+handler.OIDCLogoutPath, oidcHandler.Logout)
+	s.mux.HandleFunc("POST /session/login", handler.NewSessionHandler(s.sessions, cookie).Login)
+	return s
+}
+
+// registerClient registers a client as an admin
+func (s *oauthServer) registerClient(t *testing.T, adminToken string, req handler.RegisterClientRequest) handler.RegisteredClientDTO {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest(http.MethodPost, "/oauth/clients", bytes.NewReader(body))
+	httpReq.Header.Set("Authorization", "Bearer "+adminToken)
+	rec := s.serve(httpReq)
+	assertStatus(t, rec.Code, http.StatusCreated, "register client: expected status %d, got %d")
+
+	var resp struct {
+		Data handler.RegisteredClientDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp.Data
+}
+
+// authorize sends an authorization request as a signed-in user
+func (s *oauthServer) authorize(method, userToken string, params url.Values) *httptest.ResponseRecorder {
+	var req *http.Request
+	if method == http.MethodGet {
+		req = httptest.NewRequest(method, "/oauth/authorize?"+params.Encode(), nil)
+	} else {
+		req = httptest.NewRequest(method, "/oauth/authorize", strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("Authorization", "Bearer "+userToken)
+	return s.serve(req)
+}
+
+// postForm sends a form to a client-authenticated endpoint using HTTP Basic client credentials
+func (s *oauthServer) postForm(path string, client handler.RegisteredClientDTO, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if client.Secret != "" {
+		req.SetBasicAuth(url.QueryEscape(client.ID), url.QueryEscape(client.Secret))
+	} else {
+		form.Set("client_id", client.ID)
+		req = httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return s.serve(req)
+}
+
+// token calls the token endpoint and decodes a success or error response
+func (s *oauthServer) token(t *testing.T, client handler.RegisteredClientDTO, form url.Values) (handler.TokenResponseDTO, handler.OAuthErrorDTO, int) {
+	t.Helper()
+	rec := s.postForm("/oauth/token", client, form)
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Error("Token responses must not be cached")
+	}
+
+	var tokens handler.TokenResponseDTO
+	var oauthErr handler.OAuthErrorDTO
+	json.Unmarshal(rec.Body.Bytes(), &tokens)
+	json.Unmarshal(rec.Body.Bytes(), &oauthErr)
+	return tokens, oauthErr, rec.Code
+}
+
+// introspect returns the introspection response for a token
+func (s *oauthServer) introspect(t *testing.T, client handler.RegisteredClientDTO, token string) handler.IntrospectionDTO {
+	t.Helper()
+	rec := s.postForm("/oauth/introspect", client, url.Values{"token": {token}})
+	assertStatus(t, rec.Code, http.StatusOK, "introspect: expected status %d, got %d")
+
+	var resp handler.IntrospectionDTO
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp
+}
+
+// codeParams returns authorization request parameters with an S256 challenge for oauthTestVerifier
+func codeParams(clientID string) url.Values {
+	sum := sha256.Sum256([]byte(oauthTestVerifier))
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {oauthTestRedirect},
+		"scope":                 {"profile"},
+		"state":                 {"xyz"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// redirectParams parses the query of a redirect back to the client
+func redirectParams(t *testing.T, location string) url.Values {
+	t.Helper()
+	u, err := url.Parse(location)
+	if err != nil || !strings.HasPrefix(location, oauthTestRedirect) {
+		t.Fatalf("Unexpected redirect %q", location)
+	}
+	return u.Query()
+}
+
+// TestOAuthAuthorizationCodeFlow runs the authorization code flow with PKCE, consent, refresh
+// token rotation, introspection and revocation
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	s := newOAuthServer(t)
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+	annToken := s.login(t, "ann@example.com")
+
+	client := s.registerClient(t, adminToken, handler.RegisterClientRequest{
+		Name:         "Orders App",
+		RedirectURIs: []string{oauthTestRedirect},
+		Scopes:       []string{"profile", "orders:read"},
+	})
+	if client.Secret == "" || client.Type != "confidential" {
+		t.Fatalf("Expected a confidential client with a secret, got %+v", client)
+	}
+
+	// First authorization asks for consent
+	rec := s.authorize(http.MethodGet, annToken, codeParams(client.ID))
+	assertStatus(t, rec.Code, http.StatusOK, "consent prompt: expected status %d, got %d")
+	if !strings.Contains(rec.Body.String(), "Orders App") {
+		t.Errorf("Consent prompt should name the client: %s", rec.Body.String())
+	}
+
+	approve := codeParams(client.ID)
+	approve.Set("decision", "approve")
+	rec = s.authorize(http.MethodPost, annToken, approve)
+	assertStatus(t, rec.Code, http.StatusOK, "approve consent: expected status %d, got %d")
+	var approved struct {
+		Data handler.AuthorizeRedirectDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &approved)
+	params := redirectParams(t, approved.Data.RedirectURL)
+	if params.Get("state") != "xyz" || params.Get("iss") != oauthTestIssuer || params.Get("code") == "" {
+		t.Fatalf("Unexpected authorization response %v", params)
+	}
+	code := params.Get("code")
+
+	exchange := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {oauthTestRedirect},
+		"code_verifier": {strings.Repeat("x", 43)},
+	}
+	_, oauthErr, status := s.token(t, client, exchange)
+	if status != http.StatusBadRequest || oauthErr.Error != usecase.OAuthInvalidGrant {
+		t.Fatalf("Expected invalid_grant for a wrong verifier, got %d %+v", status, oauthErr)
+	}
+
+	exchange.Set("code_verifier", oauthTestVerifier)
+	tokens, oauthErr, status := s.token(t, client, exchange)
+	assertStatus(t, status, http.StatusOK, "exchange code: expected status %d, got %d")
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.Scope != "profile" || tokens.TokenType != "Bearer" {
+		t.Fatalf("Unexpected token response %+v", tokens)
+	}
+
+	info := s.introspect(t, client, tokens.AccessToken)
+	if !info.Active || info.Scope != "profile" || info.ClientID != client.ID || info.Username != "ann@example.com" || info.Iss != oauthTestIssuer {
+		t.Errorf("Unexpected introspection %+v", info)
+	}
+
+	// The access token works against protected endpoints but carries only the granted scopes
+	claims := mustClaims(t, tokens.AccessToken)
+	principal := auth.NewPrincipalFromClaims(claims)
+	if principal.AuthMethod != auth.AuthMethodOAuth || principal.HasScope("orders:read") || !principal.HasScope("profile") {
+		t.Errorf("Unexpected principal for access token %+v", principal)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	assertStatus(t, s.serve(req).Code, http.StatusOK, "profile with access token: expected status %d, got %d")
+
+	// Refresh tokens rotate; replaying a rotated token revokes the family
+	rotated, oauthErr, status := s.token(t, client, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}})
+	assertStatus(t, status, http.StatusOK, "refresh: expected status %d, got %d")
+	if rotated.RefreshToken == "" || rotated.RefreshToken == tokens.RefreshToken {
+		t.Fatal("Expected a new refresh token")
+	}
+	_, oauthErr, _ = s.token(t, client, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}})
+	if oauthErr.Error != usecase.OAuthInvalidGrant {
+		t.Errorf("Expected invalid_grant for a replayed refresh token, got %+v", oauthErr)
+	}
+	if s.introspect(t, client, rotated.RefreshToken).Active {
+		t.Error("Replaying a refresh token should revoke its successors")
+	}
+
+	// Codes are single use
+	_, oauthErr, _ = s.token(t, client, exchange)
+	if oauthErr.Error != usecase.OAuthInvalidGrant {
+		t.Errorf("Expected invalid_grant for a replayed code, got %+v", oauthErr)
+	}
+
+	// Revoking the access token takes effect before it expires
+	rec = s.postForm("/oauth/revoke", client, url.Values{"token": {tokens.AccessToken}})
+	assertStatus(t, rec.Code, http.StatusOK, "revoke access token: expected status %d, got %d")
+	if s.introspect(t, client, tokens.AccessToken).Active {
+		t.Error("Revoked access token should be inactive")
+	}
+	assertStatus(t, s.serve(req).Code, http.StatusUnauthorized, "profile with revoked access token: expected status %d, got %d")
+
+	// With consent stored, the next authorization redirects immediately
+	rec = s.authorize(http.MethodGet, annToken, codeParams(client.ID))
+	assertStatus(t, rec.Code, http.StatusFound, "authorize with stored consent: expected status %d, got %d")
+	if redirectParams(t, rec.Header().Get("Location")).Get("code") == "" {
+		t.Error("Expected a code in the redirect")
+	}
+}
+
+// TestOAuthAuthorizationErrors verifies which errors are redirected and which are shown to the user
+func TestOAuthAuthorizationErrors(t *testing.T) {
+	s := newOAuthServer(t)
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+	annToken := s.login(t, "ann@example.com")
+
+	public := s.registerClient(t, adminToken, handler.RegisterClientRequest{
+		Name:         "SPA",
+		Type:         "public",
+		RedirectURIs: []string{oauthTestRedirect},
+		Scopes:       []string{"profile"},
+	})
+	if public.Secret != "" {
+		t.Error("Public clients must not get a secret")
+	}
+
+	params := codeParams(public.ID)
+	params.Set("redirect_uri", "https://evil.example.com/callback")
+	rec := s.authorize(http.MethodGet, annToken, params)
+	assertStatus(t, rec.Code, http.StatusBadRequest, "unregistered redirect URI: expected status %d, got %d")
+
+	params = codeParams(public.ID)
+	params.Del("code_challenge")
+	rec = s.authorize(http.MethodGet, annToken, params)
+	assertStatus(t, rec.Code, http.StatusFound, "missing PKCE challenge: expected status %d, got %d")
+	if redirectParams(t, rec.Header().Get("Location")).Get("error") != usecase.OAuthInvalidRequest {
+		t.Errorf("Expected invalid_request redirect, got %s", rec.Header().Get("Location"))
+	}
+
+	params = codeParams(public.ID)
+	params.Set("scope", "admin")
+	rec = s.authorize(http.MethodGet, annToken, params)
+	if redirectParams(t, rec.Header().Get("Location")).Get("error") != usecase.OAuthInvalidScope {
+		t.Errorf("Expected invalid_scope redirect, got %s", rec.Header().Get("Location"))
+	}
+
+	deny := codeParams(public.ID)
+	deny.Set("decision", "deny")
+	rec = s.authorize(http.MethodPost, annToken, deny)
+	if !strings.Contains(rec.Body.String(), "error=access_denied") {
+		t.Errorf("Expected access_denied redirect, got %s", rec.Body.String())
+	}
+
+	// Public clients authenticate by client_id alone and still complete the PKCE flow
+	approve := codeParams(public.ID)
+	approve.Set("decision", "approve")
+	rec = s.authorize(http.MethodPost, annToken, approve)
+	var approved struct {
+		Data handler.AuthorizeRedirectDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &approved)
+	code := redirectParams(t, approved.Data.RedirectURL).Get("code")
+	tokens, _, status := s.token(t, public, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {oauthTestRedirect},
+		"code_verifier": {oauthTestVerifier},
+	})
+	assertStatus(t, status, http.StatusOK, "public client exchange: expected status %d, got %d")
+
+	// Access tokens are scoped credentials and cannot approve authorization requests on the user's behalf
+	rec = s.authorize(http.MethodPost, tokens.AccessToken, approve)
+	assertStatus(t, rec.Code, http.StatusForbidden, "authorize with access token: expected status %d, got %d")
+
+	rec = s.authorize(http.MethodGet, adminToken, url.Values{"client_id": {"unknown"}})
+	assertStatus(t, rec.Code, http.StatusBadRequest, "unknown client: expected status %d, got %d")
+}
+
+// TestOAuthClientCredentialsAndConsents covers machine clients, client authentication, registration
+// permissions and consent revocation
+func TestOAuthClientCredentialsAndConsents(t *testing.T) {
+	s := newOAuthServer(t)
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+	annToken := s.login(t, "ann@example.com")
+
+	body, _ := json.Marshal(handler.RegisterClientRequest{Name: "x", RedirectURIs: []string{oauthTestRedirect}, Scopes: []string{"profile"}})
+	req := httptest.NewRequest(http.MethodPost, "/oauth/clients", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+annToken)
+	assertStatus(t, s.serve(req).Code, http.StatusForbidden, "register client without admin role: expected status %d, got %d")
+
+	body, _ = json.Marshal(handler.RegisterClientRequest{Name: "x", Type: "public", GrantTypes: []string{oauth.GrantClientCredentials}, Scopes: []string{"profile"}})
+	req = httptest.NewRequest(http.MethodPost, "/oauth/clients", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	assertStatus(t, s.serve(req).Code, http.StatusBadRequest, "public client with client credentials: expected status %d, got %d")
+
+	machine := s.registerClient(t, adminToken, handler.RegisterClientRequest{
+		Name:       "Billing Job",
+		GrantTypes: []string{oauth.GrantClientCredentials},
+		Scopes:     []string{"orders:read", "orders:write"},
+	})
+
+	tokens, oauthErr, status := s.token(t, machine, url.Values{"grant_type": {"client_credentials"}, "scope": {"orders:read"}})
+	assertStatus(t, status, http.StatusOK, "client credentials: expected status %d, got %d")
+	if tokens.RefreshToken != "" || tokens.Scope != "orders:read" {
+		t.Errorf("Unexpected client credentials response %+v", tokens)
+	}
+	principal := auth.NewPrincipalFromClaims(mustClaims(t, tokens.AccessToken))
+	if principal.Type != auth.PrincipalService || principal.Subject != machine.ID {
+		t.Errorf("Client credentials token should act as the client, got %+v", principal)
+	}
+	if info := s.introspect(t, machine, tokens.AccessToken); !info.Active || info.Sub != machine.ID {
+		t.Errorf("Unexpected introspection %+v", info)
+	}
+
+	wrongSecret := machine
+	wrongSecret.Secret = "wrong"
+	_, oauthErr, status = s.token(t, wrongSecret, url.Values{"grant_type": {"client_credentials"}})
+	if status != http.StatusUnauthorized || oauthErr.Error != usecase.OAuthInvalidClient {
+		t.Errorf("Expected 401 invalid_client, got %d %+v", status, oauthErr)
+	}
+	_, oauthErr, _ = s.token(t, machine, url.Values{"grant_type": {"password"}})
+	if oauthErr.Error != usecase.OAuthUnsupportedGrantType {
+		t.Errorf("Expected unsupported_grant_type, got %+v", oauthErr)
+	}
+
+	// Withdrawing consent revokes the client's refresh tokens
+	app := s.registerClient(t, adminToken, handler.RegisterClientRequest{
+		Name:         "Orders App",
+		RedirectURIs: []string{oauthTestRedirect},
+		Scopes:       []string{"profile"},
+	})
+	approve := codeParams(app.ID)
+	approve.Set("decision", "approve")
+	rec := s.authorize(http.MethodPost, annToken, approve)
+	var approved struct {
+		Data handler.AuthorizeRedirectDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &approved)
+	tokens, _, _ = s.token(t, app, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {redirectParams(t, approved.Data.RedirectURL).Get("code")},
+		"redirect_uri":  {oauthTestRedirect},
+		"code_verifier": {oauthTestVerifier},
+	})
+
+	req = httptest.NewRequest(http.MethodGet, "/oauth/consents", nil)
+	req.Header.Set("Authorization", "Bearer "+annToken)
+	rec = s.serve(req)
+	if !strings.Contains(rec.Body.String(), app.ID) {
+		t.Errorf("Expected consent for the client, got %s", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/oauth/consents/"+app.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+annToken)
+	assertStatus(t, s.serve(req).Code, http.StatusOK, "revoke consent: expected status %d, got %d")
+	if s.introspect(t, app, tokens.RefreshToken).Active {
+		t.Error("Revoking consent should revoke refresh tokens")
+	}
+}
+
+// approvedCode approves an authorization request and returns the code it redirects with
+func (s *oauthServer) approvedCode(t *testing.T, client handler.RegisteredClientDTO, userToken string, params url.Values) string {
+	t.Helper()
+	params.Set("decision", "approve")
+	rec := s.authorize(http.MethodPost, userToken, params)
+	assertStatus(t, rec.Code, http.StatusOK, "approve consent: expected status %d, got %d")
+
+	var approved struct {
+		Data handler.AuthorizeRedirectDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &approved)
+	return redirectParams(t, approved.Data.RedirectURL).Get("code")
+}
+
+// TestOAuthCodeExchangeRules verifies the token request has to repeat the redirect_uri of the
+// authorization request, and that a code is exchanged once even by concurrent requests
+func TestOAuthCodeExchangeRules(t *testing.T) {
+	s := newOAuthServer(t)
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+	annToken := s.login(t, "ann@example.com")
+	client := s.registerClient(t, adminToken, handler.RegisterClientRequest{
+		Name:         "Orders App",
+		RedirectURIs: []string{oauthTestRedirect},
+		Scopes:       []string{"profile"},
+	})
+
+	exchange := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {s.approvedCode(t, client, annToken, codeParams(client.ID))},
+		"code_verifier": {oauthTestVerifier},
+	}
+	if _, oauthErr, _ := s.token(t, client, exchange); oauthErr.Error != usecase.OAuthInvalidGrant {
+		t.Errorf("Expected invalid_grant without the requested redirect_uri, got %+v", oauthErr)
+	}
+	exchange.Set("redirect_uri", oauthTestRedirect)
+	if _, _, status := s.token(t, client, exchange); status != http.StatusOK {
+		t.Errorf("Expected the exchange with the redirect_uri to succeed, got %d", status)
+	}
+
+	// Without a redirect_uri in the authorization request the registered one is implied
+	params := codeParams(client.ID)
+	params.Del("redirect_uri")
+	exchange = url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {s.approvedCode(t, client, annToken, params)},
+		"code_verifier": {oauthTestVerifier},
+	}
+	if _, _, status := s.token(t, client, exchange); status != http.StatusOK {
+		t.Errorf("Expected the exchange without a redirect_uri to succeed, got %d", status)
+	}
+
+	exchange = url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {s.approvedCode(t, client, annToken, codeParams(client.ID))},
+		"redirect_uri":  {oauthTestRedirect},
+		"code_verifier": {oauthTestVerifier},
+	}
+	const attempts = 8
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- s.postForm("/oauth/token", client, exchange).Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	succeeded := 0
+	for status := range statuses {
+		if status == http.StatusOK {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one of %d concurrent exchanges to succeed, got %d", attempts, succeeded)
+	}
+}
@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+const oidcTestLogoutRedirect = "https://app.example.com/signed-out"
+
+// openIDTokens runs the authorization code flow for the given scope and nonce and returns the tokens
+func (s *oauthServer) openIDTokens(t *testing.T, client handler.RegisteredClientDTO, userToken, scope, nonce string) handler.TokenResponseDTO {
+	t.Helper()
+	params := codeParams(client.ID)
+	params.Set("scope", scope)
+	params.Set("nonce", nonce)
+	params.Set("decision", "approve")
+	rec := s.authorize(http.MethodPost, userToken, params)
+	assertStatus(t, rec.Code, http.StatusOK, "approve consent: expected status %d, got %d")
+
+	var approved struct {
+		Data handler.AuthorizeRedirectDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &approved)
+	tokens, oauthErr, status := s.token(t, client, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {redirectParams(t, approved.Data.RedirectURL).Get("code")},
+		"redirect_uri":  {oauthTestRedirect},
+		"code_verifier": {oauthTestVerifier},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("Code exchange failed: %d %+v", status, oauthErr)
+	}
+	return tokens
+}
+
+// userInfo calls the userinfo endpoint with an access token
+func (s *oauthServer) userInfo(accessToken string) (map[string]interface{}, int) {
+	req := httptest.NewRequest(http.MethodGet, handler.OIDCUserInfoPath, nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rec := s.serve(req)
+
+	var claims map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &claims)
+	return claims, rec.Code
+}
+
+// registerOIDCClient registers a confidential client allowed the OpenID Connect scopes
+func (s *oauthServer) registerOIDCClient(t *testing.T, adminToken string) handler.RegisteredClientDTO {
+	t.Helper()
+	return s.registerClient(t, adminToken, handler.RegisterClientRequest{
+		Name:                   "Portal",
+		RedirectURIs:           []string{oauthTestRedirect},
+		PostLogoutRedirectURIs: []string{oidcTestLogoutRedirect},
+		Scopes:                 []string{"openid", "profile", "email", "orders:read"},
+	})
+}
+
+// TestOIDCDiscoveryAndIDTokens verifies the provider metadata and that ID tokens verify against the
+// published key set and carry the nonce and scoped claims
+func TestOIDCDiscoveryAndIDTokens(t *testing.T) {
+	s := newOAuthServer(t)
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+	annToken := s.login(t, "ann@example.com")
+	client := s.registerOIDCClient(t, adminToken)
+
+	rec := s.serve(httptest.NewRequest(http.MethodGet, handler.OIDCDiscoveryPath, nil))
+	assertStatus(t, rec.Code, http.StatusOK, "discovery: expected status %d, got %d")
+	var discovery handler.DiscoveryDTO
+	json.Unmarshal(rec.Body.Bytes(), &discovery)
+	if discovery.Issuer != oauthTestIssuer || discovery.JWKSURI != oauthTestIssuer+handler.OIDCJWKSPath ||
+		discovery.UserInfoEndpoint != oauthTestIssuer+handler.OIDCUserInfoPath || discovery.EndSessionEndpoint != oauthTestIssuer+handler.OIDCLogoutPath {
+		t.Errorf("Unexpected discovery document %+v", discovery)
+	}
+
+	rec = s.serve(httptest.NewRequest(http.MethodGet, handler.OIDCJWKSPath, nil))
+	assertStatus(t, rec.Code, http.StatusOK, "jwks: expected status %d, got %d")
+	var jwks auth.JSONWebKeySet
+	json.Unmarshal(rec.Body.Bytes(), &jwks)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Algorithm != "RS256" {
+		t.Fatalf("Unexpected key set %+v", jwks)
+	}
+	publicKey, err := jwks.Keys[0].PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+
+	tokens := s.openIDTokens(t, client, annToken, "openid profile", "n-0S6_WzA2Mj")
+	if tokens.IDToken == "" {
+		t.Fatal("Expected an ID token for the openid scope")
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokens.IDToken, claims, func(*jwt.Token) (interface{}, error) {
+		return publicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(oauthTestIssuer), jwt.WithAudience(client.ID))
+	if err != nil {
+		t.Fatalf("ID token does not verify against the JWKS: %v", err)
+	}
+	if token.Header["kid"] != jwks.Keys[0].KeyID {
+		t.Errorf("Expected kid %q, got %v", jwks.Keys[0].KeyID, token.Header["kid"])
+	}
+	u, _ := s.userRepo.FindByEmail(tenant.DefaultID, "ann@example.com")
+	if claims["sub"] != u.ID || claims["nonce"] != "n-0S6_WzA2Mj" || claims["given_name"] != "Ann" {
+		t.Errorf("Unexpected ID token claims %v", claims)
+	}
+	if _, ok := claims["email"]; ok {
+		t.Error("The email claim requires the email scope")
+	}
+
+	// ID tokens have a lifetime of their own, not the access token's
+	config := usecase.DefaultOAuthConfig(oauthTestIssuer)
+	issuedAt, _ := claims.GetIssuedAt()
+	expiresAt, _ := claims.GetExpirationTime()
+	if issuedAt == nil || expiresAt == nil || expiresAt.Sub(issuedAt.Time) != config.IDTokenTTL || config.IDTokenTTL == config.AccessTokenTTL {
+		t.Errorf("Expected the ID token to expire after %s, got iat %v exp %v", config.IDTokenTTL, issuedAt, expiresAt)
+	}
+
+	// Without openid the client gets a plain OAuth response
+	plain := s.openIDTokens(t, client, annToken, "orders:read", "")
+	if plain.IDToken != "" {
+		t.Error("ID tokens are only issued for the openid scope")
+	}
+}
+
+// TestOIDCUserInfo verifies userinfo releases only the claims the access token's scopes allow
+func TestOIDCUserInfo(t *testing.T) {
+	s := newOAuthServer(t)
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+	annToken := s.login(t, "ann@example.com")
+	client := s.registerOIDCClient(t, adminToken)
+	u, _ := s.userRepo.FindByEmail(tenant.DefaultID, "ann@example.com")
+
+	tokens := s.openIDTokens(t, client, annToken, "openid email", "")
+	claims, status := s.userInfo(tokens.AccessToken)
+	assertStatus(t, status, http.StatusOK, "userinfo: expected status %d, got %d")
+	if claims["sub"] != u.ID || claims["email"] != "ann@example.com" || claims["email_verified"] != false {
+		t.Errorf("Unexpected userinfo claims %v", claims)
+	}
+	if _, ok := claims["name"]; ok {
+		t.Error("The name claim requires the profile scope")
+	}
+
+	tokens = s.openIDTokens(t, client, annToken, "orders:read", "")
+	_, status = s.userInfo(tokens.AccessToken)
+	assertStatus(t, status, http.StatusForbidden, "userinfo without openid: expected status %d, got %d")
+
+	_, status = s.userInfo("invalid")
+	assertStatus(t, status, http.StatusUnauthorized, "userinfo with invalid token: expected status %d, got %d")
+}
+
+// TestOIDCLogout verifies RP-initiated logout ends the user's browser session and only redirects to
+// registered post-logout URIs
+func TestOIDCLogout(t *testing.T) {
+	s := newOAuthServer(t)
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+	annToken := s.login(t, "ann@example.com")
+	client := s.registerOIDCClient(t, adminToken)
+	tokens := s.openIDTokens(t, client, annToken, "openid", "")
+
+	body, _ := json.Marshal(handler.LoginRequest{Email: "ann@example.com", Password: testPassword})
+	rec := s.serve(httptest.NewRequest(http.MethodPost, "/session/login", bytes.NewReader(body)))
+	assertStatus(t, rec.Code, http.StatusOK, "session login: expected status %d, got %d")
+	sessionCookie := rec.Result().Cookies()[0]
+
+	logout := func(params url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, handler.OIDCLogoutPath+"?"+params.Encode(), nil)
+		req.AddCookie(sessionCookie)
+		return s.serve(req)
+	}
+
+	rec = logout(url.Values{"post_logout_redirect_uri": {oidcTestLogoutRedirect}})
+	assertStatus(t, rec.Code, http.StatusBadRequest, "logout without id_token_hint: expected status %d, got %d")
+
+	rec = logout(url.Values{"id_token_hint": {tokens.IDToken}, "post_logout_redirect_uri": {"https://evil.example.com/"}})
+	assertStatus(t, rec.Code, http.StatusBadRequest, "logout to unregistered URI: expected status %d, got %d")
+
+	rec = logout(url.Values{"id_token_hint": {tokens.AccessToken}})
+	assertStatus(t, rec.Code, http.StatusBadRequest, "logout with access token as hint: expected status %d, got %d")
+
+	if _, err := s.sessions.Authenticate(tenant.DefaultID, sessionCookie.Value); err != nil {
+		t.Fatalf("Rejected logout requests must not end the session: %v", err)
+	}
+
+	rec = logout(url.Values{"id_token_hint": {tokens.IDToken}, "post_logout_redirect_uri": {oidcTestLogoutRedirect}, "state": {"abc"}})
+	assertStatus(t, rec.Code, http.StatusFound, "logout: expected status %d, got %d")
+	if location := rec.Header().Get("Location"); location != oidcTestLogoutRedirect+"?state=abc" {
+		t.Errorf("Unexpected logout redirect %q", location)
+	}
+	if _, err := s.sessions.Authenticate(tenant.DefaultID, sessionCookie.Value); err != usecase.ErrInvalidSession {
+		t.Errorf("Expected the session to be ended, got %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/openapi"
+)
+
+// routeConstants are the path constants the servers register routes with
+var routeConstants = map[string]string{
+	"handler.SCIMBasePath":      handler.SCIMBasePath,
+	"handler.OIDCDiscoveryPath": handler.OIDCDiscoveryPath,
+	"handler.OIDCJWKSPath":      handler.OIDCJWKSPath,
+	"handler.OIDCUserInfoPath":  handler.OIDCUserInfoPath,
+	"handler.OIDCLogoutPath":    handler.OIDCLogoutPath,
+	"openapi.SpecPath":          openapi.SpecPath,
+	"openapi.DocsPath":          openapi.DocsPath,
+	"http.MethodPatch":          http.MethodPatch,
+}
+
+// undocumentedRoutes are operational endpoints left out of the API description
+var undocumentedRoutes = map[string]bool{"/debug/vars": true}
+
+var pathParameterPattern = regexp.MustCompile(`\{[^}]*\}|:[a-z_]+`)
+
+// normalizePath replaces path parameters with {*}, as registered routes and the document name
+// them differently
+func normalizePath(path string) string {
+	return pathParameterPattern.ReplaceAllString(path, "{*}")
+}
+
+// evalPath evaluates a path expression of a route registration; values only known at runtime,
+// such as configured provider names, become parameters
+func evalPath(t *testing.T, expr ast.Expr) string {
+	t.Helper()
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		s, err := strconv.Unquote(e.Value)
+		if err != nil {
+			t.Fatalf("Invalid string literal %s: %v", e.Value, err)
+		}
+		return s
+	case *ast.SelectorExpr:
+		if pkg, ok := e.X.(*ast.Ident); ok {
+			if value, ok := routeConstants[pkg.Name+"."+e.Sel.Name]; ok {
+				return value
+			}
+			if pkg.Name == "handler" || pkg.Name == "openapi" {
+				t.Fatalf("Unknown path constant %s.%s; add it to routeConstants", pkg.Name, e.Sel.Name)
+			}
+		}
+	case *ast.BinaryExpr:
+		if e.Op == token.ADD {
+			return evalPath(t, e.X) + evalPath(t, e.Y)
+		}
+	}
+	return "{*}"
+}
+
+// registeredRoutes parses a main package and returns the method and path of the routes it
+// registers with the gra router or with ServeMux patterns
+func registeredRoutes(t *testing.T, file string) map[string]bool {
+	t.Helper()
+	parsed, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", file, err)
+	}
+
+	routes := map[string]bool{}
+	ast.Inspect(parsed, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		receiver, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+
+		var method, path string
+		switch {
+		case receiver.Name == "http" && (sel.Sel.Name == "Handle" || sel.Sel.Name == "HandleFunc"):
+			var ok bool
+			if method, path, ok = strings.Cut(evalPath(t, call.Args[0]), " "); !ok {
+				t.Errorf("Pattern %s is registered without a method", method)
+				return true
+			}
+		case receiver.Name == "r" && sel.Sel.Name == "Handle":
+			method, path = evalPath(t, call.Args[0]), evalPath(t, call.Args[1])
+		case receiver.Name == "r" && (sel.Sel.Name == "GET" || sel.Sel.Name == "POST" || sel.Sel.Name == "PUT" || sel.Sel.Name == "DELETE"):
+			method, path = sel.Sel.Name, evalPath(t, call.Args[0])
+		default:
+			return true
+		}
+		if !undocumentedRoutes[path] {
+			routes[method+" "+normalizePath(path)] = true
+		}
+		return true
+	})
+	if len(routes) == 0 {
+		t.Fatalf("Found no routes in %s", file)
+	}
+	return routes
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compareRoutes reports routes that are registered but not documented in the document served
+// under prefix, and operations that are documented but not registered
+func compareRoutes(t *testing.T, routes map[string]bool, prefix string) {
+	t.Helper()
+	documented := map[string]bool{}
+	for _, op := range handler.NewOpenAPIDocument(prefix).Operations() {
+		documented[op.Method+" "+normalizePath(op.Path)] = true
+	}
+
+	for _, route := range sortedKeys(routes) {
+		if !documented[route] {
+			t.Errorf("Route %s is registered but not documented", route)
+		}
+	}
+	for _, op := range sortedKeys(documented) {
+		if !routes[op] {
+			t.Errorf("Operation %s is documented but not registered", op)
+		}
+	}
+}
+
+// TestOpenAPIMatchesGraRoutes compares the methods and paths of the gra server with its document
+func TestOpenAPIMatchesGraRoutes(t *testing.T) {
+	compareRoutes(t, registeredRoutes(t, "../../cmd/core-api/main.go"), "/api")
+}
+
+// TestOpenAPIMatchesServeMuxRoutes compares the method patterns of cmd/api with its document
+func TestOpenAPIMatchesServeMuxRoutes(t *testing.T) {
+	compareRoutes(t, registeredRoutes(t, "../../cmd/api/main.go"), "")
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle(openapi.SpecPath, handler.NewOpenAPIDocument("/api"))
+	mux.Handle(openapi.DocsPath, openapi.DocsHandler())
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, openapi.SpecPath, nil))
+	assertStatus(t, rec.Code, http.StatusOK, "openapi.json: expected status %d, got %d")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a JSON document, got %q", ct)
+	}
+
+	type schema struct {
+		Ref        string            `json:"$ref"`
+		Type       string            `json:"type"`
+		Format     string            `json:"format"`
+		Properties map[string]schema `json:"properties"`
+		Required   []string          `json:"required"`
+		AllOf      []schema          `json:"allOf"`
+		Items      *schema           `json:"items"`
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Security    []map[string][]string `json:"security"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema schema `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema schema `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to decode the document: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("Expected OpenAPI 3.1.0, got %q", doc.OpenAPI)
+	}
+
+	// Schemas come from the DTOs, with fields marshalled without omitempty required
+	register := doc.Paths["/register"]["post"]
+	if ref := register.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/RegisterUserRequest" {
+		t.Errorf("Expected /register to take a RegisterUserRequest, got %q", ref)
+	}
+	registerSchema := doc.Components.Schemas["RegisterUserRequest"]
+	for _, field := range []string{"first_name", "last_name", "email", "password"} {
+		if registerSchema.Properties[field].Type != "string" {
+			t.Errorf("Expected RegisterUserRequest.%s to be a string, got %+v", field, registerSchema.Properties[field])
+		}
+	}
+	if len(doc.Components.Schemas["LoginRequest"].Required) != 2 {
+		t.Errorf("Expected email and password to be required to log in, got %v", doc.Components.Schemas["LoginRequest"].Required)
+	}
+	if _, ok := register.Responses["201"]; !ok {
+		t.Errorf("Expected /register to respond 201, got %v", register.Responses)
+	}
+
+	// Responses are wrapped in the API response envelope
+	login := doc.Paths["/login"]["post"].Responses["200"].Content["application/json"].Schema
+	if len(login.AllOf) != 2 || login.AllOf[0].Ref != "#/components/schemas/APIResponse" ||
+		login.AllOf[1].Properties["data"].Ref != "#/components/schemas/AuthResponseDTO" {
+		t.Errorf("Expected /login to return an AuthResponseDTO in the envelope, got %+v", login)
+	}
+	authResponse := doc.Components.Schemas["AuthResponseDTO"]
+	if authResponse.Properties["user"].Ref != "#/components/schemas/UserResponseDTO" || authResponse.Properties["token"].Type != "string" {
+		t.Errorf("Expected AuthResponseDTO to hold the user and token, got %+v", authResponse)
+	}
+
+	// Embedded structs are flattened, times are date-times and optional fields are not required
+	created := doc.Components.Schemas["CreatedAPIKeyDTO"]
+	if created.Properties["key"].Type != "string" || created.Properties["created_at"].Format != "date-time" {
+		t.Errorf("Expected CreatedAPIKeyDTO to flatten APIKeyDTO, got %+v", created.Properties)
+	}
+	for _, field := range created.Required {
+		if field == "expires_at" {
+			t.Errorf("Expected the optional expires_at not to be required")
+		}
+	}
+
+	// Protected endpoints are mounted under the prefix and list their authentication
+	if profile, ok := doc.Paths["/api/profile"]["get"]; !ok || len(profile.Security) == 0 {
+		t.Errorf("Expected /api/profile to require authentication, got %+v", profile)
+	}
+	if _, ok := doc.Paths["/profile"]; ok {
+		t.Errorf("Expected no /profile on a server that mounts the API under /api")
+	}
+	if _, ok := doc.Paths["/scim/v2/Users/{id}"]["patch"]; !ok {
+		t.Errorf("Expected the SCIM endpoints to keep their paths")
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, openapi.DocsPath, nil))
+	assertStatus(t, rec.Code, http.StatusOK, "docs: expected status %d, got %d")
+	page := rec.Body.String()
+	if !strings.Contains(page, `const specURL = "openapi.json"`) {
+		t.Errorf("Expected the docs page to load the document next to it, got %s", page)
+	}
+	if strings.Contains(page, "http://") || strings.Contains(page, "https://") {
+		t.Errorf("Expected the docs page to load nothing from other origins, got %s", page)
+	}
+
+	// Only the inlined style and script may run
+	policy := rec.Header().Get("Content-Security-Policy")
+	for _, tag := range []string{"style", "script"} {
+		open := strings.Index(page, "<"+tag+">") + len(tag) + 2
+		end := strings.Index(page, "</"+tag+">")
+		sum := sha256.Sum256([]byte(page[open:end]))
+		if source := "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"; !strings.Contains(policy, tag+"-src "+source) {
+			t.Errorf("Expected the policy to allow the inline %s by its hash %s, got %q", tag, source, policy)
+		}
+	}
+	if !strings.Contains(policy, "default-src 'none'") {
+		t.Errorf("Expected the policy to deny other sources, got %q", policy)
+	}
+}
@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// orgServer wires the organization and invitation endpoints with a capturing mailer
+type orgServer struct {
+	*testServer
+	mailer *captureMailer
+}
+
+func newOrgServer(t *testing.T, inviteTTL time.Duration) *orgServer {
+	t.Helper()
+	s := &orgServer{testServer: newTestServer(t), mailer: &captureMailer{}}
+	config := usecase.DefaultOrgConfig([]byte("0123456789abcdef0123456789abcdef"), "https://app.example.com/join")
+	config.InviteTTL = inviteTTL
+	orgUseCase := usecase.NewOrgUseCase(usecase.OrgRepositories{
+		Orgs:        repository.NewInMemoryOrgRepository(),
+		Members:     repository.NewInMemoryMembershipRepository(),
+		Teams:       repository.NewInMemoryTeamRepository(),
+		Invitations: repository.NewInMemoryInvitationRepository(),
+	}, s.userRepo, s.users, s.mailer, config)
+
+	orgHandler := handler.NewOrgHandler(orgUseCase)
+	s.mux.Handle("GET /orgs", s.protect(orgHandler.Orgs))
+	s.mux.Handle("POST /orgs", s.protect(orgHandler.Orgs))
+	s.mux.Handle("GET /orgs/{id}", s.protect(orgHandler.Org))
+	s.mux.Handle("GET /orgs/{id}/members", s.protect(orgHandler.Org))
+	s.mux.Handle("PUT /orgs/{id}/members/{user_id}", s.protect(orgHandler.Org))
+	s.mux.Handle("DELETE /orgs/{id}/members/{user_id}", s.protect(orgHandler.Org))
+	s.mux.Handle("GET /orgs/{id}/invitations", s.protect(orgHandler.Org))
+	s.mux.Handle("POST /orgs/{id}/invitations", s.protect(orgHandler.Org))
+	s.mux.Handle("DELETE /orgs/{id}/invitations/{invitation_id}", s.protect(orgHandler.Org))
+	s.mux.Handle("GET /orgs/{id}/teams", s.protect(orgHandler.Org))
+	s.mux.Handle("POST /orgs/{id}/teams", s.protect(orgHandler.Org))
+	s.mux.Handle("DELETE /orgs/{id}/teams/{team_id}", s.protect(orgHandler.Org))
+	s.mux.Handle("PUT /orgs/{id}/teams/{team_id}/members/{user_id}", s.protect(orgHandler.Org))
+	s.mux.Handle("DELETE /orgs/{id}/teams/{team_id}/members/{user_id}", s.protect(orgHandler.Org))
+	s.mux.Handle("POST /invitations/accept", s.protect(orgHandler.AcceptInvitation))
+	s.mux.HandleFunc("POST /invitations/register", orgHandler.RegisterInvited)
+	return s
+}
+
+// call sends a request with a bearer token, unless token is empty, and decodes the response data into out
+func (s *orgServer) call(method, path, token string, body, out interface{}) int {
+	var header http.Header
+	if token != "" {
+		header = bearer(token)
+	}
+	rec := s.do(method, path, header, body)
+	if out != nil {
+		json.Unmarshal(rec.Body.Bytes(), &handler.APIResponse{Data: out})
+	}
+	return rec.Code
+}
+
+// createOrg creates an organization owned by the caller and returns its ID
+func (s *orgServer) createOrg(t *testing.T, token, name string) string {
+	t.Helper()
+	var created handler.OrgDTO
+	code := s.call(http.MethodPost, "/orgs", token, handler.CreateOrgRequest{Name: name}, &created)
+	assertStatus(t, code, http.StatusCreated, "create organization: expected status %d, got %d")
+	return created.ID
+}
+
+// invite invites an email and returns the token from the link in the invitation email
+func (s *orgServer) invite(t *testing.T, token, orgID, email, role string) string {
+	t.Helper()
+	code := s.call(http.MethodPost, "/orgs/"+orgID+"/invitations", token, handler.InviteRequest{Email: email, Role: role}, nil)
+	assertStatus(t, code, http.StatusCreated, "invite: expected status %d, got %d")
+
+	sent := s.mailer.sent
+	msg := sent[len(sent)-1]
+	if msg.To != email {
+		t.Fatalf("Expected the invitation to be sent to %s, got %s", email, msg.To)
+	}
+	_, link, ok := strings.Cut(msg.Body, "https://app.example.com/join?token=")
+	if !ok {
+		t.Fatalf("Expected an invitation link in %q", msg.Body)
+	}
+	return strings.Fields(link)[0]
+}
+
+// TestOrgInvitations verifies registered and unregistered invitees join through signed links
+// that only work once, for the invited email and until they expire
+func TestOrgInvitations(t *testing.T) {
+	s := newOrgServer(t, time.Hour)
+	annToken := s.login(t, "ann@example.com")
+	bobToken := s.login(t, "bob@example.com")
+	orgID := s.createOrg(t, annToken, "Acme")
+
+	bobInvite := s.invite(t, annToken, orgID, "bob@example.com", "admin")
+
+	// The link is bound to the invited email
+	eveToken := s.login(t, "eve@example.com")
+	code := s.call(http.MethodPost, "/invitations/accept", eveToken, handler.InvitationTokenRequest{Token: bobInvite}, nil)
+	assertStatus(t, code, http.StatusForbidden, "accept another email's invitation: expected status %d, got %d")
+
+	var joined handler.OrgDTO
+	code = s.call(http.MethodPost, "/invitations/accept", bobToken, handler.InvitationTokenRequest{Token: bobInvite}, &joined)
+	assertStatus(t, code, http.StatusOK, "accept invitation: expected status %d, got %d")
+	if joined.ID != orgID || joined.Role != "admin" {
+		t.Errorf("Unexpected joined organization: %+v", joined)
+	}
+	code = s.call(http.MethodPost, "/invitations/accept", bobToken, handler.InvitationTokenRequest{Token: bobInvite}, nil)
+	assertStatus(t, code, http.StatusBadRequest, "accept invitation twice: expected status %d, got %d")
+
+	code = s.call(http.MethodPost, "/orgs/"+orgID+"/invitations", annToken, handler.InviteRequest{Email: "bob@example.com"}, nil)
+	assertStatus(t, code, http.StatusConflict, "invite a member: expected status %d, got %d")
+
+	// Invitees without an account register with the invitation and join
+	carolInvite := s.invite(t, annToken, orgID, "carol@example.com", "member")
+	parts := strings.Split(carolInvite, ".")
+	tampered := parts[0] + ".9999999999." + parts[2]
+	code = s.call(http.MethodPost, "/invitations/register", "", handler.RegisterInvitedRequest{
+		Token: tampered, FirstName: "Carol", LastName: "Ng", Password: testPassword,
+	}, nil)
+	assertStatus(t, code, http.StatusBadRequest, "register with an extended invitation: expected status %d, got %d")
+
+	var registered handler.UserResponseDTO
+	code = s.call(http.MethodPost, "/invitations/register", "", handler.RegisterInvitedRequest{
+		Token: carolInvite, FirstName: "Carol", LastName: "Ng", Password: testPassword,
+	}, &registered)
+	assertStatus(t, code, http.StatusCreated, "register with an invitation: expected status %d, got %d")
+	if registered.Email != "carol@example.com" {
+		t.Errorf("Expected the invited email to be registered, got %q", registered.Email)
+	}
+
+	carol, err := s.users.Login(tenant.DefaultID, "carol@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	var orgs []handler.OrgDTO
+	code = s.call(http.MethodGet, "/orgs", carol.Token, nil, &orgs)
+	assertStatus(t, code, http.StatusOK, "list organizations: expected status %d, got %d")
+	if len(orgs) != 1 || orgs[0].ID != orgID || orgs[0].Role != "member" {
+		t.Errorf("Expected carol to be a member of the organization, got %+v", orgs)
+	}
+
+	var members []handler.MemberDTO
+	code = s.call(http.MethodGet, "/orgs/"+orgID+"/members", carol.Token, nil, &members)
+	assertStatus(t, code, http.StatusOK, "list members: expected status %d, got %d")
+	if len(members) != 3 {
+		t.Errorf("Expected 3 members, got %+v", members)
+	}
+
+	// Revoked and expired invitations stop working
+	daveInvite := s.invite(t, annToken, orgID, "dave@example.com", "member")
+	var invitations []handler.InvitationDTO
+	s.call(http.MethodGet, "/orgs/"+orgID+"/invitations", annToken, nil, &invitations)
+	code = s.call(http.MethodDelete, "/orgs/"+orgID+"/invitations/"+invitations[0].ID, annToken, nil, nil)
+	assertStatus(t, code, http.StatusOK, "revoke invitation: expected status %d, got %d")
+	code = s.call(http.MethodPost, "/invitations/register", "", handler.RegisterInvitedRequest{
+		Token: daveInvite, FirstName: "Dave", LastName: "Ng", Password: testPassword,
+	}, nil)
+	assertStatus(t, code, http.StatusBadRequest, "register with a revoked invitation: expected status %d, got %d")
+
+	expired := newOrgServer(t, -time.Minute)
+	ownerToken := expired.login(t, "ann@example.com")
+	expiredInvite := expired.invite(t, ownerToken, expired.createOrg(t, ownerToken, "Acme"), "dave@example.com", "member")
+	code = expired.call(http.MethodPost, "/invitations/register", "", handler.RegisterInvitedRequest{
+		Token: expiredInvite, FirstName: "Dave", LastName: "Ng", Password: testPassword,
+	}, nil)
+	assertStatus(t, code, http.StatusBadRequest, "register with an expired invitation: expected status %d, got %d")
+}
+
+// TestOrgMemberRoles verifies who can change roles and remove members, and that an
+// organization always keeps an owner
+func TestOrgMemberRoles(t *testing.T) {
+	s := newOrgServer(t, time.Hour)
+	annToken := s.login(t, "ann@example.com")
+	annID := mustClaims(t, annToken).Subject
+	bobToken := s.login(t, "bob@example.com")
+	bobID := mustClaims(t, bobToken).Subject
+	carolToken := s.login(t, "carol@example.com")
+	carolID := mustClaims(t, carolToken).Subject
+	eveToken := s.login(t, "eve@example.com")
+	orgID := s.createOrg(t, annToken, "Acme")
+
+	for token, invite := range map[string]string{
+		bobToken:   s.invite(t, annToken, orgID, "bob@example.com", "admin"),
+		carolToken: s.invite(t, annToken, orgID, "carol@example.com", "member"),
+	} {
+		code := s.call(http.MethodPost, "/invitations/accept", token, handler.InvitationTokenRequest{Token: invite}, nil)
+		assertStatus(t, code, http.StatusOK, "accept invitation: expected status %d, got %d")
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   interface{}
+		want   int
+	}{
+		{"non-member reads organization", http.MethodGet, "/orgs/" + orgID, eveToken, nil, http.StatusNotFound},
+		{"member lists invitations", http.MethodGet, "/orgs/" + orgID + "/invitations", carolToken, nil, http.StatusForbidden},
+		{"member invites", http.MethodPost, "/orgs/" + orgID + "/invitations", carolToken, handler.InviteRequest{Email: "x@example.com"}, http.StatusForbidden},
+		{"admin invites owner", http.MethodPost, "/orgs/" + orgID + "/invitations", bobToken, handler.InviteRequest{Email: "x@example.com", Role: "owner"}, http.StatusForbidden},
+		{"admin promotes to owner", http.MethodPut, "/orgs/" + orgID + "/members/" + carolID, bobToken, handler.ChangeRoleRequest{Role: "owner"}, http.StatusForbidden},
+		{"admin removes owner", http.MethodDelete, "/orgs/" + orgID + "/members/" + annID, bobToken, nil, http.StatusForbidden},
+		{"invalid role", http.MethodPut, "/orgs/" + orgID + "/members/" + carolID, annToken, handler.ChangeRoleRequest{Role: "root"}, http.StatusBadRequest},
+		{"last owner steps down", http.MethodPut, "/orgs/" + orgID + "/members/" + annID, annToken, handler.ChangeRoleRequest{Role: "admin"}, http.StatusConflict},
+		{"last owner leaves", http.MethodDelete, "/orgs/" + orgID + "/members/" + annID, annToken, nil, http.StatusConflict},
+		{"admin promotes member", http.MethodPut, "/orgs/" + orgID + "/members/" + carolID, bobToken, handler.ChangeRoleRequest{Role: "admin"}, http.StatusOK},
+		{"owner promotes owner", http.MethodPut, "/orgs/" + orgID + "/members/" + bobID, annToken, handler.ChangeRoleRequest{Role: "owner"}, http.StatusOK},
+		{"owner steps down", http.MethodPut, "/orgs/" + orgID + "/members/" + annID, annToken, handler.ChangeRoleRequest{Role: "member"}, http.StatusOK},
+		{"member leaves", http.MethodDelete, "/orgs/" + orgID + "/members/" + annID, annToken, nil, http.StatusOK},
+		{"former member reads organization", http.MethodGet, "/orgs/" + orgID, annToken, nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := s.call(tt.method, tt.path, tt.token, tt.body, nil)
+			assertStatus(t, code, tt.want, "expected status %d, got %d")
+		})
+	}
+}
+
+// TestOrgTeams verifies teams hold organization members only and lose members who leave
+func TestOrgTeams(t *testing.T) {
+	s := newOrgServer(t, time.Hour)
+	annToken := s.login(t, "ann@example.com")
+	bobToken := s.login(t, "bob@example.com")
+	bobID := mustClaims(t, bobToken).Subject
+	eveID := mustClaims(t, s.login(t, "eve@example.com")).Subject
+	orgID := s.createOrg(t, annToken, "Acme")
+
+	invite := s.invite(t, annToken, orgID, "bob@example.com", "member")
+	code := s.call(http.MethodPost, "/invitations/accept", bobToken, handler.InvitationTokenRequest{Token: invite}, nil)
+	assertStatus(t, code, http.StatusOK, "accept invitation: expected status %d, got %d")
+
+	var team handler.TeamDTO
+	code = s.call(http.MethodPost, "/orgs/"+orgID+"/teams", annToken, handler.CreateOrgRequest{Name: "Platform"}, &team)
+	assertStatus(t, code, http.StatusCreated, "create team: expected status %d, got %d")
+	code = s.call(http.MethodPost, "/orgs/"+orgID+"/teams", annToken, handler.CreateOrgRequest{Name: "platform"}, nil)
+	assertStatus(t, code, http.StatusConflict, "create duplicate team: expected status %d, got %d")
+	code = s.call(http.MethodPost, "/orgs/"+orgID+"/teams", bobToken, handler.CreateOrgRequest{Name: "Ops"}, nil)
+	assertStatus(t, code, http.StatusForbidden, "member creates team: expected status %d, got %d")
+
+	teamMember := "/orgs/" + orgID + "/teams/" + team.ID + "/members/"
+	code = s.call(http.MethodPut, teamMember+eveID, annToken, nil, nil)
+	assertStatus(t, code, http.StatusNotFound, "add non-member to team: expected status %d, got %d")
+	code = s.call(http.MethodPut, teamMember+bobID, annToken, nil, &team)
+	assertStatus(t, code, http.StatusOK, "add member to team: expected status %d, got %d")
+	if len(team.MemberIDs) != 1 || team.MemberIDs[0] != bobID {
+		t.Fatalf("Expected bob in the team, got %+v", team.MemberIDs)
+	}
+
+	code = s.call(http.MethodDelete, "/orgs/"+orgID+"/members/"+bobID, annToken, nil, nil)
+	assertStatus(t, code, http.StatusOK, "remove member: expected status %d, got %d")
+
+	var teams []handler.TeamDTO
+	s.call(http.MethodGet, "/orgs/"+orgID+"/teams", annToken, nil, &teams)
+	if len(teams) != 1 || len(teams[0].MemberIDs) != 0 {
+		t.Errorf("Expected removed members to leave their teams, got %+v", teams)
+	}
+}
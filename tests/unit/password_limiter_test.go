@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/config"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// blockingPasswordService blocks every hash until released
+type blockingPasswordService struct {
+	auth.PasswordService
+	release chan struct{}
+}
+
+func (s *blockingPasswordService) VerifyPassword(hashedPassword, password string) (bool, error) {
+	<-s.release
+	return s.PasswordService.VerifyPassword(hashedPassword, password)
+}
+
+// TestPasswordLimiterBoundsConcurrency verifies saturated hashing fails fast with a 503
+func TestPasswordLimiterBoundsConcurrency(t *testing.T) {
+	inner := &blockingPasswordService{
+		PasswordService: auth.NewPasswordService(testArgonParams),
+		release:         make(chan struct{}),
+	}
+	limited := auth.NewLimitedPasswordService(inner, testArgonParams, auth.LimiterConfig{
+		MaxConcurrent: 4,
+		// The memory budget only fits two operations
+		MemoryBudget: 2 * uint64(testArgonParams.Memory),
+		QueueTimeout: 20 * time.Millisecond,
+	})
+
+	hash, err := auth.NewPasswordService(testArgonParams).HashPassword("tV9#qL2!mZ7$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limited.VerifyPassword(hash, "tV9#qL2!mZ7$")
+		}()
+	}
+	waitFor(t, func() bool { return limited.Stats().InFlight == 2 })
+
+	if _, err := limited.VerifyPassword(hash, "tV9#qL2!mZ7$"); err != auth.ErrHashingBusy {
+		t.Errorf("Expected ErrHashingBusy, got %v", err)
+	}
+
+	// The use case and handler turn the rejection into a 503
+	repo := repository.NewInMemoryUserRepository()
+	uc := usecase.NewUserUseCase(repo, limited, testJWTService())
+	rec := httptest.NewRecorder()
+	body := `{"email":"ann@example.com","password":"tV9#qL2!mZ7$"}`
+	handler.NewUserHandler(uc).Login(rec, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body)))
+	assertStatus(t, rec.Code, http.StatusServiceUnavailable, "Expected status %d, got %d")
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+
+	close(inner.release)
+	wg.Wait()
+
+	stats := limited.Stats()
+	if stats.Rejected != 2 || stats.Completed != 2 || stats.InFlight != 0 || stats.MaxQueueDepth < 1 {
+		t.Errorf("Unexpected limiter stats %+v", stats)
+	}
+}
+
+// TestCalibrateArgonParams verifies calibration respects the memory cap
+func TestCalibrateArgonParams(t *testing.T) {
+	params := auth.CalibrateArgonParams(5*time.Millisecond, 8*1024, testArgonParams)
+
+	if params.Memory > 8*1024 || params.Iterations < 1 {
+		t.Errorf("Unexpected calibrated params %+v", params)
+	}
+	if params.SaltLength != testArgonParams.SaltLength || params.KeyLength != testArgonParams.KeyLength {
+		t.Errorf("Expected salt and key length to be kept, got %+v", params)
+	}
+}
+
+// TestStartupCalibrationIsCached verifies servers calibrate at startup when a target is set and
+// reuse a cached calibration for the same target and base parameters
+func TestStartupCalibrationIsCached(t *testing.T) {
+	t.Setenv("ARGON_CALIBRATE_TARGET", "5ms")
+	t.Setenv("ARGON_MAX_MEMORY_MIB", "8")
+	t.Setenv("ARGON_CALIBRATION_CACHE", filepath.Join(t.TempDir(), "argon.json"))
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	calibrated := cfg.ArgonParams()
+	if calibrated.Memory > 8*1024 || calibrated.Iterations < 1 {
+		t.Fatalf("Unexpected calibrated params %+v", calibrated)
+	}
+	data, err := os.ReadFile(cfg.ArgonCalibrationCache)
+	if err != nil {
+		t.Fatalf("Expected the calibration to be cached: %v", err)
+	}
+
+	// A restart takes the cached parameters as they are, even if measuring would differ
+	cached := strings.Replace(string(data), fmt.Sprintf(`"Iterations": %d`, calibrated.Iterations), `"Iterations": 42`, 1)
+	if err := os.WriteFile(cfg.ArgonCalibrationCache, []byte(cached), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if params := cfg.ArgonParams(); params.Iterations != 42 {
+		t.Errorf("Expected the cached parameters to be reused, got %+v", params)
+	}
+
+	// Another target measures again
+	cfg.ArgonCalibrateTarget = 2 * time.Millisecond
+	if params := cfg.ArgonParams(); params.Iterations == 42 {
+		t.Errorf("Expected a new target to be calibrated, got %+v", params)
+	}
+}
+
+// waitFor polls cond until it is true or a second has passed
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
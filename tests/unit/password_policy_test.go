@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/mail"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// captureMailer records sent messages for inspection
+type captureMailer struct {
+	sent []mail.Message
+	mu   sync.Mutex
+}
+
+func (m *captureMailer) Send(msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// waitFor waits for n messages to be sent in the background and returns them
+func (m *captureMailer) waitFor(t *testing.T, n int) []mail.Message {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.Lock()
+		sent := append([]mail.Message(nil), m.sent...)
+		m.mu.Unlock()
+
+		if len(sent) >= n || time.Now().After(deadline) {
+			if len(sent) != n {
+				t.Fatalf("Expected %d emails, got %d", n, len(sent))
+			}
+			return sent
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestPasswordStrength verifies the strength estimator ranks obvious passwords low
+func TestPasswordStrength(t *testing.T) {
+	weak := []string{"password", "12345678", "qwertyuiop", "aaaaaaaa", "P@ssw0rd1", "summer2024"}
+	for _, pw := range weak {
+		if score := auth.PasswordStrength(pw); score >= 2 {
+			t.Errorf("Expected %q to score below 2, got %d", pw, score)
+		}
+	}
+
+	strong := []string{"correct horse battery staple", testPassword}
+	for _, pw := range strong {
+		if score := auth.PasswordStrength(pw); score < 3 {
+			t.Errorf("Expected %q to score at least 3, got %d", pw, score)
+		}
+	}
+}
+
+// TestPasswordPolicy verifies the policy rules including the breached corpus
+func TestPasswordPolicy(t *testing.T) {
+	breachedPassword := "Tr0ub4dor&3xyz"
+	corpusPath := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(corpusPath, []byte(sha1Hex(breachedPassword)+":42\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	corpus, err := auth.LoadHashPrefixCorpus(corpusPath)
+	if err != nil {
+		t.Fatalf("Failed to load corpus: %v", err)
+	}
+
+	policy := auth.DefaultPasswordPolicy()
+	policy.Breached = corpus
+
+	cases := []struct {
+		password string
+		want     string
+	}{
+		{"short", "at least 8"},
+		{"password", "too easy"},
+		{"Zelda-Kowalski-91", "name or email"},
+		{breachedPassword, "data breach"},
+	}
+
+	for _, tc := range cases {
+		violations, err := policy.Check(tc.password, "Zelda", "Kowalski", "zelda@example.com")
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if !strings.Contains(strings.Join(violations, ";"), tc.want) {
+			t.Errorf("Expected %q to be rejected with %q, got %v", tc.password, tc.want, violations)
+		}
+	}
+
+	violations, _ := policy.Check(testPassword, "Zelda", "Kowalski", "zelda@example.com")
+	if len(violations) != 0 {
+		t.Errorf("Expected strong password to pass, got %v", violations)
+	}
+}
+
+// TestOverlongPasswords verifies long passwords are rejected without being scored and that
+// scoring stays fast for any length
+func TestOverlongPasswords(t *testing.T) {
+	long := strings.Repeat("aB3$", 256*1024)
+
+	start := time.Now()
+	violations, err := auth.DefaultPasswordPolicy().Check(long, "Zelda", "Kowalski", "zelda@example.com")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(violations) != 1 || !strings.Contains(violations[0], "at most 128") {
+		t.Errorf("Expected only the length violation, got %v", violations)
+	}
+
+	auth.PasswordStrength(long[:16*1024], "Zelda", "Kowalski", "zelda@example.com")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected long passwords to be handled quickly, took %s", elapsed)
+	}
+
+	uc := newTestUserUseCase(t)
+	_, err = uc.Register(tenant.DefaultID, "Zelda", "Kowalski", "zelda@example.com", long)
+	var errs validation.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "password" {
+		t.Errorf("Expected the password field to be rejected, got %v", err)
+	}
+	if _, err := uc.Login(tenant.DefaultID, "zelda@example.com", long); !errors.As(err, &errs) {
+		t.Errorf("Expected an overlong login password to be rejected, got %v", err)
+	}
+}
+
+// TestPasswordLengthCountsCharacters verifies the length limit counts characters, not bytes
+func TestPasswordLengthCountsCharacters(t *testing.T) {
+	// 128 characters of three bytes each, well past 128 bytes
+	password := strings.Repeat("鍵盤楽器の音色", 18) + "鍵盤"
+	if n := utf8.RuneCountInString(password); n != validation.MaxPasswordLength {
+		t.Fatalf("Expected a password of %d characters, got %d", validation.MaxPasswordLength, n)
+	}
+
+	uc := newTestUserUseCase(t)
+	if _, err := uc.Register(tenant.DefaultID, "Zelda", "Kowalski", "zelda@example.com", password); err != nil {
+		t.Fatalf("Expected a %d character password to be accepted, got %v", validation.MaxPasswordLength, err)
+	}
+	if _, err := uc.Login(tenant.DefaultID, "zelda@example.com", password); err != nil {
+		t.Errorf("Expected login with the password to succeed, got %v", err)
+	}
+
+	var errs validation.Errors
+	if _, err := uc.Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", password+"鍵"); !errors.As(err, &errs) || !errs.Has("password") {
+		t.Errorf("Expected a %d character password to be rejected, got %v", validation.MaxPasswordLength+1, err)
+	}
+}
+
+// TestHashPrefixCorpusDirectory verifies range files are looked up by hash prefix
+func TestHashPrefixCorpusDirectory(t *testing.T) {
+	dir := t.TempDir()
+	hash := sha1Hex("hunter2hunter2")
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(hash[5:]+":7\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	corpus, err := auth.LoadHashPrefixCorpus(dir)
+	if err != nil {
+		t.Fatalf("Failed to load corpus: %v", err)
+	}
+
+	if count, _ := corpus.Count("hunter2hunter2"); count != 7 {
+		t.Errorf("Expected count 7, got %d", count)
+	}
+	if breached, _ := corpus.IsBreached("not in the corpus"); breached {
+		t.Error("Expected unknown password not to be breached")
+	}
+	if suffixes, err := corpus.Range(strings.ToLower(hash[:5])); err != nil || suffixes[hash[5:]] != 7 {
+		t.Errorf("Expected the range to be found by a lowercase prefix, got %v, %v", suffixes, err)
+	}
+
+	// Prefixes name range files, so anything else is refused before a path is built
+	secret := filepath.Join(filepath.Dir(dir), "ABCDE.txt")
+	if err := os.WriteFile(secret, []byte(hash[5:]+":1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"../ABCDE", "ABCD", "ABCDEF", "ABCDG", "", "AB/CD"} {
+		if _, err := corpus.Range(prefix); !errors.Is(err, auth.ErrInvalidHashPrefix) {
+			t.Errorf("Expected prefix %q to be rejected, got %v", prefix, err)
+		}
+	}
+}
+
+// TestPasswordChangeAndReset verifies the policy is enforced on change and reset
+func TestPasswordChangeAndReset(t *testing.T) {
+	mailer := &captureMailer{}
+	uc := usecase.NewUserUseCase(
+		repository.NewInMemoryUserRepository(),
+		auth.NewPasswordService(testArgonParams),
+		testJWTService(),
+		usecase.WithPasswordReset(repository.NewInMemoryPasswordResetRepository(), mailer, time.Hour),
+	)
+
+	if _, err := uc.Register(tenant.DefaultID, "Zelda", "Kowalski", "zelda@example.com", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if err := uc.ChangePassword(tenant.DefaultID, "zelda@example.com", testPassword, "password"); err == nil {
+		t.Error("Expected weak new password to be rejected")
+	}
+	if err := uc.ChangePassword(tenant.DefaultID, "zelda@example.com", "wrong", "Kq8!vR3#pW6&"); err != usecase.ErrInvalidCredentials {
+		t.Errorf("Expected invalid credentials, got %v", err)
+	}
+	if err := uc.ChangePassword(tenant.DefaultID, "zelda@example.com", testPassword, "Kq8!vR3#pW6&"); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	if err := uc.RequestPasswordReset(tenant.DefaultID, "zelda@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset failed: %v", err)
+	}
+	sent := mailer.waitFor(t, 1)
+	token := strings.Fields(strings.SplitN(sent[0].Body, ": ", 2)[1])[0]
+
+	if err := uc.ResetPassword(tenant.DefaultID, token, "zelda-kowalski"); err == nil {
+		t.Error("Expected reset to reject a password containing the user's name")
+	}
+	if err := uc.ResetPassword(tenant.DefaultID, token, "Hn5$wX2@cJ9!"); err != nil {
+		t.Fatalf("ResetPassword failed: %v", err)
+	}
+	if err := uc.ResetPassword(tenant.DefaultID, token, "Hn5$wX2@cJ9!"); err != usecase.ErrInvalidResetToken {
+		t.Errorf("Expected reused token to be rejected, got %v", err)
+	}
+
+	if _, err := uc.Login(tenant.DefaultID, "zelda@example.com", "Hn5$wX2@cJ9!"); err != nil {
+		t.Errorf("Expected login with reset password to succeed: %v", err)
+	}
+}
+
+// sha1Hex returns the uppercase SHA-1 hex digest of a password
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
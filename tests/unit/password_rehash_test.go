@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// TestLoginRehashesOutdatedPasswords verifies hashes are upgraded when the Argon2 params change
+func TestLoginRehashesOutdatedPasswords(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	oldService := auth.NewPasswordService(testArgonParams)
+
+	if _, err := usecase.NewUserUseCase(repo, oldService, testJWTService()).Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	stronger := testArgonParams
+	stronger.Iterations = 2
+	newService := auth.NewPasswordService(stronger)
+	uc := usecase.NewUserUseCase(repo, newService, testJWTService())
+
+	report, _ := uc.PasswordHashReport(tenant.DefaultID)
+	if len(report) != 1 || report[0].Current || report[0].Params != "argon2id "+testArgonParams.String() {
+		t.Fatalf("Expected one outdated group before login, got %+v", report)
+	}
+
+	if _, err := uc.Login(tenant.DefaultID, "ann@example.com", testPassword); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	stored, _ := repo.FindByEmail(tenant.DefaultID, "ann@example.com")
+	if newService.NeedsRehash(stored.Password) {
+		t.Errorf("Expected hash to be upgraded, got %s", stored.Password)
+	}
+
+	report, _ = uc.PasswordHashReport(tenant.DefaultID)
+	if len(report) != 1 || !report[0].Current {
+		t.Errorf("Expected all users on current params after login, got %+v", report)
+	}
+
+	if _, err := uc.Login(tenant.DefaultID, "ann@example.com", testPassword); err != nil {
+		t.Errorf("Expected login with upgraded hash to succeed: %v", err)
+	}
+}
+
+// TestNeedsRehashOnlyForWeakerParams verifies hashes are kept unless the configured
+// parameters are stronger in some cost
+func TestNeedsRehashOnlyForWeakerParams(t *testing.T) {
+	stored := testArgonParams
+	stored.Iterations, stored.Parallelism = 2, 2
+	hash, err := auth.NewPasswordService(stored).HashPassword(testPassword)
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+
+	cheaper := stored
+	cheaper.Memory /= 2
+	fewerLanes := stored
+	fewerLanes.Parallelism = 1
+	moreIterations := stored
+	moreIterations.Iterations++
+	// A hash weaker in one cost is replaced even when it is stronger in another
+	traded := stored
+	traded.Memory *= 2
+	traded.Iterations = 1
+
+	cases := []struct {
+		name   string
+		params auth.ArgonParams
+		want   bool
+	}{
+		{"same", stored, false},
+		{"cheaper", cheaper, false},
+		{"fewer lanes", fewerLanes, false},
+		{"more iterations", moreIterations, true},
+		{"traded", traded, true},
+	}
+	for _, c := range cases {
+		if got := auth.NewPasswordService(c.params).NeedsRehash(hash); got != c.want {
+			t.Errorf("%s: expected NeedsRehash %v, got %v", c.name, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// TestPepperRotation verifies peppered hashes record their key ID and are upgraded after rotation
+func TestPepperRotation(t *testing.T) {
+	keys, err := auth.ParsePepperKeys("k1=MDEyMzQ1Njc4OWFiY2RlZg==, k2=ZmVkY2JhOTg3NjU0MzIxMA==")
+	if err != nil {
+		t.Fatalf("ParsePepperKeys failed: %v", err)
+	}
+
+	repo := repository.NewInMemoryUserRepository()
+	oldService := auth.NewPasswordService(testArgonParams, auth.WithPepper(auth.Pepper{CurrentKeyID: "k1", Keys: keys}))
+	if _, err := usecase.NewUserUseCase(repo, oldService, testJWTService()).Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", "tV9#qL2!mZ7$"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	stored, _ := repo.FindByEmail(tenant.DefaultID, "ann@example.com")
+	if !strings.Contains(stored.Password, ",keyid=k1$") {
+		t.Fatalf("Expected key ID in hash, got %s", stored.Password)
+	}
+
+	// Without the pepper the hash cannot be verified
+	unpeppered := auth.NewPasswordService(testArgonParams)
+	if _, err := unpeppered.VerifyPassword(stored.Password, "tV9#qL2!mZ7$"); err != auth.ErrUnknownPepperKey {
+		t.Errorf("Expected ErrUnknownPepperKey, got %v", err)
+	}
+
+	// Rotate to k2 while keeping k1 for verification
+	newService := auth.NewPasswordService(testArgonParams, auth.WithPepper(auth.Pepper{CurrentKeyID: "k2", Keys: keys}))
+	if !newService.NeedsRehash(stored.Password) {
+		t.Error("Expected hash with an old pepper key to need a rehash")
+	}
+
+	uc := usecase.NewUserUseCase(repo, newService, testJWTService())
+	if _, err := uc.Login(tenant.DefaultID, "ann@example.com", "tV9#qL2!mZ7$"); err != nil {
+		t.Fatalf("Login after rotation failed: %v", err)
+	}
+
+	stored, _ = repo.FindByEmail(tenant.DefaultID, "ann@example.com")
+	if !strings.Contains(stored.Password, ",keyid=k2$") || newService.NeedsRehash(stored.Password) {
+		t.Errorf("Expected hash to be upgraded to k2, got %s", stored.Password)
+	}
+	if label := auth.DescribeHash(stored.Password); !strings.HasSuffix(label, "keyid=k2") {
+		t.Errorf("Expected key ID in hash label, got %q", label)
+	}
+}
@@ -0,0 +1,389 @@
+package tests
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/saml"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	samlIdPEntityID = "https://idp.acme.test/metadata"
+	samlIdPSSOURL   = "https://idp.acme.test/sso"
+	samlSPEntityID  = "https://auth.example.com/auth/acme/metadata"
+	samlACSURL      = "https://auth.example.com/auth/acme/acs"
+)
+
+// samlIdP signs responses like a SAML identity provider, with a locally generated key
+type samlIdP struct {
+	cert    *x509.Certificate
+	signing *dsig.SigningContext
+}
+
+func newSAMLIdP(t *testing.T) *samlIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.acme.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	signing := dsig.NewDefaultSigningContext(dsig.TLSCertKeyStore(tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}))
+	signing.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	return &samlIdP{cert: cert, signing: signing}
+}
+
+// samlAssertion describes the response the identity provider issues
+type samlAssertion struct {
+	AssertionID  string
+	InResponseTo string
+	Email        string
+	Audience     string
+	Recipient    string
+	NotOnOrAfter time.Time
+	// SignResponse signs the whole response instead of the assertion
+	SignResponse bool
+	Unsigned     bool
+}
+
+// defaultAssertion answers the request with a valid assertion for ann@example.com
+func defaultAssertion(requestID string) samlAssertion {
+	return samlAssertion{
+		AssertionID:  "_assertion-" + requestID,
+		InResponseTo: requestID,
+		Email:        "ann@example.com",
+		Audience:     samlSPEntityID,
+		Recipient:    samlACSURL,
+		NotOnOrAfter: time.Now().Add(5 * time.Minute),
+	}
+}
+
+// issue returns the base64 SAMLResponse for an assertion
+func (idp *samlIdP) issue(t *testing.T, a samlAssertion) string {
+	t.Helper()
+	return base64.StdEncoding.EncodeToString(idp.issueXML(t, a, nil))
+}
+
+// issueXML builds the response document; edit may change the signed response before it is serialized
+func (idp *samlIdP) issueXML(t *testing.T, a samlAssertion, edit func(response *etree.Element)) []byte {
+	t.Helper()
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	assertion := etree.NewElement("saml:Assertion")
+	assertion.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	assertion.CreateAttr("ID", a.AssertionID)
+	assertion.CreateAttr("Version", "2.0")
+	assertion.CreateAttr("IssueInstant", now)
+	assertion.CreateElement("saml:Issuer").SetText(samlIdPEntityID)
+
+	subject := assertion.CreateElement("saml:Subject")
+	nameID := subject.CreateElement("saml:NameID")
+	nameID.CreateAttr("Format", "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress")
+	nameID.SetText(a.Email)
+	confirmation := subject.CreateElement("saml:SubjectConfirmation")
+	confirmation.CreateAttr("Method", "urn:oasis:names:tc:SAML:2.0:cm:bearer")
+	data := confirmation.CreateElement("saml:SubjectConfirmationData")
+	data.CreateAttr("InResponseTo", a.InResponseTo)
+	data.CreateAttr("Recipient", a.Recipient)
+	data.CreateAttr("NotOnOrAfter", a.NotOnOrAfter.UTC().Format(time.RFC3339))
+
+	conditions := assertion.CreateElement("saml:Conditions")
+	conditions.CreateAttr("NotBefore", time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+	conditions.CreateAttr("NotOnOrAfter", a.NotOnOrAfter.UTC().Format(time.RFC3339))
+	conditions.CreateElement("saml:AudienceRestriction").CreateElement("saml:Audience").SetText(a.Audience)
+
+	statement := assertion.CreateElement("saml:AttributeStatement")
+	for name, value := range map[string]string{"givenName": "Ann", "sn": "Lee", "department": "Sales"} {
+		attribute := statement.CreateElement("saml:Attribute")
+		attribute.CreateAttr("Name", name)
+		attribute.CreateElement("saml:AttributeValue").SetText(value)
+	}
+
+	if !a.Unsigned && !a.SignResponse {
+		signed, err := idp.signing.SignEnveloped(assertion)
+		if err != nil {
+			t.Fatalf("Signing the assertion failed: %v", err)
+		}
+		assertion = signed
+	}
+
+	response := etree.NewElement("samlp:Response")
+	response.CreateAttr("xmlns:samlp", "urn:oasis:names:tc:SAML:2.0:protocol")
+	response.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	response.CreateAttr("ID", "_response-"+a.AssertionID)
+	response.CreateAttr("Version", "2.0")
+	response.CreateAttr("IssueInstant", now)
+	response.CreateAttr("Destination", a.Recipient)
+	response.CreateAttr("InResponseTo", a.InResponseTo)
+	response.CreateElement("saml:Issuer").SetText(samlIdPEntityID)
+	response.CreateElement("samlp:Status").CreateElement("samlp:StatusCode").
+		CreateAttr("Value", "urn:oasis:names:tc:SAML:2.0:status:Success")
+	response.AddChild(assertion)
+
+	if a.SignResponse && !a.Unsigned {
+		signed, err := idp.signing.SignEnveloped(response)
+		if err != nil {
+			t.Fatalf("Signing the response failed: %v", err)
+		}
+		response = signed
+	}
+	if edit != nil {
+		edit(response)
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(response)
+	out, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("Serializing the response failed: %v", err)
+	}
+	return out
+}
+
+// newTestServiceProvider trusts the identity provider and treats its addresses as verified
+func newTestServiceProvider(t *testing.T, idp *samlIdP) *saml.ServiceProvider {
+	t.Helper()
+	sp, err := saml.NewServiceProvider(saml.Config{
+		Name:            "acme",
+		EntityID:        samlSPEntityID,
+		ACSURL:          samlACSURL,
+		IdPEntityID:     samlIdPEntityID,
+		IdPSSOURL:       samlIdPSSOURL,
+		IdPCertificates: []*x509.Certificate{idp.cert},
+		TrustEmail:      true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewServiceProvider failed: %v", err)
+	}
+	return sp
+}
+
+// samlRequestID decodes the AuthnRequest in a redirect URL and returns its ID
+func samlRequestID(t *testing.T, redirectURL string) string {
+	t.Helper()
+	u, err := url.Parse(redirectURL)
+	if err != nil || !strings.HasPrefix(redirectURL, samlIdPSSOURL+"?") {
+		t.Fatalf("Expected a redirect to the identity provider, got %q", redirectURL)
+	}
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	if err != nil {
+		t.Fatalf("SAMLRequest is not base64: %v", err)
+	}
+	raw, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		t.Fatalf("SAMLRequest is not deflated: %v", err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		t.Fatalf("SAMLRequest is not XML: %v", err)
+	}
+	req := doc.Root()
+	if req.Tag != "AuthnRequest" || req.SelectAttrValue("AssertionConsumerServiceURL", "") != samlACSURL {
+		t.Fatalf("Unexpected AuthnRequest %s", raw)
+	}
+	return req.SelectAttrValue("ID", "")
+}
+
+// TestSAMLLoginProvisionsUser runs a login through the ACS endpoint and checks the new user and JWT
+func TestSAMLLoginProvisionsUser(t *testing.T) {
+	idp := newSAMLIdP(t)
+	userRepo := repository.NewInMemoryUserRepository()
+	jwtService := testJWTService()
+	externalLogins := usecase.NewExternalLoginUseCase([]identity.Provider{newTestServiceProvider(t, idp)},
+		repository.NewInMemoryLinkedIdentityRepository(), repository.NewInMemoryLoginStateRepository(),
+		userRepo, jwtService, usecase.DefaultExternalLoginConfig())
+	externalLoginHandler := handler.NewExternalLoginHandler(externalLogins, true)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/acme/login", externalLoginHandler.Login)
+	mux.HandleFunc("/auth/acme/acs", externalLoginHandler.AssertionConsumer)
+	mux.HandleFunc("/auth/acme/metadata", externalLoginHandler.Metadata)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/acme/metadata", nil))
+	assertStatus(t, rec.Code, http.StatusOK, "metadata: expected status %d, got %d")
+	if !strings.Contains(rec.Body.String(), `entityID="`+samlSPEntityID+`"`) ||
+		!strings.Contains(rec.Body.String(), `Location="`+samlACSURL+`"`) {
+		t.Fatalf("Expected service provider metadata, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/acme/login", nil))
+	assertStatus(t, rec.Code, http.StatusFound, "start login: expected status %d, got %d")
+	requestID := samlRequestID(t, rec.Header().Get("Location"))
+	relayState, _ := url.Parse(rec.Header().Get("Location"))
+	cookies := rec.Result().Cookies()
+	// The ACS receives a cross-site POST, which only carries SameSite=None cookies
+	if len(cookies) != 1 || cookies[0].SameSite != http.SameSiteNoneMode || !cookies[0].Secure {
+		t.Fatalf("Expected a secure SameSite=None state cookie, got %v", cookies)
+	}
+
+	post := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		form := url.Values{
+			"SAMLResponse": {idp.issue(t, defaultAssertion(requestID))},
+			"RelayState":   {relayState.Query().Get("RelayState")},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/auth/acme/acs", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec = post(nil)
+	assertStatus(t, rec.Code, http.StatusBadRequest, "ACS without state cookie: expected status %d, got %d")
+
+	rec = post(cookies[0])
+	assertStatus(t, rec.Code, http.StatusOK, "ACS: expected status %d, got %d")
+	var resp struct {
+		Data handler.AuthResponseDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	ann, err := userRepo.FindByEmail(tenant.DefaultID, "ann@example.com")
+	if err != nil || ann.FirstName != "Ann" || ann.LastName != "Lee" || ann.Password != "" {
+		t.Fatalf("Expected a provisioned passwordless user from the attributes, got %+v, %v", ann, err)
+	}
+	if mustClaims(t, resp.Data.Token).Subject != ann.ID {
+		t.Error("Expected a JWT for the provisioned user")
+	}
+
+	// The login state is single use
+	rec = post(cookies[0])
+	assertStatus(t, rec.Code, http.StatusBadRequest, "replayed ACS post: expected status %d, got %d")
+}
+
+// TestSAMLResponseValidation covers signatures, audience, recipient, validity, request binding and replays
+func TestSAMLResponseValidation(t *testing.T) {
+	idp := newSAMLIdP(t)
+	otherIdP := newSAMLIdP(t)
+	sp := newTestServiceProvider(t, idp)
+	ctx := context.Background()
+
+	// begin starts a login at the service provider and returns the nonce and AuthnRequest ID
+	begin := func(t *testing.T) (string, string) {
+		nonce := "nonce-" + t.Name()
+		redirectURL, err := sp.AuthCodeURL(ctx, "state", nonce, "")
+		if err != nil {
+			t.Fatalf("AuthCodeURL failed: %v", err)
+		}
+		return nonce, samlRequestID(t, redirectURL)
+	}
+
+	t.Run("signed response", func(t *testing.T) {
+		nonce, requestID := begin(t)
+		a := defaultAssertion(requestID)
+		a.SignResponse = true
+		profile, err := sp.Exchange(ctx, idp.issue(t, a), "", nonce)
+		if err != nil {
+			t.Fatalf("Expected a signed response to be accepted: %v", err)
+		}
+		if profile.Subject != "ann@example.com" || profile.Email != "ann@example.com" || !profile.EmailVerified {
+			t.Errorf("Unexpected profile %+v", profile)
+		}
+	})
+
+	rejected := []struct {
+		name string
+		edit func(a *samlAssertion)
+		idp  *samlIdP
+	}{
+		{"unsigned", func(a *samlAssertion) { a.Unsigned = true }, idp},
+		{"untrusted key", func(a *samlAssertion) {}, otherIdP},
+		{"other audience", func(a *samlAssertion) { a.Audience = "https://other.example.com" }, idp},
+		{"other recipient", func(a *samlAssertion) { a.Recipient = "https://other.example.com/acs" }, idp},
+		{"expired", func(a *samlAssertion) { a.NotOnOrAfter = time.Now().Add(-10 * time.Minute) }, idp},
+		{"other request", func(a *samlAssertion) { a.InResponseTo = "_unsolicited" }, idp},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			nonce, requestID := begin(t)
+			a := defaultAssertion(requestID)
+			tt.edit(&a)
+			if _, err := sp.Exchange(ctx, tt.idp.issue(t, a), "", nonce); err == nil {
+				t.Error("Expected the response to be rejected")
+			}
+		})
+	}
+
+	t.Run("tampered assertion", func(t *testing.T) {
+		nonce, requestID := begin(t)
+		raw := idp.issueXML(t, defaultAssertion(requestID), func(response *etree.Element) {
+			for _, el := range response.FindElements("//NameID") {
+				el.SetText("admin@example.com")
+			}
+		})
+		if _, err := sp.Exchange(ctx, base64.StdEncoding.EncodeToString(raw), "", nonce); err == nil {
+			t.Error("Expected a modified assertion to fail signature validation")
+		}
+	})
+
+	t.Run("wrapped assertion", func(t *testing.T) {
+		nonce, requestID := begin(t)
+		// An attacker adds their own assertion next to the signed one
+		forged := defaultAssertion(requestID)
+		forged.Email = "admin@example.com"
+		forged.Unsigned = true
+		forgedDoc := etree.NewDocument()
+		forgedDoc.ReadFromBytes(idp.issueXML(t, forged, nil))
+		injected := forgedDoc.Root().ChildElements()[2]
+
+		raw := idp.issueXML(t, defaultAssertion(requestID), func(response *etree.Element) {
+			response.InsertChildAt(0, injected)
+		})
+		if _, err := sp.Exchange(ctx, base64.StdEncoding.EncodeToString(raw), "", nonce); err == nil {
+			t.Error("Expected a response with an injected assertion to be rejected")
+		}
+	})
+
+	t.Run("replayed assertion", func(t *testing.T) {
+		nonce, requestID := begin(t)
+		response := idp.issue(t, defaultAssertion(requestID))
+		if _, err := sp.Exchange(ctx, response, "", nonce); err != nil {
+			t.Fatalf("Expected the first use to succeed: %v", err)
+		}
+		if _, err := sp.Exchange(ctx, response, "", nonce); err == nil {
+			t.Error("Expected a replayed assertion to be rejected")
+		}
+	})
+}
@@ -0,0 +1,356 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/session"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// scimServer wires the SCIM endpoints behind API key authentication
+type scimServer struct {
+	*apiKeyServer
+	// key is a service API key with the scim scope
+	key string
+}
+
+func newSCIMServer(t *testing.T) *scimServer {
+	s := &scimServer{apiKeyServer: newAPIKeyServer(t)}
+	scimUseCase := usecase.NewSCIMUseCase(s.userRepo, repository.NewInMemoryGroupRepository(), s.users)
+	scimHandler := handler.NewSCIMHandler(scimUseCase, "https://id.example.com"+handler.SCIMBasePath)
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		s.mux.Handle(method+" "+handler.SCIMBasePath+"/Users", s.protect(scimHandler.Users))
+		s.mux.Handle(method+" "+handler.SCIMBasePath+"/Groups", s.protect(scimHandler.Groups))
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		s.mux.Handle(method+" "+handler.SCIMBasePath+"/Users/{id}", s.protect(scimHandler.User))
+		s.mux.Handle(method+" "+handler.SCIMBasePath+"/Groups/{id}", s.protect(scimHandler.Group))
+	}
+
+	adminToken := s.login(t, "admin@example.com", user.RoleAdmin)
+	created, code := s.createKey(t, adminToken, handler.CreateAPIKeyRequest{
+		Name: "directory", ServiceName: "directory", Scopes: []string{usecase.ScopeSCIM},
+	})
+	assertStatus(t, code, http.StatusCreated, "create scim key: expected status %d, got %d")
+	s.key = created.Key
+	return s
+}
+
+// scim sends a request with the service key and decodes the response into v, if given
+func (s *scimServer) scim(t *testing.T, method, path string, header http.Header, body interface{}, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	h := bearer(s.key)
+	for k, values := range header {
+		h[k] = values
+	}
+	rec := s.do(method, handler.SCIMBasePath+path, h, body)
+	if v != nil && rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: invalid response %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec
+}
+
+func (s *scimServer) createUser(t *testing.T, userName, givenName, familyName string) handler.SCIMUserDTO {
+	t.Helper()
+	var created handler.SCIMUserDTO
+	rec := s.scim(t, http.MethodPost, "/Users", nil, map[string]interface{}{
+		"schemas":  []string{handler.SCIMUserSchema},
+		"userName": userName,
+		"name":     map[string]string{"givenName": givenName, "familyName": familyName},
+		"active":   true,
+	}, &created)
+	assertStatus(t, rec.Code, http.StatusCreated, "create user: expected status %d, got %d")
+	return created
+}
+
+func patchOp(op, path string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"schemas":    []string{handler.SCIMPatchSchema},
+		"Operations": []map[string]interface{}{{"op": op, "path": path, "value": value}},
+	}
+}
+
+// TestSCIMUserLifecycle verifies users can be provisioned, updated and deprovisioned, and that
+// deprovisioning disables the account and signs it out instead of deleting it
+func TestSCIMUserLifecycle(t *testing.T) {
+	s := newSCIMServer(t)
+
+	var created handler.SCIMUserDTO
+	rec := s.scim(t, http.MethodPost, "/Users", nil, map[string]interface{}{
+		"schemas":    []string{handler.SCIMUserSchema},
+		"userName":   "Bob@Example.com",
+		"externalId": "00u1",
+		"name":       map[string]string{"givenName": "Bob", "familyName": "Stone"},
+	}, &created)
+	assertStatus(t, rec.Code, http.StatusCreated, "create: expected status %d, got %d")
+	if created.UserName != "bob@example.com" || created.Active == nil || !*created.Active || created.ExternalID != "00u1" {
+		t.Fatalf("Unexpected created user: %+v", created)
+	}
+	if rec.Header().Get("Content-Type") != "application/scim+json" {
+		t.Errorf("Expected a SCIM content type, got %q", rec.Header().Get("Content-Type"))
+	}
+	if rec.Header().Get("Location") != "https://id.example.com/scim/v2/Users/"+created.ID || rec.Header().Get("ETag") != created.Meta.Version {
+		t.Errorf("Unexpected Location %q or ETag %q", rec.Header().Get("Location"), rec.Header().Get("ETag"))
+	}
+
+	rec = s.scim(t, http.MethodPost, "/Users", nil, map[string]interface{}{"userName": "bob@example.com"}, nil)
+	assertStatus(t, rec.Code, http.StatusConflict, "duplicate userName: expected status %d, got %d")
+
+	// Entra ID sends booleans as strings and paths in the URN form
+	var patched handler.SCIMUserDTO
+	rec = s.scim(t, http.MethodPatch, "/Users/"+created.ID, nil, map[string]interface{}{
+		"schemas": []string{handler.SCIMPatchSchema},
+		"Operations": []map[string]interface{}{
+			{"op": "Replace", "path": "name.familyName", "value": "Rivers"},
+			{"op": "Replace", "path": `emails[type eq "work"].value`, "value": "bob.rivers@example.com"},
+		},
+	}, &patched)
+	assertStatus(t, rec.Code, http.StatusOK, "patch: expected status %d, got %d")
+	if patched.UserName != "bob.rivers@example.com" || patched.Name.FamilyName != "Rivers" {
+		t.Fatalf("Unexpected patched user: %+v", patched)
+	}
+	if _, err := s.userRepo.FindByEmail(tenant.DefaultID, "bob.rivers@example.com"); err != nil {
+		t.Fatalf("Renamed user not found by its new email: %v", err)
+	}
+
+	// Give the user a password, a refresh token and a session, then deprovision
+	hash, err := auth.NewPasswordService(testArgonParams).HashPassword(testPassword)
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	u, _ := s.userRepo.FindByID(tenant.DefaultID, created.ID)
+	u.Password = hash
+	if err := s.userRepo.Update(u); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	login, err := s.users.Login(tenant.DefaultID, "bob.rivers@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	now := time.Now()
+	if err := s.sessionRepo.Save(&session.Session{ID: "s1", UserID: created.ID, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Saving session failed: %v", err)
+	}
+	rec = s.do(http.MethodGet, "/profile", bearer(login.Token), nil)
+	assertStatus(t, rec.Code, http.StatusOK, "profile before deprovisioning: expected status %d, got %d")
+
+	rec = s.scim(t, http.MethodPatch, "/Users/"+created.ID, nil, patchOp("replace", "", map[string]interface{}{"active": "False"}), &patched)
+	assertStatus(t, rec.Code, http.StatusOK, "deactivate: expected status %d, got %d")
+	if patched.Active == nil || *patched.Active {
+		t.Fatalf("Expected the user to be inactive: %+v", patched)
+	}
+	if sessions, _ := s.sessionRepo.FindByUser(created.ID); len(sessions) != 0 {
+		t.Errorf("Expected the sessions of a deactivated user to be revoked, found %d", len(sessions))
+	}
+	if _, err := s.users.Refresh(tenant.DefaultID, login.RefreshToken); !errors.Is(err, usecase.ErrInvalidRefreshToken) {
+		t.Errorf("Expected the refresh tokens of a deactivated user to be revoked, got %v", err)
+	}
+	if _, err := s.users.Login(tenant.DefaultID, "bob.rivers@example.com", testPassword); !errors.Is(err, usecase.ErrAccountDisabled) {
+		t.Errorf("Expected ErrAccountDisabled logging in as a deactivated user, got %v", err)
+	}
+	rec = s.do(http.MethodGet, "/profile", bearer(login.Token), nil)
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "profile with a token issued before deactivation: expected status %d, got %d")
+
+	// Re-activation restores access; DELETE disables again but keeps the account
+	rec = s.scim(t, http.MethodPatch, "/Users/"+created.ID, nil, patchOp("replace", "active", true), nil)
+	assertStatus(t, rec.Code, http.StatusOK, "reactivate: expected status %d, got %d")
+	if _, err := s.users.Login(tenant.DefaultID, "bob.rivers@example.com", testPassword); err != nil {
+		t.Errorf("Expected a reactivated user to log in, got %v", err)
+	}
+	rec = s.do(http.MethodGet, "/profile", bearer(login.Token), nil)
+	assertStatus(t, rec.Code, http.StatusOK, "profile after reactivation: expected status %d, got %d")
+
+	rec = s.scim(t, http.MethodDelete, "/Users/"+created.ID, nil, nil, nil)
+	assertStatus(t, rec.Code, http.StatusNoContent, "delete: expected status %d, got %d")
+	u, err = s.userRepo.FindByID(tenant.DefaultID, created.ID)
+	if err != nil || !u.Disabled {
+		t.Fatalf("Expected a deleted user to be kept and disabled, got %+v, %v", u, err)
+	}
+	rec = s.do(http.MethodGet, "/profile", bearer(login.Token), nil)
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "profile with a token issued before deletion: expected status %d, got %d")
+
+	rec = s.scim(t, http.MethodGet, "/Users/unknown", nil, nil, nil)
+	assertStatus(t, rec.Code, http.StatusNotFound, "unknown user: expected status %d, got %d")
+}
+
+// TestSCIMAuthorization verifies only services and admins holding the scim scope may provision
+func TestSCIMAuthorization(t *testing.T) {
+	s := newSCIMServer(t)
+
+	rec := s.do(http.MethodGet, handler.SCIMBasePath+"/Users", nil, nil)
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "no credential: expected status %d, got %d")
+
+	userToken := s.login(t, "carol@example.com")
+	rec = s.do(http.MethodGet, handler.SCIMBasePath+"/Users", bearer(userToken), nil)
+	assertStatus(t, rec.Code, http.StatusForbidden, "regular user: expected status %d, got %d")
+
+	adminToken := s.login(t, "root@example.com", user.RoleAdmin)
+	other, code := s.createKey(t, adminToken, handler.CreateAPIKeyRequest{Name: "billing", ServiceName: "billing", Scopes: []string{"billing"}})
+	assertStatus(t, code, http.StatusCreated, "create key: expected status %d, got %d")
+	rec = s.do(http.MethodGet, handler.SCIMBasePath+"/Users", bearer(other.Key), nil)
+	assertStatus(t, rec.Code, http.StatusForbidden, "key without scim scope: expected status %d, got %d")
+
+	var errResp handler.SCIMErrorDTO
+	json.Unmarshal(rec.Body.Bytes(), &errResp)
+	if errResp.Status != "403" || len(errResp.Schemas) != 1 || errResp.Schemas[0] != handler.SCIMErrorSchema {
+		t.Errorf("Unexpected SCIM error: %+v", errResp)
+	}
+
+	rec = s.do(http.MethodGet, handler.SCIMBasePath+"/Users", bearer(adminToken), nil)
+	assertStatus(t, rec.Code, http.StatusOK, "admin: expected status %d, got %d")
+}
+
+// TestSCIMFilteringAndPagination verifies list filters and 1-based pagination
+func TestSCIMFilteringAndPagination(t *testing.T) {
+	s := newSCIMServer(t)
+	s.createUser(t, "amy@example.com", "Amy", "Ng")
+	s.createUser(t, "andy@example.com", "Andy", "Ng")
+	ben := s.createUser(t, "ben@example.com", "Ben", "Ode")
+	s.scim(t, http.MethodPatch, "/Users/"+ben.ID, nil, patchOp("replace", "active", false), nil)
+
+	tests := []struct {
+		filter string
+		want   int
+	}{
+		{`userName eq "AMY@example.com"`, 1},
+		{`userName sw "a" and name.familyName eq "Ng"`, 2},
+		{`name.familyName eq "Ode" or userName co "andy"`, 2},
+		{`not (active eq true) and emails.value ew "@example.com"`, 1},
+		{`(userName sw "a" or userName sw "b") and active eq true`, 3},
+		{`externalId pr`, 0},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "ben@example.com"`, 1},
+	}
+	for _, tt := range tests {
+		var list struct {
+			TotalResults int                   `json:"totalResults"`
+			Resources    []handler.SCIMUserDTO `json:"Resources"`
+		}
+		rec := s.scim(t, http.MethodGet, "/Users?filter="+url.QueryEscape(tt.filter), nil, nil, &list)
+		assertStatus(t, rec.Code, http.StatusOK, "filter: expected status %d, got %d")
+		if list.TotalResults != tt.want || len(list.Resources) != tt.want {
+			t.Errorf("Filter %s: expected %d results, got %d", tt.filter, tt.want, list.TotalResults)
+		}
+	}
+
+	var errResp handler.SCIMErrorDTO
+	rec := s.scim(t, http.MethodGet, "/Users?filter="+url.QueryEscape(`userName eq`), nil, nil, &errResp)
+	assertStatus(t, rec.Code, http.StatusBadRequest, "invalid filter: expected status %d, got %d")
+	if errResp.SCIMType != usecase.SCIMInvalidFilter {
+		t.Errorf("Expected scimType %s, got %+v", usecase.SCIMInvalidFilter, errResp)
+	}
+
+	// Four users exist, including the admin who created the key
+	var page handler.SCIMListResponseDTO
+	var resources []handler.SCIMUserDTO
+	page.Resources = &resources
+	s.scim(t, http.MethodGet, "/Users?startIndex=2&count=2", nil, nil, &page)
+	if page.TotalResults != 4 || page.StartIndex != 2 || page.ItemsPerPage != 2 || len(resources) != 2 {
+		t.Errorf("Unexpected page: %+v", page)
+	}
+	if resources[0].UserName != "amy@example.com" || resources[1].UserName != "andy@example.com" {
+		t.Errorf("Unexpected page contents: %s, %s", resources[0].UserName, resources[1].UserName)
+	}
+}
+
+// TestSCIMGroups verifies group provisioning and membership changes
+func TestSCIMGroups(t *testing.T) {
+	s := newSCIMServer(t)
+	amy := s.createUser(t, "amy@example.com", "Amy", "Ng")
+	ben := s.createUser(t, "ben@example.com", "Ben", "Ode")
+
+	var group handler.SCIMGroupDTO
+	rec := s.scim(t, http.MethodPost, "/Groups", nil, map[string]interface{}{
+		"schemas":     []string{handler.SCIMGroupSchema},
+		"displayName": "Engineering",
+		"members":     []map[string]string{{"value": amy.ID}},
+	}, &group)
+	assertStatus(t, rec.Code, http.StatusCreated, "create group: expected status %d, got %d")
+	if len(group.Members) != 1 || group.Members[0].Display != "amy@example.com" {
+		t.Fatalf("Unexpected group: %+v", group)
+	}
+
+	rec = s.scim(t, http.MethodPost, "/Groups", nil, map[string]interface{}{"displayName": "engineering"}, nil)
+	assertStatus(t, rec.Code, http.StatusConflict, "duplicate group: expected status %d, got %d")
+	rec = s.scim(t, http.MethodPost, "/Groups", nil, map[string]interface{}{
+		"displayName": "Sales", "members": []map[string]string{{"value": "nobody"}},
+	}, nil)
+	assertStatus(t, rec.Code, http.StatusBadRequest, "unknown member: expected status %d, got %d")
+
+	rec = s.scim(t, http.MethodPatch, "/Groups/"+group.ID, nil, patchOp("add", "members", []map[string]string{{"value": ben.ID}}), &group)
+	assertStatus(t, rec.Code, http.StatusOK, "add member: expected status %d, got %d")
+	if len(group.Members) != 2 {
+		t.Fatalf("Expected two members, got %+v", group.Members)
+	}
+
+	var member handler.SCIMUserDTO
+	s.scim(t, http.MethodGet, "/Users/"+ben.ID, nil, nil, &member)
+	if len(member.Groups) != 1 || member.Groups[0].Display != "Engineering" {
+		t.Errorf("Expected the user to list its group, got %+v", member.Groups)
+	}
+
+	var list struct {
+		TotalResults int                    `json:"totalResults"`
+		Resources    []handler.SCIMGroupDTO `json:"Resources"`
+	}
+	filter := url.QueryEscape(`displayName eq "Engineering" and members[value eq "` + ben.ID + `"]`)
+	s.scim(t, http.MethodGet, "/Groups?excludedAttributes=members&filter="+filter, nil, nil, &list)
+	if list.TotalResults != 1 || len(list.Resources[0].Members) != 0 {
+		t.Errorf("Expected one group without members, got %+v", list)
+	}
+
+	rec = s.scim(t, http.MethodPatch, "/Groups/"+group.ID, nil, patchOp("remove", `members[value eq "`+amy.ID+`"]`, nil), &group)
+	assertStatus(t, rec.Code, http.StatusOK, "remove member: expected status %d, got %d")
+	if len(group.Members) != 1 || group.Members[0].Value != ben.ID {
+		t.Fatalf("Expected only ben to remain, got %+v", group.Members)
+	}
+
+	rec = s.scim(t, http.MethodPatch, "/Groups/"+group.ID, nil, patchOp("replace", "", map[string]interface{}{"displayName": "Platform"}), &group)
+	assertStatus(t, rec.Code, http.StatusOK, "rename: expected status %d, got %d")
+	if group.DisplayName != "Platform" {
+		t.Errorf("Expected the group to be renamed, got %q", group.DisplayName)
+	}
+
+	rec = s.scim(t, http.MethodDelete, "/Groups/"+group.ID, nil, nil, nil)
+	assertStatus(t, rec.Code, http.StatusNoContent, "delete group: expected status %d, got %d")
+	rec = s.scim(t, http.MethodGet, "/Groups/"+group.ID, nil, nil, nil)
+	assertStatus(t, rec.Code, http.StatusNotFound, "deleted group: expected status %d, got %d")
+}
+
+// TestSCIMETags verifies conditional requests against resource versions
+func TestSCIMETags(t *testing.T) {
+	s := newSCIMServer(t)
+	created := s.createUser(t, "amy@example.com", "Amy", "Ng")
+	version := created.Meta.Version
+
+	rec := s.scim(t, http.MethodGet, "/Users/"+created.ID, http.Header{"If-None-Match": {version}}, nil, nil)
+	assertStatus(t, rec.Code, http.StatusNotModified, "unchanged: expected status %d, got %d")
+
+	var updated handler.SCIMUserDTO
+	rec = s.scim(t, http.MethodPut, "/Users/"+created.ID, http.Header{"If-Match": {version}}, map[string]interface{}{
+		"userName": "amy@example.com",
+		"name":     map[string]string{"givenName": "Amy", "familyName": "Lee"},
+	}, &updated)
+	assertStatus(t, rec.Code, http.StatusOK, "matching version: expected status %d, got %d")
+	if updated.Meta.Version == version {
+		t.Fatal("Expected the version to change on update")
+	}
+
+	rec = s.scim(t, http.MethodPatch, "/Users/"+created.ID, http.Header{"If-Match": {version}}, patchOp("replace", "active", false), nil)
+	assertStatus(t, rec.Code, http.StatusPreconditionFailed, "stale version: expected status %d, got %d")
+	rec = s.scim(t, http.MethodDelete, "/Users/"+created.ID, http.Header{"If-Match": {version}}, nil, nil)
+	assertStatus(t, rec.Code, http.StatusPreconditionFailed, "stale delete: expected status %d, got %d")
+}
@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/session"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+)
+
+// sessionServer wires the session endpoints next to protected endpoints
+type sessionServer struct {
+	*testServer
+}
+
+func newSessionServer(t *testing.T) *sessionServer {
+	t.Helper()
+	s := &sessionServer{testServer: newTestServer(t)}
+	if _, err := s.users.Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	sessionHandler := handler.NewSessionHandler(s.sessions, handler.DefaultSessionCookieConfig())
+	passwordHandler := handler.NewPasswordHandler(s.users)
+	s.mux.HandleFunc("POST /session/login", sessionHandler.Login)
+	s.mux.Handle("POST /session/logout", s.protect(sessionHandler.Logout))
+	s.mux.Handle("GET /session", s.protect(sessionHandler.Current))
+	s.mux.Handle("GET /sessions", s.protect(sessionHandler.List))
+	s.mux.Handle("DELETE /sessions/{id}", s.protect(sessionHandler.Terminate))
+	s.mux.Handle("GET /profile", s.protect(handler.NewProtectedHandler().Profile))
+	s.mux.Handle("POST /password/change", s.protect(passwordHandler.ChangePassword))
+	return s
+}
+
+// startSession logs in with a session and returns its cookie and CSRF token
+func (s *sessionServer) startSession(t *testing.T) (*http.Cookie, string) {
+	t.Helper()
+	rec := s.do(http.MethodPost, "/session/login", nil, handler.LoginRequest{Email: "ann@example.com", Password: testPassword})
+	assertStatus(t, rec.Code, http.StatusOK, "session login: expected status %d, got %d")
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected one cookie, got %v", cookies)
+	}
+	var resp struct {
+		Data handler.SessionLoginResponseDTO `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Data.CSRFToken == "" {
+		t.Fatalf("Expected a CSRF token in the login response: %s", rec.Body.String())
+	}
+	if bytes.Contains(rec.Body.Bytes(), []byte(cookies[0].Value)) {
+		t.Error("Session token must only be sent in the cookie")
+	}
+	return cookies[0], resp.Data.CSRFToken
+}
+
+// withCookie sends a request with a raw body and the session cookie, if given
+func (s *sessionServer) withCookie(method, path string, cookie *http.Cookie, header http.Header, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return s.serve(req)
+}
+
+// TestSessionCookieAndCSRF verifies cookie attributes, cookie authentication and CSRF enforcement
+func TestSessionCookieAndCSRF(t *testing.T) {
+	s := newSessionServer(t)
+	cookie, csrfToken := s.startSession(t)
+
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" {
+		t.Errorf("Session cookie attributes are not hardened: %+v", cookie)
+	}
+
+	rec := s.withCookie(http.MethodGet, "/profile", cookie, nil, "")
+	assertStatus(t, rec.Code, http.StatusOK, "profile with session cookie: expected status %d, got %d")
+
+	rec = s.withCookie(http.MethodGet, "/session", cookie, nil, "")
+	assertStatus(t, rec.Code, http.StatusOK, "current session: expected status %d, got %d")
+	if !bytes.Contains(rec.Body.Bytes(), []byte(csrfToken)) {
+		t.Error("Current session should return the CSRF token")
+	}
+
+	change := `{"current_password":"` + testPassword + `","new_password":"Kq8!vR3#pW6&"}`
+	rec = s.withCookie(http.MethodPost, "/password/change", cookie, nil, change)
+	assertStatus(t, rec.Code, http.StatusForbidden, "state change without CSRF token: expected status %d, got %d")
+	rec = s.withCookie(http.MethodPost, "/password/change", cookie, http.Header{"X-Csrf-Token": {"wrong"}}, change)
+	assertStatus(t, rec.Code, http.StatusForbidden, "state change with wrong CSRF token: expected status %d, got %d")
+	rec = s.withCookie(http.MethodPost, "/password/change", cookie, http.Header{"X-Csrf-Token": {csrfToken}}, change)
+	assertStatus(t, rec.Code, http.StatusOK, "state change with CSRF token: expected status %d, got %d")
+
+	// Bearer tokens cannot be sent by another site, so they need no CSRF token
+	authResp, err := s.users.Login(tenant.DefaultID, "ann@example.com", "Kq8!vR3#pW6&")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	rec = s.withCookie(http.MethodPost, "/password/change", nil, bearer(authResp.Token),
+		`{"current_password":"Kq8!vR3#pW6&","new_password":"`+testPassword+`"}`)
+	assertStatus(t, rec.Code, http.StatusOK, "state change with bearer token: expected status %d, got %d")
+
+	rec = s.withCookie(http.MethodPost, "/session/logout", cookie, http.Header{"X-Csrf-Token": {csrfToken}}, "")
+	assertStatus(t, rec.Code, http.StatusOK, "logout: expected status %d, got %d")
+	if cleared := rec.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Errorf("Logout should clear the cookie, got %v", cleared)
+	}
+	rec = s.withCookie(http.MethodGet, "/profile", cookie, nil, "")
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "profile after logout: expected status %d, got %d")
+}
+
+// TestSessionListingAndTermination verifies users can see and end their other sessions
+func TestSessionListingAndTermination(t *testing.T) {
+	s := newSessionServer(t)
+	laptop, laptopCSRF := s.startSession(t)
+	phone, _ := s.startSession(t)
+
+	rec := s.withCookie(http.MethodGet, "/sessions", laptop, nil, "")
+	assertStatus(t, rec.Code, http.StatusOK, "list sessions: expected status %d, got %d")
+	var resp struct {
+		Data []handler.SessionDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if len(resp.Data) != 2 {
+		t.Fatalf("Expected 2 sessions, got %+v", resp.Data)
+	}
+
+	var phoneID string
+	for _, listed := range resp.Data {
+		if !listed.Current {
+			phoneID = listed.ID
+		}
+	}
+	if phoneID == "" {
+		t.Fatal("Expected exactly one session to be marked current")
+	}
+
+	rec = s.withCookie(http.MethodDelete, "/sessions/"+phoneID, laptop, nil, "")
+	assertStatus(t, rec.Code, http.StatusForbidden, "terminate without CSRF token: expected status %d, got %d")
+	rec = s.withCookie(http.MethodDelete, "/sessions/"+phoneID, laptop, http.Header{"X-Csrf-Token": {laptopCSRF}}, "")
+	assertStatus(t, rec.Code, http.StatusOK, "terminate other session: expected status %d, got %d")
+
+	rec = s.withCookie(http.MethodGet, "/profile", phone, nil, "")
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "profile with terminated session: expected status %d, got %d")
+	rec = s.withCookie(http.MethodGet, "/profile", laptop, nil, "")
+	assertStatus(t, rec.Code, http.StatusOK, "profile with remaining session: expected status %d, got %d")
+}
+
+// TestSessionsRequireInteractiveUser verifies scoped credentials acting for a user, such as API
+// keys, can neither list nor end the user's sessions
+func TestSessionsRequireInteractiveUser(t *testing.T) {
+	s := newSessionServer(t)
+	s.startSession(t)
+	ann, _ := s.userRepo.FindByEmail(tenant.DefaultID, "ann@example.com")
+
+	stored, _ := s.sessionRepo.FindByUser(ann.ID)
+	if len(stored) != 1 {
+		t.Fatalf("Expected one session, got %d", len(stored))
+	}
+
+	apiKey := &auth.Principal{Type: auth.PrincipalUser, Subject: ann.ID, TenantID: tenant.DefaultID,
+		AuthMethod: auth.AuthMethodAPIKey, Scopes: []string{"sessions"}}
+	if _, err := s.sessions.List(apiKey); !errors.Is(err, usecase.ErrForbidden) {
+		t.Errorf("Expected an API key to be forbidden from listing sessions, got %v", err)
+	}
+	if err := s.sessions.Terminate(apiKey, stored[0].ID); !errors.Is(err, usecase.ErrForbidden) {
+		t.Errorf("Expected an API key to be forbidden from ending sessions, got %v", err)
+	}
+	if remaining, _ := s.sessionRepo.FindByUser(ann.ID); len(remaining) != 1 {
+		t.Errorf("Expected the session to remain, got %d", len(remaining))
+	}
+
+	login := &auth.Principal{Type: auth.PrincipalUser, Subject: ann.ID, TenantID: tenant.DefaultID}
+	if listed, err := s.sessions.List(login); err != nil || len(listed) != 1 {
+		t.Errorf("Expected the signed in user to list the session, got %+v, %v", listed, err)
+	}
+}
+
+// TestSessionTimeouts verifies both the idle and the absolute timeout end a session
+func TestSessionTimeouts(t *testing.T) {
+	s := newSessionServer(t)
+	userID := userIDFor(t, s)
+
+	// expireAll rewrites the timestamps of every stored session of the test user
+	expireAll := func(change func(stored *session.Session)) {
+		sessions, _ := s.sessionRepo.FindByUser(userID)
+		for _, stored := range sessions {
+			change(stored)
+			if err := s.sessionRepo.Update(stored); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+		}
+	}
+
+	idle, _ := s.startSession(t)
+	expireAll(func(stored *session.Session) { stored.LastSeenAt = time.Now().Add(-time.Hour) })
+	rec := s.withCookie(http.MethodGet, "/profile", idle, nil, "")
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "profile after idle timeout: expected status %d, got %d")
+
+	expired, _ := s.startSession(t)
+	expireAll(func(stored *session.Session) { stored.ExpiresAt = time.Now().Add(-time.Second) })
+	rec = s.withCookie(http.MethodGet, "/profile", expired, nil, "")
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "profile after absolute timeout: expected status %d, got %d")
+}
+
+// userIDFor returns the ID of the test user
+func userIDFor(t *testing.T, s *sessionServer) string {
+	t.Helper()
+	authResp, err := s.users.Login(tenant.DefaultID, "ann@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	return mustClaims(t, authResp.Token).Subject
+}
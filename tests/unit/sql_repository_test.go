@@ -0,0 +1,272 @@
+package tests
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/authz"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/identity"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/session"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDSN returns a database file in a temporary directory, so every test starts empty
+func sqliteDSN(t *testing.T) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), "test.db")
+}
+
+func TestSQLSessionRepository(t *testing.T) {
+	repo, err := repository.OpenSQLSessionRepository("sqlite3", sqliteDSN(t))
+	if err != nil {
+		t.Fatalf("OpenSQLSessionRepository failed: %v", err)
+	}
+	// Creating the schema again is a no-op
+	if err := repo.CreateSchema(); err != nil {
+		t.Fatalf("CreateSchema failed: %v", err)
+	}
+
+	now := time.Now()
+	older := &session.Session{ID: "s1", UserID: "u1", CSRFToken: "csrf-1", UserAgent: "curl", IPAddress: "10.0.0.1",
+		CreatedAt: now.Add(-time.Hour), LastSeenAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}
+	newer := &session.Session{ID: "s2", UserID: "u1", CSRFToken: "csrf-2", CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+	other := &session.Session{ID: "s3", UserID: "u2", CSRFToken: "csrf-3", CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(-time.Minute)}
+	for _, s := range []*session.Session{older, newer, other} {
+		if err := repo.Save(s); err != nil {
+			t.Fatalf("Save %s failed: %v", s.ID, err)
+		}
+	}
+	if err := repo.Save(older); err == nil {
+		t.Errorf("Expected a duplicate session ID to be rejected")
+	}
+
+	found, err := repo.FindByID("s1")
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if found.UserID != "u1" || found.CSRFToken != "csrf-1" || found.UserAgent != "curl" || found.IPAddress != "10.0.0.1" ||
+		!found.CreatedAt.Equal(older.CreatedAt) || !found.ExpiresAt.Equal(older.ExpiresAt) {
+		t.Errorf("Expected the stored session, got %+v", found)
+	}
+	if _, err := repo.FindByID("missing"); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Sessions of a user are listed most recently used first
+	sessions, err := repo.FindByUser("u1")
+	if err != nil {
+		t.Fatalf("FindByUser failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "s2" || sessions[1].ID != "s1" {
+		t.Errorf("Expected s2 then s1, got %+v", sessions)
+	}
+
+	older.CSRFToken, older.LastSeenAt = "rotated", now.Add(time.Minute)
+	if err := repo.Update(older); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if found, _ := repo.FindByID("s1"); found.CSRFToken != "rotated" || !found.LastSeenAt.Equal(older.LastSeenAt) {
+		t.Errorf("Expected the update to be stored, got %+v", found)
+	}
+	if err := repo.Update(&session.Session{ID: "missing"}); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when updating a missing session, got %v", err)
+	}
+
+	// s3 has expired and s2 has been idle for longer than a second
+	if err := repo.DeleteExpired(now.Add(30*time.Second), time.Second); err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	}
+	if _, err := repo.FindByID("s3"); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected the expired session to be removed, got %v", err)
+	}
+	if _, err := repo.FindByID("s2"); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected the idle session to be removed, got %v", err)
+	}
+
+	if err := repo.Delete("s1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.Delete("s1"); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when deleting twice, got %v", err)
+	}
+
+	if err := repo.Save(newer); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := repo.DeleteByUser("u1"); err != nil {
+		t.Fatalf("DeleteByUser failed: %v", err)
+	}
+	if remaining, _ := repo.FindByUser("u1"); len(remaining) != 0 {
+		t.Errorf("Expected no sessions left, got %d", len(remaining))
+	}
+}
+
+func TestSQLLinkedIdentityRepository(t *testing.T) {
+	repo, err := repository.OpenSQLLinkedIdentityRepository("sqlite3", sqliteDSN(t))
+	if err != nil {
+		t.Fatalf("OpenSQLLinkedIdentityRepository failed: %v", err)
+	}
+
+	now := time.Now()
+	github := &identity.LinkedIdentity{ID: "l1", TenantID: "default", UserID: "u1", Provider: "github", Subject: "42",
+		Email: "ada@example.com", CreatedAt: now, LastLoginAt: now}
+	google := &identity.LinkedIdentity{ID: "l2", TenantID: "default", UserID: "u1", Provider: "google", Subject: "abc",
+		Email: "ada@example.com", CreatedAt: now, LastLoginAt: now}
+	// The same provider account may be linked in another tenant
+	acme := &identity.LinkedIdentity{ID: "l3", TenantID: "acme", UserID: "u2", Provider: "github", Subject: "42",
+		Email: "ada@acme.example", CreatedAt: now, LastLoginAt: now}
+	for _, link := range []*identity.LinkedIdentity{google, github, acme} {
+		if err := repo.Save(link); err != nil {
+			t.Fatalf("Save %s failed: %v", link.ID, err)
+		}
+	}
+
+	duplicate := &identity.LinkedIdentity{ID: "l4", TenantID: "default", UserID: "u3", Provider: "github", Subject: "42", CreatedAt: now, LastLoginAt: now}
+	if err := repo.Save(duplicate); !errors.Is(err, identity.ErrAlreadyLinked) {
+		t.Errorf("Expected ErrAlreadyLinked, got %v", err)
+	}
+
+	found, err := repo.FindBySubject("default", "github", "42")
+	if err != nil {
+		t.Fatalf("FindBySubject failed: %v", err)
+	}
+	if found.ID != "l1" || found.UserID != "u1" || found.Email != "ada@example.com" || !found.CreatedAt.Equal(now) {
+		t.Errorf("Expected the default tenant's link, got %+v", found)
+	}
+	if _, err := repo.FindBySubject("default", "github", "43"); !errors.Is(err, identity.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Links of a user are ordered by provider
+	links, err := repo.FindByUser("u1")
+	if err != nil {
+		t.Fatalf("FindByUser failed: %v", err)
+	}
+	if len(links) != 2 || links[0].Provider != "github" || links[1].Provider != "google" {
+		t.Errorf("Expected the github and google links, got %+v", links)
+	}
+
+	github.Email, github.LastLoginAt = "lovelace@example.com", now.Add(time.Hour)
+	if err := repo.Update(github); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if found, _ := repo.FindBySubject("default", "github", "42"); found.Email != "lovelace@example.com" || !found.LastLoginAt.Equal(github.LastLoginAt) {
+		t.Errorf("Expected the update to be stored, got %+v", found)
+	}
+	if found, _ := repo.FindBySubject("acme", "github", "42"); found.Email != "ada@acme.example" {
+		t.Errorf("Expected the other tenant's link to be unchanged, got %+v", found)
+	}
+	if err := repo.Update(&identity.LinkedIdentity{TenantID: "default", Provider: "github", Subject: "43"}); !errors.Is(err, identity.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when updating a missing link, got %v", err)
+	}
+
+	if err := repo.Delete("u1", "github"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.Delete("u1", "github"); !errors.Is(err, identity.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when deleting twice, got %v", err)
+	}
+	if links, _ := repo.FindByUser("u1"); len(links) != 1 || links[0].Provider != "google" {
+		t.Errorf("Expected only the google link to remain, got %+v", links)
+	}
+}
+
+// parseTestTuples parses tuples of a tenant, failing the test on a malformed one
+func parseTestTuples(t *testing.T, tenantID string, specs ...string) []authz.Tuple {
+	t.Helper()
+	tuples := make([]authz.Tuple, len(specs))
+	for i, spec := range specs {
+		tuple, err := authz.ParseTuple(spec)
+		if err != nil {
+			t.Fatalf("ParseTuple(%s) failed: %v", spec, err)
+		}
+		tuple.TenantID = tenantID
+		tuples[i] = tuple
+	}
+	return tuples
+}
+
+func TestSQLTupleRepository(t *testing.T) {
+	repo, err := repository.OpenSQLTupleRepository("sqlite3", sqliteDSN(t))
+	if err != nil {
+		t.Fatalf("OpenSQLTupleRepository failed: %v", err)
+	}
+
+	tuples := parseTestTuples(t, tenant.DefaultID,
+		"document:readme#viewer@user:bob",
+		"document:readme#editor@user:alice",
+		"document:plan#viewer@team:platform#member",
+		"team:platform#member@user:alice",
+	)
+	// Writing a tuple twice leaves a single copy
+	if err := repo.Write(append(tuples, tuples[0])); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := repo.Write(parseTestTuples(t, "acme", "document:readme#viewer@user:bob")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	all, err := repo.Read(tenant.DefaultID, authz.TupleFilter{})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	var got []string
+	for _, tuple := range all {
+		if tuple.TenantID != tenant.DefaultID {
+			t.Errorf("Expected only tuples of the default tenant, got %+v", tuple)
+		}
+		got = append(got, tuple.String())
+	}
+	want := []string{
+		"document:plan#viewer@team:platform#member",
+		"document:readme#editor@user:alice",
+		"document:readme#viewer@user:bob",
+		"team:platform#member@user:alice",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// A subject filter matches the exact subject, including its relation
+	team, _ := authz.ParseSubject("team:platform")
+	if matched, _ := repo.Read(tenant.DefaultID, authz.TupleFilter{Subject: &team}); len(matched) != 0 {
+		t.Errorf("Expected team:platform not to match team:platform#member, got %+v", matched)
+	}
+	members, _ := authz.ParseSubject("team:platform#member")
+	if matched, _ := repo.Read(tenant.DefaultID, authz.TupleFilter{Subject: &members}); len(matched) != 1 || matched[0].Object.ID != "plan" {
+		t.Errorf("Expected the plan tuple, got %+v", matched)
+	}
+	if matched, _ := repo.Read(tenant.DefaultID, authz.TupleFilter{ObjectType: "document", ObjectID: "readme", Relation: "viewer"}); len(matched) != 1 {
+		t.Errorf("Expected one readme viewer, got %+v", matched)
+	}
+
+	// Deleting ignores tuples that do not exist
+	if err := repo.Delete(parseTestTuples(t, tenant.DefaultID, "document:readme#viewer@user:bob", "document:readme#viewer@user:carol")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if matched, _ := repo.Read(tenant.DefaultID, authz.TupleFilter{ObjectID: "readme"}); len(matched) != 1 || matched[0].Relation != "editor" {
+		t.Errorf("Expected only the editor tuple left on readme, got %+v", matched)
+	}
+	if matched, _ := repo.Read("acme", authz.TupleFilter{}); len(matched) != 1 {
+		t.Errorf("Expected the other tenant's tuple to be kept, got %+v", matched)
+	}
+
+	// Checks resolve usersets through the SQL store as they do in memory
+	policy, err := authz.ParsePolicy([]byte(testAuthzPolicy))
+	if err != nil {
+		t.Fatalf("ParsePolicy failed: %v", err)
+	}
+	uc := usecase.NewAuthzUseCase(policy, repo)
+	alice, _ := authz.ParseSubject("user:alice")
+	plan, _ := authz.ParseObject("document:plan")
+	if allowed, err := uc.Check(tenant.DefaultID, alice, "view", plan); err != nil || !allowed {
+		t.Errorf("Expected alice to view the plan through her team, got %v, %v", allowed, err)
+	}
+}
@@ -0,0 +1,261 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/middleware"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+)
+
+// tenantServer wires registration, login, a protected profile and the API key endpoints behind
+// the tenant middleware, with the tenants default, acme and globex
+type tenantServer struct {
+	*testServer
+	handler http.Handler
+}
+
+func newTenantServer(t *testing.T) *tenantServer {
+	t.Helper()
+	s := &tenantServer{testServer: newTestServer(t)}
+	tenantRepo := repository.NewInMemoryTenantRepository()
+	for _, id := range []string{tenant.DefaultID, "acme", "globex"} {
+		if err := tenantRepo.Save(&tenant.Tenant{ID: id, Name: id, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Save tenant failed: %v", err)
+		}
+	}
+
+	userHandler := handler.NewUserHandler(s.users)
+	apiKeyHandler := handler.NewAPIKeyHandler(s.apiKeys)
+	s.mux.HandleFunc("POST /register", userHandler.Register)
+	s.mux.HandleFunc("POST /login", userHandler.Login)
+	s.mux.Handle("GET /profile", s.protect(handler.NewProtectedHandler().Profile))
+	s.mux.Handle("GET /api-keys", s.protect(apiKeyHandler.Keys))
+	s.mux.Handle("POST /api-keys", s.protect(apiKeyHandler.Keys))
+
+	s.handler = middleware.NewTenantMiddleware(tenantRepo, middleware.TenantResolution{
+		Header:     "X-Tenant-ID",
+		PathPrefix: "/t/",
+		BaseDomain: "example.com",
+	}).Resolve(s.mux)
+	return s
+}
+
+// doAt sends a request through the tenant middleware to host, which selects a tenant by
+// subdomain when it is below example.com
+func (s *tenantServer) doAt(method, host, path string, header http.Header, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Host = host
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// register registers ann@example.com with a tenant-specific first name and returns a login token
+func (s *tenantServer) register(t *testing.T, tenantID, firstName string) string {
+	t.Helper()
+	header := http.Header{"X-Tenant-Id": {tenantID}}
+	rec := s.doAt(http.MethodPost, "localhost", "/register", header, map[string]string{
+		"first_name": firstName, "last_name": "Lee", "email": "ann@example.com", "password": testPassword,
+	})
+	assertStatus(t, rec.Code, http.StatusCreated, "register: expected status %d, got %d")
+
+	rec = s.doAt(http.MethodPost, "localhost", "/login", header, map[string]string{
+		"email": "ann@example.com", "password": testPassword,
+	})
+	assertStatus(t, rec.Code, http.StatusOK, "login: expected status %d, got %d")
+	var resp struct {
+		Data handler.AuthResponseDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp.Data.Token
+}
+
+// profileName returns the first name on the profile a credential reaches, and the status
+func (s *tenantServer) profileName(host, path string, header http.Header) (string, int) {
+	rec := s.doAt(http.MethodGet, host, path, header, nil)
+	var resp struct {
+		Data map[string]string `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp.Data["first_name"], rec.Code
+}
+
+// TestTenantIsolation verifies the same email registers separately in each tenant and that a
+// token only works in the tenant it was issued in
+func TestTenantIsolation(t *testing.T) {
+	s := newTenantServer(t)
+	acmeToken := s.register(t, "acme", "Acme")
+	globexToken := s.register(t, "globex", "Globex")
+
+	acme, err := s.userRepo.FindByEmail("acme", "ann@example.com")
+	if err != nil {
+		t.Fatalf("FindByEmail in acme failed: %v", err)
+	}
+	globex, err := s.userRepo.FindByEmail("globex", "ann@example.com")
+	if err != nil {
+		t.Fatalf("FindByEmail in globex failed: %v", err)
+	}
+	if acme.ID == globex.ID || acme.TenantID != "acme" || globex.TenantID != "globex" {
+		t.Fatalf("Expected two distinct tenant users, got %+v and %+v", acme, globex)
+	}
+	if _, err := s.userRepo.FindByEmail(tenant.DefaultID, "ann@example.com"); err == nil {
+		t.Error("Expected the default tenant to have no user ann@example.com")
+	}
+	if _, err := s.userRepo.FindByID("globex", acme.ID); err == nil {
+		t.Error("Expected an acme user ID not to resolve in globex")
+	}
+
+	claims := mustClaims(t, acmeToken)
+	if claims.TenantID != "acme" {
+		t.Errorf("Expected tenant_id acme in the token, got %q", claims.TenantID)
+	}
+
+	name, code := s.profileName("localhost", "/profile", http.Header{
+		"X-Tenant-Id":   {"acme"},
+		"Authorization": {"Bearer " + acmeToken},
+	})
+	assertStatus(t, code, http.StatusOK, "acme token at acme: expected status %d, got %d")
+	if name != "Acme" {
+		t.Errorf("Expected the acme profile, got %q", name)
+	}
+
+	// A valid token from one tenant is rejected by every other tenant
+	for _, header := range []http.Header{
+		{"X-Tenant-Id": {"globex"}, "Authorization": {"Bearer " + acmeToken}},
+		{"Authorization": {"Bearer " + acmeToken}},
+		{"X-Tenant-Id": {"acme"}, "Authorization": {"Bearer " + globexToken}},
+	} {
+		_, code := s.profileName("localhost", "/profile", header)
+		assertStatus(t, code, http.StatusUnauthorized, "token at another tenant: expected status %d, got %d")
+	}
+
+	// Logins only see the tenant's own accounts
+	rec := s.doAt(http.MethodPost, "localhost", "/login", nil, map[string]string{
+		"email": "ann@example.com", "password": testPassword,
+	})
+	assertStatus(t, rec.Code, http.StatusUnauthorized, "login at the default tenant: expected status %d, got %d")
+}
+
+// TestTenantAPIKeys verifies API keys are bound to the tenant they were created in
+func TestTenantAPIKeys(t *testing.T) {
+	s := newTenantServer(t)
+	token := s.register(t, "acme", "Acme")
+	s.register(t, "globex", "Globex")
+
+	acme := http.Header{"X-Tenant-Id": {"acme"}, "Authorization": {"Bearer " + token}}
+	rec := s.doAt(http.MethodPost, "localhost", "/api-keys", acme, handler.CreateAPIKeyRequest{
+		Name: "ci", Scopes: []string{"profile:read"},
+	})
+	assertStatus(t, rec.Code, http.StatusCreated, "create key: expected status %d, got %d")
+	var resp struct {
+		Data handler.CreatedAPIKeyDTO `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	_, code := s.profileName("localhost", "/profile", http.Header{"X-Tenant-Id": {"acme"}, "X-Api-Key": {resp.Data.Key}})
+	assertStatus(t, code, http.StatusOK, "key at its tenant: expected status %d, got %d")
+	_, code = s.profileName("localhost", "/profile", http.Header{"X-Tenant-Id": {"globex"}, "X-Api-Key": {resp.Data.Key}})
+	assertStatus(t, code, http.StatusUnauthorized, "key at another tenant: expected status %d, got %d")
+}
+
+// TestTenantResolution verifies a tenant can be selected by header, path prefix or subdomain,
+// and that unknown tenants are rejected
+func TestTenantResolution(t *testing.T) {
+	s := newTenantServer(t)
+	token := s.register(t, "acme", "Acme")
+	authorization := "Bearer " + token
+
+	tests := []struct {
+		name   string
+		host   string
+		path   string
+		header http.Header
+		want   int
+	}{
+		{"header", "localhost", "/profile", http.Header{"X-Tenant-Id": {"ACME"}}, http.StatusOK},
+		{"path", "localhost", "/t/acme/profile", nil, http.StatusOK},
+		{"subdomain", "acme.example.com", "/profile", nil, http.StatusOK},
+		{"subdomain with port", "acme.example.com:8080", "/profile", nil, http.StatusOK},
+		{"header wins over subdomain", "globex.example.com", "/profile", http.Header{"X-Tenant-Id": {"acme"}}, http.StatusOK},
+		{"other subdomain", "globex.example.com", "/profile", nil, http.StatusUnauthorized},
+		{"no tenant", "localhost", "/profile", nil, http.StatusUnauthorized},
+		{"unknown header", "localhost", "/profile", http.Header{"X-Tenant-Id": {"initech"}}, http.StatusNotFound},
+		{"unknown path", "localhost", "/t/initech/profile", nil, http.StatusNotFound},
+		{"unknown subdomain", "initech.example.com", "/profile", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Authorization": {authorization}}
+			for k, v := range tt.header {
+				header[k] = v
+			}
+			_, code := s.profileName(tt.host, tt.path, header)
+			assertStatus(t, code, tt.want, "expected status %d, got %d")
+		})
+	}
+}
+
+// TestInMemoryUserRepositoryCopiesUsers verifies callers never share the stored users, so
+// changes only take effect through a successful Update
+func TestInMemoryUserRepositoryCopiesUsers(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	ann := user.NewUser(tenant.DefaultID, "Ann", "Lee", "ann@example.com", "hash")
+	bob := user.NewUser(tenant.DefaultID, "Bob", "Rivers", "bob@example.com", "hash")
+	for _, u := range []*user.User{ann, bob} {
+		if err := repo.Save(u); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	ann.Disabled = true
+	found, _ := repo.FindByID(tenant.DefaultID, ann.ID)
+	found.Roles = append(found.Roles, user.RoleAdmin)
+	if stored, _ := repo.FindByEmail(tenant.DefaultID, "ann@example.com"); stored.Disabled || stored.HasRole(user.RoleAdmin) {
+		t.Errorf("Expected changes outside Update not to be stored, got %+v", stored)
+	}
+
+	// A failed update leaves nothing half-applied
+	found.Email = "bob@example.com"
+	found.Password = "other-hash"
+	if err := repo.Update(found); err == nil {
+		t.Fatal("Expected an update to a taken email to fail")
+	}
+	if stored, _ := repo.FindByID(tenant.DefaultID, ann.ID); stored.Email != "ann@example.com" || stored.Password != "hash" {
+		t.Errorf("Expected the failed update not to be visible, got %+v", stored)
+	}
+
+	// Readers and writers do not share memory; run with -race to check
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u, err := repo.FindByID(tenant.DefaultID, ann.ID)
+			if err != nil {
+				t.Errorf("FindByID failed: %v", err)
+				return
+			}
+			u.Disabled = i%2 == 0
+			u.Roles = append(u.Roles, "role")
+			repo.Update(u)
+			repo.FindAll(tenant.DefaultID)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/session"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/user"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+const adminTestPassword = "Correct-Horse-9-Battery"
+
+// newAdminUserUseCase wires a user use case with refresh tokens and sessions, as operator
+// actions revoke both
+func newAdminUserUseCase(t *testing.T) (*usecase.UserUseCase, user.Repository, session.Repository) {
+	t.Helper()
+	userRepo := repository.NewInMemoryUserRepository()
+	sessionRepo := repository.NewInMemorySessionRepository()
+	users := usecase.NewUserUseCase(userRepo, auth.NewPasswordService(testArgonParams), testJWTService(),
+		usecase.WithRefreshTokens(repository.NewInMemoryRefreshTokenRepository(), time.Hour),
+		usecase.WithSessions(sessionRepo))
+	return users, userRepo, sessionRepo
+}
+
+// saveTestSession stores an active session of a user
+func saveTestSession(t *testing.T, sessions session.Repository, id, userID string) {
+	t.Helper()
+	now := time.Now()
+	if err := sessions.Save(&session.Session{ID: id, UserID: userID, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Save session failed: %v", err)
+	}
+}
+
+func TestCreateAdmin(t *testing.T) {
+	users, userRepo, _ := newAdminUserUseCase(t)
+
+	created, err := users.CreateAdmin(tenant.DefaultID, "Ops", "Admin", " Root@Example.com ", adminTestPassword)
+	if err != nil {
+		t.Fatalf("CreateAdmin failed: %v", err)
+	}
+	if created.Email != "root@example.com" || len(created.Roles) != 1 || created.Roles[0] != user.RoleAdmin {
+		t.Errorf("Expected an admin with a normalized email, got %+v", created)
+	}
+	stored, err := userRepo.FindByEmail(tenant.DefaultID, "root@example.com")
+	if err != nil || !stored.HasRole(user.RoleAdmin) || stored.Password == adminTestPassword {
+		t.Errorf("Expected the admin to be stored with a hashed password, got %+v", stored)
+	}
+	if _, err := users.Login(tenant.DefaultID, "root@example.com", adminTestPassword); err != nil {
+		t.Errorf("Expected the admin to log in, got %v", err)
+	}
+
+	if _, err := users.CreateAdmin(tenant.DefaultID, "Ops", "Admin", "root@example.com", adminTestPassword); !errors.Is(err, usecase.ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+
+	// Admins are held to the password policy of registration
+	_, err = users.CreateAdmin(tenant.DefaultID, "Ops", "Admin", "weak@example.com", "password")
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		t.Errorf("Expected a weak password to be rejected, got %v", err)
+	}
+}
+
+func TestSetPasswordRevokesRefreshTokens(t *testing.T) {
+	users, _, _ := newAdminUserUseCase(t)
+	if _, err := users.CreateAdmin(tenant.DefaultID, "Ops", "Admin", "root@example.com", adminTestPassword); err != nil {
+		t.Fatalf("CreateAdmin failed: %v", err)
+	}
+	login, err := users.Login(tenant.DefaultID, "root@example.com", adminTestPassword)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	if err := users.SetPassword(tenant.DefaultID, "root@example.com", "short"); err == nil {
+		t.Errorf("Expected the password policy to apply")
+	}
+	if err := users.SetPassword(tenant.DefaultID, "nobody@example.com", "Another-Strong-7-Phrase"); !errors.Is(err, usecase.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if err := users.SetPassword(tenant.DefaultID, "ROOT@example.com", "Another-Strong-7-Phrase"); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+
+	if _, err := users.Login(tenant.DefaultID, "root@example.com", "Another-Strong-7-Phrase"); err != nil {
+		t.Errorf("Expected the new password to work, got %v", err)
+	}
+	if _, err := users.Refresh(tenant.DefaultID, login.RefreshToken); !errors.Is(err, usecase.ErrInvalidRefreshToken) {
+		t.Errorf("Expected refresh tokens issued before the reset to be revoked, got %v", err)
+	}
+}
+
+func TestSetDisabledSignsOut(t *testing.T) {
+	users, userRepo, sessions := newAdminUserUseCase(t)
+	created, err := users.CreateAdmin(tenant.DefaultID, "Ops", "Admin", "root@example.com", adminTestPassword)
+	if err != nil {
+		t.Fatalf("CreateAdmin failed: %v", err)
+	}
+	login, err := users.Login(tenant.DefaultID, "root@example.com", adminTestPassword)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	saveTestSession(t, sessions, "s1", created.ID)
+
+	disabled, err := users.SetDisabled(tenant.DefaultID, "root@example.com", true)
+	if err != nil {
+		t.Fatalf("SetDisabled failed: %v", err)
+	}
+	if !disabled.Disabled {
+		t.Errorf("Expected the account to be disabled, got %+v", disabled)
+	}
+	if _, err := users.Login(tenant.DefaultID, "root@example.com", adminTestPassword); err == nil {
+		t.Errorf("Expected a disabled account not to log in")
+	}
+	if _, err := users.Refresh(tenant.DefaultID, login.RefreshToken); !errors.Is(err, usecase.ErrInvalidRefreshToken) {
+		t.Errorf("Expected the refresh tokens to be revoked, got %v", err)
+	}
+	if remaining, _ := sessions.FindByUser(created.ID); len(remaining) != 0 {
+		t.Errorf("Expected the sessions to end, got %d", len(remaining))
+	}
+
+	stored, _ := userRepo.FindByID(tenant.DefaultID, created.ID)
+	if !stored.Disabled {
+		t.Errorf("Expected the stored user to be disabled")
+	}
+
+	enabled, err := users.SetDisabled(tenant.DefaultID, "root@example.com", false)
+	if err != nil || enabled.Disabled {
+		t.Fatalf("Expected the account to be enabled again, got %+v, %v", enabled, err)
+	}
+	if _, err := users.Login(tenant.DefaultID, "root@example.com", adminTestPassword); err != nil {
+		t.Errorf("Expected the enabled account to log in, got %v", err)
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	users, _, sessions := newAdminUserUseCase(t)
+	created, err := users.CreateAdmin(tenant.DefaultID, "Ops", "Admin", "root@example.com", adminTestPassword)
+	if err != nil {
+		t.Fatalf("CreateAdmin failed: %v", err)
+	}
+	other, err := users.CreateAdmin(tenant.DefaultID, "Other", "Admin", "other@example.com", adminTestPassword)
+	if err != nil {
+		t.Fatalf("CreateAdmin failed: %v", err)
+	}
+	saveTestSession(t, sessions, "s1", created.ID)
+	saveTestSession(t, sessions, "s2", created.ID)
+	saveTestSession(t, sessions, "s3", other.ID)
+
+	revoked, err := users.RevokeSessions(tenant.DefaultID, "root@example.com")
+	if err != nil {
+		t.Fatalf("RevokeSessions failed: %v", err)
+	}
+	if revoked != 2 {
+		t.Errorf("Expected 2 sessions to be revoked, got %d", revoked)
+	}
+	if remaining, _ := sessions.FindByUser(other.ID); len(remaining) != 1 {
+		t.Errorf("Expected other users to stay signed in, got %d sessions", len(remaining))
+	}
+	if _, err := users.RevokeSessions("acme", "root@example.com"); !errors.Is(err, usecase.ErrUserNotFound) {
+		t.Errorf("Expected users of other tenants not to be found, got %v", err)
+	}
+}
+
+func TestIssueDebugToken(t *testing.T) {
+	users, _, _ := newAdminUserUseCase(t)
+	created, err := users.CreateAdmin(tenant.DefaultID, "Ops", "Admin", "root@example.com", adminTestPassword)
+	if err != nil {
+		t.Fatalf("CreateAdmin failed: %v", err)
+	}
+
+	token, err := users.IssueDebugToken(tenant.DefaultID, "root@example.com", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("IssueDebugToken failed: %v", err)
+	}
+	claims, err := testJWTService().ValidateToken(token.Token)
+	if err != nil {
+		t.Fatalf("Expected a valid token, got %v", err)
+	}
+	if claims.Subject != created.ID || claims.Email != "root@example.com" || claims.TenantID != tenant.DefaultID || len(claims.Roles) != 1 {
+		t.Errorf("Expected the claims of a login, got %+v", claims)
+	}
+	if lifetime := claims.ExpiresAt.Sub(claims.IssuedAt.Time); lifetime != 5*time.Minute {
+		t.Errorf("Expected a token valid for 5m, got %s", lifetime)
+	}
+
+	for _, ttl := range []time.Duration{0, usecase.MaxDebugTokenTTL + time.Second} {
+		var errs validation.Errors
+		if _, err := users.IssueDebugToken(tenant.DefaultID, "root@example.com", ttl); !errors.As(err, &errs) {
+			t.Errorf("Expected a ttl of %s to be rejected, got %v", ttl, err)
+		}
+	}
+
+	if _, err := users.SetDisabled(tenant.DefaultID, "root@example.com", true); err != nil {
+		t.Fatalf("SetDisabled failed: %v", err)
+	}
+	if _, err := users.IssueDebugToken(tenant.DefaultID, "root@example.com", time.Minute); !errors.Is(err, usecase.ErrAccountDisabled) {
+		t.Errorf("Expected no tokens for a disabled account, got %v", err)
+	}
+}
+
+func TestWriteSigningKey(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.pem"), filepath.Join(dir, "new.pem")
+	oldID, err := auth.WriteSigningKey(oldPath)
+	if err != nil {
+		t.Fatalf("WriteSigningKey failed: %v", err)
+	}
+	newID, err := auth.WriteSigningKey(newPath)
+	if err != nil {
+		t.Fatalf("WriteSigningKey failed: %v", err)
+	}
+	if _, err := auth.WriteSigningKey(oldPath); err == nil {
+		t.Errorf("Expected an existing key file not to be overwritten")
+	}
+
+	// The new key signs and the old one stays published
+	keys, err := auth.LoadKeySet(newPath, oldPath)
+	if err != nil {
+		t.Fatalf("LoadKeySet failed: %v", err)
+	}
+	jwks := keys.JWKS().Keys
+	if len(jwks) != 2 || jwks[0].KeyID != newID || jwks[1].KeyID != oldID {
+		t.Errorf("Expected keys %s and %s, got %+v", newID, oldID, jwks)
+	}
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra/router"
+)
+
+// TestRegistrationValidationIsConsistent verifies both transports return the same field errors
+func TestRegistrationValidationIsConsistent(t *testing.T) {
+	uc := newTestUserUseCase(t)
+
+	netHandler := handler.NewUserHandler(uc)
+	graRouter := router.New()
+	graRouter.POST("/register", handler.NewGraUserHandler(uc).Register)
+
+	cases := []struct {
+		name   string
+		body   string
+		fields []string
+	}{
+		{"missing fields", `{}`, []string{"first_name", "last_name", "email", "password"}},
+		{"bad email", `{"first_name":"Ann","last_name":"Lee","email":"not-an-email","password":"longenough"}`, []string{"email"}},
+		{"bad name", `{"first_name":"Ann<script>","last_name":"Lee","email":"ann@example.com","password":"longenough"}`, []string{"first_name"}},
+		{"short password", `{"first_name":"Ann","last_name":"Lee","email":"ann@example.com","password":"short"}`, []string{"password"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			netRec := httptest.NewRecorder()
+			netHandler.Register(netRec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(tc.body)))
+
+			graRec := httptest.NewRecorder()
+			graRouter.ServeHTTP(graRec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(tc.body)))
+
+			netFields := fieldErrors(t, netRec)
+			graFields := fieldErrors(t, graRec)
+
+			assertStatus(t, netRec.Code, http.StatusBadRequest, "Expected status %d, got %d")
+			assertStatus(t, graRec.Code, http.StatusBadRequest, "Expected status %d, got %d")
+
+			if !reflect.DeepEqual(netFields, graFields) {
+				t.Errorf("Transports disagree: net/http %v, gra %v", netFields, graFields)
+			}
+			if !reflect.DeepEqual(netFields, tc.fields) {
+				t.Errorf("Expected field errors %v, got %v", tc.fields, netFields)
+			}
+		})
+	}
+}
+
+// TestRegistrationNormalizesEmail verifies emails are matched case-insensitively
+func TestRegistrationNormalizesEmail(t *testing.T) {
+	uc := newTestUserUseCase(t)
+
+	resp, err := uc.Register(tenant.DefaultID, "Ann", "Lee", "  Ann@Example.COM ", "longenough")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if resp.Email != "ann@example.com" {
+		t.Errorf("Expected normalized email, got %q", resp.Email)
+	}
+
+	if _, err := uc.Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", "longenough"); err == nil {
+		t.Error("Expected duplicate registration to fail")
+	}
+
+	if _, err := uc.Login(tenant.DefaultID, "ANN@example.com", "longenough"); err != nil {
+		t.Errorf("Expected login with differently cased email to succeed: %v", err)
+	}
+}
+
+// fieldErrors returns the distinct field names reported in a validation error response
+func fieldErrors(t *testing.T, rec *httptest.ResponseRecorder) []string {
+	t.Helper()
+
+	var response struct {
+		Errors []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	var fields []string
+	seen := map[string]bool{}
+	for _, e := range response.Errors {
+		if !seen[e.Field] {
+			seen[e.Field] = true
+			fields = append(fields, e.Field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,342 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lamboktulussimamora/gra-project/internal/domain/auth"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/event"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/tenant"
+	"github.com/lamboktulussimamora/gra-project/internal/domain/webhook"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/handler"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/repository"
+	"github.com/lamboktulussimamora/gra-project/internal/interface/sender"
+	"github.com/lamboktulussimamora/gra-project/internal/usecase"
+	"github.com/lamboktulussimamora/gra-project/internal/validation"
+)
+
+// webhookReceiver is an endpoint that records the payloads it receives and answers with status
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	received []receivedWebhook
+	server   *httptest.Server
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T, status int) *webhookReceiver {
+	rcv := &webhookReceiver{status: status}
+	rcv.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		rcv.received = append(rcv.received, receivedWebhook{header: r.Header.Clone(), body: body})
+		w.WriteHeader(rcv.status)
+	}))
+	t.Cleanup(rcv.server.Close)
+	return rcv
+}
+
+func (rcv *webhookReceiver) setStatus(status int) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.status = status
+}
+
+func (rcv *webhookReceiver) requests() []receivedWebhook {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]receivedWebhook(nil), rcv.received...)
+}
+
+func newTestWebhookUseCase(maxAttempts int) *usecase.WebhookUseCase {
+	config := usecase.DefaultWebhookConfig()
+	config.MaxAttempts = maxAttempts
+	config.InitialBackoff = 0
+	config.AllowHTTP = true
+	// The test receivers listen on loopback
+	config.AllowPrivateNetworks = true
+	return usecase.NewWebhookUseCase(repository.NewInMemoryWebhookEndpointRepository(),
+		repository.NewInMemoryWebhookDeliveryRepository(), sender.NewHTTPSender(5*time.Second, sender.WithPrivateNetworks()), config)
+}
+
+func TestWebhookDeliversSignedUserEvents(t *testing.T) {
+	rcv := newWebhookReceiver(t, http.StatusNoContent)
+	webhooks := newTestWebhookUseCase(3)
+	endpoint, err := webhooks.CreateEndpoint(authzAdmin(), usecase.WebhookEndpointInput{
+		URL:    rcv.server.URL,
+		Events: []string{webhook.EventUserRegistered},
+	})
+	if err != nil {
+		t.Fatalf("CreateEndpoint failed: %v", err)
+	}
+
+	userRepo := repository.NewInMemoryUserRepository()
+	bus := event.NewBus()
+	webhooks.SubscribeUserEvents(bus, repository.NewInMemoryProcessedEventRepository())
+	relay := usecase.NewEventRelay(userRepo, bus, usecase.DefaultEventRelayConfig())
+	users := usecase.NewUserUseCase(userRepo, auth.NewPasswordService(testArgonParams),
+		testJWTService(), usecase.WithEventPublisher(webhooks))
+	if _, err := users.Register(tenant.DefaultID, "Ann", "Lee", "ann@example.com", testPassword); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	login, err := users.Login(tenant.DefaultID, "ann@example.com", testPassword)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	if n, err := relay.RelayPending(); err != nil || n != 1 {
+		t.Fatalf("Expected the registration to be relayed, got %d, %v", n, err)
+	}
+	if n, err := webhooks.DeliverDue(); err != nil || n != 1 {
+		t.Fatalf("Expected one delivery of the subscribed event, got %d, %v", n, err)
+	}
+	reqs := rcv.requests()
+	if len(reqs) != 1 {
+		t.Fatalf("Expected the endpoint to receive one request, got %d", len(reqs))
+	}
+
+	got := reqs[0]
+	if err := webhook.Verify(endpoint.Secret, got.header.Get(webhook.SignatureHeader), got.body, time.Now(), 5*time.Minute); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+	if err := webhook.Verify(endpoint.Secret, got.header.Get(webhook.SignatureHeader), append(got.body, ' '), time.Now(), 5*time.Minute); err == nil {
+		t.Error("Expected a modified payload to fail verification")
+	}
+	if err := webhook.Verify(endpoint.Secret, got.header.Get(webhook.SignatureHeader), got.body, time.Now().Add(time.Hour), 5*time.Minute); err == nil {
+		t.Error("Expected an old signature to fail verification")
+	}
+
+	var payload usecase.WebhookPayload
+	if err := json.Unmarshal(got.body, &payload); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	if payload.Type != webhook.EventUserRegistered || payload.Data["user_id"] != mustClaims(t, login.Token).Subject || payload.Data["email"] != "ann@example.com" {
+		t.Errorf("Unexpected payload %s", got.body)
+	}
+	if got.header.Get(webhook.EventHeader) != webhook.EventUserRegistered {
+		t.Errorf("Expected the event type header, got %q", got.header.Get(webhook.EventHeader))
+	}
+
+	deliveries, err := webhooks.ListDeliveries(authzAdmin(), webhook.DeliveryFilter{EndpointID: endpoint.ID})
+	if err != nil || len(deliveries) != 1 || deliveries[0].Status != webhook.StatusSucceeded {
+		t.Fatalf("Expected one succeeded delivery in the log, got %v, %v", deliveries, err)
+	}
+	if len(deliveries[0].Attempts) != 1 || deliveries[0].Attempts[0].StatusCode != http.StatusNoContent {
+		t.Errorf("Expected the attempt to be logged with its status, got %+v", deliveries[0].Attempts)
+	}
+}
+
+func TestWebhookRetriesDeadLettersAndRedelivers(t *testing.T) {
+	rcv := newWebhookReceiver(t, http.StatusInternalServerError)
+	webhooks := newTestWebhookUseCase(3)
+	if _, err := webhooks.CreateEndpoint(authzAdmin(), usecase.WebhookEndpointInput{URL: rcv.server.URL}); err != nil {
+		t.Fatalf("CreateEndpoint failed: %v", err)
+	}
+	webhooks.Publish(webhook.NewEvent(tenant.DefaultID, webhook.EventUserDeleted, map[string]string{"user_id": "42"}))
+
+	for i := 0; i < 5; i++ {
+		if _, err := webhooks.DeliverDue(); err != nil {
+			t.Fatalf("DeliverDue failed: %v", err)
+		}
+	}
+	if got := len(rcv.requests()); got != 3 {
+		t.Fatalf("Expected 3 attempts before dead-lettering, got %d", got)
+	}
+
+	dead, err := webhooks.ListDeliveries(authzAdmin(), webhook.DeliveryFilter{Status: webhook.StatusDead})
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("Expected one dead-lettered delivery, got %v, %v", dead, err)
+	}
+
+	// Redelivery goes through the API and starts a fresh delivery of the same payload
+	rcv.setStatus(http.StatusOK)
+	webhookHandler := handler.NewWebhookHandler(webhooks)
+	mux := http.NewServeMux()
+	mux.Handle("/webhook-deliveries/", withTestPrincipal(authzAdmin(), http.HandlerFunc(webhookHandler.Delivery)))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook-deliveries/"+dead[0].ID+"/redeliver", nil))
+	assertStatus(t, rec.Code, http.StatusAccepted, "Expected status %d for a redelivery, got %d")
+
+	if n, err := webhooks.DeliverDue(); err != nil || n != 1 {
+		t.Fatalf("Expected the redelivery to be attempted, got %d, %v", n, err)
+	}
+	reqs := rcv.requests()
+	if string(reqs[len(reqs)-1].body) != string(dead[0].Payload) {
+		t.Error("Expected the redelivery to send the original payload")
+	}
+
+	succeeded, _ := webhooks.ListDeliveries(authzAdmin(), webhook.DeliveryFilter{Status: webhook.StatusSucceeded})
+	if len(succeeded) != 1 || succeeded[0].RedeliveryOf != dead[0].ID {
+		t.Errorf("Expected a succeeded redelivery of the dead delivery, got %v", succeeded)
+	}
+	original, _ := webhooks.GetDelivery(authzAdmin(), dead[0].ID)
+	if original.Status != webhook.StatusDead || len(original.Attempts) != 3 {
+		t.Errorf("Expected the original delivery and its log to be kept, got %+v", original)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	rcv := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	config := usecase.DefaultWebhookConfig()
+	config.AllowHTTP = true
+	config.AllowPrivateNetworks = true
+	webhooks := usecase.NewWebhookUseCase(repository.NewInMemoryWebhookEndpointRepository(),
+		repository.NewInMemoryWebhookDeliveryRepository(), sender.NewHTTPSender(5*time.Second, sender.WithPrivateNetworks()), config)
+	if _, err := webhooks.CreateEndpoint(authzAdmin(), usecase.WebhookEndpointInput{URL: rcv.server.URL}); err != nil {
+		t.Fatalf("CreateEndpoint failed: %v", err)
+	}
+	webhooks.Publish(webhook.NewEvent(tenant.DefaultID, webhook.EventUserLoggedIn, map[string]string{"user_id": "42"}))
+
+	webhooks.DeliverDue()
+	if n, _ := webhooks.DeliverDue(); n != 0 {
+		t.Error("Expected a failed delivery to wait before its next attempt")
+	}
+	pending, _ := webhooks.ListDeliveries(authzAdmin(), webhook.DeliveryFilter{Status: webhook.StatusPending})
+	if len(pending) != 1 {
+		t.Fatalf("Expected the failed delivery to stay pending, got %v", pending)
+	}
+	wait := time.Until(pending[0].NextAttemptAt)
+	if wait < 25*time.Second || wait > config.InitialBackoff {
+		t.Errorf("Expected the first retry after about %s, got %s", config.InitialBackoff, wait)
+	}
+}
+
+func TestWebhookEndpointValidationAndAccess(t *testing.T) {
+	config := usecase.DefaultWebhookConfig()
+	webhooks := usecase.NewWebhookUseCase(repository.NewInMemoryWebhookEndpointRepository(),
+		repository.NewInMemoryWebhookDeliveryRepository(), sender.NewHTTPSender(time.Second), config)
+
+	if _, err := webhooks.CreateEndpoint(authzUser("alice"), usecase.WebhookEndpointInput{URL: "https://hooks.example.com"}); !errors.Is(err, usecase.ErrForbidden) {
+		t.Errorf("Expected non-admins to be refused, got %v", err)
+	}
+
+	var errs validation.Errors
+	if _, err := webhooks.CreateEndpoint(authzAdmin(), usecase.WebhookEndpointInput{URL: "http://hooks.example.com"}); !errors.As(err, &errs) {
+		t.Errorf("Expected plain http URLs to be rejected, got %v", err)
+	}
+	if _, err := webhooks.CreateEndpoint(authzAdmin(), usecase.WebhookEndpointInput{URL: "https://hooks.example.com", Events: []string{"user.exploded"}}); !errors.As(err, &errs) {
+		t.Errorf("Expected unknown event types to be rejected, got %v", err)
+	}
+	for _, url := range []string{"https://localhost/hooks", "https://127.0.0.1/hooks", "https://10.0.0.7/hooks", "https://169.254.169.254/latest", "https://[::1]/hooks"} {
+		if _, err := webhooks.CreateEndpoint(authzAdmin(), usecase.WebhookEndpointInput{URL: url}); !errors.As(err, &errs) {
+			t.Errorf("Expected the private URL %s to be rejected, got %v", url, err)
+		}
+	}
+
+	e, err := webhooks.CreateEndpoint(authzAdmin(), usecase.WebhookEndpointInput{URL: "https://hooks.example.com"})
+	if err != nil {
+		t.Fatalf("CreateEndpoint failed: %v", err)
+	}
+	rotated, err := webhooks.RotateSecret(authzAdmin(), e.ID)
+	if err != nil || rotated.Secret == e.Secret {
+		t.Errorf("Expected RotateSecret to issue a new secret, got %v", err)
+	}
+
+	other := authzAdmin()
+	other.TenantID = "acme"
+	if _, err := webhooks.GetEndpoint(other, e.ID); !errors.Is(err, webhook.ErrEndpointNotFound) {
+		t.Errorf("Expected endpoints to be invisible to other tenants, got %v", err)
+	}
+}
+
+// TestWebhookSenderRefusesPrivateAddresses verifies the sender checks the address a host name
+// resolves to when it connects, so a name registered while public cannot be pointed inside later
+func TestWebhookSenderRefusesPrivateAddresses(t *testing.T) {
+	rcv := newWebhookReceiver(t, http.StatusNoContent)
+	_, port, _ := net.SplitHostPort(rcv.server.Listener.Addr().String())
+	req := webhook.Request{URL: "http://localhost:" + port + "/hooks", Body: []byte("{}")}
+
+	if _, err := sender.NewHTTPSender(time.Second).Send(req); !errors.Is(err, sender.ErrPrivateAddress) {
+		t.Errorf("Expected a loopback delivery to be refused, got %v", err)
+	}
+	if len(rcv.requests()) != 0 {
+		t.Error("Expected the loopback endpoint to receive nothing")
+	}
+
+	if status, err := sender.NewHTTPSender(time.Second, sender.WithPrivateNetworks()).Send(req); err != nil || status != http.StatusNoContent {
+		t.Errorf("Expected private networks to be reachable when allowed, got %d, %v", status, err)
+	}
+}
+
+// slowSender answers every request with 204, taking delay for the slow URL, and records the
+// requests per URL and how many were in flight at once
+type slowSender struct {
+	slowURL string
+	delay   time.Duration
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	sent        map[string]int
+}
+
+func (s *slowSender) Send(req webhook.Request) (int, error) {
+	s.mu.Lock()
+	s.inFlight++
+	s.maxInFlight = max(s.maxInFlight, s.inFlight)
+	s.sent[req.URL]++
+	s.mu.Unlock()
+
+	if req.URL == s.slowURL {
+		time.Sleep(s.delay)
+	}
+
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+	return http.StatusNoContent, nil
+}
+
+func (s *slowSender) count(url string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent[url]
+}
+
+// TestWebhookDeliveriesAreBoundedPerEndpoint verifies a backlog at a slow endpoint neither fills
+// a DeliverDue call nor holds up deliveries to other endpoints
+func TestWebhookDeliveriesAreBoundedPerEndpoint(t *testing.T) {
+	const slowURL, fastURL = "https://slow.example.com/hooks", "https://fast.example.com/hooks"
+	send := &slowSender{slowURL: slowURL, delay: 10 * time.Millisecond, sent: map[string]int{}}
+	config := usecase.DefaultWebhookConfig()
+	webhooks := usecase.NewWebhookUseCase(repository.NewInMemoryWebhookEndpointRepository(),
+		repository.NewInMemoryWebhookDeliveryRepository(), send, config)
+
+	if _, err := webhooks.CreateEndpoint(authzAdmin(), usecase.WebhookEndpointInput{URL: slowURL, Events: []string{webhook.EventUserLoggedIn}}); err != nil {
+		t.Fatalf("CreateEndpoint failed: %v", err)
+	}
+	if _, err := webhooks.CreateEndpoint(authzAdmin(), usecase.WebhookEndpointInput{URL: fastURL, Events: []string{webhook.EventUserRegistered}}); err != nil {
+		t.Fatalf("CreateEndpoint failed: %v", err)
+	}
+	backlog := config.MaxPerEndpoint + 2
+	for i := 0; i < backlog; i++ {
+		webhooks.Publish(webhook.NewEvent(tenant.DefaultID, webhook.EventUserLoggedIn, map[string]string{"user_id": "42"}))
+	}
+	webhooks.Publish(webhook.NewEvent(tenant.DefaultID, webhook.EventUserRegistered, map[string]string{"user_id": "43"}))
+
+	if n, err := webhooks.DeliverDue(); err != nil || n != config.MaxPerEndpoint+1 {
+		t.Fatalf("Expected %d deliveries to the slow endpoint and one to the other, got %d, %v", config.MaxPerEndpoint, n, err)
+	}
+	if send.count(fastURL) != 1 || send.count(slowURL) != config.MaxPerEndpoint {
+		t.Errorf("Unexpected requests per endpoint: %v", send.sent)
+	}
+	if send.maxInFlight < 2 {
+		t.Errorf("Expected endpoints to be sent to at the same time, got %d request in flight at most", send.maxInFlight)
+	}
+
+	if n, err := webhooks.DeliverDue(); err != nil || n != backlog-config.MaxPerEndpoint {
+		t.Errorf("Expected the rest of the backlog to be delivered next, got %d, %v", n, err)
+	}
+}